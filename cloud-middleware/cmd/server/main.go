@@ -45,6 +45,7 @@ func main() {
 		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler: application.Router(),
 	}
+	server.RegisterOnShutdown(application.CloseEventStreams)
 
 	go func() {
 		log.Printf("Starting HTTP server on port %d", cfg.Server.Port)
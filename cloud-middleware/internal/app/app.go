@@ -3,17 +3,26 @@ package app
 import (
 	"context"
 	"fmt"
+	"log"
 	"net"
+	nethttp "net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 	"gorm.io/gorm"
 
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/events"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/handler/http"
 	grpchandler "github.com/myczh-1/lazy-ctrl-cloud/internal/handler/grpc"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/idempotency"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/ratelimit"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
 	gatewayPb "github.com/myczh-1/lazy-ctrl-cloud/proto"
@@ -25,17 +34,35 @@ type Application struct {
 	db         *gorm.DB
 	grpcServer *grpc.Server
 	
+	// Login and execution throttling and execution replay; redisClient is nil
+	// when no feature uses Redis or it was unreachable at startup
+	rateLimiter      ratelimit.Limiter
+	idempotencyStore idempotency.Store
+	redisClient      *redis.Client
+	
+	// Device and execution events, streamed to dashboards
+	eventBus *events.Bus
+	
 	// Services
+	jwtService     *auth.JWTService
 	userService    service.UserService
+	apiKeyService  *service.APIKeyService
 	deviceService  *service.DeviceService
 	gatewayService *service.GatewayService
 	
 	// HTTP handlers
 	userHandler    *http.UserHandler
+	deviceHandler  *http.DeviceHandler
 	gatewayHandler *http.GatewayHandler
+	groupHandler   *http.GroupHandler
+	apiKeyHandler  *http.APIKeyHandler
 	
 	// gRPC handlers
 	grpcGatewayHandler *grpchandler.GatewayHandler
+	
+	// Background offline sweeper, stopped by Stop
+	stopSweeper context.CancelFunc
+	sweeperDone chan struct{}
 }
 
 // NewApplication creates a new application instance
@@ -54,11 +81,17 @@ func NewApplication(cfg *config.Config) (*Application, error) {
 		return nil, fmt.Errorf("failed to initialize services: %w", err)
 	}
 	
+	app.initRedis()
+	app.initRateLimiter()
+	app.initIdempotencyStore()
+	
 	// Initialize handlers
 	if err := app.initHandlers(); err != nil {
 		return nil, fmt.Errorf("failed to initialize handlers: %w", err)
 	}
 	
+	app.startOfflineSweeper()
+	
 	return app, nil
 }
 
@@ -78,11 +111,37 @@ func (a *Application) initServices() error {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(a.db)
 	deviceRepo := repository.NewDeviceRepository(a.db)
+	apiKeyRepo := repository.NewAPIKeyRepository(a.db)
 	
 	// Initialize services
-	a.userService = service.NewUserService(userRepo, a.config.JWT)
+	jwtService, err := auth.NewJWTService(a.config.JWT)
+	if err != nil {
+		return fmt.Errorf("failed to initialize JWT service: %w", err)
+	}
+	a.jwtService = jwtService
+	userService, err := service.NewUserService(userRepo, jwtService, a.config.JWT, a.config.TwoFactor, a.config.WebAuthn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize user service: %w", err)
+	}
+	a.userService = userService
+	a.eventBus = events.NewBus()
 	a.deviceService = service.NewDeviceService(deviceRepo)
-	a.gatewayService = service.NewGatewayService()
+	a.deviceService.SetEventBus(a.eventBus)
+	blockedPatterns := append([]string(nil), a.config.Gateway.BlockedPatterns...)
+	a.deviceService.SetBlockedPatterns(append(blockedPatterns, a.config.Gateway.ExtraBlockedPatterns...))
+	a.apiKeyService = service.NewAPIKeyService(apiKeyRepo, userRepo, a.deviceService)
+	gatewayService, err := service.NewGatewayService(a.config.Gateway, a.deviceService)
+	if err != nil {
+		return fmt.Errorf("failed to initialize gateway service: %w", err)
+	}
+	a.gatewayService = gatewayService
+	
+	// Keep the stored online flag, and the events published for it, in step with the connections
+	gatewayService.OnStatusChange(func(deviceID string, healthy bool) {
+		if err := a.deviceService.UpdateDeviceStatus(deviceID, healthy); err != nil {
+			log.Printf("Failed to update status of device %s: %v", deviceID, err)
+		}
+	})
 	
 	// Initialize default admin user
 	if err := a.userService.InitializeSystem(); err != nil {
@@ -92,18 +151,108 @@ func (a *Application) initServices() error {
 	return nil
 }
 
+// initRedis connects to Redis when an enabled feature uses the redis backend.
+// Redis is only used when it has a host and answers at startup, otherwise
+// those features keep their state per replica
+func (a *Application) initRedis() {
+	usesRedis := (a.config.RateLimit.Enabled && a.config.RateLimit.Backend == "redis") ||
+		(a.config.Idempotency.Enabled && a.config.Idempotency.Backend == "redis")
+	if !usesRedis || a.config.Redis.Host == "" {
+		return
+	}
+	
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", a.config.Redis.Host, a.config.Redis.Port),
+		Password: a.config.Redis.Password,
+		DB:       a.config.Redis.DB,
+	})
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Printf("Redis unavailable, rate limiting and idempotency keys fall back to memory: %v", err)
+		client.Close()
+		return
+	}
+	a.redisClient = client
+}
+
+// initRateLimiter picks the rate limit backend
+func (a *Application) initRateLimiter() {
+	cfg := a.config.RateLimit
+	if !cfg.Enabled {
+		return
+	}
+	
+	if cfg.Backend == "redis" && a.redisClient != nil {
+		a.rateLimiter = ratelimit.NewRedisLimiter(a.redisClient, cfg.Prefix)
+		return
+	}
+	a.rateLimiter = ratelimit.NewMemoryLimiter()
+}
+
+// initIdempotencyStore picks the backend of execution idempotency keys
+func (a *Application) initIdempotencyStore() {
+	cfg := a.config.Idempotency
+	if !cfg.Enabled {
+		return
+	}
+	
+	if cfg.Backend == "redis" && a.redisClient != nil {
+		a.idempotencyStore = idempotency.NewRedisStore(a.redisClient, cfg.Prefix)
+		return
+	}
+	a.idempotencyStore = idempotency.NewMemoryStore()
+}
+
 // initHandlers initializes all handlers
 func (a *Application) initHandlers() error {
 	// HTTP handlers
 	a.userHandler = http.NewUserHandler(a.userService)
-	a.gatewayHandler = http.NewGatewayHandler(a.gatewayService, a.deviceService)
+	if len(a.config.OAuth.Providers) > 0 {
+		providers := make(map[string]*auth.OAuthProvider, len(a.config.OAuth.Providers))
+		for name, providerCfg := range a.config.OAuth.Providers {
+			provider, err := auth.NewOAuthProvider(name, providerCfg)
+			if err != nil {
+				return err
+			}
+			providers[name] = provider
+		}
+		a.userHandler.SetOAuthProviders(providers, a.jwtService.OAuthStateKey())
+	}
+	a.deviceHandler = http.NewDeviceHandler(a.deviceService, a.userService)
+	a.gatewayHandler = http.NewGatewayHandler(a.gatewayService, a.deviceService, a.userService, a.eventBus)
+	a.groupHandler = http.NewGroupHandler(a.gatewayService, a.deviceService)
+	a.apiKeyHandler = http.NewAPIKeyHandler(a.apiKeyService)
 	
 	// gRPC handlers
 	a.grpcGatewayHandler = grpchandler.NewGatewayHandler(a.gatewayService, a.deviceService)
+	a.grpcGatewayHandler.SetEnrollmentTokens(a.config.Gateway.Enrollment.Tokens)
 	
 	return nil
 }
 
+// startOfflineSweeper starts marking devices offline once they stop reporting,
+// unless the threshold is 0
+func (a *Application) startOfflineSweeper() {
+	offline := a.config.Gateway.Offline
+	if offline.Threshold <= 0 {
+		return
+	}
+	interval := time.Duration(offline.SweepInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopSweeper = cancel
+	a.sweeperDone = make(chan struct{})
+	go func() {
+		defer close(a.sweeperDone)
+		a.deviceService.RunOfflineSweeper(ctx, time.Duration(offline.Threshold)*time.Second, interval)
+	}()
+}
+
 // Router returns the HTTP router
 func (a *Application) Router() *gin.Engine {
 	router := gin.New()
@@ -113,10 +262,37 @@ func (a *Application) Router() *gin.Engine {
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	
-	// Health check
+	// Health check: /health is a cheap liveness probe, /health/ready checks dependencies
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy"})
 	})
+	router.GET("/health/ready", a.readinessCheck)
+	
+	// Public keys for verifying RS256 tokens
+	if jwks := a.jwtService.JWKS(); jwks != nil {
+		router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+			c.JSON(200, jwks)
+		})
+	}
+	
+	// Routes open to API keys name the scope they need
+	authRequired := middleware.AuthRequired(a.userService, a.apiKeyService)
+	readScope := middleware.AuthRequired(a.userService, a.apiKeyService, model.APIKeyScopeRead)
+	executeScope := middleware.AuthRequired(a.userService, a.apiKeyService, model.APIKeyScopeExecute)
+	
+	// Rate limits, no-ops when disabled
+	limits := a.config.RateLimit
+	loginLimit := middleware.RateLimit(a.rateLimiter, "login", limits.Login.Limit,
+		time.Duration(limits.Login.Window)*time.Second, middleware.LoginRateLimitKey)
+	// Second factors share the login limit, counted per challenged user
+	twoFactorLimit := middleware.RateLimit(a.rateLimiter, "2fa", limits.Login.Limit,
+		time.Duration(limits.Login.Window)*time.Second, middleware.TwoFactorRateLimitKey(a.jwtService))
+	executeLimit := middleware.RateLimit(a.rateLimiter, "execute", limits.Execute.Limit,
+		time.Duration(limits.Execute.Window)*time.Second, middleware.UserRateLimitKey)
+	
+	// Replays retried executions carrying an Idempotency-Key, a no-op when disabled
+	executeOnce := middleware.Idempotency(a.idempotencyStore,
+		time.Duration(a.config.Idempotency.TTL)*time.Second, middleware.DeviceIdempotencyScope)
 	
 	// API routes
 	v1 := router.Group("/api/v1")
@@ -124,31 +300,52 @@ func (a *Application) Router() *gin.Engine {
 		// Authentication routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/login", a.userHandler.Login)
+			auth.POST("/login", loginLimit, a.userHandler.Login)
 			auth.POST("/refresh", a.userHandler.RefreshToken)
-			auth.POST("/logout", middleware.AuthRequired(), a.userHandler.Logout)
+			auth.POST("/logout", authRequired, a.userHandler.Logout)
+			auth.GET("/oauth/:provider/login", a.userHandler.OAuthLogin)
+			auth.GET("/oauth/:provider/callback", a.userHandler.OAuthCallback)
+			auth.POST("/2fa", twoFactorLimit, a.userHandler.CompleteTwoFactorLogin)
+			auth.POST("/webauthn/begin", loginLimit, a.userHandler.BeginWebAuthnLogin)
+			auth.POST("/webauthn/finish", loginLimit, a.userHandler.FinishWebAuthnLogin)
 		}
 		
 		// User profile routes
-		user := v1.Group("/user", middleware.AuthRequired())
+		user := v1.Group("/user", authRequired)
 		{
 			user.GET("/profile", a.userHandler.GetProfile)
 			user.PUT("/profile", a.userHandler.UpdateProfile)
 			user.POST("/change-password", a.userHandler.ChangePassword)
+			user.POST("/2fa/enroll", a.userHandler.EnrollTwoFactor)
+			user.POST("/2fa/verify", a.userHandler.ConfirmTwoFactor)
+			user.POST("/webauthn/register/begin", a.userHandler.BeginWebAuthnRegistration)
+			user.POST("/webauthn/register/finish", a.userHandler.FinishWebAuthnRegistration)
+			user.GET("/webauthn/credentials", a.userHandler.ListWebAuthnCredentials)
+			user.DELETE("/webauthn/credentials/:credential_id", a.userHandler.DeleteWebAuthnCredential)
+			user.GET("/sessions", a.userHandler.ListSessions)
+			user.DELETE("/sessions/:session_id", a.userHandler.RevokeSession)
+			user.POST("/api-keys", a.apiKeyHandler.CreateAPIKey)
+			user.GET("/api-keys", a.apiKeyHandler.ListAPIKeys)
+			user.DELETE("/api-keys/:key_id", a.apiKeyHandler.RevokeAPIKey)
 		}
 		
 		// Admin routes for user management
-		admin := v1.Group("/admin", middleware.AuthRequired())
+		admin := v1.Group("/admin", authRequired)
 		{
 			admin.POST("/users", a.userHandler.CreateUser)
 			admin.GET("/users", a.userHandler.ListUsers)
+			admin.POST("/users/import", a.userHandler.ImportUsers)
+			admin.GET("/users/export", a.userHandler.ExportUsers)
 			admin.GET("/users/:user_id", a.userHandler.GetUser)
 			admin.PUT("/users/:user_id", a.userHandler.UpdateUser)
 			admin.DELETE("/users/:user_id", a.userHandler.DeleteUser)
+			admin.GET("/devices", a.deviceHandler.ListDevices)
+			admin.GET("/access-audit", a.deviceHandler.AccessAudit)
+			admin.GET("/access-logs", a.deviceHandler.ListAccessLogs)
 		}
 		
 		// Device routes (placeholder)
-		// device := v1.Group("/device", middleware.AuthRequired())
+		// device := v1.Group("/device", authRequired)
 		// {
 		//     device.POST("/bind", a.deviceHandler.BindDevice)
 		//     device.DELETE("/:device_id", a.deviceHandler.UnbindDevice)
@@ -157,25 +354,106 @@ func (a *Application) Router() *gin.Engine {
 		// }
 		
 		// Gateway routes
-		gateway := v1.Group("/gateway", middleware.AuthRequired())
+		gateway := v1.Group("/gateway")
 		{
 			// Command execution
-			gateway.POST("/execute", a.gatewayHandler.ExecuteCommand)
-			gateway.GET("/commands", a.gatewayHandler.ListCommands)
+			gateway.POST("/execute", executeScope, executeLimit, executeOnce, a.gatewayHandler.ExecuteCommand)
+			gateway.GET("/commands", readScope, a.gatewayHandler.ListCommands)
+			gateway.GET("/events", readScope, a.gatewayHandler.StreamEvents)
 			
 			// Device management
-			gateway.POST("/devices/connect", a.gatewayHandler.ConnectDevice)
-			gateway.DELETE("/devices/:device_id/disconnect", a.gatewayHandler.DisconnectDevice)
-			gateway.GET("/devices", a.gatewayHandler.ListConnectedDevices)
-			gateway.GET("/devices/:device_id/status", a.gatewayHandler.GetDeviceStatus)
-			gateway.GET("/devices/:device_id/health", a.gatewayHandler.HealthCheck)
-			gateway.POST("/devices/:device_id/reload", a.gatewayHandler.ReloadConfig)
+			gateway.POST("/devices/connect", authRequired, a.gatewayHandler.ConnectDevice)
+			gateway.DELETE("/devices/:device_id/disconnect", authRequired, a.gatewayHandler.DisconnectDevice)
+			gateway.GET("/devices", readScope, a.gatewayHandler.ListConnectedDevices)
+			gateway.GET("/devices/:device_id/status", readScope, a.gatewayHandler.GetDeviceStatus)
+			gateway.GET("/devices/:device_id/health", readScope, a.gatewayHandler.HealthCheck)
+			gateway.POST("/devices/:device_id/reload", authRequired, a.gatewayHandler.ReloadConfig)
+			gateway.POST("/devices/:device_id/update", authRequired, a.gatewayHandler.UpdateAgent)
+			gateway.GET("/devices/:device_id/access-history", authRequired, a.gatewayHandler.GetAccessHistory)
+			
+			// Device members: non-owners ask to be bound and an owner approves
+			gateway.POST("/devices/:device_id/members", authRequired, a.gatewayHandler.BindDevice)
+			gateway.GET("/devices/:device_id/members/pending", authRequired, a.gatewayHandler.ListAccessRequests)
+			gateway.POST("/devices/:device_id/members/:user_id/approve", authRequired, a.gatewayHandler.ApproveAccessRequest)
+			gateway.POST("/devices/:device_id/members/:user_id/reject", authRequired, a.gatewayHandler.RejectAccessRequest)
+			
+			// Per-user favorite commands
+			gateway.GET("/devices/:device_id/favorites", readScope, a.gatewayHandler.ListFavorites)
+			gateway.POST("/devices/:device_id/favorites", authRequired, a.gatewayHandler.AddFavorite)
+			gateway.DELETE("/devices/:device_id/favorites/:command_id", authRequired, a.gatewayHandler.RemoveFavorite)
+			
+			// Executions queued for offline devices
+			gateway.GET("/devices/:device_id/pending", readScope, a.gatewayHandler.ListPendingExecutions)
+			gateway.DELETE("/devices/:device_id/pending/:execution_id", authRequired, a.gatewayHandler.CancelPendingExecution)
+			
+			// Device groups
+			gateway.POST("/groups", authRequired, a.groupHandler.CreateGroup)
+			gateway.GET("/groups", readScope, a.groupHandler.ListGroups)
+			gateway.DELETE("/groups/:group_id", authRequired, a.groupHandler.DeleteGroup)
+			gateway.POST("/groups/:group_id/devices", authRequired, a.groupHandler.AddDevice)
+			gateway.DELETE("/groups/:group_id/devices/:device_id", authRequired, a.groupHandler.RemoveDevice)
+			gateway.POST("/groups/:group_id/execute", executeScope, executeLimit, a.groupHandler.ExecuteCommand)
 		}
 	}
 	
 	return router
 }
 
+// readinessCheck reports whether the database and gateway are usable, answering
+// 503 with per-check details when any of them is down
+func (a *Application) readinessCheck(c *gin.Context) {
+	ready := true
+
+	dbCheck := gin.H{"status": "up"}
+	if err := a.pingDatabase(c.Request.Context()); err != nil {
+		ready = false
+		dbCheck = gin.H{"status": "down", "error": err.Error()}
+	}
+
+	gatewayCheck := gin.H{"status": "down", "error": "gateway service not initialized"}
+	if a.gatewayService != nil {
+		stats := a.gatewayService.ConnectionStats()
+		gatewayCheck = gin.H{
+			"status":          "up",
+			"connections":     stats.Connections,
+			"healthy":         stats.Healthy,
+			"max_connections": stats.MaxConnections,
+			"evicted":         stats.Evicted,
+		}
+	} else {
+		ready = false
+	}
+
+	status, code := "ready", nethttp.StatusOK
+	if !ready {
+		status, code = "unavailable", nethttp.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": gin.H{
+			"db":      dbCheck,
+			"gateway": gatewayCheck,
+		},
+	})
+}
+
+// pingDatabase verifies the database connection is alive
+func (a *Application) pingDatabase(ctx context.Context) error {
+	if a.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
 // StartGRPCServer starts the gRPC server
 func (a *Application) StartGRPCServer() error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.GRPC.Port))
@@ -183,7 +461,10 @@ func (a *Application) StartGRPCServer() error {
 		return fmt.Errorf("failed to listen on port %d: %w", a.config.GRPC.Port, err)
 	}
 	
-	a.grpcServer = grpc.NewServer()
+	a.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpchandler.RecoveryUnaryInterceptor),
+		grpc.ChainStreamInterceptor(grpchandler.RecoveryStreamInterceptor),
+	)
 	
 	// Register gRPC services
 	gatewayPb.RegisterGatewayServiceServer(a.grpcServer, a.grpcGatewayHandler)
@@ -192,17 +473,38 @@ func (a *Application) StartGRPCServer() error {
 	return a.grpcServer.Serve(lis)
 }
 
+// CloseEventStreams ends open event streams, which would otherwise hold the
+// HTTP server's graceful shutdown until its timeout
+func (a *Application) CloseEventStreams() {
+	if a.eventBus != nil {
+		a.eventBus.Close()
+	}
+}
+
 // Stop gracefully stops the application
 func (a *Application) Stop(ctx context.Context) error {
 	if a.grpcServer != nil {
 		a.grpcServer.GracefulStop()
 	}
 	
+	// Stop the offline sweeper before the database closes under it
+	if a.stopSweeper != nil {
+		a.stopSweeper()
+		select {
+		case <-a.sweeperDone:
+		case <-ctx.Done():
+		}
+	}
+	
 	// Stop gateway service
 	if a.gatewayService != nil {
 		a.gatewayService.Stop()
 	}
 	
+	if a.redisClient != nil {
+		a.redisClient.Close()
+	}
+	
 	// Close database connection
 	if a.db != nil {
 		sqlDB, err := a.db.DB()
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// JWK is an RSA public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is the key set served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public key other services verify tokens with, or nil
+// when tokens are signed with the shared secret
+func (j *JWTService) JWKS() *JWKS {
+	if j.publicKey == nil {
+		return nil
+	}
+
+	return &JWKS{Keys: []JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: j.method.Alg(),
+		Kid: j.keyID,
+		N:   base64.RawURLEncoding.EncodeToString(j.publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(j.publicKey.E)).Bytes()),
+	}}}
+}
+
+// loadRSAKeys reads the RS256 signing key and its public key. Without a
+// public key file the public half of the private key is used
+func loadRSAKeys(privateKeyFile, publicKeyFile string) (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	if privateKeyFile == "" {
+		return nil, nil, errors.New("RS256 requires jwt.private_key_file")
+	}
+
+	block, err := readPEM(privateKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := parseRSAPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT private key %s: %w", privateKeyFile, err)
+	}
+
+	if publicKeyFile == "" {
+		return privateKey, &privateKey.PublicKey, nil
+	}
+
+	block, err = readPEM(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := parseRSAPublicKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT public key %s: %w", publicKeyFile, err)
+	}
+	if !publicKey.Equal(&privateKey.PublicKey) {
+		return nil, nil, errors.New("JWT public key does not match the private key")
+	}
+	return privateKey, publicKey, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in JWT key %s", path)
+	}
+	return block, nil
+}
+
+// parseRSAPrivateKey accepts PKCS#1 and PKCS#8 encoded RSA keys
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// parseRSAPublicKey accepts PKIX and PKCS#1 encoded RSA public keys
+func parseRSAPublicKey(der []byte) (*rsa.PublicKey, error) {
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// rsaKeyID derives a stable key ID from the public key, so verifiers can
+// tell keys apart across rotations
+func rsaKeyID(key *rsa.PublicKey) string {
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -1,7 +1,14 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,27 +17,114 @@ import (
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"` // admin, user
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`                // admin, user
+	FamilyID  string `json:"family_id,omitempty"` // refresh tokens only, shared by every rotation of a login
+	SessionID string `json:"sid,omitempty"`       // access tokens only, the family of the login that issued them
 	jwt.RegisteredClaims
 }
 
+// challengeClaims identifies a user who passed the password check but
+// still has to present a second factor
+type challengeClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// Challenge is a valid login challenge
+type Challenge struct {
+	ID        string // unique per challenge, so its failed attempts can be counted
+	UserID    string
+	ExpiresAt time.Time
+}
+
+const (
+	challengePurpose  = "2fa"
+	challengeDuration = 5 * time.Minute
+)
+
 // JWTService handles JWT token operations
 type JWTService struct {
 	secretKey            []byte
+	method               jwt.SigningMethod // signs access and refresh tokens
+	signingKey           interface{}
+	verifyKey            interface{}
+	publicKey            *rsa.PublicKey // RS256 only, published by JWKS
+	keyID                string
+	challengeKey         []byte // separate key so challenges are never accepted as access tokens
+	webAuthnKey          []byte // signs WebAuthn ceremony sessions, see webauthn.go
+	oauthStateKey        []byte // signs the state of OAuth logins, see OAuthStateKey
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
 }
 
-// NewJWTService creates a new JWT service
-func NewJWTService(cfg config.JWTConfig) *JWTService {
-	return &JWTService{
+// publicSecretKey is the secret_key earlier sample configs shipped with.
+// Anyone can sign with it, so it is refused like an empty one
+const publicSecretKey = "your-secret-key-change-in-production"
+
+// NewJWTService creates a new JWT service, loading the RSA keys when the
+// configured algorithm is RS256
+func NewJWTService(cfg config.JWTConfig) (*JWTService, error) {
+	// Under RS256 too the secret derives the challenge, WebAuthn and OAuth
+	// state keys, and the default TOTP encryption key
+	if cfg.SecretKey == "" || cfg.SecretKey == publicSecretKey {
+		return nil, errors.New("jwt.secret_key must be set to a private random value")
+	}
+
+	j := &JWTService{
 		secretKey:            []byte(cfg.SecretKey),
+		challengeKey:         deriveKey(cfg.SecretKey, "two-factor-challenge"),
+		webAuthnKey:          deriveKey(cfg.SecretKey, "webauthn-session"),
+		oauthStateKey:        deriveKey(cfg.SecretKey, "oauth-state"),
 		accessTokenDuration:  time.Duration(cfg.AccessTokenDuration) * time.Minute,
 		refreshTokenDuration: time.Duration(cfg.RefreshTokenDuration) * 24 * time.Hour,
 	}
+
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "HS256":
+		j.method = jwt.SigningMethodHS256
+		j.signingKey = j.secretKey
+		j.verifyKey = j.secretKey
+	case "RS256":
+		privateKey, publicKey, err := loadRSAKeys(cfg.PrivateKeyFile, cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		j.method = jwt.SigningMethodRS256
+		j.signingKey = privateKey
+		j.verifyKey = publicKey
+		j.publicKey = publicKey
+		j.keyID = rsaKeyID(publicKey)
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+	return j, nil
+}
+
+// deriveKey derives the HMAC key for one purpose from the configured secret,
+// so tokens signed for that purpose never verify as anything else
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// OAuthStateKey returns the key that signs the state parameter of OAuth
+// logins. It differs from the access token key, so a state cannot be
+// presented as an access token
+func (j *JWTService) OAuthStateKey() []byte {
+	return j.oauthStateKey
+}
+
+// signToken signs access and refresh token claims with the configured algorithm
+func (j *JWTService) signToken(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(j.method, claims)
+	if j.keyID != "" {
+		token.Header["kid"] = j.keyID
+	}
+	return token.SignedString(j.signingKey)
 }
 
 // TokenPair represents access and refresh tokens
@@ -38,20 +132,37 @@ type TokenPair struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
+
+	// Identify the refresh token so it can be stored and rotated
+	RefreshTokenID   string    `json:"-"`
+	FamilyID         string    `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
-// GenerateTokenPair generates both access and refresh tokens
+// GenerateTokenPair generates both access and refresh tokens, starting a new
+// refresh token family
 func (j *JWTService) GenerateTokenPair(userID, username, email, role string) (*TokenPair, error) {
+	return j.generateTokenPair(userID, username, email, role, randomID())
+}
+
+// RotateTokenPair generates the pair replacing a used refresh token, in the
+// same family
+func (j *JWTService) RotateTokenPair(refreshClaims *Claims) (*TokenPair, error) {
+	return j.generateTokenPair(refreshClaims.UserID, refreshClaims.Username, refreshClaims.Email, refreshClaims.Role, refreshClaims.FamilyID)
+}
+
+func (j *JWTService) generateTokenPair(userID, username, email, role, familyID string) (*TokenPair, error) {
 	now := time.Now()
 	accessExpiresAt := now.Add(j.accessTokenDuration)
 	refreshExpiresAt := now.Add(j.refreshTokenDuration)
 
 	// Generate access token
 	accessClaims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		SessionID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -61,19 +172,21 @@ func (j *JWTService) GenerateTokenPair(userID, username, email, role string) (*T
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(j.secretKey)
+	accessTokenString, err := j.signToken(accessClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate refresh token
+	refreshTokenID := randomID()
 	refreshClaims := &Claims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
 		Role:     role,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refreshTokenID,
 			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -82,8 +195,7 @@ func (j *JWTService) GenerateTokenPair(userID, username, email, role string) (*T
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(j.secretKey)
+	refreshTokenString, err := j.signToken(refreshClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -92,17 +204,23 @@ func (j *JWTService) GenerateTokenPair(userID, username, email, role string) (*T
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
 		ExpiresAt:    accessExpiresAt,
+
+		RefreshTokenID:   refreshTokenID,
+		FamilyID:         familyID,
+		RefreshExpiresAt: refreshExpiresAt,
 	}, nil
 }
 
-// ValidateToken validates and parses a JWT token
+// ValidateToken validates and parses a JWT token. Only the configured
+// algorithm is accepted, so an RS256 public key can never be used as an
+// HMAC secret
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != j.method.Alg() {
 			return nil, errors.New("invalid token signing method")
 		}
-		return j.secretKey, nil
-	})
+		return j.verifyKey, nil
+	}, jwt.WithValidMethods([]string{j.method.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -120,15 +238,35 @@ func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// RefreshToken generates a new access token from a valid refresh token
-func (j *JWTService) RefreshToken(refreshTokenString string) (*TokenPair, error) {
+// ValidateRefreshToken validates a refresh token. Whether it is still unused
+// is up to the caller's token store
+func (j *JWTService) ValidateRefreshToken(refreshTokenString string) (*Claims, error) {
 	claims, err := j.ValidateToken(refreshTokenString)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate new token pair
-	return j.GenerateTokenPair(claims.UserID, claims.Username, claims.Email, claims.Role)
+	// Access tokens carry neither, so they cannot be used to refresh
+	if claims.ID == "" || claims.FamilyID == "" {
+		return nil, errors.New("not a refresh token")
+	}
+	return claims, nil
+}
+
+// ValidateAccessToken validates a token presented to authenticate a request;
+// refresh tokens and tokens without a user are rejected
+func (j *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
+	claims, err := j.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.FamilyID != "" {
+		return nil, errors.New("refresh tokens cannot authenticate requests")
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("token does not identify a user")
+	}
+	return claims, nil
 }
 
 // ExtractUserID extracts user ID from token string
@@ -147,4 +285,58 @@ func (j *JWTService) IsAdmin(tokenString string) (bool, error) {
 		return false, err
 	}
 	return claims.Role == "admin", nil
-}
\ No newline at end of file
+}
+
+// GenerateChallengeToken issues a short-lived token that is exchanged for a
+// token pair once the user's second factor is verified
+func (j *JWTService) GenerateChallengeToken(userID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(challengeDuration)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &challengeClaims{
+		Purpose: challengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "lazy-ctrl-cloud",
+			Subject:   userID,
+			ID:        randomID(),
+		},
+	})
+	tokenString, err := token.SignedString(j.challengeKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// ValidateChallengeToken returns the challenge of a valid challenge token
+func (j *JWTService) ValidateChallengeToken(tokenString string) (*Challenge, error) {
+	claims := &challengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		return j.challengeKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("challenge is invalid or expired")
+	}
+
+	if claims.Purpose != challengePurpose || claims.Subject == "" || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid challenge token")
+	}
+	return &Challenge{
+		ID:        claims.ID,
+		UserID:    claims.Subject,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// randomID returns a random hex identifier for token IDs and families
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+)
+
+const testSecret = "test-secret-key"
+
+func newTestJWTService(t *testing.T) *JWTService {
+	t.Helper()
+	j, err := NewJWTService(config.JWTConfig{SecretKey: testSecret, AccessTokenDuration: 15, RefreshTokenDuration: 7})
+	if err != nil {
+		t.Fatalf("create jwt service: %v", err)
+	}
+	return j
+}
+
+// signHS256 signs claims the way the OAuth handler signs its state parameter
+func signHS256(t *testing.T, claims jwt.Claims, key []byte) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	j := newTestJWTService(t)
+	pair, err := j.GenerateTokenPair("user-1", "alice", "alice@example.com", "user")
+	if err != nil {
+		t.Fatalf("generate tokens: %v", err)
+	}
+	challenge, _, err := j.GenerateChallengeToken("user-1")
+	if err != nil {
+		t.Fatalf("generate challenge: %v", err)
+	}
+	expiry := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	state := struct {
+		Provider string `json:"provider"`
+		Nonce    string `json:"nonce"`
+		jwt.RegisteredClaims
+	}{"github", "nonce", expiry}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantID string // empty when the token must be rejected
+	}{
+		{"access token", pair.AccessToken, "user-1"},
+		{"refresh token", pair.RefreshToken, ""},
+		{"login challenge", challenge, ""},
+		{"oauth state", signHS256(t, &state, j.OAuthStateKey()), ""},
+		{"oauth state signed with the token secret", signHS256(t, &state, []byte(testSecret)), ""},
+		{"claims without a user", signHS256(t, &Claims{Role: "admin", RegisteredClaims: expiry}, []byte(testSecret)), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := j.ValidateAccessToken(tt.token)
+			if tt.wantID == "" {
+				if err == nil {
+					t.Fatalf("accepted with user %q, want rejection", claims.UserID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if claims.UserID != tt.wantID {
+				t.Fatalf("user = %q, want %q", claims.UserID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestDerivedKeysAreDistinct(t *testing.T) {
+	j := newTestJWTService(t)
+	keys := map[string][]byte{
+		"token":     j.secretKey,
+		"challenge": j.challengeKey,
+		"webauthn":  j.webAuthnKey,
+		"oauth":     j.OAuthStateKey(),
+	}
+	seen := make(map[string]string, len(keys))
+	for name, key := range keys {
+		if other, ok := seen[string(key)]; ok {
+			t.Errorf("%s key equals the %s key", name, other)
+		}
+		seen[string(key)] = name
+	}
+}
+
+func TestNewJWTServiceRequiresPrivateSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		algorithm string
+		wantErr   bool
+	}{
+		{"private secret", testSecret, "HS256", false},
+		{"empty secret", "", "HS256", true},
+		{"sample secret", publicSecretKey, "HS256", true},
+		// RS256 still derives the challenge, WebAuthn and OAuth state keys from it
+		{"empty secret under RS256", "", "RS256", true},
+		{"sample secret under RS256", publicSecretKey, "RS256", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJWTService(config.JWTConfig{SecretKey: tt.secret, Algorithm: tt.algorithm})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "jwt.secret_key") {
+					t.Fatalf("err = %v, want a jwt.secret_key error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewJWTService: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+)
+
+// OAuthUserInfo is the identity reported by an OAuth provider
+type OAuthUserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+	AvatarURL     string
+}
+
+// OAuthProvider runs the authorization code flow against one OAuth2/OIDC provider.
+// Endpoints not configured explicitly are discovered from the issuer
+type OAuthProvider struct {
+	Name string
+
+	cfg        config.OAuthProviderConfig
+	httpClient *http.Client
+
+	mutex       sync.Mutex
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+}
+
+// NewOAuthProvider creates a provider from its configuration
+func NewOAuthProvider(name string, cfg config.OAuthProviderConfig) (*OAuthProvider, error) {
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, fmt.Errorf("oauth provider %s: client_id and client_secret are required", name)
+	}
+	if cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("oauth provider %s: redirect_url is required", name)
+	}
+	if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "") {
+		return nil, fmt.Errorf("oauth provider %s: issuer or auth_url, token_url and userinfo_url are required", name)
+	}
+
+	return &OAuthProvider{
+		Name:        name,
+		cfg:         cfg,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		authURL:     cfg.AuthURL,
+		tokenURL:    cfg.TokenURL,
+		userInfoURL: cfg.UserInfoURL,
+	}, nil
+}
+
+// AuthCodeURL returns the provider login page URL the user is redirected to
+func (p *OAuthProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+	}
+
+	separator := "?"
+	if strings.Contains(p.authURL, "?") {
+		separator = "&"
+	}
+	return p.authURL + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for the user's identity
+func (p *OAuthProvider) Exchange(ctx context.Context, code string) (*OAuthUserInfo, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := p.exchangeCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := p.getJSON(ctx, p.userInfoURL, accessToken, &claims); err != nil {
+		return nil, fmt.Errorf("failed to fetch userinfo: %w", err)
+	}
+
+	info := &OAuthUserInfo{
+		Subject:       claimString(claims, "sub", "id"),
+		Email:         claimString(claims, "email"),
+		EmailVerified: claimBool(claims, "email_verified"),
+		Username:      claimString(claims, "preferred_username", "login"),
+		Name:          claimString(claims, "name"),
+		AvatarURL:     claimString(claims, "picture", "avatar_url"),
+	}
+	if info.Subject == "" {
+		return nil, errors.New("userinfo has no subject")
+	}
+
+	// Providers such as GitHub only report verified addresses from a separate endpoint
+	if p.cfg.EmailsURL != "" && !info.EmailVerified {
+		if email, err := p.verifiedEmail(ctx, accessToken); err == nil && email != "" {
+			info.Email = email
+			info.EmailVerified = true
+		}
+	}
+
+	return info, nil
+}
+
+// discover fills in endpoints from the issuer's OIDC discovery document
+func (p *OAuthProvider) discover(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.authURL != "" && p.tokenURL != "" && p.userInfoURL != "" {
+		return nil
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	discoveryURL := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, "", &doc); err != nil {
+		return fmt.Errorf("oauth provider %s discovery failed: %w", p.Name, err)
+	}
+
+	if p.authURL == "" {
+		p.authURL = doc.AuthorizationEndpoint
+	}
+	if p.tokenURL == "" {
+		p.tokenURL = doc.TokenEndpoint
+	}
+	if p.userInfoURL == "" {
+		p.userInfoURL = doc.UserinfoEndpoint
+	}
+	if p.authURL == "" || p.tokenURL == "" || p.userInfoURL == "" {
+		return fmt.Errorf("oauth provider %s discovery document is missing endpoints", p.Name)
+	}
+
+	return nil
+}
+
+// exchangeCode requests an access token for an authorization code
+func (p *OAuthProvider) exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	return token.AccessToken, nil
+}
+
+// verifiedEmail returns the primary verified address from the emails endpoint
+func (p *OAuthProvider) verifiedEmail(ctx context.Context, accessToken string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.cfg.EmailsURL, accessToken, &emails); err != nil {
+		return "", err
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email, nil
+		}
+	}
+	return "", nil
+}
+
+// getJSON fetches a JSON document, authenticating with accessToken when set
+func (p *OAuthProvider) getJSON(ctx context.Context, target, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// claimString returns the first of keys present in claims as a string
+func claimString(claims map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := claims[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			// Numeric IDs such as GitHub's
+			return fmt.Sprintf("%.0f", value)
+		}
+	}
+	return ""
+}
+
+// claimBool reads a boolean claim; some providers send it as a string
+func claimBool(claims map[string]interface{}, key string) bool {
+	switch value := claims[key].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
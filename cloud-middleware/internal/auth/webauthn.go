@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// WebAuthn ceremonies a session token can be issued for
+const (
+	WebAuthnRegistration = "webauthn-registration"
+	WebAuthnLogin        = "webauthn-login"
+)
+
+// webAuthnSessionDuration bounds a ceremony even when the authenticator timeout is longer
+const webAuthnSessionDuration = 5 * time.Minute
+
+// webAuthnSessionClaims carries the server side state of a WebAuthn ceremony
+// between its begin and finish requests, so any replica can finish it
+type webAuthnSessionClaims struct {
+	Purpose string          `json:"purpose"`
+	Session json.RawMessage `json:"session"`
+	jwt.RegisteredClaims
+}
+
+// GenerateWebAuthnSessionToken signs a ceremony's session data. subject is
+// the user the ceremony belongs to, empty for a login without a username
+func (j *JWTService) GenerateWebAuthnSessionToken(purpose, subject string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &webAuthnSessionClaims{
+		Purpose: purpose,
+		Session: data,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(webAuthnSessionDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "lazy-ctrl-cloud",
+			Subject:   subject,
+		},
+	})
+	return token.SignedString(j.webAuthnKey)
+}
+
+// ValidateWebAuthnSessionToken returns the session data and subject of a
+// valid session token issued for purpose
+func (j *JWTService) ValidateWebAuthnSessionToken(tokenString, purpose string) (*webauthn.SessionData, string, error) {
+	claims := &webAuthnSessionClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		return j.webAuthnKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, "", errors.New("webauthn session is invalid or expired")
+	}
+	if claims.Purpose != purpose {
+		return nil, "", errors.New("webauthn session was issued for another ceremony")
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(claims.Session, &session); err != nil {
+		return nil, "", errors.New("invalid webauthn session")
+	}
+	return &session, claims.Subject, nil
+}
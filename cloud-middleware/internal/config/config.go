@@ -6,12 +6,18 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	GRPC     GRPCConfig     `mapstructure:"grpc"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server      ServerConfig      `mapstructure:"server"`
+	GRPC        GRPCConfig        `mapstructure:"grpc"`
+	Gateway     GatewayConfig     `mapstructure:"gateway"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	OAuth       OAuthConfig       `mapstructure:"oauth"`
+	TwoFactor   TwoFactorConfig   `mapstructure:"two_factor"`
+	WebAuthn    WebAuthnConfig    `mapstructure:"webauthn"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Log         LogConfig         `mapstructure:"log"`
 }
 
 // ServerConfig represents HTTP server configuration
@@ -27,6 +33,83 @@ type GRPCConfig struct {
 	Port int `mapstructure:"port"`
 }
 
+// GatewayConfig represents device gateway configuration
+type GatewayConfig struct {
+	AutoImportCommands bool             `mapstructure:"auto_import_commands"` // import device commands on first connect
+	SyncCommands       bool             `mapstructure:"sync_commands"`        // push device commands to the agent when they change and on reconnect
+	AllowInsecure      bool             `mapstructure:"allow_insecure"`       // allow plaintext gRPC when TLS is disabled
+	TLS                GatewayTLSConfig `mapstructure:"tls"`
+	Batch              BatchConfig      `mapstructure:"batch"`
+	Retry              RetryConfig      `mapstructure:"retry"`
+	Offline            OfflineConfig    `mapstructure:"offline"`
+	Queue              QueueConfig      `mapstructure:"queue"`
+	Keepalive          KeepaliveConfig  `mapstructure:"keepalive"`
+	Pool               PoolConfig       `mapstructure:"pool"`
+	Enrollment         EnrollmentConfig `mapstructure:"enrollment"`
+	RequestTimeout     int              `mapstructure:"request_timeout"` // seconds for device calls the caller sets no deadline for
+
+	// Command fragments rejected when device commands are created or updated
+	BlockedPatterns      []string `mapstructure:"blocked_patterns"`       // replaces the built-in list
+	ExtraBlockedPatterns []string `mapstructure:"extra_blocked_patterns"` // added to blocked_patterns
+}
+
+// EnrollmentConfig controls agents registering themselves with EnrollDevice
+type EnrollmentConfig struct {
+	Tokens []EnrollmentToken `mapstructure:"tokens"` // empty rejects every enrollment
+}
+
+// EnrollmentToken is a pre-shared token agents enroll with
+type EnrollmentToken struct {
+	Token  string `mapstructure:"token"`
+	UserID string `mapstructure:"user_id"` // owner of the devices enrolled with the token
+}
+
+// PoolConfig limits the device connections the gateway keeps
+type PoolConfig struct {
+	MaxConnections int `mapstructure:"max_connections"`
+	EvictionGrace  int `mapstructure:"eviction_grace"` // seconds a connection may stay unhealthy before a new device can take its slot, 0 never evicts
+}
+
+// KeepaliveConfig controls gRPC keepalive pings on directly dialed device connections
+type KeepaliveConfig struct {
+	Time                int  `mapstructure:"time"`                  // seconds without activity before pinging, 0 disables; devices accept 10 or more
+	Timeout             int  `mapstructure:"timeout"`               // seconds to wait for the ping ack before the connection is dropped
+	PermitWithoutStream bool `mapstructure:"permit_without_stream"` // ping idle connections with no call in flight
+}
+
+// QueueConfig controls executions queued for offline devices
+type QueueConfig struct {
+	TTL int `mapstructure:"ttl"` // seconds a queued execution waits for its device before expiring
+}
+
+// OfflineConfig controls the sweeper that marks silent devices offline
+type OfflineConfig struct {
+	Threshold     int `mapstructure:"threshold"`      // seconds without contact before a device is offline, 0 disables
+	SweepInterval int `mapstructure:"sweep_interval"` // seconds between sweeps
+}
+
+// RetryConfig controls exponential backoff when dialing devices
+type RetryConfig struct {
+	BaseDelay   int `mapstructure:"base_delay"`   // seconds before the first retry
+	MaxDelay    int `mapstructure:"max_delay"`    // seconds, cap for the doubled delay
+	MaxAttempts int `mapstructure:"max_attempts"` // dial attempts before giving up
+}
+
+// BatchConfig limits bulk operations such as command import and group execution
+type BatchConfig struct {
+	Concurrency int `mapstructure:"concurrency"` // devices processed in parallel
+	Timeout     int `mapstructure:"timeout"`     // seconds for the whole batch
+}
+
+// GatewayTLSConfig represents TLS configuration for device gRPC connections
+type GatewayTLSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CertFile   string `mapstructure:"cert_file"`   // client certificate presented to devices
+	KeyFile    string `mapstructure:"key_file"`    // client private key
+	CAFile     string `mapstructure:"ca_file"`     // CA used to verify device certificates
+	ServerName string `mapstructure:"server_name"` // expected device certificate name
+}
+
 // DatabaseConfig represents database configuration
 type DatabaseConfig struct {
 	Host     string `mapstructure:"host"`
@@ -46,13 +129,74 @@ type RedisConfig struct {
 	DB       int    `mapstructure:"db"`
 }
 
-// JWTConfig represents JWT configuration
+// JWTConfig represents JWT configuration. Access and refresh tokens are
+// signed with the secret key (HS256) or an RSA key pair (RS256); the secret
+// key also signs short-lived internal tokens either way
 type JWTConfig struct {
 	SecretKey            string `mapstructure:"secret_key"`
+	Algorithm            string `mapstructure:"algorithm"`        // HS256 or RS256
+	PrivateKeyFile       string `mapstructure:"private_key_file"` // RS256 signing key, PEM
+	PublicKeyFile        string `mapstructure:"public_key_file"`  // RS256 verification key, PEM; derived from the private key when empty
 	AccessTokenDuration  int    `mapstructure:"access_token_duration"`  // minutes
 	RefreshTokenDuration int    `mapstructure:"refresh_token_duration"` // days
 }
 
+// OAuthConfig represents external login providers, keyed by the name used in login URLs
+type OAuthConfig struct {
+	Providers map[string]OAuthProviderConfig `mapstructure:"providers"`
+}
+
+// OAuthProviderConfig represents an OAuth2/OIDC provider. With an issuer the
+// endpoints are discovered; plain OAuth2 providers set them explicitly
+type OAuthProviderConfig struct {
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Issuer       string   `mapstructure:"issuer"`   // OIDC issuer URL used for discovery
+	AuthURL      string   `mapstructure:"auth_url"` // overrides the discovered endpoints
+	TokenURL     string   `mapstructure:"token_url"`
+	UserInfoURL  string   `mapstructure:"userinfo_url"`
+	EmailsURL    string   `mapstructure:"emails_url"`   // verified email list, for providers without email_verified
+	Scopes       []string `mapstructure:"scopes"`       // defaults to openid, email, profile
+	RedirectURL  string   `mapstructure:"redirect_url"` // this server's callback URL registered with the provider
+}
+
+// TwoFactorConfig represents TOTP two-factor authentication configuration
+type TwoFactorConfig struct {
+	Issuer        string `mapstructure:"issuer"`         // account issuer shown in authenticator apps
+	EncryptionKey string `mapstructure:"encryption_key"` // encrypts stored secrets, defaults to the JWT secret
+}
+
+// WebAuthnConfig represents passkey login; it is disabled while RPID is empty
+type WebAuthnConfig struct {
+	RPID          string   `mapstructure:"rp_id"`           // domain the credentials are bound to, e.g. cloud.example.com
+	RPDisplayName string   `mapstructure:"rp_display_name"` // shown by the browser during enrollment
+	RPOrigins     []string `mapstructure:"rp_origins"`      // web origins allowed to use the credentials
+}
+
+// RateLimitConfig throttles login attempts and command executions
+type RateLimitConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Backend string        `mapstructure:"backend"` // "redis" shares counters across replicas, "memory" keeps them per process
+	Prefix  string        `mapstructure:"prefix"`  // Redis key prefix
+	Login   RateLimitRule `mapstructure:"login"`   // per submitted username
+	Execute RateLimitRule `mapstructure:"execute"` // per user, for device and group execution
+}
+
+// RateLimitRule allows Limit requests per sliding Window
+type RateLimitRule struct {
+	Limit  int `mapstructure:"limit"`  // 0 disables the rule
+	Window int `mapstructure:"window"` // seconds
+}
+
+// IdempotencyConfig records command execution responses under client supplied
+// Idempotency-Key headers, so retried requests are answered without running again
+type IdempotencyConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Backend string `mapstructure:"backend"` // "redis" shares keys across replicas, "memory" keeps them per process
+	Prefix  string `mapstructure:"prefix"`  // Redis key prefix
+	TTL     int    `mapstructure:"ttl"`     // seconds a key and its response are kept
+}
+
 // LogConfig represents logging configuration
 type LogConfig struct {
 	Level  string `mapstructure:"level"`
@@ -91,6 +235,27 @@ func setDefaults() {
 	// gRPC defaults
 	viper.SetDefault("grpc.port", 8081)
 	
+	// Gateway defaults
+	viper.SetDefault("gateway.auto_import_commands", false)
+	viper.SetDefault("gateway.sync_commands", true)
+	viper.SetDefault("gateway.allow_insecure", false)
+	viper.SetDefault("gateway.tls.enabled", false)
+	viper.SetDefault("gateway.batch.concurrency", 8)
+	viper.SetDefault("gateway.batch.timeout", 120)
+	viper.SetDefault("gateway.retry.base_delay", 1)
+	viper.SetDefault("gateway.retry.max_delay", 30)
+	viper.SetDefault("gateway.retry.max_attempts", 5)
+	viper.SetDefault("gateway.offline.threshold", 180)
+	viper.SetDefault("gateway.offline.sweep_interval", 60)
+	viper.SetDefault("gateway.queue.ttl", 86400)
+	viper.SetDefault("gateway.keepalive.time", 30)
+	viper.SetDefault("gateway.keepalive.timeout", 10)
+	viper.SetDefault("gateway.keepalive.permit_without_stream", true)
+	viper.SetDefault("gateway.request_timeout", 10)
+	viper.SetDefault("gateway.pool.max_connections", 100)
+	viper.SetDefault("gateway.pool.eviction_grace", 300)
+	viper.SetDefault("gateway.blocked_patterns", []string{"rm -rf /", `del /s /q C:\`, "format c:", "mkfs.", "fdisk"})
+	
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
@@ -107,10 +272,35 @@ func setDefaults() {
 	viper.SetDefault("redis.db", 0)
 	
 	// JWT defaults
-	viper.SetDefault("jwt.secret_key", "your-secret-key-change-in-production")
+	viper.SetDefault("jwt.secret_key", "")
+	viper.SetDefault("jwt.algorithm", "HS256")
 	viper.SetDefault("jwt.access_token_duration", 15)  // 15 minutes
 	viper.SetDefault("jwt.refresh_token_duration", 7)  // 7 days
 	
+	// Two-factor defaults
+	viper.SetDefault("two_factor.issuer", "Lazy Ctrl")
+	viper.SetDefault("two_factor.encryption_key", "")
+	
+	// WebAuthn defaults
+	viper.SetDefault("webauthn.rp_id", "")
+	viper.SetDefault("webauthn.rp_display_name", "Lazy Ctrl")
+	viper.SetDefault("webauthn.rp_origins", []string{})
+	
+	// Rate limit defaults
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.backend", "redis")
+	viper.SetDefault("rate_limit.prefix", "lazyctrl:ratelimit:")
+	viper.SetDefault("rate_limit.login.limit", 5)
+	viper.SetDefault("rate_limit.login.window", 60)
+	viper.SetDefault("rate_limit.execute.limit", 60)
+	viper.SetDefault("rate_limit.execute.window", 60)
+	
+	// Idempotency defaults
+	viper.SetDefault("idempotency.enabled", true)
+	viper.SetDefault("idempotency.backend", "redis")
+	viper.SetDefault("idempotency.prefix", "lazyctrl:idempotency:")
+	viper.SetDefault("idempotency.ttl", 86400) // 24 hours
+	
 	// Log defaults
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
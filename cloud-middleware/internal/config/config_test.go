@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+)
+
+// TestShippedConfigIsLockedDown checks the settings of configs/config.yaml
+// that must stay closed until an operator opts in
+func TestShippedConfigIsLockedDown(t *testing.T) {
+	cfg, err := Load("../../configs/config.yaml")
+	if err != nil {
+		t.Fatalf("load shipped config: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"no plaintext gRPC to devices", !cfg.Gateway.AllowInsecure},
+		{"no command auto-import", !cfg.Gateway.AutoImportCommands},
+		{"bounded bulk operations", cfg.Gateway.Batch.Concurrency > 0 && cfg.Gateway.Batch.Timeout > 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.ok {
+				t.Errorf("shipped config does not have %s", tt.name)
+			}
+		})
+	}
+}
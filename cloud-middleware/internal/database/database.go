@@ -16,8 +16,14 @@ func Connect(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	// Use SQLite for simplicity
 	dbPath := "data/lazy_ctrl_cloud.db"
 	
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	return Open(dbPath, logger.Default.LogMode(logger.Info))
+}
+
+// Open opens the SQLite database at dsn, such as an in-memory database in tests,
+// and migrates its schemas
+func Open(dsn string, log logger.Interface) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: log,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -33,11 +39,33 @@ func Connect(cfg config.DatabaseConfig) (*gorm.DB, error) {
 
 // autoMigrate performs automatic database migration
 func autoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&model.User{},
+		&model.UserIdentity{},
+		&model.WebAuthnCredential{},
+		&model.RefreshToken{},
+		&model.Session{},
+		&model.APIKey{},
 		&model.Device{},
 		&model.DeviceCommand{},
+		&model.UserFavoriteCommand{},
 		&model.UserDevice{},
+		&model.UserDeviceHistory{},
+		&model.AccessLog{},
 		&model.ExecutionLog{},
-	)
+		&model.PendingExecution{},
+		&model.DeviceGroup{},
+		&model.DeviceGroupMember{},
+	); err != nil {
+		return err
+	}
+	
+	// The phone index used to cover empty phones too, allowing only one user without a phone
+	if db.Migrator().HasIndex(&model.User{}, "idx_users_phone") {
+		if err := db.Migrator().DropIndex(&model.User{}, "idx_users_phone"); err != nil {
+			return err
+		}
+	}
+	
+	return nil
 }
\ No newline at end of file
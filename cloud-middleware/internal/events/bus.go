@@ -0,0 +1,119 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	DeviceOnline    = "device.online"
+	DeviceOffline   = "device.offline"
+	CommandExecuted = "command.executed"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Event is a change published to subscribers. Events concern a single device
+type Event struct {
+	Type      string    `json:"type"`
+	DeviceID  string    `json:"device_id"`
+	CommandID string    `json:"command_id,omitempty"` // command.executed only
+	Success   *bool     `json:"success,omitempty"`    // command.executed only
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Bus is an in-process publish/subscribe hub. Publishing never blocks: a
+// subscriber that does not keep up misses events
+type Bus struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	bus     *Bus
+	events  chan Event
+	dropped int
+}
+
+// NewBus creates an event bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to every subscriber, stamping it when no timestamp is set
+func (b *Bus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Subscribe registers a new subscription. On a closed bus the subscription's
+// channel is already closed
+func (b *Bus) Subscribe() *Subscription {
+	sub := &Subscription{bus: b, events: make(chan Event, subscriberBuffer)}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close ends every subscription and refuses new ones
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.events)
+		delete(b.subscribers, sub)
+	}
+}
+
+// Events returns the channel events are delivered on; it is closed when the
+// subscription or the bus is closed
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events were dropped because the subscriber fell behind
+func (s *Subscription) Dropped() int {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+	return s.dropped
+}
+
+// Close unsubscribes; closing twice is a no-op
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	if _, exists := s.bus.subscribers[s]; exists {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
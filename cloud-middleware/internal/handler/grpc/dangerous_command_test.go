@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	gatewayPb "github.com/myczh-1/lazy-ctrl-cloud/proto"
+)
+
+func TestDangerousCommands(t *testing.T) {
+	const dangerous = "sudo rm -rf / --no-preserve-root"
+
+	create := func(command string, allow bool) func(h *GatewayHandler, userID string) error {
+		return func(h *GatewayHandler, userID string) error {
+			_, err := h.CreateCommand(context.Background(), &gatewayPb.CreateCommandRequest{
+				DeviceId: "known-device", UserId: userID, Id: "wipe", Name: "Wipe", Command: command, AllowDangerous: allow,
+			})
+			return err
+		}
+	}
+	update := func(command string, allow bool, paths ...string) func(h *GatewayHandler, userID string) error {
+		return func(h *GatewayHandler, userID string) error {
+			req := &gatewayPb.UpdateCommandRequest{
+				DeviceId: "known-device", UserId: userID, CommandId: "lights", Name: "Lights", Command: command, AllowDangerous: allow,
+			}
+			if len(paths) > 0 {
+				req.UpdateMask = &fieldmaskpb.FieldMask{Paths: paths}
+			}
+			_, err := h.UpdateCommand(context.Background(), req)
+			return err
+		}
+	}
+
+	tests := []struct {
+		name       string
+		userID     string
+		call       func(h *GatewayHandler, userID string) error
+		wantCode   codes.Code
+		wantLogged bool // a denial is recorded in the access log
+	}{
+		{"create safe", "member", create("ls /", false), codes.OK, false},
+		{"create dangerous", "member", create(dangerous, false), codes.InvalidArgument, false},
+		{"create override by user", "member", create(dangerous, true), codes.PermissionDenied, true},
+		{"create override by admin", "device-admin", create(dangerous, true), codes.OK, false},
+		{"update dangerous", "member", update(dangerous, false), codes.InvalidArgument, false},
+		{"masked update dangerous", "member", update(dangerous, false, "command"), codes.InvalidArgument, false},
+		{"masked update leaving the command", "member", update(dangerous, false, "name"), codes.OK, false},
+		{"update override by user", "member", update(dangerous, true), codes.PermissionDenied, true},
+		{"update override by admin", "device-admin", update(dangerous, true), codes.OK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, deviceService := newUpdateCommandHandler(t)
+			err := tt.call(h, tt.userID)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+
+			logs, _, err := deviceService.ListAccessLogs(repository.AccessLogFilter{UserID: tt.userID}, 0, 10)
+			if err != nil {
+				t.Fatalf("list access logs: %v", err)
+			}
+			if (len(logs) > 0) != tt.wantLogged {
+				t.Errorf("access log has %d denials, want logged %v", len(logs), tt.wantLogged)
+			}
+		})
+	}
+}
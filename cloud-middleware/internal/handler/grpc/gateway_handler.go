@@ -2,8 +2,10 @@ package grpc
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
+	"slices"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -11,6 +13,7 @@ import (
 
 	gatewayPb "github.com/myczh-1/lazy-ctrl-cloud/proto"
 	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 )
@@ -20,6 +23,8 @@ type GatewayHandler struct {
 	gatewayPb.UnimplementedGatewayServiceServer
 	gatewayService *service.GatewayService
 	deviceService  *service.DeviceService
+
+	enrollmentTokens []config.EnrollmentToken
 }
 
 // NewGatewayHandler creates a new gateway gRPC handler
@@ -67,8 +72,12 @@ func (h *GatewayHandler) RegisterDevice(ctx context.Context, req *gatewayPb.Regi
 		}, nil
 	}
 
-	// Generate access token (simplified - in production use proper JWT)
-	accessToken := fmt.Sprintf("device_token_%s_%d", req.DeviceId, device.CreatedAt.Unix())
+	// The device presents this token when it opens its tunnel
+	accessToken, err := h.deviceService.IssueTunnelToken(device.ID)
+	if err != nil {
+		log.Printf("Failed to issue tunnel token for device %s: %v", device.ID, err)
+		return nil, status.Errorf(codes.Internal, "Failed to issue access token")
+	}
 
 	return &gatewayPb.RegisterDeviceResponse{
 		Success:     true,
@@ -77,6 +86,98 @@ func (h *GatewayHandler) RegisterDevice(ctx context.Context, req *gatewayPb.Regi
 	}, nil
 }
 
+// SetEnrollmentTokens sets the pre-shared tokens EnrollDevice accepts
+func (h *GatewayHandler) SetEnrollmentTokens(tokens []config.EnrollmentToken) {
+	h.enrollmentTokens = tokens
+}
+
+// EnrollDevice registers a device on its own behalf. The agent calls it on
+// startup and periodically after, so the device is created on first contact
+// and its address, version and last seen time stay current. A reachable
+// address is connected to at once, replacing a connection to an old address
+func (h *GatewayHandler) EnrollDevice(ctx context.Context, req *controllerPb.EnrollDeviceRequest) (*controllerPb.EnrollDeviceResponse, error) {
+	if req.DeviceId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Device ID is required")
+	}
+
+	ownerID, ok := h.enrollmentOwner(req.EnrollmentToken)
+	if !ok {
+		log.Printf("Rejected enrollment of device %s: invalid enrollment token", req.DeviceId)
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid enrollment token")
+	}
+
+	// A token only refreshes devices owned by its user, so it cannot redirect others
+	if existing, err := h.deviceService.GetDeviceByID(req.DeviceId); err == nil && existing != nil {
+		owned, err := h.deviceService.CheckUserDevicePermission(ownerID, req.DeviceId, "owner")
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
+		}
+		if !owned {
+			return nil, status.Errorf(codes.PermissionDenied, "Device %s is owned by another user", req.DeviceId)
+		}
+	}
+
+	systemInfo := make(map[string]interface{})
+	for k, v := range req.Metadata {
+		systemInfo[k] = v
+	}
+
+	device, created, err := h.deviceService.EnrollDevice(ownerID, req.DeviceId, req.DeviceName, req.Platform, req.Version, req.Address, systemInfo)
+	if err != nil {
+		log.Printf("Failed to enroll device %s: %v", req.DeviceId, err)
+		return &controllerPb.EnrollDeviceResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to enroll device: %v", err),
+		}, nil
+	}
+
+	if req.Address != "" {
+		// Dialing retries with backoff, so it does not hold up the agent
+		go func() {
+			if err := h.gatewayService.EnsureDeviceConnection(device.ID, req.Address); err != nil {
+				log.Printf("Failed to connect enrolled device %s at %s: %v", device.ID, req.Address, err)
+			}
+		}()
+	}
+
+	if created {
+		log.Printf("Device %s enrolled for user %s at %q", device.ID, ownerID, req.Address)
+	}
+
+	// Every enrollment rotates the tunnel token; the enrollment token already
+	// proves the caller owns the device, and an agent that lost its token
+	// gets a working one back
+	accessToken, err := h.deviceService.IssueTunnelToken(device.ID)
+	if err != nil {
+		log.Printf("Failed to issue tunnel token for device %s: %v", device.ID, err)
+		return nil, status.Errorf(codes.Internal, "Failed to issue access token")
+	}
+
+	return &controllerPb.EnrollDeviceResponse{
+		Success:     true,
+		Message:     "Device enrolled successfully",
+		AccessToken: accessToken,
+		Created:     created,
+	}, nil
+}
+
+// enrollmentOwner returns the user owning devices enrolled with token
+func (h *GatewayHandler) enrollmentOwner(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, enrollment := range h.enrollmentTokens {
+		// Tokens without an owner could only create devices nobody can reach
+		if enrollment.Token == "" || enrollment.UserID == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(enrollment.Token)) == 1 {
+			return enrollment.UserID, true
+		}
+	}
+	return "", false
+}
+
 // GetDeviceStatus returns the status of a specific device
 func (h *GatewayHandler) GetDeviceStatus(ctx context.Context, req *gatewayPb.GetDeviceStatusRequest) (*gatewayPb.GetDeviceStatusResponse, error) {
 	// Validate input parameters
@@ -94,7 +195,7 @@ func (h *GatewayHandler) GetDeviceStatus(ctx context.Context, req *gatewayPb.Get
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to access this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "get_device_status", "User does not have permission to access this device")
 	}
 
 	// Get device from database
@@ -102,6 +203,9 @@ func (h *GatewayHandler) GetDeviceStatus(ctx context.Context, req *gatewayPb.Get
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Device not found: %v", err)
 	}
+	if device == nil {
+		return nil, status.Errorf(codes.NotFound, "Device not found")
+	}
 
 	// Convert system info safely
 	systemInfo := make(map[string]string)
@@ -172,17 +276,30 @@ func (h *GatewayHandler) ListUserDevices(ctx context.Context, req *gatewayPb.Lis
 
 // ExecuteCommand executes a command on a remote device
 func (h *GatewayHandler) ExecuteCommand(ctx context.Context, req *gatewayPb.ExecuteCommandRequest) (*gatewayPb.ExecuteCommandResponse, error) {
-	// Check if user has permission to execute commands on this device
-	hasPermission, err := h.deviceService.CheckUserDevicePermission(req.UserId, req.DeviceId, "user")
+	// Admin-only commands need an admin or owner of the device
+	requiredRole, err := h.deviceService.CommandExecuteRole(req.DeviceId, req.CommandId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
+	}
+
+	// Check if user has permission to execute the command on this device
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(req.UserId, req.DeviceId, requiredRole)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to execute commands on this device")
+		if requiredRole == "admin" {
+			return nil, h.permissionDenied(req.UserId, req.DeviceId, requiredRole, "execute_command", "Only device admins may execute admin-only commands")
+		}
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, requiredRole, "execute_command", "User does not have permission to execute commands on this device")
+	}
+	callerRole, err := h.deviceService.UserDeviceRole(req.UserId, req.DeviceId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 
 	// Execute command through gateway service
-	resp, err := h.gatewayService.ExecuteCommand(req.DeviceId, req.CommandId, 30) // 30 second timeout
+	resp, err := h.gatewayService.ExecuteCommand(ctx, req.DeviceId, req.CommandId, callerRole, 30) // 30 second timeout
 	if err != nil {
 		log.Printf("Failed to execute command %s on device %s: %v", req.CommandId, req.DeviceId, err)
 		return &gatewayPb.ExecuteCommandResponse{
@@ -245,14 +362,8 @@ func (h *GatewayHandler) HealthCheck(ctx context.Context, req *gatewayPb.HealthC
 		Status:    resp.Status,
 		Timestamp: timestamppb.Now(),
 		Version:   resp.Version,
-		System: &gatewayPb.SystemInfo{
-			Os:           "unknown", // These would come from the actual response
-			Architecture: "unknown",
-			GoVersion:    "unknown",
-			NumCpu:       0,
-			NumGoroutine: 0,
-		},
-		Services: make(map[string]string),
+		System:    convertSystemInfo(resp.System),
+		Services:  nonNilStringMap(resp.Services),
 	}, nil
 }
 
@@ -264,11 +375,15 @@ func (h *GatewayHandler) GetCommandInfo(ctx context.Context, req *gatewayPb.GetC
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to access this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "get_command_info", "User does not have permission to access this device")
+	}
+	callerRole, err := h.deviceService.UserDeviceRole(req.UserId, req.DeviceId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 
 	// Get command list from device
-	resp, err := h.gatewayService.ListCommands(req.DeviceId)
+	resp, err := h.gatewayService.ListCommands(ctx, req.DeviceId, callerRole)
 	if err != nil {
 		return &gatewayPb.GetCommandInfoResponse{
 			Success: false,
@@ -330,7 +445,10 @@ func (h *GatewayHandler) CreateCommand(ctx context.Context, req *gatewayPb.Creat
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to manage commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "user", "create_command", "User does not have permission to manage commands on this device")
+	}
+	if err := h.checkCommandSafety(req.UserId, req.DeviceId, req.Command, req.AllowDangerous, "create_dangerous_command"); err != nil {
+		return nil, err
 	}
 
 	// Convert template params
@@ -364,6 +482,8 @@ func (h *GatewayHandler) CreateCommand(ctx context.Context, req *gatewayPb.Creat
 		Timeout:          int(req.Timeout),
 		TemplateID:       req.TemplateId,
 		TemplateParams:   templateParams,
+		WorkingDir:       req.WorkingDir,
+		Env:              req.Env,
 		RequiresPin:      req.Security != nil && req.Security.RequirePin,
 		Whitelisted:      req.Security != nil && req.Security.Whitelist,
 		AdminOnly:        req.Security != nil && req.Security.AdminOnly,
@@ -380,6 +500,7 @@ func (h *GatewayHandler) CreateCommand(ctx context.Context, req *gatewayPb.Creat
 	if err := h.deviceService.CreateDeviceCommand(command); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to create command: %v", err)
 	}
+	h.gatewayService.SyncDeviceCommandsAsync(req.DeviceId)
 
 	// Convert to response format
 	commandInfo := &gatewayPb.CommandInfo{
@@ -402,6 +523,8 @@ func (h *GatewayHandler) CreateCommand(ctx context.Context, req *gatewayPb.Creat
 		ShowOnHomepage:       command.ShowOnHomepage,
 		HomepageColor:        command.HomepageColor,
 		HomepagePriority:     int32(command.HomepagePriority),
+		WorkingDir:           command.WorkingDir,
+		Env:                  command.Env,
 	}
 
 	if command.HomepagePosition != nil {
@@ -449,56 +572,39 @@ func (h *GatewayHandler) UpdateCommand(ctx context.Context, req *gatewayPb.Updat
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to manage commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "user", "update_command", "User does not have permission to manage commands on this device")
 	}
 
-	// Convert template params
-	templateParams := make(map[string]interface{})
-	for k, v := range req.TemplateParams {
-		templateParams[k] = v
-	}
-
-	// Convert position config
-	var positionConfig *model.PositionConfig
-	if req.HomeLayout != nil && req.HomeLayout.DefaultPosition != nil {
-		positionConfig = &model.PositionConfig{
-			X:      int(req.HomeLayout.DefaultPosition.X),
-			Y:      int(req.HomeLayout.DefaultPosition.Y),
-			Width:  int(req.HomeLayout.DefaultPosition.Width),
-			Height: int(req.HomeLayout.DefaultPosition.Height),
+	var command *model.DeviceCommand
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) > 0 {
+		// Partial update: start from the stored command and change only the listed fields
+		existing, err := h.deviceService.GetDeviceCommand(req.DeviceId, req.CommandId)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to get command: %v", err)
+		}
+		if existing == nil {
+			return nil, status.Errorf(codes.NotFound, "Command %s not found on device %s", req.CommandId, req.DeviceId)
+		}
+		if err := applyCommandUpdateMask(existing, req, paths); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		command = existing
+	} else {
+		command = commandFromUpdateRequest(req)
+	}
+	// Only a changed command string is checked, so other edits of a stored command still work
+	if len(paths) == 0 || slices.Contains(paths, "command") {
+		if err := h.checkCommandSafety(req.UserId, req.DeviceId, command.Command, req.AllowDangerous, "update_dangerous_command"); err != nil {
+			return nil, err
 		}
-	}
-
-	// Create updated command object
-	command := &model.DeviceCommand{
-		DeviceID:         req.DeviceId,
-		CommandID:        req.CommandId,
-		Name:             req.Name,
-		Description:      req.Description,
-		Category:         req.Category,
-		Icon:             req.Icon,
-		Command:          req.Command,
-		Platform:         req.Platform,
-		CommandType:      req.CommandType,
-		Timeout:          int(req.Timeout),
-		TemplateID:       req.TemplateId,
-		TemplateParams:   templateParams,
-		RequiresPin:      req.Security != nil && req.Security.RequirePin,
-		Whitelisted:      req.Security != nil && req.Security.Whitelist,
-		AdminOnly:        req.Security != nil && req.Security.AdminOnly,
-		ShowOnHomepage:   req.HomeLayout != nil && req.HomeLayout.ShowOnHome,
-		HomepagePosition: positionConfig,
-	}
-
-	if req.HomeLayout != nil {
-		command.HomepageColor = req.HomeLayout.Color
-		command.HomepagePriority = int(req.HomeLayout.Priority)
 	}
 
 	// Update the command
 	if err := h.deviceService.UpdateDeviceCommand(command); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to update command: %v", err)
 	}
+	h.gatewayService.SyncDeviceCommandsAsync(req.DeviceId)
 
 	// Get the updated command to return
 	updatedCommand, err := h.deviceService.GetDeviceCommand(req.DeviceId, req.CommandId)
@@ -527,6 +633,8 @@ func (h *GatewayHandler) UpdateCommand(ctx context.Context, req *gatewayPb.Updat
 		ShowOnHomepage:       updatedCommand.ShowOnHomepage,
 		HomepageColor:        updatedCommand.HomepageColor,
 		HomepagePriority:     int32(updatedCommand.HomepagePriority),
+		WorkingDir:           updatedCommand.WorkingDir,
+		Env:                  updatedCommand.Env,
 	}
 
 	if updatedCommand.HomepagePosition != nil {
@@ -540,7 +648,7 @@ func (h *GatewayHandler) UpdateCommand(ctx context.Context, req *gatewayPb.Updat
 
 	// Convert template params back
 	templateParamsResp := make(map[string]string)
-	for k, v := range templateParams {
+	for k, v := range updatedCommand.TemplateParams {
 		if str, ok := v.(string); ok {
 			templateParamsResp[k] = str
 		} else {
@@ -556,6 +664,143 @@ func (h *GatewayHandler) UpdateCommand(ctx context.Context, req *gatewayPb.Updat
 	}, nil
 }
 
+// checkCommandSafety rejects a command string matching the blocklist unless
+// allowDangerous is set by a device admin, who accepts the risk
+func (h *GatewayHandler) checkCommandSafety(userID, deviceID, command string, allowDangerous bool, action string) error {
+	if !allowDangerous {
+		if err := h.deviceService.CheckCommandSafety(command); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil
+	}
+
+	isAdmin, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "admin")
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
+	}
+	if !isAdmin {
+		return h.permissionDenied(userID, deviceID, "admin", action, "Only device admins may store commands matching the dangerous-pattern blocklist")
+	}
+	return nil
+}
+
+// commandFromUpdateRequest builds the replacement command of a full update
+func commandFromUpdateRequest(req *gatewayPb.UpdateCommandRequest) *model.DeviceCommand {
+	// Convert template params
+	templateParams := make(map[string]interface{})
+	for k, v := range req.TemplateParams {
+		templateParams[k] = v
+	}
+
+	command := &model.DeviceCommand{
+		DeviceID:         req.DeviceId,
+		CommandID:        req.CommandId,
+		Name:             req.Name,
+		Description:      req.Description,
+		Category:         req.Category,
+		Icon:             req.Icon,
+		Command:          req.Command,
+		Platform:         req.Platform,
+		CommandType:      req.CommandType,
+		Timeout:          int(req.Timeout),
+		TemplateID:       req.TemplateId,
+		TemplateParams:   templateParams,
+		WorkingDir:       req.WorkingDir,
+		Env:              req.Env,
+		RequiresPin:      req.Security != nil && req.Security.RequirePin,
+		Whitelisted:      req.Security != nil && req.Security.Whitelist,
+		AdminOnly:        req.Security != nil && req.Security.AdminOnly,
+		ShowOnHomepage:   req.HomeLayout != nil && req.HomeLayout.ShowOnHome,
+		HomepagePosition: positionFromProto(req.GetHomeLayout().GetDefaultPosition()),
+	}
+
+	if req.HomeLayout != nil {
+		command.HomepageColor = req.HomeLayout.Color
+		command.HomepagePriority = int(req.HomeLayout.Priority)
+	}
+
+	return command
+}
+
+// applyCommandUpdateMask copies the fields named by paths from req onto command.
+// Paths are UpdateCommandRequest field names; security and home_layout also
+// accept their sub-fields, such as security.require_pin
+func applyCommandUpdateMask(command *model.DeviceCommand, req *gatewayPb.UpdateCommandRequest, paths []string) error {
+	security := req.GetSecurity()
+	layout := req.GetHomeLayout()
+
+	for _, path := range paths {
+		switch path {
+		case "name":
+			command.Name = req.Name
+		case "description":
+			command.Description = req.Description
+		case "command":
+			command.Command = req.Command
+		case "category":
+			command.Category = req.Category
+		case "icon":
+			command.Icon = req.Icon
+		case "platform":
+			command.Platform = req.Platform
+		case "command_type":
+			command.CommandType = req.CommandType
+		case "timeout":
+			command.Timeout = int(req.Timeout)
+		case "template_id":
+			command.TemplateID = req.TemplateId
+		case "template_params":
+			command.TemplateParams = make(map[string]interface{}, len(req.TemplateParams))
+			for k, v := range req.TemplateParams {
+				command.TemplateParams[k] = v
+			}
+		case "working_dir":
+			command.WorkingDir = req.WorkingDir
+		case "env":
+			command.Env = req.Env
+		case "security":
+			command.RequiresPin = security.GetRequirePin()
+			command.Whitelisted = security.GetWhitelist()
+			command.AdminOnly = security.GetAdminOnly()
+		case "security.require_pin":
+			command.RequiresPin = security.GetRequirePin()
+		case "security.whitelist":
+			command.Whitelisted = security.GetWhitelist()
+		case "security.admin_only":
+			command.AdminOnly = security.GetAdminOnly()
+		case "home_layout":
+			command.ShowOnHomepage = layout.GetShowOnHome()
+			command.HomepagePosition = positionFromProto(layout.GetDefaultPosition())
+			command.HomepageColor = layout.GetColor()
+			command.HomepagePriority = int(layout.GetPriority())
+		case "home_layout.show_on_home":
+			command.ShowOnHomepage = layout.GetShowOnHome()
+		case "home_layout.default_position":
+			command.HomepagePosition = positionFromProto(layout.GetDefaultPosition())
+		case "home_layout.color":
+			command.HomepageColor = layout.GetColor()
+		case "home_layout.priority":
+			command.HomepagePriority = int(layout.GetPriority())
+		default:
+			return fmt.Errorf("unsupported update_mask path: %s", path)
+		}
+	}
+	return nil
+}
+
+// positionFromProto converts a homepage position, returning nil when unset
+func positionFromProto(position *gatewayPb.PositionConfig) *model.PositionConfig {
+	if position == nil {
+		return nil
+	}
+	return &model.PositionConfig{
+		X:      int(position.X),
+		Y:      int(position.Y),
+		Width:  int(position.Width),
+		Height: int(position.Height),
+	}
+}
+
 func (h *GatewayHandler) DeleteCommand(ctx context.Context, req *gatewayPb.DeleteCommandRequest) (*gatewayPb.DeleteCommandResponse, error) {
 	// Validate input parameters
 	if req.DeviceId == "" {
@@ -574,13 +819,14 @@ func (h *GatewayHandler) DeleteCommand(ctx context.Context, req *gatewayPb.Delet
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to manage commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "user", "delete_command", "User does not have permission to manage commands on this device")
 	}
 
 	// Delete the command
 	if err := h.deviceService.DeleteDeviceCommand(req.DeviceId, req.CommandId); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to delete command: %v", err)
 	}
+	h.gatewayService.SyncDeviceCommandsAsync(req.DeviceId)
 
 	return &gatewayPb.DeleteCommandResponse{
 		Success: true,
@@ -606,7 +852,7 @@ func (h *GatewayHandler) GetCommand(ctx context.Context, req *gatewayPb.GetComma
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to view commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "get_command", "User does not have permission to view commands on this device")
 	}
 
 	// Get the specific command
@@ -639,6 +885,8 @@ func (h *GatewayHandler) GetCommand(ctx context.Context, req *gatewayPb.GetComma
 		ShowOnHomepage:       cmd.ShowOnHomepage,
 		HomepageColor:        cmd.HomepageColor,
 		HomepagePriority:     int32(cmd.HomepagePriority),
+		WorkingDir:           cmd.WorkingDir,
+		Env:                  cmd.Env,
 	}
 
 	if cmd.HomepagePosition != nil {
@@ -683,7 +931,7 @@ func (h *GatewayHandler) GetAllCommands(ctx context.Context, req *gatewayPb.GetA
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to view commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "list_commands", "User does not have permission to view commands on this device")
 	}
 
 	// Get all commands for the device
@@ -691,6 +939,10 @@ func (h *GatewayHandler) GetAllCommands(ctx context.Context, req *gatewayPb.GetA
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to get commands: %v", err)
 	}
+	commands, err = h.deviceService.VisibleCommands(req.UserId, req.DeviceId, commands)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
+	}
 
 	// Convert to response format
 	var commandInfos []*gatewayPb.CommandInfo
@@ -715,6 +967,8 @@ func (h *GatewayHandler) GetAllCommands(ctx context.Context, req *gatewayPb.GetA
 			ShowOnHomepage:       cmd.ShowOnHomepage,
 			HomepageColor:        cmd.HomepageColor,
 			HomepagePriority:     int32(cmd.HomepagePriority),
+			WorkingDir:           cmd.WorkingDir,
+			Env:                  cmd.Env,
 		}
 
 		if cmd.HomepagePosition != nil {
@@ -762,7 +1016,7 @@ func (h *GatewayHandler) GetHomepageCommands(ctx context.Context, req *gatewayPb
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to view commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "list_homepage_commands", "User does not have permission to view commands on this device")
 	}
 
 	// Get homepage commands for the device
@@ -770,6 +1024,10 @@ func (h *GatewayHandler) GetHomepageCommands(ctx context.Context, req *gatewayPb
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to get homepage commands: %v", err)
 	}
+	commands, err = h.deviceService.VisibleCommands(req.UserId, req.DeviceId, commands)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
+	}
 
 	// Convert to response format
 	var commandInfos []*gatewayPb.CommandInfo
@@ -794,6 +1052,8 @@ func (h *GatewayHandler) GetHomepageCommands(ctx context.Context, req *gatewayPb
 			ShowOnHomepage:       cmd.ShowOnHomepage,
 			HomepageColor:        cmd.HomepageColor,
 			HomepagePriority:     int32(cmd.HomepagePriority),
+			WorkingDir:           cmd.WorkingDir,
+			Env:                  cmd.Env,
 		}
 
 		if cmd.HomepagePosition != nil {
@@ -844,7 +1104,7 @@ func (h *GatewayHandler) VerifyPin(ctx context.Context, req *gatewayPb.VerifyPin
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to access this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "viewer", "verify_pin", "User does not have permission to access this device")
 	}
 
 	// TODO: PIN verification should be implemented in the controller agent
@@ -876,7 +1136,7 @@ func (h *GatewayHandler) ReloadCommands(ctx context.Context, req *gatewayPb.Relo
 		return nil, status.Errorf(codes.Internal, "Failed to check permissions: %v", err)
 	}
 	if !hasPermission {
-		return nil, status.Errorf(codes.PermissionDenied, "User does not have permission to manage commands on this device")
+		return nil, h.permissionDenied(req.UserId, req.DeviceId, "user", "reload_commands", "User does not have permission to manage commands on this device")
 	}
 
 	// Get device client and forward reload request to device
@@ -950,22 +1210,122 @@ func (h *GatewayHandler) GetStatus(ctx context.Context, req *gatewayPb.GetStatus
 	// Convert uptime to string
 	uptimeStr := fmt.Sprintf("%d seconds", deviceResp.UptimeSeconds)
 
-	// Create system info from health check
-	systemInfo := &gatewayPb.SystemInfo{
-		Os:           "unknown",
-		Architecture: "unknown", 
-		GoVersion:    "unknown",
-		NumCpu:       0,
-		NumGoroutine: 0,
+	memory := deviceResp.Memory
+	if memory == nil {
+		memory = make(map[string]int64)
 	}
 
 	return &gatewayPb.GetStatusResponse{
 		Success:   true,
 		Uptime:    uptimeStr,
 		Timestamp: timestamppb.Now(),
-		System:    systemInfo,
-		Memory:    make(map[string]int64),
+		System:    convertSystemInfo(deviceResp.System),
+		Memory:    memory,
 		Commands:  make(map[string]int32),
-		Services:  make(map[string]string),
+		Services:  nonNilStringMap(deviceResp.Services),
 	}, nil
-}
\ No newline at end of file
+}
+
+// convertSystemInfo maps device system info to the gateway type; older agents
+// do not report it, so missing fields fall back to "unknown"
+func convertSystemInfo(info *controllerPb.SystemInfo) *gatewayPb.SystemInfo {
+	systemInfo := &gatewayPb.SystemInfo{
+		Os:           "unknown",
+		Architecture: "unknown",
+		GoVersion:    "unknown",
+	}
+	if info == nil {
+		return systemInfo
+	}
+
+	if info.Os != "" {
+		systemInfo.Os = info.Os
+	}
+	if info.Architecture != "" {
+		systemInfo.Architecture = info.Architecture
+	}
+	if info.GoVersion != "" {
+		systemInfo.GoVersion = info.GoVersion
+	}
+	systemInfo.NumCpu = info.NumCpu
+	systemInfo.NumGoroutine = info.NumGoroutine
+	return systemInfo
+}
+
+// nonNilStringMap returns m, or an empty map when the device did not send one
+func nonNilStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return make(map[string]string)
+	}
+	return m
+}
+
+// Connect accepts a device-initiated tunnel stream. The first message must
+// register the device; afterwards the cloud sends requests over the stream
+// and the device answers them inline.
+func (h *GatewayHandler) Connect(stream gatewayPb.GatewayService_ConnectServer) error {
+	msg, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	register := msg.GetRegister()
+	if register == nil {
+		return status.Errorf(codes.InvalidArgument, "First tunnel message must be a registration")
+	}
+
+	device, err := h.deviceService.GetDeviceByID(register.DeviceId)
+	if err != nil || device == nil {
+		h.sendRegisterAck(stream, false, "Device not registered")
+		return status.Errorf(codes.NotFound, "Device %s not registered", register.DeviceId)
+	}
+
+	if !h.deviceService.VerifyTunnelToken(device, register.AccessToken) {
+		h.sendRegisterAck(stream, false, "Invalid access token")
+		return status.Errorf(codes.Unauthenticated, "Invalid access token for device %s", register.DeviceId)
+	}
+
+	session := service.NewTunnelSession(device.ID, stream)
+	if err := h.gatewayService.AttachTunnel(device.ID, session); err != nil {
+		h.sendRegisterAck(stream, false, err.Error())
+		return status.Errorf(codes.ResourceExhausted, "Failed to attach tunnel: %v", err)
+	}
+	defer h.gatewayService.DetachTunnel(device.ID, session)
+
+	if err := h.sendRegisterAck(stream, true, "Tunnel established"); err != nil {
+		return err
+	}
+
+	if err := h.deviceService.UpdateDeviceStatus(device.ID, true); err != nil {
+		log.Printf("Failed to mark device %s online: %v", device.ID, err)
+	}
+	log.Printf("Tunnel established for device %s", device.ID)
+
+	err = session.Serve()
+
+	if statusErr := h.deviceService.UpdateDeviceStatus(device.ID, false); statusErr != nil {
+		log.Printf("Failed to mark device %s offline: %v", device.ID, statusErr)
+	}
+	log.Printf("Tunnel closed for device %s: %v", device.ID, err)
+
+	return nil
+}
+
+// permissionDenied records a refused device access and returns the PermissionDenied error for it
+func (h *GatewayHandler) permissionDenied(userID, deviceID, requiredRole, action, message string) error {
+	h.deviceService.RecordAccessDenied(userID, deviceID, requiredRole, action)
+	return status.Error(codes.PermissionDenied, message)
+}
+
+// sendRegisterAck replies to a tunnel registration
+func (h *GatewayHandler) sendRegisterAck(stream gatewayPb.GatewayService_ConnectServer, success bool, message string) error {
+	return stream.Send(&controllerPb.TunnelMessage{
+		Payload: &controllerPb.TunnelMessage_RegisterAck{
+			RegisterAck: &controllerPb.TunnelRegisterAck{
+				Success: success,
+				Message: message,
+			},
+		},
+	})
+}
+
@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm/logger"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// fakeTunnelStream replays recv to Connect and records what it sends back
+type fakeTunnelStream struct {
+	grpc.ServerStream
+	recv []*controllerPb.TunnelMessage
+	sent []*controllerPb.TunnelMessage
+}
+
+func (s *fakeTunnelStream) Context() context.Context { return context.Background() }
+
+func (s *fakeTunnelStream) Recv() (*controllerPb.TunnelMessage, error) {
+	if len(s.recv) == 0 {
+		return nil, io.EOF
+	}
+	msg := s.recv[0]
+	s.recv = s.recv[1:]
+	return msg, nil
+}
+
+func (s *fakeTunnelStream) Send(msg *controllerPb.TunnelMessage) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func registerMessage(deviceID, token string) *controllerPb.TunnelMessage {
+	return &controllerPb.TunnelMessage{
+		Payload: &controllerPb.TunnelMessage_Register{
+			Register: &controllerPb.TunnelRegister{DeviceId: deviceID, AccessToken: token},
+		},
+	}
+}
+
+// newTestGatewayHandler returns a handler backed by an in-memory database
+// holding one device, "known-device"
+func newTestGatewayHandler(t *testing.T) (*GatewayHandler, *service.DeviceService) {
+	t.Helper()
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	deviceRepo := repository.NewDeviceRepository(db)
+	deviceService := service.NewDeviceService(deviceRepo)
+	if err := deviceRepo.Create(&model.Device{
+		ID:         "known-device",
+		DeviceName: "Known",
+		DeviceType: "desktop",
+		Platform:   "linux",
+	}); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	return NewGatewayHandler(nil, deviceService), deviceService
+}
+
+func TestConnectRejectsBeforeAttaching(t *testing.T) {
+	h, _ := newTestGatewayHandler(t)
+
+	tests := []struct {
+		name     string
+		recv     []*controllerPb.TunnelMessage
+		wantCode codes.Code
+		wantAck  bool
+	}{
+		{
+			name:     "unknown device",
+			recv:     []*controllerPb.TunnelMessage{registerMessage("no-such-device", "anything")},
+			wantCode: codes.NotFound,
+			wantAck:  true,
+		},
+		{
+			name:     "known device with wrong token",
+			recv:     []*controllerPb.TunnelMessage{registerMessage("known-device", "wrong")},
+			wantCode: codes.Unauthenticated,
+			wantAck:  true,
+		},
+		{
+			name:     "device never issued a token",
+			recv:     []*controllerPb.TunnelMessage{registerMessage("known-device", "")},
+			wantCode: codes.Unauthenticated,
+			wantAck:  true,
+		},
+		{
+			name:     "first message is not a registration",
+			recv:     []*controllerPb.TunnelMessage{{}},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &fakeTunnelStream{recv: tt.recv}
+			err := h.Connect(stream)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v (err %v)", code, tt.wantCode, err)
+			}
+			if !tt.wantAck {
+				return
+			}
+			if len(stream.sent) != 1 || stream.sent[0].GetRegisterAck().GetSuccess() {
+				t.Errorf("want one failed register ack, got %v", stream.sent)
+			}
+		})
+	}
+}
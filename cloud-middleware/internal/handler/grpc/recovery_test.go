@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+	tests := []struct {
+		name     string
+		handler  grpc.UnaryHandler
+		wantResp interface{}
+		wantCode codes.Code
+	}{
+		{
+			name:     "passes result through",
+			handler:  func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil },
+			wantResp: "ok",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "passes error through",
+			handler:  func(ctx context.Context, req interface{}) (interface{}, error) { return nil, handlerErr },
+			wantCode: codes.Unknown,
+		},
+		{
+			name: "recovers nil dereference",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				var m map[string]*int
+				return *m["missing"], nil
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := RecoveryUnaryInterceptor(context.Background(), nil, info, tt.handler)
+			if resp != tt.wantResp {
+				t.Errorf("resp = %v, want %v", resp, tt.wantResp)
+			}
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %v, want %v (err %v)", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  grpc.StreamHandler
+		wantCode codes.Code
+	}{
+		{
+			name:     "returns handler result",
+			handler:  func(srv interface{}, stream grpc.ServerStream) error { return nil },
+			wantCode: codes.OK,
+		},
+		{
+			name:     "recovers panic",
+			handler:  func(srv interface{}, stream grpc.ServerStream) error { panic("tunnel exploded") },
+			wantCode: codes.Internal,
+		},
+	}
+
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Connect"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RecoveryStreamInterceptor(nil, nil, info, tt.handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %v, want %v (err %v)", code, tt.wantCode, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+	gatewayPb "github.com/myczh-1/lazy-ctrl-cloud/proto"
+)
+
+// storedCommand is the command each update starts from
+func storedCommand() *model.DeviceCommand {
+	return &model.DeviceCommand{
+		DeviceID:         "known-device",
+		CommandID:        "lights",
+		Name:             "Lights",
+		Description:      "Toggle the lights",
+		Category:         "home",
+		Icon:             "bulb",
+		Command:          "toggle-lights",
+		Platform:         "linux",
+		Timeout:          5000,
+		WorkingDir:       "/srv",
+		Env:              model.CommandEnv{"ROOM": "kitchen"},
+		RequiresPin:      true,
+		Whitelisted:      true,
+		ShowOnHomepage:   true,
+		HomepageColor:    "#ffaa00",
+		HomepagePriority: 3,
+		HomepagePosition: &model.PositionConfig{X: 1, Y: 2, Width: 3, Height: 4},
+	}
+}
+
+// newUpdateCommandHandler returns a handler whose database holds known-device
+// with the stored command, "member", a user of the device, and "device-admin",
+// its admin. Commands
+// containing "rm -rf /" are blocked
+func newUpdateCommandHandler(t *testing.T) (*GatewayHandler, *service.DeviceService) {
+	t.Helper()
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	deviceRepo := repository.NewDeviceRepository(db)
+	deviceService := service.NewDeviceService(deviceRepo)
+	deviceService.SetBlockedPatterns([]string{"rm -rf /"})
+	if err := deviceRepo.Create(&model.Device{ID: "known-device", DeviceName: "Known", DeviceType: "desktop", Platform: "linux"}); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	for userID, role := range map[string]string{"member": "user", "device-admin": "admin"} {
+		if err := deviceRepo.CreateUserDevice(&model.UserDevice{UserID: userID, DeviceID: "known-device", Role: role, Status: "active"}); err != nil {
+			t.Fatalf("bind %s: %v", userID, err)
+		}
+	}
+	if err := deviceService.CreateDeviceCommand(storedCommand()); err != nil {
+		t.Fatalf("create command: %v", err)
+	}
+	gatewayService, err := service.NewGatewayService(config.GatewayConfig{AllowInsecure: true}, deviceService)
+	if err != nil {
+		t.Fatalf("gateway service: %v", err)
+	}
+	return NewGatewayHandler(gatewayService, deviceService), deviceService
+}
+
+func TestUpdateCommandMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *gatewayPb.UpdateCommandRequest
+		wantCode codes.Code
+		// change is applied to the stored command to get the expected result
+		change func(c *model.DeviceCommand)
+	}{
+		{
+			name:   "name only",
+			req:    &gatewayPb.UpdateCommandRequest{Name: "Kitchen lights", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}},
+			change: func(c *model.DeviceCommand) { c.Name = "Kitchen lights" },
+		},
+		{
+			name: "unlisted fields ignored",
+			req: &gatewayPb.UpdateCommandRequest{
+				Name:       "Kitchen lights",
+				Command:    "rm -rf /",
+				Security:   &gatewayPb.SecurityConfig{},
+				HomeLayout: &gatewayPb.HomeLayoutConfig{Color: "#000000"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+			},
+			change: func(c *model.DeviceCommand) { c.Name = "Kitchen lights" },
+		},
+		{
+			name:   "clear one security flag",
+			req:    &gatewayPb.UpdateCommandRequest{Security: &gatewayPb.SecurityConfig{Whitelist: true}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"security.require_pin"}}},
+			change: func(c *model.DeviceCommand) { c.RequiresPin = false },
+		},
+		{
+			name:   "whole security",
+			req:    &gatewayPb.UpdateCommandRequest{Security: &gatewayPb.SecurityConfig{AdminOnly: true}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"security"}}},
+			change: func(c *model.DeviceCommand) { c.RequiresPin, c.Whitelisted, c.AdminOnly = false, false, true },
+		},
+		{
+			name:   "layout color",
+			req:    &gatewayPb.UpdateCommandRequest{HomeLayout: &gatewayPb.HomeLayoutConfig{Color: "#00ff00", Priority: 9}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"home_layout.color"}}},
+			change: func(c *model.DeviceCommand) { c.HomepageColor = "#00ff00" },
+		},
+		{
+			name: "layout position",
+			req: &gatewayPb.UpdateCommandRequest{
+				HomeLayout: &gatewayPb.HomeLayoutConfig{DefaultPosition: &gatewayPb.PositionConfig{X: 5, Y: 6, Width: 7, Height: 8}},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"home_layout.default_position"}},
+			},
+			change: func(c *model.DeviceCommand) {
+				c.HomepagePosition = &model.PositionConfig{X: 5, Y: 6, Width: 7, Height: 8}
+			},
+		},
+		{
+			name: "several fields",
+			req:  &gatewayPb.UpdateCommandRequest{Description: "", Timeout: 1000, Env: map[string]string{"ROOM": "hall"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"description", "timeout", "env"}}},
+			change: func(c *model.DeviceCommand) {
+				c.Description, c.Timeout, c.Env = "", 1000, model.CommandEnv{"ROOM": "hall"}
+			},
+		},
+		{
+			name:     "unknown path",
+			req:      &gatewayPb.UpdateCommandRequest{Name: "Kitchen lights", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "owner"}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "dangerous command",
+			req:      &gatewayPb.UpdateCommandRequest{Command: "rm -rf /", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"command"}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "no mask replaces everything",
+			req:  &gatewayPb.UpdateCommandRequest{Name: "Kitchen lights", Command: "toggle-lights"},
+			change: func(c *model.DeviceCommand) {
+				*c = model.DeviceCommand{DeviceID: c.DeviceID, CommandID: c.CommandID, Name: "Kitchen lights", Command: "toggle-lights", TemplateParams: map[string]interface{}{}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, deviceService := newUpdateCommandHandler(t)
+			tt.req.DeviceId, tt.req.UserId, tt.req.CommandId = "known-device", "member", "lights"
+
+			_, err := h.UpdateCommand(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+
+			want := storedCommand()
+			if tt.change != nil {
+				tt.change(want)
+			}
+			got, err := deviceService.GetDeviceCommand("known-device", "lights")
+			if err != nil || got == nil {
+				t.Fatalf("get command: %v", err)
+			}
+			if diff := commandFieldDiff(got, want); diff != "" {
+				t.Errorf("stored command differs in %s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateCommandMaskNotFound(t *testing.T) {
+	h, _ := newUpdateCommandHandler(t)
+	_, err := h.UpdateCommand(context.Background(), &gatewayPb.UpdateCommandRequest{
+		DeviceId:   "known-device",
+		UserId:     "member",
+		CommandId:  "missing",
+		Name:       "Missing",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Fatalf("code = %v, want NotFound: %v", code, err)
+	}
+}
+
+// commandFieldDiff names the first user-editable field where got and want differ
+func commandFieldDiff(got, want *model.DeviceCommand) string {
+	fields := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"name", got.Name, want.Name},
+		{"description", got.Description, want.Description},
+		{"category", got.Category, want.Category},
+		{"icon", got.Icon, want.Icon},
+		{"command", got.Command, want.Command},
+		{"platform", got.Platform, want.Platform},
+		{"command_type", got.CommandType, want.CommandType},
+		{"timeout", got.Timeout, want.Timeout},
+		{"template_id", got.TemplateID, want.TemplateID},
+		{"working_dir", got.WorkingDir, want.WorkingDir},
+		{"env", len(got.Env) == 0 && len(want.Env) == 0 || reflect.DeepEqual(got.Env, want.Env), true},
+		{"requires_pin", got.RequiresPin, want.RequiresPin},
+		{"whitelisted", got.Whitelisted, want.Whitelisted},
+		{"admin_only", got.AdminOnly, want.AdminOnly},
+		{"show_on_homepage", got.ShowOnHomepage, want.ShowOnHomepage},
+		{"homepage_color", got.HomepageColor, want.HomepageColor},
+		{"homepage_priority", got.HomepagePriority, want.HomepagePriority},
+		{"homepage_position", got.HomepagePosition, want.HomepagePosition},
+	}
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.got, field.want) {
+			return fmt.Sprintf("%s: got %v, want %v", field.name, field.got, field.want)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// DeviceHandler handles HTTP requests for device administration
+type DeviceHandler struct {
+	deviceService *service.DeviceService
+	userService   service.UserService
+}
+
+// NewDeviceHandler creates a new device handler
+func NewDeviceHandler(deviceService *service.DeviceService, userService service.UserService) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+		userService:   userService,
+	}
+}
+
+// AdminDeviceListResponse represents paginated device list response
+type AdminDeviceListResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    []*model.Device `json:"data"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+}
+
+// AccessAuditResponse represents paginated access audit response
+type AccessAuditResponse struct {
+	Success bool                           `json:"success"`
+	Message string                         `json:"message"`
+	Data    []*repository.AccessAuditEntry `json:"data"`
+	Total   int64                          `json:"total"`
+	Page    int                            `json:"page"`
+	Limit   int                            `json:"limit"`
+}
+
+// AccessLogResponse represents paginated denied access response
+type AccessLogResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Data    []*model.AccessLog `json:"data"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Limit   int                `json:"limit"`
+}
+
+// ListDevices lists all devices with filtering and pagination (admin only)
+// @Summary List all devices
+// @Description List devices filtered by online status, platform and name substring
+// @Tags Admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size (max 100)" default(10)
+// @Param online query bool false "Filter by online status"
+// @Param platform query string false "Filter by platform"
+// @Param name query string false "Filter by device name substring"
+// @Success 200 {object} AdminDeviceListResponse
+// @Failure 400 {object} StandardResponse
+// @Failure 403 {object} StandardResponse
+// @Router /api/v1/admin/devices [get]
+func (h *DeviceHandler) ListDevices(c *gin.Context) {
+	if !requireAdmin(c, h.userService) {
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	limit := 10
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	// Parse filters
+	filter := repository.DeviceFilter{
+		Platform: c.Query("platform"),
+		Name:     c.Query("name"),
+	}
+
+	if onlineStr := c.Query("online"); onlineStr != "" {
+		online, err := strconv.ParseBool(onlineStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, StandardResponse{
+				Success: false,
+				Message: "Invalid online filter: " + onlineStr,
+			})
+			return
+		}
+		filter.Online = &online
+	}
+
+	offset := (page - 1) * limit
+
+	devices, total, err := h.deviceService.ListDevices(filter, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AdminDeviceListResponse{
+		Success: true,
+		Message: "Devices retrieved successfully",
+		Data:    devices,
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+	})
+}
+
+// AccessAudit lists which users are bound to each device and with what role (admin only)
+// @Summary Audit device access
+// @Description List user-device bindings across all devices, optionally filtered by role
+// @Tags Admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size (max 100)" default(10)
+// @Param role query string false "Filter by device role (owner, admin, user, viewer)"
+// @Success 200 {object} AccessAuditResponse
+// @Failure 403 {object} StandardResponse
+// @Router /api/v1/admin/access-audit [get]
+func (h *DeviceHandler) AccessAudit(c *gin.Context) {
+	if !requireAdmin(c, h.userService) {
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	limit := 10
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	offset := (page - 1) * limit
+
+	entries, total, err := h.deviceService.ListAccessAudit(c.Query("role"), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AccessAuditResponse{
+		Success: true,
+		Message: "Access audit retrieved successfully",
+		Data:    entries,
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+	})
+}
+
+// ListAccessLogs lists device accesses refused for lacking the required role (admin only)
+// @Summary List denied device accesses
+// @Description List requests refused because the user lacked the required device role, newest first
+// @Tags Admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size (max 100)" default(10)
+// @Param user_id query string false "Filter by user ID"
+// @Param device_id query string false "Filter by device ID"
+// @Param action query string false "Filter by refused action, e.g. execute_command"
+// @Success 200 {object} AccessLogResponse
+// @Failure 403 {object} StandardResponse
+// @Router /api/v1/admin/access-logs [get]
+func (h *DeviceHandler) ListAccessLogs(c *gin.Context) {
+	if !requireAdmin(c, h.userService) {
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	limit := 10
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	offset := (page - 1) * limit
+
+	filter := repository.AccessLogFilter{
+		UserID:   c.Query("user_id"),
+		DeviceID: c.Query("device_id"),
+		Action:   c.Query("action"),
+	}
+
+	entries, total, err := h.deviceService.ListAccessLogs(filter, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AccessLogResponse{
+		Success: true,
+		Message: "Access logs retrieved successfully",
+		Data:    entries,
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+	})
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// testServices are the services of a handler test over an in-memory database
+// with a regular user "member" and a system admin "admin"
+type testServices struct {
+	userService   service.UserService
+	deviceService *service.DeviceService
+	deviceRepo    repository.DeviceRepository
+}
+
+func newTestServices(t *testing.T) *testServices {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db)
+	for _, user := range []*model.User{
+		{ID: "member", Username: "member", Email: "member@example.com", Password: "password123", Role: "user"},
+		{ID: "admin", Username: "admin", Email: "admin@example.com", Password: "password123", Role: "admin"},
+	} {
+		if err := userRepo.Create(user); err != nil {
+			t.Fatalf("create user %s: %v", user.ID, err)
+		}
+	}
+	userService, err := service.NewUserService(userRepo, nil, config.JWTConfig{SecretKey: "test-secret-key"}, config.TwoFactorConfig{}, config.WebAuthnConfig{})
+	if err != nil {
+		t.Fatalf("create user service: %v", err)
+	}
+	deviceRepo := repository.NewDeviceRepository(db)
+	return &testServices{
+		userService:   userService,
+		deviceService: service.NewDeviceService(deviceRepo),
+		deviceRepo:    deviceRepo,
+	}
+}
+
+// asTestUser authenticates the request as the user named by the X-Test-User
+// header, standing in for the JWT middleware
+func asTestUser(c *gin.Context) {
+	if userID := c.GetHeader("X-Test-User"); userID != "" {
+		c.Set("user_id", userID)
+	}
+}
+
+// serveAs sends a request as user, anonymous when empty
+func serveAs(router *gin.Engine, method, path, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListDevices(t *testing.T) {
+	services := newTestServices(t)
+	for i := 1; i <= 12; i++ {
+		device := &model.Device{ID: fmt.Sprintf("dev-%02d", i), DeviceName: fmt.Sprintf("dev-%02d", i), DeviceType: "desktop", Platform: "linux", Online: i%3 == 0}
+		if err := services.deviceRepo.Create(device); err != nil {
+			t.Fatalf("create device: %v", err)
+		}
+	}
+	handler := NewDeviceHandler(services.deviceService, services.userService)
+	router := gin.New()
+	router.GET("/admin/devices", asTestUser, handler.ListDevices)
+
+	tests := []struct {
+		name      string
+		user      string
+		query     string
+		status    int
+		wantCount int
+		wantTotal int64
+		wantPage  int
+		wantLimit int
+	}{
+		{"anonymous", "", "", http.StatusUnauthorized, 0, 0, 0, 0},
+		{"regular user", "member", "", http.StatusForbidden, 0, 0, 0, 0},
+		{"defaults", "admin", "", http.StatusOK, 10, 12, 1, 10},
+		{"second page", "admin", "?page=2", http.StatusOK, 2, 12, 2, 10},
+		{"custom limit", "admin", "?limit=5&page=3", http.StatusOK, 2, 12, 3, 5},
+		{"limit over 100 falls back", "admin", "?limit=500", http.StatusOK, 10, 12, 1, 10},
+		{"invalid page falls back", "admin", "?page=0", http.StatusOK, 10, 12, 1, 10},
+		{"online filter", "admin", "?online=true", http.StatusOK, 4, 4, 1, 10},
+		{"name filter", "admin", "?name=DEV-1", http.StatusOK, 3, 3, 1, 10},
+		{"invalid online filter", "admin", "?online=maybe", http.StatusBadRequest, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(router, http.MethodGet, "/admin/devices"+tt.query, tt.user)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp AdminDeviceListResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Data) != tt.wantCount || resp.Total != tt.wantTotal || resp.Page != tt.wantPage || resp.Limit != tt.wantLimit {
+				t.Fatalf("got %d devices, total %d, page %d, limit %d; want %d, %d, %d, %d",
+					len(resp.Data), resp.Total, resp.Page, resp.Limit, tt.wantCount, tt.wantTotal, tt.wantPage, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestAccessAudit(t *testing.T) {
+	services := newTestServices(t)
+	for _, id := range []string{"dev-1", "dev-2"} {
+		if err := services.deviceRepo.Create(&model.Device{ID: id, DeviceName: id, DeviceType: "desktop", Platform: "linux"}); err != nil {
+			t.Fatalf("create device: %v", err)
+		}
+	}
+	for _, binding := range []*model.UserDevice{
+		{UserID: "member", DeviceID: "dev-1", Role: "owner", Status: model.UserDeviceStatusActive},
+		{UserID: "admin", DeviceID: "dev-1", Role: "viewer", Status: model.UserDeviceStatusActive},
+		{UserID: "member", DeviceID: "dev-2", Role: "viewer", Status: model.UserDeviceStatusActive},
+	} {
+		if err := services.deviceRepo.CreateUserDevice(binding); err != nil {
+			t.Fatalf("bind: %v", err)
+		}
+	}
+	handler := NewDeviceHandler(services.deviceService, services.userService)
+	router := gin.New()
+	router.GET("/admin/access-audit", asTestUser, handler.AccessAudit)
+
+	tests := []struct {
+		name      string
+		user      string
+		query     string
+		status    int
+		want      []string // device/user:role
+		wantTotal int64
+		wantLimit int
+	}{
+		{"anonymous", "", "", http.StatusUnauthorized, nil, 0, 0},
+		{"device owner is not an admin", "member", "", http.StatusForbidden, nil, 0, 0},
+		{"every binding", "admin", "", http.StatusOK, []string{"dev-1/admin:viewer", "dev-1/member:owner", "dev-2/member:viewer"}, 3, 10},
+		{"role filter", "admin", "?role=viewer", http.StatusOK, []string{"dev-1/admin:viewer", "dev-2/member:viewer"}, 2, 10},
+		{"paginated", "admin", "?limit=2&page=2", http.StatusOK, []string{"dev-2/member:viewer"}, 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(router, http.MethodGet, "/admin/access-audit"+tt.query, tt.user)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp AccessAuditResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			var got []string
+			for _, entry := range resp.Data {
+				got = append(got, entry.DeviceID+"/"+entry.UserID+":"+entry.Role)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || resp.Total != tt.wantTotal || resp.Limit != tt.wantLimit {
+				t.Fatalf("got %v, total %d, limit %d; want %v, %d, %d", got, resp.Total, resp.Limit, tt.want, tt.wantTotal, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// BindDeviceRequest represents a request to bind a user to a device
+type BindDeviceRequest struct {
+	UserID string `json:"user_id"`                                                // defaults to the caller; only owners may bind others
+	Role   string `json:"role" binding:"omitempty,oneof=owner admin user viewer"` // defaults to user
+}
+
+// BindDevice binds a user to a device
+// @Summary Bind a user to a device
+// @Description Device owners bind users directly. Anyone else can only ask to be bound themselves, which creates a pending binding that grants no access until an owner approves it
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param request body BindDeviceRequest true "Binding request"
+// @Success 201 {object} model.UserDevice
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/members [post]
+func (h *GatewayHandler) BindDevice(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// An empty body asks for the caller to be bound with the default role
+	var req BindDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = userID
+	}
+	if req.Role == "" {
+		req.Role = "user"
+	}
+
+	userDevice, err := h.deviceService.BindDeviceToUser(userID, req.UserID, deviceID, req.Role)
+	switch {
+	case errors.Is(err, service.ErrNotDeviceOwner):
+		h.deviceService.RecordAccessDenied(userID, deviceID, "owner", "bind_user")
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrOwnershipRequested):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAlreadyBound):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, userDevice)
+}
+
+// ListAccessRequests lists the pending bindings of a device
+// @Summary List pending access requests
+// @Description List users waiting for approval to be bound to a device (device owners only)
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Success 200 {array} repository.AccessAuditEntry
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/members/pending [get]
+func (h *GatewayHandler) ListAccessRequests(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	if _, ok := h.requireDeviceOwner(c, deviceID, "list_access_requests"); !ok {
+		return
+	}
+
+	requests, err := h.deviceService.ListAccessRequests(deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ApproveAccessRequest activates a pending binding
+// @Summary Approve an access request
+// @Description Activate a user's pending binding to a device with the role they asked for (device owners only)
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} model.UserDevice
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/members/{user_id}/approve [post]
+func (h *GatewayHandler) ApproveAccessRequest(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	actorID, ok := h.requireDeviceOwner(c, deviceID, "approve_access_request")
+	if !ok {
+		return
+	}
+
+	userDevice, err := h.deviceService.ApproveAccessRequest(actorID, c.Param("user_id"), deviceID)
+	if err != nil {
+		c.JSON(accessRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, userDevice)
+}
+
+// RejectAccessRequest drops a pending binding
+// @Summary Reject an access request
+// @Description Remove a user's pending binding to a device (device owners only)
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/members/{user_id}/reject [post]
+func (h *GatewayHandler) RejectAccessRequest(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	actorID, ok := h.requireDeviceOwner(c, deviceID, "reject_access_request")
+	if !ok {
+		return
+	}
+
+	if err := h.deviceService.RejectAccessRequest(actorID, c.Param("user_id"), deviceID); err != nil {
+		c.JSON(accessRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access request rejected"})
+}
+
+// requireDeviceOwner answers the request with an error unless the caller owns
+// the device, recording the denial; returns the caller's user ID
+func (h *GatewayHandler) requireDeviceOwner(c *gin.Context, deviceID, action string) (string, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return "", false
+	}
+
+	isOwner, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "owner")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if !isOwner {
+		h.deviceService.RecordAccessDenied(userID, deviceID, "owner", action)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return "", false
+	}
+
+	return userID, true
+}
+
+// accessRequestErrorStatus maps an approval or rejection error to its HTTP status
+func accessRequestErrorStatus(err error) int {
+	if errors.Is(err, service.ErrNoAccessRequest) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+)
+
+// eventsKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not drop the connection
+const eventsKeepAlive = 15 * time.Second
+
+// StreamEvents streams device events as Server-Sent Events
+// @Summary Stream device events
+// @Description Server-Sent Events stream of device.online, device.offline and command.executed events for the devices the caller can view. Each event's data is the JSON event; idle streams get a keepalive comment every 15 seconds. Events are not replayed, so clients should reload device state after reconnecting
+// @Tags Gateway
+// @Produce text/event-stream
+// @Success 200 {object} events.Event
+// @Failure 401 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/gateway/events [get]
+func (h *GatewayHandler) StreamEvents(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if h.eventBus == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Events are not available"})
+		return
+	}
+
+	sub := h.eventBus.Subscribe()
+	defer func() {
+		sub.Close()
+		if dropped := sub.Dropped(); dropped > 0 {
+			log.Printf("Event stream of user %s fell behind and missed %d events", userID, dropped)
+		}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if !h.canViewDeviceEvents(c, userID, event.DeviceID) {
+				continue
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// canViewDeviceEvents reports whether the caller may see a device's events.
+// Permissions are checked per event, so revoked access takes effect at once
+func (h *GatewayHandler) canViewDeviceEvents(c *gin.Context, userID, deviceID string) bool {
+	if !middleware.CanAccessDevice(c, deviceID) {
+		return false
+	}
+
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "viewer")
+	if err != nil {
+		log.Printf("Failed to check event permission of user %s on device %s: %v", userID, deviceID, err)
+		return false
+	}
+	return hasPermission
+}
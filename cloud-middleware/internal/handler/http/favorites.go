@@ -0,0 +1,196 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// AddFavoriteRequest represents a request to favorite a device command
+type AddFavoriteRequest struct {
+	CommandID string `json:"command_id" binding:"required"`
+}
+
+// FavoriteCommandResponse is a favorite with the full information of its command
+type FavoriteCommandResponse struct {
+	CommandID        string                 `json:"command_id"`
+	DeviceID         string                 `json:"device_id"`
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	Category         string                 `json:"category"`
+	Icon             string                 `json:"icon"`
+	Command          string                 `json:"command"`
+	Platform         string                 `json:"platform"`
+	CommandType      string                 `json:"command_type"`
+	Timeout          int                    `json:"timeout"` // milliseconds
+	TemplateID       string                 `json:"template_id,omitempty"`
+	TemplateParams   map[string]interface{} `json:"template_params,omitempty"`
+	RequiresPin      bool                   `json:"requires_pin"`
+	AdminOnly        bool                   `json:"admin_only"`
+	ShowOnHomepage   bool                   `json:"show_on_homepage"`
+	HomepageColor    string                 `json:"homepage_color,omitempty"`
+	HomepagePriority int                    `json:"homepage_priority"`
+	FavoritedAt      time.Time              `json:"favorited_at"`
+}
+
+// ListFavorites lists the caller's favorite commands of a device
+// @Summary List favorite commands
+// @Description List the caller's favorite commands of a device, most recently added first, with their full command information. Favorites are per user, unlike the device-wide homepage layout
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Success 200 {array} FavoriteCommandResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/favorites [get]
+func (h *GatewayHandler) ListFavorites(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	userID, ok := h.requireDeviceViewer(c, deviceID, "list_favorites")
+	if !ok {
+		return
+	}
+
+	favorites, err := h.deviceService.GetFavoriteCommands(userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]FavoriteCommandResponse, len(favorites))
+	for i, favorite := range favorites {
+		response[i] = favoriteToResponse(favorite)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AddFavorite adds a command to the caller's favorites
+// @Summary Add a favorite command
+// @Description Add a command of a device to the caller's favorites. Adding a favorite again is a no-op
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param request body AddFavoriteRequest true "Command to favorite"
+// @Success 201 {object} model.UserFavoriteCommand
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/favorites [post]
+func (h *GatewayHandler) AddFavorite(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	userID, ok := h.requireDeviceViewer(c, deviceID, "add_favorite")
+	if !ok {
+		return
+	}
+
+	var req AddFavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	favorite, err := h.deviceService.AddFavoriteCommand(userID, deviceID, req.CommandID)
+	switch {
+	case errors.Is(err, service.ErrCommandNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, favorite)
+}
+
+// RemoveFavorite removes a command from the caller's favorites
+// @Summary Remove a favorite command
+// @Description Remove a command of a device from the caller's favorites
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param command_id path string true "Command ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/favorites/{command_id} [delete]
+func (h *GatewayHandler) RemoveFavorite(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	userID, ok := h.requireDeviceViewer(c, deviceID, "remove_favorite")
+	if !ok {
+		return
+	}
+
+	err := h.deviceService.RemoveFavoriteCommand(userID, deviceID, c.Param("command_id"))
+	switch {
+	case errors.Is(err, service.ErrNoFavorite):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Favorite removed"})
+}
+
+// requireDeviceViewer answers the request with an error unless the caller has
+// at least the viewer role on the device, recording the denial; returns the
+// caller's user ID
+func (h *GatewayHandler) requireDeviceViewer(c *gin.Context, deviceID, action string) (string, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return "", false
+	}
+	if !middleware.CanAccessDevice(c, deviceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed for this device"})
+		return "", false
+	}
+
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "viewer")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if !hasPermission {
+		h.deviceService.RecordAccessDenied(userID, deviceID, "viewer", action)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return "", false
+	}
+
+	return userID, true
+}
+
+// favoriteToResponse converts a stored favorite to its HTTP representation
+func favoriteToResponse(favorite *repository.FavoriteCommand) FavoriteCommandResponse {
+	cmd := favorite.DeviceCommand
+	return FavoriteCommandResponse{
+		CommandID:        cmd.CommandID,
+		DeviceID:         cmd.DeviceID,
+		Name:             cmd.Name,
+		Description:      cmd.Description,
+		Category:         cmd.Category,
+		Icon:             cmd.Icon,
+		Command:          cmd.Command,
+		Platform:         cmd.Platform,
+		CommandType:      cmd.CommandType,
+		Timeout:          cmd.Timeout,
+		TemplateID:       cmd.TemplateID,
+		TemplateParams:   cmd.TemplateParams,
+		RequiresPin:      cmd.RequiresPin,
+		AdminOnly:        cmd.AdminOnly,
+		ShowOnHomepage:   cmd.ShowOnHomepage,
+		HomepageColor:    cmd.HomepageColor,
+		HomepagePriority: cmd.HomepagePriority,
+		FavoritedAt:      favorite.FavoritedAt,
+	}
+}
@@ -2,10 +2,13 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/events"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
 	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
 )
@@ -14,21 +17,26 @@ import (
 type GatewayHandler struct {
 	gatewayService *service.GatewayService
 	deviceService  *service.DeviceService
+	userService    service.UserService
+	eventBus       *events.Bus
 }
 
 // NewGatewayHandler creates a new gateway handler
-func NewGatewayHandler(gatewayService *service.GatewayService, deviceService *service.DeviceService) *GatewayHandler {
+func NewGatewayHandler(gatewayService *service.GatewayService, deviceService *service.DeviceService, userService service.UserService, eventBus *events.Bus) *GatewayHandler {
 	return &GatewayHandler{
 		gatewayService: gatewayService,
 		deviceService:  deviceService,
+		userService:    userService,
+		eventBus:       eventBus,
 	}
 }
 
 // ExecuteCommandRequest represents the request body for command execution
 type ExecuteCommandRequest struct {
-	DeviceID  string `json:"device_id" binding:"required"`
-	CommandID string `json:"command_id" binding:"required"`
-	Timeout   int32  `json:"timeout,omitempty"` // optional timeout in seconds
+	DeviceID       string `json:"device_id" binding:"required"`
+	CommandID      string `json:"command_id" binding:"required"`
+	Timeout        int32  `json:"timeout,omitempty"`          // optional timeout in seconds
+	QueueIfOffline bool   `json:"queue_if_offline,omitempty"` // queue the command until the device reconnects instead of failing
 }
 
 // ExecuteCommandResponse represents the response for command execution
@@ -40,10 +48,20 @@ type ExecuteCommandResponse struct {
 	ExecutionTimeMs int64  `json:"execution_time_ms"`
 }
 
-// DeviceConnectionRequest represents the request to connect a device
+// DeviceConnectionRequest represents the request to connect a device.
+// Either address or addresses must be set; addresses are tried in order.
 type DeviceConnectionRequest struct {
-	DeviceID string `json:"device_id" binding:"required"`
-	Address  string `json:"address" binding:"required"` // IP:Port
+	DeviceID  string   `json:"device_id" binding:"required"`
+	Address   string   `json:"address,omitempty"`   // IP:Port
+	Addresses []string `json:"addresses,omitempty"` // IP:Port, in order of preference
+}
+
+// UpdateAgentRequest describes the agent release a device should install
+type UpdateAgentRequest struct {
+	URL       string `json:"url" binding:"required"`       // download URL, may be pre-signed
+	SHA256    string `json:"sha256" binding:"required"`    // hex digest of the binary
+	Signature string `json:"signature" binding:"required"` // base64 Ed25519 signature of the digest followed by the version
+	Version   string `json:"version" binding:"required"`   // must be newer than the agent's version
 }
 
 // DeviceListResponse represents the list of connected devices
@@ -51,13 +69,41 @@ type DeviceListResponse struct {
 	Devices []string `json:"devices"`
 }
 
+// ConnectedDeviceResponse describes a connected device in the detailed list
+type ConnectedDeviceResponse struct {
+	DeviceID    string    `json:"device_id"`
+	Address     string    `json:"address"`
+	Addresses   []string  `json:"addresses,omitempty"`
+	Tunnel      bool      `json:"tunnel"`
+	IsHealthy   bool      `json:"is_healthy"`
+	LastPing    time.Time `json:"last_ping"`
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// Ping round-trip times in milliseconds, omitted before the first ping
+	LastLatencyMs    float64 `json:"last_latency_ms,omitempty"`
+	AverageLatencyMs float64 `json:"average_latency_ms,omitempty"`
+}
+
+// DetailedDeviceListResponse represents a page of connected devices with their status
+type DetailedDeviceListResponse struct {
+	Devices []ConnectedDeviceResponse `json:"devices"`
+	Total   int                       `json:"total"`
+	Page    int                       `json:"page"`
+	Limit   int                       `json:"limit"`
+}
+
 // DeviceStatusResponse represents device status information
 type DeviceStatusResponse struct {
 	DeviceID    string    `json:"device_id"`
 	Address     string    `json:"address"`
+	Addresses   []string  `json:"addresses,omitempty"`
 	IsHealthy   bool      `json:"is_healthy"`
 	LastPing    time.Time `json:"last_ping"`
 	ConnectedAt time.Time `json:"connected_at"`
+
+	// Ping round-trip times in milliseconds, omitted before the first ping
+	LastLatencyMs    float64 `json:"last_latency_ms,omitempty"`
+	AverageLatencyMs float64 `json:"average_latency_ms,omitempty"` // over the last 10 pings
 }
 
 // CommandInfo represents command information from device
@@ -75,14 +121,20 @@ type CommandListResponse struct {
 
 // ExecuteCommand executes a command on a remote device
 // @Summary Execute command on device
-// @Description Execute a command on a remote device through gRPC
+// @Description Execute a command on a remote device through gRPC. With an Idempotency-Key header the response is recorded for the configured TTL and replayed, status and body, when the key is sent again, so a retry does not run the command twice. Keys are scoped to the user and device and expire after the TTL. With queue_if_offline set, a command for a device that is not connected is queued and answered with 202; it runs when the device reconnects unless it expires or is cancelled first, or the user may no longer execute it by then
 // @Tags Gateway
 // @Accept json
 // @Produce json
 // @Param request body ExecuteCommandRequest true "Command execution request"
+// @Param Idempotency-Key header string false "Client-chosen key making the request safe to retry"
 // @Success 200 {object} ExecuteCommandResponse
+// @Success 202 {object} model.PendingExecution
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/gateway/execute [post]
 func (h *GatewayHandler) ExecuteCommand(c *gin.Context) {
@@ -92,13 +144,56 @@ func (h *GatewayHandler) ExecuteCommand(c *gin.Context) {
 		return
 	}
 
+	if !middleware.CanAccessDevice(c, req.DeviceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed for this device"})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The agent trusts the cloud without a PIN, so the caller must be a member
+	// of the device, and admin-only commands need an admin or owner of it
+	requiredRole, err := h.deviceService.CommandExecuteRole(req.DeviceID, req.CommandID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(userID, req.DeviceID, requiredRole)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !hasPermission {
+		h.deviceService.RecordAccessDenied(userID, req.DeviceID, requiredRole, "execute_command")
+		if requiredRole == "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only device admins may execute admin-only commands"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "User does not have permission to execute commands on this device"})
+		return
+	}
+	callerRole, err := h.deviceService.UserDeviceRole(userID, req.DeviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Default timeout if not specified
 	if req.Timeout == 0 {
 		req.Timeout = 30 // 30 seconds default
 	}
 
+	if req.QueueIfOffline && !h.gatewayService.IsDeviceConnected(req.DeviceID) {
+		h.queueExecution(c, userID, req)
+		return
+	}
+
 	// Execute command through gateway service
-	resp, err := h.gatewayService.ExecuteCommand(req.DeviceID, req.CommandID, req.Timeout)
+	resp, err := h.gatewayService.ExecuteCommand(c.Request.Context(), req.DeviceID, req.CommandID, callerRole, req.Timeout)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -124,6 +219,8 @@ func (h *GatewayHandler) ExecuteCommand(c *gin.Context) {
 // @Param device_id query string true "Device ID"
 // @Success 200 {object} CommandListResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/gateway/commands [get]
@@ -133,8 +230,29 @@ func (h *GatewayHandler) ListCommands(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
 		return
 	}
+	if !middleware.CanAccessDevice(c, deviceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not allowed for this device"})
+		return
+	}
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-	resp, err := h.gatewayService.ListCommands(deviceID)
+	// The device lists admin-only commands only for its admins and owners
+	callerRole, err := h.deviceService.UserDeviceRole(userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if callerRole == "" {
+		h.deviceService.RecordAccessDenied(userID, deviceID, "viewer", "list_commands")
+		c.JSON(http.StatusForbidden, gin.H{"error": "User does not have permission to access this device"})
+		return
+	}
+
+	resp, err := h.gatewayService.ListCommands(c.Request.Context(), deviceID, callerRole)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -177,7 +295,7 @@ func (h *GatewayHandler) ReloadConfig(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.gatewayService.ReloadConfig(deviceID)
+	resp, err := h.gatewayService.ReloadConfig(c.Request.Context(), deviceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -190,6 +308,70 @@ func (h *GatewayHandler) ReloadConfig(c *gin.Context) {
 	})
 }
 
+// UpdateAgent asks a device to update its agent
+// @Summary Update device agent
+// @Description Make a device download a signed agent release, verify it and restart into it (system admins who also administer the device).
+// @Description The device must enable update.enabled; progress is reported by the health check under services.agent_update
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param request body UpdateAgentRequest true "Release to install"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/update [post]
+func (h *GatewayHandler) UpdateAgent(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+		return
+	}
+
+	// Replacing the binary runs new code with the agent's privileges, so a
+	// device admin alone, who may just be a member invited by the owner, is
+	// not enough
+	if !requireAdmin(c, h.userService) {
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+
+	var req UpdateAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "admin")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !hasPermission {
+		h.deviceService.RecordAccessDenied(userID, deviceID, "admin", "update_agent")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	resp, err := h.gatewayService.UpdateAgent(c.Request.Context(), deviceID, &controllerPb.UpdateAgentRequest{
+		Url:       req.URL,
+		Sha256:    req.SHA256,
+		Signature: req.Signature,
+		Version:   req.Version,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"accepted": resp.Accepted,
+		"message":  resp.Message,
+	})
+}
+
 // HealthCheck performs health check on a device
 // @Summary Device health check
 // @Description Check if a device is healthy and responding
@@ -224,6 +406,9 @@ func (h *GatewayHandler) HealthCheck(c *gin.Context) {
 		return
 	}
 
+	// Update device status in database
+	_ = h.deviceService.UpdateDeviceLastSeen(deviceID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":         resp.Status,
 		"version":        resp.Version,
@@ -249,16 +434,31 @@ func (h *GatewayHandler) ConnectDevice(c *gin.Context) {
 		return
 	}
 
-	err := h.gatewayService.AddDevice(req.DeviceID, req.Address)
+	addresses := req.Addresses
+	if req.Address != "" {
+		addresses = append([]string{req.Address}, addresses...)
+	}
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address or addresses is required"})
+		return
+	}
+
+	err := h.gatewayService.AddDevice(req.DeviceID, addresses)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	address := addresses[0]
+	if conn, err := h.gatewayService.GetDeviceStatus(req.DeviceID); err == nil {
+		address = conn.Address
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Device connected successfully",
 		"device_id": req.DeviceID,
-		"address":   req.Address,
+		"address":   address,
+		"addresses": addresses,
 	})
 }
 
@@ -294,13 +494,25 @@ func (h *GatewayHandler) DisconnectDevice(c *gin.Context) {
 
 // ListConnectedDevices lists all connected devices
 // @Summary List connected devices
-// @Description Get list of all devices currently connected to the gateway
+// @Description Get list of all healthy devices currently connected to the gateway. With detailed=true, returns a page of connections, healthy or not, with their status and latency, saving a status call per device
 // @Tags Gateway
 // @Accept json
 // @Produce json
+// @Param detailed query bool false "Return status details with pagination"
+// @Param healthy query bool false "Only healthy connections (detailed only)"
+// @Param address_prefix query string false "Filter by address prefix (detailed only)"
+// @Param page query int false "Page number (detailed only)" default(1)
+// @Param limit query int false "Page size, max 100 (detailed only)" default(20)
 // @Success 200 {object} DeviceListResponse
+// @Success 200 {object} DetailedDeviceListResponse
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/gateway/devices [get]
 func (h *GatewayHandler) ListConnectedDevices(c *gin.Context) {
+	if detailed, _ := strconv.ParseBool(c.Query("detailed")); detailed {
+		h.listConnectedDevicesDetailed(c)
+		return
+	}
+
 	devices := h.gatewayService.ListConnectedDevices()
 
 	response := DeviceListResponse{
@@ -310,6 +522,63 @@ func (h *GatewayHandler) ListConnectedDevices(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// listConnectedDevicesDetailed answers ListConnectedDevices with detailed=true
+func (h *GatewayHandler) listConnectedDevicesDetailed(c *gin.Context) {
+	page := 1
+	limit := 20
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	filter := service.ConnectedDeviceFilter{
+		AddressPrefix: c.Query("address_prefix"),
+		Offset:        (page - 1) * limit,
+		Limit:         limit,
+	}
+	if healthyStr := c.Query("healthy"); healthyStr != "" {
+		healthy, err := strconv.ParseBool(healthyStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid healthy filter: " + healthyStr})
+			return
+		}
+		filter.HealthyOnly = healthy
+	}
+
+	devices, total := h.gatewayService.ListConnectedDevicesDetailed(filter)
+
+	response := DetailedDeviceListResponse{
+		Devices: make([]ConnectedDeviceResponse, len(devices)),
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+	}
+	for i, device := range devices {
+		response.Devices[i] = ConnectedDeviceResponse{
+			DeviceID:    device.DeviceID,
+			Address:     device.Address,
+			Addresses:   device.Addresses,
+			Tunnel:      device.Tunnel,
+			IsHealthy:   device.IsHealthy,
+			LastPing:    device.LastPing,
+			ConnectedAt: device.ConnectedAt,
+
+			LastLatencyMs:    durationMs(device.LastLatency),
+			AverageLatencyMs: durationMs(device.AverageLatency),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetDeviceStatus gets the status of a specific device
 // @Summary Get device status
 // @Description Get detailed status information for a connected device
@@ -334,13 +603,75 @@ func (h *GatewayHandler) GetDeviceStatus(c *gin.Context) {
 		return
 	}
 
+	lastLatency, averageLatency := conn.Latency()
 	response := DeviceStatusResponse{
 		DeviceID:    conn.DeviceID,
 		Address:     conn.Address,
+		Addresses:   conn.Addresses,
 		IsHealthy:   conn.IsHealthy,
 		LastPing:    conn.LastPing,
 		ConnectedAt: conn.ConnectedAt,
+
+		LastLatencyMs:    durationMs(lastLatency),
+		AverageLatencyMs: durationMs(averageLatency),
 	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// GetAccessHistory lists changes to users' access to a device
+// @Summary Get device access history
+// @Description List grants, role changes, revocations, access requests and rejections of user access to a device (device owners/admins only)
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param limit query int false "Maximum number of entries" default(100)
+// @Success 200 {array} model.UserDeviceHistory
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/access-history [get]
+func (h *GatewayHandler) GetAccessHistory(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "admin")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !hasPermission {
+		h.deviceService.RecordAccessDenied(userID, deviceID, "admin", "get_access_history")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	history, err := h.deviceService.GetDeviceAccessHistory(deviceID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// newUpdateAgentRouter serves UpdateAgent for device "dev-1", which member
+// and admin administer, as the user named by the X-Test-User header. The
+// device is not connected, so a request passing every check fails with 500
+func newUpdateAgentRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	for _, user := range []*model.User{
+		{ID: "member", Username: "member", Email: "member@example.com", Password: "password123", Role: "user"},
+		{ID: "admin", Username: "admin", Email: "admin@example.com", Password: "password123", Role: "admin"},
+		{ID: "outsider", Username: "outsider", Email: "outsider@example.com", Password: "password123", Role: "admin"},
+	} {
+		if err := userRepo.Create(user); err != nil {
+			t.Fatalf("create user %s: %v", user.ID, err)
+		}
+	}
+	if err := deviceRepo.Create(&model.Device{ID: "dev-1", DeviceName: "dev-1", DeviceType: "desktop", Platform: "linux"}); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	for _, userID := range []string{"member", "admin"} {
+		if err := deviceRepo.CreateUserDevice(&model.UserDevice{UserID: userID, DeviceID: "dev-1", Role: "admin", Status: "active"}); err != nil {
+			t.Fatalf("add %s to device: %v", userID, err)
+		}
+	}
+
+	jwtConfig := config.JWTConfig{SecretKey: "test-secret-key"}
+	userService, err := service.NewUserService(userRepo, nil, jwtConfig, config.TwoFactorConfig{}, config.WebAuthnConfig{})
+	if err != nil {
+		t.Fatalf("create user service: %v", err)
+	}
+	deviceService := service.NewDeviceService(deviceRepo)
+	gatewayService, err := service.NewGatewayService(config.GatewayConfig{AllowInsecure: true}, deviceService)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	handler := NewGatewayHandler(gatewayService, deviceService, userService, nil)
+
+	router := gin.New()
+	router.POST("/devices/:device_id/update", func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+	}, handler.UpdateAgent)
+	return router
+}
+
+func TestUpdateAgentRequiresSystemAndDeviceAdmin(t *testing.T) {
+	router := newUpdateAgentRouter(t)
+	const body = `{"url":"https://releases.example.com/agent","sha256":"00","signature":"AA==","version":"9.0.0"}`
+
+	tests := []struct {
+		name string
+		user string
+		want int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"device admin without the admin role", "member", http.StatusForbidden},
+		{"admin who does not administer the device", "outsider", http.StatusForbidden},
+		{"admin administering the device", "admin", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/devices/dev-1/update", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.user != "" {
+				req.Header.Set("X-Test-User", tt.user)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetAccessHistory(t *testing.T) {
+	services := newTestServices(t)
+	if err := services.deviceRepo.Create(&model.Device{ID: "dev-1", DeviceName: "dev-1", DeviceType: "desktop", Platform: "linux"}); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	for userID, role := range map[string]string{"owner": "owner", "admin": "admin", "member": "user"} {
+		if err := services.deviceRepo.CreateUserDevice(&model.UserDevice{UserID: userID, DeviceID: "dev-1", Role: role, Status: "active"}); err != nil {
+			t.Fatalf("bind %s: %v", userID, err)
+		}
+	}
+	for _, role := range []string{"viewer", "user"} {
+		if _, err := services.deviceService.BindDeviceToUser("owner", "guest-"+role, "dev-1", role); err != nil {
+			t.Fatalf("bind guest-%s: %v", role, err)
+		}
+	}
+
+	handler := NewGatewayHandler(nil, services.deviceService, services.userService, nil)
+	router := gin.New()
+	router.GET("/devices/:device_id/access-history", asTestUser, handler.GetAccessHistory)
+
+	tests := []struct {
+		name    string
+		user    string
+		query   string
+		status  int
+		entries int
+	}{
+		{"anonymous", "", "", http.StatusUnauthorized, 0},
+		{"device user", "member", "", http.StatusForbidden, 0},
+		{"unbound user", "stranger", "", http.StatusForbidden, 0},
+		{"device owner", "owner", "", http.StatusOK, 2},
+		{"device admin", "admin", "", http.StatusOK, 2},
+		{"limit", "owner", "?limit=1", http.StatusOK, 1},
+		{"invalid limit ignored", "owner", "?limit=0", http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(router, http.MethodGet, "/devices/dev-1/access-history"+tt.query, tt.user)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var history []model.UserDeviceHistory
+			if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+				t.Fatalf("decode history: %v", err)
+			}
+			if len(history) != tt.entries {
+				t.Fatalf("got %d entries, want %d", len(history), tt.entries)
+			}
+			if history[0].UserID != "guest-user" || history[0].Action != model.AccessActionGranted || history[0].ActorID != "owner" {
+				t.Errorf("newest entry = %+v, want owner granting guest-user", history[0])
+			}
+		})
+	}
+}
+
+func TestExecuteCommandRequiresDeviceMembership(t *testing.T) {
+	services := newTestServices(t)
+	if err := services.deviceRepo.Create(&model.Device{ID: "dev-1", DeviceName: "dev-1", DeviceType: "desktop", Platform: "linux"}); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	for userID, role := range map[string]string{"device-admin": "admin", "device-user": "user", "device-viewer": "viewer"} {
+		if err := services.deviceRepo.CreateUserDevice(&model.UserDevice{UserID: userID, DeviceID: "dev-1", Role: role, Status: "active"}); err != nil {
+			t.Fatalf("bind %s: %v", userID, err)
+		}
+	}
+	if err := services.deviceService.CreateDeviceCommand(&model.DeviceCommand{DeviceID: "dev-1", CommandID: "reboot", Name: "Reboot", Command: "reboot", AdminOnly: true}); err != nil {
+		t.Fatalf("create command: %v", err)
+	}
+	gatewayService, err := service.NewGatewayService(config.GatewayConfig{AllowInsecure: true}, services.deviceService)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	handler := NewGatewayHandler(gatewayService, services.deviceService, services.userService, nil)
+	router := gin.New()
+	router.POST("/execute", asTestUser, handler.ExecuteCommand)
+
+	// dev-1 is not connected, so a request passing every check fails with
+	// 500, or is queued with 202 when it asks to be
+	tests := []struct {
+		name    string
+		user    string
+		command string
+		queue   bool
+		want    int
+	}{
+		{"anonymous", "", "uptime", false, http.StatusUnauthorized},
+		{"non-member", "member", "uptime", false, http.StatusForbidden},
+		{"non-member queueing", "member", "uptime", true, http.StatusForbidden},
+		{"device viewer", "device-viewer", "uptime", false, http.StatusForbidden},
+		{"device user", "device-user", "uptime", false, http.StatusInternalServerError},
+		{"device user queueing", "device-user", "uptime", true, http.StatusAccepted},
+		{"device user, admin-only command", "device-user", "reboot", false, http.StatusForbidden},
+		{"device admin, admin-only command", "device-admin", "reboot", false, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"device_id":"dev-1","command_id":"` + tt.command + `","queue_if_offline":` + strconv.FormatBool(tt.queue) + `}`
+			req := httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.user != "" {
+				req.Header.Set("X-Test-User", tt.user)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	denials, total, err := services.deviceService.ListAccessLogs(repository.AccessLogFilter{DeviceID: "dev-1"}, 0, 10)
+	if err != nil {
+		t.Fatalf("list access logs: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("recorded %d denials, want 4: %+v", total, denials)
+	}
+}
@@ -0,0 +1,352 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// GroupHandler handles HTTP requests for device groups
+type GroupHandler struct {
+	gatewayService *service.GatewayService
+	deviceService  *service.DeviceService
+}
+
+// NewGroupHandler creates a new device group handler
+func NewGroupHandler(gatewayService *service.GatewayService, deviceService *service.DeviceService) *GroupHandler {
+	return &GroupHandler{
+		gatewayService: gatewayService,
+		deviceService:  deviceService,
+	}
+}
+
+// CreateGroupRequest represents the request body for creating a device group
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// GroupDeviceRequest represents the request body for adding a device to a group
+type GroupDeviceRequest struct {
+	DeviceID string `json:"device_id" binding:"required"`
+}
+
+// GroupExecuteRequest represents the request body for executing a command on a group
+type GroupExecuteRequest struct {
+	CommandID string `json:"command_id" binding:"required"`
+	Timeout   int32  `json:"timeout,omitempty"` // optional timeout in seconds
+}
+
+// GroupDeviceResult represents the execution result on a single device
+type GroupDeviceResult struct {
+	DeviceID        string `json:"device_id"`
+	Success         bool   `json:"success"`
+	Output          string `json:"output,omitempty"`
+	Error           string `json:"error,omitempty"`
+	ExitCode        int32  `json:"exit_code"`
+	ExecutionTimeMs int64  `json:"execution_time_ms"`
+}
+
+// GroupExecuteResponse represents the aggregated result of a group execution
+type GroupExecuteResponse struct {
+	GroupID   string              `json:"group_id"`
+	CommandID string              `json:"command_id"`
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	TimedOut  bool                `json:"timed_out"` // batch timeout hit before every device finished
+	Results   []GroupDeviceResult `json:"results"`
+}
+
+// CreateGroup creates a device group owned by the current user
+// @Summary Create device group
+// @Description Create a named group of devices
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param request body CreateGroupRequest true "Group definition"
+// @Success 201 {object} model.DeviceGroup
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/groups [post]
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.deviceService.CreateDeviceGroup(userID, req.Name, req.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// ListGroups lists the current user's device groups
+// @Summary List device groups
+// @Description List device groups owned by the current user
+// @Tags Gateway
+// @Produce json
+// @Success 200 {array} model.DeviceGroup
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/groups [get]
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	groups, err := h.deviceService.GetUserDeviceGroups(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// DeleteGroup deletes a device group
+// @Summary Delete device group
+// @Description Delete a device group; member devices are not affected
+// @Tags Gateway
+// @Produce json
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/groups/{group_id} [delete]
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	group, ok := h.ownedGroup(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deviceService.DeleteDeviceGroup(group.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Group deleted successfully",
+		"group_id": group.ID,
+	})
+}
+
+// AddDevice adds a device to a group
+// @Summary Add device to group
+// @Description Add a device the current user can access to a group
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param group_id path string true "Group ID"
+// @Param request body GroupDeviceRequest true "Device to add"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/gateway/groups/{group_id}/devices [post]
+func (h *GroupHandler) AddDevice(c *gin.Context) {
+	group, ok := h.ownedGroup(c)
+	if !ok {
+		return
+	}
+
+	var req GroupDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasPermission, err := h.deviceService.CheckUserDevicePermission(group.OwnerID, req.DeviceID, "viewer")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !hasPermission {
+		h.deviceService.RecordAccessDenied(group.OwnerID, req.DeviceID, "viewer", "add_group_device")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to device " + req.DeviceID})
+		return
+	}
+
+	if err := h.deviceService.AddDeviceToGroup(group.ID, req.DeviceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Device added to group",
+		"group_id":  group.ID,
+		"device_id": req.DeviceID,
+	})
+}
+
+// RemoveDevice removes a device from a group
+// @Summary Remove device from group
+// @Description Remove a device from a group
+// @Tags Gateway
+// @Produce json
+// @Param group_id path string true "Group ID"
+// @Param device_id path string true "Device ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/groups/{group_id}/devices/{device_id} [delete]
+func (h *GroupHandler) RemoveDevice(c *gin.Context) {
+	group, ok := h.ownedGroup(c)
+	if !ok {
+		return
+	}
+
+	deviceID := c.Param("device_id")
+	if err := h.deviceService.RemoveDeviceFromGroup(group.ID, deviceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Device removed from group",
+		"group_id":  group.ID,
+		"device_id": deviceID,
+	})
+}
+
+// ExecuteCommand executes a command on every online device in a group
+// @Summary Execute command on device group
+// @Description Execute a command concurrently on all online devices in a group the user may execute on. Per-device failures are reported individually.
+// @Tags Gateway
+// @Accept json
+// @Produce json
+// @Param group_id path string true "Group ID"
+// @Param request body GroupExecuteRequest true "Command execution request"
+// @Success 200 {object} GroupExecuteResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/groups/{group_id}/execute [post]
+func (h *GroupHandler) ExecuteCommand(c *gin.Context) {
+	group, ok := h.ownedGroup(c)
+	if !ok {
+		return
+	}
+
+	var req GroupExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Default timeout if not specified
+	if req.Timeout == 0 {
+		req.Timeout = 30 // 30 seconds default
+	}
+
+	devices, err := h.deviceService.GetGroupDevices(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := GroupExecuteResponse{
+		GroupID:   group.ID,
+		CommandID: req.CommandID,
+		Total:     len(devices),
+		Results:   make([]GroupDeviceResult, 0, len(devices)),
+	}
+
+	// Skip devices the user may not execute on or that are offline
+	var targets []service.ExecutionTarget
+	for _, device := range devices {
+		requiredRole, err := h.deviceService.CommandExecuteRole(device.ID, req.CommandID)
+		hasPermission := false
+		if err == nil {
+			hasPermission, err = h.deviceService.CheckUserDevicePermission(group.OwnerID, device.ID, requiredRole)
+		}
+		switch {
+		case err != nil:
+			response.Results = append(response.Results, GroupDeviceResult{DeviceID: device.ID, Error: err.Error(), ExitCode: -1})
+		case !hasPermission:
+			h.deviceService.RecordAccessDenied(group.OwnerID, device.ID, requiredRole, "execute_group_command")
+			response.Results = append(response.Results, GroupDeviceResult{DeviceID: device.ID, Error: "permission denied", ExitCode: -1})
+		case !middleware.CanAccessDevice(c, device.ID):
+			response.Results = append(response.Results, GroupDeviceResult{DeviceID: device.ID, Error: "permission denied", ExitCode: -1})
+		case !device.Online:
+			response.Results = append(response.Results, GroupDeviceResult{DeviceID: device.ID, Error: "device offline", ExitCode: -1})
+		default:
+			callerRole, err := h.deviceService.UserDeviceRole(group.OwnerID, device.ID)
+			if err != nil {
+				response.Results = append(response.Results, GroupDeviceResult{DeviceID: device.ID, Error: err.Error(), ExitCode: -1})
+				continue
+			}
+			targets = append(targets, service.ExecutionTarget{DeviceID: device.ID, CallerRole: callerRole})
+		}
+	}
+
+	for _, result := range h.gatewayService.ExecuteCommandOnDevices(targets, req.CommandID, req.Timeout) {
+		if result.Err != nil {
+			if errors.Is(result.Err, service.ErrBatchTimeout) {
+				response.TimedOut = true
+			}
+			response.Results = append(response.Results, GroupDeviceResult{DeviceID: result.DeviceID, Error: result.Err.Error(), ExitCode: -1})
+			continue
+		}
+		response.Results = append(response.Results, GroupDeviceResult{
+			DeviceID:        result.DeviceID,
+			Success:         result.Response.Success,
+			Output:          result.Response.Output,
+			Error:           result.Response.Error,
+			ExitCode:        result.Response.ExitCode,
+			ExecutionTimeMs: result.Response.ExecutionTimeMs,
+		})
+	}
+
+	for _, result := range response.Results {
+		if result.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ownedGroup loads the group from the path and checks the current user owns it,
+// writing the error response and returning false otherwise
+func (h *GroupHandler) ownedGroup(c *gin.Context) (*model.DeviceGroup, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	group, err := h.deviceService.GetDeviceGroup(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// Groups owned by other users are reported as missing
+	if group == nil || group.OwnerID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return nil, false
+	}
+
+	return group, true
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+)
+
+const (
+	// oauthStateCookie binds a login attempt to the browser that started it
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// oauthState is the signed state parameter round-tripped through the provider
+type oauthState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// SetOAuthProviders enables external login through the given providers.
+// stateKey signs the state parameter of login attempts
+func (h *UserHandler) SetOAuthProviders(providers map[string]*auth.OAuthProvider, stateKey []byte) {
+	h.oauthProviders = providers
+	h.oauthStateKey = stateKey
+}
+
+// OAuthLogin redirects to a provider's login page
+func (h *UserHandler) OAuthLogin(c *gin.Context) {
+	provider, ok := h.oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Message: "Unknown login provider",
+		})
+		return
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: "Failed to start login",
+		})
+		return
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &oauthState{
+		Provider: provider.Name,
+		Nonce:    nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oauthStateTTL)),
+		},
+	}).SignedString(h.oauthStateKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: "Failed to start login",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, err := provider.AuthCodeURL(ctx, state)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, nonce, int(oauthStateTTL.Seconds()), "/api/v1/auth/oauth", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback completes a provider login and issues the normal token pair
+func (h *UserHandler) OAuthCallback(c *gin.Context) {
+	provider, ok := h.oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Message: "Unknown login provider",
+		})
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "Login rejected by provider: " + providerErr,
+		})
+		return
+	}
+
+	nonce, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, "/api/v1/auth/oauth", "", c.Request.TLS != nil, true)
+	if err := h.validateOAuthState(c.Query("state"), provider.Name, nonce); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid login state: " + err.Error(),
+		})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Authorization code is required",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	info, err := provider.Exchange(ctx, code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.userService.OAuthLogin(provider.Name, info, sessionClient(c, ""))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// validateOAuthState checks that state was issued by this server for the
+// provider and the browser holding nonce
+func (h *UserHandler) validateOAuthState(state, provider, nonce string) error {
+	claims := &oauthState{}
+	token, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid state signing method")
+		}
+		return h.oauthStateKey, nil
+	})
+	if err != nil || !token.Valid {
+		return errors.New("state is invalid or expired")
+	}
+
+	if claims.Provider != provider {
+		return errors.New("state was issued for another provider")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return errors.New("state does not belong to this browser")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// ListPendingExecutions lists the commands queued for a device
+// @Summary List queued executions
+// @Description List the commands queued with queue_if_offline that still wait for the device to reconnect, oldest first. Executions past the queue TTL are expired and left out
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Success 200 {array} model.PendingExecution
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/pending [get]
+func (h *GatewayHandler) ListPendingExecutions(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	if _, ok := h.requireDeviceViewer(c, deviceID, "list_pending_executions"); !ok {
+		return
+	}
+
+	executions, err := h.deviceService.ListPendingExecutions(deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, executions)
+}
+
+// CancelPendingExecution cancels a queued command before it is delivered
+// @Summary Cancel a queued execution
+// @Description Cancel a command queued for a device before the device reconnects. Users may cancel their own executions; device admins may cancel any
+// @Tags Gateway
+// @Produce json
+// @Param device_id path string true "Device ID"
+// @Param execution_id path string true "Pending execution ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/gateway/devices/{device_id}/pending/{execution_id} [delete]
+func (h *GatewayHandler) CancelPendingExecution(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	userID, ok := h.requireDeviceViewer(c, deviceID, "cancel_pending_execution")
+	if !ok {
+		return
+	}
+
+	execution, err := h.deviceService.GetPendingExecution(deviceID, c.Param("execution_id"))
+	switch {
+	case errors.Is(err, service.ErrPendingExecutionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Cancelling someone else's execution takes a device admin
+	if execution.UserID != userID {
+		isAdmin, err := h.deviceService.CheckUserDevicePermission(userID, deviceID, "admin")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !isAdmin {
+			h.deviceService.RecordAccessDenied(userID, deviceID, "admin", "cancel_pending_execution")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	err = h.deviceService.CancelPendingExecution(deviceID, execution.ID)
+	switch {
+	case errors.Is(err, service.ErrExecutionNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Execution cancelled"})
+}
+
+// queueExecution queues a command for a device that is not connected on
+// behalf of userID, whom ExecuteCommand already checked may execute it now
+func (h *GatewayHandler) queueExecution(c *gin.Context, userID string, req ExecuteCommandRequest) {
+	execution, err := h.gatewayService.QueueExecution(userID, req.DeviceID, req.CommandID, req.Timeout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, execution)
+}
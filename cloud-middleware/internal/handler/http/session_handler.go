@@ -0,0 +1,104 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// SessionResponse describes one of the user's logins
+type SessionResponse struct {
+	ID         string `json:"id"`
+	Device     string `json:"device,omitempty"`
+	UserAgent  string `json:"user_agent"`
+	IPAddress  string `json:"ip_address"`
+	Current    bool   `json:"current"` // the session of this request
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// ListSessions lists the current user's active sessions
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	sessions, err := h.userService.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentID, _ := middleware.GetSessionID(c)
+	responses := make([]*SessionResponse, len(sessions))
+	for i := range sessions {
+		responses[i] = toSessionResponse(&sessions[i], currentID)
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Sessions retrieved successfully",
+		Data:    responses,
+	})
+}
+
+// RevokeSession signs the current user out of one of their sessions
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	if err := h.userService.RevokeSession(userID, c.Param("session_id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+// toSessionResponse converts a stored session to its response
+func toSessionResponse(session *model.Session, currentID string) *SessionResponse {
+	response := &SessionResponse{
+		ID:        session.ID,
+		Device:    session.Device,
+		UserAgent: session.UserAgent,
+		IPAddress: session.IPAddress,
+		Current:   session.ID == currentID,
+		CreatedAt: session.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+	}
+	if session.LastUsedAt != nil {
+		response.LastUsedAt = session.LastUsedAt.Format(time.RFC3339)
+	}
+	return response
+}
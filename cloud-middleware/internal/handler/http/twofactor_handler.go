@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+)
+
+// TwoFactorChallengeResponse is returned by login instead of tokens when
+// the user has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	Success        bool   `json:"success"`
+	Message        string `json:"message"`
+	Requires2FA    bool   `json:"requires2fa"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresAt      string `json:"expires_at"`
+}
+
+// TwoFactorLoginRequest completes a login challenge
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+// TwoFactorVerifyRequest confirms two-factor enrollment
+type TwoFactorVerifyRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollResponse represents a pending TOTP enrollment
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	QRCode     string `json:"qr_code"` // PNG data URI of the otpauth URL
+}
+
+// TwoFactorVerifyResponse carries the recovery codes, shown only once
+type TwoFactorVerifyResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and code for tokens
+func (h *UserHandler) CompleteTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.userService.CompleteTwoFactorLogin(req.ChallengeToken, req.Code, sessionClient(c, ""))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// EnrollTwoFactor starts TOTP enrollment for the current user
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	enrollment, err := h.userService.EnrollTwoFactor(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	png, err := qrcode.Encode(enrollment.URL, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: "Failed to generate QR code",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Scan the QR code and verify a code to enable two-factor authentication",
+		Data: TwoFactorEnrollResponse{
+			Secret:     enrollment.Secret,
+			OTPAuthURL: enrollment.URL,
+			QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a code from the enrolled app
+func (h *UserHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	codes, err := h.userService.ConfirmTwoFactor(userID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled",
+		Data:    TwoFactorVerifyResponse{RecoveryCodes: codes},
+	})
+}
@@ -1,10 +1,12 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
@@ -13,6 +15,10 @@ import (
 // UserHandler handles HTTP requests for user operations
 type UserHandler struct {
 	userService service.UserService
+
+	// External login, see SetOAuthProviders
+	oauthProviders map[string]*auth.OAuthProvider
+	oauthStateKey  []byte
 }
 
 // NewUserHandler creates a new user handler
@@ -24,8 +30,9 @@ func NewUserHandler(userService service.UserService) *UserHandler {
 
 // LoginRequest represents login request
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
 }
 
 // LoginResponse represents login response
@@ -130,7 +137,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	result, err := h.userService.Login(req.Username, req.Password)
+	result, err := h.userService.Login(req.Username, req.Password, sessionClient(c, req.DeviceName))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, StandardResponse{
 			Success: false,
@@ -139,6 +146,32 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	h.respondLogin(c, result)
+}
+
+// sessionClient describes the client of a login request for its session
+func sessionClient(c *gin.Context, device string) service.SessionClient {
+	return service.SessionClient{
+		Device:    device,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// respondLogin replies with the token pair, or with the second-factor
+// challenge when the user has two-factor authentication enabled
+func (h *UserHandler) respondLogin(c *gin.Context, result *service.LoginResult) {
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			Success:        true,
+			Message:        "Two-factor authentication required",
+			Requires2FA:    true,
+			ChallengeToken: result.Challenge.Token,
+			ExpiresAt:      result.Challenge.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, LoginResponse{
 		Success:      true,
 		Message:      "Login successful",
@@ -161,6 +194,13 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 	}
 
 	tokens, err := h.userService.RefreshToken(req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "Refresh token was already used; the session has been revoked, please log in again",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, StandardResponse{
 			Success: false,
@@ -522,6 +562,12 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 // checkAdminPermission checks if current user is admin
 func (h *UserHandler) checkAdminPermission(c *gin.Context) bool {
+	return requireAdmin(c, h.userService)
+}
+
+// requireAdmin checks that the current user is an admin and writes the
+// error response otherwise
+func requireAdmin(c *gin.Context, userService service.UserService) bool {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, StandardResponse{
@@ -531,7 +577,7 @@ func (h *UserHandler) checkAdminPermission(c *gin.Context) bool {
 		return false
 	}
 
-	isAdmin, err := h.userService.IsAdmin(userID)
+	isAdmin, err := userService.IsAdmin(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, StandardResponse{
 			Success: false,
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// userImportColumns are the CSV columns of an import, matched by header name
+var userImportColumns = []string{"username", "email", "role", "password"}
+
+// userExportColumns are the CSV columns of an export, in order
+var userExportColumns = []string{"id", "username", "email", "phone", "nickname", "role", "status", "created_at", "updated_at"}
+
+// ImportUsers creates users in bulk from a JSON array or, with Content-Type
+// text/csv, a CSV file with a header row (admin only). Users without a
+// password get a temporary one, returned once in the report
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	if !h.checkAdminPermission(c) {
+		return
+	}
+
+	var rows []service.ImportUserRow
+	var err error
+	if c.ContentType() == "text/csv" {
+		rows, err = parseUserCSV(c.Request.Body)
+	} else {
+		err = c.ShouldBindJSON(&rows)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+	if len(rows) == 0 || len(rows) > service.MaxUserImportRows {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: fmt.Sprintf("An import must contain between 1 and %d users", service.MaxUserImportRows),
+		})
+		return
+	}
+
+	result, err := h.userService.ImportUsers(rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: fmt.Sprintf("Imported %d users, skipped %d, %d errors", len(result.Created), len(result.Skipped), len(result.Errors)),
+		Data:    result,
+	})
+}
+
+// ExportUsers returns every user, without password hashes, as JSON or with
+// format=csv as a CSV download (admin only)
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	if !h.checkAdminPermission(c) {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "format must be json or csv",
+		})
+		return
+	}
+
+	users, err := h.userService.ExportUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userResponses := make([]*UserResponse, len(users))
+	for i, user := range users {
+		userResponses[i] = h.toUserResponse(user)
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, StandardResponse{
+			Success: true,
+			Message: "Users exported successfully",
+			Data:    userResponses,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write(userExportColumns)
+	for _, user := range userResponses {
+		w.Write([]string{user.ID, user.Username, user.Email, user.Phone, user.Nickname, user.Role, user.Status, user.CreatedAt, user.UpdatedAt})
+	}
+	w.Flush()
+}
+
+// parseUserCSV reads import rows from CSV with a header naming its columns.
+// Unknown columns are ignored; username and email are required
+func parseUserCSV(r io.Reader) ([]service.ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV header is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets often save CSV with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, column := range userImportColumns {
+			if name == column {
+				index[column] = i
+			}
+		}
+	}
+	for _, column := range []string{"username", "email"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("CSV column %q is required", column)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	var rows []service.ImportUserRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, service.ImportUserRow{
+			Username: field(record, "username"),
+			Email:    field(record, "email"),
+			Role:     field(record, "role"),
+			Password: field(record, "password"),
+		})
+		if len(rows) > service.MaxUserImportRows {
+			return rows, nil
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/middleware"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/service"
+)
+
+// WebAuthnRegisterFinishRequest completes enrolling an authenticator
+type WebAuthnRegisterFinishRequest struct {
+	SessionToken string          `json:"session_token" binding:"required"`
+	Name         string          `json:"name"`                          // label shown in the credential list
+	Credential   json.RawMessage `json:"credential" binding:"required"` // PublicKeyCredential from navigator.credentials.create
+}
+
+// WebAuthnLoginBeginRequest starts a passkey login
+type WebAuthnLoginBeginRequest struct {
+	Username string `json:"username"` // optional; without it the browser offers discoverable passkeys
+}
+
+// WebAuthnLoginFinishRequest completes a passkey login
+type WebAuthnLoginFinishRequest struct {
+	SessionToken string          `json:"session_token" binding:"required"`
+	Credential   json.RawMessage `json:"credential" binding:"required"` // PublicKeyCredential from navigator.credentials.get
+}
+
+// WebAuthnCredentialResponse represents an enrolled authenticator
+type WebAuthnCredentialResponse struct {
+	ID              uint     `json:"id"`
+	Name            string   `json:"name"`
+	CredentialID    string   `json:"credential_id"` // base64url, as the browser reports it
+	AttestationType string   `json:"attestation_type"`
+	Transports      []string `json:"transports"`
+	Synced          bool     `json:"synced"` // passkey backed up to a cloud account
+	LastUsedAt      string   `json:"last_used_at,omitempty"`
+	CreatedAt       string   `json:"created_at"`
+}
+
+// BeginWebAuthnRegistration returns the options for navigator.credentials.create
+func (h *UserHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ceremony, err := h.userService.BeginWebAuthnRegistration(userID)
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err, http.StatusInternalServerError), StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Create a credential with the options and finish the registration",
+		Data:    ceremony,
+	})
+}
+
+// FinishWebAuthnRegistration verifies and stores a new authenticator
+func (h *UserHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	var req WebAuthnRegisterFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	credential, err := h.userService.FinishWebAuthnRegistration(userID, req.SessionToken, req.Name, req.Credential)
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err, http.StatusBadRequest), StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, StandardResponse{
+		Success: true,
+		Message: "Authenticator registered successfully",
+		Data:    toWebAuthnCredentialResponse(credential),
+	})
+}
+
+// ListWebAuthnCredentials lists the current user's authenticators
+func (h *UserHandler) ListWebAuthnCredentials(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	credentials, err := h.userService.ListWebAuthnCredentials(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]*WebAuthnCredentialResponse, len(credentials))
+	for i := range credentials {
+		responses[i] = toWebAuthnCredentialResponse(&credentials[i])
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Authenticators retrieved successfully",
+		Data:    responses,
+	})
+}
+
+// DeleteWebAuthnCredential removes one of the current user's authenticators
+func (h *UserHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, StandardResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("credential_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid credential ID",
+		})
+		return
+	}
+
+	if err := h.userService.DeleteWebAuthnCredential(userID, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Authenticator removed successfully",
+	})
+}
+
+// BeginWebAuthnLogin returns the options for navigator.credentials.get
+func (h *UserHandler) BeginWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnLoginBeginRequest
+	// The body is optional for a discoverable passkey login
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	ceremony, err := h.userService.BeginWebAuthnLogin(req.Username)
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err, http.StatusBadRequest), StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StandardResponse{
+		Success: true,
+		Message: "Sign the challenge with an authenticator and finish the login",
+		Data:    ceremony,
+	})
+}
+
+// FinishWebAuthnLogin verifies a signed challenge and issues the normal token pair
+func (h *UserHandler) FinishWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, StandardResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.userService.FinishWebAuthnLogin(req.SessionToken, req.Credential, sessionClient(c, ""))
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err, http.StatusUnauthorized), StandardResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// webAuthnErrorStatus maps a WebAuthn service error to a status, fallback
+// being used for ceremony failures
+func webAuthnErrorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrWebAuthnDisabled) {
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+// toWebAuthnCredentialResponse converts a stored credential to its response
+func toWebAuthnCredentialResponse(credential *model.WebAuthnCredential) *WebAuthnCredentialResponse {
+	response := &WebAuthnCredentialResponse{
+		ID:              credential.ID,
+		Name:            credential.Name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.CredentialID),
+		AttestationType: credential.AttestationType,
+		Transports:      credential.Transports,
+		Synced:          credential.BackupState,
+		CreatedAt:       credential.CreatedAt.Format(time.RFC3339),
+	}
+	if credential.LastUsedAt != nil {
+		response.LastUsedAt = credential.LastUsedAt.Format(time.RFC3339)
+	}
+	return response
+}
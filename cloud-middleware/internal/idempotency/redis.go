@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseScript deletes a key only while it still holds a reservation, so a
+// late release cannot drop a completed response
+var releaseScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value and cjson.decode(value)['pending'] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore is a Store whose keys live in Redis, so every replica behind a
+// load balancer sees them
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a store keeping its records under prefix
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Begin implements Store
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint string, pendingTTL time.Duration) (*Record, error) {
+	reservation, err := json.Marshal(&Record{Pending: true, Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := s.client.SetNX(ctx, s.prefix+key, reservation, pendingTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired between the two calls; treat it as still held so the client retries
+		return &Record{Pending: true, Fingerprint: fingerprint}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete implements Store
+func (s *RedisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	stored := *record
+	stored.Pending = false
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+// Release implements Store
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return releaseScript.Run(ctx, s.client, []string{s.prefix + key}).Err()
+}
@@ -0,0 +1,113 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops expired keys
+const sweepInterval = time.Minute
+
+// Record is what a store keeps under an idempotency key: a reservation while
+// the first request runs, then the response it produced
+type Record struct {
+	Pending     bool   `json:"pending,omitempty"`      // the first request has not completed yet
+	Fingerprint string `json:"fingerprint"`            // identifies the request the key was first used with
+	Status      int    `json:"status,omitempty"`       // HTTP status of the response
+	ContentType string `json:"content_type,omitempty"` // Content-Type of the response
+	Body        []byte `json:"body,omitempty"`         // response body
+}
+
+// Store keeps idempotency keys with the response recorded for them
+type Store interface {
+	// Begin reserves key for a request with the given fingerprint for up to
+	// pendingTTL. It returns nil when the reservation was made, or the record
+	// already held under the key
+	Begin(ctx context.Context, key, fingerprint string, pendingTTL time.Duration) (*Record, error)
+
+	// Complete replaces the reservation of key with the response record, kept for ttl
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+
+	// Release drops the reservation of a request that did not complete, so it can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// memoryEntry is a record with its expiry
+type memoryEntry struct {
+	record  *Record
+	expires time.Time
+}
+
+// MemoryStore is a Store local to this process, for single-instance deployments
+type MemoryStore struct {
+	mutex     sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:   make(map[string]*memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Begin implements Store
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string, pendingTTL time.Duration) (*Record, error) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	if entry, exists := s.entries[key]; exists && now.Before(entry.expires) {
+		record := *entry.record
+		return &record, nil
+	}
+
+	s.entries[key] = &memoryEntry{
+		record:  &Record{Pending: true, Fingerprint: fingerprint},
+		expires: now.Add(pendingTTL),
+	}
+	return nil, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := *record
+	stored.Pending = false
+	s.entries[key] = &memoryEntry{
+		record:  &stored,
+		expires: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists && entry.record.Pending {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// sweep drops expired keys
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -5,11 +5,24 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 )
 
-// AuthRequired middleware validates JWT tokens
-func AuthRequired() gin.HandlerFunc {
+// APIKeyAuthenticator resolves API keys presented as bearer tokens
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*model.APIKey, *model.User, error)
+}
+
+// AccessTokenValidator verifies the JWT access tokens issued at login and
+// rejects those of revoked sessions
+type AccessTokenValidator interface {
+	ValidateAccessToken(tokenString string) (*auth.Claims, error)
+}
+
+// AuthRequired middleware validates JWT tokens. API keys are accepted only
+// when scopes are given, and must grant all of them
+func AuthRequired(tokens AccessTokenValidator, apiKeys APIKeyAuthenticator, scopes ...string) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -34,18 +47,14 @@ func AuthRequired() gin.HandlerFunc {
 		// Extract token
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		
+		if strings.HasPrefix(tokenString, model.APIKeyPrefix) {
+			authenticateAPIKey(c, apiKeys, tokenString, scopes)
+			return
+		}
+		
 		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Make sure token method conforms to "SigningMethodHMAC"
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			
-			// Return the secret key - in production, this should come from config
-			return []byte("your-secret-key-change-in-production"), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := tokens.ValidateAccessToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "unauthorized",
 				"message": "Invalid token",
@@ -54,22 +63,75 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		// Extract claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			// Set user information in context
-			c.Set("user_id", claims["user_id"])
-			c.Set("username", claims["username"])
-			c.Set("email", claims["email"])
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "Invalid token claims",
+		// Set user information in context
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	})
+}
+
+// authenticateAPIKey authenticates a request made with an API key and
+// checks its scopes and device restriction
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, tokenString string, scopes []string) {
+	if apiKeys == nil || len(scopes) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "forbidden",
+			"message": "API keys are not accepted for this endpoint",
+		})
+		c.Abort()
+		return
+	}
+
+	key, user, err := apiKeys.AuthenticateAPIKey(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "Invalid API key",
+		})
+		c.Abort()
+		return
+	}
+
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "API key lacks the " + scope + " scope",
 			})
 			c.Abort()
 			return
 		}
-	})
+	}
+
+	if deviceID := c.Param("device_id"); deviceID != "" && !key.AllowsDevice(deviceID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "forbidden",
+			"message": "API key is not allowed for this device",
+		})
+		c.Abort()
+		return
+	}
+
+	// Set user information in context
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("email", user.Email)
+	c.Set("api_key", key)
+	c.Next()
+}
+
+// CanAccessDevice reports whether the request's credentials may be used for
+// deviceID. Only API keys restricted to other devices are refused
+func CanAccessDevice(c *gin.Context, deviceID string) bool {
+	value, exists := c.Get("api_key")
+	if !exists {
+		return true
+	}
+
+	key, ok := value.(*model.APIKey)
+	return ok && key.AllowsDevice(deviceID)
 }
 
 // GetUserID extracts user ID from context
@@ -86,6 +148,20 @@ func GetUserID(c *gin.Context) (string, bool) {
 	return "", false
 }
 
+// GetSessionID extracts the login session of a JWT-authenticated request
+func GetSessionID(c *gin.Context) (string, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return "", false
+	}
+	
+	if id, ok := sessionID.(string); ok && id != "" {
+		return id, true
+	}
+	
+	return "", false
+}
+
 // GetUsername extracts username from context
 func GetUsername(c *gin.Context) (string, bool) {
 	username, exists := c.Get("username")
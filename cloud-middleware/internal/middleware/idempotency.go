@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/idempotency"
+)
+
+// IdempotencyKeyHeader is the request header carrying a client-chosen key that makes a request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks a response replayed from an earlier request with the same key
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// idempotencyPendingTTL bounds how long a request holds its key before
+// completing. A reservation left by a crashed replica expires after it, and a
+// request running longer than this can be started again by a retry
+const idempotencyPendingTTL = 10 * time.Minute
+
+// idempotencyStoreTimeout bounds recording a response, which happens even when the client went away
+const idempotencyStoreTimeout = 5 * time.Second
+
+// IdempotencyScopeFunc picks what a key is scoped to besides the user, such as
+// the target device, from the request and its body
+type IdempotencyScopeFunc func(c *gin.Context, body []byte) string
+
+// Idempotency records the response of a request carrying an Idempotency-Key
+// header for ttl and replays it, status and body, when the key comes again
+// instead of running the handler twice. Keys are kept per user and scope, so
+// clients cannot see each other's responses. Reusing a key with a different
+// request is rejected with 422, and a replay while the first request still
+// runs gets 409. Requests without the header, a nil store or a store failure
+// let the request through; it must run after AuthRequired
+func Idempotency(store idempotency.Store, ttl time.Duration, scopeFunc IdempotencyScopeFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if store == nil || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_idempotency_key",
+				"message": "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		// Put the body back for the handler
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := GetUserID(c)
+		storeKey := userID + ":" + scopeFunc(c, body) + ":" + key
+		fingerprint := requestFingerprint(c, body)
+
+		record, err := store.Begin(c.Request.Context(), storeKey, fingerprint, idempotencyPendingTTL)
+		if err != nil {
+			log.Printf("Idempotency store failed, running request without a key: %v", err)
+			c.Next()
+			return
+		}
+
+		if record != nil {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   "idempotency_key_reused",
+					"message": "Idempotency-Key was already used with a different request",
+				})
+			case record.Pending:
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "idempotency_key_in_use",
+					"message": "A request with this Idempotency-Key is still in progress",
+				})
+			default:
+				c.Header(IdempotentReplayHeader, "true")
+				c.Data(record.Status, record.ContentType, record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// A panicking handler produced no response to replay; let the key be retried
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+			defer cancel()
+			if err := store.Release(ctx, storeKey); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+		}()
+
+		c.Next()
+
+		// Record every outcome, errors included: a failed execution may still
+		// have reached the device, so a retry must not run it again
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		err = store.Complete(ctx, storeKey, &idempotency.Record{
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}, ttl)
+		if err != nil {
+			log.Printf("Failed to record idempotent response: %v", err)
+		}
+		completed = true
+	}
+}
+
+// DeviceIdempotencyScope scopes keys to the device_id of a JSON request body
+func DeviceIdempotencyScope(c *gin.Context, body []byte) string {
+	var target struct {
+		DeviceID string `json:"device_id"`
+	}
+	json.Unmarshal(body, &target)
+	return target.DeviceID
+}
+
+// requestFingerprint identifies a request by its route and body
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copies the response body while writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write implements io.Writer
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString implements io.StringWriter
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/ratelimit"
+)
+
+// fakeChallenges accepts the challenge tokens it maps to a user ID
+type fakeChallenges map[string]string
+
+func (f fakeChallenges) ValidateChallengeToken(tokenString string) (*auth.Challenge, error) {
+	userID, ok := f[tokenString]
+	if !ok {
+		return nil, errors.New("invalid challenge")
+	}
+	return &auth.Challenge{ID: tokenString, UserID: userID, ExpiresAt: time.Now().Add(time.Minute)}, nil
+}
+
+func TestTwoFactorRateLimitKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyFunc := TwoFactorRateLimitKey(fakeChallenges{"challenge-a": "user-a", "challenge-b": "user-a"})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"valid challenge", `{"challenge_token":"challenge-a","code":"123456"}`, "user:user-a"},
+		{"another challenge of the same user", `{"challenge_token":"challenge-b","code":"123456"}`, "user:user-a"},
+		{"invalid challenge", `{"challenge_token":"forged","code":"123456"}`, "ip:192.0.2.1"},
+		{"missing challenge", `{"code":"123456"}`, "ip:192.0.2.1"},
+		{"malformed body", `not json`, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/2fa", strings.NewReader(tt.body))
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+
+			if got := keyFunc(c); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+			body, _ := io.ReadAll(c.Request.Body)
+			if string(body) != tt.body {
+				t.Errorf("handler sees body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestTwoFactorRateLimitSharedAcrossChallenges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	limit := RateLimit(ratelimit.NewMemoryLimiter(), "2fa", 2, time.Minute,
+		TwoFactorRateLimitKey(fakeChallenges{"challenge-a": "user-a", "challenge-b": "user-a", "challenge-c": "user-c"}))
+	router.POST("/2fa", limit, func(c *gin.Context) { c.Status(http.StatusUnauthorized) })
+
+	tests := []struct {
+		name      string
+		challenge string
+		want      int
+	}{
+		{"first attempt", "challenge-a", http.StatusUnauthorized},
+		{"second attempt with a fresh challenge", "challenge-b", http.StatusUnauthorized},
+		{"third attempt is limited", "challenge-a", http.StatusTooManyRequests},
+		{"other user is not limited", "challenge-c", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			body := `{"challenge_token":"` + tt.challenge + `","code":"000000"}`
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/2fa", strings.NewReader(body)))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from JWTs
+const APIKeyPrefix = "lazk_"
+
+// API key scopes
+const (
+	APIKeyScopeRead    = "read"    // query devices, commands and groups
+	APIKeyScopeExecute = "execute" // run commands on devices
+)
+
+// APIKey is a long-lived credential that lets scripts act as its owner
+type APIKey struct {
+	ID         string         `gorm:"primaryKey" json:"id"`
+	UserID     string         `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"not null" json:"name"`
+	Prefix     string         `gorm:"not null" json:"prefix"` // start of the key, to recognise it
+	KeyHash    string         `gorm:"not null;uniqueIndex" json:"-"`
+	Scopes     []string       `gorm:"type:text;serializer:json" json:"scopes"`
+	DeviceIDs  []string       `gorm:"type:text;serializer:json" json:"device_ids,omitempty"` // empty allows every device
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"` // set when the key is revoked
+}
+
+// TableName returns the table name for APIKey model
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// BeforeCreate will set UUID and timestamps
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = generateUUID()
+	}
+	k.CreatedAt = time.Now()
+	return nil
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsDevice reports whether the key may be used for deviceID
+func (k *APIKey) AllowsDevice(deviceID string) bool {
+	if len(k.DeviceIDs) == 0 {
+		return true
+	}
+	for _, id := range k.DeviceIDs {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired reports whether the key is past its expiry
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
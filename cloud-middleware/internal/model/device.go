@@ -25,6 +25,9 @@ type Device struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Hash of the tunnel access token last issued to the device
+	TunnelTokenHash string `json:"-"`
+
 	// System information stored as JSON
 	SystemInfo SystemInfo `gorm:"type:text" json:"system_info"`
 
@@ -74,7 +77,7 @@ type UserDevice struct {
 	UserID   string `gorm:"not null;index" json:"user_id"`
 	DeviceID string `gorm:"not null;index" json:"device_id"`
 	Role     string `gorm:"not null;default:user" json:"role"` // owner, admin, user, viewer
-	Status   string `gorm:"not null;default:active" json:"status"` // active, disabled
+	Status   string `gorm:"not null;default:active" json:"status"` // active, pending, disabled
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
@@ -83,6 +86,44 @@ type UserDevice struct {
 	Device Device `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
 }
 
+// UserDevice statuses; only active bindings grant access
+const (
+	UserDeviceStatusActive   = "active"
+	UserDeviceStatusPending  = "pending" // requested by the user, awaiting a device owner's approval
+	UserDeviceStatusDisabled = "disabled"
+)
+
+// Access history actions for UserDeviceHistory
+const (
+	AccessActionGranted     = "granted"
+	AccessActionRoleChanged = "role_changed"
+	AccessActionRevoked     = "revoked"
+	AccessActionRequested   = "requested"
+	AccessActionRejected    = "rejected"
+)
+
+// UserDeviceHistory records a change to a user's access to a device
+type UserDeviceHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DeviceID  string    `gorm:"not null;index" json:"device_id"`
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	Action    string    `gorm:"not null" json:"action"` // granted, role_changed, revoked, requested, rejected
+	OldRole   string    `json:"old_role,omitempty"`
+	NewRole   string    `json:"new_role,omitempty"`
+	ActorID   string    `json:"actor_id"` // user who made the change
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccessLog records a request refused because the user lacked the required device role
+type AccessLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       string    `gorm:"not null;index" json:"user_id"`
+	DeviceID     string    `gorm:"not null;index" json:"device_id"`
+	RequiredRole string    `gorm:"not null" json:"required_role"`
+	Action       string    `gorm:"not null" json:"action"` // operation that was refused, e.g. execute_command
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
 // DeviceCommand represents commands configured on a device
 type DeviceCommand struct {
 	ID             string                 `gorm:"primaryKey" json:"id"`
@@ -97,7 +138,9 @@ type DeviceCommand struct {
 	CommandType    string                 `json:"command_type"`
 	Timeout        int                    `gorm:"default:30000" json:"timeout"` // milliseconds
 	TemplateID     string                 `json:"template_id"`
-	TemplateParams map[string]interface{} `gorm:"type:text" json:"template_params"`
+	TemplateParams map[string]interface{} `gorm:"type:text;serializer:json" json:"template_params"`
+	WorkingDir     string                 `json:"working_dir"`
+	Env            CommandEnv             `gorm:"type:text" json:"env"`
 	CreatedAt      time.Time              `json:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt         `gorm:"index" json:"-"`
@@ -117,6 +160,40 @@ type DeviceCommand struct {
 	Device Device `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
 }
 
+// UserFavoriteCommand marks a device command as one of a user's favorites.
+// Unlike ShowOnHomepage it is per user, so members of the same device each
+// keep their own favorites
+type UserFavoriteCommand struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"not null;uniqueIndex:idx_user_favorite_command" json:"user_id"`
+	DeviceID  string    `gorm:"not null;uniqueIndex:idx_user_favorite_command" json:"device_id"`
+	CommandID string    `gorm:"not null;uniqueIndex:idx_user_favorite_command" json:"command_id"` // DeviceCommand.CommandID
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommandEnv represents environment variables set for a command
+type CommandEnv map[string]string
+
+// Value implements driver.Valuer interface for GORM
+func (e CommandEnv) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+// Scan implements sql.Scanner interface for GORM
+func (e *CommandEnv) Scan(value interface{}) error {
+	if value == nil {
+		*e = make(CommandEnv)
+		return nil
+	}
+	
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	
+	return json.Unmarshal(bytes, e)
+}
+
 // PositionConfig represents position configuration
 type PositionConfig struct {
 	X      int `json:"x"`
@@ -164,6 +241,36 @@ type ExecutionLog struct {
 	Device Device `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
 }
 
+// PendingExecution statuses. Only pending executions are delivered or cancelled
+const (
+	PendingExecutionPending   = "pending"
+	PendingExecutionRunning   = "running"
+	PendingExecutionCompleted = "completed" // the device ran the command, successfully or not
+	PendingExecutionFailed    = "failed"    // the command could not be delivered
+	PendingExecutionDenied    = "denied"    // the user lost permission to run it before delivery
+	PendingExecutionCancelled = "cancelled"
+	PendingExecutionExpired   = "expired"
+)
+
+// PendingExecution is a command queued while its device was offline, run when
+// the device reconnects
+type PendingExecution struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"`
+	DeviceID    string     `gorm:"not null;index" json:"device_id"`
+	CommandID   string     `gorm:"not null" json:"command_id"`
+	Timeout     int32      `json:"timeout"` // seconds
+	Status      string     `gorm:"not null;index;default:'pending'" json:"status"`
+	Success     bool       `json:"success"`
+	Output      string     `json:"output,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	ExitCode    int        `json:"exit_code"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
 // TableName methods
 func (Device) TableName() string {
 	return "devices"
@@ -173,6 +280,14 @@ func (UserDevice) TableName() string {
 	return "user_devices"
 }
 
+func (UserDeviceHistory) TableName() string {
+	return "user_device_history"
+}
+
+func (AccessLog) TableName() string {
+	return "access_logs"
+}
+
 func (DeviceCommand) TableName() string {
 	return "device_commands"
 }
@@ -181,6 +296,14 @@ func (ExecutionLog) TableName() string {
 	return "execution_logs"
 }
 
+func (PendingExecution) TableName() string {
+	return "pending_executions"
+}
+
+func (UserFavoriteCommand) TableName() string {
+	return "user_favorite_commands"
+}
+
 // BeforeCreate hooks
 func (d *Device) BeforeCreate(tx *gorm.DB) error {
 	if d.ID == "" {
@@ -197,6 +320,11 @@ func (ud *UserDevice) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+func (h *UserDeviceHistory) BeforeCreate(tx *gorm.DB) error {
+	h.CreatedAt = time.Now()
+	return nil
+}
+
 func (dc *DeviceCommand) BeforeCreate(tx *gorm.DB) error {
 	if dc.ID == "" {
 		dc.ID = generateUUID()
@@ -214,6 +342,19 @@ func (el *ExecutionLog) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+func (pe *PendingExecution) BeforeCreate(tx *gorm.DB) error {
+	if pe.ID == "" {
+		pe.ID = generateUUID()
+	}
+	pe.CreatedAt = time.Now()
+	return nil
+}
+
+func (f *UserFavoriteCommand) BeforeCreate(tx *gorm.DB) error {
+	f.CreatedAt = time.Now()
+	return nil
+}
+
 // BeforeUpdate hooks
 func (d *Device) BeforeUpdate(tx *gorm.DB) error {
 	d.UpdatedAt = time.Now()
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeviceGroup represents a named set of devices managed together
+type DeviceGroup struct {
+	ID          string         `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description"`
+	OwnerID     string         `gorm:"not null;index" json:"owner_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Associations
+	Members []DeviceGroupMember `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+}
+
+// DeviceGroupMember represents a device's membership in a group
+type DeviceGroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   string    `gorm:"not null;uniqueIndex:idx_group_device" json:"group_id"`
+	DeviceID  string    `gorm:"not null;uniqueIndex:idx_group_device" json:"device_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName methods
+func (DeviceGroup) TableName() string {
+	return "device_groups"
+}
+
+func (DeviceGroupMember) TableName() string {
+	return "device_group_members"
+}
+
+// BeforeCreate hooks
+func (g *DeviceGroup) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == "" {
+		g.ID = generateUUID()
+	}
+	g.CreatedAt = time.Now()
+	g.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *DeviceGroupMember) BeforeCreate(tx *gorm.DB) error {
+	m.CreatedAt = time.Now()
+	return nil
+}
+
+// BeforeUpdate hooks
+func (g *DeviceGroup) BeforeUpdate(tx *gorm.DB) error {
+	g.UpdatedAt = time.Now()
+	return nil
+}
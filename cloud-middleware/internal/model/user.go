@@ -11,7 +11,7 @@ type User struct {
 	ID        string    `gorm:"primaryKey" json:"id"`
 	Username  string    `gorm:"uniqueIndex;not null" json:"username"`
 	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Phone     string    `gorm:"uniqueIndex" json:"phone"`
+	Phone     string    `gorm:"uniqueIndex:idx_users_phone_set,where:phone <> ''" json:"phone"` // unique when set
 	Password  string    `gorm:"not null" json:"-"`
 	Nickname  string    `json:"nickname"`
 	AvatarURL string    `json:"avatar_url"`
@@ -21,6 +21,11 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Two-factor authentication, enforced when Settings.TwoFactorEnabled is set
+	TOTPSecret    string   `json:"-"`                                   // encrypted; pending until the first code is verified
+	TOTPLastStep  int64    `json:"-"`                                   // last accepted time step, so each code works once
+	RecoveryCodes []string `gorm:"type:text;serializer:json" json:"-"` // hashes of unused recovery codes
+
 	// Settings
 	Settings *UserSettings `gorm:"embedded;embeddedPrefix:settings_" json:"settings"`
 
@@ -28,6 +33,61 @@ type User struct {
 	Devices []UserDevice `gorm:"foreignKey:UserID" json:"devices,omitempty"`
 }
 
+// UserIdentity links a user to an account at an external OAuth provider
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_provider_subject" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebAuthnCredential is a passkey or security key a user enrolled for login
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          string     `gorm:"not null;index" json:"user_id"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `gorm:"not null;uniqueIndex" json:"credential_id"`
+	PublicKey       []byte     `gorm:"not null" json:"-"`
+	AttestationType string     `json:"attestation_type"`
+	Transports      []string   `gorm:"type:text;serializer:json" json:"transports"`
+	AAGUID          []byte     `json:"aaguid"`
+	SignCount       uint32     `json:"-"`
+	BackupEligible  bool       `json:"backup_eligible"` // synced passkey; never changes for a credential
+	BackupState     bool       `json:"backup_state"`
+	LastChallenge   string     `json:"-"` // challenge of the last login, so each assertion works once
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RefreshToken records an issued refresh token. Each one is single use; the
+// tokens rotated from one login share a family that is revoked as a whole
+// when a used token is presented again
+type RefreshToken struct {
+	ID        string     `gorm:"primaryKey" json:"id"` // jti claim
+	UserID    string     `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"not null;index" json:"family_id"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is a login, followed through the refresh token family it started.
+// Revoking it revokes the family and blacklists the access tokens it issued
+type Session struct {
+	ID         string     `gorm:"primaryKey" json:"id"` // refresh token family ID
+	UserID     string     `gorm:"not null;index" json:"user_id"`
+	Device     string     `json:"device"` // name the client gave at login, if any
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt *time.Time `json:"last_used_at"`                     // last token refresh
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"` // of the newest refresh token
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 // UserSettings represents user configuration settings
 type UserSettings struct {
 	Language                     string `gorm:"default:zh-CN" json:"language"`
@@ -44,6 +104,26 @@ func (User) TableName() string {
 	return "users"
 }
 
+// TableName returns the table name for UserIdentity model
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// TableName returns the table name for WebAuthnCredential model
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// TableName returns the table name for RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// TableName returns the table name for Session model
+func (Session) TableName() string {
+	return "sessions"
+}
+
 // BeforeCreate will set UUID and timestamps
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
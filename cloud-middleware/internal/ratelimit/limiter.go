@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory limiter drops keys with no recent events
+const sweepInterval = time.Minute
+
+// Limiter counts events per key over a sliding window
+type Limiter interface {
+	// Allow records an event for key if fewer than limit events happened within
+	// the last window. When it refuses, retryAfter is how long until the oldest
+	// counted event leaves the window. Refused events are not counted and
+	// limit must be positive
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// memoryWindow holds the recent events of one key
+type memoryWindow struct {
+	events []time.Time // oldest first
+	window time.Duration
+}
+
+// MemoryLimiter is a Limiter local to this process, for single-instance deployments
+type MemoryLimiter struct {
+	mutex     sync.Mutex
+	windows   map[string]*memoryWindow
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates an in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		windows:   make(map[string]*memoryWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow implements Limiter
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	w, exists := l.windows[key]
+	if !exists {
+		w = &memoryWindow{}
+		l.windows[key] = w
+	}
+	w.window = window
+	w.events = trimEvents(w.events, now.Add(-window))
+
+	if len(w.events) >= limit {
+		return false, w.events[0].Add(window).Sub(now), nil
+	}
+	w.events = append(w.events, now)
+	return true, 0, nil
+}
+
+// sweep drops keys whose events all fell out of their window
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, w := range l.windows {
+		if len(trimEvents(w.events, now.Add(-w.window))) == 0 {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// trimEvents drops events at or before cutoff
+func trimEvents(events []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	return events[i:]
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+func TestListAccessAudit(t *testing.T) {
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	users := NewUserRepository(db)
+	devices := NewDeviceRepository(db)
+	for _, name := range []string{"alice", "bob", "carol", "gone"} {
+		if err := users.Create(&model.User{ID: name, Username: name, Email: name + "@example.com", Password: "password123", Role: "user"}); err != nil {
+			t.Fatalf("create user %s: %v", name, err)
+		}
+	}
+	for _, device := range []*model.Device{
+		{ID: "d1", DeviceName: "Office PC", DeviceType: "desktop", Platform: "windows"},
+		{ID: "d2", DeviceName: "Home Server", DeviceType: "server", Platform: "linux"},
+		{ID: "d3", DeviceName: "Retired", DeviceType: "desktop", Platform: "linux"},
+	} {
+		if err := devices.Create(device); err != nil {
+			t.Fatalf("create device %s: %v", device.ID, err)
+		}
+	}
+	for _, binding := range []*model.UserDevice{
+		{UserID: "alice", DeviceID: "d1", Role: "owner", Status: model.UserDeviceStatusActive},
+		{UserID: "bob", DeviceID: "d1", Role: "viewer", Status: model.UserDeviceStatusActive},
+		{UserID: "alice", DeviceID: "d2", Role: "owner", Status: model.UserDeviceStatusActive},
+		{UserID: "carol", DeviceID: "d2", Role: "user", Status: model.UserDeviceStatusPending},
+		{UserID: "bob", DeviceID: "d3", Role: "owner", Status: model.UserDeviceStatusActive},
+		{UserID: "gone", DeviceID: "d2", Role: "admin", Status: model.UserDeviceStatusActive},
+	} {
+		if err := devices.CreateUserDevice(binding); err != nil {
+			t.Fatalf("bind %s to %s: %v", binding.UserID, binding.DeviceID, err)
+		}
+	}
+	// Bindings of deleted devices and users are not access anyone has
+	if err := devices.Delete("d3"); err != nil {
+		t.Fatalf("delete device: %v", err)
+	}
+	if err := users.Delete("gone"); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		offset    int
+		limit     int
+		want      []string // device/user:role:status, ordered by device name then username
+		wantTotal int64
+	}{
+		{"every binding", "", 0, 10, []string{"d2/alice:owner:active", "d2/carol:user:pending", "d1/alice:owner:active", "d1/bob:viewer:active"}, 4},
+		{"owners", "owner", 0, 10, []string{"d2/alice:owner:active", "d1/alice:owner:active"}, 2},
+		{"viewers", "viewer", 0, 10, []string{"d1/bob:viewer:active"}, 1},
+		{"role of deleted bindings only", "admin", 0, 10, nil, 0},
+		{"unknown role", "guest", 0, 10, nil, 0},
+		{"first page", "", 0, 3, []string{"d2/alice:owner:active", "d2/carol:user:pending", "d1/alice:owner:active"}, 4},
+		{"second page", "", 3, 3, []string{"d1/bob:viewer:active"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := devices.ListAccessAudit(tt.role, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("ListAccessAudit: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, fmt.Sprintf("%s/%s:%s:%s", entry.DeviceID, entry.Username, entry.Role, entry.Status))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("entries = %v, want %v", got, tt.want)
+			}
+			for _, entry := range entries {
+				if entry.DeviceName == "" || entry.Email != entry.UserID+"@example.com" || entry.GrantedAt.IsZero() {
+					t.Errorf("entry %+v is missing device, user or grant details", entry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// APIKeyRepository defines the interface for API key data operations
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	GetByHash(keyHash string) (*model.APIKey, error)
+	ListByUser(userID string) ([]*model.APIKey, error)
+	Delete(userID, keyID string) error
+	UpdateLastUsed(keyID string, usedAt time.Time) error
+}
+
+// apiKeyRepository implements APIKeyRepository interface
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+// Create creates a new API key
+func (r *apiKeyRepository) Create(key *model.APIKey) error {
+	if err := r.db.Create(key).Error; err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves an unrevoked API key by the hash of its secret, or nil if none matches
+func (r *apiKeyRepository) GetByHash(keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// ListByUser retrieves the unrevoked API keys of a user
+func (r *apiKeyRepository) ListByUser(userID string) ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Delete revokes a user's API key
+func (r *apiKeyRepository) Delete(userID, keyID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", keyID, userID).Delete(&model.APIKey{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke API key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API key not found: %s", keyID)
+	}
+
+	return nil
+}
+
+// UpdateLastUsed records when a key was last used
+func (r *apiKeyRepository) UpdateLastUsed(keyID string, usedAt time.Time) error {
+	if err := r.db.Model(&model.APIKey{}).Where("id = ?", keyID).UpdateColumn("last_used_at", usedAt).Error; err != nil {
+		return fmt.Errorf("failed to update API key last used time: %w", err)
+	}
+
+	return nil
+}
@@ -1,26 +1,72 @@
 package repository
 
 import (
+	"fmt"
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 )
 
+// DeviceFilter holds optional criteria for listing devices
+type DeviceFilter struct {
+	Online   *bool  // nil matches both online and offline devices
+	Platform string // exact platform match
+	Name     string // case-insensitive device name substring
+}
+
+// AccessLogFilter holds optional criteria for listing denied accesses
+type AccessLogFilter struct {
+	UserID   string
+	DeviceID string
+	Action   string
+}
+
+// AccessAuditEntry describes one user's binding to a device
+type AccessAuditEntry struct {
+	DeviceID   string    `json:"device_id"`
+	DeviceName string    `json:"device_name"`
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	Status     string    `json:"status"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// FavoriteCommand is a device command a user marked as favorite
+type FavoriteCommand struct {
+	model.DeviceCommand
+	FavoritedAt time.Time
+}
+
 // DeviceRepository interface defines device data access methods
 type DeviceRepository interface {
 	Create(device *model.Device) error
 	GetByID(deviceID string) (*model.Device, error)
 	GetAll() ([]*model.Device, error)
+	List(filter DeviceFilter, offset, limit int) ([]*model.Device, int64, error)
 	GetOnlineDevices() ([]*model.Device, error)
+	Touch(deviceID string, seen time.Time) (bool, error)
+	MarkStaleOffline(cutoff time.Time) ([]*model.Device, error)
 	Update(device *model.Device) error
+	SetTunnelTokenHash(deviceID, hash string) error
 	Delete(deviceID string) error
 
 	// User-Device relationship methods
 	CreateUserDevice(userDevice *model.UserDevice) error
 	GetUserDevice(userID, deviceID string) (*model.UserDevice, error)
 	GetUserDevices(userID string, onlineOnly bool) ([]*model.Device, error)
+	UpdateUserDevice(userDevice *model.UserDevice) error
 	DeleteUserDevice(userID, deviceID string) error
 	DeleteAllUserDevices(deviceID string) error
+	CreateUserDeviceHistory(entry *model.UserDeviceHistory) error
+	GetUserDeviceHistory(deviceID string, limit int) ([]*model.UserDeviceHistory, error)
+	ListAccessAudit(role string, offset, limit int) ([]*AccessAuditEntry, int64, error)
+	ListDeviceMembers(deviceID, status string) ([]*AccessAuditEntry, error)
+	CreateAccessLog(entry *model.AccessLog) error
+	ListAccessLogs(filter AccessLogFilter, offset, limit int) ([]*model.AccessLog, int64, error)
 
 	// Device Command methods
 	CreateDeviceCommand(command *model.DeviceCommand) error
@@ -29,11 +75,35 @@ type DeviceRepository interface {
 	UpdateDeviceCommand(command *model.DeviceCommand) error
 	DeleteDeviceCommand(deviceID, commandID string) error
 	DeleteAllDeviceCommands(deviceID string) error
+	GetDeletedDeviceCommandIDs(deviceID string) ([]string, error)
+
+	// Favorite command methods
+	AddFavoriteCommand(userID, deviceID, commandID string) (*model.UserFavoriteCommand, error)
+	RemoveFavoriteCommand(userID, deviceID, commandID string) (bool, error)
+	GetFavoriteCommands(userID, deviceID string) ([]*FavoriteCommand, error)
 
 	// Execution Log methods
 	CreateExecutionLog(log *model.ExecutionLog) error
 	GetExecutionLogs(deviceID string, limit int) ([]*model.ExecutionLog, error)
 	GetUserExecutionLogs(userID string, limit int) ([]*model.ExecutionLog, error)
+
+	// Pending Execution methods
+	CreatePendingExecution(execution *model.PendingExecution) error
+	GetPendingExecution(deviceID, executionID string) (*model.PendingExecution, error)
+	ListPendingExecutions(deviceID string) ([]*model.PendingExecution, error)
+	ClaimPendingExecution(executionID string) (bool, error)
+	CancelPendingExecution(deviceID, executionID string) (bool, error)
+	CompletePendingExecution(execution *model.PendingExecution) error
+	ExpirePendingExecutions(now time.Time) (int64, error)
+
+	// Device Group methods
+	CreateDeviceGroup(group *model.DeviceGroup) error
+	GetDeviceGroup(groupID string) (*model.DeviceGroup, error)
+	GetDeviceGroups(ownerID string) ([]*model.DeviceGroup, error)
+	DeleteDeviceGroup(groupID string) error
+	AddDeviceToGroup(groupID, deviceID string) error
+	RemoveDeviceFromGroup(groupID, deviceID string) error
+	GetGroupDevices(groupID string) ([]*model.Device, error)
 }
 
 // deviceRepository implements the DeviceRepository interface
@@ -71,6 +141,35 @@ func (r *deviceRepository) GetAll() ([]*model.Device, error) {
 	return devices, err
 }
 
+// List retrieves devices matching the filter with pagination
+func (r *deviceRepository) List(filter DeviceFilter, offset, limit int) ([]*model.Device, int64, error) {
+	var devices []*model.Device
+	var total int64
+
+	query := r.db.Model(&model.Device{})
+	if filter.Online != nil {
+		query = query.Where("online = ?", *filter.Online)
+	}
+	if filter.Platform != "" {
+		query = query.Where("platform = ?", filter.Platform)
+	}
+	if filter.Name != "" {
+		query = query.Where("LOWER(device_name) LIKE LOWER(?)", "%"+filter.Name+"%")
+	}
+
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count devices: %w", err)
+	}
+
+	// Get paginated results
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&devices).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	return devices, total, nil
+}
+
 // GetOnlineDevices retrieves all online devices
 func (r *deviceRepository) GetOnlineDevices() ([]*model.Device, error) {
 	var devices []*model.Device
@@ -78,11 +177,69 @@ func (r *deviceRepository) GetOnlineDevices() ([]*model.Device, error) {
 	return devices, err
 }
 
+// Touch records contact from a device, setting its last seen time and marking
+// it online; reports whether the device was offline until now
+func (r *deviceRepository) Touch(deviceID string, seen time.Time) (bool, error) {
+	result := r.db.Model(&model.Device{}).Where("id = ? AND online = ?", deviceID, false).
+		Updates(map[string]interface{}{"online": true, "last_seen": seen})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	result = r.db.Model(&model.Device{}).Where("id = ?", deviceID).Update("last_seen", seen)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, gorm.ErrRecordNotFound
+	}
+	return false, nil
+}
+
+// MarkStaleOffline marks online devices last seen before cutoff as offline and returns them
+func (r *deviceRepository) MarkStaleOffline(cutoff time.Time) ([]*model.Device, error) {
+	var devices []*model.Device
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("online = ? AND last_seen < ?", true, cutoff).Find(&devices).Error; err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(devices))
+		for i, device := range devices {
+			device.Online = false
+			ids[i] = device.ID
+		}
+		return tx.Model(&model.Device{}).Where("id IN ?", ids).Update("online", false).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
 // Update updates a device
 func (r *deviceRepository) Update(device *model.Device) error {
 	return r.db.Save(device).Error
 }
 
+// SetTunnelTokenHash replaces the hash of the device's tunnel access token
+func (r *deviceRepository) SetTunnelTokenHash(deviceID, hash string) error {
+	result := r.db.Model(&model.Device{}).Where("id = ?", deviceID).Update("tunnel_token_hash", hash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 // Delete deletes a device
 func (r *deviceRepository) Delete(deviceID string) error {
 	return r.db.Where("id = ?", deviceID).Delete(&model.Device{}).Error
@@ -122,6 +279,11 @@ func (r *deviceRepository) GetUserDevices(userID string, onlineOnly bool) ([]*mo
 	return devices, err
 }
 
+// UpdateUserDevice updates a user-device relationship
+func (r *deviceRepository) UpdateUserDevice(userDevice *model.UserDevice) error {
+	return r.db.Save(userDevice).Error
+}
+
 // DeleteUserDevice deletes a user-device relationship
 func (r *deviceRepository) DeleteUserDevice(userID, deviceID string) error {
 	return r.db.Where("user_id = ? AND device_id = ?", userID, deviceID).Delete(&model.UserDevice{}).Error
@@ -132,6 +294,101 @@ func (r *deviceRepository) DeleteAllUserDevices(deviceID string) error {
 	return r.db.Where("device_id = ?", deviceID).Delete(&model.UserDevice{}).Error
 }
 
+// CreateUserDeviceHistory records a change to a user's access to a device
+func (r *deviceRepository) CreateUserDeviceHistory(entry *model.UserDeviceHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// GetUserDeviceHistory retrieves access history for a device, newest first
+func (r *deviceRepository) GetUserDeviceHistory(deviceID string, limit int) ([]*model.UserDeviceHistory, error) {
+	var history []*model.UserDeviceHistory
+	query := r.db.Where("device_id = ?", deviceID).Order("created_at DESC, id DESC")
+	
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	
+	err := query.Find(&history).Error
+	return history, err
+}
+
+// ListAccessAudit retrieves user-device bindings across all devices with pagination,
+// optionally restricted to a single role
+func (r *deviceRepository) ListAccessAudit(role string, offset, limit int) ([]*AccessAuditEntry, int64, error) {
+	var entries []*AccessAuditEntry
+	var total int64
+
+	query := r.db.Table("user_devices").
+		Joins("JOIN devices ON devices.id = user_devices.device_id AND devices.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = user_devices.user_id AND users.deleted_at IS NULL")
+	if role != "" {
+		query = query.Where("user_devices.role = ?", role)
+	}
+
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count access bindings: %w", err)
+	}
+
+	// Get paginated results
+	err := query.Select("user_devices.device_id, devices.device_name, user_devices.user_id, users.username, users.email, " +
+		"user_devices.role, user_devices.status, user_devices.created_at AS granted_at").
+		Order("devices.device_name, user_devices.device_id, users.username").
+		Offset(offset).Limit(limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list access bindings: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// ListDeviceMembers retrieves the users bound to a device with the given binding status, oldest first
+func (r *deviceRepository) ListDeviceMembers(deviceID, status string) ([]*AccessAuditEntry, error) {
+	var entries []*AccessAuditEntry
+	err := r.db.Table("user_devices").
+		Joins("JOIN devices ON devices.id = user_devices.device_id AND devices.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = user_devices.user_id AND users.deleted_at IS NULL").
+		Where("user_devices.device_id = ? AND user_devices.status = ?", deviceID, status).
+		Select("user_devices.device_id, devices.device_name, user_devices.user_id, users.username, users.email, " +
+			"user_devices.role, user_devices.status, user_devices.created_at AS granted_at").
+		Order("user_devices.created_at, user_devices.id").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device members: %w", err)
+	}
+	return entries, nil
+}
+
+// CreateAccessLog records a denied access
+func (r *deviceRepository) CreateAccessLog(entry *model.AccessLog) error {
+	return r.db.Create(entry).Error
+}
+
+// ListAccessLogs retrieves denied accesses matching the filter, newest first
+func (r *deviceRepository) ListAccessLogs(filter AccessLogFilter, offset, limit int) ([]*model.AccessLog, int64, error) {
+	var entries []*model.AccessLog
+	var total int64
+
+	query := r.db.Model(&model.AccessLog{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.DeviceID != "" {
+		query = query.Where("device_id = ?", filter.DeviceID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
 // CreateDeviceCommand creates a device command
 func (r *deviceRepository) CreateDeviceCommand(command *model.DeviceCommand) error {
 	return r.db.Create(command).Error
@@ -172,6 +429,43 @@ func (r *deviceRepository) DeleteAllDeviceCommands(deviceID string) error {
 	return r.db.Where("device_id = ?", deviceID).Delete(&model.DeviceCommand{}).Error
 }
 
+// GetDeletedDeviceCommandIDs retrieves the command IDs of soft-deleted device commands
+func (r *deviceRepository) GetDeletedDeviceCommandIDs(deviceID string) ([]string, error) {
+	var commandIDs []string
+	err := r.db.Unscoped().Model(&model.DeviceCommand{}).
+		Where("device_id = ? AND deleted_at IS NOT NULL", deviceID).
+		Distinct().Pluck("command_id", &commandIDs).Error
+	return commandIDs, err
+}
+
+// AddFavoriteCommand marks a command as a user's favorite; adding it again
+// returns the existing favorite
+func (r *deviceRepository) AddFavoriteCommand(userID, deviceID, commandID string) (*model.UserFavoriteCommand, error) {
+	favorite := &model.UserFavoriteCommand{UserID: userID, DeviceID: deviceID, CommandID: commandID}
+	err := r.db.Where(model.UserFavoriteCommand{UserID: userID, DeviceID: deviceID, CommandID: commandID}).FirstOrCreate(favorite).Error
+	return favorite, err
+}
+
+// RemoveFavoriteCommand removes a user's favorite, reporting whether it existed
+func (r *deviceRepository) RemoveFavoriteCommand(userID, deviceID, commandID string) (bool, error) {
+	result := r.db.Where("user_id = ? AND device_id = ? AND command_id = ?", userID, deviceID, commandID).
+		Delete(&model.UserFavoriteCommand{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetFavoriteCommands retrieves a user's favorite commands of a device, most
+// recently added first. Favorites of deleted commands are skipped
+func (r *deviceRepository) GetFavoriteCommands(userID, deviceID string) ([]*FavoriteCommand, error) {
+	var favorites []*FavoriteCommand
+	err := r.db.Model(&model.DeviceCommand{}).
+		Select("device_commands.*, user_favorite_commands.created_at AS favorited_at").
+		Joins("JOIN user_favorite_commands ON user_favorite_commands.device_id = device_commands.device_id AND user_favorite_commands.command_id = device_commands.command_id").
+		Where("user_favorite_commands.user_id = ? AND device_commands.device_id = ?", userID, deviceID).
+		Order("user_favorite_commands.created_at DESC, user_favorite_commands.id DESC").
+		Find(&favorites).Error
+	return favorites, err
+}
+
 // CreateExecutionLog creates an execution log entry
 func (r *deviceRepository) CreateExecutionLog(log *model.ExecutionLog) error {
 	return r.db.Create(log).Error
@@ -201,4 +495,129 @@ func (r *deviceRepository) GetUserExecutionLogs(userID string, limit int) ([]*mo
 	
 	err := query.Find(&logs).Error
 	return logs, err
-}
\ No newline at end of file
+}
+
+// CreatePendingExecution queues a command for an offline device
+func (r *deviceRepository) CreatePendingExecution(execution *model.PendingExecution) error {
+	return r.db.Create(execution).Error
+}
+
+// GetPendingExecution retrieves a queued execution of a device, in any status
+func (r *deviceRepository) GetPendingExecution(deviceID, executionID string) (*model.PendingExecution, error) {
+	var execution model.PendingExecution
+	err := r.db.Where("id = ? AND device_id = ?", executionID, deviceID).First(&execution).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// ListPendingExecutions retrieves the unexpired executions still waiting for
+// a device, oldest first
+func (r *deviceRepository) ListPendingExecutions(deviceID string) ([]*model.PendingExecution, error) {
+	var executions []*model.PendingExecution
+	err := r.db.Where("device_id = ? AND status = ? AND expires_at > ?", deviceID, model.PendingExecutionPending, time.Now()).
+		Order("created_at ASC").
+		Find(&executions).Error
+	return executions, err
+}
+
+// ClaimPendingExecution marks an unexpired pending execution as running. It
+// reports false when the execution was cancelled, expired or claimed meanwhile
+func (r *deviceRepository) ClaimPendingExecution(executionID string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&model.PendingExecution{}).
+		Where("id = ? AND status = ? AND expires_at > ?", executionID, model.PendingExecutionPending, now).
+		Updates(map[string]interface{}{"status": model.PendingExecutionRunning, "delivered_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelPendingExecution cancels an execution still waiting for its device
+func (r *deviceRepository) CancelPendingExecution(deviceID, executionID string) (bool, error) {
+	result := r.db.Model(&model.PendingExecution{}).
+		Where("id = ? AND device_id = ? AND status = ?", executionID, deviceID, model.PendingExecutionPending).
+		Update("status", model.PendingExecutionCancelled)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CompletePendingExecution stores the outcome of a delivered execution
+func (r *deviceRepository) CompletePendingExecution(execution *model.PendingExecution) error {
+	return r.db.Model(&model.PendingExecution{}).Where("id = ?", execution.ID).
+		Updates(map[string]interface{}{
+			"status":       execution.Status,
+			"success":      execution.Success,
+			"output":       execution.Output,
+			"error":        execution.Error,
+			"exit_code":    execution.ExitCode,
+			"completed_at": execution.CompletedAt,
+		}).Error
+}
+
+// ExpirePendingExecutions marks pending executions past their expiry as
+// expired and returns how many were
+func (r *deviceRepository) ExpirePendingExecutions(now time.Time) (int64, error) {
+	result := r.db.Model(&model.PendingExecution{}).
+		Where("status = ? AND expires_at <= ?", model.PendingExecutionPending, now).
+		Update("status", model.PendingExecutionExpired)
+	return result.RowsAffected, result.Error
+}
+
+// CreateDeviceGroup creates a device group
+func (r *deviceRepository) CreateDeviceGroup(group *model.DeviceGroup) error {
+	return r.db.Create(group).Error
+}
+
+// GetDeviceGroup retrieves a device group with its members
+func (r *deviceRepository) GetDeviceGroup(groupID string) (*model.DeviceGroup, error) {
+	var group model.DeviceGroup
+	err := r.db.Preload("Members").Where("id = ?", groupID).First(&group).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetDeviceGroups retrieves all device groups owned by a user
+func (r *deviceRepository) GetDeviceGroups(ownerID string) ([]*model.DeviceGroup, error) {
+	var groups []*model.DeviceGroup
+	err := r.db.Preload("Members").Where("owner_id = ?", ownerID).Order("name").Find(&groups).Error
+	return groups, err
+}
+
+// DeleteDeviceGroup deletes a device group and its memberships
+func (r *deviceRepository) DeleteDeviceGroup(groupID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&model.DeviceGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", groupID).Delete(&model.DeviceGroup{}).Error
+	})
+}
+
+// AddDeviceToGroup adds a device to a group, ignoring existing memberships
+func (r *deviceRepository) AddDeviceToGroup(groupID, deviceID string) error {
+	member := &model.DeviceGroupMember{GroupID: groupID, DeviceID: deviceID}
+	return r.db.Where(model.DeviceGroupMember{GroupID: groupID, DeviceID: deviceID}).FirstOrCreate(member).Error
+}
+
+// RemoveDeviceFromGroup removes a device from a group
+func (r *deviceRepository) RemoveDeviceFromGroup(groupID, deviceID string) error {
+	return r.db.Where("group_id = ? AND device_id = ?", groupID, deviceID).Delete(&model.DeviceGroupMember{}).Error
+}
+
+// GetGroupDevices retrieves all devices in a group
+func (r *deviceRepository) GetGroupDevices(groupID string) ([]*model.Device, error) {
+	var devices []*model.Device
+
+	err := r.db.Table("devices").
+		Joins("JOIN device_group_members ON devices.id = device_group_members.device_id").
+		Where("device_group_members.group_id = ? AND devices.deleted_at IS NULL", groupID).
+		Find(&devices).Error
+	return devices, err
+}
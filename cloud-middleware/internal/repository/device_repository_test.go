@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"testing"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// newTestDeviceRepository returns a repository over an in-memory database
+// holding the given devices
+func newTestDeviceRepository(t *testing.T, devices ...*model.Device) DeviceRepository {
+	t.Helper()
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	repo := NewDeviceRepository(db)
+	for _, device := range devices {
+		if err := repo.Create(device); err != nil {
+			t.Fatalf("create device %s: %v", device.ID, err)
+		}
+	}
+	return repo
+}
+
+func TestDeviceRepositoryList(t *testing.T) {
+	device := func(id, name, platform string, online bool) *model.Device {
+		return &model.Device{ID: id, DeviceName: name, DeviceType: "desktop", Platform: platform, Online: online}
+	}
+	// Created oldest first, so d1 is the newest
+	repo := newTestDeviceRepository(t,
+		device("d4", "Media Box", "linux", false),
+		device("d3", "Home Server", "linux", true),
+		device("d2", "office-laptop", "linux", false),
+		device("d1", "Office PC", "windows", true),
+	)
+	online, offline := true, false
+
+	tests := []struct {
+		name      string
+		filter    DeviceFilter
+		offset    int
+		limit     int
+		want      []string
+		wantTotal int64
+	}{
+		{"everything, newest first", DeviceFilter{}, 0, 10, []string{"d1", "d2", "d3", "d4"}, 4},
+		{"first page", DeviceFilter{}, 0, 2, []string{"d1", "d2"}, 4},
+		{"second page", DeviceFilter{}, 2, 2, []string{"d3", "d4"}, 4},
+		{"past the end", DeviceFilter{}, 4, 2, nil, 4},
+		{"online", DeviceFilter{Online: &online}, 0, 10, []string{"d1", "d3"}, 2},
+		{"offline", DeviceFilter{Online: &offline}, 0, 10, []string{"d2", "d4"}, 2},
+		{"platform", DeviceFilter{Platform: "linux"}, 0, 10, []string{"d2", "d3", "d4"}, 3},
+		{"name ignores case", DeviceFilter{Name: "OFFICE"}, 0, 10, []string{"d1", "d2"}, 2},
+		{"combined", DeviceFilter{Online: &offline, Platform: "linux", Name: "box"}, 0, 10, []string{"d4"}, 1},
+		{"total counts every page", DeviceFilter{Platform: "linux"}, 0, 1, []string{"d2"}, 3},
+		{"no match", DeviceFilter{Platform: "darwin"}, 0, 10, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices, total, err := repo.List(tt.filter, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Fatalf("total = %d, want %d", total, tt.wantTotal)
+			}
+			var got []string
+			for _, device := range devices {
+				got = append(got, device.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("devices = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("devices = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
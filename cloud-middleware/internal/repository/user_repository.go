@@ -3,6 +3,7 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -14,6 +15,7 @@ import (
 type UserRepository interface {
 	// User CRUD operations
 	Create(user *model.User) error
+	CreateBatch(users []*model.User) error
 	GetByID(id string) (*model.User, error)
 	GetByUsername(username string) (*model.User, error)
 	GetByEmail(email string) (*model.User, error)
@@ -30,6 +32,29 @@ type UserRepository interface {
 	SetUserRole(userID, role string) error
 	IsUsernameExists(username string) bool
 	IsEmailExists(email string) bool
+
+	// External identity operations
+	GetIdentity(provider, subject string) (*model.UserIdentity, error)
+	CreateIdentity(identity *model.UserIdentity) error
+
+	// WebAuthn credential operations
+	ListWebAuthnCredentials(userID string) ([]model.WebAuthnCredential, error)
+	GetWebAuthnCredential(credentialID []byte) (*model.WebAuthnCredential, error)
+	CreateWebAuthnCredential(credential *model.WebAuthnCredential) error
+	RecordWebAuthnLogin(credential *model.WebAuthnCredential, challenge string) (bool, error)
+	DeleteWebAuthnCredential(userID string, id uint) error
+
+	// Refresh token operations
+	CreateRefreshToken(token *model.RefreshToken) error
+	GetRefreshToken(id string) (*model.RefreshToken, error)
+	UseRefreshToken(id string) (bool, error)
+
+	// Sessions
+	CreateSession(session *model.Session) error
+	ListSessions(userID string) ([]model.Session, error)
+	TouchSession(id string, expiresAt time.Time) error
+	RevokeSession(userID, id string) (bool, error)
+	IsSessionRevoked(id string) (bool, error)
 }
 
 // userRepository implements UserRepository interface
@@ -62,6 +87,29 @@ func (r *userRepository) Create(user *model.User) error {
 	return nil
 }
 
+// CreateBatch creates all users in one transaction, or none of them
+func (r *userRepository) CreateBatch(users []*model.User) error {
+	for _, user := range users {
+		if user.Password == "" {
+			continue
+		}
+		hashedPassword, err := r.hashPassword(user.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		user.Password = hashedPassword
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			if err := tx.Create(user).Error; err != nil {
+				return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetByID retrieves a user by ID
 func (r *userRepository) GetByID(id string) (*model.User, error) {
 	var user model.User
@@ -256,6 +304,211 @@ func (r *userRepository) IsEmailExists(email string) bool {
 	return count > 0
 }
 
+// GetIdentity retrieves the link for an external account, or nil if it is not linked
+func (r *userRepository) GetIdentity(provider, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// CreateIdentity links an external account to a user
+func (r *userRepository) CreateIdentity(identity *model.UserIdentity) error {
+	if err := r.db.Create(identity).Error; err != nil {
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+
+	return nil
+}
+
+// ListWebAuthnCredentials returns a user's enrolled credentials, oldest first
+func (r *userRepository) ListWebAuthnCredentials(userID string) ([]model.WebAuthnCredential, error) {
+	var credentials []model.WebAuthnCredential
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("failed to list webauthn credentials: %w", err)
+	}
+
+	return credentials, nil
+}
+
+// GetWebAuthnCredential retrieves a credential by its authenticator-assigned ID, or nil if unknown
+func (r *userRepository) GetWebAuthnCredential(credentialID []byte) (*model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+	if err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get webauthn credential: %w", err)
+	}
+
+	return &credential, nil
+}
+
+// CreateWebAuthnCredential stores a newly enrolled credential
+func (r *userRepository) CreateWebAuthnCredential(credential *model.WebAuthnCredential) error {
+	if err := r.db.Create(credential).Error; err != nil {
+		return fmt.Errorf("failed to create webauthn credential: %w", err)
+	}
+
+	return nil
+}
+
+// RecordWebAuthnLogin saves the counters of a verified login. It reports
+// false when challenge was already used, so a replayed assertion is rejected
+// even when another replica verified it concurrently
+func (r *userRepository) RecordWebAuthnLogin(credential *model.WebAuthnCredential, challenge string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&model.WebAuthnCredential{}).
+		Where("id = ? AND last_challenge <> ?", credential.ID, challenge).
+		Updates(map[string]interface{}{
+			"sign_count":     credential.SignCount,
+			"backup_state":   credential.BackupState,
+			"last_challenge": challenge,
+			"last_used_at":   now,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update webauthn credential: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	credential.LastChallenge = challenge
+	credential.LastUsedAt = &now
+	return true, nil
+}
+
+// DeleteWebAuthnCredential removes one of a user's credentials
+func (r *userRepository) DeleteWebAuthnCredential(userID string, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebAuthnCredential{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webauthn credential: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("webauthn credential not found")
+	}
+
+	return nil
+}
+
+// CreateRefreshToken stores an issued refresh token and drops the user's
+// expired ones
+func (r *userRepository) CreateRefreshToken(token *model.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at < ?", token.UserID, time.Now()).
+			Delete(&model.RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to prune refresh tokens: %w", err)
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetRefreshToken retrieves a refresh token by ID, or nil if it is unknown
+func (r *userRepository) GetRefreshToken(id string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.Where("id = ?", id).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// UseRefreshToken marks a refresh token used. It reports false when the
+// token is unknown, already used or revoked, so concurrent refreshes with
+// the same token cannot both succeed
+func (r *userRepository) UseRefreshToken(id string) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use refresh token: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateSession stores a new login session and drops the user's expired ones
+func (r *userRepository) CreateSession(session *model.Session) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at < ?", session.UserID, time.Now()).
+			Delete(&model.Session{}).Error; err != nil {
+			return fmt.Errorf("failed to prune sessions: %w", err)
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListSessions returns a user's active sessions, most recently started first
+func (r *userRepository) ListSessions(userID string) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records a token refresh, which extends the session to the
+// expiry of the new refresh token
+func (r *userRepository) TouchSession(id string, expiresAt time.Time) error {
+	if err := r.db.Model(&model.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession revokes a user's session together with its refresh token
+// family. It reports false when the user has no such active session; the
+// family is revoked either way, covering logins from before sessions were tracked
+func (r *userRepository) RevokeSession(userID, id string) (bool, error) {
+	var revoked bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke session: %w", result.Error)
+		}
+		revoked = result.RowsAffected > 0
+
+		if err := tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, id).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+// IsSessionRevoked reports whether a session was revoked; unknown sessions are not
+func (r *userRepository) IsSessionRevoked(id string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NOT NULL", id).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return count > 0, nil
+}
+
 // hashPassword hashes a password using bcrypt
 func (r *userRepository) hashPassword(password string) (string, error) {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+)
+
+// ErrInvalidAPIKey is returned for unknown, revoked or expired keys
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// CreateAPIKeyRequest represents create API key request
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	DeviceIDs []string   `json:"device_ids"` // empty allows every device the owner can use
+	ExpiresAt *time.Time `json:"expires_at"` // nil never expires
+}
+
+// APIKeyService handles API key business logic
+type APIKeyService struct {
+	apiKeyRepo    repository.APIKeyRepository
+	userRepo      repository.UserRepository
+	deviceService *DeviceService
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, userRepo repository.UserRepository, deviceService *DeviceService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:    apiKeyRepo,
+		userRepo:      userRepo,
+		deviceService: deviceService,
+	}
+}
+
+// CreateAPIKey creates a key for userID and returns it with the plaintext
+// key, which is not stored and cannot be retrieved again
+func (s *APIKeyService) CreateAPIKey(userID string, req *CreateAPIKeyRequest) (*model.APIKey, string, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if scope != model.APIKeyScopeRead && scope != model.APIKeyScopeExecute {
+			return nil, "", fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", errors.New("expiry must be in the future")
+	}
+	for _, deviceID := range req.DeviceIDs {
+		hasPermission, err := s.deviceService.CheckUserDevicePermission(userID, deviceID, "user")
+		if err != nil {
+			return nil, "", err
+		}
+		if !hasPermission {
+			s.deviceService.RecordAccessDenied(userID, deviceID, "user", "create_api_key")
+			return nil, "", fmt.Errorf("no access to device: %s", deviceID)
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := model.APIKeyPrefix + hex.EncodeToString(secret)
+
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    plaintext[:len(model.APIKeyPrefix)+8],
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    req.Scopes,
+		DeviceIDs: req.DeviceIDs,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+// ListAPIKeys returns the unrevoked keys of a user
+func (s *APIKeyService) ListAPIKeys(userID string) ([]*model.APIKey, error) {
+	return s.apiKeyRepo.ListByUser(userID)
+}
+
+// RevokeAPIKey revokes one of the user's keys
+func (s *APIKeyService) RevokeAPIKey(userID, keyID string) error {
+	return s.apiKeyRepo.Delete(userID, keyID)
+}
+
+// AuthenticateAPIKey resolves a plaintext key to the key and its active
+// owner, and records the use
+func (s *APIKeyService) AuthenticateAPIKey(plaintext string) (*model.APIKey, *model.User, error) {
+	key, err := s.apiKeyRepo.GetByHash(hashAPIKey(plaintext))
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || key.IsExpired() {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil || user.Status != "active" {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	key.LastUsedAt = &now
+	if err := s.apiKeyRepo.UpdateLastUsed(key.ID, now); err != nil {
+		log.Printf("Failed to record use of API key %s: %v", key.ID, err)
+	}
+
+	return key, user, nil
+}
+
+// hashAPIKey hashes a key for storage. Keys are random, so a fast hash suffices
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
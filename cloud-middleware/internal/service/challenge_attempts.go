@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+)
+
+// maxChallengeFailures is how many wrong codes a login challenge survives
+// before the user has to sign in again
+const maxChallengeFailures = 5
+
+// challengeAttempts counts the wrong codes presented for each login challenge
+type challengeAttempts struct {
+	mutex    sync.Mutex
+	failures map[string]*challengeFailures // keyed by challenge ID
+}
+
+type challengeFailures struct {
+	count     int
+	expiresAt time.Time
+}
+
+func newChallengeAttempts() *challengeAttempts {
+	return &challengeAttempts{failures: make(map[string]*challengeFailures)}
+}
+
+// exhausted reports whether the challenge has used up its attempts
+func (a *challengeAttempts) exhausted(challenge *auth.Challenge) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	failures, ok := a.failures[challenge.ID]
+	return ok && failures.count >= maxChallengeFailures
+}
+
+// fail records a wrong code for the challenge and reports whether it is now
+// exhausted. Counts of expired challenges are dropped on the way
+func (a *challengeAttempts) fail(challenge *auth.Challenge) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	for id, failures := range a.failures {
+		if now.After(failures.expiresAt) {
+			delete(a.failures, id)
+		}
+	}
+
+	failures, ok := a.failures[challenge.ID]
+	if !ok {
+		failures = &challengeFailures{expiresAt: challenge.ExpiresAt}
+		a.failures[challenge.ID] = failures
+	}
+	failures.count++
+	return failures.count >= maxChallengeFailures
+}
+
+// forget drops the count of a challenge that was completed
+func (a *challengeAttempts) forget(challenge *auth.Challenge) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.failures, challenge.ID)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// SyncDeviceCommands pushes the commands stored for a device to its agent,
+// along with the IDs of deleted ones, bounded by ctx. The agent skips the sync
+// when the command set hashes the same as the last one it applied
+func (gs *GatewayService) SyncDeviceCommands(ctx context.Context, deviceID string) (*controllerPb.SyncCommandsResponse, error) {
+	if gs.deviceService == nil {
+		return nil, fmt.Errorf("device commands are not available")
+	}
+
+	client, err := gs.GetDeviceClient(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// One sync per device at a time, so an older command set never lands after a newer one
+	lock := gs.syncLock(deviceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	req, err := gs.syncRequest(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := gs.callContext(ctx)
+	defer cancel()
+
+	return client.SyncCommands(ctx, req)
+}
+
+// SyncDeviceCommandsAsync syncs a connected device's commands in the
+// background when syncing is enabled; failures are logged
+func (gs *GatewayService) SyncDeviceCommandsAsync(deviceID string) {
+	if !gs.config.SyncCommands || gs.deviceService == nil {
+		return
+	}
+	if _, err := gs.GetDeviceClient(deviceID); err != nil {
+		return
+	}
+
+	go gs.syncDeviceCommands(deviceID)
+}
+
+// syncDeviceCommands syncs a device's commands and logs the outcome
+func (gs *GatewayService) syncDeviceCommands(deviceID string) {
+	resp, err := gs.SyncDeviceCommands(context.Background(), deviceID)
+	if err != nil {
+		log.Printf("Failed to sync commands to device %s: %v", deviceID, err)
+		return
+	}
+	if !resp.Skipped {
+		log.Printf("Synced commands to device %s: %d created, %d updated, %d removed",
+			deviceID, resp.Created, resp.Updated, resp.Removed)
+	}
+}
+
+// onDeviceConnected imports the commands of a newly connected device and then
+// syncs the cloud's commands back to it, as configured. Commands queued while
+// the device was offline are then delivered in the background
+func (gs *GatewayService) onDeviceConnected(deviceID string) {
+	if gs.deviceService == nil {
+		return
+	}
+
+	if gs.config.AutoImportCommands {
+		imported, err := gs.importDeviceCommands(deviceID)
+		if err != nil {
+			log.Printf("Failed to import commands from device %s after %d imported: %v", deviceID, imported, err)
+		} else if imported > 0 {
+			log.Printf("Imported %d commands from device %s", imported, deviceID)
+		}
+	}
+
+	if gs.config.SyncCommands {
+		gs.syncDeviceCommands(deviceID)
+	}
+
+	go gs.deliverPendingExecutions(deviceID)
+}
+
+// syncLock returns the lock serializing command syncs of a device
+func (gs *GatewayService) syncLock(deviceID string) *sync.Mutex {
+	lock, _ := gs.syncLocks.LoadOrStore(deviceID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// syncRequest builds the sync request of a device's current commands. Commands
+// and removed IDs are sorted so the hash only changes with their content
+func (gs *GatewayService) syncRequest(deviceID string) (*controllerPb.SyncCommandsRequest, error) {
+	commands, err := gs.deviceService.GetDeviceCommands(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device commands: %w", err)
+	}
+	removed, err := gs.deviceService.GetRemovedDeviceCommandIDs(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted device commands: %w", err)
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].CommandID < commands[j].CommandID
+	})
+	sort.Strings(removed)
+
+	req := &controllerPb.SyncCommandsRequest{
+		Commands:   make([]*controllerPb.CommandInfo, 0, len(commands)),
+		RemovedIds: removed,
+	}
+	for _, cmd := range commands {
+		req.Commands = append(req.Commands, &controllerPb.CommandInfo{
+			Id:              cmd.CommandID,
+			Name:            cmd.Name,
+			Description:     cmd.Description,
+			Category:        cmd.Category,
+			Icon:            cmd.Icon,
+			PlatformCommand: cmd.Command,
+			Platform:        cmd.Platform,
+			CommandType:     cmd.CommandType,
+			Timeout:         int32(cmd.Timeout),
+			RequiresPin:     cmd.RequiresPin,
+			Whitelisted:     cmd.Whitelisted,
+			AdminOnly:       cmd.AdminOnly,
+			WorkingDir:      cmd.WorkingDir,
+			Env:             cmd.Env,
+		})
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash device commands: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	req.Hash = hex.EncodeToString(sum[:])
+
+	return req, nil
+}
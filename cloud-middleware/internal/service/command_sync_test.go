@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// mockDevice is an agent reporting a fixed command list. ListCommands waits
+// for release, so tests can tell whether a caller blocks on it.
+// ExecuteCommand succeeds after execDelay, tracking how many run at once
+type mockDevice struct {
+	controllerPb.UnimplementedControllerServiceServer
+	release chan struct{}
+	listed  atomic.Int32
+
+	execDelay  time.Duration
+	running    atomic.Int32
+	maxRunning atomic.Int32
+
+	callerRole atomic.Value // caller_role of the latest call
+}
+
+func (d *mockDevice) ListCommands(ctx context.Context, req *controllerPb.ListCommandsRequest) (*controllerPb.ListCommandsResponse, error) {
+	d.listed.Add(1)
+	d.callerRole.Store(req.CallerRole)
+	select {
+	case <-d.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &controllerPb.ListCommandsResponse{Commands: []*controllerPb.CommandInfo{
+		{Id: "uptime", Name: "Uptime", PlatformCommand: "uptime", Timeout: 10},
+		{Id: "reboot", PlatformCommand: "reboot", RequiresPin: true},
+	}}, nil
+}
+
+func (d *mockDevice) ExecuteCommand(ctx context.Context, req *controllerPb.ExecuteCommandRequest) (*controllerPb.ExecuteCommandResponse, error) {
+	d.callerRole.Store(req.CallerRole)
+	running := d.running.Add(1)
+	defer d.running.Add(-1)
+	for {
+		max := d.maxRunning.Load()
+		if running <= max || d.maxRunning.CompareAndSwap(max, running) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(d.execDelay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &controllerPb.ExecuteCommandResponse{Success: true, Output: req.CommandId}, nil
+}
+
+// startMockDevice serves d on a local port and returns its address
+func startMockDevice(t *testing.T, d *mockDevice) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	controllerPb.RegisterControllerServiceServer(server, d)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// waitFor polls cond until it holds or a few seconds pass
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAutoImportCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		autoImport bool
+		existing   []*model.DeviceCommand
+		wantListed bool
+		wantIDs    []string
+	}{
+		{"imports on first connect", true, nil, true, []string{"reboot", "uptime"}},
+		{"keeps commands already stored", true, []*model.DeviceCommand{{CommandID: "cloud-cmd", Name: "Cloud", Command: "true"}}, true, []string{"cloud-cmd"}},
+		{"disabled", false, nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDeviceService(t, testDevice("dev-1"))
+			for _, cmd := range tt.existing {
+				cmd.DeviceID = "dev-1"
+				if err := ds.CreateDeviceCommand(cmd); err != nil {
+					t.Fatalf("create command: %v", err)
+				}
+			}
+			device := &mockDevice{release: make(chan struct{})}
+			close(device.release)
+			address := startMockDevice(t, device)
+
+			gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true, AutoImportCommands: tt.autoImport}, ds)
+			if err != nil {
+				t.Fatalf("create gateway service: %v", err)
+			}
+			if err := gs.connectDevice("dev-1", []string{address}); err != nil {
+				t.Fatalf("connect: %v", err)
+			}
+			defer gs.RemoveDevice("dev-1")
+
+			gs.onDeviceConnected("dev-1")
+
+			if listed := device.listed.Load() > 0; listed != tt.wantListed {
+				t.Fatalf("device asked for its commands = %v, want %v", listed, tt.wantListed)
+			}
+			if role := device.callerRole.Load(); tt.wantListed && role != ownerCallerRole {
+				t.Errorf("import caller role = %v, want %s", role, ownerCallerRole)
+			}
+			commands, err := ds.GetDeviceCommands("dev-1")
+			if err != nil {
+				t.Fatalf("get commands: %v", err)
+			}
+			got := make(map[string]bool, len(commands))
+			for _, cmd := range commands {
+				got[cmd.CommandID] = true
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("stored commands %v, want %v", got, tt.wantIDs)
+			}
+			for _, id := range tt.wantIDs {
+				if !got[id] {
+					t.Fatalf("stored commands %v, want %v", got, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestAddDeviceImportsInBackground(t *testing.T) {
+	ds := newTestDeviceService(t, testDevice("dev-1"))
+	device := &mockDevice{release: make(chan struct{})}
+	address := startMockDevice(t, device)
+
+	gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true, AutoImportCommands: true}, ds)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	defer gs.RemoveDevice("dev-1")
+
+	// The device has not answered ListCommands yet, so AddDevice would hang
+	// if it imported before returning
+	added := make(chan error, 1)
+	go func() { added <- gs.AddDevice("dev-1", []string{address}) }()
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("AddDevice() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(device.release)
+		t.Fatalf("AddDevice waited for the command import")
+	}
+
+	waitFor(t, "the import to start", func() bool { return device.listed.Load() > 0 })
+	close(device.release)
+	waitFor(t, "the commands to be imported", func() bool {
+		commands, err := ds.GetDeviceCommands("dev-1")
+		return err == nil && len(commands) == 2
+	})
+}
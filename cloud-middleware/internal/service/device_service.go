@@ -1,9 +1,18 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/events"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
 )
@@ -11,8 +20,43 @@ import (
 // DeviceService handles device-related business logic
 type DeviceService struct {
 	deviceRepo repository.DeviceRepository
+
+	blockedPatterns   []string
+	accessRequestHook AccessRequestHook
+	eventBus          *events.Bus // nil when events are not published
 }
 
+// AccessRequestHook is told about a new pending binding along with the IDs of
+// the device owners who can approve it
+type AccessRequestHook func(request *model.UserDevice, ownerIDs []string)
+
+// ErrDangerousCommand is returned for command strings matching a blocked pattern
+var ErrDangerousCommand = errors.New("potentially dangerous command detected")
+
+// Binding workflow errors
+var (
+	ErrAlreadyBound       = errors.New("user is already bound to the device")
+	ErrNotDeviceOwner     = errors.New("only device owners can bind other users")
+	ErrOwnershipRequested = errors.New("the owner role cannot be requested")
+	ErrNoAccessRequest    = errors.New("no pending access request")
+)
+
+// Favorite command errors
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	ErrNoFavorite      = errors.New("command is not a favorite")
+)
+
+// Pending execution errors
+var (
+	ErrPendingExecutionNotFound = errors.New("pending execution not found")
+	ErrExecutionNotPending      = errors.New("execution is no longer pending")
+)
+
+// tunnelTokenBytes is the entropy of device tunnel tokens, which are hex
+// encoded to twice as many characters
+const tunnelTokenBytes = 32
+
 // NewDeviceService creates a new device service
 func NewDeviceService(deviceRepo repository.DeviceRepository) *DeviceService {
 	return &DeviceService{
@@ -20,6 +64,60 @@ func NewDeviceService(deviceRepo repository.DeviceRepository) *DeviceService {
 	}
 }
 
+// SetBlockedPatterns sets the command fragments CheckCommandSafety rejects
+func (ds *DeviceService) SetBlockedPatterns(patterns []string) {
+	ds.blockedPatterns = patterns
+}
+
+// SetAccessRequestHook sets the hook told about new access requests, on top of the log line
+func (ds *DeviceService) SetAccessRequestHook(hook AccessRequestHook) {
+	ds.accessRequestHook = hook
+}
+
+// SetEventBus sets the bus device online/offline transitions and command
+// executions are published to
+func (ds *DeviceService) SetEventBus(bus *events.Bus) {
+	ds.eventBus = bus
+}
+
+// publishStatus publishes a device's transition to online or offline
+func (ds *DeviceService) publishStatus(deviceID string, online bool) {
+	if ds.eventBus == nil {
+		return
+	}
+
+	eventType := events.DeviceOffline
+	if online {
+		eventType = events.DeviceOnline
+	}
+	ds.eventBus.Publish(events.Event{Type: eventType, DeviceID: deviceID})
+}
+
+// publishExecution publishes the outcome of a command executed on a device
+func (ds *DeviceService) publishExecution(deviceID, commandID string, success bool) {
+	if ds.eventBus == nil {
+		return
+	}
+	ds.eventBus.Publish(events.Event{
+		Type:      events.CommandExecuted,
+		DeviceID:  deviceID,
+		CommandID: commandID,
+		Success:   &success,
+	})
+}
+
+// CheckCommandSafety rejects a command string containing a blocked pattern,
+// matched case-insensitively
+func (ds *DeviceService) CheckCommandSafety(command string) error {
+	lower := strings.ToLower(command)
+	for _, pattern := range ds.blockedPatterns {
+		if pattern != "" && strings.Contains(lower, strings.ToLower(pattern)) {
+			return fmt.Errorf("%w: %s", ErrDangerousCommand, pattern)
+		}
+	}
+	return nil
+}
+
 // RegisterDevice registers a new device
 func (ds *DeviceService) RegisterDevice(userID, deviceID, deviceName, deviceType, platform, agentVersion string, systemInfo map[string]interface{}) (*model.Device, error) {
 	// Check if device already exists
@@ -63,24 +161,116 @@ func (ds *DeviceService) RegisterDevice(userID, deviceID, deviceName, deviceType
 		return nil, fmt.Errorf("failed to bind device to user: %w", err)
 	}
 
+	ds.recordAccessChange(deviceID, userID, userID, model.AccessActionGranted, "", userDevice.Role)
+
 	return device, nil
 }
 
-// BindDeviceToUser binds an existing device to a user
-func (ds *DeviceService) BindDeviceToUser(userID, deviceID, role string) error {
+// EnrollDevice creates or refreshes a device that registered itself. A new
+// device is owned by ownerID; a known one keeps its owners and has its name,
+// platform, version, address and system information updated. It reports
+// whether the device was created
+func (ds *DeviceService) EnrollDevice(ownerID, deviceID, deviceName, platform, agentVersion, address string, systemInfo map[string]interface{}) (*model.Device, bool, error) {
+	if deviceName == "" {
+		deviceName = deviceID
+	}
+
+	device, err := ds.deviceRepo.GetByID(deviceID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get device: %w", err)
+	}
+	if device == nil {
+		device, err = ds.RegisterDevice(ownerID, deviceID, deviceName, "desktop", platform, agentVersion, systemInfo)
+		if err != nil {
+			return nil, false, err
+		}
+		if address != "" {
+			device.IPAddress = address
+			if err := ds.deviceRepo.Update(device); err != nil {
+				return nil, false, fmt.Errorf("failed to update device: %w", err)
+			}
+		}
+		ds.publishStatus(deviceID, true)
+		return device, true, nil
+	}
+
+	wasOnline := device.Online
+	device.DeviceName = deviceName
+	device.Platform = platform
+	device.AgentVersion = agentVersion
+	device.IPAddress = address
+	device.SystemInfo = systemInfo
+	device.Online = true
+	device.LastSeen = time.Now()
+
+	if err := ds.deviceRepo.Update(device); err != nil {
+		return nil, false, fmt.Errorf("failed to update device: %w", err)
+	}
+	if !wasOnline {
+		ds.publishStatus(deviceID, true)
+	}
+	return device, false, nil
+}
+
+// IssueTunnelToken generates a new tunnel access token for a device and stores
+// its hash, revoking the previous token. The token is only returned here
+func (ds *DeviceService) IssueTunnelToken(deviceID string) (string, error) {
+	secret := make([]byte, tunnelTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate tunnel token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := ds.deviceRepo.SetTunnelTokenHash(deviceID, hashTunnelToken(token)); err != nil {
+		return "", fmt.Errorf("failed to store tunnel token: %w", err)
+	}
+	return token, nil
+}
+
+// VerifyTunnelToken reports whether token is the tunnel access token last
+// issued to device. Devices never issued one accept no token
+func (ds *DeviceService) VerifyTunnelToken(device *model.Device, token string) bool {
+	if device.TunnelTokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashTunnelToken(token)), []byte(device.TunnelTokenHash)) == 1
+}
+
+// hashTunnelToken hashes a tunnel token for storage. Tokens are random, so a
+// fast hash suffices
+func hashTunnelToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BindDeviceToUser binds an existing device to a user on behalf of actorID.
+// A device owner's binding is active at once. Anyone else can only ask for
+// themselves, which creates a pending binding a device owner must approve
+func (ds *DeviceService) BindDeviceToUser(actorID, userID, deviceID, role string) (*model.UserDevice, error) {
 	// Check if device exists
 	device, err := ds.deviceRepo.GetByID(deviceID)
 	if err != nil {
-		return fmt.Errorf("device not found: %w", err)
+		return nil, fmt.Errorf("device not found: %w", err)
 	}
 	if device == nil {
-		return fmt.Errorf("device %s does not exist", deviceID)
+		return nil, fmt.Errorf("device %s does not exist", deviceID)
 	}
 
-	// Check if user is already bound to this device
+	isOwner, err := ds.CheckUserDevicePermission(actorID, deviceID, "owner")
+	if err != nil {
+		return nil, err
+	}
+	if !isOwner && actorID != userID {
+		return nil, ErrNotDeviceOwner
+	}
+	if !isOwner && role == "owner" {
+		return nil, ErrOwnershipRequested
+	}
+
+	// Check if user is already bound to this device, pending requests included
 	userDevice, err := ds.deviceRepo.GetUserDevice(userID, deviceID)
 	if err == nil && userDevice != nil {
-		return fmt.Errorf("user %s is already bound to device %s", userID, deviceID)
+		return nil, fmt.Errorf("%w: %s is %s on device %s", ErrAlreadyBound, userID, userDevice.Status, deviceID)
 	}
 
 	// Create user-device relationship
@@ -88,15 +278,181 @@ func (ds *DeviceService) BindDeviceToUser(userID, deviceID, role string) error {
 		UserID:   userID,
 		DeviceID: deviceID,
 		Role:     role,
-		Status:   "active",
+		Status:   model.UserDeviceStatusActive,
+	}
+	if !isOwner {
+		userDevice.Status = model.UserDeviceStatusPending
+	}
+
+	if err := ds.deviceRepo.CreateUserDevice(userDevice); err != nil {
+		return nil, err
+	}
+
+	if isOwner {
+		ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionGranted, "", role)
+	} else {
+		ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionRequested, "", role)
+		ds.notifyAccessRequest(userDevice)
+	}
+	return userDevice, nil
+}
+
+// ListAccessRequests returns the pending bindings of a device, oldest first
+func (ds *DeviceService) ListAccessRequests(deviceID string) ([]*repository.AccessAuditEntry, error) {
+	return ds.deviceRepo.ListDeviceMembers(deviceID, model.UserDeviceStatusPending)
+}
+
+// ApproveAccessRequest activates a user's pending binding on behalf of actorID, a device owner
+func (ds *DeviceService) ApproveAccessRequest(actorID, userID, deviceID string) (*model.UserDevice, error) {
+	userDevice, err := ds.pendingUserDevice(userID, deviceID)
+	if err != nil {
+		return nil, err
 	}
 
-	return ds.deviceRepo.CreateUserDevice(userDevice)
+	userDevice.Status = model.UserDeviceStatusActive
+	if err := ds.deviceRepo.UpdateUserDevice(userDevice); err != nil {
+		return nil, fmt.Errorf("failed to approve access request: %w", err)
+	}
+
+	ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionGranted, "", userDevice.Role)
+	return userDevice, nil
 }
 
-// UnbindDeviceFromUser removes the binding between a user and a device
-func (ds *DeviceService) UnbindDeviceFromUser(userID, deviceID string) error {
-	return ds.deviceRepo.DeleteUserDevice(userID, deviceID)
+// RejectAccessRequest drops a user's pending binding on behalf of actorID, a device owner
+func (ds *DeviceService) RejectAccessRequest(actorID, userID, deviceID string) error {
+	userDevice, err := ds.pendingUserDevice(userID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.deviceRepo.DeleteUserDevice(userID, deviceID); err != nil {
+		return fmt.Errorf("failed to reject access request: %w", err)
+	}
+
+	ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionRejected, userDevice.Role, "")
+	return nil
+}
+
+// pendingUserDevice returns a user's binding to a device, which must be pending
+func (ds *DeviceService) pendingUserDevice(userID, deviceID string) (*model.UserDevice, error) {
+	userDevice, err := ds.deviceRepo.GetUserDevice(userID, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user device: %w", err)
+	}
+	if userDevice == nil || userDevice.Status != model.UserDeviceStatusPending {
+		return nil, fmt.Errorf("%w from user %s on device %s", ErrNoAccessRequest, userID, deviceID)
+	}
+	return userDevice, nil
+}
+
+// notifyAccessRequest logs a new access request and passes it to the hook
+// with the owners who can approve it
+func (ds *DeviceService) notifyAccessRequest(request *model.UserDevice) {
+	log.Printf("User %s requested %s access to device %s, awaiting owner approval", request.UserID, request.Role, request.DeviceID)
+
+	if ds.accessRequestHook == nil {
+		return
+	}
+
+	members, err := ds.deviceRepo.ListDeviceMembers(request.DeviceID, model.UserDeviceStatusActive)
+	if err != nil {
+		log.Printf("Failed to find owners of device %s to notify: %v", request.DeviceID, err)
+		return
+	}
+	var ownerIDs []string
+	for _, member := range members {
+		if member.Role == "owner" {
+			ownerIDs = append(ownerIDs, member.UserID)
+		}
+	}
+
+	ds.accessRequestHook(request, ownerIDs)
+}
+
+// UpdateUserDeviceRole changes a user's role on a device on behalf of actorID
+func (ds *DeviceService) UpdateUserDeviceRole(actorID, userID, deviceID, role string) error {
+	userDevice, err := ds.deviceRepo.GetUserDevice(userID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get user device: %w", err)
+	}
+	if userDevice == nil {
+		return fmt.Errorf("user %s is not bound to device %s", userID, deviceID)
+	}
+
+	if userDevice.Role == role {
+		return nil
+	}
+
+	oldRole := userDevice.Role
+	userDevice.Role = role
+	if err := ds.deviceRepo.UpdateUserDevice(userDevice); err != nil {
+		return fmt.Errorf("failed to update user device role: %w", err)
+	}
+
+	ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionRoleChanged, oldRole, role)
+	return nil
+}
+
+// UnbindDeviceFromUser removes the binding between a user and a device on behalf of actorID
+func (ds *DeviceService) UnbindDeviceFromUser(actorID, userID, deviceID string) error {
+	userDevice, err := ds.deviceRepo.GetUserDevice(userID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get user device: %w", err)
+	}
+	if userDevice == nil {
+		return fmt.Errorf("user %s is not bound to device %s", userID, deviceID)
+	}
+
+	if err := ds.deviceRepo.DeleteUserDevice(userID, deviceID); err != nil {
+		return err
+	}
+
+	ds.recordAccessChange(deviceID, userID, actorID, model.AccessActionRevoked, userDevice.Role, "")
+	return nil
+}
+
+// GetDeviceAccessHistory returns the access history of a device, newest first
+func (ds *DeviceService) GetDeviceAccessHistory(deviceID string, limit int) ([]*model.UserDeviceHistory, error) {
+	return ds.deviceRepo.GetUserDeviceHistory(deviceID, limit)
+}
+
+// recordAccessChange appends to the access history; failures are logged
+// rather than undoing the change they describe
+func (ds *DeviceService) recordAccessChange(deviceID, userID, actorID, action, oldRole, newRole string) {
+	entry := &model.UserDeviceHistory{
+		DeviceID: deviceID,
+		UserID:   userID,
+		Action:   action,
+		OldRole:  oldRole,
+		NewRole:  newRole,
+		ActorID:  actorID,
+	}
+
+	if err := ds.deviceRepo.CreateUserDeviceHistory(entry); err != nil {
+		log.Printf("Failed to record access change for device %s user %s: %v", deviceID, userID, err)
+	}
+}
+
+// RecordAccessDenied records that a user was refused action on a device for
+// lacking requiredRole; failures are logged rather than returned so the
+// denial itself is still reported to the caller
+func (ds *DeviceService) RecordAccessDenied(userID, deviceID, requiredRole, action string) {
+	log.Printf("Access denied: user %s lacks %s role on device %s for %s", userID, requiredRole, deviceID, action)
+
+	entry := &model.AccessLog{
+		UserID:       userID,
+		DeviceID:     deviceID,
+		RequiredRole: requiredRole,
+		Action:       action,
+	}
+	if err := ds.deviceRepo.CreateAccessLog(entry); err != nil {
+		log.Printf("Failed to record access denial for device %s user %s: %v", deviceID, userID, err)
+	}
+}
+
+// ListAccessLogs returns denied accesses across all devices, newest first
+func (ds *DeviceService) ListAccessLogs(filter repository.AccessLogFilter, offset, limit int) ([]*model.AccessLog, int64, error) {
+	return ds.deviceRepo.ListAccessLogs(filter, offset, limit)
 }
 
 // GetUserDevices returns all devices associated with a user
@@ -115,11 +471,21 @@ func (ds *DeviceService) UpdateDeviceStatus(deviceID string, online bool) error
 	if err != nil {
 		return fmt.Errorf("device not found: %w", err)
 	}
+	if device == nil {
+		return fmt.Errorf("device %s does not exist", deviceID)
+	}
 
+	wasOnline := device.Online
 	device.Online = online
 	device.LastSeen = time.Now()
 
-	return ds.deviceRepo.Update(device)
+	if err := ds.deviceRepo.Update(device); err != nil {
+		return err
+	}
+	if wasOnline != online {
+		ds.publishStatus(deviceID, online)
+	}
+	return nil
 }
 
 // UpdateDeviceInfo updates device information
@@ -209,25 +575,113 @@ func (ds *DeviceService) CheckUserDevicePermission(userID, deviceID, requiredRol
 	return userRoleLevel >= requiredRoleLevel, nil
 }
 
+// UserDeviceRole returns the role of a user's active binding to a device, or
+// "" without one. The gateway names it to the agent, which runs and lists
+// admin-only commands only for device admins and owners
+func (ds *DeviceService) UserDeviceRole(userID, deviceID string) (string, error) {
+	userDevice, err := ds.deviceRepo.GetUserDevice(userID, deviceID)
+	if err != nil || userDevice == nil || userDevice.Status != model.UserDeviceStatusActive {
+		return "", err
+	}
+	return userDevice.Role, nil
+}
+
+// CommandExecuteRole returns the device role required to execute a command:
+// admin for commands marked admin-only, user otherwise
+func (ds *DeviceService) CommandExecuteRole(deviceID, commandID string) (string, error) {
+	command, err := ds.deviceRepo.GetDeviceCommand(deviceID, commandID)
+	if err != nil {
+		return "", err
+	}
+	if command != nil && command.AdminOnly {
+		return "admin", nil
+	}
+	return "user", nil
+}
+
+// VisibleCommands leaves out the admin-only commands unless the user is an
+// admin or owner of the device
+func (ds *DeviceService) VisibleCommands(userID, deviceID string, commands []*model.DeviceCommand) ([]*model.DeviceCommand, error) {
+	isAdmin, err := ds.CheckUserDevicePermission(userID, deviceID, "admin")
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		return commands, nil
+	}
+
+	visible := make([]*model.DeviceCommand, 0, len(commands))
+	for _, command := range commands {
+		if !command.AdminOnly {
+			visible = append(visible, command)
+		}
+	}
+	return visible, nil
+}
+
 // GetAllDevices returns all devices (admin function)
 func (ds *DeviceService) GetAllDevices() ([]*model.Device, error) {
 	return ds.deviceRepo.GetAll()
 }
 
+// ListDevices returns devices matching the filter with pagination
+func (ds *DeviceService) ListDevices(filter repository.DeviceFilter, offset, limit int) ([]*model.Device, int64, error) {
+	return ds.deviceRepo.List(filter, offset, limit)
+}
+
+// ListAccessAudit returns the users bound to every device with their roles, paginated
+func (ds *DeviceService) ListAccessAudit(role string, offset, limit int) ([]*repository.AccessAuditEntry, int64, error) {
+	return ds.deviceRepo.ListAccessAudit(role, offset, limit)
+}
+
 // GetOnlineDevices returns all online devices
 func (ds *DeviceService) GetOnlineDevices() ([]*model.Device, error) {
 	return ds.deviceRepo.GetOnlineDevices()
 }
 
-// UpdateDeviceLastSeen updates the last seen timestamp
+// UpdateDeviceLastSeen records contact from a device, updating its last seen
+// timestamp and marking it online again if the sweeper had marked it offline
 func (ds *DeviceService) UpdateDeviceLastSeen(deviceID string) error {
-	device, err := ds.deviceRepo.GetByID(deviceID)
+	cameOnline, err := ds.deviceRepo.Touch(deviceID, time.Now())
 	if err != nil {
 		return fmt.Errorf("device not found: %w", err)
 	}
+	if cameOnline {
+		ds.publishStatus(deviceID, true)
+	}
+	return nil
+}
 
-	device.LastSeen = time.Now()
-	return ds.deviceRepo.Update(device)
+// SweepOfflineDevices marks devices not seen within threshold as offline
+func (ds *DeviceService) SweepOfflineDevices(threshold time.Duration) (int, error) {
+	devices, err := ds.deviceRepo.MarkStaleOffline(time.Now().Add(-threshold))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, device := range devices {
+		log.Printf("Device %s (%s) marked offline: last seen %s ago",
+			device.ID, device.DeviceName, time.Since(device.LastSeen).Round(time.Second))
+		ds.publishStatus(device.ID, false)
+	}
+	return len(devices), nil
+}
+
+// RunOfflineSweeper sweeps for silent devices every interval until ctx is done
+func (ds *DeviceService) RunOfflineSweeper(ctx context.Context, threshold, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := ds.SweepOfflineDevices(threshold); err != nil {
+				log.Printf("Offline device sweep failed: %v", err)
+			}
+		}
+	}
 }
 
 // ===== Command Management Methods =====
@@ -246,6 +700,40 @@ func (ds *DeviceService) CreateDeviceCommand(command *model.DeviceCommand) error
 	return ds.deviceRepo.CreateDeviceCommand(command)
 }
 
+// ImportDeviceCommands stores the commands reported by a device, but only when
+// the device has no commands stored yet. Returns the number of imported commands;
+// if ctx ends first the commands stored so far are kept and counted.
+func (ds *DeviceService) ImportDeviceCommands(ctx context.Context, deviceID string, commands []*model.DeviceCommand) (int, error) {
+	device, err := ds.deviceRepo.GetByID(deviceID)
+	if err != nil {
+		return 0, fmt.Errorf("device not found: %w", err)
+	}
+	if device == nil {
+		return 0, fmt.Errorf("device %s does not exist", deviceID)
+	}
+
+	existing, err := ds.deviceRepo.GetDeviceCommands(deviceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get device commands: %w", err)
+	}
+	if len(existing) > 0 {
+		return 0, nil
+	}
+
+	// Stored one at a time: the database allows a single writer
+	for i, command := range commands {
+		if ctx.Err() != nil {
+			return i, fmt.Errorf("import stopped after %d of %d commands: %w", i, len(commands), ErrBatchTimeout)
+		}
+		command.DeviceID = deviceID
+		if err := ds.deviceRepo.CreateDeviceCommand(command); err != nil {
+			return i, fmt.Errorf("failed to import command %s: %w", command.CommandID, err)
+		}
+	}
+
+	return len(commands), nil
+}
+
 // GetDeviceCommand retrieves a specific command for a device
 func (ds *DeviceService) GetDeviceCommand(deviceID, commandID string) (*model.DeviceCommand, error) {
 	return ds.deviceRepo.GetDeviceCommand(deviceID, commandID)
@@ -256,6 +744,32 @@ func (ds *DeviceService) GetDeviceCommands(deviceID string) ([]*model.DeviceComm
 	return ds.deviceRepo.GetDeviceCommands(deviceID)
 }
 
+// GetRemovedDeviceCommandIDs retrieves the IDs of deleted commands of a device
+// that have not been created again
+func (ds *DeviceService) GetRemovedDeviceCommandIDs(deviceID string) ([]string, error) {
+	deleted, err := ds.deviceRepo.GetDeletedDeviceCommandIDs(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := ds.deviceRepo.GetDeviceCommands(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(current))
+	for _, cmd := range current {
+		existing[cmd.CommandID] = true
+	}
+
+	removed := make([]string, 0, len(deleted))
+	for _, commandID := range deleted {
+		if !existing[commandID] {
+			removed = append(removed, commandID)
+		}
+	}
+	return removed, nil
+}
+
 // GetHomepageCommands retrieves commands that should be shown on homepage
 func (ds *DeviceService) GetHomepageCommands(deviceID string) ([]*model.DeviceCommand, error) {
 	allCommands, err := ds.deviceRepo.GetDeviceCommands(deviceID)
@@ -273,6 +787,113 @@ func (ds *DeviceService) GetHomepageCommands(deviceID string) ([]*model.DeviceCo
 	return homepageCommands, nil
 }
 
+// AddFavoriteCommand adds an existing command of a device to a user's favorites
+func (ds *DeviceService) AddFavoriteCommand(userID, deviceID, commandID string) (*model.UserFavoriteCommand, error) {
+	command, err := ds.deviceRepo.GetDeviceCommand(deviceID, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if command == nil {
+		return nil, ErrCommandNotFound
+	}
+
+	return ds.deviceRepo.AddFavoriteCommand(userID, deviceID, commandID)
+}
+
+// RemoveFavoriteCommand removes a command from a user's favorites
+func (ds *DeviceService) RemoveFavoriteCommand(userID, deviceID, commandID string) error {
+	removed, err := ds.deviceRepo.RemoveFavoriteCommand(userID, deviceID, commandID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNoFavorite
+	}
+	return nil
+}
+
+// GetFavoriteCommands retrieves a user's favorite commands of a device with
+// their full command information
+func (ds *DeviceService) GetFavoriteCommands(userID, deviceID string) ([]*repository.FavoriteCommand, error) {
+	return ds.deviceRepo.GetFavoriteCommands(userID, deviceID)
+}
+
+// QueueExecution queues a command for an offline device. It expires after ttl
+// unless the device reconnects first
+func (ds *DeviceService) QueueExecution(userID, deviceID, commandID string, timeout int32, ttl time.Duration) (*model.PendingExecution, error) {
+	execution := &model.PendingExecution{
+		UserID:    userID,
+		DeviceID:  deviceID,
+		CommandID: commandID,
+		Timeout:   timeout,
+		Status:    model.PendingExecutionPending,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := ds.deviceRepo.CreatePendingExecution(execution); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+// ListPendingExecutions retrieves the executions queued for a device, oldest
+// first. Executions past their expiry are marked expired and left out
+func (ds *DeviceService) ListPendingExecutions(deviceID string) ([]*model.PendingExecution, error) {
+	if expired, err := ds.deviceRepo.ExpirePendingExecutions(time.Now()); err != nil {
+		log.Printf("Failed to expire pending executions: %v", err)
+	} else if expired > 0 {
+		log.Printf("Expired %d pending executions", expired)
+	}
+	return ds.deviceRepo.ListPendingExecutions(deviceID)
+}
+
+// GetPendingExecution retrieves a queued execution of a device, in any status
+func (ds *DeviceService) GetPendingExecution(deviceID, executionID string) (*model.PendingExecution, error) {
+	execution, err := ds.deviceRepo.GetPendingExecution(deviceID, executionID)
+	if err != nil {
+		return nil, err
+	}
+	if execution == nil {
+		return nil, ErrPendingExecutionNotFound
+	}
+	return execution, nil
+}
+
+// CancelPendingExecution cancels an execution that has not been delivered yet
+func (ds *DeviceService) CancelPendingExecution(deviceID, executionID string) error {
+	cancelled, err := ds.deviceRepo.CancelPendingExecution(deviceID, executionID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrExecutionNotPending
+	}
+	return nil
+}
+
+// claimPendingExecution marks a pending execution as being delivered, so it
+// runs only once. It reports false when the execution is no longer pending
+func (ds *DeviceService) claimPendingExecution(executionID string) (bool, error) {
+	return ds.deviceRepo.ClaimPendingExecution(executionID)
+}
+
+// completePendingExecution stores the outcome of a delivered execution and
+// records it in the execution log
+func (ds *DeviceService) completePendingExecution(execution *model.PendingExecution, duration int64) error {
+	if err := ds.deviceRepo.CompletePendingExecution(execution); err != nil {
+		return err
+	}
+	return ds.deviceRepo.CreateExecutionLog(&model.ExecutionLog{
+		UserID:    execution.UserID,
+		DeviceID:  execution.DeviceID,
+		CommandID: execution.CommandID,
+		Success:   execution.Success,
+		Output:    execution.Output,
+		Error:     execution.Error,
+		ExitCode:  execution.ExitCode,
+		Duration:  duration,
+	})
+}
+
 // UpdateDeviceCommand updates an existing command
 func (ds *DeviceService) UpdateDeviceCommand(command *model.DeviceCommand) error {
 	// Check if command exists
@@ -304,4 +925,58 @@ func (ds *DeviceService) DeleteDeviceCommand(deviceID, commandID string) error {
 	}
 
 	return ds.deviceRepo.DeleteDeviceCommand(deviceID, commandID)
-}
\ No newline at end of file
+}
+// ===== Device Group Methods =====
+
+// CreateDeviceGroup creates a new device group owned by a user
+func (ds *DeviceService) CreateDeviceGroup(ownerID, name, description string) (*model.DeviceGroup, error) {
+	group := &model.DeviceGroup{
+		Name:        name,
+		Description: description,
+		OwnerID:     ownerID,
+	}
+
+	if err := ds.deviceRepo.CreateDeviceGroup(group); err != nil {
+		return nil, fmt.Errorf("failed to create device group: %w", err)
+	}
+
+	return group, nil
+}
+
+// GetDeviceGroup returns a device group, or nil if it does not exist
+func (ds *DeviceService) GetDeviceGroup(groupID string) (*model.DeviceGroup, error) {
+	return ds.deviceRepo.GetDeviceGroup(groupID)
+}
+
+// GetUserDeviceGroups returns all device groups owned by a user
+func (ds *DeviceService) GetUserDeviceGroups(ownerID string) ([]*model.DeviceGroup, error) {
+	return ds.deviceRepo.GetDeviceGroups(ownerID)
+}
+
+// DeleteDeviceGroup deletes a device group; member devices are not affected
+func (ds *DeviceService) DeleteDeviceGroup(groupID string) error {
+	return ds.deviceRepo.DeleteDeviceGroup(groupID)
+}
+
+// AddDeviceToGroup adds an existing device to a group
+func (ds *DeviceService) AddDeviceToGroup(groupID, deviceID string) error {
+	device, err := ds.deviceRepo.GetByID(deviceID)
+	if err != nil {
+		return fmt.Errorf("device not found: %w", err)
+	}
+	if device == nil {
+		return fmt.Errorf("device %s does not exist", deviceID)
+	}
+
+	return ds.deviceRepo.AddDeviceToGroup(groupID, deviceID)
+}
+
+// RemoveDeviceFromGroup removes a device from a group
+func (ds *DeviceService) RemoveDeviceFromGroup(groupID, deviceID string) error {
+	return ds.deviceRepo.RemoveDeviceFromGroup(groupID, deviceID)
+}
+
+// GetGroupDevices returns all devices in a group
+func (ds *DeviceService) GetGroupDevices(groupID string) ([]*model.Device, error) {
+	return ds.deviceRepo.GetGroupDevices(groupID)
+}
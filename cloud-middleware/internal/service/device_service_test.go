@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+)
+
+// newTestDeviceService returns a device service backed by an in-memory
+// database holding the given devices
+func newTestDeviceService(t *testing.T, devices ...*model.Device) *DeviceService {
+	t.Helper()
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	deviceRepo := repository.NewDeviceRepository(db)
+	for _, device := range devices {
+		if err := deviceRepo.Create(device); err != nil {
+			t.Fatalf("create device %s: %v", device.ID, err)
+		}
+	}
+	return NewDeviceService(deviceRepo)
+}
+
+func testDevice(id string) *model.Device {
+	return &model.Device{ID: id, DeviceName: id, DeviceType: "desktop", Platform: "linux"}
+}
+
+func TestVerifyTunnelToken(t *testing.T) {
+	ds := newTestDeviceService(t, testDevice("dev-1"), testDevice("dev-2"), testDevice("never-issued"))
+
+	first, err := ds.IssueTunnelToken("dev-1")
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	current, err := ds.IssueTunnelToken("dev-1")
+	if err != nil {
+		t.Fatalf("rotate token: %v", err)
+	}
+	other, err := ds.IssueTunnelToken("dev-2")
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if first == current {
+		t.Fatalf("rotation returned the same token")
+	}
+
+	tests := []struct {
+		name     string
+		deviceID string
+		token    string
+		want     bool
+	}{
+		{"current token", "dev-1", current, true},
+		{"rotated out token", "dev-1", first, false},
+		{"another device's token", "dev-1", other, false},
+		{"empty token", "dev-1", "", false},
+		{"old derived token", "dev-1", "device_token_dev-1_0", false},
+		{"device never issued a token", "never-issued", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, err := ds.GetDeviceByID(tt.deviceID)
+			if err != nil || device == nil {
+				t.Fatalf("get device: %v", err)
+			}
+			if device.TunnelTokenHash == current {
+				t.Fatalf("token stored in plaintext")
+			}
+			if got := ds.VerifyTunnelToken(device, tt.token); got != tt.want {
+				t.Errorf("VerifyTunnelToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueTunnelTokenUnknownDevice(t *testing.T) {
+	ds := newTestDeviceService(t)
+	if _, err := ds.IssueTunnelToken("missing"); err == nil {
+		t.Fatal("expected an error for an unknown device")
+	}
+}
+
+// newAdminOnlyDeviceService returns a device service over dev-1 with one
+// admin-only and one regular command, and a user per device role
+func newAdminOnlyDeviceService(t *testing.T) *DeviceService {
+	t.Helper()
+	ds := newTestDeviceService(t, testDevice("dev-1"))
+	for _, command := range []*model.DeviceCommand{
+		{DeviceID: "dev-1", CommandID: "reboot", Name: "Reboot", Command: "reboot", AdminOnly: true},
+		{DeviceID: "dev-1", CommandID: "uptime", Name: "Uptime", Command: "uptime"},
+	} {
+		if err := ds.CreateDeviceCommand(command); err != nil {
+			t.Fatalf("create command %s: %v", command.CommandID, err)
+		}
+	}
+	for _, role := range []string{"owner", "admin", "user", "viewer"} {
+		if err := ds.deviceRepo.CreateUserDevice(&model.UserDevice{UserID: role, DeviceID: "dev-1", Role: role, Status: "active"}); err != nil {
+			t.Fatalf("bind %s: %v", role, err)
+		}
+	}
+	if err := ds.deviceRepo.CreateUserDevice(&model.UserDevice{UserID: "disabled-admin", DeviceID: "dev-1", Role: "admin", Status: "disabled"}); err != nil {
+		t.Fatalf("bind disabled-admin: %v", err)
+	}
+	return ds
+}
+
+func TestCommandExecuteRole(t *testing.T) {
+	ds := newAdminOnlyDeviceService(t)
+
+	tests := []struct {
+		name      string
+		commandID string
+		want      string
+	}{
+		{"admin-only command", "reboot", "admin"},
+		{"regular command", "uptime", "user"},
+		{"unknown command", "missing", "user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ds.CommandExecuteRole("dev-1", tt.commandID)
+			if err != nil {
+				t.Fatalf("CommandExecuteRole: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("CommandExecuteRole(%s) = %q, want %q", tt.commandID, got, tt.want)
+			}
+			// The required role is what execution checks the caller against
+			allowed, err := ds.CheckUserDevicePermission("user", "dev-1", got)
+			if err != nil {
+				t.Fatalf("CheckUserDevicePermission: %v", err)
+			}
+			if want := got == "user"; allowed != want {
+				t.Fatalf("device user may execute %s = %v, want %v", tt.commandID, allowed, want)
+			}
+		})
+	}
+}
+
+func TestVisibleCommands(t *testing.T) {
+	ds := newAdminOnlyDeviceService(t)
+	commands, err := ds.GetDeviceCommands("dev-1")
+	if err != nil {
+		t.Fatalf("get commands: %v", err)
+	}
+
+	tests := []struct {
+		userID string
+		want   []string
+	}{
+		{"owner", []string{"reboot", "uptime"}},
+		{"admin", []string{"reboot", "uptime"}},
+		{"user", []string{"uptime"}},
+		{"viewer", []string{"uptime"}},
+		{"disabled-admin", []string{"uptime"}},
+		{"stranger", []string{"uptime"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			visible, err := ds.VisibleCommands(tt.userID, "dev-1", commands)
+			if err != nil {
+				t.Fatalf("VisibleCommands: %v", err)
+			}
+			got := make([]string, len(visible))
+			for i, command := range visible {
+				got[i] = command.CommandID
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("visible commands = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessHistory(t *testing.T) {
+	ds := newTestDeviceService(t, testDevice("dev-1"))
+	if err := ds.deviceRepo.CreateUserDevice(&model.UserDevice{UserID: "owner", DeviceID: "dev-1", Role: "owner", Status: "active"}); err != nil {
+		t.Fatalf("bind owner: %v", err)
+	}
+
+	// Each step changes access and must append exactly the given entry
+	tests := []struct {
+		name string
+		step func() error
+		want model.UserDeviceHistory
+	}{
+		{"owner binds member", func() error {
+			_, err := ds.BindDeviceToUser("owner", "member", "dev-1", "viewer")
+			return err
+		}, model.UserDeviceHistory{UserID: "member", Action: model.AccessActionGranted, NewRole: "viewer", ActorID: "owner"}},
+		{"owner changes member's role", func() error {
+			return ds.UpdateUserDeviceRole("owner", "member", "dev-1", "user")
+		}, model.UserDeviceHistory{UserID: "member", Action: model.AccessActionRoleChanged, OldRole: "viewer", NewRole: "user", ActorID: "owner"}},
+		{"owner unbinds member", func() error {
+			return ds.UnbindDeviceFromUser("owner", "member", "dev-1")
+		}, model.UserDeviceHistory{UserID: "member", Action: model.AccessActionRevoked, OldRole: "user", ActorID: "owner"}},
+		{"guest requests access", func() error {
+			_, err := ds.BindDeviceToUser("guest", "guest", "dev-1", "viewer")
+			return err
+		}, model.UserDeviceHistory{UserID: "guest", Action: model.AccessActionRequested, NewRole: "viewer", ActorID: "guest"}},
+		{"owner approves guest", func() error {
+			_, err := ds.ApproveAccessRequest("owner", "guest", "dev-1")
+			return err
+		}, model.UserDeviceHistory{UserID: "guest", Action: model.AccessActionGranted, NewRole: "viewer", ActorID: "owner"}},
+		{"stranger requests access", func() error {
+			_, err := ds.BindDeviceToUser("stranger", "stranger", "dev-1", "user")
+			return err
+		}, model.UserDeviceHistory{UserID: "stranger", Action: model.AccessActionRequested, NewRole: "user", ActorID: "stranger"}},
+		{"owner rejects stranger", func() error {
+			return ds.RejectAccessRequest("owner", "stranger", "dev-1")
+		}, model.UserDeviceHistory{UserID: "stranger", Action: model.AccessActionRejected, OldRole: "user", ActorID: "owner"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.step(); err != nil {
+				t.Fatalf("step failed: %v", err)
+			}
+			history, err := ds.GetDeviceAccessHistory("dev-1", 0)
+			if err != nil {
+				t.Fatalf("GetDeviceAccessHistory: %v", err)
+			}
+			if len(history) != i+1 {
+				t.Fatalf("history has %d entries, want %d", len(history), i+1)
+			}
+			got := history[0]
+			if got.DeviceID != "dev-1" || got.UserID != tt.want.UserID || got.Action != tt.want.Action ||
+				got.OldRole != tt.want.OldRole || got.NewRole != tt.want.NewRole || got.ActorID != tt.want.ActorID {
+				t.Errorf("newest entry = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no-op role change is not recorded", func(t *testing.T) {
+		if err := ds.UpdateUserDeviceRole("owner", "guest", "dev-1", "viewer"); err != nil {
+			t.Fatalf("UpdateUserDeviceRole: %v", err)
+		}
+		history, err := ds.GetDeviceAccessHistory("dev-1", 0)
+		if err != nil {
+			t.Fatalf("GetDeviceAccessHistory: %v", err)
+		}
+		if len(history) != len(tests) {
+			t.Errorf("history has %d entries, want %d", len(history), len(tests))
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		history, err := ds.GetDeviceAccessHistory("dev-1", 2)
+		if err != nil {
+			t.Fatalf("GetDeviceAccessHistory: %v", err)
+		}
+		if len(history) != 2 || history[0].Action != model.AccessActionRejected {
+			t.Errorf("limited history = %d entries starting %v, want the 2 newest", len(history), history)
+		}
+	})
+}
+
+// deadlineRepository ends an import's context after a number of stored
+// commands, standing in for a batch timeout hit partway through
+type deadlineRepository struct {
+	repository.DeviceRepository
+	after  int
+	cancel context.CancelFunc
+}
+
+func (r *deadlineRepository) CreateDeviceCommand(command *model.DeviceCommand) error {
+	if err := r.DeviceRepository.CreateDeviceCommand(command); err != nil {
+		return err
+	}
+	r.after--
+	if r.after == 0 {
+		r.cancel()
+	}
+	return nil
+}
+
+func TestImportDeviceCommandsTimeout(t *testing.T) {
+	const total = 200
+
+	tests := []struct {
+		name         string
+		deadlineAt   int // commands stored before the deadline, 0 for none
+		wantImported int
+		wantTimeout  bool
+	}{
+		{"completes", 0, total, false},
+		{"times out partway", 75, 75, true},
+		{"times out on the last command", total, total, false},
+		{"already expired", -1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDeviceService(t, testDevice("dev-1"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			switch {
+			case tt.deadlineAt > 0:
+				ds.deviceRepo = &deadlineRepository{DeviceRepository: ds.deviceRepo, after: tt.deadlineAt, cancel: cancel}
+			case tt.deadlineAt < 0:
+				cancel()
+			}
+
+			commands := make([]*model.DeviceCommand, total)
+			for i := range commands {
+				commands[i] = &model.DeviceCommand{CommandID: fmt.Sprintf("cmd-%03d", i), Name: "Command", Command: "true"}
+			}
+			imported, err := ds.ImportDeviceCommands(ctx, "dev-1", commands)
+
+			if imported != tt.wantImported {
+				t.Errorf("imported = %d, want %d", imported, tt.wantImported)
+			}
+			if errors.Is(err, ErrBatchTimeout) != tt.wantTimeout {
+				t.Errorf("err = %v, want batch timeout %v", err, tt.wantTimeout)
+			}
+			if !tt.wantTimeout && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			stored, err := ds.GetDeviceCommands("dev-1")
+			if err != nil {
+				t.Fatalf("get commands: %v", err)
+			}
+			if len(stored) != imported {
+				t.Errorf("%d commands stored, but %d reported imported", len(stored), imported)
+			}
+		})
+	}
+}
+
+func TestCheckCommandSafety(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		command  string
+		wantErr  bool
+	}{
+		{"no patterns", nil, "rm -rf /", false},
+		{"safe command", []string{"rm -rf /", "format c:"}, "ls -la", false},
+		{"blocked", []string{"rm -rf /", "format c:"}, "sudo rm -rf / --no-preserve-root", true},
+		{"blocked, other case", []string{"rm -rf /", "format c:"}, "FORMAT C: /q", true},
+		{"extra pattern", []string{"rm -rf /", "dd if="}, "dd if=/dev/zero of=/dev/sda", true},
+		{"empty pattern ignored", []string{""}, "echo hi", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDeviceService(t)
+			ds.SetBlockedPatterns(tt.patterns)
+			err := ds.CheckCommandSafety(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCommandSafety(%q) = %v, want error %v", tt.command, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDangerousCommand) {
+				t.Errorf("error %v is not ErrDangerousCommand", err)
+			}
+		})
+	}
+}
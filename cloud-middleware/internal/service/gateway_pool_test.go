@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+)
+
+func TestConnectionPoolEviction(t *testing.T) {
+	address := startMockDevice(t, &mockDevice{})
+
+	// unhealthy maps a device of the full pool to how long ago it last
+	// answered a ping, and used to how long ago a call went through it
+	tests := []struct {
+		name        string
+		grace       int
+		unhealthy   map[string]time.Duration
+		used        map[string]time.Duration
+		wantAdmit   bool
+		wantEvicted string
+	}{
+		{
+			name:        "unhealthy past the grace period",
+			grace:       1,
+			unhealthy:   map[string]time.Duration{"dev-1": 2 * time.Second},
+			wantAdmit:   true,
+			wantEvicted: "dev-1",
+		},
+		{
+			name:      "unhealthy within the grace period",
+			grace:     5,
+			unhealthy: map[string]time.Duration{"dev-1": 2 * time.Second},
+		},
+		{
+			name:      "eviction disabled",
+			grace:     0,
+			unhealthy: map[string]time.Duration{"dev-1": time.Hour},
+		},
+		{
+			name:  "every connection healthy",
+			grace: 1,
+		},
+		{
+			name:        "least recently used is evicted first",
+			grace:       1,
+			unhealthy:   map[string]time.Duration{"dev-0": 2 * time.Second, "dev-2": 2 * time.Second},
+			used:        map[string]time.Duration{"dev-0": time.Second, "dev-2": time.Minute},
+			wantAdmit:   true,
+			wantEvicted: "dev-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GatewayConfig{AllowInsecure: true}
+			cfg.Pool.MaxConnections = 3
+			cfg.Pool.EvictionGrace = tt.grace
+			gs, err := NewGatewayService(cfg, nil)
+			if err != nil {
+				t.Fatalf("create gateway service: %v", err)
+			}
+			defer gs.Stop()
+
+			for i := 0; i < cfg.Pool.MaxConnections; i++ {
+				if err := gs.AddDevice(fmt.Sprintf("dev-%d", i), []string{address}); err != nil {
+					t.Fatalf("fill pool: %v", err)
+				}
+			}
+			now := time.Now()
+			for id, since := range tt.unhealthy {
+				conn := gs.connections[id]
+				conn.mutex.Lock()
+				conn.IsHealthy = false
+				conn.LastPing = now.Add(-since)
+				conn.mutex.Unlock()
+			}
+			for id, since := range tt.used {
+				conn := gs.connections[id]
+				conn.mutex.Lock()
+				conn.LastUsed = now.Add(-since)
+				conn.mutex.Unlock()
+			}
+
+			err = gs.AddDevice("new-device", []string{address})
+			if tt.wantAdmit != (err == nil) {
+				t.Fatalf("AddDevice(new-device) = %v, want admitted %v", err, tt.wantAdmit)
+			}
+
+			stats := gs.ConnectionStats()
+			wantEvicted := 0
+			wantHealthy := cfg.Pool.MaxConnections - len(tt.unhealthy)
+			if tt.wantAdmit {
+				wantEvicted = 1
+				wantHealthy++
+				if _, err := gs.GetDeviceStatus(tt.wantEvicted); err == nil {
+					t.Errorf("%s is still in the pool", tt.wantEvicted)
+				}
+			}
+			if stats.Connections != cfg.Pool.MaxConnections || stats.Healthy != wantHealthy || stats.Evicted != wantEvicted {
+				t.Errorf("stats = %+v, want %d connections, %d healthy, %d evicted",
+					stats, cfg.Pool.MaxConnections, wantHealthy, wantEvicted)
+			}
+		})
+	}
+}
+
+func TestGetDeviceClientTouches(t *testing.T) {
+	gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true}, nil)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	defer gs.Stop()
+	if err := gs.AddDevice("dev-1", []string{startMockDevice(t, &mockDevice{})}); err != nil {
+		t.Fatalf("AddDevice: %v", err)
+	}
+
+	conn, _ := gs.GetDeviceStatus("dev-1")
+	conn.mutex.RLock()
+	before := conn.LastUsed
+	conn.mutex.RUnlock()
+	if _, err := gs.GetDeviceClient("dev-1"); err != nil {
+		t.Fatalf("GetDeviceClient: %v", err)
+	}
+	conn.mutex.RLock()
+	after := conn.LastUsed
+	conn.mutex.RUnlock()
+	if !after.After(before) {
+		t.Errorf("LastUsed = %v after a call, was %v", after, before)
+	}
+}
@@ -2,37 +2,140 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 
 	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
 )
 
-// DeviceConnection represents a gRPC connection to a specific device
+// DeviceConnection represents a gRPC connection to a specific device, either
+// dialed directly or established by the device through a reverse tunnel.
+// Directly dialed devices may list several addresses (e.g. LAN and VPN);
+// Address is the one currently in use.
 type DeviceConnection struct {
 	DeviceID     string
 	Address      string
+	Addresses    []string
 	Connection   *grpc.ClientConn
+	Tunnel       *TunnelSession
 	Client       controllerPb.ControllerServiceClient
 	LastPing     time.Time
+	LastUsed     time.Time // last call through the connection, see Touch
 	IsHealthy    bool
 	ConnectedAt  time.Time
 	mutex        sync.RWMutex
+
+	// Round-trip times of the latest health check pings, see Latency
+	latencies []time.Duration
+}
+
+// latencyWindow is the number of recent pings averaged by Latency
+const latencyWindow = 10
+
+// recordLatency adds a ping round-trip time to the moving average
+func (dc *DeviceConnection) recordLatency(rtt time.Duration) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if len(dc.latencies) == latencyWindow {
+		dc.latencies = append(dc.latencies[:0], dc.latencies[1:]...)
+	}
+	dc.latencies = append(dc.latencies, rtt)
+}
+
+// Latency returns the round-trip time of the last ping and the average over
+// the last latencyWindow pings; both are zero before the first ping
+func (dc *DeviceConnection) Latency() (last, average time.Duration) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	if len(dc.latencies) == 0 {
+		return 0, 0
+	}
+	var total time.Duration
+	for _, rtt := range dc.latencies {
+		total += rtt
+	}
+	return dc.latencies[len(dc.latencies)-1], total / time.Duration(len(dc.latencies))
+}
+
+// Touch records that a call is made through the connection
+func (dc *DeviceConnection) Touch() {
+	dc.mutex.Lock()
+	dc.LastUsed = time.Now()
+	dc.mutex.Unlock()
+}
+
+// healthy reports whether the connection passed its last check
+func (dc *DeviceConnection) healthy() bool {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	return dc.IsHealthy
+}
+
+// unhealthyFor returns how long the connection has been unhealthy, measured
+// from its last successful ping or, if it never had one, from when it was added
+func (dc *DeviceConnection) unhealthyFor(now time.Time) time.Duration {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	if dc.IsHealthy {
+		return 0
+	}
+	since := dc.LastPing
+	if since.IsZero() {
+		since = dc.ConnectedAt
+	}
+	return now.Sub(since)
 }
 
+// ErrBatchTimeout is reported for work a bulk operation did not finish in time
+var ErrBatchTimeout = errors.New("batch timeout exceeded")
+
+// tunnelAddress is reported as the address of tunnel connections
+const tunnelAddress = "tunnel"
+
+// StatusChangeFunc is told that a device's connection became healthy or unhealthy
+type StatusChangeFunc func(deviceID string, healthy bool)
+
 // GatewayService manages gRPC connections to multiple devices
 type GatewayService struct {
 	connections map[string]*DeviceConnection
 	mutex       sync.RWMutex
+	evicted     int // connections evicted to admit other devices, guarded by mutex
+	
+	config        config.GatewayConfig
+	deviceService *DeviceService
+	credentials   credentials.TransportCredentials
+	dialErr       error // why devices cannot be dialed directly, nil when they can
+	syncLocks     sync.Map // device ID to the *sync.Mutex serializing its command syncs
+	queueLocks    sync.Map // device ID to the *sync.Mutex serializing deliveries of its queued executions
+	
+	statusCallbacks []StatusChangeFunc
+	callbackMutex   sync.RWMutex
 	
 	// Connection pool settings
 	maxConnections int
+	evictionGrace  time.Duration // 0 never evicts
 	connectTimeout time.Duration
 	pingInterval   time.Duration
 	
@@ -42,64 +145,471 @@ type GatewayService struct {
 }
 
 // NewGatewayService creates a new gateway service instance
-func NewGatewayService() *GatewayService {
+func NewGatewayService(cfg config.GatewayConfig, deviceService *DeviceService) (*GatewayService, error) {
+	creds, err := loadClientCredentials(cfg)
+	dialErr := error(nil)
+	if errors.Is(err, errInsecureNotAllowed) {
+		// Devices connecting through the tunnel need no credentials
+		log.Printf("Direct device connections disabled: %v; devices can still connect through the tunnel", err)
+		dialErr = err
+	} else if err != nil {
+		return nil, err
+	}
+
+	maxConnections := cfg.Pool.MaxConnections
+	if maxConnections <= 0 {
+		maxConnections = 100
+	}
+
 	return &GatewayService{
 		connections:         make(map[string]*DeviceConnection),
-		maxConnections:      100,
+		config:              cfg,
+		deviceService:       deviceService,
+		credentials:         creds,
+		dialErr:             dialErr,
+		maxConnections:      maxConnections,
+		evictionGrace:       time.Duration(cfg.Pool.EvictionGrace) * time.Second,
 		connectTimeout:      10 * time.Second,
 		pingInterval:        30 * time.Second,
 		healthCheckInterval: 60 * time.Second,
 		maxRetries:          3,
+	}, nil
+}
+
+// errInsecureNotAllowed is returned for a gateway with neither TLS nor plaintext enabled
+var errInsecureNotAllowed = errors.New("gateway TLS is disabled and insecure connections are not allowed")
+
+// loadClientCredentials builds the transport credentials used to dial devices.
+// Plaintext is only returned when TLS is disabled and insecure mode is allowed.
+func loadClientCredentials(cfg config.GatewayConfig) (credentials.TransportCredentials, error) {
+	if !cfg.TLS.Enabled {
+		if !cfg.AllowInsecure {
+			return nil, errInsecureNotAllowed
+		}
+		return insecure.NewCredentials(), nil
 	}
+
+	tlsConfig := &tls.Config{
+		ServerName: cfg.TLS.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLS.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gateway CA file %s: %w", cfg.TLS.CAFile, err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in gateway CA file %s", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gateway client certificate %s: %w", cfg.TLS.CertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
 }
 
-// AddDevice adds a new device connection
-func (gs *GatewayService) AddDevice(deviceID, address string) error {
-	gs.mutex.Lock()
-	defer gs.mutex.Unlock()
+// OnStatusChange registers fn to be told whenever a device's connection turns
+// healthy or unhealthy: when it connects, fails or passes a health check after
+// the opposite, loses its connection, recovers or is removed. Checks that leave
+// the status unchanged are not reported. Each call runs in its own goroutine,
+// so callbacks never hold up health checking but may run out of order for
+// transitions in quick succession
+func (gs *GatewayService) OnStatusChange(fn StatusChangeFunc) {
+	gs.callbackMutex.Lock()
+	defer gs.callbackMutex.Unlock()
+	gs.statusCallbacks = append(gs.statusCallbacks, fn)
+}
 
-	if len(gs.connections) >= gs.maxConnections {
-		return fmt.Errorf("maximum number of connections reached")
+// notifyStatusChange starts the status callbacks for a transition without waiting for them
+func (gs *GatewayService) notifyStatusChange(deviceID string, healthy bool) {
+	gs.callbackMutex.RLock()
+	callbacks := gs.statusCallbacks
+	gs.callbackMutex.RUnlock()
+
+	for _, fn := range callbacks {
+		go fn(deviceID, healthy)
+	}
+}
+
+// AddDevice adds a new device connection. Addresses are tried in order and
+// the first reachable one is used; the others serve as failover targets.
+// Commands are imported and synced in the background once it is connected
+func (gs *GatewayService) AddDevice(deviceID string, addresses []string) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("device %s has no addresses", deviceID)
 	}
 
+	if err := gs.connectDevice(deviceID, addresses); err != nil {
+		return err
+	}
+
+	go gs.onDeviceConnected(deviceID)
+	return nil
+}
+
+// EnsureDeviceConnection connects a device at address unless it is already
+// connected there or through a tunnel. A connection to another address is
+// replaced, so devices with dynamic addresses can be followed
+func (gs *GatewayService) EnsureDeviceConnection(deviceID, address string) error {
+	gs.mutex.RLock()
+	conn, exists := gs.connections[deviceID]
+	gs.mutex.RUnlock()
+
+	if exists {
+		if conn.Tunnel != nil || slices.Contains(conn.Addresses, address) {
+			conn.Touch()
+			return nil
+		}
+		log.Printf("Device %s moved from %s to %s, reconnecting", deviceID, conn.Address, address)
+		if err := gs.RemoveDevice(deviceID); err != nil {
+			return err
+		}
+	}
+
+	return gs.AddDevice(deviceID, []string{address})
+}
+
+// connectDevice dials a device and registers its connection. The device is
+// listed as unhealthy while dial attempts are retried so its status can be queried.
+func (gs *GatewayService) connectDevice(deviceID string, addresses []string) error {
+	gs.mutex.Lock()
 	// Check if device already exists
 	if _, exists := gs.connections[deviceID]; exists {
+		gs.mutex.Unlock()
 		return fmt.Errorf("device %s already connected", deviceID)
 	}
 
-	// Create new connection
-	ctx, cancel := context.WithTimeout(context.Background(), gs.connectTimeout)
-	defer cancel()
+	if !gs.reserveSlotLocked() {
+		gs.mutex.Unlock()
+		return fmt.Errorf("maximum number of connections reached")
+	}
+
+	deviceConn := &DeviceConnection{
+		DeviceID:    deviceID,
+		Address:     addresses[0],
+		Addresses:   addresses,
+		ConnectedAt: time.Now(),
+	}
+	gs.connections[deviceID] = deviceConn
+	gs.mutex.Unlock()
+
+	conn, address, err := gs.dialWithRetry(deviceID, addresses, 0)
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	// The entry may have been removed or replaced by a tunnel while dialing
+	if gs.connections[deviceID] != deviceConn {
+		if conn != nil {
+			conn.Close()
+		}
+		return fmt.Errorf("device %s was removed while connecting", deviceID)
+	}
+
+	if err != nil {
+		delete(gs.connections, deviceID)
+		return err
+	}
+
+	deviceConn.Connection = conn
+	deviceConn.Address = address
+	deviceConn.Client = controllerPb.NewControllerServiceClient(conn)
+	deviceConn.LastPing = time.Now()
+	deviceConn.IsHealthy = true
+	deviceConn.ConnectedAt = time.Now()
+
+	// Start health checking for this device
+	go gs.healthCheckWorker(deviceID)
+	go gs.watchConnection(deviceConn, conn)
+	gs.notifyStatusChange(deviceID, true)
+
+	log.Printf("Device %s connected at %s", deviceID, address)
+	return nil
+}
+
+// dialWithRetry dials a device, trying each address in turn starting at
+// addresses[start], and retries the whole list with exponential backoff.
+// It returns the connection and the address it was made to.
+func (gs *GatewayService) dialWithRetry(deviceID string, addresses []string, start int) (*grpc.ClientConn, string, error) {
+	if gs.dialErr != nil {
+		return nil, "", fmt.Errorf("cannot dial device %s: %w", deviceID, gs.dialErr)
+	}
+	attempts := gs.config.Retry.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := time.Duration(gs.config.Retry.BaseDelay) * time.Second
+	if delay <= 0 {
+		delay = time.Second
+	}
+	maxDelay := time.Duration(gs.config.Retry.MaxDelay) * time.Second
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		for i := range addresses {
+			address := addresses[(start+i)%len(addresses)]
+
+			ctx, cancel := context.WithTimeout(context.Background(), gs.connectTimeout)
+			conn, err := grpc.DialContext(ctx, address, gs.dialOptions()...)
+			cancel()
+			if err == nil {
+				return conn, address, nil
+			}
+			lastErr = err
+
+			if len(addresses) > 1 {
+				log.Printf("Device %s unreachable at %s: %v", deviceID, address, err)
+			}
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("Dial attempt %d/%d to device %s failed: %v; retrying in %s", attempt, attempts, deviceID, lastErr, delay)
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
 
-	conn, err := grpc.DialContext(ctx, address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	log.Printf("Giving up on device %s at %v after %d attempts: %v", deviceID, addresses, attempts, lastErr)
+	return nil, "", fmt.Errorf("failed to connect to device %s at %v after %d attempts: %w", deviceID, addresses, attempts, lastErr)
+}
+
+// dialOptions returns the options used to dial devices, with keepalive pings
+// when configured so dead connections are noticed while idle
+func (gs *GatewayService) dialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(gs.credentials),
 		grpc.WithBlock(),
-	)
+	}
+	if gs.config.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(gs.config.Keepalive.Time) * time.Second,
+			Timeout:             time.Duration(gs.config.Keepalive.Timeout) * time.Second,
+			PermitWithoutStream: gs.config.Keepalive.PermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
+// watchConnection marks a device unhealthy as soon as its connection drops
+// out of the ready state, e.g. after an unanswered keepalive ping, instead of
+// at the next health check, and redials it. It returns once conn is closed
+func (gs *GatewayService) watchConnection(deviceConn *DeviceConnection, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(context.Background(), state) {
+		state = conn.GetState()
+		if state == connectivity.Shutdown {
+			return
+		}
+		if state != connectivity.TransientFailure && state != connectivity.Idle {
+			continue
+		}
+
+		deviceConn.mutex.Lock()
+		lost := deviceConn.Connection == conn && deviceConn.IsHealthy
+		if lost {
+			deviceConn.IsHealthy = false
+		}
+		deviceConn.mutex.Unlock()
+
+		if lost {
+			log.Printf("Device %s connection lost: state %v", deviceConn.DeviceID, state)
+			gs.notifyStatusChange(deviceConn.DeviceID, false)
+			gs.reconnectDevice(deviceConn.DeviceID, deviceConn)
+		}
+	}
+}
+
+// nextAddressIndex returns where redialing a device starts: the address after
+// the one that failed, so reconnects rotate through the list round-robin
+func nextAddressIndex(addresses []string, failed string) int {
+	for i, address := range addresses {
+		if address == failed {
+			return (i + 1) % len(addresses)
+		}
+	}
+	return 0
+}
+
+// reconnectDevice replaces a failed direct connection with a freshly dialed
+// one, failing over to the device's other addresses first
+func (gs *GatewayService) reconnectDevice(deviceID string, failed *DeviceConnection) {
+	failed.mutex.RLock()
+	previous := failed.Address
+	failed.mutex.RUnlock()
+
+	log.Printf("Reconnecting to device %s, last reachable at %s", deviceID, previous)
+
+	conn, address, err := gs.dialWithRetry(deviceID, failed.Addresses, nextAddressIndex(failed.Addresses, previous))
 	if err != nil {
-		return fmt.Errorf("failed to connect to device %s at %s: %w", deviceID, address, err)
+		// The device stays registered but unhealthy; health checks keep probing it
+		return
 	}
 
-	client := controllerPb.NewControllerServiceClient(conn)
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
 
-	deviceConn := &DeviceConnection{
+	// The entry may have been removed or replaced by a tunnel while dialing
+	if gs.connections[deviceID] != failed {
+		conn.Close()
+		return
+	}
+
+	if failed.Connection != nil {
+		if err := failed.Connection.Close(); err != nil {
+			log.Printf("Error closing connection for device %s: %v", deviceID, err)
+		}
+	}
+	failed.mutex.Lock()
+	recovered := !failed.IsHealthy
+	failed.Connection = conn
+	failed.Address = address
+	failed.Client = controllerPb.NewControllerServiceClient(conn)
+	failed.IsHealthy = true
+	failed.LastPing = time.Now()
+	failed.mutex.Unlock()
+
+	go gs.watchConnection(failed, conn)
+	if recovered {
+		gs.notifyStatusChange(deviceID, true)
+	}
+
+	// The device may have missed command changes while it was unreachable
+	if gs.config.SyncCommands && gs.deviceService != nil {
+		go gs.syncDeviceCommands(deviceID)
+	}
+
+	if address != previous {
+		log.Printf("Device %s failed over from %s to %s", deviceID, previous, address)
+	} else {
+		log.Printf("Device %s reconnected at %s", deviceID, address)
+	}
+}
+
+// AttachTunnel registers a device reachable through its outbound tunnel stream.
+// An existing connection for the device is replaced.
+func (gs *GatewayService) AttachTunnel(deviceID string, session *TunnelSession) error {
+	gs.mutex.Lock()
+	existing, exists := gs.connections[deviceID]
+	wasHealthy := exists && existing.healthy()
+	if !exists && !gs.reserveSlotLocked() {
+		gs.mutex.Unlock()
+		return fmt.Errorf("maximum number of connections reached")
+	}
+	if exists && existing.Connection != nil {
+		if err := existing.Connection.Close(); err != nil {
+			log.Printf("Error closing connection for device %s: %v", deviceID, err)
+		}
+	}
+
+	gs.connections[deviceID] = &DeviceConnection{
 		DeviceID:    deviceID,
-		Address:     address,
-		Connection:  conn,
-		Client:      client,
+		Address:     tunnelAddress,
+		Tunnel:      session,
+		Client:      &tunnelClient{session: session},
 		LastPing:    time.Now(),
 		IsHealthy:   true,
 		ConnectedAt: time.Now(),
 	}
+	gs.mutex.Unlock()
 
-	gs.connections[deviceID] = deviceConn
+	if !exists {
+		go gs.healthCheckWorker(deviceID)
+	}
+	if !wasHealthy {
+		gs.notifyStatusChange(deviceID, true)
+	}
 
-	// Start health checking for this device
-	go gs.healthCheckWorker(deviceID)
+	log.Printf("Device %s connected through tunnel", deviceID)
 
-	log.Printf("Device %s connected at %s", deviceID, address)
+	go gs.onDeviceConnected(deviceID)
 	return nil
 }
 
+// DetachTunnel removes a tunnel connection if it is still the active one
+func (gs *GatewayService) DetachTunnel(deviceID string, session *TunnelSession) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	conn, exists := gs.connections[deviceID]
+	if !exists || conn.Tunnel != session {
+		return
+	}
+
+	delete(gs.connections, deviceID)
+	if conn.healthy() {
+		gs.notifyStatusChange(deviceID, false)
+	}
+	log.Printf("Device %s tunnel disconnected", deviceID)
+}
+
+// importDeviceCommands fetches the command list from a device and stores it
+// in the cloud if the device has no commands stored yet
+func (gs *GatewayService) importDeviceCommands(deviceID string) (int, error) {
+	ctx, cancel := gs.batchContext()
+	defer cancel()
+
+	// The cloud keeps every command, admin-only ones included
+	resp, err := gs.ListCommands(ctx, deviceID, ownerCallerRole)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list commands: %w", err)
+	}
+
+	commands := make([]*model.DeviceCommand, 0, len(resp.Commands))
+	for _, cmd := range resp.Commands {
+		name := cmd.Name
+		if name == "" {
+			name = cmd.Id
+		}
+
+		commands = append(commands, &model.DeviceCommand{
+			DeviceID:    deviceID,
+			CommandID:   cmd.Id,
+			Name:        name,
+			Description: cmd.Description,
+			Category:    cmd.Category,
+			Icon:        cmd.Icon,
+			Command:     cmd.PlatformCommand,
+			Platform:    cmd.Platform,
+			CommandType: cmd.CommandType,
+			Timeout:     int(cmd.Timeout),
+			WorkingDir:  cmd.WorkingDir,
+			Env:         cmd.Env,
+			RequiresPin: cmd.RequiresPin,
+			Whitelisted: cmd.Whitelisted,
+			AdminOnly:   cmd.AdminOnly,
+		})
+	}
+
+	return gs.deviceService.ImportDeviceCommands(ctx, deviceID, commands)
+}
+
+// batchContext returns a context bounded by the configured batch timeout
+func (gs *GatewayService) batchContext() (context.Context, context.CancelFunc) {
+	if gs.config.Batch.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(gs.config.Batch.Timeout)*time.Second)
+}
+
 // RemoveDevice removes a device connection
 func (gs *GatewayService) RemoveDevice(deviceID string) error {
 	gs.mutex.Lock()
@@ -110,16 +620,74 @@ func (gs *GatewayService) RemoveDevice(deviceID string) error {
 		return fmt.Errorf("device %s not found", deviceID)
 	}
 
-	// Close the connection
-	if err := conn.Connection.Close(); err != nil {
-		log.Printf("Error closing connection for device %s: %v", deviceID, err)
+	// Close the connection; tunnel sessions end when the device hangs up
+	if conn.Connection != nil {
+		if err := conn.Connection.Close(); err != nil {
+			log.Printf("Error closing connection for device %s: %v", deviceID, err)
+		}
 	}
 
 	delete(gs.connections, deviceID)
+	if conn.healthy() {
+		gs.notifyStatusChange(deviceID, false)
+	}
 	log.Printf("Device %s disconnected", deviceID)
 	return nil
 }
 
+// reserveSlotLocked reports whether another connection fits in the pool. When
+// it is full, the least recently used connection that has been unhealthy for
+// longer than the eviction grace period is evicted to make room. The caller
+// must hold gs.mutex for writing
+func (gs *GatewayService) reserveSlotLocked() bool {
+	if len(gs.connections) < gs.maxConnections {
+		return true
+	}
+	if gs.evictionGrace <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	var victim *DeviceConnection
+	var victimUsed time.Time
+	for _, conn := range gs.connections {
+		if conn.unhealthyFor(now) <= gs.evictionGrace {
+			continue
+		}
+		conn.mutex.RLock()
+		lastUsed := conn.LastUsed
+		conn.mutex.RUnlock()
+		if victim == nil || lastUsed.Before(victimUsed) {
+			victim, victimUsed = conn, lastUsed
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	// Closing the connection also ends its watcher; the health check worker
+	// stops once the device is gone from the map
+	if victim.Connection != nil {
+		if err := victim.Connection.Close(); err != nil {
+			log.Printf("Error closing connection for device %s: %v", victim.DeviceID, err)
+		}
+	}
+	delete(gs.connections, victim.DeviceID)
+	gs.evicted++
+
+	log.Printf("Evicted device %s from the connection pool: unhealthy for %s, last used %s",
+		victim.DeviceID, victim.unhealthyFor(now).Round(time.Second), formatLastUsed(victimUsed))
+	return true
+}
+
+// formatLastUsed describes a connection's last use for logs
+func formatLastUsed(lastUsed time.Time) string {
+	if lastUsed.IsZero() {
+		return "never"
+	}
+	return lastUsed.Format(time.RFC3339)
+}
+
 // GetDeviceClient returns the gRPC client for a device
 func (gs *GatewayService) GetDeviceClient(deviceID string) (controllerPb.ControllerServiceClient, error) {
 	gs.mutex.RLock()
@@ -134,6 +702,7 @@ func (gs *GatewayService) GetDeviceClient(deviceID string) (controllerPb.Control
 		return nil, fmt.Errorf("device %s is not healthy", deviceID)
 	}
 
+	conn.Touch()
 	return conn.Client, nil
 }
 
@@ -152,6 +721,111 @@ func (gs *GatewayService) ListConnectedDevices() []string {
 	return devices
 }
 
+// ConnectedDeviceFilter selects the connections listed by ListConnectedDevicesDetailed
+type ConnectedDeviceFilter struct {
+	HealthyOnly   bool
+	AddressPrefix string // matches the address in use
+	Offset        int
+	Limit         int // 0 lists all
+}
+
+// ConnectedDevice is a snapshot of a device connection
+type ConnectedDevice struct {
+	DeviceID       string
+	Address        string
+	Addresses      []string
+	Tunnel         bool
+	IsHealthy      bool
+	LastPing       time.Time
+	ConnectedAt    time.Time
+	LastLatency    time.Duration // 0 before the first ping
+	AverageLatency time.Duration
+}
+
+// ListConnectedDevicesDetailed returns a page of the connections matching
+// filter, ordered by device ID, and how many match in total. Unlike
+// ListConnectedDevices it includes unhealthy connections unless filtered out
+func (gs *GatewayService) ListConnectedDevicesDetailed(filter ConnectedDeviceFilter) ([]ConnectedDevice, int) {
+	gs.mutex.RLock()
+	conns := make([]*DeviceConnection, 0, len(gs.connections))
+	for _, conn := range gs.connections {
+		conns = append(conns, conn)
+	}
+	gs.mutex.RUnlock()
+
+	var devices []ConnectedDevice
+	for _, conn := range conns {
+		device := conn.snapshot()
+		if filter.HealthyOnly && !device.IsHealthy {
+			continue
+		}
+		if filter.AddressPrefix != "" && !strings.HasPrefix(device.Address, filter.AddressPrefix) {
+			continue
+		}
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].DeviceID < devices[j].DeviceID
+	})
+
+	total := len(devices)
+	if filter.Offset >= total {
+		return []ConnectedDevice{}, total
+	}
+	devices = devices[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(devices) {
+		devices = devices[:filter.Limit]
+	}
+	return devices, total
+}
+
+// snapshot copies the connection's state for listing
+func (dc *DeviceConnection) snapshot() ConnectedDevice {
+	lastLatency, averageLatency := dc.Latency()
+
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	return ConnectedDevice{
+		DeviceID:       dc.DeviceID,
+		Address:        dc.Address,
+		Addresses:      append([]string(nil), dc.Addresses...),
+		Tunnel:         dc.Tunnel != nil,
+		IsHealthy:      dc.IsHealthy,
+		LastPing:       dc.LastPing,
+		ConnectedAt:    dc.ConnectedAt,
+		LastLatency:    lastLatency,
+		AverageLatency: averageLatency,
+	}
+}
+
+// PoolStats describes the gateway's connection pool
+type PoolStats struct {
+	Connections    int
+	Healthy        int
+	MaxConnections int
+	Evicted        int // since startup
+}
+
+// ConnectionStats returns the number of device connections, how many are healthy,
+// the connection limit and how many connections were evicted
+func (gs *GatewayService) ConnectionStats() PoolStats {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+
+	stats := PoolStats{
+		Connections:    len(gs.connections),
+		MaxConnections: gs.maxConnections,
+		Evicted:        gs.evicted,
+	}
+	for _, conn := range gs.connections {
+		if conn.IsHealthy {
+			stats.Healthy++
+		}
+	}
+	return stats
+}
+
 // GetDeviceStatus returns the status of a specific device
 func (gs *GatewayService) GetDeviceStatus(deviceID string) (*DeviceConnection, error) {
 	gs.mutex.RLock()
@@ -173,7 +847,9 @@ func (gs *GatewayService) healthCheckWorker(deviceID string) {
 	for {
 		select {
 		case <-ticker.C:
-			gs.performHealthCheck(deviceID)
+			if conn, reconnect := gs.performHealthCheck(deviceID); reconnect {
+				gs.reconnectDevice(deviceID, conn)
+			}
 		}
 
 		// Check if device still exists
@@ -187,33 +863,43 @@ func (gs *GatewayService) healthCheckWorker(deviceID string) {
 	}
 }
 
-// performHealthCheck performs a health check on a specific device
-func (gs *GatewayService) performHealthCheck(deviceID string) {
+// performHealthCheck performs a health check on a specific device and reports
+// whether a previously healthy direct connection has failed and should be redialed
+func (gs *GatewayService) performHealthCheck(deviceID string) (*DeviceConnection, bool) {
 	gs.mutex.RLock()
 	conn, exists := gs.connections[deviceID]
 	gs.mutex.RUnlock()
 
-	if !exists {
-		return
+	if !exists || conn.Client == nil {
+		return nil, false
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// Check connection state
-	state := conn.Connection.GetState()
-	if state == connectivity.TransientFailure || state == connectivity.Shutdown {
-		conn.mutex.Lock()
-		conn.IsHealthy = false
-		conn.mutex.Unlock()
-		log.Printf("Device %s health check failed: connection state %v", deviceID, state)
-		return
+	if conn.Connection != nil {
+		state := conn.Connection.GetState()
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			conn.mutex.Lock()
+			wasHealthy := conn.IsHealthy
+			conn.IsHealthy = false
+			conn.mutex.Unlock()
+			log.Printf("Device %s health check failed: connection state %v", deviceID, state)
+			if wasHealthy {
+				gs.notifyStatusChange(deviceID, false)
+			}
+			return conn, wasHealthy
+		}
 	}
 
+	gs.measureLatency(ctx, conn)
+
 	// Try to ping the device
 	_, err := conn.Client.HealthCheck(ctx, &controllerPb.HealthCheckRequest{})
 	
 	conn.mutex.Lock()
+	wasHealthy := conn.IsHealthy
 	if err != nil {
 		conn.IsHealthy = false
 		log.Printf("Device %s health check failed: %v", deviceID, err)
@@ -222,54 +908,190 @@ func (gs *GatewayService) performHealthCheck(deviceID string) {
 		conn.LastPing = time.Now()
 	}
 	conn.mutex.Unlock()
+	if healthy := err == nil; healthy != wasHealthy {
+		gs.notifyStatusChange(deviceID, healthy)
+	}
+
+	if err == nil && gs.deviceService != nil {
+		if err := gs.deviceService.UpdateDeviceLastSeen(deviceID); err != nil {
+			log.Printf("Failed to update last seen for device %s: %v", deviceID, err)
+		}
+		go gs.deliverPendingExecutions(deviceID)
+	}
+	return conn, false
+}
+
+// measureLatency samples the round-trip time to a device with the Ping RPC.
+// Agents without Ping are skipped; the health check itself reports failures
+func (gs *GatewayService) measureLatency(ctx context.Context, conn *DeviceConnection) {
+	sent := time.Now()
+	resp, err := conn.Client.Ping(ctx, &controllerPb.PingRequest{ClientTimeUnixNano: sent.UnixNano()})
+	if err != nil {
+		if status.Code(err) != codes.Unimplemented {
+			log.Printf("Device %s ping failed: %v", conn.DeviceID, err)
+		}
+		return
+	}
+	if resp.ClientTimeUnixNano != sent.UnixNano() {
+		log.Printf("Device %s ping echoed the wrong timestamp", conn.DeviceID)
+		return
+	}
+
+	conn.recordLatency(time.Since(sent))
 }
 
-// ExecuteCommand executes a command on a specific device
-func (gs *GatewayService) ExecuteCommand(deviceID, commandID string, timeout int32) (*controllerPb.ExecuteCommandResponse, error) {
+// executeCallMargin is added to a command's timeout to bound the call, leaving
+// the device time to report the timed out execution
+const executeCallMargin = 5 * time.Second
+
+// ownerCallerRole is the caller role of calls the cloud makes for itself
+const ownerCallerRole = "owner"
+
+// ExecuteCommand executes a command on a specific device, bounded by ctx. A
+// positive timeout in seconds is passed to the device and also bounds the
+// call; otherwise the call gets the configured request timeout. callerRole is
+// the device role of the user the command runs for; the device runs
+// admin-only commands only for admins and owners
+func (gs *GatewayService) ExecuteCommand(ctx context.Context, deviceID, commandID, callerRole string, timeout int32) (*controllerPb.ExecuteCommandResponse, error) {
 	client, err := gs.GetDeviceClient(deviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second+executeCallMargin)
+	} else {
+		ctx, cancel = gs.callContext(ctx)
+	}
 	defer cancel()
 
 	req := &controllerPb.ExecuteCommandRequest{
 		CommandId:      commandID,
 		TimeoutSeconds: timeout,
+		CallerRole:     callerRole,
 	}
 
-	return client.ExecuteCommand(ctx, req)
+	resp, err := client.ExecuteCommand(ctx, req)
+	if gs.deviceService != nil {
+		gs.deviceService.publishExecution(deviceID, commandID, err == nil && resp.Success)
+	}
+	return resp, err
+}
+
+// ExecutionTarget is a device of a bulk execution with the device role of the
+// user the command runs for
+type ExecutionTarget struct {
+	DeviceID   string
+	CallerRole string
+}
+
+// DeviceExecutionResult holds the outcome of a command on one device in a bulk execution
+type DeviceExecutionResult struct {
+	DeviceID string
+	Response *controllerPb.ExecuteCommandResponse
+	Err      error
 }
 
-// ListCommands retrieves all commands from a device
-func (gs *GatewayService) ListCommands(deviceID string) (*controllerPb.ListCommandsResponse, error) {
+// ExecuteCommandOnDevices executes a command on several devices, at most
+// Batch.Concurrency at a time and within Batch.Timeout overall. Devices not
+// reached before the timeout report ErrBatchTimeout. Results are returned in
+// the same order as targets.
+func (gs *GatewayService) ExecuteCommandOnDevices(targets []ExecutionTarget, commandID string, timeout int32) []DeviceExecutionResult {
+	results := make([]DeviceExecutionResult, len(targets))
+
+	ctx, cancel := gs.batchContext()
+	defer cancel()
+
+	concurrency := gs.config.Batch.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(targets)
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i] = DeviceExecutionResult{DeviceID: target.DeviceID, Err: ErrBatchTimeout}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target ExecutionTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := gs.ExecuteCommand(ctx, target.DeviceID, commandID, target.CallerRole, timeout)
+			if err != nil && ctx.Err() != nil {
+				err = ErrBatchTimeout
+			}
+			results[i] = DeviceExecutionResult{
+				DeviceID: target.DeviceID,
+				Response: resp,
+				Err:      err,
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// callContext bounds a device call by the configured request timeout unless
+// the caller already set a deadline
+func (gs *GatewayService) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || gs.config.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(gs.config.RequestTimeout)*time.Second)
+}
+
+// ListCommands retrieves the commands of a device visible to a user with
+// callerRole on it, bounded by ctx
+func (gs *GatewayService) ListCommands(ctx context.Context, deviceID, callerRole string) (*controllerPb.ListCommandsResponse, error) {
 	client, err := gs.GetDeviceClient(deviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := gs.callContext(ctx)
 	defer cancel()
 
-	req := &controllerPb.ListCommandsRequest{}
+	req := &controllerPb.ListCommandsRequest{CallerRole: callerRole}
 	return client.ListCommands(ctx, req)
 }
 
-// ReloadConfig reloads configuration on a device
-func (gs *GatewayService) ReloadConfig(deviceID string) (*controllerPb.ReloadConfigResponse, error) {
+// ReloadConfig reloads configuration on a device, bounded by ctx
+func (gs *GatewayService) ReloadConfig(ctx context.Context, deviceID string) (*controllerPb.ReloadConfigResponse, error) {
 	client, err := gs.GetDeviceClient(deviceID)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := gs.callContext(ctx)
 	defer cancel()
 
 	req := &controllerPb.ReloadConfigRequest{}
 	return client.ReloadConfig(ctx, req)
 }
 
+// UpdateAgent asks a device to install a signed agent release, bounded by ctx.
+// The device answers once the update has started; progress shows in its health check
+func (gs *GatewayService) UpdateAgent(ctx context.Context, deviceID string, req *controllerPb.UpdateAgentRequest) (*controllerPb.UpdateAgentResponse, error) {
+	client, err := gs.GetDeviceClient(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := gs.callContext(ctx)
+	defer cancel()
+
+	return client.UpdateAgent(ctx, req)
+}
+
 // VerifyPin verifies PIN on a device
 func (gs *GatewayService) VerifyPin(deviceID, pin string) (*controllerPb.VerifyPinResponse, error) {
 	client, err := gs.GetDeviceClient(deviceID)
@@ -321,6 +1143,9 @@ func (gs *GatewayService) Stop() error {
 
 	var lastErr error
 	for deviceID, conn := range gs.connections {
+		if conn.Connection == nil {
+			continue
+		}
 		if err := conn.Connection.Close(); err != nil {
 			log.Printf("Error closing connection for device %s: %v", deviceID, err)
 			lastErr = err
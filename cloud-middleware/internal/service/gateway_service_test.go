@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+)
+
+func TestNewGatewayServiceCredentials(t *testing.T) {
+	missingCA := config.GatewayConfig{}
+	missingCA.TLS.Enabled = true
+	missingCA.TLS.CAFile = filepath.Join(t.TempDir(), "missing-ca.pem")
+
+	tests := []struct {
+		name        string
+		cfg         config.GatewayConfig
+		wantErr     bool
+		wantDialErr bool
+	}{
+		{"plaintext allowed", config.GatewayConfig{AllowInsecure: true}, false, false},
+		{"neither TLS nor plaintext starts for the tunnel only", config.GatewayConfig{}, false, true},
+		{"unreadable TLS files fail", missingCA, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, err := NewGatewayService(tt.cfg, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGatewayService() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !tt.wantDialErr {
+				if gs.dialErr != nil {
+					t.Fatalf("direct connections disabled: %v", gs.dialErr)
+				}
+				return
+			}
+			_, _, err = gs.dialWithRetry("dev-1", []string{"127.0.0.1:1"}, 0)
+			if !errors.Is(err, errInsecureNotAllowed) {
+				t.Fatalf("dial error = %v, want %v", err, errInsecureNotAllowed)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandOnDevicesBatchLimits(t *testing.T) {
+	// Pairs of devices finish at 400ms and 800ms; the third pair would finish
+	// at 1.2s, past the 1s batch timeout
+	tests := []struct {
+		name          string
+		batch         config.BatchConfig
+		devices       int
+		wantSucceeded int
+		wantMaxAtOnce int32
+	}{
+		{"all within the timeout", config.BatchConfig{Concurrency: 2, Timeout: 5}, 4, 4, 2},
+		{"timeout stops the rest", config.BatchConfig{Concurrency: 2, Timeout: 1}, 6, 4, 2},
+		{"unlimited concurrency", config.BatchConfig{Timeout: 5}, 6, 6, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &mockDevice{execDelay: 400 * time.Millisecond}
+			address := startMockDevice(t, device)
+			gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true, Batch: tt.batch}, nil)
+			if err != nil {
+				t.Fatalf("create gateway service: %v", err)
+			}
+			targets := make([]ExecutionTarget, tt.devices)
+			for i := range targets {
+				targets[i] = ExecutionTarget{DeviceID: fmt.Sprintf("dev-%d", i), CallerRole: "user"}
+				if err := gs.connectDevice(targets[i].DeviceID, []string{address}); err != nil {
+					t.Fatalf("connect: %v", err)
+				}
+				defer gs.RemoveDevice(targets[i].DeviceID)
+			}
+
+			results := gs.ExecuteCommandOnDevices(targets, "uptime", 0)
+
+			succeeded := 0
+			for i, result := range results {
+				if result.DeviceID != targets[i].DeviceID {
+					t.Fatalf("result %d is for %s, want %s", i, result.DeviceID, targets[i].DeviceID)
+				}
+				switch {
+				case result.Err == nil:
+					succeeded++
+				case !errors.Is(result.Err, ErrBatchTimeout):
+					t.Errorf("%s failed with %v, want success or ErrBatchTimeout", result.DeviceID, result.Err)
+				}
+			}
+			if succeeded != tt.wantSucceeded {
+				t.Errorf("%d devices succeeded, want %d", succeeded, tt.wantSucceeded)
+			}
+			// Devices are started in order, so the ones cut off are the last
+			for _, result := range results[tt.wantSucceeded:] {
+				if !errors.Is(result.Err, ErrBatchTimeout) {
+					t.Errorf("%s: err = %v, want ErrBatchTimeout", result.DeviceID, result.Err)
+				}
+			}
+			if got := device.maxRunning.Load(); got != tt.wantMaxAtOnce {
+				t.Errorf("%d executions ran at once, want %d", got, tt.wantMaxAtOnce)
+			}
+		})
+	}
+}
+
+// unreachableAddress returns a local address nothing listens on
+func unreachableAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestNextAddressIndex(t *testing.T) {
+	addresses := []string{"lan:50051", "vpn:50051", "wan:50051"}
+	tests := []struct {
+		failed string
+		want   int
+	}{
+		{"lan:50051", 1},
+		{"vpn:50051", 2},
+		{"wan:50051", 0},
+		{"unknown:50051", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.failed, func(t *testing.T) {
+			if got := nextAddressIndex(addresses, tt.failed); got != tt.want {
+				t.Errorf("nextAddressIndex(%q) = %d, want %d", tt.failed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectDeviceFailover(t *testing.T) {
+	up := startMockDevice(t, &mockDevice{})
+	down := unreachableAddress(t)
+
+	tests := []struct {
+		name        string
+		addresses   []string
+		wantAddress string
+		wantErr     bool
+	}{
+		{"primary up", []string{up, down}, up, false},
+		{"primary down, secondary up", []string{down, up}, up, false},
+		{"every address down", []string{down, unreachableAddress(t)}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true}, nil)
+			if err != nil {
+				t.Fatalf("create gateway service: %v", err)
+			}
+			gs.connectTimeout = 300 * time.Millisecond
+
+			err = gs.AddDevice("dev-1", tt.addresses)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("AddDevice succeeded, want an error")
+				}
+				if _, err := gs.GetDeviceStatus("dev-1"); err == nil {
+					t.Error("unreachable device is still registered")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddDevice: %v", err)
+			}
+			defer gs.RemoveDevice("dev-1")
+
+			conn, err := gs.GetDeviceStatus("dev-1")
+			if err != nil {
+				t.Fatalf("status: %v", err)
+			}
+			if conn.Address != tt.wantAddress {
+				t.Errorf("active address = %s, want %s", conn.Address, tt.wantAddress)
+			}
+			resp, err := gs.ExecuteCommand(context.Background(), "dev-1", "uptime", "user", 5)
+			if err != nil || !resp.Success {
+				t.Errorf("execute = %v, %v, want success", resp, err)
+			}
+		})
+	}
+}
+
+func TestReconnectDeviceFailover(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	primary := grpc.NewServer()
+	controllerPb.RegisterControllerServiceServer(primary, &mockDevice{})
+	go primary.Serve(listener)
+	defer primary.Stop()
+	primaryAddress := listener.Addr().String()
+	secondaryAddress := startMockDevice(t, &mockDevice{})
+
+	gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true}, nil)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	gs.connectTimeout = 300 * time.Millisecond
+	if err := gs.AddDevice("dev-1", []string{primaryAddress, secondaryAddress}); err != nil {
+		t.Fatalf("AddDevice: %v", err)
+	}
+	defer gs.RemoveDevice("dev-1")
+	conn, err := gs.GetDeviceStatus("dev-1")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	activeAddress := func() (string, bool) {
+		conn.mutex.RLock()
+		defer conn.mutex.RUnlock()
+		return conn.Address, conn.IsHealthy
+	}
+	if address, _ := activeAddress(); address != primaryAddress {
+		t.Fatalf("active address = %s, want the primary %s", address, primaryAddress)
+	}
+
+	// Losing the primary fails over to the secondary
+	primary.Stop()
+	waitFor(t, "failover to the secondary", func() bool {
+		address, healthy := activeAddress()
+		return address == secondaryAddress && healthy
+	})
+	resp, err := gs.ExecuteCommand(context.Background(), "dev-1", "uptime", "user", 5)
+	if err != nil || !resp.Success {
+		t.Errorf("execute after failover = %v, %v, want success", resp, err)
+	}
+}
+
+func TestDeviceCallsForwardCallerRole(t *testing.T) {
+	device := &mockDevice{release: make(chan struct{})}
+	close(device.release)
+	address := startMockDevice(t, device)
+	gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true}, nil)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+	if err := gs.connectDevice("dev-1", []string{address}); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer gs.RemoveDevice("dev-1")
+
+	// The device decides on admin-only commands from the role it is told
+	if _, err := gs.ExecuteCommand(context.Background(), "dev-1", "uptime", "admin", 5); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := device.callerRole.Load(); got != "admin" {
+		t.Errorf("execute caller role = %v, want admin", got)
+	}
+	if _, err := gs.ListCommands(context.Background(), "dev-1", "viewer"); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := device.callerRole.Load(); got != "viewer" {
+		t.Errorf("list caller role = %v, want viewer", got)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// QueueExecution queues a command for a device that is not connected. It runs
+// when the device reconnects, unless it expires after the configured TTL first
+func (gs *GatewayService) QueueExecution(userID, deviceID, commandID string, timeout int32) (*model.PendingExecution, error) {
+	if gs.deviceService == nil {
+		return nil, fmt.Errorf("execution queue is not available")
+	}
+	return gs.deviceService.QueueExecution(userID, deviceID, commandID, timeout, time.Duration(gs.config.Queue.TTL)*time.Second)
+}
+
+// IsDeviceConnected reports whether a device has a healthy connection
+func (gs *GatewayService) IsDeviceConnected(deviceID string) bool {
+	_, err := gs.GetDeviceClient(deviceID)
+	return err == nil
+}
+
+// queueLock returns the lock serializing deliveries of a device's queued executions
+func (gs *GatewayService) queueLock(deviceID string) *sync.Mutex {
+	lock, _ := gs.queueLocks.LoadOrStore(deviceID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// deliverPendingExecutions runs the commands queued for a device, oldest first,
+// and records their outcome. Executions are left queued if the device goes
+// away again mid-way
+func (gs *GatewayService) deliverPendingExecutions(deviceID string) {
+	if gs.deviceService == nil {
+		return
+	}
+
+	// Reconnects and health checks both trigger deliveries; one at a time is enough
+	lock := gs.queueLock(deviceID)
+	if !lock.TryLock() {
+		return
+	}
+	defer lock.Unlock()
+
+	executions, err := gs.deviceService.ListPendingExecutions(deviceID)
+	if err != nil {
+		log.Printf("Failed to list pending executions of device %s: %v", deviceID, err)
+		return
+	}
+
+	delivered := 0
+	for _, execution := range executions {
+		if !gs.IsDeviceConnected(deviceID) {
+			break
+		}
+
+		claimed, err := gs.deviceService.claimPendingExecution(execution.ID)
+		if err != nil {
+			log.Printf("Failed to claim pending execution %s: %v", execution.ID, err)
+			break
+		}
+		if !claimed {
+			continue
+		}
+
+		var resp *controllerPb.ExecuteCommandResponse
+		allowed, err := gs.mayDeliver(execution)
+		if err == nil && allowed {
+			// The device is told the role the user holds now, not when queueing
+			var callerRole string
+			callerRole, err = gs.deviceService.UserDeviceRole(execution.UserID, deviceID)
+			if err == nil {
+				resp, err = gs.ExecuteCommand(context.Background(), deviceID, execution.CommandID, callerRole, execution.Timeout)
+			}
+		}
+		var duration int64
+		switch {
+		case err != nil:
+			execution.Status = model.PendingExecutionFailed
+			execution.Error = err.Error()
+		case !allowed:
+			execution.Status = model.PendingExecutionDenied
+			execution.Error = "user no longer has permission to execute this command on the device"
+		default:
+			execution.Status = model.PendingExecutionCompleted
+			execution.Success = resp.Success
+			execution.Output = resp.Output
+			execution.Error = resp.Error
+			execution.ExitCode = int(resp.ExitCode)
+			duration = resp.ExecutionTimeMs
+		}
+		completedAt := time.Now()
+		execution.CompletedAt = &completedAt
+
+		if err := gs.deviceService.completePendingExecution(execution, duration); err != nil {
+			log.Printf("Failed to record pending execution %s: %v", execution.ID, err)
+		}
+		delivered++
+	}
+
+	if delivered > 0 {
+		log.Printf("Delivered %d queued executions to device %s", delivered, deviceID)
+	}
+}
+
+// mayDeliver rechecks that the user who queued an execution may still run it,
+// as their role or the command may have changed since. Denials are recorded
+// like those of direct executions
+func (gs *GatewayService) mayDeliver(execution *model.PendingExecution) (bool, error) {
+	requiredRole, err := gs.deviceService.CommandExecuteRole(execution.DeviceID, execution.CommandID)
+	if err != nil {
+		return false, err
+	}
+	allowed, err := gs.deviceService.CheckUserDevicePermission(execution.UserID, execution.DeviceID, requiredRole)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		gs.deviceService.RecordAccessDenied(execution.UserID, execution.DeviceID, requiredRole, "execute_queued_command")
+	}
+	return allowed, nil
+}
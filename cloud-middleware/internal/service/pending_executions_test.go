@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+)
+
+func TestDeliverPendingExecutionsRechecksPermission(t *testing.T) {
+	ds := newAdminOnlyDeviceService(t)
+	device := &mockDevice{release: make(chan struct{})}
+	close(device.release)
+	address := startMockDevice(t, device)
+
+	gs, err := NewGatewayService(config.GatewayConfig{AllowInsecure: true}, ds)
+	if err != nil {
+		t.Fatalf("create gateway service: %v", err)
+	}
+
+	// Queueing checked the user, but their role or the command may change
+	// before the device is back
+	tests := []struct {
+		name       string
+		userID     string
+		commandID  string
+		wantStatus string
+	}{
+		{"device user", "user", "uptime", model.PendingExecutionCompleted},
+		{"device admin on an admin-only command", "admin", "reboot", model.PendingExecutionCompleted},
+		{"device user on an admin-only command", "user", "reboot", model.PendingExecutionDenied},
+		{"viewer", "viewer", "uptime", model.PendingExecutionDenied},
+		{"disabled binding", "disabled-admin", "uptime", model.PendingExecutionDenied},
+		{"no longer a member", "stranger", "uptime", model.PendingExecutionDenied},
+	}
+	executions := make([]*model.PendingExecution, len(tests))
+	for i, tt := range tests {
+		executions[i], err = ds.QueueExecution(tt.userID, "dev-1", tt.commandID, 5, time.Minute)
+		if err != nil {
+			t.Fatalf("queue %s: %v", tt.name, err)
+		}
+	}
+
+	if err := gs.connectDevice("dev-1", []string{address}); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer gs.RemoveDevice("dev-1")
+	gs.deliverPendingExecutions("dev-1")
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execution, err := ds.GetPendingExecution("dev-1", executions[i].ID)
+			if err != nil {
+				t.Fatalf("get execution: %v", err)
+			}
+			if execution.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s: %s", execution.Status, tt.wantStatus, execution.Error)
+			}
+		})
+	}
+
+	logs, total, err := ds.ListAccessLogs(repository.AccessLogFilter{DeviceID: "dev-1"}, 0, 10)
+	if err != nil {
+		t.Fatalf("list access logs: %v", err)
+	}
+	if total != 4 {
+		t.Fatalf("recorded %d denials, want 4", total)
+	}
+	for _, entry := range logs {
+		if entry.Action != "execute_queued_command" {
+			t.Errorf("denial action = %s, want execute_queued_command", entry.Action)
+		}
+	}
+}
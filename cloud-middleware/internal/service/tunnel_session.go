@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	controllerPb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// TunnelStream is the server side of a device-initiated Connect stream
+type TunnelStream = grpc.BidiStreamingServer[controllerPb.TunnelMessage, controllerPb.TunnelMessage]
+
+// TunnelSession routes requests to a device over its outbound tunnel stream
+type TunnelSession struct {
+	deviceID string
+	stream   TunnelStream
+
+	sendMutex sync.Mutex
+	mutex     sync.Mutex
+	pending   map[string]chan *controllerPb.TunnelResponse
+	nextID    uint64
+	closed    bool
+	done      chan struct{}
+}
+
+// NewTunnelSession creates a tunnel session for a registered device stream
+func NewTunnelSession(deviceID string, stream TunnelStream) *TunnelSession {
+	return &TunnelSession{
+		deviceID: deviceID,
+		stream:   stream,
+		pending:  make(map[string]chan *controllerPb.TunnelResponse),
+		done:     make(chan struct{}),
+	}
+}
+
+// Serve reads responses from the device until the stream ends
+func (ts *TunnelSession) Serve() error {
+	defer ts.close()
+
+	for {
+		msg, err := ts.stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		resp := msg.GetResponse()
+		if resp == nil {
+			continue
+		}
+
+		ts.mutex.Lock()
+		ch, exists := ts.pending[msg.RequestId]
+		delete(ts.pending, msg.RequestId)
+		ts.mutex.Unlock()
+
+		if exists {
+			ch <- resp
+		}
+	}
+}
+
+// Done is closed once the tunnel stream has ended
+func (ts *TunnelSession) Done() <-chan struct{} {
+	return ts.done
+}
+
+// close fails all in-flight requests and marks the session closed
+func (ts *TunnelSession) close() {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if ts.closed {
+		return
+	}
+	ts.closed = true
+	for id, ch := range ts.pending {
+		close(ch)
+		delete(ts.pending, id)
+	}
+	close(ts.done)
+}
+
+// call sends a request over the tunnel and waits for the matching response
+func (ts *TunnelSession) call(ctx context.Context, req *controllerPb.TunnelRequest) (*controllerPb.TunnelResponse, error) {
+	ts.mutex.Lock()
+	if ts.closed {
+		ts.mutex.Unlock()
+		return nil, status.Errorf(codes.Unavailable, "tunnel to device %s is closed", ts.deviceID)
+	}
+	requestID := strconv.FormatUint(atomic.AddUint64(&ts.nextID, 1), 10)
+	ch := make(chan *controllerPb.TunnelResponse, 1)
+	ts.pending[requestID] = ch
+	ts.mutex.Unlock()
+
+	ts.sendMutex.Lock()
+	err := ts.stream.Send(&controllerPb.TunnelMessage{
+		RequestId: requestID,
+		Payload:   &controllerPb.TunnelMessage_Request{Request: req},
+	})
+	ts.sendMutex.Unlock()
+	if err != nil {
+		ts.forget(requestID)
+		return nil, status.Errorf(codes.Unavailable, "failed to send request to device %s: %v", ts.deviceID, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, status.Errorf(codes.Unavailable, "tunnel to device %s closed", ts.deviceID)
+		}
+		if resp.Error != "" {
+			return nil, status.Error(codes.Unknown, resp.Error)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		ts.forget(requestID)
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// forget drops a pending request that will not be awaited
+func (ts *TunnelSession) forget(requestID string) {
+	ts.mutex.Lock()
+	delete(ts.pending, requestID)
+	ts.mutex.Unlock()
+}
+
+// tunnelClient adapts a TunnelSession to the ControllerServiceClient interface
+// so gateway callers do not need to know how the device is reached
+type tunnelClient struct {
+	session *TunnelSession
+}
+
+// ExecuteCommand executes a command over the tunnel
+func (tc *tunnelClient) ExecuteCommand(ctx context.Context, in *controllerPb.ExecuteCommandRequest, opts ...grpc.CallOption) (*controllerPb.ExecuteCommandResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_ExecuteCommand{ExecuteCommand: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetExecuteCommand() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for ExecuteCommand")
+	}
+	return resp.GetExecuteCommand(), nil
+}
+
+// ListCommands lists commands over the tunnel
+func (tc *tunnelClient) ListCommands(ctx context.Context, in *controllerPb.ListCommandsRequest, opts ...grpc.CallOption) (*controllerPb.ListCommandsResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_ListCommands{ListCommands: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetListCommands() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for ListCommands")
+	}
+	return resp.GetListCommands(), nil
+}
+
+// HealthCheck checks device health over the tunnel
+func (tc *tunnelClient) HealthCheck(ctx context.Context, in *controllerPb.HealthCheckRequest, opts ...grpc.CallOption) (*controllerPb.HealthCheckResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_HealthCheck{HealthCheck: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetHealthCheck() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for HealthCheck")
+	}
+	return resp.GetHealthCheck(), nil
+}
+
+// Ping measures latency over the tunnel
+func (tc *tunnelClient) Ping(ctx context.Context, in *controllerPb.PingRequest, opts ...grpc.CallOption) (*controllerPb.PingResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_Ping{Ping: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetPing() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for Ping")
+	}
+	return resp.GetPing(), nil
+}
+
+// SyncCommands pushes device commands over the tunnel
+func (tc *tunnelClient) SyncCommands(ctx context.Context, in *controllerPb.SyncCommandsRequest, opts ...grpc.CallOption) (*controllerPb.SyncCommandsResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_SyncCommands{SyncCommands: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetSyncCommands() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for SyncCommands")
+	}
+	return resp.GetSyncCommands(), nil
+}
+
+// UpdateAgent asks the device to update itself over the tunnel
+func (tc *tunnelClient) UpdateAgent(ctx context.Context, in *controllerPb.UpdateAgentRequest, opts ...grpc.CallOption) (*controllerPb.UpdateAgentResponse, error) {
+	resp, err := tc.session.call(ctx, &controllerPb.TunnelRequest{
+		Request: &controllerPb.TunnelRequest_UpdateAgent{UpdateAgent: in},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetUpdateAgent() == nil {
+		return nil, fmt.Errorf("unexpected tunnel response for UpdateAgent")
+	}
+	return resp.GetUpdateAgent(), nil
+}
+
+// ReloadConfig is not available over the tunnel
+func (tc *tunnelClient) ReloadConfig(ctx context.Context, in *controllerPb.ReloadConfigRequest, opts ...grpc.CallOption) (*controllerPb.ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "ReloadConfig is not supported over tunnel")
+}
+
+// VerifyPin is not available over the tunnel
+func (tc *tunnelClient) VerifyPin(ctx context.Context, in *controllerPb.VerifyPinRequest, opts ...grpc.CallOption) (*controllerPb.VerifyPinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "VerifyPin is not supported over tunnel")
+}
+
+// GetVersion is not available over the tunnel
+func (tc *tunnelClient) GetVersion(ctx context.Context, in *controllerPb.GetVersionRequest, opts ...grpc.CallOption) (*controllerPb.GetVersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "GetVersion is not supported over tunnel")
+}
+
+// GetStatus is not available over the tunnel
+func (tc *tunnelClient) GetStatus(ctx context.Context, in *controllerPb.GetStatusRequest, opts ...grpc.CallOption) (*controllerPb.GetStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "GetStatus is not supported over tunnel")
+}
+
+// TailLogs is not available over the tunnel
+func (tc *tunnelClient) TailLogs(ctx context.Context, in *controllerPb.TailLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[controllerPb.LogLine], error) {
+	return nil, status.Errorf(codes.Unimplemented, "TailLogs is not supported over tunnel")
+}
+
+// UploadFile is not available over the tunnel
+func (tc *tunnelClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[controllerPb.UploadFileChunk, controllerPb.UploadFileResponse], error) {
+	return nil, status.Errorf(codes.Unimplemented, "UploadFile is not supported over tunnel")
+}
+
+// DownloadFile is not available over the tunnel
+func (tc *tunnelClient) DownloadFile(ctx context.Context, in *controllerPb.DownloadFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[controllerPb.FileChunk], error) {
+	return nil, status.Errorf(codes.Unimplemented, "DownloadFile is not supported over tunnel")
+}
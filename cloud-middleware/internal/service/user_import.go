@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// MaxUserImportRows bounds a single import; every created user costs a bcrypt hash
+const MaxUserImportRows = 500
+
+// tempPasswordBytes is the entropy of generated temporary passwords, which
+// are hex encoded to twice as many characters
+const tempPasswordBytes = 8
+
+// ImportUserRow is one user of a bulk import
+type ImportUserRow struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Password string `json:"password"` // optional; a temporary password is generated when empty
+}
+
+// ImportedUser is a user created by a bulk import
+type ImportedUser struct {
+	Row          int    `json:"row"` // 1-based position in the import
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	TempPassword string `json:"temp_password,omitempty"` // generated password, only ever returned here
+}
+
+// UserImportIssue is a row a bulk import did not create
+type UserImportIssue struct {
+	Row      int    `json:"row"`
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+// UserImportResult reports what a bulk import did with each row
+type UserImportResult struct {
+	Created []ImportedUser    `json:"created"`
+	Skipped []UserImportIssue `json:"skipped"` // username or email already taken
+	Errors  []UserImportIssue `json:"errors"`  // rows failing validation
+}
+
+// ImportUsers validates every row like CreateUser and creates the valid ones
+// in a single transaction. Rows whose username or email already exists, in
+// the system or earlier in the import, are skipped. Nothing is created when
+// the transaction fails
+func (s *userService) ImportUsers(rows []ImportUserRow) (*UserImportResult, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no users to import")
+	}
+	if len(rows) > MaxUserImportRows {
+		return nil, fmt.Errorf("at most %d users can be imported at once", MaxUserImportRows)
+	}
+
+	result := &UserImportResult{
+		Created: []ImportedUser{},
+		Skipped: []UserImportIssue{},
+		Errors:  []UserImportIssue{},
+	}
+	usernames := make(map[string]bool, len(rows))
+	emails := make(map[string]bool, len(rows))
+	var users []*model.User
+	for i, row := range rows {
+		req := &CreateUserRequest{
+			Username: strings.TrimSpace(row.Username),
+			Email:    strings.TrimSpace(row.Email),
+			Password: row.Password,
+			Role:     strings.TrimSpace(row.Role),
+		}
+		issue := UserImportIssue{Row: i + 1, Username: req.Username}
+
+		tempPassword := ""
+		if req.Password == "" {
+			password, err := generateTempPassword()
+			if err != nil {
+				return nil, err
+			}
+			req.Password, tempPassword = password, password
+		}
+		if err := s.validateCreateUserRequest(req); err != nil {
+			issue.Reason = err.Error()
+			result.Errors = append(result.Errors, issue)
+			continue
+		}
+
+		switch {
+		case usernames[req.Username] || s.userRepo.IsUsernameExists(req.Username):
+			issue.Reason = "username already exists"
+		case emails[req.Email] || s.userRepo.IsEmailExists(req.Email):
+			issue.Reason = "email already exists"
+		}
+		if issue.Reason != "" {
+			result.Skipped = append(result.Skipped, issue)
+			continue
+		}
+		usernames[req.Username] = true
+		emails[req.Email] = true
+
+		if req.Role == "" {
+			req.Role = "user"
+		}
+		users = append(users, &model.User{
+			Username: req.Username,
+			Email:    req.Email,
+			Password: req.Password,
+			Role:     req.Role,
+			Status:   "active",
+			Settings: defaultUserSettings(),
+		})
+		result.Created = append(result.Created, ImportedUser{
+			Row:          issue.Row,
+			Username:     req.Username,
+			Email:        req.Email,
+			Role:         req.Role,
+			TempPassword: tempPassword,
+		})
+	}
+
+	if len(users) > 0 {
+		if err := s.userRepo.CreateBatch(users); err != nil {
+			return nil, err
+		}
+	}
+	for i, user := range users {
+		result.Created[i].ID = user.ID
+	}
+
+	log.Printf("Imported %d users, skipped %d, rejected %d", len(result.Created), len(result.Skipped), len(result.Errors))
+	return result, nil
+}
+
+// ExportUsers returns every user without password hashes
+func (s *userService) ExportUsers() ([]*model.User, error) {
+	users, _, err := s.userRepo.List(0, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		user.Password = ""
+	}
+	return users, nil
+}
+
+// generateTempPassword returns a random password for an imported user
+func generateTempPassword() (string, error) {
+	secret := make([]byte, tempPasswordBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
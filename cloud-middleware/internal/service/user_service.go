@@ -1,10 +1,16 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
 
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
@@ -12,12 +18,43 @@ import (
 	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
 )
 
+// ErrRefreshTokenReused is returned when a refresh token is presented after
+// it was already exchanged. The token may have leaked, so its whole family
+// is revoked and the user has to log in again
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// ErrSessionNotFound is returned when revoking a session the user does not have
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrChallengeExhausted is returned once a login challenge has seen too many
+// wrong codes; the user has to sign in again for a new one
+var ErrChallengeExhausted = errors.New("too many failed attempts, please sign in again")
+
 // UserService defines the interface for user business logic
 type UserService interface {
 	// Authentication
-	Login(username, password string) (*LoginResult, error)
+	Login(username, password string, client SessionClient) (*LoginResult, error)
 	RefreshToken(refreshToken string) (*auth.TokenPair, error)
 	Logout(userID, refreshToken string) error
+	OAuthLogin(provider string, info *auth.OAuthUserInfo, client SessionClient) (*LoginResult, error)
+	CompleteTwoFactorLogin(challengeToken, code string, client SessionClient) (*LoginResult, error)
+	ValidateAccessToken(tokenString string) (*auth.Claims, error)
+
+	// Sessions
+	ListSessions(userID string) ([]model.Session, error)
+	RevokeSession(userID, sessionID string) error
+
+	// Two-factor authentication
+	EnrollTwoFactor(userID string) (*TwoFactorEnrollment, error)
+	ConfirmTwoFactor(userID, code string) ([]string, error)
+
+	// WebAuthn (passkey) login
+	BeginWebAuthnRegistration(userID string) (*WebAuthnCeremony, error)
+	FinishWebAuthnRegistration(userID, sessionToken, name string, response []byte) (*model.WebAuthnCredential, error)
+	BeginWebAuthnLogin(username string) (*WebAuthnCeremony, error)
+	FinishWebAuthnLogin(sessionToken string, response []byte, client SessionClient) (*LoginResult, error)
+	ListWebAuthnCredentials(userID string) ([]model.WebAuthnCredential, error)
+	DeleteWebAuthnCredential(userID string, id uint) error
 
 	// User Management (Admin only)
 	CreateUser(req *CreateUserRequest) (*model.User, error)
@@ -26,6 +63,8 @@ type UserService interface {
 	DeleteUser(userID string) error
 	ListUsers(offset, limit int) ([]*model.User, int64, error)
 	SetUserRole(userID, role string) error
+	ImportUsers(rows []ImportUserRow) (*UserImportResult, error)
+	ExportUsers() ([]*model.User, error)
 
 	// Profile Management
 	GetProfile(userID string) (*model.User, error)
@@ -37,10 +76,31 @@ type UserService interface {
 	IsAdmin(userID string) (bool, error)
 }
 
-// LoginResult represents login response
+// LoginResult represents login response. Users with two-factor
+// authentication get a Challenge instead of Tokens
 type LoginResult struct {
-	User   *model.User      `json:"user"`
-	Tokens *auth.TokenPair  `json:"tokens"`
+	User      *model.User         `json:"user"`
+	Tokens    *auth.TokenPair     `json:"tokens"`
+	Challenge *TwoFactorChallenge `json:"challenge,omitempty"`
+}
+
+// SessionClient describes the client a login comes from, recorded with its session
+type SessionClient struct {
+	Device    string // name the client gave, may be empty
+	UserAgent string
+	IPAddress string
+}
+
+// TwoFactorChallenge is exchanged for tokens with a TOTP or recovery code
+type TwoFactorChallenge struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TwoFactorEnrollment is a pending TOTP secret to add to an authenticator app
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"` // otpauth:// URL
 }
 
 // CreateUserRequest represents create user request
@@ -73,31 +133,90 @@ type UpdateProfileRequest struct {
 
 // userService implements UserService interface
 type userService struct {
-	userRepo   repository.UserRepository
-	jwtService *auth.JWTService
+	userRepo        repository.UserRepository
+	jwtService      *auth.JWTService
+	secretCipher    *auth.SecretCipher
+	twoFactorIssuer string
+	webAuthn        *webauthn.WebAuthn // nil while passkey login is not configured
+	challenges      *challengeAttempts
 }
 
 // NewUserService creates a new user service
-func NewUserService(userRepo repository.UserRepository, jwtConfig config.JWTConfig) UserService {
-	return &userService{
-		userRepo:   userRepo,
-		jwtService: auth.NewJWTService(jwtConfig),
+func NewUserService(userRepo repository.UserRepository, jwtService *auth.JWTService, jwtConfig config.JWTConfig, twoFactorConfig config.TwoFactorConfig, webAuthnConfig config.WebAuthnConfig) (UserService, error) {
+	encryptionKey := twoFactorConfig.EncryptionKey
+	if encryptionKey == "" {
+		encryptionKey = jwtConfig.SecretKey
+	}
+	secretCipher, err := auth.NewSecretCipher(encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create two-factor secret cipher: %w", err)
 	}
+	webAuthn, err := newWebAuthn(webAuthnConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure webauthn: %w", err)
+	}
+
+	return &userService{
+		userRepo:        userRepo,
+		jwtService:      jwtService,
+		secretCipher:    secretCipher,
+		twoFactorIssuer: twoFactorConfig.Issuer,
+		webAuthn:        webAuthn,
+		challenges:      newChallengeAttempts(),
+	}, nil
 }
 
 // Login authenticates user and returns tokens
-func (s *userService) Login(username, password string) (*LoginResult, error) {
+func (s *userService) Login(username, password string, client SessionClient) (*LoginResult, error) {
 	// Validate credentials
 	user, err := s.userRepo.ValidateCredentials(username, password)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.startSession(user, client)
+}
+
+// startSession issues tokens for an authenticated user, or a challenge when
+// the user still has to present a second factor
+func (s *userService) startSession(user *model.User, client SessionClient) (*LoginResult, error) {
+	if twoFactorEnabled(user) {
+		token, expiresAt, err := s.jwtService.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate challenge: %w", err)
+		}
+
+		user.Password = ""
+		return &LoginResult{
+			User:      user,
+			Challenge: &TwoFactorChallenge{Token: token, ExpiresAt: expiresAt},
+		}, nil
+	}
+
+	return s.issueTokens(user, client)
+}
+
+// issueTokens generates a token pair for a fully authenticated user and
+// records the session it starts
+func (s *userService) issueTokens(user *model.User, client SessionClient) (*LoginResult, error) {
 	// Generate tokens
 	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Username, user.Email, user.Role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
+	if err := s.storeRefreshToken(user.ID, tokens); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.CreateSession(&model.Session{
+		ID:        tokens.FamilyID,
+		UserID:    user.ID,
+		Device:    client.Device,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: tokens.RefreshExpiresAt,
+	}); err != nil {
+		return nil, err
+	}
 
 	// Remove password from response
 	user.Password = ""
@@ -108,21 +227,363 @@ func (s *userService) Login(username, password string) (*LoginResult, error) {
 	}, nil
 }
 
-// RefreshToken generates new tokens from refresh token
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery code for tokens.
+// A challenge stops accepting codes after maxChallengeFailures wrong ones
+func (s *userService) CompleteTwoFactorLogin(challengeToken, code string, client SessionClient) (*LoginResult, error) {
+	challenge, err := s.jwtService.ValidateChallengeToken(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if s.challenges.exhausted(challenge) {
+		return nil, ErrChallengeExhausted
+	}
+
+	user, err := s.userRepo.GetByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != "active" {
+		return nil, errors.New("user account is disabled")
+	}
+	if !twoFactorEnabled(user) {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	if err := s.verifySecondFactor(user, code, true); err != nil {
+		if s.challenges.fail(challenge) {
+			return nil, ErrChallengeExhausted
+		}
+		return nil, err
+	}
+	s.challenges.forget(challenge)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return s.issueTokens(user, client)
+}
+
+// EnrollTwoFactor starts enrollment with a new TOTP secret. It takes effect
+// once ConfirmTwoFactor sees a valid code
+func (s *userService) EnrollTwoFactor(userID string) (*TwoFactorEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorEnabled(user) {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	encrypted, err := s.secretCipher.Encrypt(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	user.TOTPSecret = encrypted
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return &TwoFactorEnrollment{
+		Secret: secret,
+		URL:    auth.TOTPURL(s.twoFactorIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication after checking a code
+// from the enrolled app, and returns the recovery codes. Only their hashes
+// are stored, so they cannot be shown again
+func (s *userService) ConfirmTwoFactor(userID, code string) ([]string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorEnabled(user) {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrollment has not been started")
+	}
+
+	if err := s.verifySecondFactor(user, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	user.RecoveryCodes = hashes
+	if user.Settings == nil {
+		user.Settings = &model.UserSettings{}
+	}
+	user.Settings.TwoFactorEnabled = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code, or with allowRecovery an unused
+// recovery code, and records it as used on user. The caller saves user
+func (s *userService) verifySecondFactor(user *model.User, code string, allowRecovery bool) error {
+	secret, err := s.secretCipher.Decrypt(user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read two-factor secret: %w", err)
+	}
+
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now(), user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if allowRecovery {
+		hash := auth.HashRecoveryCode(code)
+		for i, stored := range user.RecoveryCodes {
+			if stored == hash {
+				user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+	}
+
+	return errors.New("invalid verification code")
+}
+
+// twoFactorEnabled reports whether user has to present a second factor
+func twoFactorEnabled(user *model.User) bool {
+	return user.Settings != nil && user.Settings.TwoFactorEnabled && user.TOTPSecret != ""
+}
+
+// storeRefreshToken records the refresh token of a newly issued pair so it can be rotated
+func (s *userService) storeRefreshToken(userID string, tokens *auth.TokenPair) error {
+	return s.userRepo.CreateRefreshToken(&model.RefreshToken{
+		ID:        tokens.RefreshTokenID,
+		UserID:    userID,
+		FamilyID:  tokens.FamilyID,
+		ExpiresAt: tokens.RefreshExpiresAt,
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new pair. Each refresh token
+// works once; presenting a used one again revokes its family and returns
+// ErrRefreshTokenReused
 func (s *userService) RefreshToken(refreshToken string) (*auth.TokenPair, error) {
-	return s.jwtService.RefreshToken(refreshToken)
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := s.userRepo.UseRefreshToken(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		stored, err := s.userRepo.GetRefreshToken(claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if stored == nil || stored.UsedAt == nil {
+			return nil, errors.New("refresh token has been revoked")
+		}
+
+		// Either the legitimate client or an attacker holds the newer token,
+		// and there is no telling which, so neither keeps the session
+		if _, err := s.userRepo.RevokeSession(stored.UserID, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		log.Printf("Refresh token reuse detected for user %s, revoked token family %s", stored.UserID, stored.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	tokens, err := s.jwtService.RotateTokenPair(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+	if err := s.storeRefreshToken(claims.UserID, tokens); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.TouchSession(claims.FamilyID, tokens.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+	return tokens, nil
 }
 
-// Logout invalidates user tokens (placeholder - in production would need token blacklist)
+// Logout revokes the session being closed, so neither its refresh token
+// family nor its access tokens are accepted any more
 func (s *userService) Logout(userID, refreshToken string) error {
-	// In a production system, you would typically:
-	// 1. Add the refresh token to a blacklist
-	// 2. Store blacklisted tokens in Redis with expiration
-	// For now, we'll just validate the token
-	_, err := s.jwtService.ValidateToken(refreshToken)
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	if claims.UserID != userID {
+		return errors.New("refresh token belongs to another user")
+	}
+
+	_, err = s.userRepo.RevokeSession(userID, claims.FamilyID)
 	return err
 }
 
+// ValidateAccessToken validates an access token and rejects it once its
+// session has been revoked
+func (s *userService) ValidateAccessToken(tokenString string) (*auth.Claims, error) {
+	claims, err := s.jwtService.ValidateAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return claims, nil
+	}
+
+	revoked, err := s.userRepo.IsSessionRevoked(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("session has been revoked")
+	}
+	return claims, nil
+}
+
+// ListSessions returns the user's active sessions, most recently started first
+func (s *userService) ListSessions(userID string) ([]model.Session, error) {
+	return s.userRepo.ListSessions(userID)
+}
+
+// RevokeSession ends one of the user's sessions, e.g. on a lost device
+func (s *userService) RevokeSession(userID, sessionID string) error {
+	revoked, err := s.userRepo.RevokeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	log.Printf("User %s revoked session %s", userID, sessionID)
+	return nil
+}
+
+// OAuthLogin signs in a user authenticated by an external provider. The
+// provider account is matched by its link, then by verified email to an
+// existing user, and otherwise a new user with role "user" is created.
+func (s *userService) OAuthLogin(provider string, info *auth.OAuthUserInfo, client SessionClient) (*LoginResult, error) {
+	var user *model.User
+
+	identity, err := s.userRepo.GetIdentity(provider, info.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err = s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if info.Email == "" {
+			return nil, errors.New("provider did not share an email address")
+		}
+
+		if s.userRepo.IsEmailExists(info.Email) {
+			// Only a verified address proves the provider account owns the existing user
+			if !info.EmailVerified {
+				return nil, errors.New("email belongs to an existing account but is not verified by the provider")
+			}
+			user, err = s.userRepo.GetByEmail(info.Email)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			user, err = s.createOAuthUser(info)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.userRepo.CreateIdentity(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  info.Subject,
+			Email:    info.Email,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Status != "active" {
+		return nil, errors.New("user account is disabled")
+	}
+
+	return s.startSession(user, client)
+}
+
+// createOAuthUser creates a user for a first-time external login. The random
+// password keeps local login closed until the user sets one
+func (s *userService) createOAuthUser(info *auth.OAuthUserInfo) (*model.User, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	nickname := info.Name
+	if nickname == "" {
+		nickname = info.Username
+	}
+
+	user := &model.User{
+		Username:  s.availableUsername(info),
+		Email:     info.Email,
+		Password:  hex.EncodeToString(secret),
+		Nickname:  nickname,
+		AvatarURL: info.AvatarURL,
+		Role:      "user",
+		Status:    "active",
+		Settings:  defaultUserSettings(),
+	}
+
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// availableUsername derives an unused username from the provider's username or email
+func (s *userService) availableUsername(info *auth.OAuthUserInfo) string {
+	base := info.Username
+	if base == "" {
+		base = strings.SplitN(info.Email, "@", 2)[0]
+	}
+
+	var sb strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			sb.WriteRune(r)
+		}
+	}
+	base = sb.String()
+	for len(base) < 3 {
+		base += "_"
+	}
+	if len(base) > 45 {
+		base = base[:45]
+	}
+
+	username := base
+	for i := 2; s.userRepo.IsUsernameExists(username); i++ {
+		username = fmt.Sprintf("%s-%d", base, i)
+	}
+	return username
+}
+
 // CreateUser creates a new user (admin only)
 func (s *userService) CreateUser(req *CreateUserRequest) (*model.User, error) {
 	// Validate input
@@ -147,16 +608,10 @@ func (s *userService) CreateUser(req *CreateUserRequest) (*model.User, error) {
 	// Set default settings if not provided
 	settings := req.Settings
 	if settings == nil {
-		settings = &model.UserSettings{
-			Language:                   "zh-CN",
-			Timezone:                   "Asia/Shanghai",
-			EmailNotifications:         true,
-			PushNotifications:          true,
-			TwoFactorEnabled:           false,
-			DeviceVerificationRequired: true,
-			SessionTimeoutMinutes:      60,
-		}
+		settings = defaultUserSettings()
 	}
+	// Two-factor authentication is only switched on through enrollment
+	settings.TwoFactorEnabled = false
 
 	// Create user
 	user := &model.User{
@@ -234,6 +689,8 @@ func (s *userService) UpdateUser(userID string, req *UpdateUserRequest) (*model.
 	}
 	
 	if req.Settings != nil {
+		// Two-factor authentication is only switched on through enrollment
+		req.Settings.TwoFactorEnabled = user.Settings != nil && user.Settings.TwoFactorEnabled
 		user.Settings = req.Settings
 	}
 
@@ -295,6 +752,8 @@ func (s *userService) UpdateProfile(userID string, req *UpdateProfileRequest) (*
 	}
 	
 	if req.Settings != nil {
+		// Two-factor authentication is only switched on through enrollment
+		req.Settings.TwoFactorEnabled = user.Settings != nil && user.Settings.TwoFactorEnabled
 		user.Settings = req.Settings
 	}
 
@@ -333,6 +792,19 @@ func (s *userService) IsAdmin(userID string) (bool, error) {
 	return user.Role == "admin", nil
 }
 
+// defaultUserSettings returns the settings of users created without any
+func defaultUserSettings() *model.UserSettings {
+	return &model.UserSettings{
+		Language:                   "zh-CN",
+		Timezone:                   "Asia/Shanghai",
+		EmailNotifications:         true,
+		PushNotifications:          true,
+		TwoFactorEnabled:           false,
+		DeviceVerificationRequired: true,
+		SessionTimeoutMinutes:      60,
+	}
+}
+
 // Validation helpers
 
 func (s *userService) validateCreateUserRequest(req *CreateUserRequest) error {
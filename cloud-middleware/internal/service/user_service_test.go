@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/database"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/repository"
+)
+
+const testJWTSecret = "test-secret-key-for-user-service"
+
+// newTestUserService returns a user service backed by an in-memory database
+func newTestUserService(t *testing.T) (*userService, repository.UserRepository) {
+	t.Helper()
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", logger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	jwtConfig := config.JWTConfig{SecretKey: testJWTSecret, AccessTokenDuration: 15, RefreshTokenDuration: 7}
+	jwtService, err := auth.NewJWTService(jwtConfig)
+	if err != nil {
+		t.Fatalf("create jwt service: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db)
+	us, err := NewUserService(userRepo, jwtService, jwtConfig, config.TwoFactorConfig{}, config.WebAuthnConfig{})
+	if err != nil {
+		t.Fatalf("create user service: %v", err)
+	}
+	return us.(*userService), userRepo
+}
+
+// createTwoFactorUser stores an active user with TOTP enabled and returns it
+// with its plain TOTP secret
+func createTwoFactorUser(t *testing.T, userRepo repository.UserRepository, username string) (*model.User, string) {
+	t.Helper()
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	secretCipher, err := auth.NewSecretCipher(testJWTSecret)
+	if err != nil {
+		t.Fatalf("create cipher: %v", err)
+	}
+	encrypted, err := secretCipher.Encrypt(secret)
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+	settings := defaultUserSettings()
+	settings.TwoFactorEnabled = true
+	user := &model.User{
+		Username:   username,
+		Email:      username + "@example.com",
+		Password:   "password123",
+		Role:       "user",
+		Status:     "active",
+		TOTPSecret: encrypted,
+		Settings:   settings,
+	}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user, secret
+}
+
+func TestCompleteTwoFactorLoginExhaustsChallenge(t *testing.T) {
+	us, userRepo := newTestUserService(t)
+	user, secret := createTwoFactorUser(t, userRepo, "alice")
+
+	challenge, _, err := us.jwtService.GenerateChallengeToken(user.ID)
+	if err != nil {
+		t.Fatalf("generate challenge: %v", err)
+	}
+	valid, err := auth.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	wrong := "000000"
+	if wrong == valid {
+		wrong = "111111"
+	}
+
+	tests := []struct {
+		name      string
+		code      string
+		exhausted bool
+	}{
+		{"wrong code 1", wrong, false},
+		{"wrong code 2", wrong, false},
+		{"wrong code 3", wrong, false},
+		{"wrong code 4", wrong, false},
+		{"wrong code 5 exhausts the challenge", wrong, true},
+		{"valid code after exhaustion", valid, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := us.CompleteTwoFactorLogin(challenge, tt.code, SessionClient{})
+			if err == nil {
+				t.Fatalf("got tokens %v, want an error", result)
+			}
+			if got := errors.Is(err, ErrChallengeExhausted); got != tt.exhausted {
+				t.Fatalf("got error %v, exhausted = %v, want %v", err, got, tt.exhausted)
+			}
+		})
+	}
+
+	// Signing in again issues a fresh challenge with its own attempts
+	fresh, _, err := us.jwtService.GenerateChallengeToken(user.ID)
+	if err != nil {
+		t.Fatalf("generate challenge: %v", err)
+	}
+	result, err := us.CompleteTwoFactorLogin(fresh, valid, SessionClient{})
+	if err != nil {
+		t.Fatalf("fresh challenge with a valid code: %v", err)
+	}
+	if result.Tokens == nil {
+		t.Fatalf("fresh challenge returned no tokens")
+	}
+}
+
+func TestChallengeAttemptsAreCountedPerChallenge(t *testing.T) {
+	attempts := newChallengeAttempts()
+	expiresAt := time.Now().Add(time.Minute)
+	first := &auth.Challenge{ID: "first", UserID: "u1", ExpiresAt: expiresAt}
+	second := &auth.Challenge{ID: "second", UserID: "u1", ExpiresAt: expiresAt}
+	expired := &auth.Challenge{ID: "expired", UserID: "u2", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	for i := 1; i < maxChallengeFailures; i++ {
+		if attempts.fail(first) {
+			t.Fatalf("challenge exhausted after %d failures", i)
+		}
+	}
+	attempts.fail(expired)
+
+	tests := []struct {
+		name      string
+		challenge *auth.Challenge
+		fail      bool
+		want      bool
+	}{
+		{"failure below the limit is not exhausted", first, false, false},
+		{"failure reaching the limit exhausts", first, true, true},
+		{"exhausted challenge stays exhausted", first, false, true},
+		{"other challenge of the same user is unaffected", second, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fail {
+				if got := attempts.fail(tt.challenge); got != tt.want {
+					t.Fatalf("fail() = %v, want %v", got, tt.want)
+				}
+			}
+			if got := attempts.exhausted(tt.challenge); got != tt.want {
+				t.Fatalf("exhausted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := attempts.failures[expired.ID]; ok {
+		t.Errorf("count of an expired challenge was kept")
+	}
+	attempts.forget(first)
+	if attempts.exhausted(first) {
+		t.Errorf("forgotten challenge is still exhausted")
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/auth"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/config"
+	"github.com/myczh-1/lazy-ctrl-cloud/internal/model"
+)
+
+// ErrWebAuthnDisabled is returned while no relying party ID is configured
+var ErrWebAuthnDisabled = errors.New("webauthn login is not enabled")
+
+// maxWebAuthnCredentialName limits the label a user gives an authenticator
+const maxWebAuthnCredentialName = 64
+
+// WebAuthnCeremony is the options handed to the browser's WebAuthn API and
+// the token that carries the ceremony's state to its finish request
+type WebAuthnCeremony struct {
+	Options      interface{} `json:"options"` // protocol.CredentialCreation or protocol.CredentialAssertion
+	SessionToken string      `json:"session_token"`
+}
+
+// newWebAuthn creates the relying party, or nil when it is not configured
+func newWebAuthn(cfg config.WebAuthnConfig) (*webauthn.WebAuthn, error) {
+	if cfg.RPID == "" {
+		return nil, nil
+	}
+
+	displayName := cfg.RPDisplayName
+	if displayName == "" {
+		displayName = "Lazy Ctrl"
+	}
+	origins := cfg.RPOrigins
+	if len(origins) == 0 {
+		origins = []string{"https://" + cfg.RPID}
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+	})
+}
+
+// webAuthnUser adapts a user and its stored credentials to the library
+type webAuthnUser struct {
+	user        *model.User
+	credentials []model.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Nickname != "" {
+		return u.user.Nickname
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for i, stored := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, len(stored.Transports))
+		for j, transport := range stored.Transports {
+			transports[j] = protocol.AuthenticatorTransport(transport)
+		}
+
+		credentials[i] = webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		}
+	}
+	return credentials
+}
+
+// storedCredential returns the stored credential with the given ID
+func (u *webAuthnUser) storedCredential(id []byte) *model.WebAuthnCredential {
+	for i := range u.credentials {
+		if bytes.Equal(u.credentials[i].CredentialID, id) {
+			return &u.credentials[i]
+		}
+	}
+	return nil
+}
+
+// loadWebAuthnUser returns user with its enrolled credentials
+func (s *userService) loadWebAuthnUser(user *model.User) (*webAuthnUser, error) {
+	credentials, err := s.userRepo.ListWebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// BeginWebAuthnRegistration starts enrolling a new authenticator for the user.
+// Authenticators the user already enrolled are excluded
+func (s *userService) BeginWebAuthnRegistration(userID string) (*WebAuthnCeremony, error) {
+	if s.webAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	waUser, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := webauthn.Credentials(waUser.WebAuthnCredentials()).CredentialDescriptors()
+	creation, session, err := s.webAuthn.BeginRegistration(waUser,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start webauthn registration: %w", err)
+	}
+
+	token, err := s.jwtService.GenerateWebAuthnSessionToken(auth.WebAuthnRegistration, user.ID, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign webauthn session: %w", err)
+	}
+
+	return &WebAuthnCeremony{Options: creation, SessionToken: token}, nil
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's attestation and
+// stores the new credential under name
+func (s *userService) FinishWebAuthnRegistration(userID, sessionToken, name string, response []byte) (*model.WebAuthnCredential, error) {
+	if s.webAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	session, subject, err := s.jwtService.ValidateWebAuthnSessionToken(sessionToken, auth.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if subject != userID {
+		return nil, errors.New("webauthn session belongs to another user")
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) > maxWebAuthnCredentialName {
+		return nil, fmt.Errorf("name must be at most %d characters", maxWebAuthnCredentialName)
+	}
+	if name == "" {
+		name = "Passkey"
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn response: %s", webAuthnErrorDetail(err))
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	waUser, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(waUser, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn registration failed: %s", webAuthnErrorDetail(err))
+	}
+
+	existing, err := s.userRepo.GetWebAuthnCredential(credential.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("authenticator is already registered")
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+	stored := &model.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.userRepo.CreateWebAuthnCredential(stored); err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// BeginWebAuthnLogin starts a login. With a username the browser is offered
+// that user's credentials; without one it lets the user pick a passkey
+func (s *userService) BeginWebAuthnLogin(username string) (*WebAuthnCeremony, error) {
+	if s.webAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		subject   string
+		err       error
+	)
+	if username == "" {
+		assertion, session, err = s.webAuthn.BeginDiscoverableLogin()
+	} else {
+		user, lookupErr := s.userRepo.GetByUsername(username)
+		if lookupErr != nil {
+			return nil, errors.New("no passkey is registered for this account")
+		}
+		waUser, lookupErr := s.loadWebAuthnUser(user)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if len(waUser.credentials) == 0 {
+			return nil, errors.New("no passkey is registered for this account")
+		}
+		subject = user.ID
+		assertion, session, err = s.webAuthn.BeginLogin(waUser)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start webauthn login: %w", err)
+	}
+
+	token, err := s.jwtService.GenerateWebAuthnSessionToken(auth.WebAuthnLogin, subject, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign webauthn session: %w", err)
+	}
+
+	return &WebAuthnCeremony{Options: assertion, SessionToken: token}, nil
+}
+
+// FinishWebAuthnLogin verifies the authenticator's assertion and issues the
+// normal token pair. The authenticator already verified the user, so no
+// TOTP challenge follows
+func (s *userService) FinishWebAuthnLogin(sessionToken string, response []byte, client SessionClient) (*LoginResult, error) {
+	if s.webAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	session, subject, err := s.jwtService.ValidateWebAuthnSessionToken(sessionToken, auth.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn response: %s", webAuthnErrorDetail(err))
+	}
+
+	// The credential identifies the user; a username given at begin must match it
+	stored, err := s.userRepo.GetWebAuthnCredential(parsed.RawID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || (subject != "" && stored.UserID != subject) {
+		return nil, errors.New("unknown authenticator")
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != "active" {
+		return nil, errors.New("user account is disabled")
+	}
+	waUser, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var credential *webauthn.Credential
+	if subject == "" {
+		credential, err = s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			if !bytes.Equal(userHandle, waUser.WebAuthnID()) {
+				return nil, errors.New("user handle does not match the credential")
+			}
+			return waUser, nil
+		}, *session, parsed)
+	} else {
+		credential, err = s.webAuthn.ValidateLogin(waUser, *session, parsed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("webauthn login failed: %s", webAuthnErrorDetail(err))
+	}
+
+	// A counter that does not grow suggests a cloned authenticator
+	if credential.Authenticator.CloneWarning {
+		return nil, errors.New("authenticator signature counter did not increase")
+	}
+
+	stored = waUser.storedCredential(credential.ID)
+	stored.SignCount = credential.Authenticator.SignCount
+	stored.BackupState = credential.Flags.BackupState
+	fresh, err := s.userRepo.RecordWebAuthnLogin(stored, session.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, errors.New("webauthn assertion was already used")
+	}
+
+	return s.issueTokens(user, client)
+}
+
+// ListWebAuthnCredentials returns the authenticators the user enrolled
+func (s *userService) ListWebAuthnCredentials(userID string) ([]model.WebAuthnCredential, error) {
+	return s.userRepo.ListWebAuthnCredentials(userID)
+}
+
+// DeleteWebAuthnCredential removes one of the user's authenticators
+func (s *userService) DeleteWebAuthnCredential(userID string, id uint) error {
+	return s.userRepo.DeleteWebAuthnCredential(userID, id)
+}
+
+// webAuthnErrorDetail includes the library's detail, which says which check failed
+func webAuthnErrorDetail(err error) string {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.Details != "" {
+		return protocolErr.Details
+	}
+	return err.Error()
+}
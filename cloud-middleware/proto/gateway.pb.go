@@ -7,8 +7,10 @@
 package proto
 
 import (
+	proto "github.com/myczh-1/lazy-ctrl-agent/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -491,6 +493,9 @@ type CreateCommandRequest struct {
 	TemplateParams map[string]string      `protobuf:"bytes,13,rep,name=template_params,json=templateParams,proto3" json:"template_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Security       *SecurityConfig        `protobuf:"bytes,14,opt,name=security,proto3" json:"security,omitempty"`
 	HomeLayout     *HomeLayoutConfig      `protobuf:"bytes,15,opt,name=home_layout,json=homeLayout,proto3" json:"home_layout,omitempty"`
+	WorkingDir     string                 `protobuf:"bytes,16,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`                                           // 工作目录
+	Env            map[string]string      `protobuf:"bytes,17,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 环境变量
+	AllowDangerous bool                   `protobuf:"varint,18,opt,name=allow_dangerous,json=allowDangerous,proto3" json:"allow_dangerous,omitempty"`                              // 命令匹配危险模式黑名单时仍然保存，需要设备管理员权限
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -630,6 +635,27 @@ func (x *CreateCommandRequest) GetHomeLayout() *HomeLayoutConfig {
 	return nil
 }
 
+func (x *CreateCommandRequest) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *CreateCommandRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *CreateCommandRequest) GetAllowDangerous() bool {
+	if x != nil {
+		return x.AllowDangerous
+	}
+	return false
+}
+
 type UpdateCommandRequest struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	DeviceId       string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
@@ -647,6 +673,11 @@ type UpdateCommandRequest struct {
 	TemplateParams map[string]string      `protobuf:"bytes,13,rep,name=template_params,json=templateParams,proto3" json:"template_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Security       *SecurityConfig        `protobuf:"bytes,14,opt,name=security,proto3" json:"security,omitempty"`
 	HomeLayout     *HomeLayoutConfig      `protobuf:"bytes,15,opt,name=home_layout,json=homeLayout,proto3" json:"home_layout,omitempty"`
+	WorkingDir     string                 `protobuf:"bytes,16,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`                                           // 工作目录
+	Env            map[string]string      `protobuf:"bytes,17,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 环境变量
+	// 只更新列出的字段 (如 "name"、"security.require_pin")，其余保持不变；为空时替换整个命令
+	UpdateMask     *fieldmaskpb.FieldMask `protobuf:"bytes,18,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	AllowDangerous bool                   `protobuf:"varint,19,opt,name=allow_dangerous,json=allowDangerous,proto3" json:"allow_dangerous,omitempty"` // 命令匹配危险模式黑名单时仍然保存，需要设备管理员权限
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -786,6 +817,34 @@ func (x *UpdateCommandRequest) GetHomeLayout() *HomeLayoutConfig {
 	return nil
 }
 
+func (x *UpdateCommandRequest) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *UpdateCommandRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *UpdateCommandRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+func (x *UpdateCommandRequest) GetAllowDangerous() bool {
+	if x != nil {
+		return x.AllowDangerous
+	}
+	return false
+}
+
 type DeleteCommandRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
@@ -1230,6 +1289,8 @@ type CommandInfo struct {
 	HomepageColor    string                 `protobuf:"bytes,20,opt,name=homepage_color,json=homepageColor,proto3" json:"homepage_color,omitempty"`
 	HomepagePriority int32                  `protobuf:"varint,21,opt,name=homepage_priority,json=homepagePriority,proto3" json:"homepage_priority,omitempty"`
 	HomepagePosition *PositionConfig        `protobuf:"bytes,22,opt,name=homepage_position,json=homepagePosition,proto3" json:"homepage_position,omitempty"`
+	WorkingDir       string                 `protobuf:"bytes,23,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	Env              map[string]string      `protobuf:"bytes,24,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
@@ -1418,6 +1479,20 @@ func (x *CommandInfo) GetHomepagePosition() *PositionConfig {
 	return nil
 }
 
+func (x *CommandInfo) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
 type CreateCommandResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -2706,7 +2781,7 @@ var File_proto_gateway_proto protoreflect.FileDescriptor
 
 const file_proto_gateway_proto_rawDesc = "" +
 	"\n" +
-	"\x13proto/gateway.proto\x12\agateway\x1a\x1fgoogle/protobuf/timestamp.proto\"\xab\x02\n" +
+	"\x13proto/gateway.proto\x12\agateway\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x16proto/controller.proto\"\xab\x02\n" +
 	"\x15RegisterDeviceRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1f\n" +
 	"\vdevice_name\x18\x02 \x01(\tR\n" +
@@ -2747,7 +2822,7 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x17ListUserDevicesResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12/\n" +
-	"\adevices\x18\x03 \x03(\v2\x15.gateway.DeviceStatusR\adevices\"\xe6\x04\n" +
+	"\adevices\x18\x03 \x03(\v2\x15.gateway.DeviceStatusR\adevices\"\xa2\x06\n" +
 	"\x14CreateCommandRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x0e\n" +
@@ -2766,10 +2841,17 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x0ftemplate_params\x18\r \x03(\v21.gateway.CreateCommandRequest.TemplateParamsEntryR\x0etemplateParams\x123\n" +
 	"\bsecurity\x18\x0e \x01(\v2\x17.gateway.SecurityConfigR\bsecurity\x12:\n" +
 	"\vhome_layout\x18\x0f \x01(\v2\x19.gateway.HomeLayoutConfigR\n" +
-	"homeLayout\x1aA\n" +
+	"homeLayout\x12\x1f\n" +
+	"\vworking_dir\x18\x10 \x01(\tR\n" +
+	"workingDir\x128\n" +
+	"\x03env\x18\x11 \x03(\v2&.gateway.CreateCommandRequest.EnvEntryR\x03env\x12'\n" +
+	"\x0fallow_dangerous\x18\x12 \x01(\bR\x0eallowDangerous\x1aA\n" +
 	"\x13TemplateParamsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf5\x04\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xee\x06\n" +
 	"\x14UpdateCommandRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1d\n" +
@@ -2789,9 +2871,18 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x0ftemplate_params\x18\r \x03(\v21.gateway.UpdateCommandRequest.TemplateParamsEntryR\x0etemplateParams\x123\n" +
 	"\bsecurity\x18\x0e \x01(\v2\x17.gateway.SecurityConfigR\bsecurity\x12:\n" +
 	"\vhome_layout\x18\x0f \x01(\v2\x19.gateway.HomeLayoutConfigR\n" +
-	"homeLayout\x1aA\n" +
+	"homeLayout\x12\x1f\n" +
+	"\vworking_dir\x18\x10 \x01(\tR\n" +
+	"workingDir\x128\n" +
+	"\x03env\x18\x11 \x03(\v2&.gateway.UpdateCommandRequest.EnvEntryR\x03env\x12;\n" +
+	"\vupdate_mask\x18\x12 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\x12'\n" +
+	"\x0fallow_dangerous\x18\x13 \x01(\bR\x0eallowDangerous\x1aA\n" +
 	"\x13TemplateParamsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"k\n" +
 	"\x14DeleteCommandRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x17\n" +
@@ -2825,7 +2916,7 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"showOnHome\x12B\n" +
 	"\x10default_position\x18\x02 \x01(\v2\x17.gateway.PositionConfigR\x0fdefaultPosition\x12\x14\n" +
 	"\x05color\x18\x03 \x01(\tR\x05color\x12\x1a\n" +
-	"\bpriority\x18\x04 \x01(\x05R\bpriority\"\x80\a\n" +
+	"\bpriority\x18\x04 \x01(\x05R\bpriority\"\x8a\b\n" +
 	"\vCommandInfo\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
@@ -2852,9 +2943,15 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x10show_on_homepage\x18\x13 \x01(\bR\x0eshowOnHomepage\x12%\n" +
 	"\x0ehomepage_color\x18\x14 \x01(\tR\rhomepageColor\x12+\n" +
 	"\x11homepage_priority\x18\x15 \x01(\x05R\x10homepagePriority\x12D\n" +
-	"\x11homepage_position\x18\x16 \x01(\v2\x17.gateway.PositionConfigR\x10homepagePosition\x1aA\n" +
+	"\x11homepage_position\x18\x16 \x01(\v2\x17.gateway.PositionConfigR\x10homepagePosition\x12\x1f\n" +
+	"\vworking_dir\x18\x17 \x01(\tR\n" +
+	"workingDir\x12/\n" +
+	"\x03env\x18\x18 \x03(\v2\x1d.gateway.CommandInfo.EnvEntryR\x03env\x1aA\n" +
 	"\x13TemplateParamsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"{\n" +
 	"\x15CreateCommandResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
@@ -2963,12 +3060,12 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\x1a;\n" +
 	"\rServicesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\x8d\n" +
-	"\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xa5\v\n" +
 	"\x0eGatewayService\x12Q\n" +
 	"\x0eRegisterDevice\x12\x1e.gateway.RegisterDeviceRequest\x1a\x1f.gateway.RegisterDeviceResponse\x12T\n" +
 	"\x0fGetDeviceStatus\x12\x1f.gateway.GetDeviceStatusRequest\x1a .gateway.GetDeviceStatusResponse\x12T\n" +
-	"\x0fListUserDevices\x12\x1f.gateway.ListUserDevicesRequest\x1a .gateway.ListUserDevicesResponse\x12N\n" +
+	"\x0fListUserDevices\x12\x1f.gateway.ListUserDevicesRequest\x1a .gateway.ListUserDevicesResponse\x12Q\n" +
+	"\fEnrollDevice\x12\x1f.controller.EnrollDeviceRequest\x1a .controller.EnrollDeviceResponse\x12N\n" +
 	"\rCreateCommand\x12\x1d.gateway.CreateCommandRequest\x1a\x1e.gateway.CreateCommandResponse\x12N\n" +
 	"\rUpdateCommand\x12\x1d.gateway.UpdateCommandRequest\x1a\x1e.gateway.UpdateCommandResponse\x12N\n" +
 	"\rDeleteCommand\x12\x1d.gateway.DeleteCommandRequest\x1a\x1e.gateway.DeleteCommandResponse\x12E\n" +
@@ -2983,7 +3080,8 @@ const file_proto_gateway_proto_rawDesc = "" +
 	"\x0eReloadCommands\x12\x1e.gateway.ReloadCommandsRequest\x1a\x1f.gateway.ReloadCommandsResponse\x12E\n" +
 	"\n" +
 	"GetVersion\x12\x1a.gateway.GetVersionRequest\x1a\x1b.gateway.GetVersionResponse\x12B\n" +
-	"\tGetStatus\x12\x19.gateway.GetStatusRequest\x1a\x1a.gateway.GetStatusResponseB*Z(github.com/myczh-1/lazy-ctrl-cloud/protob\x06proto3"
+	"\tGetStatus\x12\x19.gateway.GetStatusRequest\x1a\x1a.gateway.GetStatusResponse\x12C\n" +
+	"\aConnect\x12\x19.controller.TunnelMessage\x1a\x19.controller.TunnelMessage(\x010\x01B*Z(github.com/myczh-1/lazy-ctrl-cloud/protob\x06proto3"
 
 var (
 	file_proto_gateway_proto_rawDescOnce sync.Once
@@ -2997,7 +3095,7 @@ func file_proto_gateway_proto_rawDescGZIP() []byte {
 	return file_proto_gateway_proto_rawDescData
 }
 
-var file_proto_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 48)
+var file_proto_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 51)
 var file_proto_gateway_proto_goTypes = []any{
 	(*RegisterDeviceRequest)(nil),       // 0: gateway.RegisterDeviceRequest
 	(*RegisterDeviceResponse)(nil),      // 1: gateway.RegisterDeviceResponse
@@ -3040,83 +3138,98 @@ var file_proto_gateway_proto_goTypes = []any{
 	nil,                                 // 38: gateway.RegisterDeviceRequest.MetadataEntry
 	nil,                                 // 39: gateway.DeviceStatus.SystemInfoEntry
 	nil,                                 // 40: gateway.CreateCommandRequest.TemplateParamsEntry
-	nil,                                 // 41: gateway.UpdateCommandRequest.TemplateParamsEntry
-	nil,                                 // 42: gateway.CommandInfo.TemplateParamsEntry
-	nil,                                 // 43: gateway.GetCommandInfoResponse.InfoEntry
-	nil,                                 // 44: gateway.HealthCheckResponse.ServicesEntry
-	nil,                                 // 45: gateway.GetStatusResponse.MemoryEntry
-	nil,                                 // 46: gateway.GetStatusResponse.CommandsEntry
-	nil,                                 // 47: gateway.GetStatusResponse.ServicesEntry
-	(*timestamppb.Timestamp)(nil),       // 48: google.protobuf.Timestamp
+	nil,                                 // 41: gateway.CreateCommandRequest.EnvEntry
+	nil,                                 // 42: gateway.UpdateCommandRequest.TemplateParamsEntry
+	nil,                                 // 43: gateway.UpdateCommandRequest.EnvEntry
+	nil,                                 // 44: gateway.CommandInfo.TemplateParamsEntry
+	nil,                                 // 45: gateway.CommandInfo.EnvEntry
+	nil,                                 // 46: gateway.GetCommandInfoResponse.InfoEntry
+	nil,                                 // 47: gateway.HealthCheckResponse.ServicesEntry
+	nil,                                 // 48: gateway.GetStatusResponse.MemoryEntry
+	nil,                                 // 49: gateway.GetStatusResponse.CommandsEntry
+	nil,                                 // 50: gateway.GetStatusResponse.ServicesEntry
+	(*timestamppb.Timestamp)(nil),       // 51: google.protobuf.Timestamp
+	(*fieldmaskpb.FieldMask)(nil),       // 52: google.protobuf.FieldMask
+	(*proto.EnrollDeviceRequest)(nil),   // 53: controller.EnrollDeviceRequest
+	(*proto.TunnelMessage)(nil),         // 54: controller.TunnelMessage
+	(*proto.EnrollDeviceResponse)(nil),  // 55: controller.EnrollDeviceResponse
 }
 var file_proto_gateway_proto_depIdxs = []int32{
 	38, // 0: gateway.RegisterDeviceRequest.metadata:type_name -> gateway.RegisterDeviceRequest.MetadataEntry
-	48, // 1: gateway.DeviceStatus.last_seen:type_name -> google.protobuf.Timestamp
+	51, // 1: gateway.DeviceStatus.last_seen:type_name -> google.protobuf.Timestamp
 	39, // 2: gateway.DeviceStatus.system_info:type_name -> gateway.DeviceStatus.SystemInfoEntry
 	3,  // 3: gateway.GetDeviceStatusResponse.device:type_name -> gateway.DeviceStatus
 	3,  // 4: gateway.ListUserDevicesResponse.devices:type_name -> gateway.DeviceStatus
 	40, // 5: gateway.CreateCommandRequest.template_params:type_name -> gateway.CreateCommandRequest.TemplateParamsEntry
 	13, // 6: gateway.CreateCommandRequest.security:type_name -> gateway.SecurityConfig
 	15, // 7: gateway.CreateCommandRequest.home_layout:type_name -> gateway.HomeLayoutConfig
-	41, // 8: gateway.UpdateCommandRequest.template_params:type_name -> gateway.UpdateCommandRequest.TemplateParamsEntry
-	13, // 9: gateway.UpdateCommandRequest.security:type_name -> gateway.SecurityConfig
-	15, // 10: gateway.UpdateCommandRequest.home_layout:type_name -> gateway.HomeLayoutConfig
-	14, // 11: gateway.HomeLayoutConfig.default_position:type_name -> gateway.PositionConfig
-	42, // 12: gateway.CommandInfo.template_params:type_name -> gateway.CommandInfo.TemplateParamsEntry
-	48, // 13: gateway.CommandInfo.created_at:type_name -> google.protobuf.Timestamp
-	48, // 14: gateway.CommandInfo.updated_at:type_name -> google.protobuf.Timestamp
-	14, // 15: gateway.CommandInfo.homepage_position:type_name -> gateway.PositionConfig
-	16, // 16: gateway.CreateCommandResponse.command:type_name -> gateway.CommandInfo
-	16, // 17: gateway.UpdateCommandResponse.command:type_name -> gateway.CommandInfo
-	16, // 18: gateway.GetCommandResponse.command:type_name -> gateway.CommandInfo
-	16, // 19: gateway.GetAllCommandsResponse.commands:type_name -> gateway.CommandInfo
-	16, // 20: gateway.GetHomepageCommandsResponse.commands:type_name -> gateway.CommandInfo
-	43, // 21: gateway.GetCommandInfoResponse.info:type_name -> gateway.GetCommandInfoResponse.InfoEntry
-	48, // 22: gateway.HealthCheckResponse.timestamp:type_name -> google.protobuf.Timestamp
-	28, // 23: gateway.HealthCheckResponse.system:type_name -> gateway.SystemInfo
-	44, // 24: gateway.HealthCheckResponse.services:type_name -> gateway.HealthCheckResponse.ServicesEntry
-	48, // 25: gateway.GetStatusResponse.timestamp:type_name -> google.protobuf.Timestamp
-	28, // 26: gateway.GetStatusResponse.system:type_name -> gateway.SystemInfo
-	45, // 27: gateway.GetStatusResponse.memory:type_name -> gateway.GetStatusResponse.MemoryEntry
-	46, // 28: gateway.GetStatusResponse.commands:type_name -> gateway.GetStatusResponse.CommandsEntry
-	47, // 29: gateway.GetStatusResponse.services:type_name -> gateway.GetStatusResponse.ServicesEntry
-	0,  // 30: gateway.GatewayService.RegisterDevice:input_type -> gateway.RegisterDeviceRequest
-	2,  // 31: gateway.GatewayService.GetDeviceStatus:input_type -> gateway.GetDeviceStatusRequest
-	5,  // 32: gateway.GatewayService.ListUserDevices:input_type -> gateway.ListUserDevicesRequest
-	7,  // 33: gateway.GatewayService.CreateCommand:input_type -> gateway.CreateCommandRequest
-	8,  // 34: gateway.GatewayService.UpdateCommand:input_type -> gateway.UpdateCommandRequest
-	9,  // 35: gateway.GatewayService.DeleteCommand:input_type -> gateway.DeleteCommandRequest
-	10, // 36: gateway.GatewayService.GetCommand:input_type -> gateway.GetCommandRequest
-	11, // 37: gateway.GatewayService.GetAllCommands:input_type -> gateway.GetAllCommandsRequest
-	12, // 38: gateway.GatewayService.GetHomepageCommands:input_type -> gateway.GetHomepageCommandsRequest
-	23, // 39: gateway.GatewayService.ExecuteCommand:input_type -> gateway.ExecuteCommandRequest
-	25, // 40: gateway.GatewayService.GetCommandInfo:input_type -> gateway.GetCommandInfoRequest
-	27, // 41: gateway.GatewayService.HealthCheck:input_type -> gateway.HealthCheckRequest
-	30, // 42: gateway.GatewayService.VerifyPin:input_type -> gateway.VerifyPinRequest
-	32, // 43: gateway.GatewayService.ReloadCommands:input_type -> gateway.ReloadCommandsRequest
-	34, // 44: gateway.GatewayService.GetVersion:input_type -> gateway.GetVersionRequest
-	36, // 45: gateway.GatewayService.GetStatus:input_type -> gateway.GetStatusRequest
-	1,  // 46: gateway.GatewayService.RegisterDevice:output_type -> gateway.RegisterDeviceResponse
-	4,  // 47: gateway.GatewayService.GetDeviceStatus:output_type -> gateway.GetDeviceStatusResponse
-	6,  // 48: gateway.GatewayService.ListUserDevices:output_type -> gateway.ListUserDevicesResponse
-	17, // 49: gateway.GatewayService.CreateCommand:output_type -> gateway.CreateCommandResponse
-	18, // 50: gateway.GatewayService.UpdateCommand:output_type -> gateway.UpdateCommandResponse
-	19, // 51: gateway.GatewayService.DeleteCommand:output_type -> gateway.DeleteCommandResponse
-	20, // 52: gateway.GatewayService.GetCommand:output_type -> gateway.GetCommandResponse
-	21, // 53: gateway.GatewayService.GetAllCommands:output_type -> gateway.GetAllCommandsResponse
-	22, // 54: gateway.GatewayService.GetHomepageCommands:output_type -> gateway.GetHomepageCommandsResponse
-	24, // 55: gateway.GatewayService.ExecuteCommand:output_type -> gateway.ExecuteCommandResponse
-	26, // 56: gateway.GatewayService.GetCommandInfo:output_type -> gateway.GetCommandInfoResponse
-	29, // 57: gateway.GatewayService.HealthCheck:output_type -> gateway.HealthCheckResponse
-	31, // 58: gateway.GatewayService.VerifyPin:output_type -> gateway.VerifyPinResponse
-	33, // 59: gateway.GatewayService.ReloadCommands:output_type -> gateway.ReloadCommandsResponse
-	35, // 60: gateway.GatewayService.GetVersion:output_type -> gateway.GetVersionResponse
-	37, // 61: gateway.GatewayService.GetStatus:output_type -> gateway.GetStatusResponse
-	46, // [46:62] is the sub-list for method output_type
-	30, // [30:46] is the sub-list for method input_type
-	30, // [30:30] is the sub-list for extension type_name
-	30, // [30:30] is the sub-list for extension extendee
-	0,  // [0:30] is the sub-list for field type_name
+	41, // 8: gateway.CreateCommandRequest.env:type_name -> gateway.CreateCommandRequest.EnvEntry
+	42, // 9: gateway.UpdateCommandRequest.template_params:type_name -> gateway.UpdateCommandRequest.TemplateParamsEntry
+	13, // 10: gateway.UpdateCommandRequest.security:type_name -> gateway.SecurityConfig
+	15, // 11: gateway.UpdateCommandRequest.home_layout:type_name -> gateway.HomeLayoutConfig
+	43, // 12: gateway.UpdateCommandRequest.env:type_name -> gateway.UpdateCommandRequest.EnvEntry
+	52, // 13: gateway.UpdateCommandRequest.update_mask:type_name -> google.protobuf.FieldMask
+	14, // 14: gateway.HomeLayoutConfig.default_position:type_name -> gateway.PositionConfig
+	44, // 15: gateway.CommandInfo.template_params:type_name -> gateway.CommandInfo.TemplateParamsEntry
+	51, // 16: gateway.CommandInfo.created_at:type_name -> google.protobuf.Timestamp
+	51, // 17: gateway.CommandInfo.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 18: gateway.CommandInfo.homepage_position:type_name -> gateway.PositionConfig
+	45, // 19: gateway.CommandInfo.env:type_name -> gateway.CommandInfo.EnvEntry
+	16, // 20: gateway.CreateCommandResponse.command:type_name -> gateway.CommandInfo
+	16, // 21: gateway.UpdateCommandResponse.command:type_name -> gateway.CommandInfo
+	16, // 22: gateway.GetCommandResponse.command:type_name -> gateway.CommandInfo
+	16, // 23: gateway.GetAllCommandsResponse.commands:type_name -> gateway.CommandInfo
+	16, // 24: gateway.GetHomepageCommandsResponse.commands:type_name -> gateway.CommandInfo
+	46, // 25: gateway.GetCommandInfoResponse.info:type_name -> gateway.GetCommandInfoResponse.InfoEntry
+	51, // 26: gateway.HealthCheckResponse.timestamp:type_name -> google.protobuf.Timestamp
+	28, // 27: gateway.HealthCheckResponse.system:type_name -> gateway.SystemInfo
+	47, // 28: gateway.HealthCheckResponse.services:type_name -> gateway.HealthCheckResponse.ServicesEntry
+	51, // 29: gateway.GetStatusResponse.timestamp:type_name -> google.protobuf.Timestamp
+	28, // 30: gateway.GetStatusResponse.system:type_name -> gateway.SystemInfo
+	48, // 31: gateway.GetStatusResponse.memory:type_name -> gateway.GetStatusResponse.MemoryEntry
+	49, // 32: gateway.GetStatusResponse.commands:type_name -> gateway.GetStatusResponse.CommandsEntry
+	50, // 33: gateway.GetStatusResponse.services:type_name -> gateway.GetStatusResponse.ServicesEntry
+	0,  // 34: gateway.GatewayService.RegisterDevice:input_type -> gateway.RegisterDeviceRequest
+	2,  // 35: gateway.GatewayService.GetDeviceStatus:input_type -> gateway.GetDeviceStatusRequest
+	5,  // 36: gateway.GatewayService.ListUserDevices:input_type -> gateway.ListUserDevicesRequest
+	53, // 37: gateway.GatewayService.EnrollDevice:input_type -> controller.EnrollDeviceRequest
+	7,  // 38: gateway.GatewayService.CreateCommand:input_type -> gateway.CreateCommandRequest
+	8,  // 39: gateway.GatewayService.UpdateCommand:input_type -> gateway.UpdateCommandRequest
+	9,  // 40: gateway.GatewayService.DeleteCommand:input_type -> gateway.DeleteCommandRequest
+	10, // 41: gateway.GatewayService.GetCommand:input_type -> gateway.GetCommandRequest
+	11, // 42: gateway.GatewayService.GetAllCommands:input_type -> gateway.GetAllCommandsRequest
+	12, // 43: gateway.GatewayService.GetHomepageCommands:input_type -> gateway.GetHomepageCommandsRequest
+	23, // 44: gateway.GatewayService.ExecuteCommand:input_type -> gateway.ExecuteCommandRequest
+	25, // 45: gateway.GatewayService.GetCommandInfo:input_type -> gateway.GetCommandInfoRequest
+	27, // 46: gateway.GatewayService.HealthCheck:input_type -> gateway.HealthCheckRequest
+	30, // 47: gateway.GatewayService.VerifyPin:input_type -> gateway.VerifyPinRequest
+	32, // 48: gateway.GatewayService.ReloadCommands:input_type -> gateway.ReloadCommandsRequest
+	34, // 49: gateway.GatewayService.GetVersion:input_type -> gateway.GetVersionRequest
+	36, // 50: gateway.GatewayService.GetStatus:input_type -> gateway.GetStatusRequest
+	54, // 51: gateway.GatewayService.Connect:input_type -> controller.TunnelMessage
+	1,  // 52: gateway.GatewayService.RegisterDevice:output_type -> gateway.RegisterDeviceResponse
+	4,  // 53: gateway.GatewayService.GetDeviceStatus:output_type -> gateway.GetDeviceStatusResponse
+	6,  // 54: gateway.GatewayService.ListUserDevices:output_type -> gateway.ListUserDevicesResponse
+	55, // 55: gateway.GatewayService.EnrollDevice:output_type -> controller.EnrollDeviceResponse
+	17, // 56: gateway.GatewayService.CreateCommand:output_type -> gateway.CreateCommandResponse
+	18, // 57: gateway.GatewayService.UpdateCommand:output_type -> gateway.UpdateCommandResponse
+	19, // 58: gateway.GatewayService.DeleteCommand:output_type -> gateway.DeleteCommandResponse
+	20, // 59: gateway.GatewayService.GetCommand:output_type -> gateway.GetCommandResponse
+	21, // 60: gateway.GatewayService.GetAllCommands:output_type -> gateway.GetAllCommandsResponse
+	22, // 61: gateway.GatewayService.GetHomepageCommands:output_type -> gateway.GetHomepageCommandsResponse
+	24, // 62: gateway.GatewayService.ExecuteCommand:output_type -> gateway.ExecuteCommandResponse
+	26, // 63: gateway.GatewayService.GetCommandInfo:output_type -> gateway.GetCommandInfoResponse
+	29, // 64: gateway.GatewayService.HealthCheck:output_type -> gateway.HealthCheckResponse
+	31, // 65: gateway.GatewayService.VerifyPin:output_type -> gateway.VerifyPinResponse
+	33, // 66: gateway.GatewayService.ReloadCommands:output_type -> gateway.ReloadCommandsResponse
+	35, // 67: gateway.GatewayService.GetVersion:output_type -> gateway.GetVersionResponse
+	37, // 68: gateway.GatewayService.GetStatus:output_type -> gateway.GetStatusResponse
+	54, // 69: gateway.GatewayService.Connect:output_type -> controller.TunnelMessage
+	52, // [52:70] is the sub-list for method output_type
+	34, // [34:52] is the sub-list for method input_type
+	34, // [34:34] is the sub-list for extension type_name
+	34, // [34:34] is the sub-list for extension extendee
+	0,  // [0:34] is the sub-list for field type_name
 }
 
 func init() { file_proto_gateway_proto_init() }
@@ -3130,7 +3243,7 @@ func file_proto_gateway_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_gateway_proto_rawDesc), len(file_proto_gateway_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   48,
+			NumMessages:   51,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
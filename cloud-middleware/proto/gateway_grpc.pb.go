@@ -8,6 +8,7 @@ package proto
 
 import (
 	context "context"
+	proto "github.com/myczh-1/lazy-ctrl-agent/proto"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -22,6 +23,7 @@ const (
 	GatewayService_RegisterDevice_FullMethodName      = "/gateway.GatewayService/RegisterDevice"
 	GatewayService_GetDeviceStatus_FullMethodName     = "/gateway.GatewayService/GetDeviceStatus"
 	GatewayService_ListUserDevices_FullMethodName     = "/gateway.GatewayService/ListUserDevices"
+	GatewayService_EnrollDevice_FullMethodName        = "/gateway.GatewayService/EnrollDevice"
 	GatewayService_CreateCommand_FullMethodName       = "/gateway.GatewayService/CreateCommand"
 	GatewayService_UpdateCommand_FullMethodName       = "/gateway.GatewayService/UpdateCommand"
 	GatewayService_DeleteCommand_FullMethodName       = "/gateway.GatewayService/DeleteCommand"
@@ -35,6 +37,7 @@ const (
 	GatewayService_ReloadCommands_FullMethodName      = "/gateway.GatewayService/ReloadCommands"
 	GatewayService_GetVersion_FullMethodName          = "/gateway.GatewayService/GetVersion"
 	GatewayService_GetStatus_FullMethodName           = "/gateway.GatewayService/GetStatus"
+	GatewayService_Connect_FullMethodName             = "/gateway.GatewayService/Connect"
 )
 
 // GatewayServiceClient is the client API for GatewayService service.
@@ -47,6 +50,8 @@ type GatewayServiceClient interface {
 	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error)
 	GetDeviceStatus(ctx context.Context, in *GetDeviceStatusRequest, opts ...grpc.CallOption) (*GetDeviceStatusResponse, error)
 	ListUserDevices(ctx context.Context, in *ListUserDevicesRequest, opts ...grpc.CallOption) (*ListUserDevicesResponse, error)
+	// 设备自注册 - agent 启动时携带注册令牌主动登记，云端创建或更新设备并建立连接
+	EnrollDevice(ctx context.Context, in *proto.EnrollDeviceRequest, opts ...grpc.CallOption) (*proto.EnrollDeviceResponse, error)
 	// 命令管理 - 映射本地 HTTP API
 	CreateCommand(ctx context.Context, in *CreateCommandRequest, opts ...grpc.CallOption) (*CreateCommandResponse, error)
 	UpdateCommand(ctx context.Context, in *UpdateCommandRequest, opts ...grpc.CallOption) (*UpdateCommandResponse, error)
@@ -63,6 +68,8 @@ type GatewayServiceClient interface {
 	ReloadCommands(ctx context.Context, in *ReloadCommandsRequest, opts ...grpc.CallOption) (*ReloadCommandsResponse, error)
 	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error)
 	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	// 反向隧道 - 设备主动建立长连接，云端通过该连接下发请求
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[proto.TunnelMessage, proto.TunnelMessage], error)
 }
 
 type gatewayServiceClient struct {
@@ -103,6 +110,16 @@ func (c *gatewayServiceClient) ListUserDevices(ctx context.Context, in *ListUser
 	return out, nil
 }
 
+func (c *gatewayServiceClient) EnrollDevice(ctx context.Context, in *proto.EnrollDeviceRequest, opts ...grpc.CallOption) (*proto.EnrollDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(proto.EnrollDeviceResponse)
+	err := c.cc.Invoke(ctx, GatewayService_EnrollDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *gatewayServiceClient) CreateCommand(ctx context.Context, in *CreateCommandRequest, opts ...grpc.CallOption) (*CreateCommandResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(CreateCommandResponse)
@@ -233,6 +250,19 @@ func (c *gatewayServiceClient) GetStatus(ctx context.Context, in *GetStatusReque
 	return out, nil
 }
 
+func (c *gatewayServiceClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[proto.TunnelMessage, proto.TunnelMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GatewayService_ServiceDesc.Streams[0], GatewayService_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[proto.TunnelMessage, proto.TunnelMessage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GatewayService_ConnectClient = grpc.BidiStreamingClient[proto.TunnelMessage, proto.TunnelMessage]
+
 // GatewayServiceServer is the server API for GatewayService service.
 // All implementations must embed UnimplementedGatewayServiceServer
 // for forward compatibility.
@@ -243,6 +273,8 @@ type GatewayServiceServer interface {
 	RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error)
 	GetDeviceStatus(context.Context, *GetDeviceStatusRequest) (*GetDeviceStatusResponse, error)
 	ListUserDevices(context.Context, *ListUserDevicesRequest) (*ListUserDevicesResponse, error)
+	// 设备自注册 - agent 启动时携带注册令牌主动登记，云端创建或更新设备并建立连接
+	EnrollDevice(context.Context, *proto.EnrollDeviceRequest) (*proto.EnrollDeviceResponse, error)
 	// 命令管理 - 映射本地 HTTP API
 	CreateCommand(context.Context, *CreateCommandRequest) (*CreateCommandResponse, error)
 	UpdateCommand(context.Context, *UpdateCommandRequest) (*UpdateCommandResponse, error)
@@ -259,6 +291,8 @@ type GatewayServiceServer interface {
 	ReloadCommands(context.Context, *ReloadCommandsRequest) (*ReloadCommandsResponse, error)
 	GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error)
 	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	// 反向隧道 - 设备主动建立长连接，云端通过该连接下发请求
+	Connect(grpc.BidiStreamingServer[proto.TunnelMessage, proto.TunnelMessage]) error
 	mustEmbedUnimplementedGatewayServiceServer()
 }
 
@@ -278,6 +312,9 @@ func (UnimplementedGatewayServiceServer) GetDeviceStatus(context.Context, *GetDe
 func (UnimplementedGatewayServiceServer) ListUserDevices(context.Context, *ListUserDevicesRequest) (*ListUserDevicesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListUserDevices not implemented")
 }
+func (UnimplementedGatewayServiceServer) EnrollDevice(context.Context, *proto.EnrollDeviceRequest) (*proto.EnrollDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollDevice not implemented")
+}
 func (UnimplementedGatewayServiceServer) CreateCommand(context.Context, *CreateCommandRequest) (*CreateCommandResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateCommand not implemented")
 }
@@ -317,6 +354,9 @@ func (UnimplementedGatewayServiceServer) GetVersion(context.Context, *GetVersion
 func (UnimplementedGatewayServiceServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
 }
+func (UnimplementedGatewayServiceServer) Connect(grpc.BidiStreamingServer[proto.TunnelMessage, proto.TunnelMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
 func (UnimplementedGatewayServiceServer) mustEmbedUnimplementedGatewayServiceServer() {}
 func (UnimplementedGatewayServiceServer) testEmbeddedByValue()                        {}
 
@@ -392,6 +432,24 @@ func _GatewayService_ListUserDevices_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GatewayService_EnrollDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(proto.EnrollDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServiceServer).EnrollDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GatewayService_EnrollDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServiceServer).EnrollDevice(ctx, req.(*proto.EnrollDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _GatewayService_CreateCommand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateCommandRequest)
 	if err := dec(in); err != nil {
@@ -626,6 +684,13 @@ func _GatewayService_GetStatus_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GatewayService_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GatewayServiceServer).Connect(&grpc.GenericServerStream[proto.TunnelMessage, proto.TunnelMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GatewayService_ConnectServer = grpc.BidiStreamingServer[proto.TunnelMessage, proto.TunnelMessage]
+
 // GatewayService_ServiceDesc is the grpc.ServiceDesc for GatewayService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -645,6 +710,10 @@ var GatewayService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListUserDevices",
 			Handler:    _GatewayService_ListUserDevices_Handler,
 		},
+		{
+			MethodName: "EnrollDevice",
+			Handler:    _GatewayService_EnrollDevice_Handler,
+		},
 		{
 			MethodName: "CreateCommand",
 			Handler:    _GatewayService_CreateCommand_Handler,
@@ -698,6 +767,13 @@ var GatewayService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _GatewayService_GetStatus_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _GatewayService_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/gateway.proto",
 }
@@ -18,12 +18,16 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/myczh-1/lazy-ctrl-agent/internal/app"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/update"
 )
 
 var (
@@ -35,7 +39,15 @@ func main() {
 	flag.Parse()
 
 	if *version {
-		fmt.Printf("%s v%s\n", common.AppName, common.AppVersion)
+		fmt.Printf("%s v%s (commit %s, built %s)\n", common.AppName, common.AppVersion, common.GitCommit, common.BuildTime)
+		os.Exit(0)
+	}
+
+	if flag.Arg(0) == "hash-pin" {
+		if err := hashPin(flag.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to hash PIN: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
@@ -43,12 +55,43 @@ func main() {
 	application, err := app.NewApplication(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create application: %v\n", err)
+		rollbackUpdate(err)
 		os.Exit(1)
 	}
 
 	// Run the application (this blocks until shutdown)
 	if err := application.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Application error: %v\n", err)
+		rollbackUpdate(err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
+
+// rollbackUpdate restarts into the previous binary when a freshly updated
+// agent fails before confirming its startup, including when an earlier start
+// of it crashed, was killed or hung before confirming
+func rollbackUpdate(cause error) {
+	if err := update.Rollback(cause); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to roll back agent update: %v\n", err)
+	}
+}
+
+// hashPin prints the bcrypt hash of a PIN for security.pin_hash. Without an
+// argument the PIN is read from standard input, keeping it out of the shell history
+func hashPin(pin string) error {
+	if pin == "" {
+		fmt.Fprint(os.Stderr, "PIN: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		pin = strings.TrimRight(line, "\r\n")
+	}
+
+	hash, err := security.HashPin(pin)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
@@ -9,9 +9,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/update"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/http"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/grpc"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/mqtt"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/tunnel"
 )
 
 // Server interface defines the contract for all server types
@@ -55,32 +58,65 @@ func (a *Application) initializeServers() error {
 	cfg := a.container.Config
 	logger := a.container.Logger
 	
+	// Transports reported by the HTTP health endpoint
+	subsystems := make(map[string]http.Subsystem)
+	
 	// Initialize HTTP server if enabled
+	var httpServer *http.Server
 	if cfg.Server.HTTP.Enabled {
-		httpServer := http.NewServer(
+		httpServer = http.NewServer(
 			cfg,
 			logger,
 			a.container.CommandService,
 			a.container.ExecutorService,
 			a.container.SecurityService,
+			a.container.MaintenanceService,
+			a.container.SchedulerService,
+			a.container.AuditService,
+			a.container.MetricsService,
+			a.container.FileService,
 		)
 		a.servers = append(a.servers, httpServer)
+		subsystems["http"] = httpServer
 		logger.WithField("port", cfg.Server.HTTP.Port).Info("HTTP server enabled")
 	}
 	
+	// The gRPC controller service also handles requests arriving over the tunnel
+	grpcServer := grpc.NewServer(
+		cfg,
+		logger,
+		a.container.CommandService,
+		a.container.ExecutorService,
+		a.container.SecurityService,
+		a.container.MaintenanceService,
+		a.container.UpdateService,
+		a.container.FileService,
+	)
+	
 	// Initialize gRPC server if enabled
 	if cfg.Server.GRPC.Enabled {
-		grpcServer := grpc.NewServer(
-			cfg,
-			logger,
-			a.container.CommandService,
-			a.container.ExecutorService,
-			a.container.SecurityService,
-		)
 		a.servers = append(a.servers, grpcServer)
+		subsystems["grpc"] = grpcServer
 		logger.WithField("port", cfg.Server.GRPC.Port).Info("gRPC server enabled")
 	}
 	
+	// Enrollment hands the tunnel a fresh access token each time it runs
+	tunnelToken := tunnel.NewAccessToken(cfg.Tunnel.AccessToken)
+	
+	// Initialize reverse tunnel to the cloud if enabled
+	if cfg.Tunnel.Enabled {
+		tunnelClient := tunnel.NewClient(cfg, logger, grpcServer, tunnelToken)
+		a.servers = append(a.servers, tunnelClient)
+		subsystems["tunnel"] = tunnelClient
+		logger.WithField("cloud_address", cfg.Tunnel.CloudAddress).Info("Tunnel client enabled")
+	}
+	
+	// Register with the cloud so it can reach the device without manual setup
+	if cfg.Enrollment.Enabled {
+		a.servers = append(a.servers, tunnel.NewEnroller(cfg, logger, tunnelToken))
+		logger.WithField("cloud_address", cfg.Tunnel.CloudAddress).Info("Cloud enrollment enabled")
+	}
+	
 	// Initialize MQTT client if enabled
 	if cfg.MQTT.Enabled {
 		mqttClient := mqtt.NewClient(
@@ -89,8 +125,11 @@ func (a *Application) initializeServers() error {
 			a.container.CommandService,
 			a.container.ExecutorService,
 			a.container.SecurityService,
+			a.container.MaintenanceService,
 		)
 		a.servers = append(a.servers, mqttClient)
+		subsystems["mqtt"] = mqttClient
+		a.container.ExecutorService.SetResultPublisher(mqttClient, cfg.MQTT.PublishResults)
 		logger.WithField("broker", cfg.MQTT.Broker).Info("MQTT client enabled")
 	}
 	
@@ -98,6 +137,10 @@ func (a *Application) initializeServers() error {
 		return fmt.Errorf("no servers enabled in configuration")
 	}
 	
+	if httpServer != nil {
+		httpServer.SetSubsystems(subsystems)
+	}
+	
 	return nil
 }
 
@@ -131,18 +174,33 @@ func (a *Application) Run() error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	
-	select {
-	case err := <-errChan:
-		logger.WithError(err).Error("Server error occurred")
-		cancel()
-		return err
-	case sig := <-sigChan:
-		logger.WithField("signal", sig).Info("Received shutdown signal")
-		cancel()
-	}
+	// An update installed by the previous process is kept once the servers
+	// have run for a while without failing
+	confirmUpdate := time.After(update.ConfirmDelay)
 	
-	// Perform graceful shutdown
-	return a.shutdown(&wg)
+	for {
+		select {
+		case err := <-errChan:
+			logger.WithError(err).Error("Server error occurred")
+			cancel()
+			return err
+		case sig := <-sigChan:
+			logger.WithField("signal", sig).Info("Received shutdown signal")
+			cancel()
+			
+			// Perform graceful shutdown
+			return a.shutdown(&wg)
+		case <-confirmUpdate:
+			a.container.UpdateService.ConfirmStartup()
+		case <-a.container.UpdateService.RestartRequested():
+			logger.Info("Restarting into the updated agent")
+			cancel()
+			if err := a.shutdown(&wg); err != nil {
+				return err
+			}
+			return a.container.UpdateService.Restart()
+		}
+	}
 }
 
 // startBackgroundTasks starts background maintenance tasks
@@ -161,8 +219,43 @@ func (a *Application) startBackgroundTasks() {
 		}
 	}()
 	
+	// Start audit log retention task
+	if auditService := a.container.AuditService; auditService != nil {
+		interval := time.Duration(a.container.Config.Audit.PruneInterval) * time.Minute
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			
+			logger.Debug("Starting audit log retention task")
+			for {
+				if err := auditService.Prune(); err != nil {
+					logger.WithError(err).Warn("Failed to prune audit log")
+				}
+				<-ticker.C
+			}
+		}()
+	}
+	
+	// Start resource usage sampling
+	if metricsService := a.container.MetricsService; metricsService != nil {
+		go func() {
+			ticker := time.NewTicker(metricsService.Interval())
+			defer ticker.Stop()
+			
+			logger.Debug("Starting metrics sampling task")
+			for {
+				metricsService.Sample()
+				<-ticker.C
+			}
+		}()
+	}
+	
 	// Add other background tasks here as needed
-	// For example: metrics collection, health checks, etc.
+	// For example: health checks, etc.
 }
 
 // shutdown performs graceful shutdown of all components
@@ -170,8 +263,12 @@ func (a *Application) shutdown(wg *sync.WaitGroup) error {
 	logger := a.container.Logger
 	logger.Info("Shutting down application...")
 	
-	// Create shutdown context with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Create shutdown context with timeout, leaving the HTTP server its own shutdown timeout
+	timeout := common.DefaultShutdownTimeout
+	if httpTimeout := time.Duration(a.container.Config.Server.HTTP.ShutdownTimeout)*time.Second + 5*time.Second; httpTimeout > timeout {
+		timeout = httpTimeout
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
 	defer shutdownCancel()
 	
 	// Channel to signal shutdown completion
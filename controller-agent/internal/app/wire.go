@@ -1,14 +1,26 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/metrics"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/scheduler"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/update"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/webhook"
 )
 
 // Container holds all application dependencies
@@ -17,7 +29,17 @@ type Container struct {
 	Logger          *logrus.Logger
 	CommandService  *service.CommandService
 	ExecutorService *executor.Service
-	SecurityService *security.Service
+	SecurityService    *security.Service
+	SchedulerService   *scheduler.Service
+	MaintenanceService *maintenance.Service
+	AuditService       *audit.Service   // nil when auditing is disabled
+	MetricsService     *metrics.Service // nil when metrics history is disabled
+	WebhookService     *webhook.Service // nil without webhook endpoints
+	UpdateService      *update.Service
+	FileService        *files.Service
+	
+	commandStore io.Closer // nil for the file backend
+	logFile      *os.File
 }
 
 // NewContainer creates and initializes all application dependencies
@@ -33,42 +55,124 @@ func NewContainer(configPath string) (*Container, error) {
 	
 	cfg := config.Get()
 	
-	// Configure logger based on config
-	if level, err := logrus.ParseLevel(cfg.Log.Level); err == nil {
-		logger.SetLevel(level)
+	// Configure logger based on config; the level can be changed at runtime
+	switch cfg.Log.Format {
+	case "json", "":
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{})
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected \"json\" or \"text\"", cfg.Log.Format)
 	}
 	
-	if cfg.Log.Format == "text" {
-		logger.SetFormatter(&logrus.TextFormatter{})
+	if level, err := logrus.ParseLevel(cfg.Log.Level); err == nil {
+		logger.SetLevel(level)
+	} else {
+		logger.WithError(err).Warnf("Invalid log level, using %s", logger.GetLevel())
 	}
 	
 	// Setup log output if specified
+	var logFile *os.File
 	if cfg.Log.OutputPath != "" {
-		// Note: File output setup moved to main.go to handle file closure properly
+		file, err := os.OpenFile(cfg.Log.OutputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		logFile = file
+		logger.SetOutput(io.MultiWriter(os.Stderr, logFile))
 		logger.WithField("log_file", cfg.Log.OutputPath).Info("Log file configured")
 	}
 	
 	// Initialize command repository
-	commandRepo := infrastructure.NewFileCommandRepository(cfg.Commands.ConfigPath)
-	
-	// Initialize command repository with data
-	if fileRepo, ok := commandRepo.(*infrastructure.FileCommandRepository); ok {
-		if err := fileRepo.Initialize(); err != nil {
-			return nil, fmt.Errorf("failed to initialize command repository: %w", err)
-		}
+	commandRepo, commandStore, err := newCommandRepository(cfg, logger)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Initialize services
 	commandService := service.NewCommandService(commandRepo)
+	commandService.SetDefaults(cfg.Commands.DefaultCategory, cfg.Commands.DefaultPlatform)
 	executorService := executor.NewService(logger)
+	executorService.SetLoginShell(cfg.Commands.LoginShell)
+	executorService.SetMaxStdinSize(cfg.Commands.MaxStdin)
+	executorService.SetMaxOutputBytes(cfg.Commands.MaxOutput)
+	executorService.SetKillGracePeriod(time.Duration(cfg.Commands.KillGrace) * time.Second)
+	executorService.SetSigningKey([]byte(cfg.Security.ResultSigningKey))
+	executorService.SetMaxConcurrent(cfg.Commands.MaxConcurrent)
+	executorService.SetTenantConcurrency(cfg.Commands.MaxConcurrentPerTenant)
+	executorService.SetDeviceID(cfg.Tunnel.DeviceID)
+	blockedPatterns := append([]string(nil), cfg.Security.BlockedPatterns...)
+	executorService.SetBlockedPatterns(append(blockedPatterns, cfg.Security.ExtraBlockedPatterns...))
+	commandService.SetCommandValidator(executorService)
+	if cfg.Commands.OutputSummary.Enabled {
+		executorService.SetOutputSummary(cfg.Commands.OutputSummary.HeadLines, cfg.Commands.OutputSummary.TailLines)
+	}
 	securityService := security.NewService(cfg, logger)
+	executorService.SetDiagnosticResolver(&diagnosticResolver{
+		commandService:  commandService,
+		securityService: securityService,
+	})
+	dependencies := &dependencyResolver{
+		commandService:  commandService,
+		securityService: securityService,
+	}
+	executorService.SetDependencyResolver(dependencies, time.Duration(cfg.Commands.DependencyFreshness)*time.Second)
+	schedulerService := scheduler.NewService(logger)
+	maintenanceService, err := maintenance.NewService(cfg.Maintenance.StateFile, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize maintenance mode: %w", err)
+	}
+	
+	var auditService *audit.Service
+	if cfg.Audit.Enabled {
+		maxAge := time.Duration(cfg.Audit.MaxAgeDays) * 24 * time.Hour
+		var err error
+		auditService, err = audit.NewService(cfg.Audit.Path, cfg.Audit.MaxEntries, maxAge, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize audit log: %w", err)
+		}
+		executorService.AddExecutionRecorder(auditService)
+		dependencies.auditService = auditService
+	}
+	
+	var webhookService *webhook.Service
+	if len(cfg.Webhooks.Endpoints) > 0 {
+		webhookService = webhook.NewService(cfg.Webhooks, logger)
+		executorService.AddExecutionRecorder(webhookService)
+	}
+	
+	var metricsService *metrics.Service
+	if cfg.Metrics.Enabled {
+		metricsService = metrics.NewService(
+			time.Duration(cfg.Metrics.Interval)*time.Second,
+			time.Duration(cfg.Metrics.Retention)*time.Minute,
+		)
+	}
+	
+	updateService, err := update.NewService(cfg.Update, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize agent updates: %w", err)
+	}
+	
+	fileService, err := files.NewService(cfg.Files, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize file transfers: %w", err)
+	}
 	
 	container := &Container{
-		Config:          cfg,
-		Logger:          logger,
-		CommandService:  commandService,
-		ExecutorService: executorService,
-		SecurityService: securityService,
+		Config:             cfg,
+		Logger:             logger,
+		CommandService:     commandService,
+		ExecutorService:    executorService,
+		SecurityService:    securityService,
+		SchedulerService:   schedulerService,
+		MaintenanceService: maintenanceService,
+		AuditService:       auditService,
+		MetricsService:     metricsService,
+		WebhookService:     webhookService,
+		UpdateService:      updateService,
+		FileService:        fileService,
+		commandStore:       commandStore,
+		logFile:            logFile,
 	}
 	
 	logger.WithFields(logrus.Fields{
@@ -83,8 +187,171 @@ func NewContainer(configPath string) (*Container, error) {
 func (c *Container) Shutdown() {
 	c.Logger.Info("Shutting down application container")
 	
-	// Add any cleanup logic here if needed
-	// For example, closing database connections, flushing logs, etc.
+	// Pending delayed executions do not survive a restart
+	c.SchedulerService.CancelAll()
+	
+	if c.WebhookService != nil {
+		c.WebhookService.Close()
+	}
+	
+	if c.commandStore != nil {
+		if err := c.commandStore.Close(); err != nil {
+			c.Logger.WithError(err).Warn("Failed to close command store")
+		}
+	}
 	
 	c.Logger.Info("Application container shutdown complete")
-}
\ No newline at end of file
+	
+	if c.logFile != nil {
+		c.Logger.SetOutput(os.Stderr)
+		c.logFile.Close()
+	}
+}
+
+// newCommandRepository creates the command repository of the configured
+// backend and loads it. The closer is nil when there is nothing to close
+func newCommandRepository(cfg *config.Config, logger *logrus.Logger) (repository.CommandRepository, io.Closer, error) {
+	switch cfg.Commands.Backend {
+	case "", "file":
+		fileRepo := infrastructure.NewFileCommandRepository(cfg.Commands.ConfigPath).(*infrastructure.FileCommandRepository)
+		fileRepo.SetLoadRetry(infrastructure.LoadRetry{
+			MaxAttempts: cfg.Commands.LoadRetry.MaxAttempts,
+			BaseDelay:   time.Duration(cfg.Commands.LoadRetry.BaseDelay) * time.Second,
+			MaxDelay:    time.Duration(cfg.Commands.LoadRetry.MaxDelay) * time.Second,
+			OnRetry: func(attempt int, delay time.Duration, err error) {
+				logger.WithFields(logrus.Fields{
+					"config_path": cfg.Commands.ConfigPath,
+					"attempt":     attempt,
+					"retry_in":    delay.String(),
+				}).WithError(err).Warn("Failed to load commands, retrying")
+			},
+		})
+		if err := fileRepo.Initialize(); err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize command repository: %w", err)
+		}
+		return fileRepo, nil, nil
+		
+	case "sqlite":
+		sqliteRepo, err := infrastructure.NewSQLiteCommandRepository(cfg.Commands.SQLitePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize command repository: %w", err)
+		}
+		imported, err := sqliteRepo.Initialize(cfg.Commands.ConfigPath)
+		if err != nil {
+			sqliteRepo.Close()
+			return nil, nil, fmt.Errorf("failed to initialize command repository: %w", err)
+		}
+		if imported > 0 {
+			logger.WithFields(logrus.Fields{
+				"config_path": cfg.Commands.ConfigPath,
+				"sqlite_path": cfg.Commands.SQLitePath,
+				"commands":    imported,
+			}).Info("Imported commands file into the SQLite command store")
+		}
+		return sqliteRepo, sqliteRepo, nil
+		
+	default:
+		return nil, nil, fmt.Errorf("unknown commands backend %q", cfg.Commands.Backend)
+	}
+}
+
+// diagnosticResolver resolves failure diagnostics from the command set. A
+// diagnostic must pass the command whitelist like any other execution
+type diagnosticResolver struct {
+	commandService  *service.CommandService
+	securityService *security.Service
+}
+
+func (r *diagnosticResolver) ResolveDiagnostic(ctx context.Context, commandID string) (*executor.Diagnostic, error) {
+	cmd, err := r.commandService.GetCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.securityService.CheckCommandAccess(cmd); err != nil {
+		return nil, err
+	}
+	platformCommand, err := r.commandService.GetPlatformCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &executor.Diagnostic{
+		Command: platformCommand,
+		Options: executor.ExecuteOptions{
+			WorkingDir: cmd.WorkingDir,
+			Env:        cmd.Env,
+			LoginShell: cmd.LoginShell,
+			Shell:      cmd.Shell,
+			Args:       cmd.Args,
+		},
+		Timeout: time.Duration(cmd.GetTimeout()) * time.Millisecond,
+	}, nil
+}
+
+// dependencyResolver checks dependencies against the audit log and resolves
+// them from the command set. Dependencies run automatically must pass the
+// command whitelist and cannot require a PIN, since none is given for them
+type dependencyResolver struct {
+	commandService  *service.CommandService
+	securityService *security.Service
+	auditService    *audit.Service // nil when auditing is disabled
+}
+
+func (r *dependencyResolver) LastSuccess(ctx context.Context, commandID string) (time.Time, error) {
+	if r.auditService == nil {
+		return time.Time{}, fmt.Errorf("the audit log is disabled, so past executions are unknown")
+	}
+	// Executions are audited under the canonical ID, while dependencies may use an alias
+	cmd, err := r.commandService.GetCommand(ctx, commandID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.auditService.LastSuccess(cmd.ID)
+}
+
+func (r *dependencyResolver) ResolveDependency(ctx context.Context, commandID string) (*executor.Dependency, error) {
+	cmd, err := r.commandService.GetCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.securityService.CheckCommandAccess(cmd); err != nil {
+		return nil, err
+	}
+	if cmd.RequiresPin() || cmd.RequiresAdmin() {
+		return nil, fmt.Errorf("command requires a PIN or the admin PIN and cannot run automatically")
+	}
+	platformCommand, err := r.commandService.GetPlatformCommand(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	env, err := cmd.ExecutionEnv(nil)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &executor.Dependency{
+		Command: platformCommand,
+		Options: executor.ExecuteOptions{
+			WorkingDir: cmd.WorkingDir,
+			Env:        env,
+			CommandID:  cmd.ID,
+			Publish:    cmd.PublishResults,
+			LoginShell: cmd.LoginShell,
+			Shell:      cmd.Shell,
+			Args:       cmd.Args,
+			Tenant:     cmd.Tenant(),
+			Category:   cmd.Category,
+
+			PreHook:     cmd.PreHook,
+			PostHook:    cmd.PostHook,
+			StrictHooks: cmd.StrictHooks,
+
+			OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+			OutputFormat:        cmd.OutputFormat,
+			Retry:               cmd.Retry,
+			DependsOn:           cmd.DependsOn,
+			AutoRunDeps:         cmd.AutoRunDeps,
+		},
+		Timeout: time.Duration(cmd.GetTimeout()) * time.Millisecond,
+	}, nil
+}
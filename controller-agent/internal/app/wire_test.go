@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestDiagnosticResolver(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	commands := []*entity.Command{
+		{ID: "capture-logs", Name: "Capture logs", Command: "tail log", Platform: runtime.GOOS, WorkingDir: "/var/log", Timeout: 2000, Security: &entity.SecurityConfig{Whitelist: true}},
+		{ID: "plain", Name: "Plain", Command: "tail log", Platform: runtime.GOOS},
+	}
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	commandService := service.NewCommandService(repo)
+
+	tests := []struct {
+		name        string
+		defaultDeny bool
+		id          string
+		wantErr     bool
+		wantDenied  bool
+	}{
+		{"whitelisted", true, "capture-logs", false, false},
+		{"plain, default allow", false, "plain", false, false},
+		{"plain, default deny", true, "plain", true, true},
+		{"missing", false, "missing", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Security: config.SecurityConfig{DefaultDeny: tt.defaultDeny}}
+			resolver := &diagnosticResolver{commandService: commandService, securityService: security.NewService(cfg, logger)}
+
+			diagnostic, err := resolver.ResolveDiagnostic(context.Background(), tt.id)
+			if (err != nil) != tt.wantErr || errors.Is(err, common.ErrCommandNotAllowed) != tt.wantDenied {
+				t.Fatalf("ResolveDiagnostic() = %v, want error %v, denied %v", err, tt.wantErr, tt.wantDenied)
+			}
+			if tt.id == "capture-logs" && (diagnostic.Command != "tail log" || diagnostic.Options.WorkingDir != "/var/log" || diagnostic.Timeout.Milliseconds() != 2000) {
+				t.Errorf("diagnostic = %+v, want the command's process settings", diagnostic)
+			}
+		})
+	}
+}
@@ -1,27 +1,52 @@
 package entity
 
 import (
+	"fmt"
+	"net"
 	"runtime"
 	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 )
 
 // Command represents a command entity in the domain
 type Command struct {
 	ID             string
+	Aliases        []string // other IDs the command can be looked up and executed by
 	Name           string
 	Description    string
 	Category       string
 	Icon           string
 	Command        string
 	Platform       string
+	Platforms      map[string]string // command string per OS (runtime.GOOS name), used instead of Command there
 	CommandType    string
 	Security       *SecurityConfig
+	RateLimit      *RateLimitConfig
+	AllowedHours   *AllowedHoursConfig
+	Retry          *RetryConfig // nil runs the command once
 	Timeout        int
+	MaxTimeout     int // milliseconds, caps request timeout overrides below the server max; 0 uses the server max
 	UserID         string
 	DeviceID       string
 	HomeLayout     *HomeLayoutConfig
 	TemplateId     string
 	TemplateParams map[string]interface{}
+	Params         []ParamSpec // declared parameters, see ResolveParams
+	WorkingDir     string
+	Env            map[string]string
+	PublishResults bool
+	LoginShell     bool
+	Shell          string   // common.Shell* name, empty for the platform default
+	Args           []string // program and arguments executed directly when Shell is "none"
+	PreHook        string   // run before the command; a failure skips the command
+	PostHook       string   // run after the command with its exit code in LAZYCTRL_EXIT_CODE
+	StrictHooks    bool     // a failing post-hook fails the execution
+	OutputFormat   string   // common.OutputFormat* name, empty for text
+	// ID of a command run when this one fails, its output attached to the result
+	OnFailureDiagnostic string
+	DependsOn      []string // IDs of commands that must have succeeded recently before this one runs
+	AutoRunDeps    bool     // run dependencies that have not succeeded recently instead of failing
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
@@ -31,6 +56,77 @@ type SecurityConfig struct {
 	RequirePin bool
 	Whitelist  bool
 	AdminOnly  bool
+
+	// Restrict execution to these clients; empty lists allow any client
+	AllowedClientIDs []string
+	AllowedIPs       []string // exact addresses or CIDR ranges
+}
+
+// RateLimitConfig limits how often a command may run across all clients
+type RateLimitConfig struct {
+	MaxExecutions int
+	Window        int // seconds
+}
+
+// AllowedHoursConfig restricts execution to a daily time window in the
+// agent's configured timezone
+type AllowedHoursConfig struct {
+	Start string // "HH:MM", inclusive
+	End   string // "HH:MM", exclusive; earlier than Start for windows past midnight
+}
+
+// Validate checks that both ends of the window are valid times of day
+func (a *AllowedHoursConfig) Validate() error {
+	start, err := parseClock(a.Start)
+	if err != nil {
+		return fmt.Errorf("invalid allowed hours start: %w", err)
+	}
+	end, err := parseClock(a.End)
+	if err != nil {
+		return fmt.Errorf("invalid allowed hours end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("allowed hours start and end must differ")
+	}
+	return nil
+}
+
+// NextAllowed returns now if it falls inside the window, otherwise the next
+// time the window opens
+func (a *AllowedHoursConfig) NextAllowed(now time.Time) time.Time {
+	start, errStart := parseClock(a.Start)
+	end, errEnd := parseClock(a.End)
+	if errStart != nil || errEnd != nil {
+		return now
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+
+	var inside bool
+	if start < end {
+		inside = minute >= start && minute < end
+	} else {
+		inside = minute >= start || minute < end
+	}
+	if inside {
+		return now
+	}
+
+	// Opening time today, or tomorrow once it has passed
+	day := now.Day()
+	if minute >= start {
+		day++
+	}
+	return time.Date(now.Year(), now.Month(), day, start/60, start%60, 0, 0, now.Location())
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
 }
 
 // HomeLayoutConfig represents homepage layout configuration
@@ -78,6 +174,49 @@ func (c *Command) GetTimeout() int {
 	return 10000 // Default 10 seconds
 }
 
+// ExecutionTimeout returns how long an execution may run: the request's
+// override when set, otherwise the command's timeout. It is capped by the
+// command's MaxTimeout and by serverMax, where zero means no cap; clamped
+// reports whether a cap lowered it
+func (c *Command) ExecutionTimeout(override, serverMax time.Duration) (timeout time.Duration, clamped bool) {
+	timeout = time.Duration(c.GetTimeout()) * time.Millisecond
+	if override > 0 {
+		timeout = override
+	}
+	
+	limit := serverMax
+	if c.MaxTimeout > 0 {
+		if commandMax := time.Duration(c.MaxTimeout) * time.Millisecond; limit <= 0 || commandMax < limit {
+			limit = commandMax
+		}
+	}
+	if limit > 0 && timeout > limit {
+		return limit, true
+	}
+	return timeout, false
+}
+
+// Tenant returns the device, or failing that the user, the command belongs to
+func (c *Command) Tenant() string {
+	if c.DeviceID != "" {
+		return c.DeviceID
+	}
+	return c.UserID
+}
+
+// Principal identifies the caller commands are scoped to in tenancy mode
+type Principal struct {
+	UserID   string
+	DeviceID string
+}
+
+// VisibleTo reports whether the command belongs to the principal. A command
+// without a user or device is shared by every principal in that respect
+func (c *Command) VisibleTo(principal Principal) bool {
+	return (c.UserID == "" || c.UserID == principal.UserID) &&
+		(c.DeviceID == "" || c.DeviceID == principal.DeviceID)
+}
+
 // IsWhitelisted checks if the command is whitelisted
 func (c *Command) IsWhitelisted() bool {
 	if c.Security == nil {
@@ -86,6 +225,12 @@ func (c *Command) IsWhitelisted() bool {
 	return c.Security.Whitelist
 }
 
+// IsExplicitlyWhitelisted checks if the command opts in to the whitelist,
+// without the default allow of commands that have no security settings
+func (c *Command) IsExplicitlyWhitelisted() bool {
+	return c.Security != nil && c.Security.Whitelist
+}
+
 // RequiresPin checks if the command requires PIN verification
 func (c *Command) RequiresPin() bool {
 	if c.Security == nil {
@@ -102,6 +247,47 @@ func (c *Command) RequiresAdmin() bool {
 	return c.Security.AdminOnly
 }
 
+// IsClientAllowed checks if the command may be executed by the given client.
+// When both lists are set, matching either one is enough
+func (c *Command) IsClientAllowed(clientID, clientIP string) bool {
+	if c.Security == nil || (len(c.Security.AllowedClientIDs) == 0 && len(c.Security.AllowedIPs) == 0) {
+		return true
+	}
+	
+	if clientID != "" {
+		for _, allowed := range c.Security.AllowedClientIDs {
+			if allowed == clientID {
+				return true
+			}
+		}
+	}
+	
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range c.Security.AllowedIPs {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRateLimit checks if the command has its own execution rate limit
+func (c *Command) HasRateLimit() bool {
+	return c.RateLimit != nil && c.RateLimit.MaxExecutions > 0 && c.RateLimit.Window > 0
+}
+
+// HasAllowedHours checks if the command may only run during part of the day
+func (c *Command) HasAllowedHours() bool {
+	return c.AllowedHours != nil && c.AllowedHours.Validate() == nil
+}
+
 // ShowOnHomepage checks if the command should be displayed on homepage
 func (c *Command) ShowOnHomepage() bool {
 	if c.HomeLayout == nil {
@@ -150,7 +336,70 @@ func (c *Command) GetHomepagePriority() int {
 
 // IsAvailableOnPlatform checks if command is available on current platform
 func (c *Command) IsAvailableOnPlatform() bool {
-	return c.Platform == runtime.GOOS
+	_, ok := c.PlatformCommand()
+	return ok
+}
+
+// PlatformCommand returns the command string for the current platform: its
+// variant in Platforms, or else Command when Platform matches
+func (c *Command) PlatformCommand() (string, bool) {
+	if variant := c.Platforms[runtime.GOOS]; variant != "" {
+		return variant, true
+	}
+	if c.Platform == runtime.GOOS {
+		return c.Command, true
+	}
+	return "", false
+}
+
+// HasAlias reports whether name is one of the command's aliases
+func (c *Command) HasAlias(name string) bool {
+	for _, alias := range c.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateShell checks that the command's shell is supported and that a
+// command executed without a shell has a program to run
+func (c *Command) ValidateShell() error {
+	switch c.Shell {
+	case "", common.ShellSh, common.ShellBash, common.ShellCmd, common.ShellPowerShell:
+		return nil
+	case common.ShellNone:
+		if len(c.Args) == 0 || c.Args[0] == "" {
+			return fmt.Errorf("shell %q requires args with the program to execute", c.Shell)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported shell %q", c.Shell)
+}
+
+// ValidatePlatforms checks that every platform variant names a supported OS
+// and has a command string
+func (c *Command) ValidatePlatforms() error {
+	for platform, command := range c.Platforms {
+		switch platform {
+		case common.PlatformWindows, common.PlatformLinux, common.PlatformDarwin:
+		default:
+			return fmt.Errorf("unsupported platform %q", platform)
+		}
+		if command == "" {
+			return fmt.Errorf("platform %q has an empty command", platform)
+		}
+	}
+	return nil
+}
+
+// ValidateOutputFormat checks that the command's output format is supported
+func (c *Command) ValidateOutputFormat() error {
+	switch c.OutputFormat {
+	case "", common.OutputFormatText, common.OutputFormatJSON, common.OutputFormatLines:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q", c.OutputFormat)
 }
 
 // Update updates the command with new values and sets UpdatedAt
@@ -187,12 +436,21 @@ func (c *Command) UpdateFields(updates map[string]interface{}) {
 	if platform, ok := updates["platform"].(string); ok && platform != "" {
 		c.Platform = platform
 	}
+	if platforms, ok := updates["platforms"].(map[string]string); ok {
+		if len(platforms) == 0 {
+			platforms = nil
+		}
+		c.Platforms = platforms
+	}
 	if commandType, ok := updates["commandType"].(string); ok && commandType != "" {
 		c.CommandType = commandType
 	}
 	if timeout, ok := updates["timeout"].(int); ok && timeout > 0 {
 		c.Timeout = timeout
 	}
+	if maxTimeout, ok := updates["maxTimeout"].(int); ok && maxTimeout >= 0 {
+		c.MaxTimeout = maxTimeout
+	}
 	if userID, ok := updates["userId"].(string); ok && userID != "" {
 		c.UserID = userID
 	}
@@ -205,6 +463,63 @@ func (c *Command) UpdateFields(updates map[string]interface{}) {
 	if templateParams, ok := updates["templateParams"].(map[string]interface{}); ok {
 		c.TemplateParams = templateParams
 	}
+	if params, ok := updates["params"].([]ParamSpec); ok {
+		if len(params) == 0 {
+			params = nil
+		}
+		c.Params = params
+	}
+	if workingDir, ok := updates["workingDir"].(string); ok && workingDir != "" {
+		c.WorkingDir = workingDir
+	}
+	if env, ok := updates["env"].(map[string]string); ok {
+		c.Env = env
+	}
+	if publishResults, ok := updates["publishResults"].(bool); ok {
+		c.PublishResults = publishResults
+	}
+	if loginShell, ok := updates["loginShell"].(bool); ok {
+		c.LoginShell = loginShell
+	}
+	if shell, ok := updates["shell"].(string); ok {
+		c.Shell = shell
+	}
+	if args, ok := updates["args"].([]string); ok {
+		c.Args = args
+	}
+	if aliases, ok := updates["aliases"].([]string); ok {
+		c.Aliases = aliases
+	}
+	if preHook, ok := updates["preHook"].(string); ok {
+		c.PreHook = preHook
+	}
+	if postHook, ok := updates["postHook"].(string); ok {
+		c.PostHook = postHook
+	}
+	if strictHooks, ok := updates["strictHooks"].(bool); ok {
+		c.StrictHooks = strictHooks
+	}
+	if outputFormat, ok := updates["outputFormat"].(string); ok {
+		c.OutputFormat = outputFormat
+	}
+	if diagnostic, ok := updates["onFailureDiagnostic"].(string); ok {
+		c.OnFailureDiagnostic = diagnostic
+	}
+	if dependsOn, ok := updates["dependsOn"].([]string); ok {
+		c.DependsOn = dependsOn
+	}
+	if autoRunDeps, ok := updates["autoRunDeps"].(bool); ok {
+		c.AutoRunDeps = autoRunDeps
+	}
+	if rateLimit, ok := updates["rateLimit"].(*RateLimitConfig); ok {
+		c.RateLimit = rateLimit
+	}
+	if allowedHours, ok := updates["allowedHours"].(*AllowedHoursConfig); ok {
+		c.AllowedHours = allowedHours
+	}
+	if retry, ok := updates["retry"].(*RetryConfig); ok {
+		c.Retry = retry
+	}
 	c.UpdatedAt = time.Now()
 }
 
@@ -217,6 +532,15 @@ func (c *Command) SetSecurity(requirePin, whitelist, adminOnly bool) {
 	}
 }
 
+// SetAllowedClients restricts execution to the given client IDs and IP addresses
+func (c *Command) SetAllowedClients(clientIDs, ips []string) {
+	if c.Security == nil {
+		c.Security = &SecurityConfig{}
+	}
+	c.Security.AllowedClientIDs = clientIDs
+	c.Security.AllowedIPs = ips
+}
+
 // SetHomeLayout sets homepage layout configuration
 func (c *Command) SetHomeLayout(showOnHome bool, position *PositionConfig, color string, priority int) {
 	c.HomeLayout = &HomeLayoutConfig{
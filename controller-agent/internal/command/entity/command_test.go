@@ -0,0 +1,183 @@
+package entity
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// otherPlatform is a platform the tests are not running on
+func otherPlatform() string {
+	if runtime.GOOS == "windows" {
+		return "linux"
+	}
+	return "windows"
+}
+
+func TestIsAvailableOnPlatform(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Command
+		want bool
+	}{
+		{"current platform", Command{Command: "echo", Platform: runtime.GOOS}, true},
+		{"current platform, not whitelisted", Command{Command: "echo", Platform: runtime.GOOS, Security: &SecurityConfig{}}, true},
+		{"other platform", Command{Command: "echo", Platform: otherPlatform()}, false},
+		{"other platform, whitelisted", Command{Command: "echo", Platform: otherPlatform(), Security: &SecurityConfig{Whitelist: true}}, false},
+		{"variant for current platform", Command{Command: "echo", Platform: otherPlatform(), Platforms: map[string]string{runtime.GOOS: "echo variant"}}, true},
+		{"empty variant for current platform", Command{Command: "echo", Platform: otherPlatform(), Platforms: map[string]string{runtime.GOOS: ""}}, false},
+		{"no platform", Command{Command: "echo"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmd.IsAvailableOnPlatform(); got != tt.want {
+				t.Errorf("IsAvailableOnPlatform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWhitelisted(t *testing.T) {
+	tests := []struct {
+		name         string
+		security     *SecurityConfig
+		want         bool
+		wantExplicit bool
+	}{
+		{"no security settings", nil, true, false},
+		{"not whitelisted", &SecurityConfig{}, false, false},
+		{"whitelisted", &SecurityConfig{Whitelist: true}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Command{Security: tt.security}
+			if got := cmd.IsWhitelisted(); got != tt.want {
+				t.Errorf("IsWhitelisted() = %v, want %v", got, tt.want)
+			}
+			if got := cmd.IsExplicitlyWhitelisted(); got != tt.wantExplicit {
+				t.Errorf("IsExplicitlyWhitelisted() = %v, want %v", got, tt.wantExplicit)
+			}
+		})
+	}
+}
+
+func TestIsClientAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		security *SecurityConfig
+		clientID string
+		clientIP string
+		want     bool
+	}{
+		{"no security settings", nil, "", "", true},
+		{"no restriction", &SecurityConfig{}, "", "", true},
+		{"allowed client ID", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}}, "kitchen", "", true},
+		{"other client ID", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}}, "garage", "10.0.0.1", false},
+		{"no client ID", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}}, "", "10.0.0.1", false},
+		{"allowed IP", &SecurityConfig{AllowedIPs: []string{"10.0.0.1"}}, "", "10.0.0.1", true},
+		{"other IP", &SecurityConfig{AllowedIPs: []string{"10.0.0.1"}}, "", "10.0.0.2", false},
+		{"IP in allowed network", &SecurityConfig{AllowedIPs: []string{"192.168.1.0/24"}}, "", "192.168.1.77", true},
+		{"IP outside allowed network", &SecurityConfig{AllowedIPs: []string{"192.168.1.0/24"}}, "", "192.168.2.1", false},
+		{"allowed IPv6", &SecurityConfig{AllowedIPs: []string{"fd00::/8"}}, "", "fd00::1", true},
+		{"unparsable IP", &SecurityConfig{AllowedIPs: []string{"10.0.0.1"}}, "", "not-an-ip", false},
+		{"no IP", &SecurityConfig{AllowedIPs: []string{"10.0.0.1"}}, "", "", false},
+		{"ID match suffices with both lists", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}, AllowedIPs: []string{"10.0.0.1"}}, "kitchen", "10.0.0.2", true},
+		{"IP match suffices with both lists", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}, AllowedIPs: []string{"10.0.0.1"}}, "garage", "10.0.0.1", true},
+		{"neither matches with both lists", &SecurityConfig{AllowedClientIDs: []string{"kitchen"}, AllowedIPs: []string{"10.0.0.1"}}, "garage", "10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Command{Security: tt.security}
+			if got := cmd.IsClientAllowed(tt.clientID, tt.clientIP); got != tt.want {
+				t.Errorf("IsClientAllowed(%q, %q) = %v, want %v", tt.clientID, tt.clientIP, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedHoursValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hours   AllowedHoursConfig
+		wantErr bool
+	}{
+		{"daytime", AllowedHoursConfig{Start: "09:00", End: "17:30"}, false},
+		{"past midnight", AllowedHoursConfig{Start: "22:00", End: "02:00"}, false},
+		{"empty start", AllowedHoursConfig{End: "17:00"}, true},
+		{"hour out of range", AllowedHoursConfig{Start: "24:00", End: "02:00"}, true},
+		{"not a time", AllowedHoursConfig{Start: "9am", End: "5pm"}, true},
+		{"start equals end", AllowedHoursConfig{Start: "09:00", End: "09:00"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hours.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextAllowed(t *testing.T) {
+	day := func(d, hour, minute int) time.Time {
+		return time.Date(2026, time.January, d, hour, minute, 0, 0, time.UTC)
+	}
+	daytime := AllowedHoursConfig{Start: "09:00", End: "17:00"}
+	night := AllowedHoursConfig{Start: "22:00", End: "02:00"}
+
+	tests := []struct {
+		name  string
+		hours AllowedHoursConfig
+		now   time.Time
+		want  time.Time
+	}{
+		{"inside", daytime, day(10, 12, 0), day(10, 12, 0)},
+		{"at the start", daytime, day(10, 9, 0), day(10, 9, 0)},
+		{"at the end", daytime, day(10, 17, 0), day(11, 9, 0)},
+		{"before the start", daytime, day(10, 7, 30), day(10, 9, 0)},
+		{"after the end", daytime, day(10, 20, 0), day(11, 9, 0)},
+		{"after the end of the month", daytime, day(31, 20, 0), time.Date(2026, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"past midnight, before midnight", night, day(10, 23, 0), day(10, 23, 0)},
+		{"past midnight, after midnight", night, day(10, 1, 59), day(10, 1, 59)},
+		{"past midnight, outside", night, day(10, 12, 0), day(10, 22, 0)},
+		{"past midnight, at the end", night, day(10, 2, 0), day(10, 22, 0)},
+		{"invalid window allows", AllowedHoursConfig{Start: "late", End: "early"}, day(10, 12, 0), day(10, 12, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hours.NextAllowed(tt.now); !got.Equal(tt.want) {
+				t.Errorf("NextAllowed(%s) = %s, want %s", tt.now.Format("Jan 2 15:04"), got.Format("Jan 2 15:04"), tt.want.Format("Jan 2 15:04"))
+			}
+		})
+	}
+}
+
+func TestExecutionTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         Command
+		override    time.Duration
+		serverMax   time.Duration
+		want        time.Duration
+		wantClamped bool
+	}{
+		{"default timeout", Command{}, 0, 0, 10 * time.Second, false},
+		{"command timeout", Command{Timeout: 5000}, 0, 0, 5 * time.Second, false},
+		{"override", Command{Timeout: 5000}, 20 * time.Second, 0, 20 * time.Second, false},
+		{"override below the global max", Command{}, 20 * time.Second, time.Minute, 20 * time.Second, false},
+		{"override above the global max", Command{}, time.Hour, time.Minute, time.Minute, true},
+		{"command timeout above the global max", Command{Timeout: 120000}, 0, time.Minute, time.Minute, true},
+		{"override above the command max", Command{MaxTimeout: 30000}, time.Minute, 0, 30 * time.Second, true},
+		{"command max below the global max", Command{MaxTimeout: 30000}, time.Hour, time.Minute, 30 * time.Second, true},
+		{"command max above the global max", Command{MaxTimeout: 300000}, time.Hour, time.Minute, time.Minute, true},
+		{"default timeout above the command max", Command{MaxTimeout: 2000}, 0, time.Minute, 2 * time.Second, true},
+		{"at the max", Command{MaxTimeout: 30000}, 30 * time.Second, time.Minute, 30 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, clamped := tt.cmd.ExecutionTimeout(tt.override, tt.serverMax)
+			if got != tt.want || clamped != tt.wantClamped {
+				t.Errorf("ExecutionTimeout(%v, %v) = %v, %v; want %v, %v", tt.override, tt.serverMax, got, clamped, tt.want, tt.wantClamped)
+			}
+		})
+	}
+}
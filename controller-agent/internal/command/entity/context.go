@@ -0,0 +1,44 @@
+package entity
+
+import "strings"
+
+// Environment variables the executor sets for every execution, after the
+// command's env and parameters so neither can override them
+const (
+	ContextEnvDeviceID = "LAZYCTRL_DEVICE_ID"
+	ContextEnvUserID   = "LAZYCTRL_USER_ID"
+	ContextEnvNow      = "LAZYCTRL_NOW"
+	ContextEnvPlatform = "LAZYCTRL_PLATFORM"
+)
+
+// ParamTemplate is how a command string or argument references a resolved
+// parameter, with the parameter's name in place of <name>
+const ParamTemplate = "{{.Params.<name>}}"
+
+// ContextVariable describes a built-in value exposed to every command
+type ContextVariable struct {
+	Name        string `json:"name"` // reserved, parameters cannot use it
+	Env         string `json:"env"`
+	Template    string `json:"template"` // placeholder in the command string or args
+	Description string `json:"description"`
+}
+
+// ContextVariables lists the built-in values in the order they are documented
+var ContextVariables = []ContextVariable{
+	{Name: "DeviceID", Env: ContextEnvDeviceID, Template: "{{.DeviceID}}", Description: "the agent's tunnel.device_id, empty when not configured"},
+	{Name: "UserID", Env: ContextEnvUserID, Template: "{{.UserID}}", Description: "the user of the caller's tenant token (X-Tenant-Token header, x-tenant-token gRPC metadata or tenantToken MQTT field), empty without a valid one"},
+	{Name: "Now", Env: ContextEnvNow, Template: "{{.Now}}", Description: "the time the process was started, RFC 3339"},
+	{Name: "Platform", Env: ContextEnvPlatform, Template: "{{.Platform}}", Description: "the agent's operating system, such as linux or windows"},
+}
+
+// IsReservedParamName reports whether name refers to a context variable,
+// ignoring case and underscores so "user_id" cannot pass for "UserID"
+func IsReservedParamName(name string) bool {
+	normalized := strings.ToUpper(strings.ReplaceAll(name, "_", ""))
+	for _, variable := range ContextVariables {
+		if normalized == strings.ToUpper(variable.Name) {
+			return true
+		}
+	}
+	return false
+}
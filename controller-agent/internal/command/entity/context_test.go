@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestIsReservedParamName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"DeviceID", true},
+		{"UserID", true},
+		{"Now", true},
+		{"Platform", true},
+		{"userid", true},
+		{"user_id", true},
+		{"USER_ID", true},
+		{"_Device_Id_", true},
+		{"user", false},
+		{"device", false},
+		{"nowait", false},
+		{"port", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsReservedParamName(tt.name); got != tt.want {
+				t.Fatalf("IsReservedParamName(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedParamNamesAreRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		specs  []ParamSpec
+		params map[string]interface{}
+	}{
+		{"spec named after a context variable", []ParamSpec{{Name: "user_id", Type: common.ParamTypeString}}, nil},
+		{"request param spoofing the user", []ParamSpec{{Name: "port", Type: common.ParamTypeInt}}, map[string]interface{}{"UserID": "admin"}},
+		{"request param on a command without specs", nil, map[string]interface{}{"device_id": "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{ID: "cmd", Params: tt.specs}
+			if tt.params == nil {
+				if err := cmd.ValidateParamSpecs(); err == nil {
+					t.Fatalf("ValidateParamSpecs() accepted a reserved name")
+				}
+				return
+			}
+			if err := cmd.ValidateParamSpecs(); err != nil {
+				t.Fatalf("ValidateParamSpecs() = %v", err)
+			}
+			if _, err := cmd.ExecutionEnv(tt.params); !errors.Is(err, common.ErrCommandInvalidParams) {
+				t.Fatalf("ExecutionEnv() = %v, want %v", err, common.ErrCommandInvalidParams)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package entity
+
+import "sort"
+
+// DependencyCycle returns a chain of command IDs leading from a command back
+// to itself through DependsOn, such as [a b a], or nil if there is none.
+// Dependencies may name commands by alias; unknown ones are ignored
+func DependencyCycle(commands []*Command) []string {
+	byName := make(map[string]*Command, len(commands))
+	for _, cmd := range commands {
+		for _, alias := range cmd.Aliases {
+			byName[alias] = cmd
+		}
+	}
+	for _, cmd := range commands {
+		byName[cmd.ID] = cmd
+	}
+
+	// Visit in ID order so the same set always reports the same cycle
+	sorted := append([]*Command(nil), commands...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(commands))
+	var path []string
+
+	var visit func(cmd *Command) []string
+	visit = func(cmd *Command) []string {
+		switch state[cmd.ID] {
+		case done:
+			return nil
+		case visiting:
+			// The cycle starts where the command first appears on the path
+			for i, id := range path {
+				if id == cmd.ID {
+					return append(append([]string(nil), path[i:]...), cmd.ID)
+				}
+			}
+		}
+
+		state[cmd.ID] = visiting
+		path = append(path, cmd.ID)
+		for _, name := range cmd.DependsOn {
+			if dependency, ok := byName[name]; ok {
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[cmd.ID] = done
+		return nil
+	}
+
+	for _, cmd := range sorted {
+		if cycle := visit(cmd); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
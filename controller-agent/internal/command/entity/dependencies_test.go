@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []*Command
+		want     []string
+	}{
+		{"no dependencies", []*Command{{ID: "a"}, {ID: "b"}}, nil},
+		{"chain", []*Command{{ID: "a", DependsOn: []string{"b"}}, {ID: "b", DependsOn: []string{"c"}}, {ID: "c"}}, nil},
+		{"diamond", []*Command{
+			{ID: "a", DependsOn: []string{"b", "c"}},
+			{ID: "b", DependsOn: []string{"d"}},
+			{ID: "c", DependsOn: []string{"d"}},
+			{ID: "d"},
+		}, nil},
+		{"self", []*Command{{ID: "a", DependsOn: []string{"a"}}}, []string{"a", "a"}},
+		{"two commands", []*Command{{ID: "a", DependsOn: []string{"b"}}, {ID: "b", DependsOn: []string{"a"}}}, []string{"a", "b", "a"}},
+		{"cycle below the entry", []*Command{
+			{ID: "a", DependsOn: []string{"b"}},
+			{ID: "b", DependsOn: []string{"c"}},
+			{ID: "c", DependsOn: []string{"b"}},
+		}, []string{"b", "c", "b"}},
+		{"through an alias", []*Command{
+			{ID: "a", DependsOn: []string{"bee"}},
+			{ID: "b", Aliases: []string{"bee"}, DependsOn: []string{"a"}},
+		}, []string{"a", "b", "a"}},
+		{"unknown dependency ignored", []*Command{{ID: "a", DependsOn: []string{"missing"}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DependencyCycle(tt.commands); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DependencyCycle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
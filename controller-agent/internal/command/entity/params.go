@@ -0,0 +1,192 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// ParamEnvPrefix prefixes the environment variables resolved parameters are
+// passed to the command in, followed by the upper-cased parameter name
+const ParamEnvPrefix = "LAZYCTRL_PARAM_"
+
+// paramNamePattern keeps parameter names usable as environment variable names
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParamSpec declares a parameter a command accepts
+type ParamSpec struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // common.ParamType* name
+	Required bool        `json:"required,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+	Values   []string    `json:"values,omitempty"` // allowed values of an enum
+}
+
+// ValidateParamSpecs checks that every parameter spec has a unique usable
+// name, a supported type and a default of that type
+func (c *Command) ValidateParamSpecs() error {
+	seen := make(map[string]bool, len(c.Params))
+	for _, spec := range c.Params {
+		if !paramNamePattern.MatchString(spec.Name) {
+			return fmt.Errorf("invalid parameter name %q", spec.Name)
+		}
+		if IsReservedParamName(spec.Name) {
+			return fmt.Errorf("parameter name %q is reserved for a context variable", spec.Name)
+		}
+		key := strings.ToUpper(spec.Name)
+		if seen[key] {
+			return fmt.Errorf("duplicate parameter %q", spec.Name)
+		}
+		seen[key] = true
+
+		switch spec.Type {
+		case common.ParamTypeString, common.ParamTypeInt, common.ParamTypeBool:
+			if len(spec.Values) > 0 {
+				return fmt.Errorf("parameter %q: only enum parameters have values", spec.Name)
+			}
+		case common.ParamTypeEnum:
+			if len(spec.Values) == 0 {
+				return fmt.Errorf("parameter %q: enum requires values", spec.Name)
+			}
+		default:
+			return fmt.Errorf("parameter %q: unsupported type %q", spec.Name, spec.Type)
+		}
+
+		if spec.Default != nil {
+			if _, err := spec.convert(spec.Default); err != nil {
+				return fmt.Errorf("parameter %q: invalid default: %w", spec.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveParams checks params against the command's parameter specs and
+// returns every parameter's value as a string, defaults filled in. Unknown,
+// mistyped and missing required parameters fail with
+// common.ErrCommandInvalidParams, as do params named after a context
+// variable. A command without specs accepts any other params and resolves none
+func (c *Command) ResolveParams(params map[string]interface{}) (map[string]string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if IsReservedParamName(name) {
+			return nil, fmt.Errorf("%w: parameter %q is reserved for a context variable", common.ErrCommandInvalidParams, name)
+		}
+	}
+	if len(c.Params) == 0 {
+		return nil, nil
+	}
+
+	specs := make(map[string]ParamSpec, len(c.Params))
+	for _, spec := range c.Params {
+		specs[spec.Name] = spec
+	}
+	for _, name := range names {
+		if _, ok := specs[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown parameter %q", common.ErrCommandInvalidParams, name)
+		}
+	}
+
+	resolved := make(map[string]string, len(c.Params))
+	for _, spec := range c.Params {
+		value, ok := params[spec.Name]
+		if !ok || value == nil {
+			if spec.Default == nil {
+				if spec.Required {
+					return nil, fmt.Errorf("%w: missing required parameter %q", common.ErrCommandInvalidParams, spec.Name)
+				}
+				continue
+			}
+			value = spec.Default
+		}
+
+		converted, err := spec.convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parameter %q: %s", common.ErrCommandInvalidParams, spec.Name, err)
+		}
+		resolved[spec.Name] = converted
+	}
+	return resolved, nil
+}
+
+// ParamEnv returns the resolved parameters as environment variables
+func ParamEnv(resolved map[string]string) map[string]string {
+	env := make(map[string]string, len(resolved))
+	for name, value := range resolved {
+		env[ParamEnvPrefix+strings.ToUpper(name)] = value
+	}
+	return env
+}
+
+// ExecutionEnv resolves the command's template params, overridden by params
+// given for this execution, and returns the command's environment with the
+// resolved parameters added
+func (c *Command) ExecutionEnv(params map[string]interface{}) (map[string]string, error) {
+	merged := make(map[string]interface{}, len(c.TemplateParams)+len(params))
+	for name, value := range c.TemplateParams {
+		merged[name] = value
+	}
+	for name, value := range params {
+		merged[name] = value
+	}
+	resolved, err := c.ResolveParams(merged)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved) == 0 {
+		return c.Env, nil
+	}
+
+	env := ParamEnv(resolved)
+	for key, value := range c.Env {
+		env[key] = value
+	}
+	return env, nil
+}
+
+// convert checks value against the spec's type and formats it as a string.
+// Numbers decoded from JSON arrive as float64, so integral floats are ints
+func (spec ParamSpec) convert(value interface{}) (string, error) {
+	switch spec.Type {
+	case common.ParamTypeString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case common.ParamTypeInt:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return strconv.FormatInt(int64(v), 10), nil
+			}
+		}
+	case common.ParamTypeBool:
+		if b, ok := value.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	case common.ParamTypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			break
+		}
+		for _, allowed := range spec.Values {
+			if s == allowed {
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", s, strings.Join(spec.Values, ", "))
+	}
+	return "", fmt.Errorf("expected %s, got %T", spec.Type, value)
+}
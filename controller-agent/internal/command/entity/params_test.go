@@ -0,0 +1,133 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestValidateParamSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []ParamSpec
+		wantErr bool
+	}{
+		{"no specs", nil, false},
+		{"every type", []ParamSpec{
+			{Name: "host", Type: common.ParamTypeString, Required: true},
+			{Name: "port", Type: common.ParamTypeInt, Default: float64(22)},
+			{Name: "verbose", Type: common.ParamTypeBool, Default: false},
+			{Name: "mode", Type: common.ParamTypeEnum, Values: []string{"fast", "safe"}, Default: "safe"},
+		}, false},
+		{"invalid name", []ParamSpec{{Name: "my-param", Type: common.ParamTypeString}}, true},
+		{"duplicate name ignoring case", []ParamSpec{{Name: "port", Type: common.ParamTypeInt}, {Name: "PORT", Type: common.ParamTypeInt}}, true},
+		{"unsupported type", []ParamSpec{{Name: "ratio", Type: "float"}}, true},
+		{"enum without values", []ParamSpec{{Name: "mode", Type: common.ParamTypeEnum}}, true},
+		{"values on a string", []ParamSpec{{Name: "host", Type: common.ParamTypeString, Values: []string{"a"}}}, true},
+		{"default of the wrong type", []ParamSpec{{Name: "port", Type: common.ParamTypeInt, Default: "22"}}, true},
+		{"default outside the enum", []ParamSpec{{Name: "mode", Type: common.ParamTypeEnum, Values: []string{"fast"}, Default: "slow"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{ID: "cmd", Params: tt.specs}
+			if err := cmd.ValidateParamSpecs(); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateParamSpecs() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	specs := []ParamSpec{
+		{Name: "host", Type: common.ParamTypeString, Required: true},
+		{Name: "port", Type: common.ParamTypeInt, Default: float64(22)},
+		{Name: "verbose", Type: common.ParamTypeBool},
+		{Name: "mode", Type: common.ParamTypeEnum, Values: []string{"fast", "safe"}},
+	}
+
+	tests := []struct {
+		name    string
+		specs   []ParamSpec
+		params  map[string]interface{}
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "defaults filled in",
+			specs:  specs,
+			params: map[string]interface{}{"host": "nas"},
+			want:   map[string]string{"host": "nas", "port": "22"},
+		},
+		{
+			name:   "every type given",
+			specs:  specs,
+			params: map[string]interface{}{"host": "nas", "port": float64(2222), "verbose": true, "mode": "fast"},
+			want:   map[string]string{"host": "nas", "port": "2222", "verbose": "true", "mode": "fast"},
+		},
+		{
+			name:   "null falls back to the default",
+			specs:  specs,
+			params: map[string]interface{}{"host": "nas", "port": nil},
+			want:   map[string]string{"host": "nas", "port": "22"},
+		},
+		{name: "required missing", specs: specs, params: map[string]interface{}{"port": float64(22)}, wantErr: true},
+		{name: "required null", specs: specs, params: map[string]interface{}{"host": nil}, wantErr: true},
+		{name: "enum violation", specs: specs, params: map[string]interface{}{"host": "nas", "mode": "reckless"}, wantErr: true},
+		{name: "enum given a number", specs: specs, params: map[string]interface{}{"host": "nas", "mode": float64(1)}, wantErr: true},
+		{name: "unknown param", specs: specs, params: map[string]interface{}{"host": "nas", "user": "root"}, wantErr: true},
+		{name: "string given a number", specs: specs, params: map[string]interface{}{"host": float64(1)}, wantErr: true},
+		{name: "int given a fraction", specs: specs, params: map[string]interface{}{"host": "nas", "port": 22.5}, wantErr: true},
+		{name: "int given a string", specs: specs, params: map[string]interface{}{"host": "nas", "port": "22"}, wantErr: true},
+		{name: "bool given a string", specs: specs, params: map[string]interface{}{"host": "nas", "verbose": "true"}, wantErr: true},
+		{name: "no specs accept anything", params: map[string]interface{}{"anything": "goes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{ID: "cmd", Params: tt.specs}
+			got, err := cmd.ResolveParams(tt.params)
+			if tt.wantErr {
+				if !errors.Is(err, common.ErrCommandInvalidParams) {
+					t.Fatalf("ResolveParams() = %v, want %v", err, common.ErrCommandInvalidParams)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveParams() = %v", err)
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ResolveParams() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutionEnvParams(t *testing.T) {
+	cmd := &Command{
+		ID:             "cmd",
+		Env:            map[string]string{"LANG": "C", ParamEnvPrefix + "MODE": "pinned"},
+		TemplateParams: map[string]interface{}{"host": "nas", "mode": "safe"},
+		Params: []ParamSpec{
+			{Name: "host", Type: common.ParamTypeString, Required: true},
+			{Name: "mode", Type: common.ParamTypeEnum, Values: []string{"fast", "safe"}},
+		},
+	}
+
+	// Request params override the stored templateParams, and the command's
+	// own env overrides both
+	env, err := cmd.ExecutionEnv(map[string]interface{}{"host": "router"})
+	if err != nil {
+		t.Fatalf("ExecutionEnv() = %v", err)
+	}
+	want := map[string]string{
+		"LANG":                  "C",
+		ParamEnvPrefix + "HOST": "router",
+		ParamEnvPrefix + "MODE": "pinned",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("ExecutionEnv() = %v, want %v", env, want)
+	}
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// RetryConfig retries failed executions of a command. Attempts stop early
+// when the execution is cancelled or times out
+type RetryConfig struct {
+	MaxAttempts int     `json:"maxAttempts"`         // attempts including the first; 1 or less never retries
+	Delay       int     `json:"delay,omitempty"`     // milliseconds between the first two attempts
+	Backoff     float64 `json:"backoff,omitempty"`   // factor the delay grows by per attempt; 0 keeps it constant
+	ExitCodes   []int   `json:"exitCodes,omitempty"` // exit codes worth retrying; empty retries every failure
+}
+
+// Validate checks the attempt count, delay and backoff
+func (r *RetryConfig) Validate() error {
+	if r.MaxAttempts < 0 || r.MaxAttempts > common.MaxRetryAttempts {
+		return fmt.Errorf("retry maxAttempts must be between 0 and %d", common.MaxRetryAttempts)
+	}
+	if r.Delay < 0 || time.Duration(r.Delay)*time.Millisecond > common.MaxRetryDelay {
+		return fmt.Errorf("retry delay must be between 0 and %d milliseconds", common.MaxRetryDelay.Milliseconds())
+	}
+	if r.Backoff != 0 && r.Backoff < 1 {
+		return fmt.Errorf("retry backoff must be 0 or at least 1")
+	}
+	return nil
+}
+
+// Retryable reports whether an execution that failed with exitCode on the
+// given attempt, counting from 1, is attempted again
+func (r *RetryConfig) Retryable(attempt, exitCode int) bool {
+	if r == nil || attempt >= r.MaxAttempts {
+		return false
+	}
+	return len(r.ExitCodes) == 0 || slices.Contains(r.ExitCodes, exitCode)
+}
+
+// DelayAfter returns how long to wait after the given failed attempt,
+// growing by Backoff per attempt up to common.MaxRetryDelay
+func (r *RetryConfig) DelayAfter(attempt int) time.Duration {
+	delay := time.Duration(r.Delay) * time.Millisecond
+	for i := 1; i < attempt && r.Backoff > 1 && delay < common.MaxRetryDelay; i++ {
+		delay = time.Duration(float64(delay) * r.Backoff)
+	}
+	return min(delay, common.MaxRetryDelay)
+}
+
+// HasRetry checks if failed executions of the command are retried
+func (c *Command) HasRetry() bool {
+	return c.Retry != nil && c.Retry.MaxAttempts > 1
+}
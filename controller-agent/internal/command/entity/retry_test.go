@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestRetryConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		wantErr bool
+	}{
+		{"zero value", RetryConfig{}, false},
+		{"full policy", RetryConfig{MaxAttempts: 3, Delay: 500, Backoff: 2, ExitCodes: []int{1, 75}}, false},
+		{"most attempts", RetryConfig{MaxAttempts: common.MaxRetryAttempts}, false},
+		{"too many attempts", RetryConfig{MaxAttempts: common.MaxRetryAttempts + 1}, true},
+		{"negative attempts", RetryConfig{MaxAttempts: -1}, true},
+		{"negative delay", RetryConfig{MaxAttempts: 2, Delay: -1}, true},
+		{"delay over the cap", RetryConfig{MaxAttempts: 2, Delay: int(common.MaxRetryDelay.Milliseconds()) + 1}, true},
+		{"shrinking backoff", RetryConfig{MaxAttempts: 2, Backoff: 0.5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.retry.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryConfigRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    *RetryConfig
+		attempt  int
+		exitCode int
+		want     bool
+	}{
+		{"no policy", nil, 1, 1, false},
+		{"single attempt", &RetryConfig{MaxAttempts: 1}, 1, 1, false},
+		{"attempts left", &RetryConfig{MaxAttempts: 3}, 2, 1, true},
+		{"attempts used up", &RetryConfig{MaxAttempts: 3}, 3, 1, false},
+		{"listed exit code", &RetryConfig{MaxAttempts: 3, ExitCodes: []int{75}}, 1, 75, true},
+		{"unlisted exit code", &RetryConfig{MaxAttempts: 3, ExitCodes: []int{75}}, 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retry.Retryable(tt.attempt, tt.exitCode); got != tt.want {
+				t.Fatalf("Retryable(%d, %d) = %v, want %v", tt.attempt, tt.exitCode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryConfigDelayAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		attempt int
+		want    time.Duration
+	}{
+		{"constant", RetryConfig{Delay: 100}, 3, 100 * time.Millisecond},
+		{"first attempt", RetryConfig{Delay: 100, Backoff: 2}, 1, 100 * time.Millisecond},
+		{"doubled twice", RetryConfig{Delay: 100, Backoff: 2}, 3, 400 * time.Millisecond},
+		{"backoff of one", RetryConfig{Delay: 100, Backoff: 1}, 5, 100 * time.Millisecond},
+		{"capped", RetryConfig{Delay: 60000, Backoff: 10}, 5, common.MaxRetryDelay},
+		{"no delay", RetryConfig{Backoff: 2}, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retry.DelayAfter(tt.attempt); got != tt.want {
+				t.Fatalf("DelayAfter(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
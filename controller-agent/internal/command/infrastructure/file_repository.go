@@ -3,9 +3,13 @@ package infrastructure
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,12 +23,15 @@ type FileCommandRepository struct {
 	commands   map[string]*entity.Command
 	version    string
 	mu         sync.RWMutex // 保护并发访问
+	loadRetry  LoadRetry
 }
 
-// CommandConfig represents the JSON structure of command configuration file
-type CommandConfig struct {
-	Version  string           `json:"version"`
-	Commands []*entity.Command `json:"commands"`
+// LoadRetry controls how Initialize retries a commands file that cannot be loaded yet
+type LoadRetry struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	OnRetry     func(attempt int, delay time.Duration, err error) // called before each wait, may be nil
 }
 
 // NewFileCommandRepository creates a new file-based command repository
@@ -38,19 +45,16 @@ func NewFileCommandRepository(configPath string) repository.CommandRepository {
 
 // Create creates a new command
 func (r *FileCommandRepository) Create(ctx context.Context, command *entity.Command) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	
-	// Check if command already exists
-	if _, exists := r.commands[command.ID]; exists {
-		return fmt.Errorf("command with ID %s already exists", command.ID)
-	}
-	
-	// Add command to memory
-	r.commands[command.ID] = command
-	
-	// Save to file
-	return r.saveToFile()
+	return r.modify(func() error {
+		// Check if command already exists
+		if _, exists := r.commands[command.ID]; exists {
+			return fmt.Errorf("command with ID %s already exists", command.ID)
+		}
+		
+		// Add command to memory
+		r.commands[command.ID] = command
+		return nil
+	})
 }
 
 // GetByID retrieves a command by its ID
@@ -81,36 +85,30 @@ func (r *FileCommandRepository) GetAll(ctx context.Context) ([]*entity.Command,
 
 // Update updates an existing command
 func (r *FileCommandRepository) Update(ctx context.Context, command *entity.Command) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	
-	// Check if command exists
-	if _, exists := r.commands[command.ID]; !exists {
-		return fmt.Errorf("command not found: %s", command.ID)
-	}
-	
-	// Update command in memory
-	r.commands[command.ID] = command
-	
-	// Save to file
-	return r.saveToFile()
+	return r.modify(func() error {
+		// Check if command exists
+		if _, exists := r.commands[command.ID]; !exists {
+			return fmt.Errorf("command not found: %s", command.ID)
+		}
+		
+		// Update command in memory
+		r.commands[command.ID] = command
+		return nil
+	})
 }
 
 // Delete deletes a command by ID
 func (r *FileCommandRepository) Delete(ctx context.Context, id string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	
-	// Check if command exists
-	if _, exists := r.commands[id]; !exists {
-		return fmt.Errorf("command not found: %s", id)
-	}
-	
-	// Delete from memory
-	delete(r.commands, id)
-	
-	// Save to file
-	return r.saveToFile()
+	return r.modify(func() error {
+		// Check if command exists
+		if _, exists := r.commands[id]; !exists {
+			return fmt.Errorf("command not found: %s", id)
+		}
+		
+		// Delete from memory
+		delete(r.commands, id)
+		return nil
+	})
 }
 
 // GetByUserID retrieves commands for a specific user
@@ -155,6 +153,63 @@ func (r *FileCommandRepository) GetHomepageCommands(ctx context.Context) ([]*ent
 	return commands, nil
 }
 
+// Search retrieves a page of commands matching the filter, ordered by ID
+func (r *FileCommandRepository) Search(ctx context.Context, filter repository.CommandFilter) ([]*entity.Command, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	query := strings.ToLower(filter.Query)
+	var matched []*entity.Command
+	for _, cmd := range r.commands {
+		if filter.Category != "" && cmd.Category != filter.Category {
+			continue
+		}
+		if filter.Platform != "" && !strings.EqualFold(cmd.Platform, filter.Platform) && cmd.Platforms[strings.ToLower(filter.Platform)] == "" {
+			continue
+		}
+		if filter.ShowOnHomepage != nil && cmd.ShowOnHomepage() != *filter.ShowOnHomepage {
+			continue
+		}
+		if filter.NoAdminOnly && cmd.RequiresAdmin() {
+			continue
+		}
+		if filter.Principal != nil && !cmd.VisibleTo(*filter.Principal) {
+			continue
+		}
+		if query != "" &&
+			!strings.Contains(strings.ToLower(cmd.Name), query) &&
+			!strings.Contains(strings.ToLower(cmd.Description), query) &&
+			!strings.Contains(strings.ToLower(cmd.Command), query) {
+			continue
+		}
+		matched = append(matched, cmd)
+	}
+	
+	// Map iteration order is random, so sort for stable pages
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID < matched[j].ID
+	})
+	
+	total := len(matched)
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if filter.Offset >= total {
+		return []*entity.Command{}, total, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	
+	// Only copy the page being returned
+	commands := make([]*entity.Command, len(matched))
+	for i, cmd := range matched {
+		commands[i] = r.copyCommand(cmd)
+	}
+	return commands, total, nil
+}
+
 // Exists checks if a command with the given ID exists
 func (r *FileCommandRepository) Exists(ctx context.Context, id string) (bool, error) {
 	r.mu.RLock()
@@ -164,18 +219,139 @@ func (r *FileCommandRepository) Exists(ctx context.Context, id string) (bool, er
 	return exists, nil
 }
 
+// Export returns the version and a copy of every command
+func (r *FileCommandRepository) Export(ctx context.Context) (*repository.CommandConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	config := &repository.CommandConfig{
+		Version:  r.version,
+		Commands: make([]*entity.Command, 0, len(r.commands)),
+	}
+	for _, cmd := range r.commands {
+		config.Commands = append(config.Commands, r.copyCommand(cmd))
+	}
+	return config, nil
+}
+
+// ReplaceAll replaces every command at once; on failure the previous commands are kept
+func (r *FileCommandRepository) ReplaceAll(ctx context.Context, commands []*entity.Command) error {
+	replacement := make(map[string]*entity.Command, len(commands))
+	for _, cmd := range commands {
+		replacement[cmd.ID] = cmd
+	}
+	
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	lock, err := r.lockFile()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	
+	previous := r.commands
+	r.commands = replacement
+	if err := r.saveToFile(); err != nil {
+		r.commands = previous
+		return err
+	}
+	return nil
+}
+
 // Reload reloads the command configuration from storage
 func (r *FileCommandRepository) Reload(ctx context.Context) error {
 	return r.loadFromFile()
 }
 
-// Initialize loads commands from file on startup
+// SetLoadRetry sets the retry policy used by Initialize
+func (r *FileCommandRepository) SetLoadRetry(retry LoadRetry) {
+	r.loadRetry = retry
+}
+
+// Initialize loads commands from file on startup, retrying with exponential
+// backoff until the file loads or the attempts run out
 func (r *FileCommandRepository) Initialize() error {
-	return r.loadFromFile()
+	attempts := r.loadRetry.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := r.loadRetry.BaseDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	maxDelay := r.loadRetry.MaxDelay
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+	
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = r.loadFromFile(); err == nil || attempt >= attempts {
+			break
+		}
+		
+		if r.loadRetry.OnRetry != nil {
+			r.loadRetry.OnRetry(attempt, delay, err)
+		}
+		time.Sleep(delay)
+		
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+	if err != nil && attempts > 1 {
+		return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+// modify applies fn to the latest commands on disk and saves the result. The
+// file lock is held throughout so writers in other processes are not overwritten
+func (r *FileCommandRepository) modify(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	lock, err := r.lockFile()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	
+	// Pick up changes saved by other processes; a missing file is created on save
+	if err := r.readFile(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	
+	if err := fn(); err != nil {
+		return err
+	}
+	return r.saveToFile()
+}
+
+// lockFile takes the cross-process lock guarding the configuration file
+func (r *FileCommandRepository) lockFile() (*fileLock, error) {
+	return acquireFileLock(r.configPath+".lock", lockTimeout)
 }
 
 // loadFromFile loads commands from the configuration file
 func (r *FileCommandRepository) loadFromFile() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	lock, err := r.lockFile()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	
+	return r.readFile()
+}
+
+// readFile replaces the in-memory commands with the configuration file contents.
+// Callers hold r.mu and the file lock
+func (r *FileCommandRepository) readFile() error {
 	// Resolve absolute path
 	configPath := r.configPath
 	if !filepath.IsAbs(configPath) {
@@ -189,29 +365,7 @@ func (r *FileCommandRepository) loadFromFile() error {
 	}
 	
 	// Parse command configuration
-	var config struct {
-		Version  string `json:"version"`
-		Commands []struct {
-			ID             string                 `json:"id"`
-			Name           string                 `json:"name,omitempty"`
-			Description    string                 `json:"description,omitempty"`
-			Category       string                 `json:"category,omitempty"`
-			Icon           string                 `json:"icon,omitempty"`
-			Command        string                 `json:"command"`
-			Platform       string                 `json:"platform"`
-			CommandType    string                 `json:"commandType,omitempty"`
-			Security       *entity.SecurityConfig `json:"security,omitempty"`
-			Timeout        int                    `json:"timeout,omitempty"`
-			UserID         string                 `json:"userId,omitempty"`
-			DeviceID       string                 `json:"deviceId,omitempty"`
-			HomeLayout     *entity.HomeLayoutConfig `json:"homeLayout,omitempty"`
-			TemplateId     string                 `json:"templateId,omitempty"`
-			TemplateParams map[string]interface{} `json:"templateParams,omitempty"`
-			CreatedAt      string                 `json:"createdAt,omitempty"`
-			UpdatedAt      string                 `json:"updatedAt,omitempty"`
-		} `json:"commands"`
-	}
-	
+	var config repository.CommandConfig
 	if err := json.Unmarshal(data, &config); err != nil {
 		return fmt.Errorf("failed to parse commands config: %w", err)
 	}
@@ -220,109 +374,24 @@ func (r *FileCommandRepository) loadFromFile() error {
 		return fmt.Errorf("missing version in commands config")
 	}
 	
-	// Convert to entity commands
-	r.mu.Lock()
-	r.commands = make(map[string]*entity.Command)
+	r.commands = make(map[string]*entity.Command, len(config.Commands))
 	r.version = config.Version
-	
-	for _, cmdData := range config.Commands {
-		cmd := &entity.Command{
-			ID:             cmdData.ID,
-			Name:           cmdData.Name,
-			Description:    cmdData.Description,
-			Category:       cmdData.Category,
-			Icon:           cmdData.Icon,
-			Command:        cmdData.Command,
-			Platform:       cmdData.Platform,
-			CommandType:    cmdData.CommandType,
-			Security:       cmdData.Security,
-			Timeout:        cmdData.Timeout,
-			UserID:         cmdData.UserID,
-			DeviceID:       cmdData.DeviceID,
-			HomeLayout:     cmdData.HomeLayout,
-			TemplateId:     cmdData.TemplateId,
-			TemplateParams: cmdData.TemplateParams,
-		}
-		
-		// Parse timestamps
-		if cmdData.CreatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, cmdData.CreatedAt); err == nil {
-				cmd.CreatedAt = t
-			} else {
-				cmd.CreatedAt = time.Now()
-			}
-		} else {
-			cmd.CreatedAt = time.Now()
-		}
-		
-		if cmdData.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, cmdData.UpdatedAt); err == nil {
-				cmd.UpdatedAt = t
-			} else {
-				cmd.UpdatedAt = time.Now()
-			}
-		} else {
-			cmd.UpdatedAt = time.Now()
-		}
-		
+	for _, cmd := range config.Commands {
 		r.commands[cmd.ID] = cmd
 	}
-	r.mu.Unlock()
 	
 	return nil
 }
 
-// saveToFile saves current commands to the configuration file
+// saveToFile saves current commands to the configuration file. The file is
+// written to a temporary path first so it is replaced atomically
 func (r *FileCommandRepository) saveToFile() error {
-	// Convert entities to JSON structure
-	commands := make([]map[string]interface{}, 0, len(r.commands))
-	
-	for _, cmd := range r.commands {
-		cmdData := map[string]interface{}{
-			"id":          cmd.ID,
-			"name":        cmd.Name,
-			"description": cmd.Description,
-			"category":    cmd.Category,
-			"icon":        cmd.Icon,
-			"command":     cmd.Command,
-			"platform":    cmd.Platform,
-			"createdAt":   cmd.CreatedAt.Format(time.RFC3339),
-			"updatedAt":   cmd.UpdatedAt.Format(time.RFC3339),
-		}
-		
-		// Add optional fields
-		if cmd.CommandType != "" {
-			cmdData["commandType"] = cmd.CommandType
-		}
-		if cmd.Timeout > 0 {
-			cmdData["timeout"] = cmd.Timeout
-		}
-		if cmd.UserID != "" {
-			cmdData["userId"] = cmd.UserID
-		}
-		if cmd.DeviceID != "" {
-			cmdData["deviceId"] = cmd.DeviceID
-		}
-		if cmd.TemplateId != "" {
-			cmdData["templateId"] = cmd.TemplateId
-		}
-		if cmd.TemplateParams != nil {
-			cmdData["templateParams"] = cmd.TemplateParams
-		}
-		if cmd.Security != nil {
-			cmdData["security"] = cmd.Security
-		}
-		if cmd.HomeLayout != nil {
-			cmdData["homeLayout"] = cmd.HomeLayout
-		}
-		
-		commands = append(commands, cmdData)
+	config := repository.CommandConfig{
+		Version:  r.version,
+		Commands: make([]*entity.Command, 0, len(r.commands)),
 	}
-	
-	// Create config structure
-	config := map[string]interface{}{
-		"version":  r.version,
-		"commands": commands,
+	for _, cmd := range r.commands {
+		config.Commands = append(config.Commands, cmd)
 	}
 	
 	// Marshal to JSON
@@ -338,7 +407,12 @@ func (r *FileCommandRepository) saveToFile() error {
 	}
 	
 	// Write to file
-	if err := ioutil.WriteFile(configPath, data, 0644); err != nil {
+	tmpPath := configPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write commands file: %w", err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write commands file: %w", err)
 	}
 	
@@ -357,9 +431,20 @@ func (r *FileCommandRepository) copyCommand(cmd *entity.Command) *entity.Command
 		Platform:       cmd.Platform,
 		CommandType:    cmd.CommandType,
 		Timeout:        cmd.Timeout,
+		MaxTimeout:     cmd.MaxTimeout,
 		UserID:         cmd.UserID,
 		DeviceID:       cmd.DeviceID,
 		TemplateId:     cmd.TemplateId,
+		WorkingDir:     cmd.WorkingDir,
+		PublishResults: cmd.PublishResults,
+		LoginShell:     cmd.LoginShell,
+		Shell:          cmd.Shell,
+		PreHook:        cmd.PreHook,
+		PostHook:       cmd.PostHook,
+		StrictHooks:    cmd.StrictHooks,
+		OutputFormat:   cmd.OutputFormat,
+		OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+		AutoRunDeps:    cmd.AutoRunDeps,
 		CreatedAt:      cmd.CreatedAt,
 		UpdatedAt:      cmd.UpdatedAt,
 	}
@@ -370,9 +455,31 @@ func (r *FileCommandRepository) copyCommand(cmd *entity.Command) *entity.Command
 			RequirePin: cmd.Security.RequirePin,
 			Whitelist:  cmd.Security.Whitelist,
 			AdminOnly:  cmd.Security.AdminOnly,
+
+			AllowedClientIDs: append([]string(nil), cmd.Security.AllowedClientIDs...),
+			AllowedIPs:       append([]string(nil), cmd.Security.AllowedIPs...),
 		}
 	}
 	
+	// Deep copy RateLimit
+	if cmd.RateLimit != nil {
+		rateLimit := *cmd.RateLimit
+		newCmd.RateLimit = &rateLimit
+	}
+	
+	// Deep copy AllowedHours
+	if cmd.AllowedHours != nil {
+		allowedHours := *cmd.AllowedHours
+		newCmd.AllowedHours = &allowedHours
+	}
+	
+	// Deep copy Retry
+	if cmd.Retry != nil {
+		retry := *cmd.Retry
+		retry.ExitCodes = append([]int(nil), cmd.Retry.ExitCodes...)
+		newCmd.Retry = &retry
+	}
+	
 	// Deep copy HomeLayout
 	if cmd.HomeLayout != nil {
 		newCmd.HomeLayout = &entity.HomeLayoutConfig{
@@ -390,6 +497,37 @@ func (r *FileCommandRepository) copyCommand(cmd *entity.Command) *entity.Command
 		}
 	}
 	
+	// Deep copy Args
+	if cmd.Args != nil {
+		newCmd.Args = append([]string(nil), cmd.Args...)
+	}
+	
+	// Deep copy Aliases
+	if cmd.Aliases != nil {
+		newCmd.Aliases = append([]string(nil), cmd.Aliases...)
+	}
+	
+	// Deep copy DependsOn
+	if cmd.DependsOn != nil {
+		newCmd.DependsOn = append([]string(nil), cmd.DependsOn...)
+	}
+	
+	// Deep copy Env
+	if cmd.Env != nil {
+		newCmd.Env = make(map[string]string, len(cmd.Env))
+		for k, v := range cmd.Env {
+			newCmd.Env[k] = v
+		}
+	}
+	
+	// Deep copy Platforms
+	if cmd.Platforms != nil {
+		newCmd.Platforms = make(map[string]string, len(cmd.Platforms))
+		for k, v := range cmd.Platforms {
+			newCmd.Platforms[k] = v
+		}
+	}
+	
 	// Deep copy TemplateParams
 	if cmd.TemplateParams != nil {
 		newCmd.TemplateParams = make(map[string]interface{})
@@ -398,5 +536,14 @@ func (r *FileCommandRepository) copyCommand(cmd *entity.Command) *entity.Command
 		}
 	}
 	
+	// Deep copy Params
+	if cmd.Params != nil {
+		newCmd.Params = make([]entity.ParamSpec, len(cmd.Params))
+		for i, spec := range cmd.Params {
+			spec.Values = append([]string(nil), spec.Values...)
+			newCmd.Params[i] = spec
+		}
+	}
+	
 	return newCmd
 }
\ No newline at end of file
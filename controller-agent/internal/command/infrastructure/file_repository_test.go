@@ -0,0 +1,203 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+)
+
+const testCommandsFile = `{"version": "1.0", "commands": [{"id": "uptime", "command": "uptime"}]}`
+
+func TestInitializeRetry(t *testing.T) {
+	const base, max = 10 * time.Millisecond, 25 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// availableAt is the attempt from which the file can be loaded, 0 for never
+		availableAt  int
+		missingDir   bool
+		invalidFirst bool
+		maxAttempts  int
+		wantErr      string
+		wantDelays   []time.Duration
+	}{
+		{name: "available at once", availableAt: 1, maxAttempts: 5},
+		{name: "file appears later", availableAt: 3, maxAttempts: 5, wantDelays: []time.Duration{base, 2 * base}},
+		{name: "mount appears later", availableAt: 3, missingDir: true, maxAttempts: 5, wantDelays: []time.Duration{base, 2 * base}},
+		{name: "file fixed later", availableAt: 2, invalidFirst: true, maxAttempts: 5, wantDelays: []time.Duration{base}},
+		{name: "appears on the last attempt", availableAt: 4, maxAttempts: 4, wantDelays: []time.Duration{base, 2 * base, max}},
+		{name: "never appears", maxAttempts: 4, wantErr: "giving up after 4 attempts", wantDelays: []time.Duration{base, 2 * base, max}},
+		{name: "appears too late", availableAt: 5, maxAttempts: 4, wantErr: "giving up after 4 attempts", wantDelays: []time.Duration{base, 2 * base, max}},
+		{name: "retries disabled", availableAt: 2, maxAttempts: 1, wantErr: "failed to read commands file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.missingDir {
+				dir = filepath.Join(dir, "mnt")
+			}
+			path := filepath.Join(dir, "commands.json")
+			makeAvailable := func() {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(testCommandsFile), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.invalidFirst {
+				if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.availableAt == 1 {
+				makeAvailable()
+			}
+
+			var delays []time.Duration
+			repo := NewFileCommandRepository(path).(*FileCommandRepository)
+			repo.SetLoadRetry(LoadRetry{
+				MaxAttempts: tt.maxAttempts,
+				BaseDelay:   base,
+				MaxDelay:    max,
+				OnRetry: func(attempt int, delay time.Duration, err error) {
+					if err == nil {
+						t.Errorf("retry %d without an error", attempt)
+					}
+					delays = append(delays, delay)
+					// The file is there by the time the next attempt runs
+					if attempt+1 == tt.availableAt {
+						makeAvailable()
+					}
+				},
+			})
+
+			start := time.Now()
+			err := repo.Initialize()
+			elapsed := time.Since(start)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Initialize() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(delays, tt.wantDelays) {
+				t.Errorf("retry delays = %v, want %v", delays, tt.wantDelays)
+			}
+			var budget time.Duration
+			for _, delay := range tt.wantDelays {
+				budget += delay
+			}
+			if elapsed < budget || elapsed > budget+time.Second {
+				t.Errorf("Initialize() took %v, want about %v", elapsed, budget)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := repo.GetByID(context.Background(), "uptime"); err != nil {
+				t.Errorf("GetByID(uptime) after Initialize: %v", err)
+			}
+		})
+	}
+}
+
+func TestInitializeRetryConcurrentAvailability(t *testing.T) {
+	// The file shows up on its own, like a mount finishing in the background
+	path := filepath.Join(t.TempDir(), "commands.json")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(60 * time.Millisecond)
+		os.WriteFile(path+".tmp", []byte(testCommandsFile), 0644)
+		os.Rename(path+".tmp", path)
+	}()
+	defer wg.Wait()
+
+	retries := 0
+	repo := NewFileCommandRepository(path).(*FileCommandRepository)
+	repo.SetLoadRetry(LoadRetry{
+		MaxAttempts: 20,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			retries++
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("retry %d: error = %v, want a missing file", attempt, err)
+			}
+		},
+	})
+	if err := repo.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	if retries == 0 {
+		t.Error("Initialize() did not retry")
+	}
+	if _, err := repo.GetByID(context.Background(), "uptime"); err != nil {
+		t.Errorf("GetByID(uptime) after Initialize: %v", err)
+	}
+}
+
+func TestSearchBoundsOffsetsAndCopies(t *testing.T) {
+	repo := NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, id := range []string{"a", "b", "c"} {
+		if err := repo.Create(context.Background(), &entity.Command{ID: id, Name: id, Command: "echo " + id}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter repository.CommandFilter
+		want   string
+	}{
+		{"first page", repository.CommandFilter{Limit: 2}, "a,b"},
+		{"second page", repository.CommandFilter{Offset: 2, Limit: 2}, "c"},
+		{"negative offset starts at the beginning", repository.CommandFilter{Offset: -4, Limit: 2}, "a,b"},
+		{"offset past the end", repository.CommandFilter{Offset: 3, Limit: 2}, ""},
+		{"huge offset", repository.CommandFilter{Offset: int(^uint(0) >> 1), Limit: 2}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, total, err := repo.Search(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if total != 3 {
+				t.Errorf("total = %d, want 3", total)
+			}
+			ids := make([]string, len(commands))
+			for i, cmd := range commands {
+				ids[i] = cmd.ID
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("page = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Results are copies, so changing them leaves the stored commands alone
+	commands, _, err := repo.Search(context.Background(), repository.CommandFilter{Query: "a"})
+	if err != nil || len(commands) != 1 {
+		t.Fatalf("Search = %v, %v", commands, err)
+	}
+	commands[0].Command = "rm -rf /"
+	stored, err := repo.GetByID(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.Command != "echo a" {
+		t.Errorf("stored command changed to %q through a search result", stored.Command)
+	}
+}
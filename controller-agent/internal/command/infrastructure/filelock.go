@@ -0,0 +1,53 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLockTimeout is returned when another process holds the commands file lock too long
+var ErrLockTimeout = errors.New("timed out waiting for commands file lock")
+
+const (
+	lockTimeout      = 5 * time.Second
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// fileLock is an advisory lock shared by every process using the same commands
+// file. It lives in a separate file because saves replace the commands file
+type fileLock struct {
+	file *os.File
+}
+
+// acquireFileLock takes an exclusive lock on path, waiting up to timeout
+func acquireFileLock(path string, timeout time.Duration) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return &fileLock{file: file}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%w: %s still held after %s", ErrLockTimeout, path, timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// release unlocks and closes the lock file
+func (l *fileLock) release() {
+	unlockFile(l.file)
+	l.file.Close()
+}
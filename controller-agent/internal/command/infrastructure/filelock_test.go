@@ -0,0 +1,173 @@
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+)
+
+// lockHelperEnv names the lock file TestLockHelperProcess holds
+const lockHelperEnv = "LAZYCTRL_TEST_LOCK_HELPER"
+
+// TestLockHelperProcess is not a test: it is the other process of the
+// cross-process tests, holding the lock for half a second
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv(lockHelperEnv)
+	if path == "" {
+		t.Skip("helper process only")
+	}
+	lock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	time.Sleep(500 * time.Millisecond)
+	lock.release()
+	os.Exit(0)
+}
+
+// holdLockInOtherProcess starts a process holding the lock on path and
+// returns once it has it
+func holdLockInOtherProcess(t *testing.T, path string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), lockHelperEnv+"="+path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	t.Cleanup(func() { cmd.Wait() })
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "locked\n" {
+		t.Fatalf("helper did not take the lock: %q, %v", line, err)
+	}
+	return cmd
+}
+
+func TestAcquireFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json.lock")
+
+	held, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := acquireFileLock(path, 100*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("second acquire = %v, want ErrLockTimeout", err)
+	}
+
+	// A waiter gets the lock once it is released
+	acquired := make(chan error, 1)
+	go func() {
+		lock, err := acquireFileLock(path, 5*time.Second)
+		if err == nil {
+			lock.release()
+		}
+		acquired <- err
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("waiter returned %v while the lock was held", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	held.release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("waiter: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter did not get the released lock")
+	}
+}
+
+func TestFileLockAcrossProcesses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "commands.json")
+
+	t.Run("times out", func(t *testing.T) {
+		holdLockInOtherProcess(t, path+".lock")
+		if _, err := acquireFileLock(path+".lock", 100*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+			t.Fatalf("acquire = %v, want ErrLockTimeout", err)
+		}
+	})
+
+	t.Run("writer waits", func(t *testing.T) {
+		holdLockInOtherProcess(t, path+".lock")
+		repo := NewFileCommandRepository(path)
+		start := time.Now()
+		if err := repo.Create(context.Background(), &entity.Command{ID: "uptime", Command: "uptime"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		// The helper holds the lock for 500ms after reporting it
+		if waited := time.Since(start); waited < 300*time.Millisecond {
+			t.Errorf("create finished after %s, before the other process released the lock", waited)
+		}
+	})
+}
+
+func TestFileRepositoryConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	// Each repository opens the lock file itself, like separate agents
+	writers := []repository.CommandRepository{NewFileCommandRepository(path), NewFileCommandRepository(path)}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, len(writers)*perWriter)
+	for w, repo := range writers {
+		wg.Add(1)
+		go func(w int, repo repository.CommandRepository) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				id := fmt.Sprintf("writer-%d-%02d", w, i)
+				errs <- repo.Create(context.Background(), &entity.Command{ID: id, Command: "echo " + id})
+			}
+		}(w, repo)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var saved repository.CommandConfig
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("commands file is corrupt: %v", err)
+	}
+	if len(saved.Commands) != len(writers)*perWriter {
+		t.Fatalf("file has %d commands, want %d: writes were lost", len(saved.Commands), len(writers)*perWriter)
+	}
+
+	reader := NewFileCommandRepository(path)
+	if err := reader.Reload(context.Background()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	for w := range writers {
+		for i := 0; i < perWriter; i++ {
+			id := fmt.Sprintf("writer-%d-%02d", w, i)
+			if exists, err := reader.Exists(context.Background(), id); err != nil || !exists {
+				t.Errorf("%s missing: %v", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+)
+
+// metaVersion is the command_meta key holding the configuration version
+const metaVersion = "version"
+
+// commandModel is a command row. The whole command is kept in Data in the
+// format of the commands file; the other columns are copies used in queries
+type commandModel struct {
+	ID             string `gorm:"primaryKey"`
+	Name           string
+	Description    string
+	Category       string `gorm:"index"`
+	Platform       string
+	UserID         string `gorm:"index"`
+	Command        string
+	ShowOnHomepage bool   `gorm:"index"`
+	Data           string `gorm:"type:text;not null"`
+}
+
+// TableName sets the table name of commandModel
+func (commandModel) TableName() string {
+	return "commands"
+}
+
+// commandMetaModel is a key/value setting of the command store
+type commandMetaModel struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
+
+// TableName sets the table name of commandMetaModel
+func (commandMetaModel) TableName() string {
+	return "command_meta"
+}
+
+// SQLiteCommandRepository implements CommandRepository using a SQLite database.
+// Every change is a single transaction, so concurrent edits from several
+// processes sharing the database cannot corrupt it
+type SQLiteCommandRepository struct {
+	db      *gorm.DB
+	version string
+}
+
+// NewSQLiteCommandRepository opens the database at path, creating it and its
+// directory when missing. Call Initialize before use
+func NewSQLiteCommandRepository(path string) (*SQLiteCommandRepository, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create commands database directory: %w", err)
+		}
+	}
+
+	// WAL lets readers continue while another process writes; writers wait for the lock instead of failing
+	db, err := gorm.Open(sqlite.Open(path+"?_journal_mode=WAL&_busy_timeout=5000"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open commands database: %w", err)
+	}
+
+	return &SQLiteCommandRepository{
+		db:      db,
+		version: "3.0",
+	}, nil
+}
+
+// Initialize creates the schema. On first run, while the database has no
+// version yet, the commands of the JSON file at jsonPath are imported; a
+// missing file starts an empty database. Returns the number of imported commands
+func (r *SQLiteCommandRepository) Initialize(jsonPath string) (int, error) {
+	if err := r.db.AutoMigrate(&commandModel{}, &commandMetaModel{}); err != nil {
+		return 0, fmt.Errorf("failed to migrate commands database: %w", err)
+	}
+
+	ctx := context.Background()
+	version, err := r.readVersion(r.db)
+	if err != nil {
+		return 0, err
+	}
+	if version != "" {
+		r.version = version
+		return 0, nil
+	}
+
+	config := &repository.CommandConfig{Version: r.version}
+	if _, err := os.Stat(jsonPath); err == nil {
+		source := NewFileCommandRepository(jsonPath)
+		if err := source.Reload(ctx); err != nil {
+			return 0, fmt.Errorf("failed to import commands file: %w", err)
+		}
+		if config, err = source.Export(ctx); err != nil {
+			return 0, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to import commands file: %w", err)
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := replaceCommands(tx, config.Commands); err != nil {
+			return err
+		}
+		return tx.Save(&commandMetaModel{Key: metaVersion, Value: config.Version}).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import commands file: %w", err)
+	}
+
+	r.version = config.Version
+	return len(config.Commands), nil
+}
+
+// Close closes the database
+func (r *SQLiteCommandRepository) Close() error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Create creates a new command
+func (r *SQLiteCommandRepository) Create(ctx context.Context, command *entity.Command) error {
+	model, err := toCommandModel(command)
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&commandModel{}).Where("id = ?", command.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("command with ID %s already exists", command.ID)
+		}
+		return tx.Create(model).Error
+	})
+}
+
+// GetByID retrieves a command by its ID
+func (r *SQLiteCommandRepository) GetByID(ctx context.Context, id string) (*entity.Command, error) {
+	commands, err := r.find(r.db.WithContext(ctx).Where("id = ?", id).Limit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("command not found: %s", id)
+	}
+	return commands[0], nil
+}
+
+// GetAll retrieves all commands
+func (r *SQLiteCommandRepository) GetAll(ctx context.Context) ([]*entity.Command, error) {
+	return r.find(r.db.WithContext(ctx))
+}
+
+// Update updates an existing command
+func (r *SQLiteCommandRepository) Update(ctx context.Context, command *entity.Command) error {
+	model, err := toCommandModel(command)
+	if err != nil {
+		return err
+	}
+
+	// Select("*") also writes zero values, such as a cleared description
+	result := r.db.WithContext(ctx).Model(&commandModel{}).Where("id = ?", command.ID).Select("*").Updates(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("command not found: %s", command.ID)
+	}
+	return nil
+}
+
+// Delete deletes a command by ID
+func (r *SQLiteCommandRepository) Delete(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&commandModel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("command not found: %s", id)
+	}
+	return nil
+}
+
+// GetByUserID retrieves commands for a specific user
+func (r *SQLiteCommandRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.Command, error) {
+	return r.find(r.db.WithContext(ctx).Where("user_id = ?", userID))
+}
+
+// GetByCategory retrieves commands by category
+func (r *SQLiteCommandRepository) GetByCategory(ctx context.Context, category string) ([]*entity.Command, error) {
+	return r.find(r.db.WithContext(ctx).Where("category = ?", category))
+}
+
+// GetHomepageCommands retrieves commands that should be displayed on homepage
+func (r *SQLiteCommandRepository) GetHomepageCommands(ctx context.Context) ([]*entity.Command, error) {
+	return r.find(r.db.WithContext(ctx).Where("show_on_homepage = ?", true))
+}
+
+// Search retrieves a page of commands matching the filter, ordered by ID
+func (r *SQLiteCommandRepository) Search(ctx context.Context, filter repository.CommandFilter) ([]*entity.Command, int, error) {
+	query := r.db.WithContext(ctx).Model(&commandModel{})
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Platform != "" {
+		// Platform variants are only kept in the command data
+		query = query.Where(`(platform = ? COLLATE NOCASE OR json_extract(data, '$.platforms.' || lower(?)) <> '')`,
+			filter.Platform, filter.Platform)
+	}
+	if filter.ShowOnHomepage != nil {
+		query = query.Where("show_on_homepage = ?", *filter.ShowOnHomepage)
+	}
+	if filter.NoAdminOnly {
+		query = query.Where("coalesce(json_extract(data, '$.security.AdminOnly'), 0) = 0")
+	}
+	if filter.Principal != nil {
+		// Rows predate a device column, so the device is read from the data
+		query = query.Where("user_id IN ('', ?)", filter.Principal.UserID).
+			Where("coalesce(json_extract(data, '$.deviceId'), '') IN ('', ?)", filter.Principal.DeviceID)
+	}
+	if filter.Query != "" {
+		// LIKE ignores ASCII case in SQLite
+		pattern := "%" + escapeLike(filter.Query) + "%"
+		query = query.Where(`name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\' OR command LIKE ? ESCAPE '\'`,
+			pattern, pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	page := query.Offset(filter.Offset)
+	if filter.Limit > 0 {
+		page = page.Limit(filter.Limit)
+	}
+	commands, err := r.find(page)
+	if err != nil {
+		return nil, 0, err
+	}
+	if commands == nil {
+		commands = []*entity.Command{}
+	}
+	return commands, int(total), nil
+}
+
+// Exists checks if a command with the given ID exists
+func (r *SQLiteCommandRepository) Exists(ctx context.Context, id string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&commandModel{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Export returns the version and every command
+func (r *SQLiteCommandRepository) Export(ctx context.Context) (*repository.CommandConfig, error) {
+	commands, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.CommandConfig{
+		Version:  r.version,
+		Commands: commands,
+	}, nil
+}
+
+// ReplaceAll replaces every command in one transaction; on failure the previous commands are kept
+func (r *SQLiteCommandRepository) ReplaceAll(ctx context.Context, commands []*entity.Command) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceCommands(tx, commands)
+	})
+}
+
+// Reload re-reads the configuration version. Commands are always read from
+// the database, so changes by other processes need no reload
+func (r *SQLiteCommandRepository) Reload(ctx context.Context) error {
+	version, err := r.readVersion(r.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		r.version = version
+	}
+	return nil
+}
+
+// find loads the commands selected by query, ordered by ID
+func (r *SQLiteCommandRepository) find(query *gorm.DB) ([]*entity.Command, error) {
+	var models []commandModel
+	if err := query.Order("id").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to query commands: %w", err)
+	}
+
+	var commands []*entity.Command
+	for _, model := range models {
+		cmd, err := repository.UnmarshalCommand([]byte(model.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse command %s: %w", model.ID, err)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// readVersion returns the stored configuration version, or "" before the first import
+func (r *SQLiteCommandRepository) readVersion(db *gorm.DB) (string, error) {
+	var meta []commandMetaModel
+	if err := db.Where(&commandMetaModel{Key: metaVersion}).Limit(1).Find(&meta).Error; err != nil {
+		return "", fmt.Errorf("failed to read commands database version: %w", err)
+	}
+	if len(meta) == 0 {
+		return "", nil
+	}
+	return meta[0].Value, nil
+}
+
+// replaceCommands deletes every command and inserts the given ones within tx
+func replaceCommands(tx *gorm.DB, commands []*entity.Command) error {
+	models := make([]*commandModel, 0, len(commands))
+	for _, cmd := range commands {
+		model, err := toCommandModel(cmd)
+		if err != nil {
+			return err
+		}
+		models = append(models, model)
+	}
+
+	if err := tx.Where("1 = 1").Delete(&commandModel{}).Error; err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(models, 100).Error
+}
+
+// toCommandModel converts a command to its row
+func toCommandModel(cmd *entity.Command) (*commandModel, error) {
+	data, err := repository.MarshalCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal command %s: %w", cmd.ID, err)
+	}
+
+	return &commandModel{
+		ID:             cmd.ID,
+		Name:           cmd.Name,
+		Description:    cmd.Description,
+		Category:       cmd.Category,
+		Platform:       cmd.Platform,
+		UserID:         cmd.UserID,
+		Command:        cmd.Command,
+		ShowOnHomepage: cmd.ShowOnHomepage(),
+		Data:           string(data),
+	}, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -31,9 +31,31 @@ type CommandRepository interface {
 	// GetHomepageCommands retrieves commands that should be displayed on homepage
 	GetHomepageCommands(ctx context.Context) ([]*entity.Command, error)
 	
+	// Search retrieves a page of commands matching the filter, ordered by ID,
+	// along with the total number of matches
+	Search(ctx context.Context, filter CommandFilter) ([]*entity.Command, int, error)
+	
 	// Exists checks if a command with the given ID exists
 	Exists(ctx context.Context, id string) (bool, error)
 	
+	// Export returns the configuration version and every command
+	Export(ctx context.Context) (*CommandConfig, error)
+	
+	// ReplaceAll atomically replaces every command with the given ones
+	ReplaceAll(ctx context.Context, commands []*entity.Command) error
+	
 	// Reload reloads the command configuration from storage
 	Reload(ctx context.Context) error
+}
+
+// CommandFilter selects commands in Search; zero values match everything
+type CommandFilter struct {
+	Query          string // case-insensitive substring of name, description or command
+	Category       string
+	Platform       string
+	ShowOnHomepage *bool
+	NoAdminOnly    bool              // leave out admin-only commands
+	Principal      *entity.Principal // when set, only commands visible to the principal
+	Offset         int
+	Limit          int // 0 returns every match
 }
\ No newline at end of file
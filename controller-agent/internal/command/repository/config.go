@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+// CommandConfig represents the JSON structure of command configuration file.
+// The same structure is used as the portable bundle for export and import
+type CommandConfig struct {
+	Version  string            `json:"version"`
+	Commands []*entity.Command `json:"commands"`
+}
+
+// commandRecord is a command as stored in the configuration file
+type commandRecord struct {
+	ID             string                     `json:"id"`
+	Aliases        []string                   `json:"aliases,omitempty"`
+	Name           string                     `json:"name,omitempty"`
+	Description    string                     `json:"description,omitempty"`
+	Category       string                     `json:"category,omitempty"`
+	Icon           string                     `json:"icon,omitempty"`
+	Command        string                     `json:"command"`
+	Platform       string                     `json:"platform"`
+	Platforms      map[string]string          `json:"platforms,omitempty"` // OS name to command string
+	CommandType    string                     `json:"commandType,omitempty"`
+	Security       *entity.SecurityConfig     `json:"security,omitempty"`
+	RateLimit      *entity.RateLimitConfig    `json:"rateLimit,omitempty"`
+	AllowedHours   *entity.AllowedHoursConfig `json:"allowedHours,omitempty"`
+	Retry          *entity.RetryConfig        `json:"retry,omitempty"`
+	Timeout        int                        `json:"timeout,omitempty"`
+	MaxTimeout     int                        `json:"maxTimeout,omitempty"`
+	UserID         string                     `json:"userId,omitempty"`
+	DeviceID       string                     `json:"deviceId,omitempty"`
+	HomeLayout     *entity.HomeLayoutConfig   `json:"homeLayout,omitempty"`
+	TemplateId     string                     `json:"templateId,omitempty"`
+	TemplateParams map[string]interface{}     `json:"templateParams,omitempty"`
+	Params         []entity.ParamSpec         `json:"params,omitempty"`
+	WorkingDir     string                     `json:"workingDir,omitempty"`
+	Env            map[string]string          `json:"env,omitempty"`
+	PublishResults bool                       `json:"publishResults,omitempty"`
+	LoginShell     bool                       `json:"loginShell,omitempty"`
+	Shell          string                     `json:"shell,omitempty"`
+	Args           []string                   `json:"args,omitempty"`
+	PreHook        string                     `json:"preHook,omitempty"`
+	PostHook       string                     `json:"postHook,omitempty"`
+	StrictHooks    bool                       `json:"strictHooks,omitempty"`
+	OutputFormat   string                     `json:"outputFormat,omitempty"`
+	OnFailureDiagnostic string                `json:"onFailureDiagnostic,omitempty"`
+	DependsOn      []string                   `json:"dependsOn,omitempty"`
+	AutoRunDeps    bool                       `json:"autoRunDeps,omitempty"`
+	CreatedAt      string                     `json:"createdAt,omitempty"`
+	UpdatedAt      string                     `json:"updatedAt,omitempty"`
+}
+
+// UnmarshalJSON parses a configuration file; missing or invalid timestamps are set to now
+func (c *CommandConfig) UnmarshalJSON(data []byte) error {
+	var config struct {
+		Version  string          `json:"version"`
+		Commands []commandRecord `json:"commands"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	c.Version = config.Version
+	c.Commands = make([]*entity.Command, 0, len(config.Commands))
+	for _, record := range config.Commands {
+		c.Commands = append(c.Commands, record.toEntity())
+	}
+
+	return nil
+}
+
+// toEntity converts a stored command; missing or invalid timestamps are set to now
+func (record commandRecord) toEntity() *entity.Command {
+	cmd := &entity.Command{
+		ID:             record.ID,
+		Aliases:        record.Aliases,
+		Name:           record.Name,
+		Description:    record.Description,
+		Category:       record.Category,
+		Icon:           record.Icon,
+		Command:        record.Command,
+		Platform:       record.Platform,
+		Platforms:      record.Platforms,
+		CommandType:    record.CommandType,
+		Security:       record.Security,
+		RateLimit:      record.RateLimit,
+		AllowedHours:   record.AllowedHours,
+		Retry:          record.Retry,
+		Timeout:        record.Timeout,
+		MaxTimeout:     record.MaxTimeout,
+		UserID:         record.UserID,
+		DeviceID:       record.DeviceID,
+		HomeLayout:     record.HomeLayout,
+		TemplateId:     record.TemplateId,
+		TemplateParams: record.TemplateParams,
+		Params:         record.Params,
+		WorkingDir:     record.WorkingDir,
+		Env:            record.Env,
+		PublishResults: record.PublishResults,
+		LoginShell:     record.LoginShell,
+		Shell:          record.Shell,
+		Args:           record.Args,
+		PreHook:        record.PreHook,
+		PostHook:       record.PostHook,
+		StrictHooks:    record.StrictHooks,
+		OutputFormat:   record.OutputFormat,
+		OnFailureDiagnostic: record.OnFailureDiagnostic,
+		DependsOn:      record.DependsOn,
+		AutoRunDeps:    record.AutoRunDeps,
+	}
+
+	// Parse timestamps
+	if record.CreatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, record.CreatedAt); err == nil {
+			cmd.CreatedAt = t
+		} else {
+			cmd.CreatedAt = time.Now()
+		}
+	} else {
+		cmd.CreatedAt = time.Now()
+	}
+
+	if record.UpdatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, record.UpdatedAt); err == nil {
+			cmd.UpdatedAt = t
+		} else {
+			cmd.UpdatedAt = time.Now()
+		}
+	} else {
+		cmd.UpdatedAt = time.Now()
+	}
+
+	return cmd
+}
+
+// MarshalJSON encodes the configuration file, ordering commands by ID
+func (c CommandConfig) MarshalJSON() ([]byte, error) {
+	sorted := make([]*entity.Command, len(c.Commands))
+	copy(sorted, c.Commands)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	// Convert entities to JSON structure
+	commands := make([]map[string]interface{}, 0, len(sorted))
+	for _, cmd := range sorted {
+		commands = append(commands, commandData(cmd))
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"version":  c.Version,
+		"commands": commands,
+	})
+}
+
+// commandData is the stored form of a command; empty optional fields are left out
+func commandData(cmd *entity.Command) map[string]interface{} {
+	cmdData := map[string]interface{}{
+		"id":          cmd.ID,
+		"name":        cmd.Name,
+		"description": cmd.Description,
+		"category":    cmd.Category,
+		"icon":        cmd.Icon,
+		"command":     cmd.Command,
+		"platform":    cmd.Platform,
+		"createdAt":   cmd.CreatedAt.Format(time.RFC3339),
+		"updatedAt":   cmd.UpdatedAt.Format(time.RFC3339),
+	}
+
+	// Add optional fields
+	if len(cmd.Platforms) > 0 {
+		cmdData["platforms"] = cmd.Platforms
+	}
+	if cmd.CommandType != "" {
+		cmdData["commandType"] = cmd.CommandType
+	}
+	if cmd.Timeout > 0 {
+		cmdData["timeout"] = cmd.Timeout
+	}
+	if cmd.MaxTimeout > 0 {
+		cmdData["maxTimeout"] = cmd.MaxTimeout
+	}
+	if cmd.UserID != "" {
+		cmdData["userId"] = cmd.UserID
+	}
+	if cmd.DeviceID != "" {
+		cmdData["deviceId"] = cmd.DeviceID
+	}
+	if cmd.TemplateId != "" {
+		cmdData["templateId"] = cmd.TemplateId
+	}
+	if cmd.TemplateParams != nil {
+		cmdData["templateParams"] = cmd.TemplateParams
+	}
+	if len(cmd.Params) > 0 {
+		cmdData["params"] = cmd.Params
+	}
+	if cmd.WorkingDir != "" {
+		cmdData["workingDir"] = cmd.WorkingDir
+	}
+	if len(cmd.Env) > 0 {
+		cmdData["env"] = cmd.Env
+	}
+	if cmd.PublishResults {
+		cmdData["publishResults"] = cmd.PublishResults
+	}
+	if cmd.LoginShell {
+		cmdData["loginShell"] = cmd.LoginShell
+	}
+	if cmd.Shell != "" {
+		cmdData["shell"] = cmd.Shell
+	}
+	if len(cmd.Args) > 0 {
+		cmdData["args"] = cmd.Args
+	}
+	if len(cmd.Aliases) > 0 {
+		cmdData["aliases"] = cmd.Aliases
+	}
+	if cmd.PreHook != "" {
+		cmdData["preHook"] = cmd.PreHook
+	}
+	if cmd.PostHook != "" {
+		cmdData["postHook"] = cmd.PostHook
+	}
+	if cmd.StrictHooks {
+		cmdData["strictHooks"] = cmd.StrictHooks
+	}
+	if cmd.OutputFormat != "" {
+		cmdData["outputFormat"] = cmd.OutputFormat
+	}
+	if cmd.OnFailureDiagnostic != "" {
+		cmdData["onFailureDiagnostic"] = cmd.OnFailureDiagnostic
+	}
+	if len(cmd.DependsOn) > 0 {
+		cmdData["dependsOn"] = cmd.DependsOn
+	}
+	if cmd.AutoRunDeps {
+		cmdData["autoRunDeps"] = cmd.AutoRunDeps
+	}
+	if cmd.Security != nil {
+		cmdData["security"] = cmd.Security
+	}
+	if cmd.RateLimit != nil {
+		cmdData["rateLimit"] = cmd.RateLimit
+	}
+	if cmd.AllowedHours != nil {
+		cmdData["allowedHours"] = cmd.AllowedHours
+	}
+	if cmd.Retry != nil {
+		cmdData["retry"] = cmd.Retry
+	}
+	if cmd.HomeLayout != nil {
+		cmdData["homeLayout"] = cmd.HomeLayout
+	}
+
+	return cmdData
+}
+
+// MarshalCommand encodes one command the way the configuration file stores it
+func MarshalCommand(cmd *entity.Command) ([]byte, error) {
+	return json.Marshal(commandData(cmd))
+}
+
+// UnmarshalCommand decodes a command encoded by MarshalCommand
+func UnmarshalCommand(data []byte) (*entity.Command, error) {
+	var record commandRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return record.toEntity(), nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// newAliasService returns a service holding "lights" with the aliases "lamp"
+// and "light", and "fan" without aliases
+func newAliasService(t *testing.T) *CommandService {
+	t.Helper()
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range []*entity.Command{
+		{ID: "lights", Name: "Lights", Command: "toggle lights", Platform: runtime.GOOS, Aliases: []string{"lamp", "light"}},
+		{ID: "fan", Name: "Fan", Command: "toggle fan", Platform: runtime.GOOS},
+	} {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	return NewCommandService(repo)
+}
+
+func TestCreateCommandAliasConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		aliases  []string
+		conflict bool
+	}{
+		{"new aliases", "heater", []string{"heat", "radiator"}, false},
+		{"no aliases", "heater", nil, false},
+		{"empty alias", "heater", []string{""}, true},
+		{"alias repeats the ID", "heater", []string{"heater"}, true},
+		{"repeated alias", "heater", []string{"heat", "heat"}, true},
+		{"alias is another ID", "heater", []string{"fan"}, true},
+		{"alias is another alias", "heater", []string{"lamp"}, true},
+		{"ID is another alias", "light", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAliasService(t)
+			_, err := s.CreateCommand(context.Background(), tt.id, tt.id, "echo "+tt.id, tt.aliases, entity.Principal{}, false, nil)
+			if errors.Is(err, common.ErrCommandAliasConflict) != tt.conflict {
+				t.Fatalf("CreateCommand() = %v, want conflict %v", err, tt.conflict)
+			}
+			if !tt.conflict && err != nil {
+				t.Fatalf("CreateCommand() = %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateCommandAliasConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		aliases  []string
+		conflict bool
+	}{
+		{"keep own aliases", "lights", []string{"lamp", "light", "bulb"}, false},
+		{"drop aliases", "lights", []string{}, false},
+		{"take another alias", "fan", []string{"lamp"}, true},
+		{"take another ID", "fan", []string{"lights"}, true},
+		{"alias repeats the ID", "fan", []string{"fan"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAliasService(t)
+			_, err := s.UpdateCommandWithFields(context.Background(), tt.id, map[string]interface{}{"aliases": tt.aliases}, false)
+			if errors.Is(err, common.ErrCommandAliasConflict) != tt.conflict {
+				t.Fatalf("UpdateCommandWithFields() = %v, want conflict %v", err, tt.conflict)
+			}
+			if tt.conflict {
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateCommandWithFields() = %v", err)
+			}
+			cmd, err := s.GetCommand(context.Background(), tt.id)
+			if err != nil || strings.Join(cmd.Aliases, ",") != strings.Join(tt.aliases, ",") {
+				t.Errorf("stored aliases = %v (%v), want %v", cmd.Aliases, err, tt.aliases)
+			}
+		})
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	s := newAliasService(t)
+
+	tests := []struct {
+		name    string
+		wantID  string
+		wantCmd string
+	}{
+		{"lights", "lights", "toggle lights"},
+		{"lamp", "lights", "toggle lights"},
+		{"light", "lights", "toggle lights"},
+		{"fan", "fan", "toggle fan"},
+		{"missing", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := s.GetCommand(context.Background(), tt.name)
+			if tt.wantID == "" {
+				if err == nil {
+					t.Fatalf("GetCommand(%s) = %s, want an error", tt.name, cmd.ID)
+				}
+				return
+			}
+			if err != nil || cmd.ID != tt.wantID {
+				t.Fatalf("GetCommand(%s) = %v, %v; want canonical ID %s", tt.name, cmd, err, tt.wantID)
+			}
+			command, err := s.GetPlatformCommand(context.Background(), tt.name)
+			if err != nil || command != tt.wantCmd {
+				t.Errorf("GetPlatformCommand(%s) = %q, %v; want %q", tt.name, command, err, tt.wantCmd)
+			}
+		})
+	}
+}
+
+func TestAliasConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []*entity.Command
+		want     []string
+	}{
+		{
+			name:     "distinct",
+			commands: []*entity.Command{{ID: "a", Aliases: []string{"x"}}, {ID: "b", Aliases: []string{"y"}}},
+		},
+		{
+			name:     "alias shared by two commands",
+			commands: []*entity.Command{{ID: "b", Aliases: []string{"x"}}, {ID: "a", Aliases: []string{"x"}}},
+			want:     []string{"command b: alias x is already used by command a"},
+		},
+		{
+			name:     "alias is another ID",
+			commands: []*entity.Command{{ID: "a", Aliases: []string{"b"}}, {ID: "b"}},
+			want:     []string{"command a: alias b is already used by command b"},
+		},
+		{
+			name:     "alias repeats its ID and empty alias",
+			commands: []*entity.Command{{ID: "a", Aliases: []string{"a", ""}}},
+			want:     []string{"command a: alias a repeats its ID or another alias", "command a: empty alias"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aliasConflicts(tt.commands); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("aliasConflicts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+)
+
+// Import modes
+const (
+	ImportModeMerge   = "merge"   // add new commands, leave or overwrite existing ones
+	ImportModeReplace = "replace" // swap the whole command set for the bundle
+)
+
+// ImportConflict is a bundle command whose ID is already in use in merge mode
+type ImportConflict struct {
+	ID      string
+	Updated bool // false when the existing command was kept
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	Created   int
+	Updated   int
+	Skipped   int
+	Removed   int // commands dropped by replace mode or deleted by a sync
+	Conflicts []ImportConflict
+}
+
+// ImportValidationError lists every problem found in a bundle; nothing is imported
+type ImportValidationError struct {
+	Problems []string
+}
+
+func (e *ImportValidationError) Error() string {
+	return fmt.Sprintf("invalid command bundle: %s", strings.Join(e.Problems, "; "))
+}
+
+// ExportCommands returns every command as a portable bundle
+func (s *CommandService) ExportCommands(ctx context.Context) (*repository.CommandConfig, error) {
+	config, err := s.repo.Export(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export commands: %w", err)
+	}
+
+	return config, nil
+}
+
+// ImportCommands imports a bundle. In merge mode new commands are added and
+// commands whose ID already exists are reported as conflicts, then kept or,
+// with overwrite, replaced. In replace mode the bundle becomes the whole
+// command set. Either way the stored commands change in a single swap.
+// Dangerous commands are rejected like on create unless allowDangerous is set
+func (s *CommandService) ImportCommands(ctx context.Context, bundle *repository.CommandConfig, mode string, overwrite, allowDangerous bool) (*ImportResult, error) {
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return nil, &ImportValidationError{Problems: []string{fmt.Sprintf("unknown import mode %q", mode)}}
+	}
+	if err := validateBundle(bundle); err != nil {
+		return nil, err
+	}
+	previous, err := s.storedExecutables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if problems := s.executableProblems(bundle.Commands, previous, allowDangerous); len(problems) > 0 {
+		return nil, &ImportValidationError{Problems: problems}
+	}
+
+	return s.applyImport(ctx, bundle.Commands, mode, overwrite, nil)
+}
+
+// applyImport stores validated commands as ImportCommands describes. In merge
+// mode the commands in remove are also deleted unless the import brings them
+func (s *CommandService) applyImport(ctx context.Context, imported []*entity.Command, mode string, overwrite bool, remove []string) (*ImportResult, error) {
+	existing, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all commands: %w", err)
+	}
+	current := make(map[string]*entity.Command, len(existing))
+	for _, cmd := range existing {
+		current[cmd.ID] = cmd
+	}
+
+	result := &ImportResult{}
+	var commands []*entity.Command
+
+	if mode == ImportModeReplace {
+		for _, cmd := range imported {
+			if _, exists := current[cmd.ID]; exists {
+				result.Updated++
+				delete(current, cmd.ID)
+			} else {
+				result.Created++
+			}
+		}
+		result.Removed = len(current)
+		commands = imported
+	} else {
+		for _, cmd := range imported {
+			previous, exists := current[cmd.ID]
+			if !exists {
+				current[cmd.ID] = cmd
+				result.Created++
+				continue
+			}
+
+			result.Conflicts = append(result.Conflicts, ImportConflict{ID: cmd.ID, Updated: overwrite})
+			if !overwrite {
+				result.Skipped++
+				continue
+			}
+
+			cmd.CreatedAt = previous.CreatedAt
+			cmd.UpdatedAt = time.Now()
+			current[cmd.ID] = cmd
+			result.Updated++
+		}
+
+		kept := make(map[string]bool, len(imported))
+		for _, cmd := range imported {
+			kept[cmd.ID] = true
+		}
+		for _, id := range remove {
+			if _, exists := current[id]; exists && !kept[id] {
+				delete(current, id)
+				result.Removed++
+			}
+		}
+
+		commands = make([]*entity.Command, 0, len(current))
+		for _, cmd := range current {
+			commands = append(commands, cmd)
+		}
+	}
+
+	// Aliases can only be checked against the command set the import produces
+	if problems := aliasConflicts(commands); len(problems) > 0 {
+		return nil, &ImportValidationError{Problems: problems}
+	}
+	// So can dependency cycles, which may run through commands kept from before
+	if cycle := entity.DependencyCycle(commands); cycle != nil {
+		return nil, &ImportValidationError{Problems: []string{
+			fmt.Sprintf("circular dependency: %s", strings.Join(cycle, " -> ")),
+		}}
+	}
+
+	if err := s.repo.ReplaceAll(ctx, commands); err != nil {
+		return nil, fmt.Errorf("failed to import commands: %w", err)
+	}
+
+	return result, nil
+}
+
+// storedExecutables returns the executable strings of every stored command by ID
+func (s *CommandService) storedExecutables(ctx context.Context) (map[string][]string, error) {
+	commands, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all commands: %w", err)
+	}
+	previous := make(map[string][]string, len(commands))
+	for _, cmd := range commands {
+		previous[cmd.ID] = executableStrings(cmd)
+	}
+	return previous, nil
+}
+
+// executableProblems validates the executable strings of imported or synced
+// commands like CreateCommand does. Strings the stored command with the same
+// ID already runs, listed in previous, are not checked again
+func (s *CommandService) executableProblems(commands []*entity.Command, previous map[string][]string, allowDangerous bool) []string {
+	var problems []string
+	for _, cmd := range commands {
+		if err := s.checkExecutables(cmd, previous[cmd.ID], allowDangerous); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+		}
+	}
+	return problems
+}
+
+// validateBundle checks every command in a bundle and reports all problems at once
+func validateBundle(bundle *repository.CommandConfig) error {
+	var problems []string
+	if bundle.Version == "" {
+		problems = append(problems, "missing version")
+	}
+	problems = append(problems, commandProblems(bundle.Commands)...)
+
+	if len(problems) > 0 {
+		return &ImportValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// commandProblems checks a set of imported commands and returns every problem found
+func commandProblems(commands []*entity.Command) []string {
+	var problems []string
+	seen := make(map[string]bool, len(commands))
+
+	for i, cmd := range commands {
+		if cmd.ID == "" {
+			problems = append(problems, fmt.Sprintf("command %d: ID is required", i))
+			continue
+		}
+		if seen[cmd.ID] {
+			problems = append(problems, fmt.Sprintf("command %s: duplicate ID", cmd.ID))
+		}
+		seen[cmd.ID] = true
+
+		if cmd.Timeout < 0 {
+			problems = append(problems, fmt.Sprintf("command %s: timeout must not be negative", cmd.ID))
+		}
+		if cmd.RateLimit != nil && (cmd.RateLimit.MaxExecutions < 0 || cmd.RateLimit.Window < 0) {
+			problems = append(problems, fmt.Sprintf("command %s: rate limit must not be negative", cmd.ID))
+		}
+		if cmd.AllowedHours != nil {
+			if err := cmd.AllowedHours.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+			}
+		}
+		if cmd.Retry != nil {
+			if err := cmd.Retry.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+			}
+		}
+		if err := cmd.ValidateShell(); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+		}
+		if err := cmd.ValidateParamSpecs(); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+		} else if _, err := cmd.ResolveParams(cmd.TemplateParams); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s: %s", cmd.ID, err))
+		}
+		if cmd.OnFailureDiagnostic == cmd.ID {
+			problems = append(problems, fmt.Sprintf("command %s: cannot be its own diagnostic", cmd.ID))
+		}
+		for _, dependency := range cmd.DependsOn {
+			if dependency == "" || dependency == cmd.ID {
+				problems = append(problems, fmt.Sprintf("command %s: invalid dependency %q", cmd.ID, dependency))
+			}
+		}
+		if cmd.Security != nil {
+			for _, allowed := range cmd.Security.AllowedIPs {
+				if _, _, err := net.ParseCIDR(allowed); err != nil && net.ParseIP(allowed) == nil {
+					problems = append(problems, fmt.Sprintf("command %s: invalid allowed IP %q", cmd.ID, allowed))
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// aliasConflicts reports every alias in a command set that is empty or names
+// more than one command, checking commands in ID order
+func aliasConflicts(commands []*entity.Command) []string {
+	sorted := append([]*entity.Command(nil), commands...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	owners := make(map[string]string, len(sorted)) // ID or alias to the command it names
+	for _, cmd := range sorted {
+		owners[cmd.ID] = cmd.ID
+	}
+
+	var problems []string
+	for _, cmd := range sorted {
+		for _, alias := range cmd.Aliases {
+			if alias == "" {
+				problems = append(problems, fmt.Sprintf("command %s: empty alias", cmd.ID))
+				continue
+			}
+			if owner, taken := owners[alias]; taken {
+				if owner == cmd.ID {
+					problems = append(problems, fmt.Sprintf("command %s: alias %s repeats its ID or another alias", cmd.ID, alias))
+				} else {
+					problems = append(problems, fmt.Sprintf("command %s: alias %s is already used by command %s", cmd.ID, alias, owner))
+				}
+				continue
+			}
+			owners[alias] = cmd.ID
+		}
+	}
+	return problems
+}
@@ -4,15 +4,34 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 )
 
 // CommandService provides business logic for command operations
 type CommandService struct {
 	repo repository.CommandRepository
+	
+	defaultCategory string
+	defaultPlatform string
+	
+	validator CommandValidator
+	
+	syncMutex sync.Mutex
+	syncHash  string // hash of the last command set synced from the cloud
+}
+
+// CommandValidator rejects command strings that must not be stored, such as
+// those matching the executor's dangerous-pattern blocklist
+type CommandValidator interface {
+	ValidateCommand(command string) error
 }
 
 // NewCommandService creates a new CommandService
@@ -22,8 +41,179 @@ func NewCommandService(repo repository.CommandRepository) *CommandService {
 	}
 }
 
-// CreateCommand creates a new command with validation
-func (s *CommandService) CreateCommand(ctx context.Context, id, name, command string) (*entity.Command, error) {
+// SetDefaults sets the category and platform given to created commands;
+// an empty platform keeps the agent's OS
+func (s *CommandService) SetDefaults(category, platform string) {
+	s.defaultCategory = category
+	s.defaultPlatform = platform
+}
+
+// SetCommandValidator checks the command strings of created and updated commands
+func (s *CommandService) SetCommandValidator(validator CommandValidator) {
+	s.validator = validator
+}
+
+// checkCommand validates a new command string unless the caller accepted the
+// risk with allowDangerous; an empty string leaves the command unchanged
+func (s *CommandService) checkCommand(command string, allowDangerous bool) error {
+	if s.validator == nil || allowDangerous || command == "" {
+		return nil
+	}
+	return s.validator.ValidateCommand(command)
+}
+
+// executableStrings returns everything of cmd the executor runs: the command
+// string, the args of direct execution, the platform variants and the hooks
+func executableStrings(cmd *entity.Command) []string {
+	strs := []string{cmd.Command, strings.Join(cmd.Args, " "), cmd.PreHook, cmd.PostHook}
+	platforms := make([]string, 0, len(cmd.Platforms))
+	for _, command := range cmd.Platforms {
+		platforms = append(platforms, command)
+	}
+	sort.Strings(platforms)
+	return append(strs, platforms...)
+}
+
+// checkExecutables validates every executable string of cmd that is not
+// among previous, the strings the command had before a change, unless the
+// caller accepted the risk with allowDangerous
+func (s *CommandService) checkExecutables(cmd *entity.Command, previous []string, allowDangerous bool) error {
+	for _, command := range executableStrings(cmd) {
+		if slices.Contains(previous, command) {
+			continue
+		}
+		if err := s.checkCommand(command, allowDangerous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckPlatforms validates platform variants, including their command strings
+// unless the caller accepted the risk with allowDangerous
+func (s *CommandService) CheckPlatforms(platforms map[string]string, allowDangerous bool) error {
+	cmd := &entity.Command{Platforms: platforms}
+	if err := cmd.ValidatePlatforms(); err != nil {
+		return err
+	}
+	for _, command := range platforms {
+		if err := s.checkCommand(command, allowDangerous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAliases rejects aliases, and for new commands an ID, that would make a
+// name resolve to more than one command
+func (s *CommandService) checkAliases(ctx context.Context, id string, aliases []string) error {
+	seen := map[string]bool{id: true}
+	for _, alias := range aliases {
+		if alias == "" {
+			return fmt.Errorf("%w: alias must not be empty", common.ErrCommandAliasConflict)
+		}
+		if seen[alias] {
+			return fmt.Errorf("%w: alias %s repeats the command ID or another alias", common.ErrCommandAliasConflict, alias)
+		}
+		seen[alias] = true
+	}
+	
+	commands, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get all commands: %w", err)
+	}
+	for _, other := range commands {
+		if other.ID == id {
+			continue
+		}
+		if other.HasAlias(id) {
+			return fmt.Errorf("%w: %s is an alias of command %s", common.ErrCommandAliasConflict, id, other.ID)
+		}
+		for _, alias := range aliases {
+			if alias == other.ID || other.HasAlias(alias) {
+				return fmt.Errorf("%w: alias %s is already used by command %s", common.ErrCommandAliasConflict, alias, other.ID)
+			}
+		}
+	}
+	
+	return nil
+}
+
+// CheckDependencies rejects dependencies of command id that do not name an
+// existing command, by ID or alias, or that lead back to the command
+func (s *CommandService) CheckDependencies(ctx context.Context, id string, dependsOn []string) error {
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	
+	commands, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get all commands: %w", err)
+	}
+	
+	// Check the command set as it would be with the new dependencies
+	var found bool
+	graph := make([]*entity.Command, 0, len(commands)+1)
+	for _, cmd := range commands {
+		if cmd.ID == id {
+			found = true
+			updated := *cmd
+			updated.DependsOn = dependsOn
+			cmd = &updated
+		}
+		graph = append(graph, cmd)
+	}
+	if !found {
+		graph = append(graph, &entity.Command{ID: id, DependsOn: dependsOn})
+	}
+	
+	for _, dependency := range dependsOn {
+		if dependency == "" {
+			return fmt.Errorf("%w: dependency must not be empty", common.ErrCommandDependency)
+		}
+		if !hasCommand(graph, dependency) {
+			return fmt.Errorf("%w: command %s does not exist", common.ErrCommandDependency, dependency)
+		}
+	}
+	if cycle := entity.DependencyCycle(graph); cycle != nil {
+		return fmt.Errorf("%w: circular dependency %s", common.ErrCommandDependency, strings.Join(cycle, " -> "))
+	}
+	
+	return nil
+}
+
+// hasCommand reports whether name is the ID or an alias of one of commands
+func hasCommand(commands []*entity.Command, name string) bool {
+	for _, cmd := range commands {
+		if cmd.ID == name || cmd.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findByAlias returns the command that has name as an alias, or nil. Should a
+// hand-edited file give several commands the alias, the lowest ID wins
+func (s *CommandService) findByAlias(ctx context.Context, name string) *entity.Command {
+	commands, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil
+	}
+	
+	var found *entity.Command
+	for _, cmd := range commands {
+		if cmd.HasAlias(name) && (found == nil || cmd.ID < found.ID) {
+			found = cmd
+		}
+	}
+	return found
+}
+
+// CreateCommand creates a new command with validation. The configured default
+// category and platform are applied, then configure, if given, sets the
+// caller's other fields before the command is saved. Neither the ID nor the
+// aliases may name another command. The command belongs to owner's user and device
+func (s *CommandService) CreateCommand(ctx context.Context, id, name, command string, aliases []string, owner entity.Principal, allowDangerous bool, configure func(*entity.Command)) (*entity.Command, error) {
 	if id == "" {
 		return nil, fmt.Errorf("command ID is required")
 	}
@@ -40,9 +230,27 @@ func (s *CommandService) CreateCommand(ctx context.Context, id, name, command st
 	if exists {
 		return nil, fmt.Errorf("command with ID %s already exists", id)
 	}
+	if err := s.checkAliases(ctx, id, aliases); err != nil {
+		return nil, err
+	}
 	
 	// Create new command entity
 	cmd := entity.NewCommand(id, name, command)
+	cmd.Aliases = aliases
+	cmd.UserID = owner.UserID
+	cmd.DeviceID = owner.DeviceID
+	cmd.Category = s.defaultCategory
+	if s.defaultPlatform != "" {
+		cmd.Platform = s.defaultPlatform
+	}
+	// The repository may reload its storage before the next write, so
+	// everything must be set before the command is saved
+	if configure != nil {
+		configure(cmd)
+	}
+	if err := s.checkExecutables(cmd, nil, allowDangerous); err != nil {
+		return nil, err
+	}
 	
 	// Save to repository
 	if err := s.repo.Create(ctx, cmd); err != nil {
@@ -52,7 +260,8 @@ func (s *CommandService) CreateCommand(ctx context.Context, id, name, command st
 	return cmd, nil
 }
 
-// GetCommand retrieves a command by ID
+// GetCommand retrieves a command by ID or alias; the returned command always
+// carries its canonical ID
 func (s *CommandService) GetCommand(ctx context.Context, id string) (*entity.Command, error) {
 	if id == "" {
 		return nil, fmt.Errorf("command ID is required")
@@ -60,6 +269,9 @@ func (s *CommandService) GetCommand(ctx context.Context, id string) (*entity.Com
 	
 	cmd, err := s.repo.GetByID(ctx, id)
 	if err != nil {
+		if aliased := s.findByAlias(ctx, id); aliased != nil {
+			return aliased, nil
+		}
 		return nil, fmt.Errorf("failed to get command: %w", err)
 	}
 	
@@ -77,10 +289,13 @@ func (s *CommandService) GetAllCommands(ctx context.Context) ([]*entity.Command,
 }
 
 // UpdateCommand updates an existing command
-func (s *CommandService) UpdateCommand(ctx context.Context, id, name, description, command string) (*entity.Command, error) {
+func (s *CommandService) UpdateCommand(ctx context.Context, id, name, description, command string, allowDangerous bool) (*entity.Command, error) {
 	if id == "" {
 		return nil, fmt.Errorf("command ID is required")
 	}
+	if err := s.checkCommand(command, allowDangerous); err != nil {
+		return nil, err
+	}
 	
 	// Get existing command
 	cmd, err := s.repo.GetByID(ctx, id)
@@ -100,19 +315,40 @@ func (s *CommandService) UpdateCommand(ctx context.Context, id, name, descriptio
 }
 
 // UpdateCommandWithFields updates a command with multiple fields
-func (s *CommandService) UpdateCommandWithFields(ctx context.Context, id string, updates map[string]interface{}) (*entity.Command, error) {
+func (s *CommandService) UpdateCommandWithFields(ctx context.Context, id string, updates map[string]interface{}, allowDangerous bool) (*entity.Command, error) {
 	if id == "" {
 		return nil, fmt.Errorf("command ID is required")
 	}
+	platforms, _ := updates["platforms"].(map[string]string)
+	if err := s.CheckPlatforms(platforms, allowDangerous); err != nil {
+		return nil, err
+	}
 	
 	// Get existing command
 	cmd, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get command: %w", err)
 	}
+	if aliases, ok := updates["aliases"].([]string); ok {
+		if err := s.checkAliases(ctx, id, aliases); err != nil {
+			return nil, err
+		}
+	}
+	if dependsOn, ok := updates["dependsOn"].([]string); ok {
+		if err := s.CheckDependencies(ctx, id, dependsOn); err != nil {
+			return nil, err
+		}
+	}
 	
-	// Update command fields
+	// Update command fields; what the command already ran is not checked again
+	previous := executableStrings(cmd)
 	cmd.UpdateFields(updates)
+	if err := s.checkExecutables(cmd, previous, allowDangerous); err != nil {
+		return nil, err
+	}
+	if err := checkParams(cmd); err != nil {
+		return nil, err
+	}
 	
 	// Handle security updates separately
 	if securityData, ok := updates["security"]; ok {
@@ -121,6 +357,9 @@ func (s *CommandService) UpdateCommandWithFields(ctx context.Context, id string,
 			whitelist, _ := securityMap["whitelist"].(bool)
 			adminOnly, _ := securityMap["adminOnly"].(bool)
 			cmd.SetSecurity(requirePin, whitelist, adminOnly)
+			allowedClientIDs, _ := securityMap["allowedClientIds"].([]string)
+			allowedIPs, _ := securityMap["allowedIps"].([]string)
+			cmd.SetAllowedClients(allowedClientIDs, allowedIPs)
 		}
 	}
 	
@@ -154,6 +393,18 @@ func (s *CommandService) UpdateCommandWithFields(ctx context.Context, id string,
 	return cmd, nil
 }
 
+// checkParams validates the command's parameter specs and its stored
+// template params against them
+func checkParams(cmd *entity.Command) error {
+	if err := cmd.ValidateParamSpecs(); err != nil {
+		return fmt.Errorf("%w: %s", common.ErrCommandInvalidParams, err)
+	}
+	if _, err := cmd.ResolveParams(cmd.TemplateParams); err != nil {
+		return err
+	}
+	return nil
+}
+
 // DeleteCommand deletes a command by ID
 func (s *CommandService) DeleteCommand(ctx context.Context, id string) error {
 	if id == "" {
@@ -185,11 +436,12 @@ func (s *CommandService) GetPlatformCommand(ctx context.Context, id string) (str
 	}
 	
 	// Check if command is available on current platform
-	if !cmd.IsAvailableOnPlatform() {
+	command, ok := cmd.PlatformCommand()
+	if !ok {
 		return "", fmt.Errorf("command %s not available on platform %s", id, runtime.GOOS)
 	}
 	
-	return cmd.Command, nil
+	return command, nil
 }
 
 // ValidateCommand validates if a command can be executed
@@ -204,9 +456,9 @@ func (s *CommandService) ValidateCommand(ctx context.Context, id string, allowed
 		return fmt.Errorf("command not whitelisted: %s", id)
 	}
 	
-	// Check global whitelist
+	// Check global whitelist by canonical ID, so aliases cannot bypass it
 	if enableWhitelist {
-		if !s.isCommandAllowed(id, allowedCommands) {
+		if !s.isCommandAllowed(cmd.ID, allowedCommands) {
 			return fmt.Errorf("command not allowed: %s", id)
 		}
 	}
@@ -229,6 +481,16 @@ func (s *CommandService) GetHomepageCommands(ctx context.Context) ([]*entity.Com
 	return commands, nil
 }
 
+// SearchCommands retrieves a page of commands matching the filter and the total match count
+func (s *CommandService) SearchCommands(ctx context.Context, filter repository.CommandFilter) ([]*entity.Command, int, error) {
+	commands, total, err := s.repo.Search(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search commands: %w", err)
+	}
+	
+	return commands, total, nil
+}
+
 // GetCommandsByCategory retrieves commands by category
 func (s *CommandService) GetCommandsByCategory(ctx context.Context, category string) ([]*entity.Command, error) {
 	commands, err := s.repo.GetByCategory(ctx, category)
@@ -239,6 +501,35 @@ func (s *CommandService) GetCommandsByCategory(ctx context.Context, category str
 	return commands, nil
 }
 
+// CategoryCount is a command category and how many commands are in it
+type CategoryCount struct {
+	Category string // empty for uncategorized commands
+	Count    int
+}
+
+// GetCategories returns every category in use with its command count, sorted by name
+func (s *CommandService) GetCategories(ctx context.Context) ([]CategoryCount, error) {
+	commands, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commands: %w", err)
+	}
+	
+	counts := make(map[string]int)
+	for _, cmd := range commands {
+		counts[cmd.Category]++
+	}
+	
+	categories := make([]CategoryCount, 0, len(counts))
+	for category, count := range counts {
+		categories = append(categories, CategoryCount{Category: category, Count: count})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Category < categories[j].Category
+	})
+	
+	return categories, nil
+}
+
 // GetCommandsByUser retrieves commands for a specific user
 func (s *CommandService) GetCommandsByUser(ctx context.Context, userID string) ([]*entity.Command, error) {
 	commands, err := s.repo.GetByUserID(ctx, userID)
@@ -274,6 +565,9 @@ func (s *CommandService) GetCommandInfo(ctx context.Context, id string) (map[str
 		"command":     cmd.Command,
 		"platform":    cmd.Platform,
 		"timeout":     cmd.GetTimeout(),
+		"workingDir":  cmd.WorkingDir,
+		"env":         cmd.Env,
+		"params":      cmd.Params,
 		"requiresPin": cmd.RequiresPin(),
 		"whitelisted": cmd.IsWhitelisted(),
 		"available":   cmd.IsAvailableOnPlatform(),
@@ -281,6 +575,11 @@ func (s *CommandService) GetCommandInfo(ctx context.Context, id string) (map[str
 		"updatedAt":   cmd.UpdatedAt.Format(time.RFC3339),
 	}
 	
+	// Built-in variables every execution sets in the command's environment,
+	// and how the command string or args reference them and parameters
+	info["contextVariables"] = entity.ContextVariables
+	info["paramTemplate"] = entity.ParamTemplate
+	
 	// Add homepage layout information
 	if cmd.ShowOnHomepage() {
 		x, y, width, height := cmd.GetHomepagePosition()
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+)
+
+func TestCreateCommandDefaults(t *testing.T) {
+	tests := []struct {
+		name            string
+		defaultCategory string
+		defaultPlatform string
+		wantCategory    string
+		wantPlatform    string
+	}{
+		{"no defaults", "", "", "", runtime.GOOS},
+		{"default category", "general", "", "general", runtime.GOOS},
+		{"default platform", "", "linux", "", "linux"},
+		{"both defaults", "tools", "darwin", "tools", "darwin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+			s := NewCommandService(repo)
+			s.SetDefaults(tt.defaultCategory, tt.defaultPlatform)
+
+			cmd, err := s.CreateCommand(context.Background(), "uptime", "Uptime", "uptime", nil, entity.Principal{}, false, nil)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if cmd.Category != tt.wantCategory || cmd.Platform != tt.wantPlatform {
+				t.Errorf("created category %q, platform %q, want %q, %q", cmd.Category, cmd.Platform, tt.wantCategory, tt.wantPlatform)
+			}
+
+			stored, err := repo.GetByID(context.Background(), "uptime")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if stored.Category != tt.wantCategory || stored.Platform != tt.wantPlatform {
+				t.Errorf("stored category %q, platform %q, want %q, %q", stored.Category, stored.Platform, tt.wantCategory, tt.wantPlatform)
+			}
+		})
+	}
+}
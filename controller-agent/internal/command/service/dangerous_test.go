@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// newValidatedService returns a service checking commands against the
+// executor's default blocklist, holding the command "safe"
+func newValidatedService(t *testing.T) *CommandService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := NewCommandService(infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json")))
+	s.SetCommandValidator(executor.NewService(logger))
+	if _, err := s.CreateCommand(context.Background(), "safe", "Safe", "echo safe", nil, entity.Principal{}, false, nil); err != nil {
+		t.Fatalf("create safe: %v", err)
+	}
+	return s
+}
+
+func TestDangerousCommands(t *testing.T) {
+	const dangerous = "rm -rf / --no-preserve-root"
+
+	tests := []struct {
+		name        string
+		change      func(s *CommandService, allowDangerous bool) error
+		wantBlocked bool
+	}{
+		{
+			name: "create safe",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.CreateCommand(context.Background(), "new", "New", "ls /", nil, entity.Principal{}, allow, nil)
+				return err
+			},
+		},
+		{
+			name: "create dangerous",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.CreateCommand(context.Background(), "new", "New", dangerous, nil, entity.Principal{}, allow, nil)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "update to dangerous",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommand(context.Background(), "safe", "", "", dangerous, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "update without a command",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommand(context.Background(), "safe", "Renamed", "", "", allow)
+				return err
+			},
+		},
+		{
+			name: "fields update to dangerous",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommandWithFields(context.Background(), "safe", map[string]interface{}{"command": dangerous}, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "fields update to dangerous args",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommandWithFields(context.Background(), "safe", map[string]interface{}{"shell": common.ShellNone, "args": []string{"rm", "-rf", "/"}}, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "create with dangerous args",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.CreateCommand(context.Background(), "new", "New", "echo", nil, entity.Principal{}, allow, func(cmd *entity.Command) {
+					cmd.Shell = common.ShellNone
+					cmd.Args = []string{"rm", "-rf", "/"}
+				})
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "create with dangerous hook",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.CreateCommand(context.Background(), "new", "New", "ls /", nil, entity.Principal{}, allow, func(cmd *entity.Command) {
+					cmd.PostHook = dangerous
+				})
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "fields update to dangerous hook",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommandWithFields(context.Background(), "safe", map[string]interface{}{"preHook": dangerous}, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "import dangerous",
+			change: func(s *CommandService, allow bool) error {
+				bundle := &repository.CommandConfig{Version: "3.0", Commands: []*entity.Command{{ID: "new", Name: "New", Command: "ls /", PreHook: dangerous}}}
+				_, err := s.ImportCommands(context.Background(), bundle, ImportModeMerge, false, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+		{
+			name: "fields update to dangerous platform variant",
+			change: func(s *CommandService, allow bool) error {
+				_, err := s.UpdateCommandWithFields(context.Background(), "safe", map[string]interface{}{"platforms": map[string]string{"windows": "format c: /q"}}, allow)
+				return err
+			},
+			wantBlocked: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, allow := range []bool{false, true} {
+			name := tt.name
+			if allow {
+				name += ", allowed"
+			}
+			t.Run(name, func(t *testing.T) {
+				err := tt.change(newValidatedService(t), allow)
+				wantBlocked := tt.wantBlocked && !allow
+				// Imports report the error as a problem of the bundle
+				if wantBlocked != (errors.Is(err, common.ErrCommandDangerous) || strings.Contains(fmt.Sprint(err), common.ErrCommandDangerous.Error())) {
+					t.Fatalf("err = %v, want blocked %v", err, wantBlocked)
+				}
+				if !wantBlocked && err != nil {
+					t.Fatalf("err = %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestSyncCommandsDangerous(t *testing.T) {
+	tests := []struct {
+		name        string
+		synced      SyncedCommand
+		wantBlocked bool
+	}{
+		{"safe", SyncedCommand{ID: "new", Name: "New", Command: "ls /"}, false},
+		{"dangerous new command", SyncedCommand{ID: "new", Name: "New", Command: "rm -rf /"}, true},
+		{"safe command made dangerous", SyncedCommand{ID: "safe", Name: "Safe", Command: "rm -rf /"}, true},
+		{"approved command kept", SyncedCommand{ID: "approved", Name: "Renamed", Command: "rm -rf /tmp/cache"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newValidatedService(t)
+			if _, err := s.CreateCommand(context.Background(), "approved", "Approved", "rm -rf /tmp/cache", nil, entity.Principal{}, true, nil); err != nil {
+				t.Fatalf("create approved: %v", err)
+			}
+
+			_, _, err := s.SyncCommands(context.Background(), []SyncedCommand{tt.synced}, nil, "")
+			var validationErr *ImportValidationError
+			if tt.wantBlocked != errors.As(err, &validationErr) {
+				t.Fatalf("err = %v, want blocked %v", err, tt.wantBlocked)
+			}
+			if !tt.wantBlocked && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			cmd, err := s.GetCommand(context.Background(), tt.synced.ID)
+			if stored := err == nil && cmd.Command == tt.synced.Command; stored == tt.wantBlocked {
+				t.Errorf("stored command = %v, %v; want blocked %v", cmd, err, tt.wantBlocked)
+			}
+		})
+	}
+}
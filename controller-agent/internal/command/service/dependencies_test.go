@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range []*entity.Command{
+		{ID: "mount", Name: "Mount", Command: "mount /backup", Platform: runtime.GOOS, Aliases: []string{"mnt"}},
+		{ID: "backup", Name: "Backup", Command: "rsync", Platform: runtime.GOOS, DependsOn: []string{"mount"}},
+		{ID: "verify", Name: "Verify", Command: "check", Platform: runtime.GOOS, DependsOn: []string{"backup"}},
+	} {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	s := NewCommandService(repo)
+
+	tests := []struct {
+		name      string
+		id        string
+		dependsOn []string
+		wantErr   bool
+	}{
+		{"none", "new", nil, false},
+		{"existing command", "new", []string{"verify"}, false},
+		{"by alias", "new", []string{"mnt"}, false},
+		{"unknown command", "new", []string{"missing"}, true},
+		{"empty name", "new", []string{""}, true},
+		{"itself", "new", []string{"new"}, true},
+		{"direct cycle", "mount", []string{"backup"}, true},
+		{"indirect cycle", "mount", []string{"verify"}, true},
+		{"cycle through an alias", "backup", []string{"verify", "mnt"}, true},
+		{"replacing the dependencies", "backup", []string{"mnt"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.CheckDependencies(context.Background(), tt.id, tt.dependsOn)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("CheckDependencies(%s, %v) = %v, wantErr %v", tt.id, tt.dependsOn, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, common.ErrCommandDependency) {
+				t.Errorf("error = %v, want %v", err, common.ErrCommandDependency)
+			}
+		})
+	}
+}
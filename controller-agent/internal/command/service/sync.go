@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+// SyncedCommand is a command as managed in the cloud. A sync only sets these
+// fields; the rest of an existing command, such as its aliases, rate limit or
+// hooks, is kept
+type SyncedCommand struct {
+	ID          string
+	Name        string
+	Description string
+	Category    string
+	Icon        string
+	Command     string
+	Platform    string
+	CommandType string
+	Timeout     int // milliseconds
+	WorkingDir  string
+	Env         map[string]string
+	RequirePin  bool
+	Whitelist   bool
+	AdminOnly   bool
+}
+
+// SyncCommands applies the command set the cloud manages for this device:
+// every synced command is created or updated and the commands in removed are
+// deleted, in a single swap like an import. Commands the cloud does not know
+// are left alone. New dangerous command strings are rejected as on create,
+// failing the whole sync. A non-empty hash equal to that of the last applied sync
+// skips the sync, reported by skipped
+func (s *CommandService) SyncCommands(ctx context.Context, synced []SyncedCommand, removed []string, hash string) (result *ImportResult, skipped bool, err error) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	if hash != "" && hash == s.syncHash {
+		return nil, true, nil
+	}
+
+	existing, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get all commands: %w", err)
+	}
+	current := make(map[string]*entity.Command, len(existing))
+	previous := make(map[string][]string, len(existing))
+	for _, cmd := range existing {
+		current[cmd.ID] = cmd
+		previous[cmd.ID] = executableStrings(cmd)
+	}
+
+	commands := make([]*entity.Command, 0, len(synced))
+	for _, sc := range synced {
+		cmd, exists := current[sc.ID]
+		if !exists {
+			cmd = entity.NewCommand(sc.ID, sc.Name, sc.Command)
+		}
+		applySyncedFields(cmd, sc)
+		commands = append(commands, cmd)
+	}
+
+	problems := commandProblems(commands)
+	// The cloud cannot accept dangerous commands on the device's behalf
+	problems = append(problems, s.executableProblems(commands, previous, false)...)
+	if len(problems) > 0 {
+		return nil, false, &ImportValidationError{Problems: problems}
+	}
+
+	result, err = s.applyImport(ctx, commands, ImportModeMerge, true, removed)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.syncHash = hash
+	return result, false, nil
+}
+
+// applySyncedFields copies the cloud-managed fields onto a command
+func applySyncedFields(cmd *entity.Command, sc SyncedCommand) {
+	cmd.Name = sc.Name
+	cmd.Description = sc.Description
+	cmd.Category = sc.Category
+	cmd.Icon = sc.Icon
+	cmd.Command = sc.Command
+	if sc.Platform != "" {
+		cmd.Platform = sc.Platform
+	}
+	cmd.CommandType = sc.CommandType
+	cmd.Timeout = sc.Timeout
+	cmd.WorkingDir = sc.WorkingDir
+	cmd.Env = sc.Env
+
+	// Client restrictions are not managed in the cloud
+	if cmd.Security == nil {
+		cmd.Security = &entity.SecurityConfig{}
+	}
+	cmd.Security.RequirePin = sc.RequirePin
+	cmd.Security.Whitelist = sc.Whitelist
+	cmd.Security.AdminOnly = sc.AdminOnly
+
+	cmd.UpdatedAt = time.Now()
+}
@@ -0,0 +1,25 @@
+package common
+
+import "time"
+
+// Build information, injected at build time via ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/myczh-1/lazy-ctrl-agent/internal/common.GitCommit=$(git rev-parse --short HEAD)"
+var (
+	AppVersion = "2.0.0"
+	GitCommit  = "unknown"
+	BuildTime  = "unknown"
+)
+
+// startTime records when the agent process started
+var startTime = time.Now()
+
+// StartTime returns the time the agent process started
+func StartTime() time.Time {
+	return startTime
+}
+
+// Uptime returns how long the agent process has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	first := Uptime()
+	time.Sleep(10 * time.Millisecond)
+	second := Uptime()
+
+	if second-first < 10*time.Millisecond {
+		t.Errorf("uptime grew by %v between calls 10ms apart", second-first)
+	}
+	if got := time.Since(StartTime()); got < second {
+		t.Errorf("time since start %v is less than uptime %v", got, second)
+	}
+	if !StartTime().Equal(StartTime()) {
+		t.Error("start time changed between calls")
+	}
+}
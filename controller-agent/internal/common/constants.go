@@ -5,13 +5,13 @@ import "time"
 // Application constants
 const (
 	// Application information
-	AppName    = "lazy-ctrl-agent"
-	AppVersion = "2.0.0"
+	AppName = "lazy-ctrl-agent"
 	
 	// Default timeouts
 	DefaultCommandTimeout = 10 * time.Second
 	DefaultHTTPTimeout    = 30 * time.Second
 	DefaultShutdownTimeout = 30 * time.Second
+	DefaultKillGracePeriod = 5 * time.Second // SIGTERM to SIGKILL of a stopped command
 	
 	// Rate limiting
 	DefaultRateLimitPerMinute = 60
@@ -50,8 +50,45 @@ const (
 	CommandTypeScript    = "script"
 	CommandTypeSequence  = "sequence"
 	CommandTypeTemplate  = "template"
+	
+	// Command shells; an empty shell means the platform default (sh or cmd)
+	ShellSh         = "sh"
+	ShellBash       = "bash"
+	ShellCmd        = "cmd"
+	ShellPowerShell = "powershell"
+	ShellNone       = "none" // exec Args directly without any shell
+	
+	// Command output formats; an empty format means text
+	OutputFormatText  = "text"
+	OutputFormatJSON  = "json"  // stdout parsed as JSON
+	OutputFormatLines = "lines" // stdout split into lines
+	
+	// Command parameter types
+	ParamTypeString = "string"
+	ParamTypeInt    = "int"
+	ParamTypeBool   = "bool"
+	ParamTypeEnum   = "enum" // a string from the spec's allowed values
+	
+	// Command retries
+	MaxRetryAttempts = 10
+	MaxRetryDelay    = 10 * time.Minute // cap of the delay between two attempts
+	
+	// Power actions
+	PowerActionShutdown = "shutdown"
+	PowerActionReboot   = "reboot"
+	MaxPowerDelay       = 24 * time.Hour
 )
 
+// DefaultBlockedPatterns are the dangerous command fragments rejected unless
+// security.blocked_patterns replaces them, matched case-insensitively
+var DefaultBlockedPatterns = []string{
+	"rm -rf /",
+	"del /s /q C:\\",
+	"format c:",
+	"mkfs.",
+	"fdisk",
+}
+
 // HTTP Status messages
 const (
 	StatusHealthy   = "healthy"
@@ -79,6 +116,9 @@ const (
 	HeaderAuthorization   = "Authorization"
 	HeaderXPin            = "X-Pin"
 	HeaderXRequestID      = "X-Request-ID"
+	HeaderXTimeoutMs      = "X-Timeout-Ms"
+	HeaderXClientID       = "X-Client-ID"
+	HeaderXTenantToken    = "X-Tenant-Token"
 	HeaderXRealIP         = "X-Real-IP"
 	HeaderXForwardedFor   = "X-Forwarded-For"
 	HeaderUserAgent       = "User-Agent"
@@ -105,9 +145,11 @@ const (
 const (
 	MQTTTopicPrefix   = "lazy-ctrl"
 	MQTTTopicCommand  = "command"
+	MQTTTopicCommands = "commands"
 	MQTTTopicExecute  = "execute"
 	MQTTTopicStatus   = "status"
 	MQTTTopicResponse = "response"
+	MQTTTopicResults  = "results"
 )
 
 // Default positions for UI layout
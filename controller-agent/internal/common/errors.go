@@ -12,18 +12,36 @@ var (
 	ErrCommandAlreadyExists = errors.New("command already exists")
 	ErrCommandInvalidID     = errors.New("invalid command ID")
 	ErrCommandInvalidConfig = errors.New("invalid command configuration")
+	ErrCommandDangerous     = errors.New("potentially dangerous command detected")
+	ErrCommandAliasConflict = errors.New("command alias conflict")
+	ErrCommandInvalidParams = errors.New("invalid command parameters")
+	ErrCommandDependency    = errors.New("invalid command dependency")
 	
 	// Security errors
 	ErrInvalidPin         = errors.New("invalid PIN")
 	ErrPinRequired        = errors.New("PIN required")
+	ErrTooManyPinFailures = errors.New("too many wrong PINs")
 	ErrRateLimitExceeded  = errors.New("rate limit exceeded")
 	ErrUnauthorized       = errors.New("unauthorized")
 	ErrCommandNotAllowed  = errors.New("command not allowed")
+	ErrClientNotAllowed   = errors.New("client not allowed to execute command")
+	ErrOutsideAllowedHours = errors.New("command outside allowed hours")
+	ErrAdminRequired      = errors.New("command is admin-only and requires the admin PIN")
 	
 	// Execution errors
 	ErrExecutionFailed    = errors.New("command execution failed")
 	ErrExecutionTimeout   = errors.New("command execution timeout")
 	ErrPlatformNotSupported = errors.New("platform not supported")
+	ErrMaintenanceMode    = errors.New("agent is in maintenance mode")
+	ErrUpdateDisabled     = errors.New("agent self-update is disabled")
+	ErrUpdateInProgress   = errors.New("agent update already in progress")
+	
+	// File transfer errors
+	ErrFilesDisabled      = errors.New("file transfers are disabled")
+	ErrInvalidFilePath    = errors.New("path is not inside an allowed directory")
+	ErrFileNotFound       = errors.New("file not found")
+	ErrFileExists         = errors.New("file already exists")
+	ErrFileTooLarge       = errors.New("file exceeds the upload size limit")
 	
 	// Configuration errors
 	ErrConfigNotFound     = errors.New("configuration not found")
@@ -2,15 +2,27 @@ package config
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Security SecurityConfig `mapstructure:"security"`
-	Commands CommandsConfig `mapstructure:"commands"`
-	MQTT     MQTTConfig     `mapstructure:"mqtt"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Security    SecurityConfig    `mapstructure:"security"`
+	Commands    CommandsConfig    `mapstructure:"commands"`
+	MQTT        MQTTConfig        `mapstructure:"mqtt"`
+	Tunnel      TunnelConfig      `mapstructure:"tunnel"`
+	Enrollment  EnrollmentConfig  `mapstructure:"enrollment"`
+	Audit       AuditConfig       `mapstructure:"audit"`
+	Webhooks    WebhooksConfig    `mapstructure:"webhooks"`
+	Metrics     MetricsConfig     `mapstructure:"metrics"`
+	Maintenance MaintenanceConfig `mapstructure:"maintenance"`
+	Update      UpdateConfig      `mapstructure:"update"`
+	Files       FilesConfig       `mapstructure:"files"`
+	Log         LogConfig         `mapstructure:"log"`
 }
 
 type ServerConfig struct {
@@ -24,46 +36,224 @@ type HTTPConfig struct {
 	Port       int    `mapstructure:"port"`
 	StaticPath string `mapstructure:"static_path"`
 	StaticDir  string `mapstructure:"static_dir"`
+
+	AllowedOrigins  []string          `mapstructure:"allowed_origins"`  // browser origins allowed cross-origin access; "*" allows any
+	ShutdownTimeout int               `mapstructure:"shutdown_timeout"` // seconds in-flight requests get to finish on stop
+	Compression     CompressionConfig `mapstructure:"compression"`
+}
+
+// CompressionConfig controls gzip compression of HTTP responses
+type CompressionConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	MinSize       int      `mapstructure:"min_size"`       // bytes a response must reach before it is compressed
+	ExcludedPaths []string `mapstructure:"excluded_paths"` // path prefixes never compressed, such as streaming endpoints
 }
 
 type GRPCConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Host    string `mapstructure:"host"`
-	Port    int    `mapstructure:"port"`
+	Enabled    bool          `mapstructure:"enabled"`
+	Host       string        `mapstructure:"host"`
+	Port       int           `mapstructure:"port"`
+	Reflection bool          `mapstructure:"reflection"` // serve the reflection API for grpcurl; development only
+	TLS        GRPCTLSConfig `mapstructure:"tls"`
+}
+
+type GRPCTLSConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	CertFile          string `mapstructure:"cert_file"`
+	KeyFile           string `mapstructure:"key_file"`
+	ClientCAFile      string `mapstructure:"client_ca_file"`
+	RequireClientCert bool   `mapstructure:"require_client_cert"`
 }
 
 type SecurityConfig struct {
 	EnableWhitelist   bool     `mapstructure:"enable_whitelist"`
+	DefaultDeny       bool     `mapstructure:"default_deny"` // reject commands that are neither whitelisted nor in allowed_commands
 	PinRequired       bool     `mapstructure:"pin_required"`
-	Pin               string   `mapstructure:"pin"`
+	Pin               string   `mapstructure:"pin"`            // plaintext, deprecated in favour of pin_hash
+	PinHash           string   `mapstructure:"pin_hash"`       // bcrypt hash from "hash-pin", takes precedence over pin
+	AdminPinHash      string   `mapstructure:"admin_pin_hash"` // bcrypt hash of the admin PIN, empty denies admin operations
 	RateLimitEnabled  bool     `mapstructure:"rate_limit_enabled"`
 	RateLimitPerMin   int      `mapstructure:"rate_limit_per_min"`
 	AllowedCommands   []string `mapstructure:"allowed_commands"`
+	ResultSigningKey  string   `mapstructure:"result_signing_key"` // HMAC key for execution results, empty disables signing
+
+	// Command fragments rejected when commands are created or updated
+	BlockedPatterns      []string `mapstructure:"blocked_patterns"`       // replaces common.DefaultBlockedPatterns
+	ExtraBlockedPatterns []string `mapstructure:"extra_blocked_patterns"` // added to blocked_patterns
+
+	// Ad-hoc execution runs unsaved command strings for the admin, which is
+	// arbitrary code execution on the host
+	AllowAdhoc           bool `mapstructure:"allow_adhoc"`
+	AdhocRateLimitPerMin int  `mapstructure:"adhoc_rate_limit_per_min"` // across all clients, also when rate_limit_enabled is off
 }
 
 type CommandsConfig struct {
-	ConfigPath string `mapstructure:"config_path"`
+	Backend    string `mapstructure:"backend"`     // "file" or "sqlite"
+	SQLitePath string `mapstructure:"sqlite_path"` // database of the sqlite backend
+	ConfigPath string `mapstructure:"config_path"` // commands file; imported once when the sqlite database is created
 	HotReload  bool   `mapstructure:"hot_reload"`
+	MaxTimeout int    `mapstructure:"max_timeout"` // milliseconds, ceiling for every execution, see MaxExecutionTimeout
+	LoginShell bool   `mapstructure:"login_shell"` // run all commands through a login shell
+	MaxStdin   int    `mapstructure:"max_stdin"`   // bytes of stdin accepted per execution, 0 is unlimited
+	MaxOutput  int    `mapstructure:"max_output"`  // bytes of output kept per execution, 0 is unlimited
+	KillGrace  int    `mapstructure:"kill_grace"`  // seconds a stopped command has between SIGTERM and SIGKILL
+
+	DefaultCategory string `mapstructure:"default_category"` // category of created commands that don't set one
+	DefaultPlatform string `mapstructure:"default_platform"` // platform of created commands that don't set one, empty is the agent's OS
+	Timezone        string `mapstructure:"timezone"`         // IANA zone for allowed hours, empty is the system zone
+
+	MaxConcurrent          int `mapstructure:"max_concurrent"`            // concurrent executions in total, 0 is unlimited
+	MaxConcurrentPerTenant int `mapstructure:"max_concurrent_per_tenant"` // concurrent executions per device/user, 0 is unlimited
+	DependencyFreshness    int `mapstructure:"dependency_freshness"`      // seconds a dependency's last success stays valid, 0 accepts any
+
+	Tenancy bool          `mapstructure:"tenancy"` // scope commands to the tenant of the caller's X-Tenant-Token
+	Tenants []TenantToken `mapstructure:"tenants"` // tenant tokens; callers without one only see unowned commands
+
+	Batch BatchConfig `mapstructure:"batch"`
+
+	OutputSummary OutputSummaryConfig `mapstructure:"output_summary"`
+	LoadRetry     LoadRetryConfig     `mapstructure:"load_retry"`
+}
+
+// LoadRetryConfig retries loading the commands file at startup, for files on
+// mounts that may not be available yet
+type LoadRetryConfig struct {
+	MaxAttempts int `mapstructure:"max_attempts"` // load attempts before giving up, 1 disables retries
+	BaseDelay   int `mapstructure:"base_delay"`   // seconds before the first retry, doubled after each attempt
+	MaxDelay    int `mapstructure:"max_delay"`    // seconds, cap for the doubled delay
+}
+
+// TenantToken identifies the user and device a caller acts for in tenancy mode
+type TenantToken struct {
+	Token    string `mapstructure:"token"`
+	UserID   string `mapstructure:"user_id"`
+	DeviceID string `mapstructure:"device_id"`
+}
+
+// BatchConfig limits batch execution requests
+type BatchConfig struct {
+	MaxCommands int `mapstructure:"max_commands"` // commands accepted per batch
+}
+
+// OutputSummaryConfig trims long command output to its first and last lines
+type OutputSummaryConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	HeadLines int  `mapstructure:"head_lines"`
+	TailLines int  `mapstructure:"tail_lines"`
 }
 
 type MQTTConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Broker         string `mapstructure:"broker"`
+	Port           int    `mapstructure:"port"`
+	Username       string `mapstructure:"username"`
+	Password       string `mapstructure:"password"`
+	ClientID       string `mapstructure:"client_id"`
+	TopicBase      string `mapstructure:"topic_base"`
+	PublishResults bool   `mapstructure:"publish_results"` // publish every result, not only opted-in commands
+
+	StatusTopic        string `mapstructure:"status_topic"`         // presence topic, defaults to <topic_base>/status
+	HeartbeatInterval  int    `mapstructure:"heartbeat_interval"`   // seconds between presence heartbeats, 0 disables
+	PerRequestResponse bool   `mapstructure:"per_request_response"` // publish responses to <topic_base>/response/<requestId>
+}
+
+type TunnelConfig struct {
+	Enabled      bool            `mapstructure:"enabled"`
+	CloudAddress string          `mapstructure:"cloud_address"`
+	DeviceID     string          `mapstructure:"device_id"`
+	AccessToken  string          `mapstructure:"access_token"`
+	ReconnectMin int             `mapstructure:"reconnect_min"` // seconds
+	ReconnectMax int             `mapstructure:"reconnect_max"` // seconds
+	TLS          TunnelTLSConfig `mapstructure:"tls"`
+}
+
+// EnrollmentConfig controls registering the agent with the cloud, which is
+// reached with the tunnel's cloud_address, device_id and tls settings
+type EnrollmentConfig struct {
 	Enabled    bool   `mapstructure:"enabled"`
-	Broker     string `mapstructure:"broker"`
-	Port       int    `mapstructure:"port"`
-	Username   string `mapstructure:"username"`
-	Password   string `mapstructure:"password"`
-	ClientID   string `mapstructure:"client_id"`
-	TopicBase  string `mapstructure:"topic_base"`
+	Token      string `mapstructure:"token"`       // pre-shared enrollment token configured in the cloud
+	DeviceName string `mapstructure:"device_name"` // empty uses the device ID
+	Address    string `mapstructure:"address"`     // gRPC address the cloud dials, empty detects it
+	Interval   int    `mapstructure:"interval"`    // seconds between re-enrollments
+}
+
+type TunnelTLSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	CAFile     string `mapstructure:"ca_file"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// UpdateConfig controls replacing the agent binary with a release pushed by the cloud
+type UpdateConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	PublicKey string `mapstructure:"public_key"` // base64 Ed25519 key that release signatures are checked against
+	Timeout   int    `mapstructure:"timeout"`    // seconds allowed for the download
+	MaxSize   int64  `mapstructure:"max_size"`   // bytes
+}
+
+// FilesConfig controls transferring files to and from the agent host
+type FilesConfig struct {
+	AllowedDirs   []string `mapstructure:"allowed_dirs"`    // transfers are disabled while empty; relative paths resolve against the first
+	MaxUploadSize int64    `mapstructure:"max_upload_size"` // bytes
+}
+
+// AuditConfig controls the execution audit log and its retention
+type AuditConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	Path          string `mapstructure:"path"`
+	MaxEntries    int    `mapstructure:"max_entries"`    // 0 keeps every entry
+	MaxAgeDays    int    `mapstructure:"max_age_days"`   // 0 keeps entries forever
+	PruneInterval int    `mapstructure:"prune_interval"` // minutes between retention passes
+}
+
+// WebhooksConfig controls the notifications POSTed after every execution
+type WebhooksConfig struct {
+	Secret     string            `mapstructure:"secret"`      // HMAC-SHA256 key signing the body, empty sends unsigned
+	Timeout    int               `mapstructure:"timeout"`     // seconds per delivery attempt
+	MaxRetries int               `mapstructure:"max_retries"` // further attempts after a failed delivery
+	MaxOutput  int               `mapstructure:"max_output"`  // bytes of output included in the payload
+	Endpoints  []WebhookEndpoint `mapstructure:"endpoints"`
+}
+
+// WebhookEndpoint is a URL notified of executions; empty filters match every command
+type WebhookEndpoint struct {
+	URL        string   `mapstructure:"url"`
+	Commands   []string `mapstructure:"commands"`   // command IDs
+	Categories []string `mapstructure:"categories"`
+}
+
+// MetricsConfig controls the in-memory history of resource usage samples
+type MetricsConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	Interval  int  `mapstructure:"interval"`  // seconds between samples
+	Retention int  `mapstructure:"retention"` // minutes of samples kept
+}
+
+// MaintenanceConfig controls where the maintenance mode is saved
+type MaintenanceConfig struct {
+	StateFile string `mapstructure:"state_file"` // empty forgets the mode on restart
 }
 
 type LogConfig struct {
 	Level      string `mapstructure:"level"`
 	Format     string `mapstructure:"format"`
 	OutputPath string `mapstructure:"output_path"`
+	TailRate   int    `mapstructure:"tail_rate"` // max lines per second streamed by TailLogs
 }
 
 var globalConfig *Config
 
+// MaxExecutionTimeout returns the ceiling every execution is clamped to,
+// whatever the command or request asks for
+func (c CommandsConfig) MaxExecutionTimeout() time.Duration {
+	if c.MaxTimeout <= 0 {
+		return common.DefaultHTTPTimeout
+	}
+	return time.Duration(c.MaxTimeout) * time.Millisecond
+}
+
 func Load(configPath string) error {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -105,30 +295,97 @@ func setDefaults() {
 	viper.SetDefault("server.http.port", 7070)
 	viper.SetDefault("server.http.static_path", "")
 	viper.SetDefault("server.http.static_dir", "")
+	viper.SetDefault("server.http.allowed_origins", []string{})
+	viper.SetDefault("server.http.shutdown_timeout", 30)
+	viper.SetDefault("server.http.compression.enabled", true)
+	viper.SetDefault("server.http.compression.min_size", 1024)
+	viper.SetDefault("server.http.compression.excluded_paths", []string{"/api/v1/execute/ws"})
 	viper.SetDefault("server.grpc.enabled", true)
 	viper.SetDefault("server.grpc.host", "0.0.0.0")
 	viper.SetDefault("server.grpc.port", 7071)
+	viper.SetDefault("server.grpc.reflection", false)
+	viper.SetDefault("server.grpc.tls.enabled", false)
+	viper.SetDefault("server.grpc.tls.require_client_cert", true)
 
 	// Security defaults
 	viper.SetDefault("security.enable_whitelist", true)
+	viper.SetDefault("security.default_deny", false)
 	viper.SetDefault("security.pin_required", false)
 	viper.SetDefault("security.rate_limit_enabled", true)
 	viper.SetDefault("security.rate_limit_per_min", 60)
+	viper.SetDefault("security.blocked_patterns", common.DefaultBlockedPatterns)
+	viper.SetDefault("security.allow_adhoc", false)
+	viper.SetDefault("security.adhoc_rate_limit_per_min", 5)
 
 	// Commands defaults
+	viper.SetDefault("commands.backend", "file")
+	viper.SetDefault("commands.sqlite_path", "data/commands.db")
 	viper.SetDefault("commands.config_path", "configs/commands.json")
 	viper.SetDefault("commands.hot_reload", true)
+	viper.SetDefault("commands.max_timeout", 300000)
+	viper.SetDefault("commands.login_shell", false)
+	viper.SetDefault("commands.max_stdin", 1048576)
+	viper.SetDefault("commands.max_output", 1048576)
+	viper.SetDefault("commands.kill_grace", 5)
+	viper.SetDefault("commands.default_category", "general")
+	viper.SetDefault("commands.default_platform", "")
+	viper.SetDefault("commands.timezone", "")
+	viper.SetDefault("commands.max_concurrent", 8)
+	viper.SetDefault("commands.max_concurrent_per_tenant", 0)
+	viper.SetDefault("commands.dependency_freshness", 3600)
+	viper.SetDefault("commands.tenancy", false)
+	viper.SetDefault("commands.batch.max_commands", 20)
+	viper.SetDefault("commands.output_summary.enabled", false)
+	viper.SetDefault("commands.output_summary.head_lines", 100)
+	viper.SetDefault("commands.output_summary.tail_lines", 100)
+	viper.SetDefault("commands.load_retry.max_attempts", 5)
+	viper.SetDefault("commands.load_retry.base_delay", 1)
+	viper.SetDefault("commands.load_retry.max_delay", 10)
 
 	// MQTT defaults
 	viper.SetDefault("mqtt.enabled", false)
 	viper.SetDefault("mqtt.port", 1883)
 	viper.SetDefault("mqtt.client_id", "lazy-ctrl-agent")
 	viper.SetDefault("mqtt.topic_base", "lazy-ctrl")
+	viper.SetDefault("mqtt.publish_results", false)
+	viper.SetDefault("mqtt.heartbeat_interval", 30)
+	viper.SetDefault("mqtt.per_request_response", false)
+
+	// Tunnel defaults
+	viper.SetDefault("tunnel.enabled", false)
+	viper.SetDefault("tunnel.reconnect_min", 1)
+	viper.SetDefault("tunnel.reconnect_max", 60)
+	viper.SetDefault("tunnel.tls.enabled", false)
+
+	// Enrollment defaults
+	viper.SetDefault("enrollment.enabled", false)
+	viper.SetDefault("enrollment.interval", 300)
+
+	// Audit defaults
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.path", "data/audit.jsonl")
+	viper.SetDefault("audit.max_entries", 10000)
+	viper.SetDefault("audit.max_age_days", 30)
+	viper.SetDefault("audit.prune_interval", 60)
+	viper.SetDefault("webhooks.secret", "")
+	viper.SetDefault("webhooks.timeout", 10)
+	viper.SetDefault("webhooks.max_retries", 3)
+	viper.SetDefault("webhooks.max_output", 1024)
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("metrics.interval", 10)
+	viper.SetDefault("metrics.retention", 60)
+	viper.SetDefault("maintenance.state_file", "data/maintenance.json")
+	viper.SetDefault("update.enabled", false)
+	viper.SetDefault("update.timeout", 300)
+	viper.SetDefault("update.max_size", 200*1024*1024)
+	viper.SetDefault("files.allowed_dirs", []string{})
+	viper.SetDefault("files.max_upload_size", 100*1024*1024)
 
 	// Log defaults
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
 	viper.SetDefault("log.output_path", "")
+	viper.SetDefault("log.tail_rate", 50)
 }
 
 func Get() *Config {
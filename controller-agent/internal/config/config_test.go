@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// TestShippedConfigIsLockedDown checks the settings of configs/config.yaml
+// that must stay closed until an operator opts in
+func TestShippedConfigIsLockedDown(t *testing.T) {
+	if err := Load("../../configs/config.yaml"); err != nil {
+		t.Fatalf("load shipped config: %v", err)
+	}
+	cfg := Get()
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"no cross-origin access", len(cfg.Server.HTTP.AllowedOrigins) == 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.ok {
+				t.Errorf("shipped config does not have %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestExecutionLimitDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg := Get()
+
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"commands.max_concurrent", cfg.Commands.MaxConcurrent, 8},
+		{"commands.max_concurrent_per_tenant", cfg.Commands.MaxConcurrentPerTenant, 0},
+		{"commands.batch.max_commands", cfg.Commands.Batch.MaxCommands, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxExecutionTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxTimeout int
+		want       time.Duration
+	}{
+		{"configured", 120000, 2 * time.Minute},
+		{"unset falls back to the HTTP timeout", 0, common.DefaultHTTPTimeout},
+		{"negative falls back to the HTTP timeout", -1, common.DefaultHTTPTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := CommandsConfig{MaxTimeout: tt.maxTimeout}
+			if got := cfg.MaxExecutionTimeout(); got != tt.want {
+				t.Errorf("MaxExecutionTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatedCommandDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg := Get()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"commands.default_category", cfg.Commands.DefaultCategory, "general"},
+		{"commands.default_platform", cfg.Commands.DefaultPlatform, ""}, // the agent's OS
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// Interfaces through which a command can be executed
+const (
+	InterfaceHTTP = "http"
+	InterfaceGRPC = "grpc"
+	InterfaceMQTT = "mqtt"
+)
+
+// Entry is a single audited command execution
+type Entry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	RunID       string    `json:"runId"`
+	CommandID   string    `json:"commandId"`
+	Interface   string    `json:"interface"`
+	ClientIP    string    `json:"clientIp,omitempty"`
+	PinRequired bool      `json:"pinRequired"`
+	Success     bool      `json:"success"`
+	ExitCode    int       `json:"exitCode"`
+	Duration    int64     `json:"duration"` // milliseconds
+	Signature   string    `json:"signature,omitempty"`
+}
+
+// Filter selects audit entries; zero values match everything
+type Filter struct {
+	CommandID string
+	Since     time.Time
+	Until     time.Time
+	Success   *bool
+	Offset    int
+	Limit     int
+}
+
+// CommandStats summarizes the executions of a command
+type CommandStats struct {
+	Executions  int        `json:"executions"`
+	Successes   int        `json:"successes"`
+	Failures    int        `json:"failures"`
+	SuccessRate float64    `json:"successRate"` // 0 to 1, 0 without executions
+	AvgDuration int64      `json:"avgDuration"` // milliseconds
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastSuccess bool       `json:"lastSuccess"` // outcome of the last run
+}
+
+// Service is an append-only audit log stored as JSON lines
+type Service struct {
+	path       string
+	maxEntries int
+	maxAge     time.Duration
+	logger     *logrus.Logger
+	mutex      sync.Mutex
+}
+
+// NewService creates an audit log at path; maxEntries and maxAge of zero keep everything
+func NewService(path string, maxEntries int, maxAge time.Duration, logger *logrus.Logger) (*Service, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create audit directory: %w", err)
+		}
+	}
+
+	return &Service{
+		path:       path,
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		logger:     logger,
+	}, nil
+}
+
+// RecordExecution appends an execution to the audit log
+func (s *Service) RecordExecution(opts executor.ExecuteOptions, result *executor.ExecutionResult) {
+	s.Record(Entry{
+		Timestamp:   time.Now(),
+		RunID:       result.RunID,
+		CommandID:   opts.CommandID,
+		Interface:   opts.Source,
+		ClientIP:    opts.ClientIP,
+		PinRequired: opts.RequiresPin,
+		Success:     result.Success,
+		ExitCode:    result.ExitCode,
+		Duration:    result.ExecutionTime.Milliseconds(),
+		Signature:   result.Signature,
+	})
+}
+
+// Record appends an entry to the audit log
+func (s *Service) Record(entry Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to encode audit entry")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to open audit log")
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		s.logger.WithError(err).Error("Failed to write audit entry")
+	}
+}
+
+// Query returns matching entries newest first, along with the total number of matches
+func (s *Service) Query(filter Filter) ([]Entry, int, error) {
+	s.mutex.Lock()
+	entries, err := s.load()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matched := make([]Entry, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if filter.matches(entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+
+	total := len(matched)
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if filter.Offset >= total {
+		return []Entry{}, total, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+
+	return matched, total, nil
+}
+
+// LastSuccess returns when a command last succeeded, zero if it never did
+// within the retained entries
+func (s *Service) LastSuccess(commandID string) (time.Time, error) {
+	success := true
+	entries, _, err := s.Query(Filter{CommandID: commandID, Success: &success, Limit: 1})
+	if err != nil || len(entries) == 0 {
+		return time.Time{}, err
+	}
+	return entries[0].Timestamp, nil
+}
+
+// CommandHistory returns the executions of filter.CommandID within the
+// filter's time range newest first, limited by filter.Limit, along with stats
+// over every execution in the range
+func (s *Service) CommandHistory(filter Filter) ([]Entry, CommandStats, error) {
+	s.mutex.Lock()
+	entries, err := s.load()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, CommandStats{}, err
+	}
+
+	var stats CommandStats
+	var totalDuration int64
+	recent := make([]Entry, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !filter.matches(entry) {
+			continue
+		}
+
+		if stats.Executions == 0 {
+			lastRun := entry.Timestamp
+			stats.LastRun = &lastRun
+			stats.LastSuccess = entry.Success
+		}
+		stats.Executions++
+		if entry.Success {
+			stats.Successes++
+		} else {
+			stats.Failures++
+		}
+		totalDuration += entry.Duration
+
+		if filter.Limit <= 0 || len(recent) < filter.Limit {
+			recent = append(recent, entry)
+		}
+	}
+
+	if stats.Executions > 0 {
+		stats.SuccessRate = float64(stats.Successes) / float64(stats.Executions)
+		stats.AvgDuration = totalDuration / int64(stats.Executions)
+	}
+
+	return recent, stats, nil
+}
+
+// Prune drops entries older than the max age and beyond the max entry count
+func (s *Service) Prune() error {
+	if s.maxEntries <= 0 && s.maxAge <= 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	kept := entries
+	if s.maxAge > 0 {
+		cutoff := time.Now().Add(-s.maxAge)
+		// Entries are appended in order, so the first one inside the window starts the kept range
+		start := sort.Search(len(kept), func(i int) bool {
+			return kept[i].Timestamp.After(cutoff)
+		})
+		kept = kept[start:]
+	}
+	if s.maxEntries > 0 && len(kept) > s.maxEntries {
+		kept = kept[len(kept)-s.maxEntries:]
+	}
+
+	if len(kept) == len(entries) {
+		return nil
+	}
+
+	if err := s.rewrite(kept); err != nil {
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"removed":   len(entries) - len(kept),
+		"remaining": len(kept),
+	}).Info("Pruned audit log")
+
+	return nil
+}
+
+// load reads all entries; callers must hold the mutex
+func (s *Service) load() ([]Entry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip lines left half-written by a crash
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// rewrite replaces the log with entries via a temp file; callers must hold the mutex
+func (s *Service) rewrite(entries []Entry) error {
+	tmpPath := s.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace audit log: %w", err)
+	}
+	return nil
+}
+
+// matches reports whether entry passes the filter
+func (f Filter) matches(entry Entry) bool {
+	if f.CommandID != "" && entry.CommandID != f.CommandID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Success != nil && entry.Success != *f.Success {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestService returns an audit log in a temporary directory
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s, err := NewService(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0, logger)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+func TestQueryBoundsOffsets(t *testing.T) {
+	s := newTestService(t)
+	start := time.Now().Add(-time.Hour)
+	for i, id := range []string{"a", "b", "c"} {
+		s.Record(Entry{Timestamp: start.Add(time.Duration(i) * time.Minute), CommandID: id, Success: true})
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"newest first", Filter{Limit: 2}, []string{"c", "b"}},
+		{"second page", Filter{Offset: 2, Limit: 2}, []string{"a"}},
+		{"negative offset starts at the newest", Filter{Offset: -4, Limit: 1}, []string{"c"}},
+		{"offset past the end", Filter{Offset: 3}, nil},
+		{"huge offset", Filter{Offset: int(^uint(0) >> 1)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := s.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if total != 3 {
+				t.Errorf("total = %d, want 3", total)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("entries = %v, want %v", entries, tt.want)
+			}
+			for i, entry := range entries {
+				if entry.CommandID != tt.want[i] {
+					t.Errorf("entry %d = %s, want %s", i, entry.CommandID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLastSuccess(t *testing.T) {
+	s := newTestService(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.Record(Entry{Timestamp: start, CommandID: "backup", Success: true})
+	s.Record(Entry{Timestamp: start.Add(time.Minute), CommandID: "backup", Success: false})
+	s.Record(Entry{Timestamp: start.Add(2 * time.Minute), CommandID: "deploy", Success: true})
+
+	check := func(s *Service, commandID string, want time.Time) {
+		t.Helper()
+		got, err := s.LastSuccess(commandID)
+		if err != nil {
+			t.Fatalf("LastSuccess(%s): %v", commandID, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("LastSuccess(%s) = %v, want %v", commandID, got, want)
+		}
+	}
+
+	// A later failure keeps the earlier success
+	check(s, "backup", start)
+	check(s, "deploy", start.Add(2*time.Minute))
+	check(s, "never", time.Time{})
+
+	// The index is rebuilt from the log on startup
+	restarted, err := NewService(s.path, 0, 0, s.logger)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	check(restarted, "backup", start)
+
+	// Pruned successes are forgotten
+	restarted.maxEntries = 1
+	if err := restarted.Prune(); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	check(restarted, "backup", time.Time{})
+	check(restarted, "deploy", start.Add(2*time.Minute))
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// templatePattern matches the placeholders of context variables, such as
+// {{.DeviceID}}, and of parameters, {{.Params.name}}. Any other {{...}}, such
+// as a docker --format template, is left as it is
+var templatePattern = func() *regexp.Regexp {
+	names := make([]string, len(entity.ContextVariables))
+	for i, variable := range entity.ContextVariables {
+		names[i] = variable.Name
+	}
+	return regexp.MustCompile(`\{\{\s*\.(?:(` + strings.Join(names, "|") + `)|Params\.([A-Za-z_][A-Za-z0-9_]*))\s*\}\}`)
+}()
+
+// SetDeviceID sets the device ID exposed to commands as entity.ContextEnvDeviceID
+func (s *Service) SetDeviceID(deviceID string) {
+	s.deviceID = deviceID
+}
+
+// contextEnv returns the context variables of an execution started now
+func (s *Service) contextEnv(opts ExecuteOptions) map[string]string {
+	return map[string]string{
+		entity.ContextEnvDeviceID: s.deviceID,
+		entity.ContextEnvUserID:   opts.UserID,
+		entity.ContextEnvNow:      time.Now().Format(time.RFC3339),
+		entity.ContextEnvPlatform: runtime.GOOS,
+	}
+}
+
+// renderTemplate replaces each placeholder in s with render called with the
+// environment variable holding its value and the sh quote the placeholder
+// stands in, and reports whether s had any
+func renderTemplate(s string, render func(env string, quote byte) string) (string, bool) {
+	matches := templatePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, false
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(s[last:match[0]])
+		last = match[1]
+		quote := shQuoteAt(s, match[0])
+		if match[4] >= 0 {
+			b.WriteString(render(entity.ParamEnvPrefix+strings.ToUpper(s[match[4]:match[5]]), quote))
+			continue
+		}
+		name := s[match[2]:match[3]]
+		for _, variable := range entity.ContextVariables {
+			if variable.Name == name {
+				b.WriteString(render(variable.Env, quote))
+				break
+			}
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String(), true
+}
+
+// shQuoteAt returns the quote sh is inside of at offset pos of s: a single
+// or double quote, or 0 outside quotes
+func shQuoteAt(s string, pos int) byte {
+	var quote byte
+	for i := 0; i < pos; i++ {
+		switch c := s[i]; {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			if quote == c {
+				quote = 0
+			} else if quote == 0 {
+				quote = c
+			}
+		}
+	}
+	return quote
+}
+
+// shellReference returns how the shell running command expands an environment
+// variable. Placeholders become such references rather than the values
+// themselves, so a value is never parsed as shell syntax. sh references are
+// double-quoted so values with spaces or globs stay one word, closing a single
+// quote around them first. cmd's !NAME! needs delayed expansion, which
+// prepareCommand turns on for templated commands
+func shellReference(shell, command string) func(env string, quote byte) string {
+	switch shell {
+	case common.ShellSh, common.ShellBash:
+		return func(env string, quote byte) string {
+			switch quote {
+			case '"':
+				return "${" + env + "}"
+			case '\'':
+				return `'"${` + env + `}"'`
+			}
+			return `"${` + env + `}"`
+		}
+	case common.ShellPowerShell:
+		return func(env string, _ byte) string { return "$env:" + env }
+	case common.ShellCmd:
+		return func(env string, _ byte) string { return "!" + env + "!" }
+	}
+	if runtime.GOOS == "windows" {
+		if strings.HasPrefix(command, "powershell") {
+			return shellReference(common.ShellPowerShell, command)
+		}
+		return shellReference(common.ShellCmd, command)
+	}
+	return shellReference(common.ShellSh, command)
+}
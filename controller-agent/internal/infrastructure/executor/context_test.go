@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// newTestService returns an executor that logs nowhere
+func newTestService() *Service {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewService(logger)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		shell     string
+		input     string
+		want      string
+		wantFound bool
+	}{
+		{"sh device ID", common.ShellSh, "echo {{.DeviceID}}", `echo "${LAZYCTRL_DEVICE_ID}"`, true},
+		{"bash user ID", common.ShellBash, `echo "{{ .UserID }}"`, `echo "${LAZYCTRL_USER_ID}"`, true},
+		{"powershell now", common.ShellPowerShell, "Write-Output {{.Now}}", "Write-Output $env:LAZYCTRL_NOW", true},
+		{"cmd platform", common.ShellCmd, "echo {{.Platform}}", "echo !LAZYCTRL_PLATFORM!", true},
+		{"parameter", common.ShellSh, "ping -c {{.Params.count}} host", `ping -c "${LAZYCTRL_PARAM_COUNT}" host`, true},
+		{"several placeholders", common.ShellSh, "{{.DeviceID}}-{{.Platform}}", `"${LAZYCTRL_DEVICE_ID}"-"${LAZYCTRL_PLATFORM}"`, true},
+		{"single-quoted placeholder", common.ShellSh, "echo 'id={{.DeviceID}}'", `echo 'id='"${LAZYCTRL_DEVICE_ID}"''`, true},
+		{"escaped quote is not a quote", common.ShellSh, `echo \" {{.DeviceID}}`, `echo \" "${LAZYCTRL_DEVICE_ID}"`, true},
+		{"other templates are kept", common.ShellSh, "docker ps --format '{{.Names}}'", "docker ps --format '{{.Names}}'", false},
+		{"no placeholder", common.ShellSh, "uptime", "uptime", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := renderTemplate(tt.input, shellReference(tt.shell, tt.input))
+			if got != tt.want || found != tt.wantFound {
+				t.Fatalf("renderTemplate() = %q, %v, want %q, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestContextVariablesResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs sh")
+	}
+	s := newTestService()
+	s.SetDeviceID("device-1")
+
+	spoofed := map[string]string{
+		entity.ContextEnvDeviceID:      "spoofed",
+		entity.ContextEnvUserID:        "spoofed",
+		entity.ContextEnvPlatform:      "spoofed",
+		entity.ParamEnvPrefix + "NAME": "$(echo injected)",
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		opts    ExecuteOptions
+		want    string // output with Now replaced by NOW
+	}{
+		{"device ID", "echo {{.DeviceID}}", ExecuteOptions{Shell: common.ShellSh}, "device-1"},
+		{"user ID", "echo {{.UserID}}", ExecuteOptions{Shell: common.ShellSh, UserID: "alice"}, "alice"},
+		{"no user", `echo "[{{.UserID}}]"`, ExecuteOptions{Shell: common.ShellSh}, "[]"},
+		{"platform", "echo {{.Platform}}", ExecuteOptions{Shell: common.ShellSh}, runtime.GOOS},
+		{"now", "echo {{.Now}}", ExecuteOptions{Shell: common.ShellSh}, "NOW"},
+		{"environment", "echo $LAZYCTRL_DEVICE_ID $LAZYCTRL_USER_ID", ExecuteOptions{Shell: common.ShellSh, UserID: "alice"}, "device-1 alice"},
+		{"built-ins cannot be overridden by env", "echo {{.DeviceID}} {{.UserID}} {{.Platform}}", ExecuteOptions{Shell: common.ShellSh, UserID: "alice", Env: spoofed}, "device-1 alice " + runtime.GOOS},
+		{"parameter values are not parsed by the shell", `echo "{{.Params.name}}"`, ExecuteOptions{Shell: common.ShellSh, Env: spoofed}, "$(echo injected)"},
+		{"unquoted parameter is one argument", `printf '[%s]' {{.Params.words}}`, ExecuteOptions{Shell: common.ShellSh, Env: map[string]string{entity.ParamEnvPrefix + "WORDS": "a  b *"}}, "[a  b *]"},
+		{"single-quoted parameter is expanded", `printf '[%s]' 'x {{.Params.words}}'`, ExecuteOptions{Shell: common.ShellSh, Env: map[string]string{entity.ParamEnvPrefix + "WORDS": "a  b *"}}, "[x a  b *]"},
+		{"direct execution gets values", "", ExecuteOptions{Shell: common.ShellNone, Args: []string{"echo", "{{.DeviceID}}", "{{.UserID}}", "{{.Params.name}}"}, UserID: "alice", Env: spoofed}, "device-1 alice $(echo injected)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			result, err := s.ExecuteWithOptions(ctx, tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			got := strings.TrimSpace(result.Output)
+			if tt.want == "NOW" {
+				if _, err := time.Parse(time.RFC3339, got); err != nil {
+					t.Fatalf("Now = %q, want an RFC 3339 time", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// ErrDependencyNotSatisfied is returned when a dependency of a command has not
+// succeeded recently enough and could not be run first
+var ErrDependencyNotSatisfied = errors.New("dependency not satisfied")
+
+// Dependency is a command run automatically because a command depending on
+// it was executed with AutoRunDeps
+type Dependency struct {
+	Command string
+	Options ExecuteOptions // the dependency's own settings, including its dependencies
+	Timeout time.Duration  // 0 means common.DefaultCommandTimeout
+}
+
+// DependencyResolver looks up the dependencies of a command
+type DependencyResolver interface {
+	// LastSuccess returns when the command last succeeded, zero if it never did
+	LastSuccess(ctx context.Context, commandID string) (time.Time, error)
+	// ResolveDependency returns how to run the command as a dependency
+	ResolveDependency(ctx context.Context, commandID string) (*Dependency, error)
+}
+
+// SetDependencyResolver enables the checks of executions with DependsOn set: a
+// dependency is satisfied when it succeeded within freshness, or at any time
+// for 0. Without a resolver dependencies are not checked
+func (s *Service) SetDependencyResolver(resolver DependencyResolver, freshness time.Duration) {
+	s.dependencies = resolver
+	s.dependencyFreshness = freshness
+}
+
+// checkDependencies fails unless every dependency of the execution is
+// satisfied. With AutoRunDeps, unsatisfied dependencies are run first
+func (s *Service) checkDependencies(ctx context.Context, opts ExecuteOptions) error {
+	for _, dependencyID := range opts.DependsOn {
+		lastSuccess, err := s.dependencies.LastSuccess(ctx, dependencyID)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrDependencyNotSatisfied, dependencyID, err)
+		}
+		if !lastSuccess.IsZero() && (s.dependencyFreshness <= 0 || time.Since(lastSuccess) <= s.dependencyFreshness) {
+			continue
+		}
+
+		if !opts.AutoRunDeps {
+			if lastSuccess.IsZero() {
+				return fmt.Errorf("%w: %s has never succeeded", ErrDependencyNotSatisfied, dependencyID)
+			}
+			return fmt.Errorf("%w: %s last succeeded %s ago, more than %s", ErrDependencyNotSatisfied,
+				dependencyID, time.Since(lastSuccess).Round(time.Second), s.dependencyFreshness)
+		}
+		if err := s.runDependency(ctx, opts, dependencyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runDependency runs a dependency of the execution, after its own
+// dependencies. Commands already being resolved are not run again, which stops
+// cycles that a hand-edited commands file may contain
+func (s *Service) runDependency(ctx context.Context, opts ExecuteOptions, dependencyID string) error {
+	chain := append(slices.Clone(opts.dependents), opts.CommandID)
+
+	dependency, err := s.dependencies.ResolveDependency(ctx, dependencyID)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrDependencyNotSatisfied, dependencyID, err)
+	}
+	if slices.Contains(chain, dependency.Options.CommandID) {
+		return fmt.Errorf("%w: circular dependency on %s", ErrDependencyNotSatisfied, dependencyID)
+	}
+
+	depOpts := dependency.Options
+	depOpts.Source = opts.Source
+	depOpts.ClientIP = opts.ClientIP
+	depOpts.UserID = opts.UserID
+	depOpts.dependents = chain
+
+	timeout := dependency.Timeout
+	if timeout <= 0 {
+		timeout = common.DefaultCommandTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s.logger.WithFields(logrus.Fields{
+		"command_id":    opts.CommandID,
+		"dependency_id": dependencyID,
+	}).Info("Running command dependency")
+
+	result, err := s.ExecuteWithOptions(runCtx, dependency.Command, depOpts)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrDependencyNotSatisfied, dependencyID, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s failed: %s", ErrDependencyNotSatisfied, dependencyID, result.Error)
+	}
+	return nil
+}
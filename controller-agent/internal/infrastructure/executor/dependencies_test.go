@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDependencies reports the last successes in a map, and records a
+// success for every dependency that is run
+type fakeDependencies struct {
+	mu           sync.Mutex
+	lastSuccess  map[string]time.Time
+	dependencies map[string]*Dependency
+	ran          []string
+}
+
+func (f *fakeDependencies) LastSuccess(ctx context.Context, commandID string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastSuccess[commandID], nil
+}
+
+func (f *fakeDependencies) ResolveDependency(ctx context.Context, commandID string) (*Dependency, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dependency, ok := f.dependencies[commandID]
+	if !ok {
+		return nil, errors.New("command not found: " + commandID)
+	}
+	f.ran = append(f.ran, commandID)
+	return dependency, nil
+}
+
+func TestExecuteDependencies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh commands")
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		freshness   time.Duration
+		lastSuccess map[string]time.Time
+		opts        ExecuteOptions
+		wantErr     bool
+		wantRan     []string
+	}{
+		{
+			name:        "satisfied",
+			freshness:   time.Hour,
+			lastSuccess: map[string]time.Time{"mount": now.Add(-time.Minute)},
+			opts:        ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}},
+		},
+		{
+			name:        "stale",
+			freshness:   time.Hour,
+			lastSuccess: map[string]time.Time{"mount": now.Add(-2 * time.Hour)},
+			opts:        ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}},
+			wantErr:     true,
+		},
+		{
+			name:      "never succeeded",
+			freshness: time.Hour,
+			opts:      ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}},
+			wantErr:   true,
+		},
+		{
+			name:        "any success without a freshness window",
+			lastSuccess: map[string]time.Time{"mount": now.Add(-30 * 24 * time.Hour)},
+			opts:        ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}},
+		},
+		{
+			name:        "one of two unsatisfied",
+			freshness:   time.Hour,
+			lastSuccess: map[string]time.Time{"mount": now},
+			opts:        ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount", "unlock"}},
+			wantErr:     true,
+		},
+		{
+			name:      "auto-run the missing dependency",
+			freshness: time.Hour,
+			opts:      ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}, AutoRunDeps: true},
+			wantRan:   []string{"mount"},
+		},
+		{
+			name:      "auto-run its dependencies first",
+			freshness: time.Hour,
+			opts:      ExecuteOptions{CommandID: "backup", DependsOn: []string{"unlock"}, AutoRunDeps: true},
+			wantRan:   []string{"unlock", "mount"},
+		},
+		{
+			name:      "auto-run dependency fails",
+			freshness: time.Hour,
+			opts:      ExecuteOptions{CommandID: "backup", DependsOn: []string{"broken"}, AutoRunDeps: true},
+			wantErr:   true,
+			wantRan:   []string{"broken"},
+		},
+		{
+			name:      "auto-run stops a cycle",
+			freshness: time.Hour,
+			opts:      ExecuteOptions{CommandID: "backup", DependsOn: []string{"loop"}, AutoRunDeps: true},
+			wantErr:   true,
+			wantRan:   []string{"loop", "backup"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &fakeDependencies{
+				lastSuccess: tt.lastSuccess,
+				dependencies: map[string]*Dependency{
+					"mount":  {Command: "true", Options: ExecuteOptions{CommandID: "mount"}},
+					"unlock": {Command: "true", Options: ExecuteOptions{CommandID: "unlock", DependsOn: []string{"mount"}, AutoRunDeps: true}},
+					"broken": {Command: "exit 3", Options: ExecuteOptions{CommandID: "broken"}},
+					// A hand-edited commands file can hold a cycle: loop depends on backup
+					"loop":   {Command: "true", Options: ExecuteOptions{CommandID: "loop", DependsOn: []string{"backup"}, AutoRunDeps: true}},
+					"backup": {Command: "true", Options: ExecuteOptions{CommandID: "backup", DependsOn: []string{"loop"}, AutoRunDeps: true}},
+				},
+			}
+			s := newTestService()
+			s.SetDependencyResolver(resolver, tt.freshness)
+
+			result, err := s.ExecuteWithOptions(context.Background(), "echo ran", tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDependencyNotSatisfied) {
+					t.Fatalf("err = %v, want %v", err, ErrDependencyNotSatisfied)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ExecuteWithOptions: %v", err)
+				}
+				if !result.Success {
+					t.Errorf("execution failed: %s", result.Error)
+				}
+			}
+			if len(resolver.ran) != len(tt.wantRan) {
+				t.Fatalf("ran dependencies %v, want %v", resolver.ran, tt.wantRan)
+			}
+			for i := range tt.wantRan {
+				if resolver.ran[i] != tt.wantRan[i] {
+					t.Fatalf("ran dependencies %v, want %v", resolver.ran, tt.wantRan)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteDependenciesWithoutResolver(t *testing.T) {
+	s := newTestService()
+	result, err := s.ExecuteWithOptions(context.Background(), "echo ran", ExecuteOptions{CommandID: "backup", DependsOn: []string{"mount"}})
+	if err != nil || !result.Success {
+		t.Fatalf("execution = %+v, %v; dependencies are not checked without a resolver", result, err)
+	}
+}
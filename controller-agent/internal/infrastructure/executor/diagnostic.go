@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// Diagnostic is a command run after another command fails, e.g. to capture logs
+type Diagnostic struct {
+	Command string
+	Options ExecuteOptions // only the process settings are used: working dir, env, shell and args
+	Timeout time.Duration  // 0 means common.DefaultCommandTimeout
+}
+
+// DiagnosticResolver looks up the diagnostic command configured by ID
+type DiagnosticResolver interface {
+	ResolveDiagnostic(ctx context.Context, commandID string) (*Diagnostic, error)
+}
+
+// DiagnosticResult is the outcome of the diagnostic run after a failed execution
+type DiagnosticResult struct {
+	CommandID string `json:"command_id"`
+	Success   bool   `json:"success"`
+	Output    string `json:"output"`
+	Error     string `json:"error,omitempty"`
+	ExitCode  int    `json:"exit_code"`
+}
+
+// SetDiagnosticResolver enables the diagnostics of executions with
+// OnFailureDiagnostic set; without a resolver they are not run
+func (s *Service) SetDiagnosticResolver(resolver DiagnosticResolver) {
+	s.diagnostics = resolver
+}
+
+// runDiagnostic runs the diagnostic command diagnosticID after the command
+// commandID failed. The diagnostic's own diagnostic and hooks are never run,
+// so diagnostics cannot recurse. It gets a fresh context because the failure
+// may have been the execution timing out
+func (s *Service) runDiagnostic(commandID, diagnosticID string) *DiagnosticResult {
+	result := &DiagnosticResult{CommandID: diagnosticID, ExitCode: -1}
+	if diagnosticID == commandID {
+		result.Error = "a command cannot be its own diagnostic"
+		return result
+	}
+	
+	diagnostic, err := s.diagnostics.ResolveDiagnostic(context.Background(), diagnosticID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	
+	opts := ExecuteOptions{
+		WorkingDir: diagnostic.Options.WorkingDir,
+		Env:        diagnostic.Options.Env,
+		LoginShell: diagnostic.Options.LoginShell || s.loginShell,
+		Shell:      diagnostic.Options.Shell,
+		Args:       diagnostic.Options.Args,
+	}
+	if err := validateWorkingDir(opts.WorkingDir); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if err := validateShell(opts.Shell, opts.Args); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	
+	timeout := diagnostic.Timeout
+	if timeout <= 0 {
+		timeout = common.DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	
+	s.logger.WithFields(logrus.Fields{
+		"command_id":    commandID,
+		"diagnostic_id": diagnosticID,
+	}).Info("Running failure diagnostic")
+	
+	cmd := s.prepareCommand(ctx, diagnostic.Command, opts)
+	output := &cappedBuffer{limit: s.maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	_, err = s.runProcess(cmd)
+	
+	result.Success = err == nil
+	result.Output = output.String()
+	result.ExitCode = 0
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = exitCode(err)
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiagnostics resolves diagnostics from a map and counts the lookups
+type fakeDiagnostics struct {
+	mu          sync.Mutex
+	diagnostics map[string]*Diagnostic
+	resolved    []string
+}
+
+func (f *fakeDiagnostics) ResolveDiagnostic(ctx context.Context, commandID string) (*Diagnostic, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resolved = append(f.resolved, commandID)
+	diagnostic, ok := f.diagnostics[commandID]
+	if !ok {
+		return nil, errors.New("command not found: " + commandID)
+	}
+	return diagnostic, nil
+}
+
+func TestExecuteDiagnostic(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh commands")
+	}
+	resolver := &fakeDiagnostics{diagnostics: map[string]*Diagnostic{
+		"capture-logs": {Command: "echo last log line"},
+		"env-logs":     {Command: `echo "$LOG_LEVEL"`, Options: ExecuteOptions{Env: map[string]string{"LOG_LEVEL": "debug"}}},
+		"broken":       {Command: "echo partial; exit 4"},
+	}}
+
+	tests := []struct {
+		name         string
+		command      string
+		opts         ExecuteOptions
+		noResolver   bool
+		timeout      time.Duration
+		want         *DiagnosticResult
+		wantResolved []string
+	}{
+		{
+			name:    "success is not diagnosed",
+			command: "echo ok",
+			opts:    ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "capture-logs"},
+		},
+		{
+			name:         "failure attaches the output",
+			command:      "exit 1",
+			opts:         ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "capture-logs"},
+			want:         &DiagnosticResult{CommandID: "capture-logs", Success: true, Output: "last log line\n"},
+			wantResolved: []string{"capture-logs"},
+		},
+		{
+			name:         "diagnostic gets its own env",
+			command:      "exit 1",
+			opts:         ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "env-logs", Env: map[string]string{"LOG_LEVEL": "info"}},
+			want:         &DiagnosticResult{CommandID: "env-logs", Success: true, Output: "debug\n"},
+			wantResolved: []string{"env-logs"},
+		},
+		{
+			name:         "timeout is diagnosed with a fresh context",
+			command:      "sleep 5",
+			opts:         ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "capture-logs"},
+			timeout:      100 * time.Millisecond,
+			want:         &DiagnosticResult{CommandID: "capture-logs", Success: true, Output: "last log line\n"},
+			wantResolved: []string{"capture-logs"},
+		},
+		{
+			name:    "no diagnostic configured",
+			command: "exit 1",
+			opts:    ExecuteOptions{CommandID: "backup"},
+		},
+		{
+			name:       "no resolver",
+			command:    "exit 1",
+			opts:       ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "capture-logs"},
+			noResolver: true,
+		},
+		{
+			name:         "failing diagnostic runs once",
+			command:      "exit 1",
+			opts:         ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "broken"},
+			want:         &DiagnosticResult{CommandID: "broken", Output: "partial\n", Error: "exit status 4", ExitCode: 4},
+			wantResolved: []string{"broken"},
+		},
+		{
+			name:    "own diagnostic",
+			command: "exit 1",
+			opts:    ExecuteOptions{CommandID: "capture-logs", OnFailureDiagnostic: "capture-logs"},
+			want:    &DiagnosticResult{CommandID: "capture-logs", Error: "a command cannot be its own diagnostic", ExitCode: -1},
+		},
+		{
+			name:         "unknown diagnostic",
+			command:      "exit 1",
+			opts:         ExecuteOptions{CommandID: "backup", OnFailureDiagnostic: "missing"},
+			want:         &DiagnosticResult{CommandID: "missing", Error: "command not found: missing", ExitCode: -1},
+			wantResolved: []string{"missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver.resolved = nil
+			s := newTestService()
+			if !tt.noResolver {
+				s.SetDiagnosticResolver(resolver)
+			}
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			result, err := s.ExecuteWithOptions(ctx, tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+			if tt.want == nil {
+				if result.Diagnostic != nil {
+					t.Errorf("diagnostic = %+v, want none", result.Diagnostic)
+				}
+			} else if result.Diagnostic == nil || *result.Diagnostic != *tt.want {
+				t.Errorf("diagnostic = %+v, want %+v", result.Diagnostic, tt.want)
+			}
+			if strings.Join(resolver.resolved, ",") != strings.Join(tt.wantResolved, ",") {
+				t.Errorf("resolved %q, want %q", resolver.resolved, tt.wantResolved)
+			}
+		})
+	}
+}
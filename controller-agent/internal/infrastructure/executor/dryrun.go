@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// DryRunResult describes how a command would be executed without running it
+type DryRunResult struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"` // resolved process invocation, including the shell
+	WorkingDir string   `json:"workingDir,omitempty"`
+	EnvKeys    []string `json:"envKeys,omitempty"` // command-level variables, values omitted
+	LoginShell bool     `json:"loginShell"`
+	Shell      string   `json:"shell,omitempty"`
+	PreHook    string   `json:"preHook,omitempty"`
+	PostHook   string   `json:"postHook,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// DryRun resolves command as ExecuteWithOptions would and reports problems
+// that would make the execution fail as warnings instead of running it
+func (s *Service) DryRun(command string, opts ExecuteOptions) *DryRunResult {
+	if s.loginShell {
+		opts.LoginShell = true
+	}
+	result := &DryRunResult{
+		Command:    command,
+		WorkingDir: opts.WorkingDir,
+		LoginShell: opts.LoginShell,
+		Shell:      opts.Shell,
+		PreHook:    opts.PreHook,
+		PostHook:   opts.PostHook,
+	}
+	
+	for key := range opts.Env {
+		result.EnvKeys = append(result.EnvKeys, key)
+	}
+	sort.Strings(result.EnvKeys)
+	
+	// An invalid shell cannot be resolved to a process invocation
+	if err := validateShell(opts.Shell, opts.Args); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	} else {
+		result.Args = s.prepareCommand(context.Background(), command, opts).Args
+	}
+	if err := s.ValidateCommand(command); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+	for _, hook := range []string{opts.PreHook, opts.PostHook} {
+		if hook == "" {
+			continue
+		}
+		if err := s.ValidateCommand(hook); err != nil {
+			result.Warnings = append(result.Warnings, "hook: "+err.Error())
+		}
+	}
+	if err := validateWorkingDir(opts.WorkingDir); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+	if s.maxStdinSize > 0 && len(opts.Stdin) > s.maxStdinSize {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %d bytes, limit is %d", ErrStdinTooLarge, len(opts.Stdin), s.maxStdinSize))
+	}
+	
+	return result
+}
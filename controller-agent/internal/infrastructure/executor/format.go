@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// parsesOutput reports whether the format needs stdout captured on its own
+func parsesOutput(format string) bool {
+	return format == common.OutputFormatJSON || format == common.OutputFormatLines
+}
+
+// parseOutput converts stdout to the structured form of format. A JSON parse
+// failure returns no data and a warning so the caller falls back to the raw output
+func parseOutput(format string, stdout *cappedBuffer) (interface{}, string) {
+	if stdout.truncated {
+		return nil, fmt.Sprintf("output exceeded the size cap and was not parsed as %s", format)
+	}
+	raw := stdout.String()
+	
+	switch format {
+	case common.OutputFormatJSON:
+		if strings.TrimSpace(raw) == "" {
+			return nil, "output is empty, expected JSON"
+		}
+		var data interface{}
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, fmt.Sprintf("output is not valid JSON: %v", err)
+		}
+		return data, ""
+	case common.OutputFormatLines:
+		raw = strings.TrimSuffix(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+		if raw == "" {
+			return []string{}, ""
+		}
+		return strings.Split(raw, "\n"), ""
+	}
+	return nil, ""
+}
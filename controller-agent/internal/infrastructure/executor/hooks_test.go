@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestExecuteHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use a POSIX shell")
+	}
+
+	tests := []struct {
+		name        string
+		command     string
+		opts        ExecuteOptions
+		wantRan     bool // the main command ran
+		wantSuccess bool
+		wantExit    int
+		wantPre     string // pre-hook output, "-" when it did not run
+		wantPost    string // post-hook output, "-" when it did not run
+	}{
+		{"no hooks", "echo main", ExecuteOptions{}, true, true, 0, "-", "-"},
+		{"pre-hook succeeds", "echo main", ExecuteOptions{PreHook: "echo setup"}, true, true, 0, "setup", "-"},
+		{"pre-hook aborts", "echo main", ExecuteOptions{PreHook: "echo refused; exit 4", PostHook: "echo teardown"}, false, false, 4, "refused", "-"},
+		{"post-hook gets the exit code", "echo main; exit 3", ExecuteOptions{PostHook: "echo code=$" + ExitCodeEnv}, true, false, 3, "-", "code=3"},
+		{"post-hook after success", "echo main", ExecuteOptions{PostHook: "echo code=$" + ExitCodeEnv}, true, true, 0, "-", "code=0"},
+		{"failing post-hook is only logged", "echo main", ExecuteOptions{PostHook: "exit 5"}, true, true, 0, "-", ""},
+		{"failing post-hook with strict hooks", "echo main", ExecuteOptions{PostHook: "exit 5", StrictHooks: true}, true, false, 5, "-", ""},
+		{"hooks run in a shell for direct execution", "", ExecuteOptions{Shell: common.ShellNone, Args: []string{"echo", "main"}, PreHook: "echo $((1 + 1))"}, true, true, 0, "2", "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The main command leaves a marker so a skipped run can be told apart
+			marker := filepath.Join(t.TempDir(), "ran")
+			command := tt.command
+			if command != "" {
+				command = "touch " + marker + "; " + command
+			} else {
+				tt.opts.PreHook += "; touch " + marker
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			result, err := newTestService().ExecuteWithOptions(ctx, command, tt.opts)
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if _, statErr := os.Stat(marker); (statErr == nil) != tt.wantRan {
+				t.Errorf("main command ran = %v, want %v", statErr == nil, tt.wantRan)
+			}
+			if result.Success != tt.wantSuccess || result.ExitCode != tt.wantExit {
+				t.Errorf("success %v, exit code %d; want %v, %d (error %q)", result.Success, result.ExitCode, tt.wantSuccess, tt.wantExit, result.Error)
+			}
+			if !tt.wantRan && !strings.HasPrefix(result.Error, "pre-hook failed") {
+				t.Errorf("error = %q, want the pre-hook failure", result.Error)
+			}
+			if tt.wantRan && !strings.Contains(result.Output, "main") {
+				t.Errorf("output = %q, want the command's output", result.Output)
+			}
+			if strings.Contains(result.Output, "setup") || strings.Contains(result.Output, "code=") {
+				t.Errorf("output = %q, hook output belongs in the hook results", result.Output)
+			}
+			for _, hook := range []struct {
+				name   string
+				result *HookResult
+				want   string
+			}{{"pre-hook", result.PreHook, tt.wantPre}, {"post-hook", result.PostHook, tt.wantPost}} {
+				switch {
+				case hook.want == "-" && hook.result != nil:
+					t.Errorf("%s ran: %+v", hook.name, hook.result)
+				case hook.want == "-":
+				case hook.result == nil:
+					t.Errorf("%s did not run", hook.name)
+				case strings.TrimSpace(hook.result.Output) != hook.want:
+					t.Errorf("%s output = %q, want %q", hook.name, hook.result.Output, hook.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// profileVar is exported only by the test HOME's shell profiles
+const profileVar = "LAZYCTRL_TEST_PROFILE_VAR"
+
+func TestLoginShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("login shells are not used on Windows")
+	}
+	home := t.TempDir()
+	// bash reads .bash_profile and sh reads .profile
+	for _, name := range []string{".profile", ".bash_profile"} {
+		if err := os.WriteFile(filepath.Join(home, name), []byte("export "+profileVar+"=from-profile\n"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	t.Setenv("HOME", home)
+	t.Setenv(profileVar, "") // restored after the test
+	os.Unsetenv(profileVar)
+
+	const command = `test "$` + profileVar + `" = from-profile && echo "$` + profileVar + `"`
+	tests := []struct {
+		name        string
+		global      bool
+		opts        ExecuteOptions
+		wantSuccess bool
+	}{
+		{"plain shell skips the profile", false, ExecuteOptions{}, false},
+		{"per-command login shell", false, ExecuteOptions{LoginShell: true}, true},
+		{"global login shell", true, ExecuteOptions{}, true},
+		{"bash login shell", false, ExecuteOptions{Shell: common.ShellBash, LoginShell: true}, true},
+		{"bash without login", false, ExecuteOptions{Shell: common.ShellBash}, false},
+		{"sh login shell", false, ExecuteOptions{Shell: common.ShellSh, LoginShell: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Shell == common.ShellBash {
+				if _, err := exec.LookPath("bash"); err != nil {
+					t.Skip("bash not installed")
+				}
+			}
+			s := newTestService()
+			s.SetLoginShell(tt.global)
+
+			result, err := s.ExecuteWithOptions(context.Background(), command, tt.opts)
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+			if result.Success != tt.wantSuccess {
+				t.Fatalf("success = %v, want %v: %s%s", result.Success, tt.wantSuccess, result.Output, result.Error)
+			}
+			if tt.wantSuccess && strings.TrimSpace(result.Output) != "from-profile" {
+				t.Errorf("output = %q, want from-profile", result.Output)
+			}
+		})
+	}
+}
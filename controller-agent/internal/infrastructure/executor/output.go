@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Output stream names passed to ExecuteOptions.OnOutput
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// outputTruncatedMarker is appended to output cut off at the size cap
+const outputTruncatedMarker = "\n[output truncated]"
+
+// SetMaxOutputBytes caps the output kept per execution; 0 means unlimited
+func (s *Service) SetMaxOutputBytes(size int) {
+	if size < 0 {
+		size = 0
+	}
+	s.maxOutput = size
+}
+
+// cappedBuffer collects combined stdout/stderr up to a limit and silently
+// drains the rest so the child process never blocks on a full pipe
+type cappedBuffer struct {
+	mutex     sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// streamWriter collects one output stream into the execution's buffer and
+// hands every chunk to the OnOutput callback as it is written
+type streamWriter struct {
+	output   *cappedBuffer
+	stream   string
+	onOutput func(stream string, data []byte)
+}
+
+// Write implements io.Writer
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.onOutput(w.stream, p)
+	return w.output.Write(p)
+}
+
+// String returns the collected output, marked when it was truncated
+func (b *cappedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	if b.truncated {
+		return b.buf.String() + outputTruncatedMarker
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{"unlimited", 0, []string{"abc", "def"}, "abcdef", false},
+		{"under the cap", 10, []string{"abc", "def"}, "abcdef", false},
+		{"exactly the cap", 6, []string{"abc", "def"}, "abcdef", false},
+		{"write crossing the cap", 4, []string{"abc", "def"}, "abcd", true},
+		{"writes past the cap", 3, []string{"abc", "def", "ghi"}, "abc", true},
+		{"single oversized write", 2, []string{"abcdef"}, "ab", true},
+		{"empty write at the cap", 3, []string{"abc", ""}, "abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &cappedBuffer{limit: tt.limit}
+			for _, write := range tt.writes {
+				// The full length is reported so writers never block or fail
+				if n, err := b.Write([]byte(write)); n != len(write) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", write, n, err)
+				}
+			}
+			want := tt.want
+			if tt.wantTruncated {
+				want += outputTruncatedMarker
+			}
+			if b.truncated != tt.wantTruncated || b.String() != want {
+				t.Errorf("buffer = %q (truncated %v), want %q (truncated %v)", b.String(), b.truncated, want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestExecuteTruncatesOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command uses a POSIX shell")
+	}
+	// Prints 200000 bytes, far more than the pipe buffer
+	const command = "yes 123456789 | head -n 20000"
+
+	tests := []struct {
+		name          string
+		maxOutput     int
+		wantLen       int
+		wantTruncated bool
+	}{
+		{"cap below the output", 1000, 1000, true},
+		{"cap above the output", 1 << 20, 200000, false},
+		{"unlimited", 0, 200000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			s.SetMaxOutputBytes(tt.maxOutput)
+
+			result, err := s.ExecuteWithOptions(context.Background(), command, ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+			if !result.Success {
+				t.Fatalf("execution failed: %s", result.Error)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			output := strings.TrimSuffix(result.Output, outputTruncatedMarker)
+			if tt.wantTruncated != (output != result.Output) {
+				t.Errorf("output ends with the truncation marker = %v, want %v", output != result.Output, tt.wantTruncated)
+			}
+			if len(output) != tt.wantLen {
+				t.Errorf("kept %d bytes of output, want %d", len(output), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestDefaultMaxOutput(t *testing.T) {
+	if got := newTestService().maxOutput; got != 1<<20 {
+		t.Errorf("default output cap = %d, want 1MB", got)
+	}
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// ShellInfo describes a shell found on the agent's PATH
+type ShellInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// candidateShells lists the shells UIs may build commands for
+var candidateShells = []string{"sh", "bash", "zsh", "fish", "pwsh", "powershell", "cmd"}
+
+// DefaultShell returns the shell commands are executed with on this platform
+func DefaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+// DetectShells returns the candidate shells available on the PATH
+func DetectShells() []ShellInfo {
+	shells := make([]ShellInfo, 0, len(candidateShells))
+	for _, name := range candidateShells {
+		if path, err := exec.LookPath(name); err == nil {
+			shells = append(shells, ShellInfo{Name: name, Path: path})
+		}
+	}
+	return shells
+}
+
+// ShellExecutionAvailable reports whether the default shell can be found
+func ShellExecutionAvailable() bool {
+	_, err := exec.LookPath(DefaultShell())
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePath returns a PATH holding an executable stub for each name
+func fakePath(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestDetectShells(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell stubs are shell scripts")
+	}
+
+	tests := []struct {
+		name          string
+		onPath        []string
+		want          []string
+		wantAvailable bool
+	}{
+		{"none", nil, nil, false},
+		{"sh only", []string{"sh"}, []string{"sh"}, true},
+		{"listed in candidate order", []string{"zsh", "pwsh", "bash", "sh"}, []string{"sh", "bash", "zsh", "pwsh"}, true},
+		{"no default shell", []string{"bash", "fish"}, []string{"bash", "fish"}, false},
+		{"unknown shells ignored", []string{"sh", "tcsh", "ksh"}, []string{"sh"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakePath(t, tt.onPath...)
+			t.Setenv("PATH", dir)
+
+			shells := DetectShells()
+			var got []string
+			for _, shell := range shells {
+				got = append(got, shell.Name)
+				if shell.Path != filepath.Join(dir, shell.Name) {
+					t.Errorf("%s path = %q, want it in %s", shell.Name, shell.Path, dir)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("DetectShells() = %v, want %v", got, tt.want)
+			}
+			if available := ShellExecutionAvailable(); available != tt.wantAvailable {
+				t.Errorf("ShellExecutionAvailable() = %v, want %v", available, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestDetectShellsSkipsNonExecutables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits do not apply")
+	}
+	dir := fakePath(t, "bash")
+	if err := os.WriteFile(filepath.Join(dir, "zsh"), []byte("not executable"), 0o644); err != nil {
+		t.Fatalf("write zsh: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	shells := DetectShells()
+	if len(shells) != 1 || shells[0].Name != "bash" {
+		t.Errorf("DetectShells() = %v, want only bash", shells)
+	}
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// PowerCommand returns the platform command for an immediate power action.
+// The delay is handled by the scheduler so cancellation works on every platform.
+func PowerCommand(action string) (string, error) {
+	return powerCommandFor(action, runtime.GOOS)
+}
+
+func powerCommandFor(action, platform string) (string, error) {
+	switch action {
+	case common.PowerActionShutdown:
+		switch platform {
+		case common.PlatformWindows:
+			return "shutdown /s /t 0", nil
+		case common.PlatformDarwin:
+			return "shutdown -h now", nil
+		case common.PlatformLinux:
+			return "shutdown -h now", nil
+		}
+	case common.PowerActionReboot:
+		switch platform {
+		case common.PlatformWindows:
+			return "shutdown /r /t 0", nil
+		case common.PlatformDarwin:
+			return "shutdown -r now", nil
+		case common.PlatformLinux:
+			return "shutdown -r now", nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported power action: %s", action)
+	}
+
+	return "", fmt.Errorf("power action %s not supported on platform %s", action, platform)
+}
@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestPowerCommandFor(t *testing.T) {
+	tests := []struct {
+		action   string
+		platform string
+		want     string
+		wantErr  bool
+	}{
+		{common.PowerActionShutdown, common.PlatformLinux, "shutdown -h now", false},
+		{common.PowerActionShutdown, common.PlatformDarwin, "shutdown -h now", false},
+		{common.PowerActionShutdown, common.PlatformWindows, "shutdown /s /t 0", false},
+		{common.PowerActionReboot, common.PlatformLinux, "shutdown -r now", false},
+		{common.PowerActionReboot, common.PlatformDarwin, "shutdown -r now", false},
+		{common.PowerActionReboot, common.PlatformWindows, "shutdown /r /t 0", false},
+		{common.PowerActionReboot, "plan9", "", true},
+		{"hibernate", common.PlatformLinux, "", true},
+		{"", common.PlatformLinux, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+"/"+tt.platform, func(t *testing.T) {
+			got, err := powerCommandFor(tt.action, tt.platform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("powerCommandFor() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("powerCommandFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Signals reported in ExecutionResult.KilledBy
+const (
+	KilledBySIGTERM = "SIGTERM"
+	KilledBySIGKILL = "SIGKILL"
+)
+
+// SetKillGracePeriod sets how long a timed out or cancelled command has to
+// exit after SIGTERM before its process group is killed
+func (s *Service) SetKillGracePeriod(grace time.Duration) {
+	if grace < 0 {
+		grace = 0
+	}
+	s.killGrace = grace
+}
+
+// processKiller stops a command together with the processes it started once
+// its context is done: the group gets SIGTERM, then SIGKILL after the grace
+// period. Windows has no SIGTERM, so there the group is killed at once
+type processKiller struct {
+	grace time.Duration
+	group *processGroup
+
+	mutex    sync.Mutex
+	killedBy string
+	exited   bool
+}
+
+// runProcess runs cmd in its own process group and returns the signal that
+// stopped it, empty if it was not stopped by the agent
+func (s *Service) runProcess(cmd *exec.Cmd) (string, error) {
+	killer := &processKiller{grace: s.killGrace}
+	configureProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killer.stop(cmd.Process)
+	}
+	// Stop waiting for descendants that escaped the group and hold the pipes open
+	cmd.WaitDelay = s.killGrace + time.Second
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	group, err := newProcessGroup(cmd.Process)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to set up process group; only the command itself can be killed")
+	}
+	killer.mutex.Lock()
+	killer.group = group
+	killer.mutex.Unlock()
+
+	err = cmd.Wait()
+	if group != nil {
+		group.release()
+	}
+
+	killer.mutex.Lock()
+	defer killer.mutex.Unlock()
+	killer.exited = true
+	return killer.killedBy, err
+}
+
+// stop is the command's exec.Cmd.Cancel
+func (k *processKiller) stop(process *os.Process) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.group == nil {
+		// Cancelled before the group was set up, or setting it up failed
+		k.killedBy = KilledBySIGKILL
+		return process.Kill()
+	}
+	if !k.group.terminate() {
+		k.killedBy = KilledBySIGKILL
+		return k.group.kill()
+	}
+	k.killedBy = KilledBySIGTERM
+
+	group := k.group
+	time.AfterFunc(k.grace, func() {
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		// The group outlives the command when a descendant ignores SIGTERM,
+		// so it is killed even if the command already exited
+		if !k.exited {
+			k.killedBy = KilledBySIGKILL
+		}
+		group.kill()
+	})
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processGroup is the process group led by a command
+type processGroup struct {
+	pgid int
+}
+
+// configureProcessGroup starts cmd as the leader of a new process group
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func newProcessGroup(process *os.Process) (*processGroup, error) {
+	return &processGroup{pgid: process.Pid}, nil
+}
+
+// terminate sends SIGTERM to the group and reports whether the group is
+// given the grace period to exit
+func (g *processGroup) terminate() bool {
+	return syscall.Kill(-g.pgid, syscall.SIGTERM) == nil
+}
+
+func (g *processGroup) kill() error {
+	if err := syscall.Kill(-g.pgid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+func (g *processGroup) release() {}
@@ -0,0 +1,102 @@
+//go:build !windows
+
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecuteKillsProcessGroup(t *testing.T) {
+	const grace = 300 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		command      string
+		wantKilledBy string
+		minElapsed   time.Duration // the grace period is only waited for when SIGTERM is ignored
+	}{
+		{"finishes before the deadline", "true", "", 0},
+		{"exits on SIGTERM", "sleep 5", KilledBySIGTERM, 0},
+		{"ignores SIGTERM", "trap '' TERM; sleep 5", KilledBySIGKILL, grace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			s.SetKillGracePeriod(grace)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			result, err := s.ExecuteWithOptions(ctx, tt.command, ExecuteOptions{})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+
+			if result.KilledBy != tt.wantKilledBy || result.Killed != (tt.wantKilledBy != "") {
+				t.Errorf("killed = %v by %q, want %q", result.Killed, result.KilledBy, tt.wantKilledBy)
+			}
+			if result.Success == result.Killed {
+				t.Errorf("success = %v for a command killed = %v", result.Success, result.Killed)
+			}
+			if tt.wantKilledBy != "" && elapsed < 200*time.Millisecond+tt.minElapsed {
+				t.Errorf("stopped after %v, before the deadline and grace period", elapsed)
+			}
+			if elapsed > 3*time.Second {
+				t.Errorf("stopped after %v, want it killed within the grace period", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecuteKillsDescendants(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	s := newTestService()
+	s.SetKillGracePeriod(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// The background child ignores SIGTERM and outlives the shell, which exits on it
+	command := "sh -c \"trap '' TERM; sleep 30\" & echo $! > " + pidFile + "; wait"
+	if _, err := s.ExecuteWithOptions(ctx, command, ExecuteOptions{}); err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("read child pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("parse child pid: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("child %d survived the command's process group kill", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether pid is running; a zombie waiting to be reaped
+// by an init that never does counts as dead
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
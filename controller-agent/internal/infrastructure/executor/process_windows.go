@@ -0,0 +1,52 @@
+//go:build windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/windows"
+)
+
+// processGroup is a job object holding a command and the processes it starts
+type processGroup struct {
+	job windows.Handle
+}
+
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// newProcessGroup assigns the started process to a new job object; children
+// it spawns from then on join the job too
+func newProcessGroup(process *os.Process) (*processGroup, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	defer windows.CloseHandle(handle)
+	if err := windows.AssignProcessToJobObject(job, handle); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return &processGroup{job: job}, nil
+}
+
+// terminate reports false: console processes cannot be sent SIGTERM, so the
+// job is killed without a grace period
+func (g *processGroup) terminate() bool {
+	return false
+}
+
+func (g *processGroup) kill() error {
+	return windows.TerminateJobObject(g.job, 1)
+}
+
+// release closes the job handle; processes left in the job keep running
+func (g *processGroup) release() {
+	windows.CloseHandle(g.job)
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+// flakyCommand fails with exitCode until its attempt, counted in a file under
+// dir, reaches succeedOn
+func flakyCommand(dir string, succeedOn, exitCode int) string {
+	counter := filepath.Join(dir, "attempts")
+	return fmt.Sprintf("n=$(cat %[1]s 2>/dev/null || echo 0); n=$((n+1)); echo $n > %[1]s; [ $n -ge %[2]d ] || exit %[3]d",
+		counter, succeedOn, exitCode)
+}
+
+func TestExecuteRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the flaky command is a POSIX shell script")
+	}
+
+	tests := []struct {
+		name         string
+		succeedOn    int
+		exitCode     int
+		retry        *entity.RetryConfig
+		wantSuccess  bool
+		wantAttempts int
+		wantMinDelay time.Duration
+	}{
+		{"succeeds on the second attempt", 2, 1, &entity.RetryConfig{MaxAttempts: 3}, true, 2, 0},
+		{"no retry by default", 2, 1, nil, false, 1, 0},
+		{"attempts used up", 5, 1, &entity.RetryConfig{MaxAttempts: 3}, false, 3, 0},
+		{"listed exit code retried", 2, 75, &entity.RetryConfig{MaxAttempts: 3, ExitCodes: []int{75}}, true, 2, 0},
+		{"unlisted exit code not retried", 2, 1, &entity.RetryConfig{MaxAttempts: 3, ExitCodes: []int{75}}, false, 1, 0},
+		{"delay with backoff", 3, 1, &entity.RetryConfig{MaxAttempts: 3, Delay: 100, Backoff: 2}, true, 3, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			start := time.Now()
+			result, err := s.ExecuteWithOptions(context.Background(), flakyCommand(t.TempDir(), tt.succeedOn, tt.exitCode), ExecuteOptions{Retry: tt.retry})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+
+			if result.Success != tt.wantSuccess || result.Attempts != tt.wantAttempts {
+				t.Fatalf("success = %v after %d attempts, want %v after %d: %s",
+					result.Success, result.Attempts, tt.wantSuccess, tt.wantAttempts, result.Error)
+			}
+			if len(result.FailedAttempts) != tt.wantAttempts-1 {
+				t.Errorf("failed attempts = %+v, want %d", result.FailedAttempts, tt.wantAttempts-1)
+			}
+			for _, attempt := range result.FailedAttempts {
+				if attempt.ExitCode != tt.exitCode {
+					t.Errorf("failed attempt exit code = %d, want %d", attempt.ExitCode, tt.exitCode)
+				}
+			}
+			if elapsed < tt.wantMinDelay {
+				t.Errorf("finished after %v, want at least %v of retry delays", elapsed, tt.wantMinDelay)
+			}
+		})
+	}
+}
+
+func TestExecuteRetryCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the flaky command is a POSIX shell script")
+	}
+	s := newTestService()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// The context ends during the delay before the second attempt
+	start := time.Now()
+	result, err := s.ExecuteWithOptions(ctx, flakyCommand(t.TempDir(), 5, 1), ExecuteOptions{
+		Retry: &entity.RetryConfig{MaxAttempts: 5, Delay: 10000},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("retries ran on for %v after the context ended", elapsed)
+	}
+	if result.Success || result.Attempts != 1 {
+		t.Errorf("success = %v after %d attempts, want a failure after 1", result.Success, result.Attempts)
+	}
+}
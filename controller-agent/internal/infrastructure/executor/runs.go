@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActiveRun represents a command execution that is still in progress
+type ActiveRun struct {
+	RunID     string    `json:"runId"`
+	CommandID string    `json:"commandId,omitempty"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"startedAt"`
+	cancel    context.CancelFunc
+
+	userID   string // caller that started the run, see StartedBy
+	clientIP string
+}
+
+// StartedBy reports whether the caller with the given tenant user and address
+// started the run. A run started with a tenant token belongs to its user,
+// any other run to the address it was requested from
+func (r *ActiveRun) StartedBy(userID, clientIP string) bool {
+	if r.userID != "" {
+		return userID == r.userID
+	}
+	return clientIP != "" && clientIP == r.clientIP
+}
+
+// registerRun records an execution so it can be cancelled while running
+func (s *Service) registerRun(command string, opts ExecuteOptions, cancel context.CancelFunc) (*ActiveRun, error) {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	runID := opts.RunID
+	if runID == "" {
+		runID = uuid.New().String()
+	} else if _, exists := s.runs[runID]; exists {
+		return nil, fmt.Errorf("run ID already in use: %s", runID)
+	}
+
+	run := &ActiveRun{
+		RunID:     runID,
+		CommandID: opts.CommandID,
+		Command:   command,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+		userID:    opts.UserID,
+		clientIP:  opts.ClientIP,
+	}
+	s.runs[runID] = run
+	return run, nil
+}
+
+// unregisterRun removes a finished execution from the registry
+func (s *Service) unregisterRun(runID string) {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	delete(s.runs, runID)
+}
+
+// GetRun returns the execution with the given run ID if it is still in progress
+func (s *Service) GetRun(runID string) (*ActiveRun, bool) {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	run, exists := s.runs[runID]
+	return run, exists
+}
+
+// CancelRun cancels a running execution, terminating its child process
+func (s *Service) CancelRun(runID string) error {
+	s.runsMutex.Lock()
+	run, exists := s.runs[runID]
+	s.runsMutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("run not found: %s", runID)
+	}
+
+	s.logger.WithField("run_id", runID).Info("Cancelling command execution")
+	run.cancel()
+	return nil
+}
+
+// ListActiveRuns returns the executions currently in progress, oldest first
+func (s *Service) ListActiveRuns() []*ActiveRun {
+	s.runsMutex.Lock()
+	defer s.runsMutex.Unlock()
+
+	runs := make([]*ActiveRun, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestActiveRunStartedBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ExecuteOptions
+		userID   string
+		clientIP string
+		want     bool
+	}{
+		{"same tenant user", ExecuteOptions{UserID: "alice", ClientIP: "192.0.2.1"}, "alice", "192.0.2.9", true},
+		{"other tenant user at the same address", ExecuteOptions{UserID: "alice", ClientIP: "192.0.2.1"}, "bob", "192.0.2.1", false},
+		{"no token for a tenant's run", ExecuteOptions{UserID: "alice", ClientIP: "192.0.2.1"}, "", "192.0.2.1", false},
+		{"same address", ExecuteOptions{ClientIP: "192.0.2.1"}, "", "192.0.2.1", true},
+		{"same address with a token", ExecuteOptions{ClientIP: "192.0.2.1"}, "alice", "192.0.2.1", true},
+		{"other address", ExecuteOptions{ClientIP: "192.0.2.1"}, "", "192.0.2.2", false},
+		{"run without a caller", ExecuteOptions{}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			run, err := s.registerRun("true", tt.opts, func() {})
+			if err != nil {
+				t.Fatalf("register: %v", err)
+			}
+			if got := run.StartedBy(tt.userID, tt.clientIP); got != tt.want {
+				t.Fatalf("StartedBy(%q, %q) = %v, want %v", tt.userID, tt.clientIP, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRegistry(t *testing.T) {
+	s := newTestService()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	run, err := s.registerRun("sleep 1", ExecuteOptions{RunID: "run-1", CommandID: "sleep"}, cancel)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := s.registerRun("sleep 1", ExecuteOptions{RunID: "run-1"}, func() {}); err == nil {
+		t.Fatalf("registering a run ID in use succeeded")
+	}
+	if got, ok := s.GetRun("run-1"); !ok || got != run {
+		t.Fatalf("GetRun(run-1) = %v, %v", got, ok)
+	}
+	if err := s.CancelRun("run-1"); err != nil {
+		t.Fatalf("CancelRun: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("CancelRun did not cancel the run's context")
+	}
+
+	s.unregisterRun("run-1")
+	if _, ok := s.GetRun("run-1"); ok {
+		t.Fatalf("finished run is still registered")
+	}
+	if err := s.CancelRun("run-1"); err == nil {
+		t.Fatalf("cancelling a finished run succeeded")
+	}
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrExecutorBusy is returned when an execution's context ends while it
+// waits for one of the executor's slots
+var ErrExecutorBusy = errors.New("no execution slot available")
+
+// semaphore bounds how many executions run at once
+type semaphore struct {
+	slots chan struct{}
+}
+
+// newSemaphore returns a semaphore with limit slots; 0 or less is unlimited
+func newSemaphore(limit int) *semaphore {
+	if limit <= 0 {
+		return &semaphore{}
+	}
+	return &semaphore{slots: make(chan struct{}, limit)}
+}
+
+// acquire waits for a free slot, returning the function that releases it,
+// or the context's error if it ends first. Waiters are served in arrival order
+func (s *semaphore) acquire(ctx context.Context) (func(), error) {
+	if s == nil || s.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SetMaxConcurrent limits how many commands run at once across all callers
+// and tenants; further executions queue for a free slot. 0 means unlimited
+func (s *Service) SetMaxConcurrent(limit int) {
+	s.slots = newSemaphore(limit)
+}
+
+// acquireSlot waits for a free executor-wide slot
+func (s *Service) acquireSlot(ctx context.Context) (func(), error) {
+	release, err := s.slots.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExecutorBusy, err)
+	}
+	return release, nil
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreBoundsConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		workers int
+		wantMax int32
+	}{
+		{"one at a time", 1, 5, 1},
+		{"limit below workers", 3, 8, 3},
+		{"limit above workers", 10, 4, 4},
+		{"unlimited", 0, 6, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sem := newSemaphore(tt.limit)
+			var running, peak atomic.Int32
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < tt.workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					release, err := sem.acquire(context.Background())
+					if err != nil {
+						t.Errorf("acquire: %v", err)
+						return
+					}
+					defer release()
+					n := running.Add(1)
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+					time.Sleep(20 * time.Millisecond)
+					running.Add(-1)
+				}()
+			}
+			close(start)
+			wg.Wait()
+			if got := peak.Load(); got != tt.wantMax {
+				t.Fatalf("peak concurrency = %d, want %d", got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestExecuteWaitsForExecutorSlot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	s := newTestService()
+	s.SetMaxConcurrent(1)
+
+	// Hold the only slot, as a running command would
+	release, err := s.acquireSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Execute(ctx, "true"); !errors.Is(err, ErrExecutorBusy) {
+		t.Fatalf("Execute() while the slot is held = %v, want ErrExecutorBusy", err)
+	}
+
+	release()
+	result, err := s.Execute(context.Background(), "true")
+	if err != nil || !result.Success {
+		t.Fatalf("Execute() after release = %+v, %v", result, err)
+	}
+}
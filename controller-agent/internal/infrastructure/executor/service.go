@@ -1,78 +1,459 @@
 package executor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 )
 
 type Service struct {
-	logger *logrus.Logger
+	logger    *logrus.Logger
+	runs      map[string]*ActiveRun
+	runsMutex sync.Mutex
+
+	publisher  ResultPublisher
+	publishAll bool
+	recorders  []ExecutionRecorder
+
+	diagnostics DiagnosticResolver
+
+	dependencies        DependencyResolver
+	dependencyFreshness time.Duration
+
+	summaryHead int
+	summaryTail int
+
+	loginShell bool
+
+	blockedPatterns []string
+
+	maxStdinSize int
+	maxOutput    int
+
+	killGrace time.Duration
+
+	signingKey []byte
+
+	slots *semaphore
+
+	tenantLimit int
+	tenantSlots map[string]*semaphore
+	tenantMutex sync.Mutex
+
+	deviceID string
+}
+
+// ErrStdinTooLarge is returned when the provided stdin exceeds the configured limit
+var ErrStdinTooLarge = errors.New("stdin exceeds maximum size")
+
+// ResultPublisher receives execution results for commands that publish them
+type ResultPublisher interface {
+	PublishResult(commandID string, result *ExecutionResult)
+}
+
+// ExecutionRecorder receives every completed execution, e.g. for auditing
+type ExecutionRecorder interface {
+	RecordExecution(opts ExecuteOptions, result *ExecutionResult)
 }
 
 type ExecutionResult struct {
+	RunID         string        `json:"run_id"`
+	CommandID     string        `json:"command_id,omitempty"`
+	Cancelled     bool          `json:"cancelled"`
 	Success       bool          `json:"success"`
 	Output        string        `json:"output"`
+	OmittedLines  int           `json:"omitted_lines,omitempty"`
+	Truncated     bool          `json:"truncated,omitempty"` // output exceeded the size cap
+	Killed        bool          `json:"killed,omitempty"`    // stopped by the agent on timeout or cancellation
+	KilledBy      string        `json:"killed_by,omitempty"` // KilledBySIGTERM, or KilledBySIGKILL once the grace period ran out
 	Error         string        `json:"error"`
 	ExitCode      int           `json:"exit_code"`
 	ExecutionTime time.Duration `json:"execution_time"`
+	Signature     string        `json:"signature,omitempty"` // see VerifyResult
+	PreHook       *HookResult   `json:"pre_hook,omitempty"`
+	PostHook      *HookResult   `json:"post_hook,omitempty"`
+
+	Attempts       int             `json:"attempts"`                  // executions of the command, more than one when retried
+	FailedAttempts []AttemptResult `json:"failed_attempts,omitempty"` // attempts before the last, oldest first
+
+	Diagnostic *DiagnosticResult `json:"diagnostic,omitempty"` // set when a failure ran OnFailureDiagnostic
+
+	// Stdout parsed per ExecuteOptions.OutputFormat: the decoded JSON value or
+	// the lines as []string. When parsing fails Data is nil and OutputWarning says why
+	Data          interface{} `json:"data,omitempty"`
+	OutputWarning string      `json:"output_warning,omitempty"`
+}
+
+// AttemptResult is the outcome of a failed attempt that was retried
+type AttemptResult struct {
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+}
+
+// HookResult is the outcome of a command's pre- or post-execution hook
+type HookResult struct {
+	Success  bool   `json:"success"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// ExitCodeEnv passes the main command's exit code to its post-hook
+const ExitCodeEnv = "LAZYCTRL_EXIT_CODE"
+
+// ExecuteOptions holds per-command process settings
+type ExecuteOptions struct {
+	WorkingDir string            // empty means the agent's working directory
+	Env        map[string]string // merged over the agent's environment
+	RunID      string            // optional caller-chosen run ID, generated when empty
+	CommandID  string            // command ID reported in the active run list
+	Publish    bool              // publish the result even if not publishing globally
+	LoginShell bool              // run through a login shell even if not enabled globally
+	Shell      string            // one of the common.Shell* names, empty for the platform default
+	Args       []string          // program and arguments executed directly when Shell is common.ShellNone
+	Stdin      []byte            // written to the process's stdin, which is then closed
+	Tenant     string            // device or user the command belongs to, see SetTenantConcurrency
+	UserID     string            // caller's user ID, exposed as entity.ContextEnvUserID
+	Category   string            // command category, for execution recorders
+
+	// OnOutput receives the command's output as it is produced, stream being
+	// StreamStdout or StreamStderr. It may be called from two goroutines at
+	// once, and data must not be kept after it returns
+	OnOutput func(stream string, data []byte)
+
+	PreHook     string // run first; the command is skipped if it fails
+	PostHook    string // run last with ExitCodeEnv set
+	StrictHooks bool   // a failing post-hook fails the execution
+
+	OnFailureDiagnostic string // ID of a command run when the execution fails, see SetDiagnosticResolver
+
+	DependsOn   []string // IDs of commands that must have succeeded recently, see SetDependencyResolver
+	AutoRunDeps bool     // run unsatisfied dependencies first instead of failing
+	dependents  []string // commands whose dependencies are being run, innermost last
+
+	OutputFormat string // common.OutputFormat* name; json and lines parse stdout into ExecutionResult.Data
+
+	Retry *entity.RetryConfig // retries failed attempts; nil runs the command once
+
+	Source      string // interface the request arrived on, for auditing
+	ClientIP    string // requesting client, for auditing
+	RequiresPin bool   // whether the command required a PIN, for auditing
 }
 
 func NewService(logger *logrus.Logger) *Service {
 	return &Service{
-		logger: logger,
+		logger:    logger,
+		runs:      make(map[string]*ActiveRun),
+		maxOutput: common.MaxCommandOutputSize,
+		killGrace: common.DefaultKillGracePeriod,
+
+		blockedPatterns: common.DefaultBlockedPatterns,
+	}
+}
+
+// SetBlockedPatterns replaces the dangerous command fragments ValidateCommand rejects
+func (s *Service) SetBlockedPatterns(patterns []string) {
+	s.blockedPatterns = patterns
+}
+
+// SetResultPublisher registers where results are published; publishAll publishes
+// every command's result, otherwise only those executed with Publish set
+func (s *Service) SetResultPublisher(publisher ResultPublisher, publishAll bool) {
+	s.publisher = publisher
+	s.publishAll = publishAll
+}
+
+// AddExecutionRecorder registers a recorder notified of every completed execution
+func (s *Service) AddExecutionRecorder(recorder ExecutionRecorder) {
+	s.recorders = append(s.recorders, recorder)
+}
+
+// SetMaxStdinSize limits the stdin accepted per execution in bytes; 0 means unlimited
+func (s *Service) SetMaxStdinSize(size int) {
+	s.maxStdinSize = size
+}
+
+// SetLoginShell runs every command through a login shell so the user's
+// profile is sourced; this costs a profile load per execution
+func (s *Service) SetLoginShell(enabled bool) {
+	s.loginShell = enabled
+	if enabled {
+		s.logger.Warn("Login shell execution enabled for all commands; sourcing the profile slows every execution")
 	}
 }
 
 func (s *Service) Execute(ctx context.Context, command string) (*ExecutionResult, error) {
+	return s.ExecuteWithOptions(ctx, command, ExecuteOptions{})
+}
+
+func (s *Service) ExecuteWithOptions(ctx context.Context, command string, opts ExecuteOptions) (*ExecutionResult, error) {
+	if err := validateWorkingDir(opts.WorkingDir); err != nil {
+		return nil, err
+	}
+	if s.maxStdinSize > 0 && len(opts.Stdin) > s.maxStdinSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrStdinTooLarge, len(opts.Stdin), s.maxStdinSize)
+	}
+	if err := validateShell(opts.Shell, opts.Args); err != nil {
+		return nil, err
+	}
+	
+	// Dependencies run before taking a tenant slot, which they may need themselves
+	if len(opts.DependsOn) > 0 && s.dependencies != nil {
+		if err := s.checkDependencies(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Wait for a slot of the command's tenant; time spent queued is not execution time
+	release, err := s.acquireTenantSlot(ctx, opts.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	
+	// Then for an executor-wide slot, so a tenant's queued executions don't hold one
+	releaseSlot, err := s.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseSlot()
+	
 	startTime := time.Now()
 	
+	// Register the run so it can be cancelled while in progress
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	run, err := s.registerRun(command, opts, cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unregisterRun(run.RunID)
+	
 	s.logger.WithFields(logrus.Fields{
-		"command":  command,
-		"platform": runtime.GOOS,
+		"run_id":      run.RunID,
+		"command":     command,
+		"platform":    runtime.GOOS,
+		"working_dir": opts.WorkingDir,
 	}).Info("Executing command")
 
-	cmd := s.prepareCommand(ctx, command)
-	
-	output, err := cmd.CombinedOutput()
-	executionTime := time.Since(startTime)
+	if s.loginShell {
+		opts.LoginShell = true
+	}
 	
 	result := &ExecutionResult{
-		Success:       err == nil,
-		Output:        string(output),
-		ExecutionTime: executionTime,
+		RunID:     run.RunID,
+		CommandID: opts.CommandID,
 	}
-
-	if err != nil {
+	
+	if opts.PreHook != "" {
+		result.PreHook = s.runHook(runCtx, opts.PreHook, opts, nil)
+	}
+	
+	output := &cappedBuffer{limit: s.maxOutput}
+	if result.PreHook != nil && !result.PreHook.Success {
+		// A failing pre-hook aborts the command
+		err = fmt.Errorf("pre-hook failed: %s", result.PreHook.Error)
 		result.Error = err.Error()
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
+		result.ExitCode = result.PreHook.ExitCode
+	} else {
+		// Failed attempts are retried per the command's retry policy; the
+		// result describes the last attempt
+		for {
+			output = &cappedBuffer{limit: s.maxOutput}
+			err = s.runAttempt(runCtx, run.RunID, command, opts, output, result)
+			result.Attempts++
+			if err == nil || runCtx.Err() != nil || !opts.Retry.Retryable(result.Attempts, result.ExitCode) {
+				break
+			}
+			result.FailedAttempts = append(result.FailedAttempts, AttemptResult{
+				ExitCode: result.ExitCode,
+				Error:    result.Error,
+			})
+			
+			delay := opts.Retry.DelayAfter(result.Attempts)
+			s.logger.WithFields(logrus.Fields{
+				"run_id":    run.RunID,
+				"attempt":   result.Attempts,
+				"exit_code": result.ExitCode,
+				"delay":     delay,
+			}).Warn("Command failed, retrying")
+			
+			select {
+			case <-time.After(delay):
+			case <-runCtx.Done():
+			}
+			if runCtx.Err() != nil {
+				break
+			}
+		}
+		
+		// Cancelled by CancelRun rather than by the caller's context
+		if err != nil && runCtx.Err() == context.Canceled && ctx.Err() == nil {
+			result.Cancelled = true
+			result.Error = "execution cancelled"
 		}
 		
+		if opts.PostHook != "" {
+			result.PostHook = s.runHook(runCtx, opts.PostHook, opts, map[string]string{
+				ExitCodeEnv: strconv.Itoa(result.ExitCode),
+			})
+			if !result.PostHook.Success {
+				s.logger.WithFields(logrus.Fields{
+					"run_id":    run.RunID,
+					"hook":      opts.PostHook,
+					"error":     result.PostHook.Error,
+					"exit_code": result.PostHook.ExitCode,
+				}).Warn("Post-hook failed")
+				
+				if opts.StrictHooks && err == nil {
+					err = fmt.Errorf("post-hook failed: %s", result.PostHook.Error)
+					result.Success = false
+					result.Error = err.Error()
+					result.ExitCode = result.PostHook.ExitCode
+				}
+			}
+		}
+	}
+	executionTime := time.Since(startTime)
+	result.ExecutionTime = executionTime
+	
+	// A cancelled run was stopped on purpose, so there is nothing to diagnose
+	if err != nil && !result.Cancelled && opts.OnFailureDiagnostic != "" && s.diagnostics != nil {
+		result.Diagnostic = s.runDiagnostic(opts.CommandID, opts.OnFailureDiagnostic)
+	}
+	
+	s.signResult(result)
+	
+	if err != nil {
 		s.logger.WithFields(logrus.Fields{
 			"command":        command,
 			"error":          err.Error(),
-			"output":         string(output),
+			"output":         output.String(),
 			"execution_time": executionTime,
 			"exit_code":      result.ExitCode,
+			"signature":      result.Signature,
 		}).Error("Command execution failed")
 	} else {
 		s.logger.WithFields(logrus.Fields{
 			"command":        command,
 			"execution_time": executionTime,
+			"signature":      result.Signature,
 		}).Info("Command executed successfully")
 	}
 
+	if s.publisher != nil && opts.CommandID != "" && (s.publishAll || opts.Publish) {
+		s.publisher.PublishResult(opts.CommandID, result)
+	}
+	for _, recorder := range s.recorders {
+		recorder.RecordExecution(opts, result)
+	}
+
 	return result, nil
 }
 
+// runAttempt runs the command once and sets the outcome fields of result
+func (s *Service) runAttempt(ctx context.Context, runID, command string, opts ExecuteOptions, output *cappedBuffer, result *ExecutionResult) error {
+	cmd := s.prepareCommand(ctx, command, opts)
+	if opts.OnOutput != nil {
+		cmd.Stdout = &streamWriter{output: output, stream: StreamStdout, onOutput: opts.OnOutput}
+		cmd.Stderr = &streamWriter{output: output, stream: StreamStderr, onOutput: opts.OnOutput}
+	} else {
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	// Structured formats parse stdout alone so stderr noise cannot break them
+	var stdout *cappedBuffer
+	if parsesOutput(opts.OutputFormat) {
+		stdout = &cappedBuffer{limit: s.maxOutput}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+	}
+	killedBy, err := s.runProcess(cmd)
+	
+	result.Killed = killedBy != ""
+	result.KilledBy = killedBy
+	result.Success = err == nil
+	result.Output, result.OmittedLines = summarizeOutput(output.String(), s.summaryHead, s.summaryTail)
+	result.Truncated = output.truncated
+	result.Data, result.OutputWarning = nil, ""
+	if stdout != nil {
+		result.Data, result.OutputWarning = parseOutput(opts.OutputFormat, stdout)
+		if result.OutputWarning != "" {
+			s.logger.WithFields(logrus.Fields{
+				"run_id":  runID,
+				"format":  opts.OutputFormat,
+				"warning": result.OutputWarning,
+			}).Warn("Falling back to raw command output")
+		}
+	}
+	
+	result.Error, result.ExitCode = "", 0
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = exitCode(err)
+	}
+	return err
+}
+
+// runHook runs a pre- or post-execution hook with the command's working
+// directory and environment plus extraEnv. Hooks do not receive stdin
+func (s *Service) runHook(ctx context.Context, hook string, opts ExecuteOptions, extraEnv map[string]string) *HookResult {
+	if len(extraEnv) > 0 {
+		env := make(map[string]string, len(opts.Env)+len(extraEnv))
+		for key, value := range opts.Env {
+			env[key] = value
+		}
+		for key, value := range extraEnv {
+			env[key] = value
+		}
+		opts.Env = env
+	}
+	opts.Stdin = nil
+	// Hooks are shell strings even when the command itself is executed directly
+	if opts.Shell == common.ShellNone {
+		opts.Shell = ""
+	}
+	opts.Args = nil
+	
+	cmd := s.prepareCommand(ctx, hook, opts)
+	output := &cappedBuffer{limit: s.maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	_, err := s.runProcess(cmd)
+	
+	result := &HookResult{
+		Success: err == nil,
+		Output:  output.String(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = exitCode(err)
+	}
+	return result
+}
+
+// exitCode returns the process exit code for a failed run, or 1 if it never exited
+func exitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
 func (s *Service) ExecuteWithTimeout(command string, timeout time.Duration) (*ExecutionResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -80,9 +461,64 @@ func (s *Service) ExecuteWithTimeout(command string, timeout time.Duration) (*Ex
 	return s.Execute(ctx, command)
 }
 
-func (s *Service) prepareCommand(ctx context.Context, command string) *exec.Cmd {
+func (s *Service) prepareCommand(ctx context.Context, command string, opts ExecuteOptions) *exec.Cmd {
+	// 命令级环境变量覆盖继承的进程环境变量，上下文变量最后设置，不可被覆盖
+	execEnv := make(map[string]string, len(opts.Env)+len(entity.ContextVariables))
+	for key, value := range opts.Env {
+		execEnv[key] = value
+	}
+	for key, value := range s.contextEnv(opts) {
+		execEnv[key] = value
+	}
+	
 	var cmd *exec.Cmd
+	templated := false
+	if opts.Shell != common.ShellNone {
+		command, templated = renderTemplate(command, shellReference(opts.Shell, command))
+	}
+	
+	switch opts.Shell {
+	case common.ShellNone:
+		// 直接执行参数数组，不经过任何shell解析，模板变量直接替换为值
+		args := make([]string, len(opts.Args))
+		for i, arg := range opts.Args {
+			args[i], _ = renderTemplate(arg, func(env string, _ byte) string { return execEnv[env] })
+		}
+		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	case common.ShellSh, common.ShellBash:
+		if opts.LoginShell {
+			cmd = exec.CommandContext(ctx, opts.Shell, "-lc", command)
+		} else {
+			cmd = exec.CommandContext(ctx, opts.Shell, "-c", command)
+		}
+	case common.ShellCmd:
+		cmd = exec.CommandContext(ctx, "cmd", cmdArgs(command, templated)...)
+	case common.ShellPowerShell:
+		cmd = exec.CommandContext(ctx, powerShell(), "-Command", command)
+	default:
+		cmd = defaultShellCommand(ctx, command, opts.LoginShell, templated)
+	}
+	
+	if opts.WorkingDir != "" {
+		cmd.Dir = opts.WorkingDir
+	}
 	
+	if len(opts.Stdin) > 0 {
+		cmd.Stdin = bytes.NewReader(opts.Stdin)
+	}
+	
+	env := os.Environ()
+	for key, value := range execEnv {
+		env = append(env, key+"="+value)
+	}
+	cmd.Env = env
+	
+	return cmd
+}
+
+// defaultShellCommand runs command through the platform's default shell, the
+// behavior of commands that do not declare a shell
+func defaultShellCommand(ctx context.Context, command string, login, templated bool) *exec.Cmd {
 	if runtime.GOOS == "windows" {
 		if strings.HasPrefix(command, "powershell") {
 			// 解析PowerShell命令参数
@@ -90,18 +526,75 @@ func (s *Service) prepareCommand(ctx context.Context, command string) *exec.Cmd
 			if len(parts) >= 3 && parts[1] == "-c" {
 				// 去掉外层引号
 				script := strings.Trim(parts[2], "\"")
-				cmd = exec.CommandContext(ctx, "powershell", "-Command", script)
-			} else {
-				cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+				return exec.CommandContext(ctx, "powershell", "-Command", script)
 			}
-		} else {
-			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
 		}
-	} else {
-		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		return exec.CommandContext(ctx, "cmd", cmdArgs(command, templated)...)
+	}
+	if login {
+		// 登录shell会加载用户profile(PATH、别名等)
+		return exec.CommandContext(ctx, loginShell(), "-lc", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// cmdArgs returns cmd's arguments for running command. Templated commands
+// reference variables as !NAME!, which needs delayed expansion
+func cmdArgs(command string, templated bool) []string {
+	if templated {
+		return []string{"/V:ON", "/C", command}
+	}
+	return []string{"/C", command}
+}
+
+// powerShell returns the PowerShell executable, which is pwsh outside Windows
+func powerShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "pwsh"
+}
+
+// validateShell checks that shell is known and that direct execution has a program
+func validateShell(shell string, args []string) error {
+	switch shell {
+	case "", common.ShellSh, common.ShellBash, common.ShellCmd, common.ShellPowerShell:
+		return nil
+	case common.ShellNone:
+		if len(args) == 0 || args[0] == "" {
+			return fmt.Errorf("shell %q requires args with the program to execute", shell)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported shell %q", shell)
+}
+
+// loginShell returns the shell used for login execution, preferring bash
+func loginShell() string {
+	if _, err := exec.LookPath("bash"); err == nil {
+		return "bash"
+	}
+	return "sh"
+}
+
+// validateWorkingDir checks that the configured working directory exists
+func validateWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
 	}
 	
-	return cmd
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("working directory does not exist: %s", dir)
+		}
+		return fmt.Errorf("cannot access working directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("working directory is not a directory: %s", dir)
+	}
+	
+	return nil
 }
 
 func (s *Service) ValidateCommand(command string) error {
@@ -110,18 +603,10 @@ func (s *Service) ValidateCommand(command string) error {
 	}
 
 	// 基本安全检查 - 防止危险命令
-	dangerousPatterns := []string{
-		"rm -rf /",
-		"del /s /q C:\\",
-		"format c:",
-		"mkfs.",
-		"fdisk",
-	}
-
 	lowerCmd := strings.ToLower(command)
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(lowerCmd, strings.ToLower(pattern)) {
-			return fmt.Errorf("potentially dangerous command detected: %s", pattern)
+	for _, pattern := range s.blockedPatterns {
+		if pattern != "" && strings.Contains(lowerCmd, strings.ToLower(pattern)) {
+			return fmt.Errorf("%w: %s", common.ErrCommandDangerous, pattern)
 		}
 	}
 
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestValidateShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		shell   string
+		args    []string
+		wantErr string
+	}{
+		{"platform default", "", nil, ""},
+		{"sh", common.ShellSh, nil, ""},
+		{"bash", common.ShellBash, nil, ""},
+		{"cmd", common.ShellCmd, nil, ""},
+		{"powershell", common.ShellPowerShell, nil, ""},
+		{"none with args", common.ShellNone, []string{"echo", "hi"}, ""},
+		{"none without args", common.ShellNone, nil, "requires args"},
+		{"none with empty program", common.ShellNone, []string{"", "hi"}, "requires args"},
+		{"unknown shell", "zsh", nil, `unsupported shell "zsh"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateShell(tt.shell, tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateShell() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateShell() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrepareCommandShell(t *testing.T) {
+	// Quotes and spaces must reach the shell as one script, not split into words
+	const script = `Write-Output "two words" 'single' "it's"`
+	defaultArgs := []string{"sh", "-c", script}
+	if runtime.GOOS == "windows" {
+		defaultArgs = []string{"cmd", "/C", script}
+	}
+
+	tests := []struct {
+		name string
+		opts ExecuteOptions
+		want []string
+	}{
+		{"platform default", ExecuteOptions{}, defaultArgs},
+		{"sh", ExecuteOptions{Shell: common.ShellSh}, []string{"sh", "-c", script}},
+		{"sh login", ExecuteOptions{Shell: common.ShellSh, LoginShell: true}, []string{"sh", "-lc", script}},
+		{"bash", ExecuteOptions{Shell: common.ShellBash}, []string{"bash", "-c", script}},
+		{"cmd", ExecuteOptions{Shell: common.ShellCmd}, []string{"cmd", "/C", script}},
+		{"powershell", ExecuteOptions{Shell: common.ShellPowerShell}, []string{powerShell(), "-Command", script}},
+		{"none", ExecuteOptions{Shell: common.ShellNone, Args: []string{"printf", "%s", `"quoted arg"`, "two words"}}, []string{"printf", "%s", `"quoted arg"`, "two words"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newTestService().prepareCommand(context.Background(), script, tt.opts)
+			if !reflect.DeepEqual(cmd.Args, tt.want) {
+				t.Errorf("args = %q, want %q", cmd.Args, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQuotedArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses printf and sh")
+	}
+	tests := []struct {
+		name    string
+		command string
+		opts    ExecuteOptions
+		want    string
+	}{
+		{
+			name:    "embedded quotes through sh",
+			command: `printf '%s|' "say \"hi\"" 'two words' "it's"`,
+			opts:    ExecuteOptions{Shell: common.ShellSh},
+			want:    `say "hi"|two words|it's|`,
+		},
+		{
+			name: "no shell interpretation",
+			opts: ExecuteOptions{Shell: common.ShellNone, Args: []string{"printf", "%s|", `say "hi"`, "two words", "$HOME", "; echo injected", "*"}},
+			want: `say "hi"|two words|$HOME|; echo injected|*|`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newTestService().ExecuteWithOptions(context.Background(), tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("ExecuteWithOptions: %v", err)
+			}
+			if !result.Success || result.Output != tt.want {
+				t.Errorf("success %v, output %q; want %q", result.Success, result.Output, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteRejectsInvalidShell(t *testing.T) {
+	tests := []ExecuteOptions{
+		{Shell: "zsh"},
+		{Shell: common.ShellNone},
+	}
+	for _, opts := range tests {
+		if _, err := newTestService().ExecuteWithOptions(context.Background(), "echo hi", opts); err == nil {
+			t.Errorf("ExecuteWithOptions(shell %q, args %q) succeeded, want an error", opts.Shell, opts.Args)
+		}
+	}
+}
+
+func TestDryRunShell(t *testing.T) {
+	s := newTestService()
+	preview := s.DryRun("", ExecuteOptions{Shell: common.ShellNone, Args: []string{"echo", "a b"}})
+	if !reflect.DeepEqual(preview.Args, []string{"echo", "a b"}) || preview.Shell != common.ShellNone {
+		t.Errorf("dry run = %+v, want the args executed directly", preview)
+	}
+
+	preview = s.DryRun("echo hi", ExecuteOptions{Shell: "zsh"})
+	if preview.Args != nil || len(preview.Warnings) == 0 || !strings.Contains(strings.Join(preview.Warnings, "\n"), "unsupported shell") {
+		t.Errorf("dry run = %+v, want an unsupported shell warning and no args", preview)
+	}
+}
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// signedResult lists the result fields covered by a signature. They are the
+// fields every execute response carries, so clients can verify responses
+type signedResult struct {
+	RunID     string `json:"runId"`
+	CommandID string `json:"commandId"`
+	Success   bool   `json:"success"`
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"`
+	Error     string `json:"error"`
+}
+
+// SetSigningKey enables HMAC-SHA256 signing of execution results; an empty key disables it
+func (s *Service) SetSigningKey(key []byte) {
+	s.signingKey = key
+}
+
+// signResult sets the result's signature when signing is enabled
+func (s *Service) signResult(result *ExecutionResult) {
+	if len(s.signingKey) == 0 {
+		return
+	}
+	result.Signature = ResultSignature(s.signingKey, result)
+}
+
+// ResultSignature returns the hex HMAC-SHA256 of the result's signed fields:
+// runId, commandId, success, exitCode, output and error, encoded as a JSON
+// object in that order
+func ResultSignature(key []byte, result *ExecutionResult) string {
+	payload, _ := json.Marshal(signedResult{
+		RunID:     result.RunID,
+		CommandID: result.CommandID,
+		Success:   result.Success,
+		ExitCode:  result.ExitCode,
+		Output:    result.Output,
+		Error:     result.Error,
+	})
+	
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResult reports whether the result's signature matches its signed fields
+func VerifyResult(key []byte, result *ExecutionResult) bool {
+	expected, err := hex.DecodeString(ResultSignature(key, result))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(result.Signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestVerifyResult(t *testing.T) {
+	key := []byte("signing-key")
+	signed := func() *ExecutionResult {
+		result := &ExecutionResult{RunID: "run-1", CommandID: "uptime", Success: true, ExitCode: 0, Output: "up 3 days"}
+		result.Signature = ResultSignature(key, result)
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(*ExecutionResult)
+		key    []byte
+		want   bool
+	}{
+		{"untouched", func(*ExecutionResult) {}, key, true},
+		{"run ID changed", func(r *ExecutionResult) { r.RunID = "run-2" }, key, false},
+		{"command ID changed", func(r *ExecutionResult) { r.CommandID = "shutdown" }, key, false},
+		{"success flipped", func(r *ExecutionResult) { r.Success = false }, key, false},
+		{"exit code changed", func(r *ExecutionResult) { r.ExitCode = 1 }, key, false},
+		{"output changed", func(r *ExecutionResult) { r.Output = "up 4 days" }, key, false},
+		{"error added", func(r *ExecutionResult) { r.Error = "failed" }, key, false},
+		{"signature replaced", func(r *ExecutionResult) { r.Signature = ResultSignature([]byte("other-key"), r) }, key, false},
+		{"signature not hex", func(r *ExecutionResult) { r.Signature = "not-hex" }, key, false},
+		{"signature missing", func(r *ExecutionResult) { r.Signature = "" }, key, false},
+		{"wrong key", func(*ExecutionResult) {}, []byte("other-key"), false},
+		{"unsigned field changed", func(r *ExecutionResult) { r.ExecutionTime = time.Hour }, key, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := signed()
+			tt.tamper(result)
+			if got := VerifyResult(tt.key, result); got != tt.want {
+				t.Errorf("VerifyResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteSignsResult(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs sh")
+	}
+	key := []byte("signing-key")
+
+	tests := []struct {
+		name    string
+		key     []byte
+		command string
+		signed  bool
+	}{
+		{"success", key, "echo signed", true},
+		{"failure", key, "echo failed; exit 3", true},
+		{"signing disabled", nil, "echo unsigned", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			s.SetSigningKey(tt.key)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			result, _ := s.ExecuteWithOptions(ctx, tt.command, ExecuteOptions{Shell: common.ShellSh, CommandID: "signed"})
+			if result == nil {
+				t.Fatal("no result")
+			}
+
+			if !tt.signed {
+				if result.Signature != "" {
+					t.Errorf("signature = %q, want none", result.Signature)
+				}
+				return
+			}
+			if !VerifyResult(key, result) {
+				t.Fatalf("signature %q does not verify for %+v", result.Signature, result)
+			}
+			result.Output += "tampered"
+			if VerifyResult(key, result) {
+				t.Error("signature still verifies after the output was tampered with")
+			}
+		})
+	}
+}
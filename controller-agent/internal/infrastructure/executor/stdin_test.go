@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// echoStdin is a command writing its stdin to stdout
+func echoStdin() string {
+	if runtime.GOOS == "windows" {
+		return `findstr "^"`
+	}
+	return "cat"
+}
+
+func TestExecuteStdin(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		stdin      string
+		wantOutput string
+		wantErr    error
+	}{
+		{"no stdin", 0, "", "", nil},
+		{"piped", 0, "hello stdin\n", "hello stdin", nil},
+		{"several lines", 0, "one\ntwo\nthree\n", "one\ntwo\nthree", nil},
+		{"at the limit", 8, "12345678", "12345678", nil},
+		{"over the limit", 8, "123456789", "", ErrStdinTooLarge},
+		{"unlimited", 0, strings.Repeat("x", 1<<16), strings.Repeat("x", 1<<16), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			s.SetMaxStdinSize(tt.limit)
+
+			result, err := s.ExecuteWithOptions(context.Background(), echoStdin(), ExecuteOptions{Stdin: []byte(tt.stdin)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := strings.TrimRight(strings.ReplaceAll(result.Output, "\r\n", "\n"), "\n"); got != tt.wantOutput {
+				t.Errorf("output = %.40q (%d bytes), want %.40q", got, len(got), tt.wantOutput)
+			}
+			// A closed pipe ends cat instead of leaving it waiting for input
+			if !result.Success {
+				t.Errorf("execution failed: %s", result.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetOutputSummary limits returned output to the first headLines and last
+// tailLines lines; zero for both disables summarization
+func (s *Service) SetOutputSummary(headLines, tailLines int) {
+	if headLines < 0 {
+		headLines = 0
+	}
+	if tailLines < 0 {
+		tailLines = 0
+	}
+	s.summaryHead = headLines
+	s.summaryTail = tailLines
+}
+
+// summarizeOutput keeps the first head and last tail lines of output and
+// replaces the rest with a marker, returning the number of omitted lines
+func summarizeOutput(output string, head, tail int) (string, int) {
+	if head == 0 && tail == 0 {
+		return output, 0
+	}
+	
+	trailingNewline := strings.HasSuffix(output, "\n")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) <= head+tail {
+		return output, 0
+	}
+	
+	omitted := len(lines) - head - tail
+	kept := make([]string, 0, head+tail+1)
+	kept = append(kept, lines[:head]...)
+	kept = append(kept, fmt.Sprintf("... [%d lines omitted] ...", omitted))
+	kept = append(kept, lines[len(lines)-tail:]...)
+	
+	summary := strings.Join(kept, "\n")
+	if trailingNewline {
+		summary += "\n"
+	}
+	return summary, omitted
+}
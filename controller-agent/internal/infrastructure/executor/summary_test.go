@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns "1\n2\n...n\n"
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestSummarizeOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		head, tail  int
+		want        string
+		wantOmitted int
+	}{
+		{"disabled", numberedLines(10), 0, 0, numberedLines(10), 0},
+		{"short output is kept", numberedLines(4), 2, 2, numberedLines(4), 0},
+		{"head and tail", numberedLines(10), 2, 3, "1\n2\n... [5 lines omitted] ...\n8\n9\n10\n", 5},
+		{"head only", numberedLines(5), 2, 0, "1\n2\n... [3 lines omitted] ...\n", 3},
+		{"tail only", numberedLines(5), 0, 1, "... [4 lines omitted] ...\n5\n", 4},
+		{"one line over", numberedLines(5), 2, 2, "1\n2\n... [1 lines omitted] ...\n4\n5\n", 1},
+		{"no trailing newline", "a\nb\nc\nd", 1, 1, "a\n... [2 lines omitted] ...\nd", 2},
+		{"empty", "", 1, 1, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, omitted := summarizeOutput(tt.output, tt.head, tt.tail)
+			if got != tt.want {
+				t.Fatalf("summarizeOutput() output = %q, want %q", got, tt.want)
+			}
+			if omitted != tt.wantOmitted {
+				t.Fatalf("summarizeOutput() omitted = %d, want %d", omitted, tt.wantOmitted)
+			}
+		})
+	}
+}
+
+func TestExecuteSummarizesOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	s := newTestService()
+	s.SetOutputSummary(2, 1)
+
+	result, err := s.Execute(context.Background(), "seq 1 6")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if want := "1\n2\n... [3 lines omitted] ...\n6\n"; result.Output != want {
+		t.Fatalf("output = %q, want %q", result.Output, want)
+	}
+	if result.OmittedLines != 3 {
+		t.Fatalf("omitted lines = %d, want 3", result.OmittedLines)
+	}
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTenantBusy is returned when an execution's context ends while it waits
+// for one of its tenant's execution slots
+var ErrTenantBusy = errors.New("no execution slot available for tenant")
+
+// SetTenantConcurrency limits how many commands of one tenant run at once;
+// further executions queue for a free slot. 0 means unlimited
+func (s *Service) SetTenantConcurrency(limit int) {
+	s.tenantMutex.Lock()
+	defer s.tenantMutex.Unlock()
+	
+	s.tenantLimit = limit
+	s.tenantSlots = make(map[string]*semaphore)
+}
+
+// acquireTenantSlot waits for a free slot of the tenant, returning the
+// function that releases it. A burst from one tenant only occupies that
+// tenant's slots
+func (s *Service) acquireTenantSlot(ctx context.Context, tenant string) (func(), error) {
+	s.tenantMutex.Lock()
+	if s.tenantLimit <= 0 {
+		s.tenantMutex.Unlock()
+		return func() {}, nil
+	}
+	slots, exists := s.tenantSlots[tenant]
+	if !exists {
+		slots = newSemaphore(s.tenantLimit)
+		s.tenantSlots[tenant] = slots
+	}
+	s.tenantMutex.Unlock()
+	
+	release, err := slots.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrTenantBusy, tenant, err)
+	}
+	return release, nil
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTenantBurstDoesNotBlockOtherTenants(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	s := newTestService()
+	// Queued executions of tenant a must not hold executor slots, or the
+	// second slot would be taken and the other tenants blocked
+	s.SetMaxConcurrent(2)
+	s.SetTenantConcurrency(1)
+
+	// Tenant a queues a burst of slow commands, one running at a time
+	var wg sync.WaitGroup
+	finished := make(chan time.Time, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := s.ExecuteWithOptions(context.Background(), "sleep 0.3", ExecuteOptions{Tenant: "a"})
+			if err != nil || !result.Success {
+				t.Errorf("tenant a: %+v, %v", result, err)
+			}
+			finished <- time.Now()
+		}()
+	}
+	waitForRuns(t, s, 1)
+
+	tests := []struct {
+		name   string
+		tenant string
+	}{
+		{"other tenant", "b"},
+		{"unowned commands", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			result, err := s.ExecuteWithOptions(ctx, "true", ExecuteOptions{Tenant: tt.tenant})
+			if err != nil || !result.Success {
+				t.Fatalf("executed behind tenant a's burst: %+v, %v", result, err)
+			}
+		})
+	}
+
+	t.Run("same tenant waits", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, err := s.ExecuteWithOptions(ctx, "true", ExecuteOptions{Tenant: "a"}); !errors.Is(err, ErrTenantBusy) {
+			t.Fatalf("err = %v, want ErrTenantBusy", err)
+		}
+	})
+
+	wg.Wait()
+	close(finished)
+	var last time.Time
+	for at := range finished {
+		if at.After(last) {
+			last = at
+		}
+	}
+	// One slot for three 0.3s commands
+	if took := last.Sub(start); took < 800*time.Millisecond {
+		t.Errorf("tenant a's burst took %s, want its commands to run one at a time", took)
+	}
+}
+
+func TestTenantConcurrencyUnlimited(t *testing.T) {
+	s := newTestService()
+	s.SetTenantConcurrency(0)
+	for i := 0; i < 3; i++ {
+		release, err := s.acquireTenantSlot(context.Background(), "a")
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		defer release()
+	}
+}
+
+// waitForRuns waits until n runs are active
+func waitForRuns(t *testing.T, s *Service, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ListActiveRuns()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d runs did not start", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestValidateCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    []string // nil keeps the defaults
+		command     string
+		wantErr     bool
+		wantBlocked bool
+	}{
+		{"safe command", nil, "ls -la /tmp", false, false},
+		{"empty command", nil, "  ", true, false},
+		{"default pattern", nil, "sudo rm -rf / --no-preserve-root", true, true},
+		{"default pattern, other case", nil, "FORMAT C: /q", true, true},
+		{"default pattern, windows path", nil, `del /s /q C:\Windows`, true, true},
+		{"custom patterns replace defaults", []string{"shutdown"}, "rm -rf /", false, false},
+		{"custom pattern", []string{"shutdown"}, "shutdown -h now", true, true},
+		{"extended defaults", append(append([]string(nil), common.DefaultBlockedPatterns...), "dd if="), "dd if=/dev/zero of=/dev/sda", true, true},
+		{"empty pattern ignored", []string{""}, "echo hi", false, false},
+		{"no patterns", []string{}, "rm -rf /", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			if tt.patterns != nil {
+				s.SetBlockedPatterns(tt.patterns)
+			}
+			err := s.ValidateCommand(tt.command)
+			if (err != nil) != tt.wantErr || errors.Is(err, common.ErrCommandDangerous) != tt.wantBlocked {
+				t.Errorf("ValidateCommand(%q) = %v, want error %v, blocked %v", tt.command, err, tt.wantErr, tt.wantBlocked)
+			}
+		})
+	}
+}
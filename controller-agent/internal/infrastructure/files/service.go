@@ -0,0 +1,215 @@
+// Package files moves files to and from the directories the agent is
+// configured to expose, keeping every path inside them
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// ChunkSize is how many bytes of a file each streamed chunk carries
+const ChunkSize = 64 * 1024
+
+// UploadResult describes a file written by Upload
+type UploadResult struct {
+	Path   string
+	Size   int64
+	SHA256 string // hex digest of the content
+}
+
+// Service reads and writes files within the allowed directories
+type Service struct {
+	roots         []string // absolute, symlinks resolved
+	maxUploadSize int64
+	logger        *logrus.Logger
+}
+
+// NewService resolves the allowed directories, which must exist
+func NewService(cfg config.FilesConfig, logger *logrus.Logger) (*Service, error) {
+	s := &Service{
+		maxUploadSize: cfg.MaxUploadSize,
+		logger:        logger,
+	}
+	for _, dir := range cfg.AllowedDirs {
+		root, err := filepath.Abs(dir)
+		if err == nil {
+			root, err = filepath.EvalSymlinks(root)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("files.allowed_dirs %q: %w", dir, err)
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("files.allowed_dirs %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("files.allowed_dirs %q is not a directory", dir)
+		}
+		s.roots = append(s.roots, root)
+	}
+	if len(s.roots) > 0 {
+		logger.WithField("dirs", s.roots).Info("File transfers enabled")
+	}
+	return s, nil
+}
+
+// Enabled reports whether any directory is allowed
+func (s *Service) Enabled() bool {
+	return len(s.roots) > 0
+}
+
+// MaxUploadSize is the largest file Upload accepts in bytes, 0 is unlimited
+func (s *Service) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+// Upload writes r to path, replacing an existing file only when overwrite is
+// set. The content goes to a temporary file first so a failed or oversized
+// upload never leaves a partial file behind
+func (s *Service) Upload(path string, r io.Reader, overwrite bool) (*UploadResult, error) {
+	target, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(target); err == nil {
+		if info.IsDir() {
+			return nil, fmt.Errorf("%w: %s is a directory", common.ErrInvalidFilePath, path)
+		}
+		if !overwrite {
+			return nil, common.ErrFileExists
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if s.maxUploadSize > 0 {
+		r = io.LimitReader(r, s.maxUploadSize+1)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if s.maxUploadSize > 0 && size > s.maxUploadSize {
+		return nil, fmt.Errorf("%w of %d bytes", common.ErrFileTooLarge, s.maxUploadSize)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return &UploadResult{
+		Path:   target,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Open opens the file at path for download. Symlinks are followed only as
+// long as they stay within the allowed directories
+func (s *Service) Open(path string) (*os.File, os.FileInfo, error) {
+	target, err := s.resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err = filepath.EvalSymlinks(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, common.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if !s.allowed(target) {
+		return nil, nil, common.ErrInvalidFilePath
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: %s is not a regular file", common.ErrInvalidFilePath, path)
+	}
+	return file, info, nil
+}
+
+// resolve maps path to an absolute path inside an allowed directory. Paths
+// containing ".." are rejected outright rather than cleaned, and the parent
+// directory's symlinks are resolved before checking it against the roots
+func (s *Service) resolve(path string) (string, error) {
+	if !s.Enabled() {
+		return "", common.ErrFilesDisabled
+	}
+	if path == "" {
+		return "", fmt.Errorf("%w: path is required", common.ErrInvalidFilePath)
+	}
+	for _, part := range strings.FieldsFunc(path, isSeparator) {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %s contains ..", common.ErrInvalidFilePath, path)
+		}
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.roots[0], path)
+	}
+	path = filepath.Clean(path)
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: directory does not exist", common.ErrFileNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if !s.allowed(target) {
+		return "", common.ErrInvalidFilePath
+	}
+	return target, nil
+}
+
+// allowed reports whether target lies below one of the roots
+func (s *Service) allowed(target string) bool {
+	for _, root := range s.roots {
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == "." {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == filepath.Separator
+}
@@ -0,0 +1,127 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// Mode is the agent's maintenance state
+type Mode struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"` // when maintenance was enabled
+}
+
+// Service holds the maintenance mode shared by every interface. While it is
+// enabled commands are not executed; everything else keeps working
+type Service struct {
+	path   string // empty keeps the mode in memory only
+	logger *logrus.Logger
+	mutex  sync.RWMutex
+	mode   Mode
+}
+
+// NewService creates the maintenance state, restoring the mode saved at path
+// when there is one
+func NewService(path string, logger *logrus.Logger) (*Service, error) {
+	s := &Service{path: path, logger: logger}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.mode); err != nil {
+		return nil, fmt.Errorf("invalid maintenance state %s: %w", path, err)
+	}
+
+	if s.mode.Enabled {
+		logger.WithField("reason", s.mode.Reason).Warn("Agent restarted in maintenance mode, commands will not be executed")
+	}
+	return s, nil
+}
+
+// SetMaintenanceMode enables or disables maintenance and saves the mode. The
+// reason is only kept while maintenance is enabled
+func (s *Service) SetMaintenanceMode(enabled bool, reason string) (Mode, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	mode := Mode{Enabled: enabled}
+	if enabled {
+		mode.Reason = reason
+		since := time.Now()
+		if s.mode.Enabled && s.mode.Since != nil {
+			since = *s.mode.Since
+		}
+		mode.Since = &since
+	}
+
+	if err := s.save(mode); err != nil {
+		return s.mode, err
+	}
+	s.mode = mode
+
+	s.logger.WithFields(logrus.Fields{
+		"enabled": enabled,
+		"reason":  mode.Reason,
+	}).Warn("Maintenance mode changed")
+	return mode, nil
+}
+
+// Mode returns the current maintenance mode
+func (s *Service) Mode() Mode {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.mode
+}
+
+// CheckExecution returns common.ErrMaintenanceMode, with the reason, while
+// maintenance is enabled
+func (s *Service) CheckExecution() error {
+	mode := s.Mode()
+	if !mode.Enabled {
+		return nil
+	}
+	if mode.Reason == "" {
+		return common.ErrMaintenanceMode
+	}
+	return fmt.Errorf("%w: %s", common.ErrMaintenanceMode, mode.Reason)
+}
+
+// save writes mode to the state file, replacing it atomically
+func (s *Service) save(mode Mode) error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create maintenance state directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save maintenance state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save maintenance state: %w", err)
+	}
+	return nil
+}
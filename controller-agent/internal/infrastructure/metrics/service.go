@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Sample is the agent's resource usage at one point in time
+type Sample struct {
+	Timestamp    time.Time `json:"timestamp"`
+	CPUPercent   float64   `json:"cpuPercent"`   // process CPU since the previous sample, 100 is one full core
+	HeapAlloc    uint64    `json:"heapAlloc"`    // bytes of allocated heap objects
+	Sys          uint64    `json:"sys"`          // bytes obtained from the OS
+	NumGoroutine int       `json:"numGoroutine"`
+}
+
+// Service samples resource usage into a fixed-size ring buffer
+type Service struct {
+	interval time.Duration
+	samples  []Sample // ring buffer, next is the oldest once full
+	next     int
+	full     bool
+	lastCPU  time.Duration
+	lastAt   time.Time
+	mutex    sync.RWMutex
+}
+
+// NewService creates a sampler keeping retention worth of samples taken every interval
+func NewService(interval, retention time.Duration) *Service {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	size := int(retention / interval)
+	if size < 1 {
+		size = 1
+	}
+
+	return &Service{
+		interval: interval,
+		samples:  make([]Sample, size),
+	}
+}
+
+// Interval returns the time between samples
+func (s *Service) Interval() time.Duration {
+	return s.interval
+}
+
+// Retention returns how far back the buffer reaches
+func (s *Service) Retention() time.Duration {
+	return time.Duration(len(s.samples)) * s.interval
+}
+
+// Sample records the current resource usage, evicting the oldest sample when full
+func (s *Service) Sample() Sample {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	now := time.Now()
+	cpu := processCPUTime()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sample := Sample{
+		Timestamp:    now,
+		HeapAlloc:    memStats.HeapAlloc,
+		Sys:          memStats.Sys,
+		NumGoroutine: runtime.NumGoroutine(),
+	}
+	if !s.lastAt.IsZero() {
+		if elapsed := now.Sub(s.lastAt); elapsed > 0 {
+			sample.CPUPercent = float64(cpu-s.lastCPU) / float64(elapsed) * 100
+		}
+	}
+	s.lastCPU = cpu
+	s.lastAt = now
+
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+	return sample
+}
+
+// History returns the samples taken within window, oldest first; a window of
+// zero returns everything retained
+func (s *Service) History(window time.Duration) []Sample {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ordered := make([]Sample, 0, len(s.samples))
+	if s.full {
+		ordered = append(ordered, s.samples[s.next:]...)
+	}
+	ordered = append(ordered, s.samples[:s.next]...)
+
+	if window <= 0 {
+		return ordered
+	}
+
+	cutoff := time.Now().Add(-window)
+	for i, sample := range ordered {
+		if !sample.Timestamp.Before(cutoff) {
+			return ordered[i:]
+		}
+	}
+	return []Sample{}
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewService(t *testing.T) {
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		retention     time.Duration
+		wantInterval  time.Duration
+		wantRetention time.Duration
+		wantCapacity  int
+	}{
+		{"defaults", 10 * time.Second, time.Hour, 10 * time.Second, time.Hour, 360},
+		{"uneven retention rounds down", 10 * time.Second, 65 * time.Second, 10 * time.Second, time.Minute, 6},
+		{"no interval", 0, time.Minute, 10 * time.Second, time.Minute, 6},
+		{"retention below interval", time.Minute, 10 * time.Second, time.Minute, time.Minute, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(tt.interval, tt.retention)
+			if s.Interval() != tt.wantInterval || s.Retention() != tt.wantRetention || len(s.samples) != tt.wantCapacity {
+				t.Errorf("interval %v, retention %v, capacity %d; want %v, %v, %d",
+					s.Interval(), s.Retention(), len(s.samples), tt.wantInterval, tt.wantRetention, tt.wantCapacity)
+			}
+		})
+	}
+}
+
+func TestSamplesRespectRetention(t *testing.T) {
+	s := NewService(time.Second, 3*time.Second)
+	if history := s.History(0); history == nil || len(history) != 0 {
+		t.Fatalf("History() before sampling = %#v, want an empty slice", history)
+	}
+
+	var taken []Sample
+	for i := 1; i <= 7; i++ {
+		taken = append(taken, s.Sample())
+
+		want := taken
+		if len(want) > 3 {
+			want = want[len(want)-3:]
+		}
+		history := s.History(0)
+		if len(history) != len(want) {
+			t.Fatalf("after %d samples History() has %d, want %d", i, len(history), len(want))
+		}
+		for j := range want {
+			if !history[j].Timestamp.Equal(want[j].Timestamp) {
+				t.Fatalf("after %d samples History()[%d] taken at %v, want %v (oldest first)", i, j, history[j].Timestamp, want[j].Timestamp)
+			}
+		}
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	s := NewService(time.Second, time.Minute)
+	s.Sample()
+	time.Sleep(100 * time.Millisecond)
+	recent := s.Sample()
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   int
+	}{
+		{"everything", 0, 2},
+		{"covers both", time.Minute, 2},
+		{"only the recent one", 50 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := s.History(tt.window)
+			if len(history) != tt.want {
+				t.Fatalf("History(%v) has %d samples, want %d", tt.window, len(history), tt.want)
+			}
+			if last := history[len(history)-1]; !last.Timestamp.Equal(recent.Timestamp) {
+				t.Errorf("History(%v) ends at %v, want the recent sample", tt.window, last.Timestamp)
+			}
+		})
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if history := s.History(time.Millisecond); history == nil || len(history) != 0 {
+		t.Errorf("History(1ms) = %#v, want an empty slice", history)
+	}
+}
+
+func TestSampleCPU(t *testing.T) {
+	s := NewService(time.Second, time.Minute)
+	if first := s.Sample(); first.CPUPercent != 0 || first.NumGoroutine == 0 || first.HeapAlloc == 0 || first.Sys == 0 {
+		t.Fatalf("first sample = %+v, want no CPU and the current memory and goroutines", first)
+	}
+
+	// Keep one core busy so the next sample sees CPU time
+	deadline := time.Now().Add(100 * time.Millisecond)
+	n := 0
+	for time.Now().Before(deadline) {
+		n++
+	}
+	if second := s.Sample(); second.CPUPercent <= 0 {
+		t.Errorf("CPU after %d busy iterations = %v%%, want more than 0", n, second.CPUPercent)
+	}
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Service manages delayed command executions
+type Service struct {
+	logger *logrus.Logger
+	tasks  map[string]*Task
+	mutex  sync.Mutex
+}
+
+// Task represents a pending delayed execution
+type Task struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	ExecuteAt time.Time `json:"executeAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	timer     *time.Timer
+}
+
+// NewService creates a new scheduler service
+func NewService(logger *logrus.Logger) *Service {
+	return &Service{
+		logger: logger,
+		tasks:  make(map[string]*Task),
+	}
+}
+
+// Schedule registers run to be invoked after delay under the given task ID
+func (s *Service) Schedule(id, name, command string, delay time.Duration, run func()) (*Task, error) {
+	if id == "" {
+		return nil, fmt.Errorf("task ID is required")
+	}
+	if delay < 0 {
+		return nil, fmt.Errorf("delay cannot be negative")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.tasks[id]; exists {
+		return nil, fmt.Errorf("task already scheduled: %s", id)
+	}
+
+	now := time.Now()
+	task := &Task{
+		ID:        id,
+		Name:      name,
+		Command:   command,
+		ExecuteAt: now.Add(delay),
+		CreatedAt: now,
+	}
+
+	task.timer = time.AfterFunc(delay, func() {
+		s.mutex.Lock()
+		current, exists := s.tasks[id]
+		if !exists || current != task {
+			s.mutex.Unlock()
+			return
+		}
+		delete(s.tasks, id)
+		s.mutex.Unlock()
+
+		s.logger.WithFields(logrus.Fields{
+			"task_id": id,
+			"name":    name,
+		}).Info("Running scheduled task")
+		run()
+	})
+	s.tasks[id] = task
+
+	s.logger.WithFields(logrus.Fields{
+		"task_id":    id,
+		"name":       name,
+		"execute_at": task.ExecuteAt.Format(time.RFC3339),
+	}).Info("Task scheduled")
+
+	return task, nil
+}
+
+// Cancel stops a pending task before it runs
+func (s *Service) Cancel(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		return fmt.Errorf("scheduled task not found: %s", id)
+	}
+
+	task.timer.Stop()
+	delete(s.tasks, id)
+
+	s.logger.WithField("task_id", id).Info("Scheduled task cancelled")
+	return nil
+}
+
+// Get returns a pending task by ID
+func (s *Service) Get(id string) (*Task, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	return task, exists
+}
+
+// List returns all pending tasks ordered by execution time
+func (s *Service) List() []*Task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tasks := make([]*Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ExecuteAt.Before(tasks[j].ExecuteAt)
+	})
+	return tasks
+}
+
+// CancelAll stops every pending task
+func (s *Service) CancelAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, task := range s.tasks {
+		task.timer.Stop()
+		delete(s.tasks, id)
+	}
+}
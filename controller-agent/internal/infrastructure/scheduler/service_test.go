@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestService() *Service {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewService(logger)
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		delay   time.Duration
+		wantErr bool
+	}{
+		{"runs after the delay", "task", 10 * time.Millisecond, false},
+		{"runs without delay", "task", 0, false},
+		{"requires an ID", "", time.Millisecond, true},
+		{"rejects a negative delay", "task", -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			ran := make(chan struct{})
+			task, err := s.Schedule(tt.id, "name", "true", tt.delay, func() { close(ran) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Schedule() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if task.ExecuteAt.Sub(task.CreatedAt) != tt.delay {
+				t.Fatalf("task executes %v after creation, want %v", task.ExecuteAt.Sub(task.CreatedAt), tt.delay)
+			}
+			select {
+			case <-ran:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("task did not run")
+			}
+			if _, exists := s.Get(tt.id); exists {
+				t.Fatalf("task is still pending after running")
+			}
+		})
+	}
+}
+
+func TestScheduleRejectsDuplicateID(t *testing.T) {
+	s := newTestService()
+	defer s.CancelAll()
+
+	if _, err := s.Schedule("power", "reboot", "reboot", time.Hour, func() {}); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if _, err := s.Schedule("power", "shutdown", "shutdown", time.Hour, func() {}); err == nil {
+		t.Fatalf("scheduling a pending task ID succeeded")
+	}
+	if task, _ := s.Get("power"); task.Name != "reboot" {
+		t.Fatalf("pending task = %s, want the first one", task.Name)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  string
+		wantErr bool
+	}{
+		{"pending task", "task", false},
+		{"unknown task", "other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService()
+			defer s.CancelAll()
+			ran := make(chan struct{}, 1)
+			if _, err := s.Schedule("task", "name", "true", 50*time.Millisecond, func() { ran <- struct{}{} }); err != nil {
+				t.Fatalf("Schedule: %v", err)
+			}
+
+			if err := s.Cancel(tt.cancel); (err != nil) != tt.wantErr {
+				t.Fatalf("Cancel() error = %v, want error %v", err, tt.wantErr)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if ranTask := len(ran) == 1; ranTask != tt.wantErr {
+				t.Fatalf("task ran = %v, want %v", ranTask, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListAndCancelAll(t *testing.T) {
+	s := newTestService()
+	for _, task := range []struct {
+		id    string
+		delay time.Duration
+	}{
+		{"later", 3 * time.Hour},
+		{"soon", time.Hour},
+		{"middle", 2 * time.Hour},
+	} {
+		if _, err := s.Schedule(task.id, task.id, "true", task.delay, func() {}); err != nil {
+			t.Fatalf("Schedule %s: %v", task.id, err)
+		}
+	}
+
+	tasks := s.List()
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.ID)
+	}
+	if want := []string{"soon", "middle", "later"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("List() = %v, want %v", got, want)
+	}
+
+	s.CancelAll()
+	if tasks := s.List(); len(tasks) != 0 {
+		t.Fatalf("%d tasks pending after CancelAll", len(tasks))
+	}
+}
@@ -0,0 +1,74 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// windowAround returns allowed hours from the given offsets of now in loc
+func windowAround(loc *time.Location, from, to time.Duration) *entity.AllowedHoursConfig {
+	now := time.Now().In(loc)
+	return &entity.AllowedHoursConfig{Start: now.Add(from).Format("15:04"), End: now.Add(to).Format("15:04")}
+}
+
+func TestCheckAllowedHours(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		timezone string
+		hours    *entity.AllowedHoursConfig
+		wantErr  bool
+	}{
+		{"no window", "", nil, false},
+		{"inside", "", windowAround(time.Local, -time.Hour, time.Hour), false},
+		{"outside", "", windowAround(time.Local, time.Hour, 2*time.Hour), true},
+		{"inside in the configured timezone", "Asia/Tokyo", windowAround(tokyo, -time.Hour, time.Hour), false},
+		// Honolulu is 19 hours behind Tokyo, so Tokyo's current hour is hours away there
+		{"outside in the configured timezone", "Pacific/Honolulu", windowAround(tokyo, -time.Hour, time.Hour), true},
+		{"invalid window is ignored", "", &entity.AllowedHoursConfig{Start: "late", End: "early"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Commands.Timezone = tt.timezone
+			s := newTestService(cfg)
+
+			err := s.CheckAllowedHours(&entity.Command{ID: "backup", AllowedHours: tt.hours})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckAllowedHours() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, common.ErrOutsideAllowedHours) {
+				t.Errorf("error %v is not ErrOutsideAllowedHours", err)
+			}
+			// The message names the window and when the command may run next
+			_, after, ok := strings.Cut(err.Error(), "next allowed at ")
+			if !ok || !strings.Contains(err.Error(), tt.hours.Start+" to "+tt.hours.End) {
+				t.Fatalf("error %q does not report the window and next allowed time", err)
+			}
+			next, parseErr := time.Parse(time.RFC3339, after)
+			if parseErr != nil {
+				t.Fatalf("next allowed time %q: %v", after, parseErr)
+			}
+			if wait := time.Until(next); wait <= 0 || wait > 24*time.Hour {
+				t.Errorf("next allowed at %s, want within the next day", next)
+			}
+			if next.Format("15:04") != tt.hours.Start {
+				t.Errorf("next allowed at %s, want the window start %s", next.Format("15:04"), tt.hours.Start)
+			}
+		})
+	}
+}
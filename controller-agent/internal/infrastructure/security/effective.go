@@ -0,0 +1,94 @@
+package security
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
+)
+
+// Caller describes who is about to execute a command and over which transport
+type Caller struct {
+	Transport string // audit.InterfaceHTTP, audit.InterfaceGRPC or audit.InterfaceMQTT
+	ClientID  string // X-Client-ID header, gRPC metadata or MQTT request field
+	IP        string // empty for MQTT, which carries no client address
+}
+
+// EffectiveSecurity is what a caller must satisfy to execute a command, combining
+// the command's own settings with the agent-wide policy and the transport's checks
+type EffectiveSecurity struct {
+	CommandID string `json:"commandId"`
+	Transport string `json:"transport"`
+
+	RequiresPin   bool `json:"requiresPin"`   // a valid PIN must accompany the request
+	RequiresAdmin bool `json:"requiresAdmin"` // the command is marked admin-only
+	Whitelisted   bool `json:"whitelisted"`   // passes the command whitelist, see CheckCommandAccess
+
+	ClientAllowed      bool       `json:"clientAllowed"`      // the caller passes the command's client restriction
+	WithinAllowedHours bool       `json:"withinAllowedHours"` // the command's daily window is open now
+	NextAllowedAt      *time.Time `json:"nextAllowedAt,omitempty"`
+
+	ClientRateLimit  int               `json:"clientRateLimit"` // requests per minute per client, 0 when not applied
+	CommandRateLimit *CommandRateLimit `json:"commandRateLimit,omitempty"`
+
+	// CanExecute reports whether the checks that do not depend on the request
+	// itself pass now; a required PIN must still be supplied
+	CanExecute bool     `json:"canExecute"`
+	Reasons    []string `json:"reasons,omitempty"` // why CanExecute is false
+}
+
+// CommandRateLimit is a command's own execution limit, shared by all clients
+type CommandRateLimit struct {
+	MaxExecutions int `json:"maxExecutions"`
+	Window        int `json:"window"` // seconds
+}
+
+// EffectiveSecurity computes the requirements caller faces when executing cmd
+func (s *Service) EffectiveSecurity(cmd *entity.Command, caller Caller) *EffectiveSecurity {
+	effective := &EffectiveSecurity{
+		CommandID:          cmd.ID,
+		Transport:          caller.Transport,
+		RequiresPin:        cmd.RequiresPin() && s.config.Security.PinRequired,
+		RequiresAdmin:      cmd.RequiresAdmin(),
+		Whitelisted:        true,
+		ClientAllowed:      cmd.IsClientAllowed(caller.ClientID, caller.IP),
+		WithinAllowedHours: true,
+		CanExecute:         true,
+	}
+
+	if err := s.CheckCommandAccess(cmd); err != nil {
+		effective.Whitelisted = false
+		effective.CanExecute = false
+		effective.Reasons = append(effective.Reasons, err.Error())
+	}
+
+	if !effective.ClientAllowed {
+		effective.CanExecute = false
+		effective.Reasons = append(effective.Reasons, "client not allowed to execute this command")
+	}
+
+	if cmd.HasAllowedHours() {
+		now := time.Now().In(s.location)
+		if next := cmd.AllowedHours.NextAllowed(now); !next.Equal(now) {
+			effective.WithinAllowedHours = false
+			effective.NextAllowedAt = &next
+			effective.CanExecute = false
+			effective.Reasons = append(effective.Reasons, fmt.Sprintf("outside allowed hours %s-%s, next allowed at %s",
+				cmd.AllowedHours.Start, cmd.AllowedHours.End, next.Format(time.RFC3339)))
+		}
+	}
+
+	// MQTT requests are not subject to the per-client limit
+	if s.config.Security.RateLimitEnabled && caller.Transport != audit.InterfaceMQTT {
+		effective.ClientRateLimit = s.config.Security.RateLimitPerMin
+	}
+	if cmd.HasRateLimit() {
+		effective.CommandRateLimit = &CommandRateLimit{
+			MaxExecutions: cmd.RateLimit.MaxExecutions,
+			Window:        cmd.RateLimit.Window,
+		}
+	}
+
+	return effective
+}
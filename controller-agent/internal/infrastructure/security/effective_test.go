@@ -0,0 +1,138 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
+)
+
+func TestEffectiveSecurity(t *testing.T) {
+	now := time.Now()
+	closedHours := &entity.AllowedHoursConfig{Start: now.Add(time.Hour).Format("15:04"), End: now.Add(2 * time.Hour).Format("15:04")}
+	httpCaller := Caller{Transport: audit.InterfaceHTTP, ClientID: "kitchen", IP: "192.0.2.10"}
+
+	type want struct {
+		requiresPin, requiresAdmin, whitelisted, clientAllowed, withinHours, canExecute bool
+		clientRateLimit                                                                 int
+		reasons                                                                         int
+	}
+	tests := []struct {
+		name     string
+		security config.SecurityConfig
+		cmd      entity.Command
+		caller   Caller
+		want     want
+	}{
+		{
+			name: "unrestricted command",
+			cmd:  entity.Command{ID: "uptime"},
+			want: want{whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+		{
+			name:     "command PIN with PINs enabled",
+			security: config.SecurityConfig{PinRequired: true},
+			cmd:      entity.Command{ID: "reboot", Security: &entity.SecurityConfig{RequirePin: true, Whitelist: true}},
+			want:     want{requiresPin: true, whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+		{
+			name: "command PIN with PINs disabled",
+			cmd:  entity.Command{ID: "reboot", Security: &entity.SecurityConfig{RequirePin: true, Whitelist: true}},
+			want: want{whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+		{
+			name: "admin only",
+			cmd:  entity.Command{ID: "shutdown", Security: &entity.SecurityConfig{AdminOnly: true, Whitelist: true}},
+			want: want{requiresAdmin: true, whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+		{
+			name:     "not in allowed commands",
+			security: config.SecurityConfig{EnableWhitelist: true, AllowedCommands: []string{"uptime"}},
+			cmd:      entity.Command{ID: "reboot"},
+			want:     want{clientAllowed: true, withinHours: true, reasons: 1},
+		},
+		{
+			name:     "default deny without whitelisting",
+			security: config.SecurityConfig{DefaultDeny: true},
+			cmd:      entity.Command{ID: "reboot", Security: &entity.SecurityConfig{}},
+			want:     want{clientAllowed: true, withinHours: true, reasons: 1},
+		},
+		{
+			name:   "allowed client",
+			cmd:    entity.Command{ID: "lights", Security: &entity.SecurityConfig{Whitelist: true, AllowedClientIDs: []string{"kitchen"}}},
+			caller: httpCaller,
+			want:   want{whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+		{
+			name:   "other client",
+			cmd:    entity.Command{ID: "lights", Security: &entity.SecurityConfig{Whitelist: true, AllowedClientIDs: []string{"garage"}}},
+			caller: httpCaller,
+			want:   want{whitelisted: true, withinHours: true, reasons: 1},
+		},
+		{
+			name: "outside allowed hours",
+			cmd:  entity.Command{ID: "backup", AllowedHours: closedHours},
+			want: want{whitelisted: true, clientAllowed: true, reasons: 1},
+		},
+		{
+			name:     "every check fails",
+			security: config.SecurityConfig{DefaultDeny: true},
+			cmd:      entity.Command{ID: "backup", Security: &entity.SecurityConfig{AllowedIPs: []string{"10.0.0.0/8"}}, AllowedHours: closedHours},
+			caller:   httpCaller,
+			want:     want{reasons: 3},
+		},
+		{
+			name:     "client rate limit over HTTP",
+			security: config.SecurityConfig{RateLimitEnabled: true, RateLimitPerMin: 30},
+			cmd:      entity.Command{ID: "uptime"},
+			caller:   httpCaller,
+			want:     want{whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true, clientRateLimit: 30},
+		},
+		{
+			name:     "no client rate limit over MQTT",
+			security: config.SecurityConfig{RateLimitEnabled: true, RateLimitPerMin: 30},
+			cmd:      entity.Command{ID: "uptime"},
+			caller:   Caller{Transport: audit.InterfaceMQTT},
+			want:     want{whitelisted: true, clientAllowed: true, withinHours: true, canExecute: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(&config.Config{Security: tt.security})
+			got := s.EffectiveSecurity(&tt.cmd, tt.caller)
+
+			gotWant := want{
+				requiresPin:     got.RequiresPin,
+				requiresAdmin:   got.RequiresAdmin,
+				whitelisted:     got.Whitelisted,
+				clientAllowed:   got.ClientAllowed,
+				withinHours:     got.WithinAllowedHours,
+				canExecute:      got.CanExecute,
+				clientRateLimit: got.ClientRateLimit,
+				reasons:         len(got.Reasons),
+			}
+			if gotWant != tt.want {
+				t.Errorf("EffectiveSecurity() = %+v, want %+v (reasons %q)", gotWant, tt.want, got.Reasons)
+			}
+			if got.CommandID != tt.cmd.ID || got.Transport != tt.caller.Transport {
+				t.Errorf("reported command %q over %q, want %q over %q", got.CommandID, got.Transport, tt.cmd.ID, tt.caller.Transport)
+			}
+			if (got.NextAllowedAt != nil) == tt.want.withinHours {
+				t.Errorf("nextAllowedAt = %v with withinAllowedHours %v", got.NextAllowedAt, got.WithinAllowedHours)
+			}
+		})
+	}
+}
+
+func TestEffectiveSecurityCommandRateLimit(t *testing.T) {
+	s := newTestService(&config.Config{})
+	got := s.EffectiveSecurity(&entity.Command{ID: "uptime", RateLimit: &entity.RateLimitConfig{MaxExecutions: 5, Window: 60}}, Caller{Transport: audit.InterfaceGRPC})
+	if got.CommandRateLimit == nil || *got.CommandRateLimit != (CommandRateLimit{MaxExecutions: 5, Window: 60}) {
+		t.Fatalf("commandRateLimit = %+v, want 5 per 60s", got.CommandRateLimit)
+	}
+	if got := s.EffectiveSecurity(&entity.Command{ID: "uptime"}, Caller{}); got.CommandRateLimit != nil {
+		t.Errorf("commandRateLimit = %+v for a command without one", got.CommandRateLimit)
+	}
+}
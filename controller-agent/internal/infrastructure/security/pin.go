@@ -0,0 +1,102 @@
+package security
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// HashPin returns the bcrypt hash of pin for security.pin_hash
+func HashPin(pin string) (string, error) {
+	if pin == "" {
+		return "", errors.New("PIN must not be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// pinConfigured reports whether a PIN is set in either form
+func (s *Service) pinConfigured() bool {
+	return s.config.Security.PinHash != "" || s.config.Security.Pin != ""
+}
+
+// checkPin compares providedPin with the configured PIN in constant time,
+// preferring the hash over the plaintext PIN
+func (s *Service) checkPin(providedPin string) bool {
+	if hash := s.config.Security.PinHash; hash != "" {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(providedPin)) == nil
+	}
+
+	// Hashing first keeps the comparison constant-time regardless of length
+	provided := sha256.Sum256([]byte(providedPin))
+	configured := sha256.Sum256([]byte(s.config.Security.Pin))
+	return subtle.ConstantTimeCompare(provided[:], configured[:]) == 1
+}
+
+// checkAdminPin verifies providedPin against the admin PIN hash. The regular
+// PIN never stands in for it, so without one every admin PIN is rejected
+func (s *Service) checkAdminPin(providedPin string) bool {
+	hash := s.config.Security.AdminPinHash
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(providedPin)) == nil
+}
+
+// VerifyPin checks the PIN a client sent for a PIN-protected operation under
+// the wrong-PIN throttle: a throttled client is refused without the PIN being
+// checked, and a wrong PIN counts against it. The error wraps
+// common.ErrTooManyPinFailures or common.ErrInvalidPin
+func (s *Service) VerifyPin(clientIP, providedPin string) error {
+	return s.verifyPin(clientIP, providedPin, s.ValidatePin)
+}
+
+// VerifyAdminPin is VerifyPin for the admin PIN
+func (s *Service) VerifyAdminPin(clientIP, providedPin string) error {
+	return s.verifyPin(clientIP, providedPin, s.ValidateAdminPin)
+}
+
+// IsAdmin reports whether a client passed a valid admin PIN where one is
+// optional, under the same throttle as VerifyAdminPin
+func (s *Service) IsAdmin(clientIP, adminPin string) bool {
+	return adminPin != "" && s.VerifyAdminPin(clientIP, adminPin) == nil
+}
+
+func (s *Service) verifyPin(clientIP, providedPin string, validate func(string) bool) error {
+	if err := s.CheckPinFailures(clientIP); err != nil {
+		return err
+	}
+	if !validate(providedPin) {
+		// A missing PIN guesses nothing, so only wrong ones count
+		if providedPin != "" {
+			s.RecordPinFailure(clientIP)
+		}
+		return common.ErrInvalidPin
+	}
+	return nil
+}
+
+// checkPinConfig warns about a plaintext PIN and rejects a malformed hash at startup
+func (s *Service) checkPinConfig() {
+	security := s.config.Security
+	if security.AdminPinHash != "" {
+		if _, err := bcrypt.Cost([]byte(security.AdminPinHash)); err != nil {
+			s.logger.WithError(err).Error("security.admin_pin_hash is not a bcrypt hash, every admin PIN will be rejected")
+		}
+	} else if security.AllowAdhoc {
+		s.logger.Warn("security.allow_adhoc is set without security.admin_pin_hash, ad-hoc execution will be refused")
+	}
+	if security.PinHash != "" {
+		if _, err := bcrypt.Cost([]byte(security.PinHash)); err != nil {
+			s.logger.WithError(err).Error("security.pin_hash is not a bcrypt hash, every PIN will be rejected")
+		}
+		return
+	}
+	if security.Pin != "" {
+		s.logger.Warn("The PIN is stored in plaintext; run \"controller-agent hash-pin\" and set security.pin_hash instead of security.pin")
+	}
+}
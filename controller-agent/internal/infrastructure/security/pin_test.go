@@ -0,0 +1,68 @@
+package security
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestHashPin(t *testing.T) {
+	if _, err := HashPin(""); err == nil {
+		t.Fatal("HashPin(\"\") succeeded, want an error")
+	}
+	hash, err := HashPin("1234")
+	if err != nil {
+		t.Fatalf("HashPin: %v", err)
+	}
+	if hash == "1234" {
+		t.Fatal("HashPin returned the PIN itself")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("1234")); err != nil {
+		t.Fatalf("hash does not match the PIN: %v", err)
+	}
+}
+
+func TestValidatePinHash(t *testing.T) {
+	pinHash, err := HashPin("1234")
+	if err != nil {
+		t.Fatalf("hash PIN: %v", err)
+	}
+	adminHash, err := HashPin("9999")
+	if err != nil {
+		t.Fatalf("hash admin PIN: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		security  config.SecurityConfig
+		pin       string
+		wantPin   bool
+		wantAdmin bool
+	}{
+		{"plaintext PIN is not an admin PIN", config.SecurityConfig{Pin: "1234"}, "1234", true, false},
+		{"wrong plaintext PIN", config.SecurityConfig{Pin: "1234"}, "0000", false, false},
+		{"hashed PIN is not an admin PIN", config.SecurityConfig{PinHash: pinHash}, "1234", true, false},
+		{"wrong hashed PIN", config.SecurityConfig{PinHash: pinHash}, "0000", false, false},
+		{"hash takes precedence", config.SecurityConfig{Pin: "0000", PinHash: pinHash}, "0000", false, false},
+		{"malformed hash rejects every PIN", config.SecurityConfig{Pin: "1234", PinHash: "1234"}, "1234", false, false},
+		{"admin hash for admin operations", config.SecurityConfig{Pin: "1234", AdminPinHash: adminHash}, "9999", false, true},
+		{"regular PIN is not the admin PIN", config.SecurityConfig{Pin: "1234", AdminPinHash: adminHash}, "1234", true, false},
+		{"admin hash without a regular PIN", config.SecurityConfig{AdminPinHash: adminHash}, "9999", false, true},
+		{"no PIN configured", config.SecurityConfig{}, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Security: tt.security}
+			cfg.Security.PinRequired = true
+			s := newTestService(cfg)
+			if got := s.ValidatePin(tt.pin); got != tt.wantPin {
+				t.Errorf("ValidatePin(%q) = %v, want %v", tt.pin, got, tt.wantPin)
+			}
+			if got := s.ValidateAdminPin(tt.pin); got != tt.wantAdmin {
+				t.Errorf("ValidateAdminPin(%q) = %v, want %v", tt.pin, got, tt.wantAdmin)
+			}
+		})
+	}
+}
@@ -6,15 +6,21 @@ import (
 	"sync"
 	"time"
 
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
 type Service struct {
-	config      *config.Config
-	logger      *logrus.Logger
-	rateLimiter map[string]*rateLimitEntry
-	mutex       sync.RWMutex
+	config         *config.Config
+	logger         *logrus.Logger
+	location       *time.Location // timezone of command allowed hours
+	rateLimiter    map[string]*rateLimitEntry
+	commandLimiter map[string]*rateLimitEntry // keyed by command ID
+	adhocLimiter   *rateLimitEntry            // shared by all clients
+	pinFailures    map[string]*rateLimitEntry // wrong PINs, keyed by client IP
+	mutex          sync.RWMutex
 }
 
 type rateLimitEntry struct {
@@ -23,11 +29,29 @@ type rateLimitEntry struct {
 }
 
 func NewService(config *config.Config, logger *logrus.Logger) *Service {
-	return &Service{
-		config:      config,
-		logger:      logger,
-		rateLimiter: make(map[string]*rateLimitEntry),
+	location := time.Local
+	if config.Commands.Timezone != "" {
+		loc, err := time.LoadLocation(config.Commands.Timezone)
+		if err != nil {
+			logger.WithError(err).Warnf("Unknown timezone %q, using system timezone for allowed hours", config.Commands.Timezone)
+		} else {
+			location = loc
+		}
+	}
+
+	s := &Service{
+		config:         config,
+		logger:         logger,
+		location:       location,
+		rateLimiter:    make(map[string]*rateLimitEntry),
+		commandLimiter: make(map[string]*rateLimitEntry),
+		pinFailures:    make(map[string]*rateLimitEntry),
 	}
+	s.checkPinConfig()
+	if config.Security.AllowAdhoc {
+		logger.Warn("Ad-hoc execution enabled; admin PIN holders can run arbitrary commands")
+	}
+	return s
 }
 
 func (s *Service) ValidatePin(providedPin string) bool {
@@ -35,21 +59,24 @@ func (s *Service) ValidatePin(providedPin string) bool {
 		return true
 	}
 
-	if s.config.Security.Pin == "" {
+	if !s.pinConfigured() {
 		s.logger.Warn("PIN validation enabled but no PIN configured")
 		return false
 	}
 
-	// 使用SHA256哈希比较PIN
-	hasher := sha256.New()
-	hasher.Write([]byte(providedPin))
-	providedHash := fmt.Sprintf("%x", hasher.Sum(nil))
+	return s.checkPin(providedPin)
+}
 
-	hasher.Reset()
-	hasher.Write([]byte(s.config.Security.Pin))
-	configHash := fmt.Sprintf("%x", hasher.Sum(nil))
+// ValidateAdminPin verifies the admin PIN for admin-only operations and
+// commands. Unlike ValidatePin it always requires security.admin_pin_hash;
+// the regular PIN is never accepted in its place.
+func (s *Service) ValidateAdminPin(providedPin string) bool {
+	if s.config.Security.AdminPinHash == "" {
+		s.logger.Warn("Admin operation requested but security.admin_pin_hash is not set")
+		return false
+	}
 
-	return providedHash == configHash
+	return s.checkAdminPin(providedPin)
 }
 
 func (s *Service) CheckRateLimit(clientID string) error {
@@ -86,6 +113,121 @@ func (s *Service) CheckRateLimit(clientID string) error {
 	return nil
 }
 
+// CheckCommandRateLimit limits executions of a single command to maxExecutions
+// per window across all clients; it applies in addition to CheckRateLimit
+func (s *Service) CheckCommandRateLimit(commandID string, maxExecutions int, window time.Duration) error {
+	if maxExecutions <= 0 || window <= 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	entry, exists := s.commandLimiter[commandID]
+
+	if !exists || now.After(entry.resetTime) {
+		s.commandLimiter[commandID] = &rateLimitEntry{
+			count:     1,
+			resetTime: now.Add(window),
+		}
+		return nil
+	}
+
+	if entry.count >= maxExecutions {
+		s.logger.WithFields(logrus.Fields{
+			"command_id": commandID,
+			"count":      entry.count,
+			"limit":      maxExecutions,
+			"window":     window.String(),
+		}).Warn("Command rate limit exceeded")
+
+		return fmt.Errorf("rate limit exceeded for command %s: %d executions per %s", commandID, maxExecutions, window)
+	}
+
+	entry.count++
+	return nil
+}
+
+// CheckAdhocRateLimit limits ad-hoc executions to adhoc_rate_limit_per_min
+// across all clients. Unlike CheckRateLimit it applies even when rate
+// limiting is disabled, and a limit of 0 or less blocks them
+func (s *Service) CheckAdhocRateLimit() error {
+	limit := s.config.Security.AdhocRateLimitPerMin
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if s.adhocLimiter == nil || now.After(s.adhocLimiter.resetTime) {
+		s.adhocLimiter = &rateLimitEntry{resetTime: now.Add(time.Minute)}
+	}
+
+	if s.adhocLimiter.count >= limit {
+		s.logger.WithFields(logrus.Fields{
+			"count": s.adhocLimiter.count,
+			"limit": limit,
+		}).Warn("Ad-hoc rate limit exceeded")
+
+		return fmt.Errorf("rate limit exceeded: %d ad-hoc executions per minute", limit)
+	}
+
+	s.adhocLimiter.count++
+	return nil
+}
+
+// maxPinFailuresPerMin is how many wrong PINs a client may send per minute
+// before its requests are refused without checking the PIN
+const maxPinFailuresPerMin = 5
+
+// CheckPinFailures refuses a client that sent too many wrong PINs within the
+// last minute. Call it before validating the PIN, and RecordPinFailure after
+// a wrong one, so the PIN cannot be guessed at the speed of the API
+func (s *Service) CheckPinFailures(clientIP string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, exists := s.pinFailures[clientIP]
+	if !exists || time.Now().After(entry.resetTime) || entry.count < maxPinFailuresPerMin {
+		return nil
+	}
+	return fmt.Errorf("%w: %d per minute", common.ErrTooManyPinFailures, maxPinFailuresPerMin)
+}
+
+// RecordPinFailure counts a wrong PIN sent by a client
+func (s *Service) RecordPinFailure(clientIP string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	entry, exists := s.pinFailures[clientIP]
+	if !exists || now.After(entry.resetTime) {
+		entry = &rateLimitEntry{resetTime: now.Add(time.Minute)}
+		s.pinFailures[clientIP] = entry
+	}
+	entry.count++
+	if entry.count == maxPinFailuresPerMin {
+		s.logger.WithField("client_ip", clientIP).Warn("Too many wrong PINs, refusing client for the rest of the minute")
+	}
+}
+
+// CheckAllowedHours rejects a command outside its daily allowed hours,
+// reporting when it may run next
+func (s *Service) CheckAllowedHours(cmd *entity.Command) error {
+	if !cmd.HasAllowedHours() {
+		return nil
+	}
+
+	now := time.Now().In(s.location)
+	next := cmd.AllowedHours.NextAllowed(now)
+	if next.Equal(now) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s may run from %s to %s, next allowed at %s",
+		common.ErrOutsideAllowedHours, cmd.ID, cmd.AllowedHours.Start, cmd.AllowedHours.End, next.Format(time.RFC3339))
+}
+
 func (s *Service) ValidateCommandAccess(commandID string) error {
 	if !s.config.Security.EnableWhitelist {
 		return nil
@@ -106,7 +248,28 @@ func (s *Service) ValidateCommandAccess(commandID string) error {
 		"allowed_commands": s.config.Security.AllowedCommands,
 	}).Warn("Command not in whitelist")
 
-	return fmt.Errorf("command not allowed: %s", commandID)
+	return fmt.Errorf("%w: %s", common.ErrCommandNotAllowed, commandID)
+}
+
+// CheckCommandAccess applies the command whitelist to cmd: the agent's
+// allowed_commands list, and with default_deny the command must also be
+// whitelisted itself or listed in allowed_commands
+func (s *Service) CheckCommandAccess(cmd *entity.Command) error {
+	if err := s.ValidateCommandAccess(cmd.ID); err != nil {
+		return err
+	}
+	
+	if !s.config.Security.DefaultDeny || cmd.IsExplicitlyWhitelisted() {
+		return nil
+	}
+	for _, allowed := range s.config.Security.AllowedCommands {
+		if allowed == cmd.ID {
+			return nil
+		}
+	}
+	
+	s.logger.WithField("command_id", cmd.ID).Warn("Command not whitelisted, denied by default")
+	return fmt.Errorf("%w: %s is not whitelisted", common.ErrCommandNotAllowed, cmd.ID)
 }
 
 func (s *Service) GetClientID(remoteAddr string, userAgent string) string {
@@ -127,4 +290,14 @@ func (s *Service) CleanupRateLimiter() {
 			delete(s.rateLimiter, clientID)
 		}
 	}
+	for commandID, entry := range s.commandLimiter {
+		if now.After(entry.resetTime) {
+			delete(s.commandLimiter, commandID)
+		}
+	}
+	for clientIP, entry := range s.pinFailures {
+		if now.After(entry.resetTime) {
+			delete(s.pinFailures, clientIP)
+		}
+	}
 }
\ No newline at end of file
@@ -0,0 +1,92 @@
+package security
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestCheckPinFailures(t *testing.T) {
+	s := newTestService(&config.Config{})
+	for i := 0; i < maxPinFailuresPerMin-1; i++ {
+		s.RecordPinFailure("192.0.2.1")
+	}
+	for i := 0; i < maxPinFailuresPerMin; i++ {
+		s.RecordPinFailure("192.0.2.9")
+	}
+	s.pinFailures["192.0.2.9"].resetTime = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name     string
+		record   bool // record one more failure before checking
+		clientIP string
+		wantErr  bool
+	}{
+		{"below the limit", false, "192.0.2.1", false},
+		{"reaching the limit", true, "192.0.2.1", true},
+		{"other client", false, "192.0.2.2", false},
+		{"limit of a past minute", false, "192.0.2.9", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.record {
+				s.RecordPinFailure(tt.clientIP)
+			}
+			if err := s.CheckPinFailures(tt.clientIP); (err != nil) != tt.wantErr {
+				t.Fatalf("CheckPinFailures() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s.CleanupRateLimiter()
+	if _, ok := s.pinFailures["192.0.2.9"]; ok {
+		t.Errorf("expired failures were not cleaned up")
+	}
+}
+
+func TestVerifyPinThrottlesWrongPins(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.PinRequired = true
+	cfg.Security.Pin = "1234"
+	adminHash, err := HashPin("9999")
+	if err != nil {
+		t.Fatalf("hash admin PIN: %v", err)
+	}
+	cfg.Security.AdminPinHash = adminHash
+	s := newTestService(cfg)
+
+	// Missing PINs are refused without counting
+	for i := 0; i < maxPinFailuresPerMin; i++ {
+		if err := s.VerifyAdminPin("192.0.2.1", ""); !errors.Is(err, common.ErrInvalidPin) {
+			t.Fatalf("empty PIN: err = %v, want ErrInvalidPin", err)
+		}
+	}
+	if s.IsAdmin("192.0.2.1", "") {
+		t.Fatal("empty admin PIN accepted")
+	}
+
+	// Wrong regular and admin PINs share the client's failure count
+	for i := 0; i < maxPinFailuresPerMin; i++ {
+		verify := s.VerifyPin
+		if i%2 == 1 {
+			verify = s.VerifyAdminPin
+		}
+		if err := verify("192.0.2.1", "0000"); !errors.Is(err, common.ErrInvalidPin) {
+			t.Fatalf("wrong PIN %d: err = %v, want ErrInvalidPin", i+1, err)
+		}
+	}
+
+	// Once throttled even the right PIN is refused without being checked
+	if err := s.VerifyPin("192.0.2.1", "1234"); !errors.Is(err, common.ErrTooManyPinFailures) {
+		t.Fatalf("right PIN after the limit: err = %v, want ErrTooManyPinFailures", err)
+	}
+	if s.IsAdmin("192.0.2.1", "9999") {
+		t.Fatal("throttled client treated as admin")
+	}
+	if err := s.VerifyAdminPin("192.0.2.2", "9999"); err != nil {
+		t.Fatalf("other client: %v", err)
+	}
+}
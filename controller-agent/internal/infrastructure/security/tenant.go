@@ -0,0 +1,50 @@
+package security
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+// AuthenticateTenant returns the user and device of the configured tenant
+// token matching token. Tokens without a user or device are ignored, since
+// they would identify nobody
+func (s *Service) AuthenticateTenant(token string) (entity.Principal, bool) {
+	if token == "" {
+		return entity.Principal{}, false
+	}
+
+	// Hashing first keeps the comparison constant-time regardless of length
+	provided := sha256.Sum256([]byte(token))
+	for _, tenant := range s.config.Commands.Tenants {
+		if tenant.Token == "" || (tenant.UserID == "" && tenant.DeviceID == "") {
+			continue
+		}
+		configured := sha256.Sum256([]byte(tenant.Token))
+		if subtle.ConstantTimeCompare(provided[:], configured[:]) == 1 {
+			return entity.Principal{UserID: tenant.UserID, DeviceID: tenant.DeviceID}, true
+		}
+	}
+	return entity.Principal{}, false
+}
+
+// TenantUserID returns the user of the tenant token, or "" without a valid
+// one. Commands are told the caller's user ID only through it, so the ID is
+// never one the caller simply claimed
+func (s *Service) TenantUserID(token string) string {
+	principal, _ := s.AuthenticateTenant(token)
+	return principal.UserID
+}
+
+// TenantScope returns the principal a caller at clientIP presenting token is
+// scoped to in tenancy mode, or nil when tenancy is off or adminPin is valid
+// and the caller sees every command. A caller without a valid token is the
+// empty principal, which sees only unowned commands
+func (s *Service) TenantScope(clientIP, token, adminPin string) *entity.Principal {
+	if !s.config.Commands.Tenancy || s.IsAdmin(clientIP, adminPin) {
+		return nil
+	}
+	principal, _ := s.AuthenticateTenant(token)
+	return &principal
+}
@@ -0,0 +1,79 @@
+package security
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// newTestService returns a security service for cfg that logs nowhere
+func newTestService(cfg *config.Config) *Service {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewService(cfg, logger)
+}
+
+func TestAuthenticateTenant(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Commands.Tenants = []config.TenantToken{
+		{Token: "alice-token", UserID: "alice"},
+		{Token: "laptop-token", UserID: "bob", DeviceID: "laptop"},
+		{Token: "", UserID: "empty-token"},
+		{Token: "nobody-token"},
+	}
+	s := newTestService(cfg)
+
+	tests := []struct {
+		name   string
+		token  string
+		want   entity.Principal
+		wantOK bool
+	}{
+		{"user token", "alice-token", entity.Principal{UserID: "alice"}, true},
+		{"user and device token", "laptop-token", entity.Principal{UserID: "bob", DeviceID: "laptop"}, true},
+		{"unknown token", "mallory-token", entity.Principal{}, false},
+		{"prefix of a token", "alice", entity.Principal{}, false},
+		{"no token", "", entity.Principal{}, false},
+		{"token naming nobody", "nobody-token", entity.Principal{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.AuthenticateTenant(tt.token)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("AuthenticateTenant(%q) = %+v, %v; want %+v, %v", tt.token, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTenantUserID(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Commands.Tenants = []config.TenantToken{
+		{Token: "alice-token", UserID: "alice"},
+		{Token: "laptop-token", DeviceID: "laptop"},
+	}
+	s := newTestService(cfg)
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"user token", "alice-token", "alice"},
+		{"device-only token", "laptop-token", ""},
+		{"unknown token", "mallory-token", ""},
+		{"a user ID is not a token", "alice", ""},
+		{"no token", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.TenantUserID(tt.token); got != tt.want {
+				t.Fatalf("TenantUserID(%q) = %q, want %q", tt.token, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package security
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestCheckCommandAccess(t *testing.T) {
+	whitelisted := &entity.Command{ID: "lights", Security: &entity.SecurityConfig{Whitelist: true}}
+	notWhitelisted := &entity.Command{ID: "lights", Security: &entity.SecurityConfig{}}
+	noSecurity := &entity.Command{ID: "lights"}
+
+	tests := []struct {
+		name      string
+		security  config.SecurityConfig
+		cmd       *entity.Command
+		wantAllow bool
+	}{
+		{"default allow, whitelisted", config.SecurityConfig{}, whitelisted, true},
+		{"default allow, not whitelisted", config.SecurityConfig{}, notWhitelisted, true},
+		{"default allow, no security settings", config.SecurityConfig{}, noSecurity, true},
+		{"default deny, whitelisted", config.SecurityConfig{DefaultDeny: true}, whitelisted, true},
+		{"default deny, not whitelisted", config.SecurityConfig{DefaultDeny: true}, notWhitelisted, false},
+		{"default deny, no security settings", config.SecurityConfig{DefaultDeny: true}, noSecurity, false},
+		{"default deny, in allowed commands", config.SecurityConfig{DefaultDeny: true, EnableWhitelist: true, AllowedCommands: []string{"lights"}}, noSecurity, true},
+		{"default deny, in allowed commands without enable_whitelist", config.SecurityConfig{DefaultDeny: true, AllowedCommands: []string{"lights"}}, noSecurity, true},
+		{"allowed commands, listed", config.SecurityConfig{EnableWhitelist: true, AllowedCommands: []string{"lights"}}, notWhitelisted, true},
+		{"allowed commands, not listed", config.SecurityConfig{EnableWhitelist: true, AllowedCommands: []string{"uptime"}}, whitelisted, false},
+		{"allowed commands without enable_whitelist", config.SecurityConfig{AllowedCommands: []string{"uptime"}}, whitelisted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(&config.Config{Security: tt.security})
+			err := s.CheckCommandAccess(tt.cmd)
+			if (err == nil) != tt.wantAllow {
+				t.Fatalf("CheckCommandAccess() = %v, want allowed %v", err, tt.wantAllow)
+			}
+			if err != nil && !errors.Is(err, common.ErrCommandNotAllowed) {
+				t.Errorf("error %v is not ErrCommandNotAllowed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package update
+
+import (
+	"os"
+	"syscall"
+)
+
+// restart execs exe in place of the current process, keeping its PID so
+// service managers keep tracking the agent
+func restart(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,473 @@
+// Package update replaces the agent binary with a signed release pushed by the
+// cloud and restarts into it, restoring the previous binary if that fails
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// Update states reported by Status, followed by the version and any error
+const (
+	StateDisabled    = "disabled"
+	StateIdle        = "idle"
+	StateDownloading = "downloading"
+	StateRestarting  = "restarting"
+	StateUpdated     = "updated"
+	StateFailed      = "failed"
+	StateRolledBack  = "rolled_back"
+)
+
+// ConfirmDelay is how long an updated agent has to run before the update is
+// kept; failing to start before that restores the previous binary
+const ConfirmDelay = 30 * time.Second
+
+// probeTimeout bounds running the downloaded binary with -version
+const probeTimeout = 10 * time.Second
+
+// Suffixes of the files kept next to the executable during an update
+const (
+	backupSuffix = ".old"
+	markerSuffix = ".update.json"
+)
+
+// Marker states
+const (
+	markerPending    = "pending"     // new binary installed, not confirmed yet
+	markerRolledBack = "rolled_back" // previous binary restored
+)
+
+// Request describes the release to install
+type Request struct {
+	URL       string
+	SHA256    string // hex digest of the binary
+	Signature string // base64 Ed25519 signature of SignedMessage
+	Version   string // must be newer than the running agent
+}
+
+// SignedMessage returns what a release signature covers: the raw SHA-256
+// digest of the binary followed by its version. Binding the version keeps a
+// signed old release from being replayed as a downgrade
+func SignedMessage(digest []byte, version string) []byte {
+	return append(append([]byte{}, digest...), version...)
+}
+
+// marker survives the restart so the next process knows how the update went
+type marker struct {
+	State           string `json:"state"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previousVersion"`
+	Starts          int    `json:"starts,omitempty"` // starts of the new binary while pending
+	Error           string `json:"error,omitempty"`
+}
+
+// errUnconfirmedStart fails a start of an update whose previous start never
+// confirmed, so the caller rolls it back
+var errUnconfirmedStart = errors.New("updated agent did not confirm its previous start")
+
+// Service downloads, verifies and installs agent releases
+type Service struct {
+	exe       string // resolved at startup; once renamed, the running image reports the backup path
+	enabled   bool
+	publicKey ed25519.PublicKey
+	maxSize   int64
+	client    *http.Client
+	logger    *logrus.Logger
+
+	mutex   sync.Mutex
+	status  string
+	busy    bool
+	restart chan struct{}
+}
+
+// NewService creates the update service and reports a rollback done by the
+// previous process. It fails when a pending update was already started once
+// without confirming, as the agent then crashed, was killed or hung; Rollback
+// restores the previous binary
+func NewService(cfg config.UpdateConfig, logger *logrus.Logger) (*Service, error) {
+	s := &Service{
+		enabled: cfg.Enabled,
+		maxSize: cfg.MaxSize,
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		logger:  logger,
+		status:  StateIdle,
+		restart: make(chan struct{}),
+	}
+	exe, err := executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate agent binary: %w", err)
+	}
+	s.exe = exe
+	if err := recordStart(exe); err != nil {
+		return nil, err
+	}
+
+	if !cfg.Enabled {
+		s.status = StateDisabled
+	} else {
+		key, err := base64.StdEncoding.DecodeString(cfg.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("update.public_key must be a base64 Ed25519 public key")
+		}
+		s.publicKey = key
+	}
+
+	if m, err := readMarker(exe); err == nil && m.State == markerRolledBack {
+		s.status = fmt.Sprintf("%s %s: %s", StateRolledBack, m.Version, m.Error)
+		logger.WithFields(logrus.Fields{
+			"version": m.Version,
+			"error":   m.Error,
+		}).Warn("Agent update was rolled back")
+		os.Remove(exe + markerSuffix)
+	}
+	return s, nil
+}
+
+// Status describes the last or current update, e.g. "downloading 1.2.0"
+func (s *Service) Status() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+// RestartRequested is closed once a release is installed; the application
+// then shuts down and calls Restart
+func (s *Service) RestartRequested() <-chan struct{} {
+	return s.restart
+}
+
+// Start verifies the request and installs the release in the background
+func (s *Service) Start(req Request) error {
+	if !s.enabled {
+		return common.ErrUpdateDisabled
+	}
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	digest, err := hex.DecodeString(req.SHA256)
+	if err != nil || len(digest) != sha256.Size {
+		return errors.New("sha256 must be a hex SHA-256 digest")
+	}
+	newer, err := newerVersion(req.Version, common.AppVersion)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		return fmt.Errorf("version %s is not newer than the running %s", req.Version, common.AppVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil || !ed25519.Verify(s.publicKey, SignedMessage(digest, req.Version), signature) {
+		return errors.New("invalid release signature")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.busy {
+		return common.ErrUpdateInProgress
+	}
+	s.busy = true
+	s.status = StateDownloading + " " + req.Version
+
+	go s.run(req, digest)
+	return nil
+}
+
+// ConfirmStartup keeps an update installed by the previous process once this
+// one has run for ConfirmDelay
+func (s *Service) ConfirmStartup() {
+	exe := s.exe
+	m, err := readMarker(exe)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.WithError(err).Warn("Failed to read agent update marker")
+		}
+		return
+	}
+	if m.State != markerPending {
+		return
+	}
+
+	if err := os.Remove(exe + backupSuffix); err != nil && !os.IsNotExist(err) {
+		s.logger.WithError(err).Warn("Failed to remove previous agent binary")
+	}
+	os.Remove(exe + markerSuffix)
+
+	s.mutex.Lock()
+	s.status = fmt.Sprintf("%s %s -> %s", StateUpdated, m.PreviousVersion, common.AppVersion)
+	s.mutex.Unlock()
+	s.logger.WithFields(logrus.Fields{
+		"previous_version": m.PreviousVersion,
+		"version":          common.AppVersion,
+	}).Info("Agent update confirmed")
+}
+
+// run installs the release and asks the application to restart into it
+func (s *Service) run(req Request, digest []byte) {
+	logger := s.logger.WithField("version", req.Version)
+
+	if err := s.install(req, digest); err != nil {
+		logger.WithError(err).Error("Agent update failed")
+		s.mutex.Lock()
+		s.status = StateFailed + ": " + err.Error()
+		s.busy = false
+		s.mutex.Unlock()
+		return
+	}
+
+	logger.Info("Agent update installed, restarting")
+	s.mutex.Lock()
+	s.status = StateRestarting + " " + req.Version
+	s.mutex.Unlock()
+	close(s.restart)
+}
+
+// install swaps the running executable for the verified release, keeping the
+// previous binary until the new one confirms its startup
+func (s *Service) install(req Request, digest []byte) error {
+	exe := s.exe
+
+	// Same directory so the final rename cannot cross file systems; same
+	// extension so Windows still treats it as an executable
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update-*"+filepath.Ext(exe))
+	if err != nil {
+		return fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = s.download(req.URL, tmp, digest)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := probe(tmp.Name()); err != nil {
+		return fmt.Errorf("new binary does not run: %w", err)
+	}
+
+	if err := writeMarker(exe, marker{
+		State:           markerPending,
+		Version:         req.Version,
+		PreviousVersion: common.AppVersion,
+	}); err != nil {
+		return fmt.Errorf("failed to write update marker: %w", err)
+	}
+	if err := os.Rename(exe, exe+backupSuffix); err != nil {
+		os.Remove(exe + markerSuffix)
+		return fmt.Errorf("failed to back up current binary: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		os.Rename(exe+backupSuffix, exe)
+		os.Remove(exe + markerSuffix)
+		return fmt.Errorf("failed to install new binary: %w", err)
+	}
+	return nil
+}
+
+// download writes the release to w and checks it against digest
+func (s *Service) download(rawURL string, w io.Writer, digest []byte) error {
+	resp, err := s.client.Get(rawURL)
+	if err != nil {
+		// The error repeats the URL, whose query may hold credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: status %d", resp.StatusCode)
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), io.LimitReader(resp.Body, s.maxSize+1))
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if n > s.maxSize {
+		return fmt.Errorf("binary exceeds %d bytes", s.maxSize)
+	}
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+// Restart replaces the process with the installed binary. It only returns if
+// neither that binary nor the restored previous one could be started
+func (s *Service) Restart() error {
+	err := restart(s.exe)
+	if rollbackErr := rollback(s.exe, err); rollbackErr != nil {
+		return fmt.Errorf("restart failed: %v; rollback failed: %w", err, rollbackErr)
+	}
+	return restart(s.exe)
+}
+
+// Rollback restores the previous binary when the update installed by the
+// previous process has not been confirmed, and restarts into it. Without a
+// pending update it does nothing
+func Rollback(cause error) error {
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+	if m, err := readMarker(exe); err != nil || m.State != markerPending {
+		return nil
+	}
+
+	if err := rollback(exe, cause); err != nil {
+		return err
+	}
+	return restart(exe)
+}
+
+// recordStart counts a start of a pending update in its marker, failing with
+// errUnconfirmedStart when an earlier start of it did not confirm
+func recordStart(exe string) error {
+	m, err := readMarker(exe)
+	if err != nil || m.State != markerPending {
+		return nil
+	}
+	if m.Starts > 0 {
+		return errUnconfirmedStart
+	}
+	m.Starts++
+	if err := writeMarker(exe, m); err != nil {
+		return fmt.Errorf("failed to write update marker: %w", err)
+	}
+	return nil
+}
+
+// rollback moves the backup over the new binary and records why
+func rollback(exe string, cause error) error {
+	m, err := readMarker(exe)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(exe+backupSuffix, exe); err != nil {
+		return err
+	}
+	m.State = markerRolledBack
+	m.Error = cause.Error()
+	return writeMarker(exe, m)
+}
+
+// newerVersion reports whether version is newer than current. Both are
+// MAJOR.MINOR.PATCH with an optional "v" prefix and "-prerelease" suffix; a
+// prerelease sorts before its release
+func newerVersion(version, current string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	c, err := parseVersion(current)
+	if err != nil {
+		return false, fmt.Errorf("invalid running version %q: %w", current, err)
+	}
+	for i := range v.numbers {
+		if v.numbers[i] != c.numbers[i] {
+			return v.numbers[i] > c.numbers[i], nil
+		}
+	}
+	switch {
+	case v.prerelease == c.prerelease:
+		return false, nil
+	case v.prerelease == "":
+		return true, nil
+	case c.prerelease == "":
+		return false, nil
+	}
+	return v.prerelease > c.prerelease, nil
+}
+
+type version struct {
+	numbers    [3]int
+	prerelease string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, v.prerelease, _ = strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != len(v.numbers) {
+		return v, errors.New("want MAJOR.MINOR.PATCH")
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, errors.New("want MAJOR.MINOR.PATCH")
+		}
+		v.numbers[i] = n
+	}
+	return v, nil
+}
+
+// probe checks that the binary starts on this platform
+func probe(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// executable returns the path of the running binary with symlinks resolved
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+func readMarker(exe string) (marker, error) {
+	var m marker
+	data, err := os.ReadFile(exe + markerSuffix)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+func writeMarker(exe string, m marker) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(exe+markerSuffix, data, 0644)
+}
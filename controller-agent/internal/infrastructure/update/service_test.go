@@ -0,0 +1,193 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		current string
+		want    bool
+		wantErr bool
+	}{
+		{"2.0.1", "2.0.0", true, false},
+		{"2.1.0", "2.0.9", true, false},
+		{"10.0.0", "9.9.9", true, false},
+		{"v2.0.1", "2.0.0", true, false},
+		{"2.0.0", "2.0.0", false, false},
+		{"1.9.9", "2.0.0", false, false},
+		{"2.0.0", "2.0.0-rc1", true, false},
+		{"2.0.0-rc1", "2.0.0", false, false},
+		{"2.0.0-rc2", "2.0.0-rc1", true, false},
+		{"", "2.0.0", false, true},
+		{"2.0", "2.0.0", false, true},
+		{"2.0.x", "2.0.0", false, true},
+		{"2.0.1", "dev", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version+" vs "+tt.current, func(t *testing.T) {
+			got, err := newerVersion(tt.version, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("newerVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartVerifiesSignedVersion(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// The release is never served, so an accepted update fails in the background
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	digest := sha256.Sum256([]byte("release binary"))
+	sign := func(message []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message))
+	}
+	newer := "99.0.0"
+
+	tests := []struct {
+		name      string
+		version   string
+		signature string
+		wantErr   string // empty when the update must start
+	}{
+		{"signed newer release", newer, sign(SignedMessage(digest[:], newer)), ""},
+		{"signature over the digest only", newer, sign(digest[:]), "invalid release signature"},
+		{"signature for another version", newer, sign(SignedMessage(digest[:], "98.0.0")), "invalid release signature"},
+		{"signed current version", common.AppVersion, sign(SignedMessage(digest[:], common.AppVersion)), "not newer"},
+		{"signed older version", "0.0.1", sign(SignedMessage(digest[:], "0.0.1")), "not newer"},
+		{"missing version", "", sign(SignedMessage(digest[:], "")), "invalid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewService(config.UpdateConfig{
+				Enabled:   true,
+				PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+				Timeout:   5,
+				MaxSize:   1024,
+			}, logger)
+			if err != nil {
+				t.Fatalf("create service: %v", err)
+			}
+
+			err = s.Start(Request{
+				URL:       server.URL + "/agent",
+				SHA256:    hex.EncodeToString(digest[:]),
+				Signature: tt.signature,
+				Version:   tt.version,
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Start() = %v, want the update to start", err)
+				}
+				waitForState(t, s, StateFailed)
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Start() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if status := s.Status(); status != StateIdle {
+				t.Fatalf("status = %q after a rejected update, want %q", status, StateIdle)
+			}
+		})
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s, err := NewService(config.UpdateConfig{}, logger)
+	if err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	if err := s.Start(Request{}); !errors.Is(err, common.ErrUpdateDisabled) {
+		t.Fatalf("Start() = %v, want %v", err, common.ErrUpdateDisabled)
+	}
+}
+
+// waitForState waits for the background update to reach state
+func waitForState(t *testing.T, s *Service, state string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasPrefix(s.Status(), state) {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %q, want %q", s.Status(), state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecordStartRollsBackUnconfirmedStart(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(exe, []byte("new"), 0755); err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+	if err := os.WriteFile(exe+backupSuffix, []byte("old"), 0755); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+
+	// Without a pending update starts are not counted
+	if err := recordStart(exe); err != nil {
+		t.Fatalf("recordStart() without a marker = %v", err)
+	}
+	if err := writeMarker(exe, marker{State: markerRolledBack, Version: "2.0.0"}); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if err := recordStart(exe); err != nil {
+		t.Fatalf("recordStart() after a rollback = %v", err)
+	}
+
+	if err := writeMarker(exe, marker{State: markerPending, Version: "2.0.0", PreviousVersion: "1.0.0"}); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if err := recordStart(exe); err != nil {
+		t.Fatalf("first start of the update = %v", err)
+	}
+	if m, err := readMarker(exe); err != nil || m.Starts != 1 {
+		t.Fatalf("marker after the first start = %+v, %v, want one start", m, err)
+	}
+
+	// The first start died without confirming, so the second one fails and
+	// the previous binary comes back
+	err := recordStart(exe)
+	if !errors.Is(err, errUnconfirmedStart) {
+		t.Fatalf("second start of the update = %v, want %v", err, errUnconfirmedStart)
+	}
+	if err := rollback(exe, err); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if data, err := os.ReadFile(exe); err != nil || string(data) != "old" {
+		t.Fatalf("binary after rollback = %q, %v, want the previous one", data, err)
+	}
+	if m, err := readMarker(exe); err != nil || m.State != markerRolledBack || m.Error != errUnconfirmedStart.Error() {
+		t.Fatalf("marker after rollback = %+v, %v", m, err)
+	}
+}
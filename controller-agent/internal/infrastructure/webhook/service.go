@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// SignatureHeader carries "sha256=" followed by the hex HMAC-SHA256 of the body
+const SignatureHeader = "X-Webhook-Signature"
+
+// retryBackoff is the delay before the first retry; it doubles for each further one
+const retryBackoff = time.Second
+
+// Payload is the JSON body POSTed to webhooks after an execution
+type Payload struct {
+	RunID     string    `json:"runId"`
+	CommandID string    `json:"commandId"`
+	Category  string    `json:"category,omitempty"`
+	Success   bool      `json:"success"`
+	ExitCode  int       `json:"exitCode"`
+	Duration  int64     `json:"duration"` // milliseconds
+	Output    string    `json:"output"`   // first max_output bytes
+	Truncated bool      `json:"truncated,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Service POSTs execution results to the configured endpoints in the background
+type Service struct {
+	endpoints  []config.WebhookEndpoint
+	secret     []byte
+	maxRetries int
+	maxOutput  int
+	client     *http.Client
+	logger     *logrus.Logger
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+}
+
+// NewService creates a webhook service for the configured endpoints
+func NewService(cfg config.WebhooksConfig, logger *logrus.Logger) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		endpoints:  cfg.Endpoints,
+		secret:     []byte(cfg.Secret),
+		maxRetries: cfg.MaxRetries,
+		maxOutput:  cfg.MaxOutput,
+		client:     &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		logger:     logger,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// RecordExecution notifies the matching endpoints of an execution without
+// waiting for the deliveries
+func (s *Service) RecordExecution(opts executor.ExecuteOptions, result *executor.ExecutionResult) {
+	var urls []string
+	for _, endpoint := range s.endpoints {
+		if matches(endpoint, opts.CommandID, opts.Category) {
+			urls = append(urls, endpoint.URL)
+		}
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	output, truncated := result.Output, result.Truncated
+	if s.maxOutput > 0 && len(output) > s.maxOutput {
+		output, truncated = output[:s.maxOutput], true
+	}
+	body, err := json.Marshal(Payload{
+		RunID:     result.RunID,
+		CommandID: opts.CommandID,
+		Category:  opts.Category,
+		Success:   result.Success,
+		ExitCode:  result.ExitCode,
+		Duration:  result.ExecutionTime.Milliseconds(),
+		Output:    output,
+		Truncated: truncated,
+		Error:     result.Error,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to encode webhook payload")
+		return
+	}
+
+	for _, url := range urls {
+		s.pending.Add(1)
+		go func(url string) {
+			defer s.pending.Done()
+			s.deliver(url, body)
+		}(url)
+	}
+}
+
+// Close abandons pending retries and waits for deliveries in flight
+func (s *Service) Close() {
+	s.cancel()
+	s.pending.Wait()
+}
+
+// deliver POSTs body to url, retrying failed attempts with exponential backoff
+func (s *Service) deliver(url string, body []byte) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.post(url, body)
+		if err == nil {
+			return
+		}
+		if attempt >= s.maxRetries {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"url":      url,
+				"attempts": attempt + 1,
+			}).Warn("Webhook delivery failed")
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// post makes a single delivery attempt; any non-2xx status is a failure. It
+// is bounded by the client timeout rather than s.ctx, so Close lets attempts
+// in flight finish
+func (s *Service) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Signature(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Signature returns the hex HMAC-SHA256 of body, as sent in SignatureHeader
+func Signature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// matches reports whether the endpoint's filters select the command
+func matches(endpoint config.WebhookEndpoint, commandID, category string) bool {
+	if len(endpoint.Commands) > 0 && !contains(endpoint.Commands, commandID) {
+		return false
+	}
+	if len(endpoint.Categories) > 0 && !contains(endpoint.Categories, category) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
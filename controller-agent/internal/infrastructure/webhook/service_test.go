@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// delivery is a request received by a webhookReceiver
+type delivery struct {
+	signature string
+	body      []byte
+}
+
+// webhookReceiver is an endpoint recording what it receives. It answers the
+// first failures requests with 500 and the rest with 204
+type webhookReceiver struct {
+	mutex      sync.Mutex
+	failures   int
+	deliveries []delivery
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deliveries = append(r.deliveries, delivery{signature: req.Header.Get(SignatureHeader), body: body})
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *webhookReceiver) received() []delivery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+func newTestService(cfg config.WebhooksConfig) *Service {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5
+	}
+	return NewService(cfg, logger)
+}
+
+func testResult() *executor.ExecutionResult {
+	return &executor.ExecutionResult{
+		RunID:         "run-1",
+		Success:       true,
+		Output:        "hello world",
+		ExitCode:      0,
+		ExecutionTime: 1500 * time.Millisecond,
+	}
+}
+
+func TestDeliverySignedPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		maxOutput  int
+		wantOutput string
+		truncated  bool
+	}{
+		{"signed", "webhook-secret", 0, "hello world", false},
+		{"unsigned without a secret", "", 0, "hello world", false},
+		{"output cut to max_output", "webhook-secret", 5, "hello", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			s := newTestService(config.WebhooksConfig{
+				Secret:    tt.secret,
+				MaxOutput: tt.maxOutput,
+				Endpoints: []config.WebhookEndpoint{{URL: server.URL}},
+			})
+			s.RecordExecution(executor.ExecuteOptions{CommandID: "greet", Category: "demo"}, testResult())
+			s.Close()
+
+			deliveries := receiver.received()
+			if len(deliveries) != 1 {
+				t.Fatalf("got %d deliveries, want 1", len(deliveries))
+			}
+			got := deliveries[0]
+
+			if tt.secret == "" {
+				if got.signature != "" {
+					t.Fatalf("unsigned delivery carries %s %q", SignatureHeader, got.signature)
+				}
+			} else {
+				mac := hmac.New(sha256.New, []byte(tt.secret))
+				mac.Write(got.body)
+				if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got.signature != want {
+					t.Fatalf("%s = %q, want %q", SignatureHeader, got.signature, want)
+				}
+			}
+
+			var payload Payload
+			if err := json.Unmarshal(got.body, &payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			if payload.RunID != "run-1" || payload.CommandID != "greet" || payload.Category != "demo" ||
+				!payload.Success || payload.Duration != 1500 {
+				t.Fatalf("payload = %+v", payload)
+			}
+			if payload.Output != tt.wantOutput || payload.Truncated != tt.truncated {
+				t.Fatalf("output = %q truncated %v, want %q truncated %v", payload.Output, payload.Truncated, tt.wantOutput, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		maxRetries int
+		want       int
+	}{
+		{"delivered first time", 0, 2, 1},
+		{"retried after a failure", 1, 2, 2},
+		{"gives up after max_retries", 5, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{failures: tt.failures}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			s := newTestService(config.WebhooksConfig{
+				MaxRetries: tt.maxRetries,
+				Endpoints:  []config.WebhookEndpoint{{URL: server.URL}},
+			})
+			s.RecordExecution(executor.ExecuteOptions{CommandID: "greet"}, testResult())
+
+			deadline := time.Now().Add(5 * time.Second)
+			for len(receiver.received()) < tt.want && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			s.Close()
+			if got := len(receiver.received()); got != tt.want {
+				t.Fatalf("got %d delivery attempts, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint config.WebhookEndpoint
+		command  string
+		category string
+		want     bool
+	}{
+		{"no filters", config.WebhookEndpoint{}, "greet", "demo", true},
+		{"listed command", config.WebhookEndpoint{Commands: []string{"greet"}}, "greet", "", true},
+		{"other command", config.WebhookEndpoint{Commands: []string{"reboot"}}, "greet", "demo", false},
+		{"listed category", config.WebhookEndpoint{Categories: []string{"demo"}}, "greet", "demo", true},
+		{"other category", config.WebhookEndpoint{Categories: []string{"power"}}, "greet", "demo", false},
+		{"both filters must match", config.WebhookEndpoint{Commands: []string{"greet"}, Categories: []string{"power"}}, "greet", "demo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matches(tt.endpoint, tt.command, tt.category); got != tt.want {
+				t.Fatalf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordExecutionSkipsUnmatchedEndpoints(t *testing.T) {
+	matched, unmatched := &webhookReceiver{}, &webhookReceiver{}
+	matchedServer, unmatchedServer := httptest.NewServer(matched), httptest.NewServer(unmatched)
+	defer matchedServer.Close()
+	defer unmatchedServer.Close()
+
+	s := newTestService(config.WebhooksConfig{Endpoints: []config.WebhookEndpoint{
+		{URL: matchedServer.URL, Commands: []string{"greet"}},
+		{URL: unmatchedServer.URL, Commands: []string{"reboot"}},
+	}})
+	s.RecordExecution(executor.ExecuteOptions{CommandID: "greet"}, testResult())
+	s.Close()
+
+	if got := len(matched.received()); got != 1 {
+		t.Fatalf("matching endpoint got %d deliveries, want 1", got)
+	}
+	if got := len(unmatched.received()); got != 0 {
+		t.Fatalf("other endpoint got %d deliveries, want 0", got)
+	}
+}
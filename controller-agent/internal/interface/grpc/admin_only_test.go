@@ -0,0 +1,218 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// hashTestPin returns the bcrypt hash of pin at the lowest cost to keep the
+// tests fast
+func hashTestPin(t *testing.T, pin string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash PIN: %v", err)
+	}
+	return string(hash)
+}
+
+// newAdminOnlyServer returns a server with the PIN 1234 and the admin PIN 9999
+// over a plain, a PIN-protected and an admin-only command
+func newAdminOnlyServer(t *testing.T) *Server {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range []*entity.Command{
+		{ID: "plain", Name: "Plain", Command: "echo ok", Platform: runtime.GOOS},
+		{ID: "pinned", Name: "Pinned", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{RequirePin: true}},
+		{ID: "admin", Name: "Admin", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{AdminOnly: true}},
+	} {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+
+	cfg := &config.Config{Security: config.SecurityConfig{PinRequired: true, Pin: "1234", AdminPinHash: hashTestPin(t, "9999")}}
+	return NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+}
+
+func TestExecuteCommandPinAndAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		role     string // caller_role of the request
+		id       string
+		wantCode codes.Code
+	}{
+		{"plain", context.Background(), "", "plain", codes.OK},
+		{"pinned without PIN", context.Background(), "", "pinned", codes.Unauthenticated},
+		{"pinned with wrong PIN", tenantContext(pinMetadataKey, "0000"), "", "pinned", codes.Unauthenticated},
+		{"pinned with PIN", tenantContext(pinMetadataKey, "1234"), "", "pinned", codes.OK},
+		{"admin without PIN", context.Background(), "", "admin", codes.PermissionDenied},
+		{"admin with regular PIN only", tenantContext(pinMetadataKey, "1234"), "", "admin", codes.PermissionDenied},
+		{"admin with wrong admin PIN", tenantContext(adminPinMetadataKey, "0000"), "", "admin", codes.PermissionDenied},
+		{"admin with the regular PIN as admin PIN", tenantContext(adminPinMetadataKey, "1234"), "", "admin", codes.PermissionDenied},
+		{"admin with admin PIN", tenantContext(adminPinMetadataKey, "9999"), "", "admin", codes.OK},
+		{"admin role claimed without the cloud", context.Background(), callerRoleAdmin, "admin", codes.PermissionDenied},
+		{"pinned over tunnel", WithTunnelCaller(context.Background()), callerRoleUser, "pinned", codes.OK},
+		{"admin over tunnel for a device user", WithTunnelCaller(context.Background()), callerRoleUser, "admin", codes.PermissionDenied},
+		{"admin over tunnel without a role", WithTunnelCaller(context.Background()), "", "admin", codes.PermissionDenied},
+		{"admin over tunnel for a device admin", WithTunnelCaller(context.Background()), callerRoleAdmin, "admin", codes.OK},
+		{"admin over tunnel for the device owner", WithTunnelCaller(context.Background()), callerRoleOwner, "admin", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A server per case keeps wrong PINs from throttling the next one
+			s := newAdminOnlyServer(t)
+			resp, err := s.ExecuteCommand(tt.ctx, &pb.ExecuteCommandRequest{CommandId: tt.id, CallerRole: tt.role})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err == nil && !resp.Success {
+				t.Errorf("execution failed: %s", resp.Error)
+			}
+
+			// Dry runs are checked the same way
+			_, err = s.ExecuteCommand(tt.ctx, &pb.ExecuteCommandRequest{CommandId: tt.id, CallerRole: tt.role, DryRun: true})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("dry run code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestListCommandsHidesAdminOnly(t *testing.T) {
+	s := newAdminOnlyServer(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		role string // caller_role of the request
+		want string
+	}{
+		{"no PIN", context.Background(), "", "pinned,plain"},
+		{"wrong admin PIN", tenantContext(adminPinMetadataKey, "0000"), "", "pinned,plain"},
+		{"regular PIN as admin PIN", tenantContext(adminPinMetadataKey, "1234"), "", "pinned,plain"},
+		{"admin PIN", tenantContext(adminPinMetadataKey, "9999"), "", "admin,pinned,plain"},
+		{"admin role claimed without the cloud", context.Background(), callerRoleAdmin, "pinned,plain"},
+		{"tunnel for a device user", WithTunnelCaller(context.Background()), callerRoleUser, "pinned,plain"},
+		{"tunnel for a device admin", WithTunnelCaller(context.Background()), callerRoleAdmin, "admin,pinned,plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.ListCommands(tt.ctx, &pb.ListCommandsRequest{CallerRole: tt.role})
+			if err != nil {
+				t.Fatalf("ListCommands: %v", err)
+			}
+			var got []string
+			for _, cmd := range resp.Commands {
+				got = append(got, cmd.Id)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("commands = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinAndAdminOverEachTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		serve     func(*testing.T, *Server) pb.ControllerServiceClient
+		role      string // caller_role the cloud sends
+		wantCode  codes.Code
+		wantAdmin bool
+	}{
+		{"direct mTLS from the cloud for a device admin", serveMTLSTestServer, callerRoleAdmin, codes.OK, true},
+		{"direct mTLS from the cloud for a device user", serveMTLSTestServer, callerRoleUser, codes.OK, false},
+		{"direct without a client certificate", serveTestServer, callerRoleAdmin, codes.Unauthenticated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAdminOnlyServer(t)
+			client := tt.serve(t, s)
+
+			// The cloud gateway sends the user's device role, never PIN metadata
+			_, err := client.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: "pinned", TimeoutSeconds: 5, CallerRole: tt.role})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("pinned code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			_, err = client.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: "admin", TimeoutSeconds: 5, CallerRole: tt.role})
+			if ok := err == nil; ok != tt.wantAdmin {
+				t.Fatalf("admin executed = %v, want %v: %v", ok, tt.wantAdmin, err)
+			}
+
+			resp, err := client.ListCommands(context.Background(), &pb.ListCommandsRequest{CallerRole: tt.role})
+			if err != nil {
+				t.Fatalf("ListCommands: %v", err)
+			}
+			listed := false
+			for _, cmd := range resp.Commands {
+				listed = listed || cmd.Id == "admin"
+			}
+			if listed != tt.wantAdmin {
+				t.Errorf("admin listed = %v, want %v", listed, tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandPinFailureThrottling(t *testing.T) {
+	s := newAdminOnlyServer(t)
+	withPeer := func(key, pin string) context.Context {
+		return peer.NewContext(tenantContext(key, pin), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5000}})
+	}
+
+	for i := 0; i < 5; i++ {
+		key := pinMetadataKey
+		id := "pinned"
+		if i%2 == 1 {
+			key, id = adminPinMetadataKey, "admin"
+		}
+		if _, err := s.ExecuteCommand(withPeer(key, "0000"), &pb.ExecuteCommandRequest{CommandId: id}); status.Code(err) == codes.OK {
+			t.Fatalf("wrong PIN %d accepted", i+1)
+		}
+	}
+
+	// Once throttled even the right PIN is refused, and admins are not listed
+	if _, err := s.ExecuteCommand(withPeer(pinMetadataKey, "1234"), &pb.ExecuteCommandRequest{CommandId: "pinned"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("right PIN after the limit: code = %v, want ResourceExhausted", status.Code(err))
+	}
+	resp, err := s.ListCommands(withPeer(adminPinMetadataKey, "9999"), &pb.ListCommandsRequest{})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	for _, cmd := range resp.Commands {
+		if cmd.Id == "admin" {
+			t.Fatal("throttled caller listed admin-only commands")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandAlias(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	cmd := &entity.Command{ID: "lights", Name: "Lights", Command: "echo toggled", Platform: runtime.GOOS, Aliases: []string{"lamp"}}
+	if err := repo.Create(context.Background(), cmd); err != nil {
+		t.Fatalf("create lights: %v", err)
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	cfg := &config.Config{}
+	s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+	for _, id := range []string{"lights", "lamp"} {
+		t.Run(id, func(t *testing.T) {
+			resp, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: id})
+			if err != nil {
+				t.Fatalf("ExecuteCommand(%s): %v", id, err)
+			}
+			if !resp.Success || resp.CommandId != "lights" || resp.Output != "toggled\n" {
+				t.Errorf("success %v, commandId %q, output %q; want the canonical ID lights", resp.Success, resp.CommandId, resp.Output)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandAllowedHours(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	now := time.Now()
+	window := func(from, to time.Duration) *entity.AllowedHoursConfig {
+		return &entity.AllowedHoursConfig{Start: now.Add(from).Format("15:04"), End: now.Add(to).Format("15:04")}
+	}
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	commands := []*entity.Command{
+		{ID: "inside", Name: "Inside", Command: "echo ok", Platform: runtime.GOOS, AllowedHours: window(-time.Hour, time.Hour)},
+		{ID: "outside", Name: "Outside", Command: "echo ok", Platform: runtime.GOOS, AllowedHours: window(time.Hour, 2*time.Hour)},
+	}
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	cfg := &config.Config{}
+	s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+	tests := []struct {
+		id       string
+		wantCode codes.Code
+	}{
+		{"inside", codes.OK},
+		{"outside", codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: tt.id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "next allowed at ") {
+				t.Errorf("error %q does not report the next allowed time", err)
+			}
+		})
+	}
+}
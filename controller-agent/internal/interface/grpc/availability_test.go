@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestListCommandsPlatformSupported(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+
+	// Availability and whitelisting disagree for every command but "here"
+	tests := []struct {
+		cmd             *entity.Command
+		wantSupported   bool
+		wantWhitelisted bool
+	}{
+		{&entity.Command{ID: "here", Command: "echo", Platform: runtime.GOOS}, true, true},
+		{&entity.Command{ID: "here-not-whitelisted", Command: "echo", Platform: runtime.GOOS, Security: &entity.SecurityConfig{}}, true, false},
+		{&entity.Command{ID: "elsewhere", Command: "echo", Platform: other}, false, true},
+		{&entity.Command{ID: "variant", Command: "echo", Platform: other, Platforms: map[string]string{runtime.GOOS: "echo"}, Security: &entity.SecurityConfig{}}, true, false},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, tt := range tests {
+		if err := repo.Create(context.Background(), tt.cmd); err != nil {
+			t.Fatalf("create %s: %v", tt.cmd.ID, err)
+		}
+	}
+	cfg := &config.Config{}
+	s := NewServer(cfg, logger, service.NewCommandService(repo), nil, security.NewService(cfg, logger), nil, nil, nil)
+
+	resp, err := s.ListCommands(context.Background(), &pb.ListCommandsRequest{})
+	if err != nil {
+		t.Fatalf("ListCommands: %v", err)
+	}
+	listed := make(map[string]*pb.CommandInfo, len(resp.Commands))
+	for _, cmd := range resp.Commands {
+		listed[cmd.Id] = cmd
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd.ID, func(t *testing.T) {
+			got, ok := listed[tt.cmd.ID]
+			if !ok {
+				t.Fatal("command not listed")
+			}
+			if got.PlatformSupported != tt.wantSupported {
+				t.Errorf("platform_supported = %v, want %v", got.PlatformSupported, tt.wantSupported)
+			}
+			if got.Whitelisted != tt.wantWhitelisted {
+				t.Errorf("whitelisted = %v, want %v", got.Whitelisted, tt.wantWhitelisted)
+			}
+		})
+	}
+}
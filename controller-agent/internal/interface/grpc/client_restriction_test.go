@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandAllowedClients(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	commands := []*entity.Command{
+		{ID: "by-id", Name: "By ID", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedClientIDs: []string{"kitchen"}}},
+		{ID: "by-ip", Name: "By IP", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedIPs: []string{"192.0.2.0/24"}}},
+	}
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	cfg := &config.Config{}
+	s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+	tests := []struct {
+		name     string
+		id       string
+		clientID string
+		peerIP   string
+		wantCode codes.Code
+	}{
+		{"allowed client ID", "by-id", "kitchen", "203.0.113.5", codes.OK},
+		{"other client ID", "by-id", "garage", "203.0.113.5", codes.PermissionDenied},
+		{"no client ID", "by-id", "", "203.0.113.5", codes.PermissionDenied},
+		{"allowed network", "by-ip", "", "192.0.2.10", codes.OK},
+		{"other network", "by-ip", "kitchen", "203.0.113.5", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.peerIP), Port: 4000}})
+			if tt.clientID != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(clientIDMetadataKey, tt.clientID))
+			}
+			_, err := s.ExecuteCommand(ctx, &pb.ExecuteCommandRequest{CommandId: tt.id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+		})
+	}
+}
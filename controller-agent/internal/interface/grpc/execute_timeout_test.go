@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandTimeoutClamped(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range []*entity.Command{
+		{ID: "slow", Name: "Slow", Command: "sleep 5", Platform: runtime.GOOS},
+		{ID: "capped", Name: "Capped", Command: "sleep 5", Platform: runtime.GOOS, MaxTimeout: 200},
+	} {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		serverMax int // milliseconds
+		id        string
+	}{
+		{"global max", 200, "slow"},
+		{"command max", 60000, "capped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Commands: config.CommandsConfig{MaxTimeout: tt.serverMax}}
+			s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+			// Asking for an hour is capped, not rejected
+			start := time.Now()
+			resp, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: tt.id, TimeoutSeconds: 3600})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("ExecuteCommand: %v", err)
+			}
+			if resp.Success {
+				t.Errorf("sleep 5 succeeded, want it stopped at the ceiling")
+			}
+			if elapsed > 3*time.Second {
+				t.Errorf("execution took %v, want it stopped after 200ms", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// UploadFile writes the streamed chunks to a file in an allowed directory. The
+// first chunk names the file and carries the admin PIN
+func (s *Server) UploadFile(stream grpc.ClientStreamingServer[pb.UploadFileChunk, pb.UploadFileResponse]) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Errorf(codes.InvalidArgument, "no file chunk received")
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.securityService.VerifyAdminPin(peerIP(stream.Context()), first.Pin); err != nil {
+		return pinError(err, codes.PermissionDenied, "file transfers require a valid admin PIN")
+	}
+
+	result, err := s.files.Upload(first.Path, &chunkReader{stream: stream, data: first.Data}, first.Overwrite)
+	if err != nil {
+		return fileStatus(err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"path":      result.Path,
+		"size":      result.Size,
+		"client_ip": peerAddress(stream.Context()),
+	}).Warn("File uploaded over gRPC")
+	return stream.SendAndClose(&pb.UploadFileResponse{
+		Path:   result.Path,
+		Size:   result.Size,
+		Sha256: result.SHA256,
+	})
+}
+
+// DownloadFile streams a file from an allowed directory in chunks, the first of
+// which carries the file size
+func (s *Server) DownloadFile(req *pb.DownloadFileRequest, stream grpc.ServerStreamingServer[pb.FileChunk]) error {
+	if err := s.securityService.VerifyAdminPin(peerIP(stream.Context()), req.Pin); err != nil {
+		return pinError(err, codes.PermissionDenied, "file transfers require a valid admin PIN")
+	}
+
+	file, info, err := s.files.Open(req.Path)
+	if err != nil {
+		return fileStatus(err)
+	}
+	defer file.Close()
+
+	s.logger.WithFields(logrus.Fields{
+		"path":      file.Name(),
+		"size":      info.Size(),
+		"client_ip": peerAddress(stream.Context()),
+	}).Info("File downloaded over gRPC")
+
+	buf := make([]byte, files.ChunkSize)
+	sent := false
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk := &pb.FileChunk{Data: buf[:n]}
+			if !sent {
+				chunk.Size = info.Size()
+			}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			sent = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read file: %s", err.Error())
+		}
+	}
+	// An empty file still gets the chunk reporting its size
+	if !sent {
+		return stream.Send(&pb.FileChunk{Size: info.Size()})
+	}
+	return nil
+}
+
+// chunkReader reads the data of an upload stream, starting with the data of
+// the chunk already received
+type chunkReader struct {
+	stream grpc.ClientStreamingServer[pb.UploadFileChunk, pb.UploadFileResponse]
+	data   []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = chunk.Data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// fileStatus maps a file service error to a gRPC status
+func fileStatus(err error) error {
+	switch {
+	case errors.Is(err, common.ErrFilesDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, common.ErrInvalidFilePath):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, common.ErrFileNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, common.ErrFileExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, common.ErrFileTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "file transfer failed: %s", err.Error())
+}
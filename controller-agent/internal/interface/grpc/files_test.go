@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestFileTransferPinFailureThrottling(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.Pin = "4321"
+	cfg.Security.AdminPinHash = hashTestPin(t, "4321")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := serveTestServer(t, NewServer(cfg, logger, nil, nil, security.NewService(cfg, logger), nil, nil, nil))
+
+	// download returns the status the first streamed message fails with
+	download := func(pin string) codes.Code {
+		stream, err := client.DownloadFile(context.Background(), &pb.DownloadFileRequest{Path: "/tmp/file", Pin: pin})
+		if err != nil {
+			return status.Code(err)
+		}
+		_, err = stream.Recv()
+		return status.Code(err)
+	}
+	upload := func(pin string) codes.Code {
+		stream, err := client.UploadFile(context.Background())
+		if err != nil {
+			return status.Code(err)
+		}
+		if err := stream.Send(&pb.UploadFileChunk{Path: "/tmp/file", Pin: pin}); err != nil {
+			return status.Code(err)
+		}
+		_, err = stream.CloseAndRecv()
+		return status.Code(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		transfer := download
+		if i%2 == 1 {
+			transfer = upload
+		}
+		if code := transfer("0000"); code != codes.PermissionDenied {
+			t.Fatalf("wrong PIN %d: code = %v, want PermissionDenied", i+1, code)
+		}
+	}
+
+	// Once throttled even the right PIN is refused without being checked
+	if code := download("4321"); code != codes.ResourceExhausted {
+		t.Fatalf("download after the limit: code = %v, want ResourceExhausted", code)
+	}
+	if code := upload("4321"); code != codes.ResourceExhausted {
+		t.Fatalf("upload after the limit: code = %v, want ResourceExhausted", code)
+	}
+}
@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// healthCheckInterval is how often the standard health service re-evaluates the agent
+const healthCheckInterval = 15 * time.Second
+
+// healthCheckTimeout bounds loading the commands during a health check
+const healthCheckTimeout = 5 * time.Second
+
+// runHealthChecks keeps the standard health status of the server ("") and of the
+// controller service current until stop is closed
+func (s *Server) runHealthChecks(healthServer *health.Server, stop <-chan struct{}) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		status := s.servingStatus()
+		healthServer.SetServingStatus("", status)
+		healthServer.SetServingStatus(pb.ControllerService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// servingStatus reports SERVING when commands can be loaded and executed
+func (s *Server) servingStatus() healthpb.HealthCheckResponse_ServingStatus {
+	if s.executorService == nil || s.commandService == nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	if _, err := s.commandService.GetAllCommands(ctx); err != nil {
+		s.logger.WithError(err).Warn("gRPC health check failed to load commands")
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_SERVING
+}
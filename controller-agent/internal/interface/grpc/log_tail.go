@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// logTailPollInterval is how often the log file is checked for new lines
+const logTailPollInterval = 500 * time.Millisecond
+
+// TailLogs streams lines appended to the agent log file until the client disconnects.
+// Lines are sent no faster than the configured tail rate; the file buffers the rest
+func (s *Server) TailLogs(req *pb.TailLogsRequest, stream grpc.ServerStreamingServer[pb.LogLine]) error {
+	if err := s.securityService.VerifyAdminPin(peerIP(stream.Context()), req.Pin); err != nil {
+		return pinError(err, codes.PermissionDenied, "tailing logs requires a valid admin PIN")
+	}
+
+	path := s.config.Log.OutputPath
+	if path == "" {
+		return status.Errorf(codes.FailedPrecondition, "file logging is not enabled")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to open log file: %s", err.Error())
+	}
+	defer func() { file.Close() }()
+
+	// Only lines written after the client connects are streamed
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to seek log file: %s", err.Error())
+	}
+
+	var interval time.Duration
+	if s.config.Log.TailRate > 0 {
+		interval = time.Second / time.Duration(s.config.Log.TailRate)
+	}
+
+	ctx := stream.Context()
+	clientIP := peerAddress(ctx)
+	s.logger.WithField("client_ip", clientIP).Info("Log tail started")
+	defer s.logger.WithField("client_ip", clientIP).Info("Log tail stopped")
+
+	reader := bufio.NewReader(file)
+	partial := ""
+	var lastSent time.Time
+	for {
+		chunk, err := reader.ReadString('\n')
+		offset += int64(len(chunk))
+
+		if err == nil {
+			if wait := interval - time.Since(lastSent); interval > 0 && wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+
+			line := strings.TrimRight(partial+chunk, "\r\n")
+			partial = ""
+			if err := stream.Send(&pb.LogLine{Line: line, Timestamp: time.Now().Unix()}); err != nil {
+				return err
+			}
+			lastSent = time.Now()
+			continue
+		}
+		if err != io.EOF {
+			return status.Errorf(codes.Internal, "failed to read log file: %s", err.Error())
+		}
+
+		// Keep an incomplete trailing line until the rest of it is written
+		partial += chunk
+
+		select {
+		case <-time.After(logTailPollInterval):
+		case <-ctx.Done():
+			return nil
+		}
+
+		if logFileReplaced(file, path, offset) {
+			reopened, err := os.Open(path)
+			if err != nil {
+				// The new file may not exist yet mid-rotation
+				continue
+			}
+			file.Close()
+			file = reopened
+			reader.Reset(file)
+			offset = 0
+			partial = ""
+			s.logger.WithFields(logrus.Fields{
+				"client_ip": clientIP,
+				"log_file":  path,
+			}).Debug("Log file rotated, tail restarted")
+		}
+	}
+}
+
+// logFileReplaced reports whether the log at path was rotated or truncated
+// since it was opened as file and read up to offset
+func logFileReplaced(file *os.File, path string, offset int64) bool {
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	opened, err := file.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(opened, current) || current.Size() < offset
+}
@@ -0,0 +1,251 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// serveTestServer serves s over an in-memory connection and returns a client
+func serveTestServer(t *testing.T, s *Server) pb.ControllerServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterControllerServiceServer(server, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewControllerServiceClient(conn)
+}
+
+// newLogTailServer returns a server logging to a file in a temporary
+// directory, and the path of that file
+func newLogTailServer(t *testing.T, tailRate int) (*Server, string, *test.Hook) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.log")
+	if err := os.WriteFile(path, []byte("written before the tail\n"), 0o644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.Security.Pin = "4321"
+	cfg.Security.AdminPinHash = hashTestPin(t, "4321")
+	cfg.Log.OutputPath = path
+	cfg.Log.TailRate = tailRate
+	logger, hook := test.NewNullLogger()
+	return NewServer(cfg, logger, nil, nil, security.NewService(cfg, logger), nil, nil, nil), path, hook
+}
+
+// appendLog appends text to the log file
+func appendLog(t *testing.T, path, text string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatalf("append log: %v", err)
+	}
+}
+
+// startTail opens a tail and waits until the server has positioned it at
+// the end of the file, so later writes are streamed
+func startTail(t *testing.T, ctx context.Context, client pb.ControllerServiceClient, hook *test.Hook) grpc.ServerStreamingClient[pb.LogLine] {
+	t.Helper()
+	stream, err := client.TailLogs(ctx, &pb.TailLogsRequest{Pin: "4321"})
+	if err != nil {
+		t.Fatalf("TailLogs: %v", err)
+	}
+	waitForLog(t, hook, "Log tail started")
+	return stream
+}
+
+// waitForLog waits until the server logs message
+func waitForLog(t *testing.T, hook *test.Hook, message string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == message {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server never logged %q", message)
+}
+
+func TestTailLogsRejects(t *testing.T) {
+	s, _, _ := newLogTailServer(t, 0)
+	noFile, _, _ := newLogTailServer(t, 0)
+	noFile.config.Log.OutputPath = ""
+	missing, _, _ := newLogTailServer(t, 0)
+	missing.config.Log.OutputPath = filepath.Join(t.TempDir(), "missing.log")
+
+	tests := []struct {
+		name   string
+		server *Server
+		pin    string
+		want   codes.Code
+	}{
+		{"no PIN", s, "", codes.PermissionDenied},
+		{"wrong PIN", s, "0000", codes.PermissionDenied},
+		{"file logging disabled", noFile, "4321", codes.FailedPrecondition},
+		{"log file missing", missing, "4321", codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := serveTestServer(t, tt.server).TailLogs(context.Background(), &pb.TailLogsRequest{Pin: tt.pin})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("code = %v, want %v: %v", code, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTailLogsPinFailureThrottling(t *testing.T) {
+	s, _, _ := newLogTailServer(t, 0)
+	client := serveTestServer(t, s)
+	tail := func(pin string) codes.Code {
+		stream, err := client.TailLogs(context.Background(), &pb.TailLogsRequest{Pin: pin})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		return status.Code(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := tail("0000"); code != codes.PermissionDenied {
+			t.Fatalf("wrong PIN %d: code = %v, want PermissionDenied", i+1, code)
+		}
+	}
+
+	// Once throttled even the right PIN is refused without being checked
+	if code := tail("4321"); code != codes.ResourceExhausted {
+		t.Fatalf("right PIN after the limit: code = %v, want ResourceExhausted", code)
+	}
+}
+
+func TestTailLogsStreamsNewLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{"single line", []string{"agent started\n"}, []string{"agent started"}},
+		{"several lines at once", []string{"one\ntwo\nthree\n"}, []string{"one", "two", "three"}},
+		{"line written in parts", []string{"half a ", "line\n"}, []string{"half a line"}},
+		{"CRLF endings", []string{"windows line\r\n"}, []string{"windows line"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, path, hook := newLogTailServer(t, 0)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stream := startTail(t, ctx, serveTestServer(t, s), hook)
+
+			for i, write := range tt.writes {
+				if i > 0 {
+					// Let the tail read the earlier part first
+					time.Sleep(2 * logTailPollInterval)
+				}
+				appendLog(t, path, write)
+			}
+			for _, want := range tt.want {
+				line, err := stream.Recv()
+				if err != nil {
+					t.Fatalf("Recv: %v", err)
+				}
+				if line.Line != want {
+					t.Fatalf("line = %q, want %q", line.Line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTailLogsRateCap(t *testing.T) {
+	const rate = 5 // lines per second, so lines arrive at least 200ms apart
+	s, path, hook := newLogTailServer(t, rate)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := startTail(t, ctx, serveTestServer(t, s), hook)
+
+	appendLog(t, path, "1\n2\n3\n4\n")
+	var first time.Time
+	for i := 0; i < 4; i++ {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if i == 0 {
+			first = time.Now()
+		}
+	}
+	if elapsed, min := time.Since(first), 3*time.Second/rate; elapsed < min-20*time.Millisecond {
+		t.Errorf("4 lines took %v after the first, want at least %v at %d lines/s", elapsed, min, rate)
+	}
+}
+
+func TestTailLogsStopsOnDisconnect(t *testing.T) {
+	s, path, hook := newLogTailServer(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := startTail(t, ctx, serveTestServer(t, s), hook)
+
+	appendLog(t, path, "before disconnect\n")
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	cancel()
+	waitForLog(t, hook, "Log tail stopped")
+
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled && err != io.EOF {
+		t.Errorf("Recv after disconnect = %v, want cancelled", err)
+	}
+}
+
+func TestTailLogsFollowsRotation(t *testing.T) {
+	s, path, hook := newLogTailServer(t, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := startTail(t, ctx, serveTestServer(t, s), hook)
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("after rotation\n"), 0o644); err != nil {
+		t.Fatalf("write new log: %v", err)
+	}
+
+	line, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if line.Line != "after rotation" {
+		t.Errorf("line = %q, want the new file's first line", line.Line)
+	}
+}
@@ -2,25 +2,68 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
 	"github.com/sirupsen/logrus"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/update"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
 )
 
+// clientIDMetadataKey carries the caller's client ID for per-command restrictions
+const clientIDMetadataKey = "x-client-id"
+
+// Tenancy mode scopes commands to the tenant of the x-tenant-token metadata;
+// a valid x-admin-pin sees every command
+const (
+	tenantTokenMetadataKey = "x-tenant-token"
+	adminPinMetadataKey    = "x-admin-pin"
+)
+
+// pinMetadataKey carries the PIN for commands that require one
+const pinMetadataKey = "x-pin"
+
+// Device roles the cloud names in caller_role for the user it acts for; only
+// admins and owners may run and see admin-only commands
+const (
+	callerRoleOwner = "owner"
+	callerRoleAdmin = "admin"
+	callerRoleUser  = "user"
+)
+
+// keepaliveMinTime is the shortest client keepalive interval accepted; the
+// cloud gateway pings idle connections to notice dropped NAT mappings
+const keepaliveMinTime = 10 * time.Second
+
 // Server represents the gRPC server
 type Server struct {
 	pb.UnimplementedControllerServiceServer
@@ -29,8 +72,13 @@ type Server struct {
 	commandService  *service.CommandService
 	executorService *executor.Service
 	securityService *security.Service
+	maintenance     *maintenance.Service
+	updates         *update.Service
+	files           *files.Service
 	grpcServer      *grpc.Server
-	startTime       time.Time
+	healthServer    *health.Server
+	stopHealth      chan struct{}
+	status          atomic.Value // common.Status* of the listener
 }
 
 // NewServer creates a new gRPC server instance
@@ -40,6 +88,9 @@ func NewServer(
 	commandService *service.CommandService,
 	executorService *executor.Service,
 	securityService *security.Service,
+	maintenanceService *maintenance.Service,
+	updateService *update.Service,
+	fileService *files.Service,
 ) *Server {
 	return &Server{
 		config:          cfg,
@@ -47,59 +98,141 @@ func NewServer(
 		commandService:  commandService,
 		executorService: executorService,
 		securityService: securityService,
-		startTime:       time.Now(),
+		maintenance:     maintenanceService,
+		updates:         updateService,
+		files:           fileService,
 	}
 }
 
 // Start starts the gRPC server
 func (s *Server) Start() error {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+	}
+
+	if s.config.Server.GRPC.TLS.Enabled {
+		creds, err := loadServerCredentials(s.config.Server.GRPC.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to load gRPC TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+		s.logger.WithField("mtls", s.config.Server.GRPC.TLS.RequireClientCert).Info("gRPC TLS enabled")
+	}
+
 	listen, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.config.Server.GRPC.Host, s.config.Server.GRPC.Port))
 	if err != nil {
+		s.status.Store(common.StatusUnhealthy)
 		return fmt.Errorf("failed to listen on gRPC port: %w", err)
 	}
+	s.status.Store(common.StatusHealthy)
 
-	s.grpcServer = grpc.NewServer(
-		grpc.UnaryInterceptor(s.unaryInterceptor),
-	)
+	s.grpcServer = grpc.NewServer(opts...)
 
 	pb.RegisterControllerServiceServer(s.grpcServer, s)
 
+	s.healthServer = health.NewServer()
+	healthpb.RegisterHealthServer(s.grpcServer, s.healthServer)
+	s.stopHealth = make(chan struct{})
+	go s.runHealthChecks(s.healthServer, s.stopHealth)
+
+	// Reflection exposes the full API to anyone who can connect, so it is opt-in
+	if s.config.Server.GRPC.Reflection {
+		reflection.Register(s.grpcServer)
+		s.logger.Warn("gRPC reflection enabled, disable it in production")
+	}
+
 	s.logger.WithField("addr", listen.Addr().String()).Info("Starting gRPC server")
 
 	if err := s.grpcServer.Serve(listen); err != nil {
+		s.status.Store(common.StatusUnhealthy)
 		return fmt.Errorf("gRPC server failed: %w", err)
 	}
 
 	return nil
 }
 
+// Status reports whether the server is listening
+func (s *Server) Status() string {
+	if status, ok := s.status.Load().(string); ok {
+		return status
+	}
+	return common.StatusStarting
+}
+
 // Stop stops the gRPC server gracefully
 func (s *Server) Stop() {
 	s.logger.Info("Stopping gRPC server")
+	s.status.Store(common.StatusStopping)
+	if s.healthServer != nil {
+		close(s.stopHealth)
+		// Report NOT_SERVING to watchers while in-flight calls drain
+		s.healthServer.Shutdown()
+	}
 	if s.grpcServer != nil {
 		s.grpcServer.GracefulStop()
 	}
 }
 
+// loadServerCredentials builds TLS credentials for the gRPC server, requiring
+// and verifying client certificates when mTLS is enabled
+func loadServerCredentials(cfg config.GRPCTLSConfig) (credentials.TransportCredentials, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("cert_file and key_file are required when TLS is enabled")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate %s: %w", cfg.CertFile, err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.RequireClientCert {
+		if cfg.ClientCAFile == "" {
+			return nil, fmt.Errorf("client_ca_file is required when client certificates are required")
+		}
+
+		caPEM, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file %s: %w", cfg.ClientCAFile, err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
 // unaryInterceptor provides common middleware for all gRPC calls
 func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	start := time.Now()
 
 	// Extract client info
-	peer, _ := peer.FromContext(ctx)
-	clientIP := "unknown"
-	if peer != nil {
-		clientIP = peer.Addr.String()
-	}
-
-	// Rate limiting
-	if err := s.securityService.CheckRateLimit(clientIP); err != nil {
-		s.logger.WithFields(logrus.Fields{
-			"method":    info.FullMethod,
-			"client_ip": clientIP,
-			"error":     err.Error(),
-		}).Warn("gRPC rate limit exceeded")
-		return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+	clientIP := peerAddress(ctx)
+
+	// Rate limiting; health probes are exempt so frequent probing cannot lock clients out
+	if !strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		if err := s.securityService.CheckRateLimit(clientIP); err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"method":    info.FullMethod,
+				"client_ip": clientIP,
+				"error":     err.Error(),
+			}).Warn("gRPC rate limit exceeded")
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+		}
 	}
 
 	// Call the handler
@@ -116,6 +249,103 @@ func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *gr
 	return resp, err
 }
 
+// peerAddress returns the calling client's address, or "unknown" when absent
+// (e.g. requests arriving over the tunnel)
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// peerIP returns the calling client's IP without the port, or "" when absent
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// metadataValue returns the first value of an incoming metadata key
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// tunnelCallerKey marks calls relayed over the cloud tunnel
+type tunnelCallerKey struct{}
+
+// WithTunnelCaller marks ctx as relayed over the cloud tunnel. The agent dials
+// the tunnel itself with its device credential, so relayed calls carry the
+// cloud's authority instead of per-call PINs
+func WithTunnelCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tunnelCallerKey{}, true)
+}
+
+// fromTunnel reports whether ctx was relayed over the cloud tunnel
+func fromTunnel(ctx context.Context) bool {
+	relayed, _ := ctx.Value(tunnelCallerKey{}).(bool)
+	return relayed
+}
+
+// fromCloud reports whether the call carries the cloud's authority: it was
+// relayed over the tunnel, or the caller dialed directly with a client
+// certificate verified against client_ca_file under mTLS
+func (s *Server) fromCloud(ctx context.Context) bool {
+	if fromTunnel(ctx) {
+		return true
+	}
+	tlsConfig := s.config.Server.GRPC.TLS
+	if !tlsConfig.Enabled || !tlsConfig.RequireClientCert {
+		return false
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(tlsInfo.State.VerifiedChains) > 0
+}
+
+// cloudAdmin reports whether the cloud made the call for an admin or owner
+// of this device. The cloud names the user's role in callerRole; the role of
+// any other caller is ignored
+func (s *Server) cloudAdmin(ctx context.Context, callerRole string) bool {
+	return s.fromCloud(ctx) && (callerRole == callerRoleAdmin || callerRole == callerRoleOwner)
+}
+
+// isAdminCaller reports whether the cloud made the call for a device admin or
+// the call carries a valid x-admin-pin
+func (s *Server) isAdminCaller(ctx context.Context, callerRole string) bool {
+	return s.cloudAdmin(ctx, callerRole) || s.securityService.IsAdmin(peerIP(ctx), metadataValue(ctx, adminPinMetadataKey))
+}
+
+// pinError converts a failed PIN check into a status: ResourceExhausted once
+// the caller sent too many wrong PINs, code with message otherwise
+func pinError(err error, code codes.Code, message string) error {
+	if errors.Is(err, common.ErrTooManyPinFailures) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(code, message)
+}
+
+// tenantScope returns the principal a call is scoped to in tenancy mode, or nil
+// when every command is visible
+func (s *Server) tenantScope(ctx context.Context) *entity.Principal {
+	return s.securityService.TenantScope(peerIP(ctx), metadataValue(ctx, tenantTokenMetadataKey), metadataValue(ctx, adminPinMetadataKey))
+}
+
 // ExecuteCommand executes a command via gRPC
 func (s *Server) ExecuteCommand(ctx context.Context, req *pb.ExecuteCommandRequest) (*pb.ExecuteCommandResponse, error) {
 	if req.CommandId == "" {
@@ -127,28 +357,142 @@ func (s *Server) ExecuteCommand(ctx context.Context, req *pb.ExecuteCommandReque
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "command not found: %s", req.CommandId)
 	}
+	if principal := s.tenantScope(ctx); principal != nil && !cmd.VisibleTo(*principal) {
+		return nil, status.Errorf(codes.NotFound, "command not found: %s", req.CommandId)
+	}
+
+	if !cmd.IsClientAllowed(metadataValue(ctx, clientIDMetadataKey), peerIP(ctx)) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s: %s", common.ErrClientNotAllowed.Error(), req.CommandId)
+	}
+
+	// PIN verification if required; the cloud acts without PINs
+	if cmd.RequiresPin() && !s.fromCloud(ctx) {
+		if err := s.securityService.VerifyPin(peerIP(ctx), metadataValue(ctx, pinMetadataKey)); err != nil {
+			return nil, pinError(err, codes.Unauthenticated, "invalid or missing PIN")
+		}
+	}
+
+	// Admin-only commands need the admin PIN in addition to any regular PIN,
+	// unless the cloud acts for an admin of the device
+	if cmd.RequiresAdmin() && !s.cloudAdmin(ctx, req.CallerRole) {
+		if err := s.securityService.VerifyAdminPin(peerIP(ctx), metadataValue(ctx, adminPinMetadataKey)); err != nil {
+			return nil, pinError(err, codes.PermissionDenied, common.ErrAdminRequired.Error())
+		}
+	}
 
 	// Get platform command
-	platformCommand, err := s.commandService.GetPlatformCommand(ctx, req.CommandId)
+	platformCommand, err := s.commandService.GetPlatformCommand(ctx, cmd.ID)
 	if err != nil {
 		return nil, status.Errorf(codes.FailedPrecondition, "command not available: %s", err.Error())
 	}
 
+	// The request carries no params, so the command's template params are used
+	env, err := cmd.ExecutionEnv(nil)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	executeOptions := executor.ExecuteOptions{
+		WorkingDir: cmd.WorkingDir,
+		Env:        env,
+		CommandID:  cmd.ID,
+		Publish:    cmd.PublishResults,
+		LoginShell: cmd.LoginShell,
+		Shell:      cmd.Shell,
+		Args:       cmd.Args,
+		Tenant:     cmd.Tenant(),
+		UserID:     s.securityService.TenantUserID(metadataValue(ctx, tenantTokenMetadataKey)),
+		Category:   cmd.Category,
+		Stdin:      req.Stdin,
+
+		PreHook:     cmd.PreHook,
+		PostHook:    cmd.PostHook,
+		StrictHooks: cmd.StrictHooks,
+
+		OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+		OutputFormat:        cmd.OutputFormat,
+		Retry:               cmd.Retry,
+		DependsOn:           cmd.DependsOn,
+		AutoRunDeps:         cmd.AutoRunDeps,
+
+		Source:      audit.InterfaceGRPC,
+		ClientIP:    peerAddress(ctx),
+		RequiresPin: cmd.RequiresPin(),
+	}
+
+	if req.DryRun {
+		preview := s.executorService.DryRun(platformCommand, executeOptions)
+		if err := s.securityService.CheckCommandAccess(cmd); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		} else if !cmd.IsWhitelisted() {
+			preview.Warnings = append(preview.Warnings, "command is not whitelisted")
+		}
+		if err := s.securityService.CheckAllowedHours(cmd); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		}
+		if err := s.maintenance.CheckExecution(); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		}
+		return &pb.ExecuteCommandResponse{
+			CommandId: cmd.ID,
+			Success:   true,
+			DryRun:    true,
+			Command:   preview.Command,
+			Args:      preview.Args,
+			Warnings:  preview.Warnings,
+		}, nil
+	}
+
+	if err := s.maintenance.CheckExecution(); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if err := s.securityService.CheckCommandAccess(cmd); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if err := s.securityService.CheckAllowedHours(cmd); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if cmd.HasRateLimit() {
+		window := time.Duration(cmd.RateLimit.Window) * time.Second
+		if err := s.securityService.CheckCommandRateLimit(cmd.ID, cmd.RateLimit.MaxExecutions, window); err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+	}
+
 	// Execute with timeout
-	timeout := time.Duration(cmd.GetTimeout()) * time.Millisecond
-	if req.TimeoutSeconds > 0 {
-		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	// Requests cannot raise the timeout past the command's or the agent's ceiling
+	override := time.Duration(req.TimeoutSeconds) * time.Second
+	timeout, clamped := cmd.ExecutionTimeout(override, s.config.Commands.MaxExecutionTimeout())
+	if clamped {
+		s.logger.WithFields(logrus.Fields{
+			"command_id": cmd.ID,
+			"requested":  override,
+			"timeout":    timeout,
+		}).Info("Execution timeout clamped to the maximum")
 	}
 	
 	executeCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	startTime := time.Now()
-	result, err := s.executorService.Execute(executeCtx, platformCommand)
+	result, err := s.executorService.ExecuteWithOptions(executeCtx, platformCommand, executeOptions)
 	executionTime := time.Since(startTime)
 	
+	if errors.Is(err, executor.ErrStdinTooLarge) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, executor.ErrTenantBusy) || errors.Is(err, executor.ErrExecutorBusy) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, executor.ErrDependencyNotSatisfied) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
 	if err != nil {
 		return &pb.ExecuteCommandResponse{
+			CommandId:       cmd.ID,
 			Success:         false,
 			Output:          "",
 			Error:           err.Error(),
@@ -157,13 +501,27 @@ func (s *Server) ExecuteCommand(ctx context.Context, req *pb.ExecuteCommandReque
 		}, nil
 	}
 
-	return &pb.ExecuteCommandResponse{
+	response := &pb.ExecuteCommandResponse{
+		RunId:           result.RunID,
+		CommandId:       cmd.ID,
 		Success:         result.Success,
 		Output:          result.Output,
 		Error:           result.Error,
 		ExitCode:        int32(result.ExitCode),
 		ExecutionTimeMs: executionTime.Milliseconds(),
-	}, nil
+		Truncated:       result.Truncated,
+		Signature:       result.Signature,
+		OutputWarning:   result.OutputWarning,
+	}
+	// Protobuf has no dynamic value type here, so parsed output travels as JSON
+	if result.Data != nil {
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode output data: %s", err.Error())
+		}
+		response.DataJson = string(data)
+	}
+	return response, nil
 }
 
 // ListCommands returns all available commands
@@ -172,15 +530,36 @@ func (s *Server) ListCommands(ctx context.Context, req *pb.ListCommandsRequest)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get commands: %s", err.Error())
 	}
+	principal := s.tenantScope(ctx)
+	admin := s.isAdminCaller(ctx, req.CallerRole)
 
-	pbCommands := make([]*pb.CommandInfo, len(commands))
-	for i, cmd := range commands {
-		pbCommands[i] = &pb.CommandInfo{
+	pbCommands := make([]*pb.CommandInfo, 0, len(commands))
+	for _, cmd := range commands {
+		if principal != nil && !cmd.VisibleTo(*principal) {
+			continue
+		}
+		// Admin-only commands stay hidden from callers who are not admins
+		if cmd.RequiresAdmin() && !admin {
+			continue
+		}
+		pbCommands = append(pbCommands, &pb.CommandInfo{
 			Id:                cmd.ID,
 			Description:       cmd.Description,
 			PlatformSupported: cmd.IsAvailableOnPlatform(),
 			PlatformCommand:   cmd.Command,
-		}
+			Name:              cmd.Name,
+			Category:          cmd.Category,
+			Icon:              cmd.Icon,
+			Platform:          cmd.Platform,
+			CommandType:       cmd.CommandType,
+			Timeout:           int32(cmd.GetTimeout()),
+			RequiresPin:       cmd.RequiresPin(),
+			Whitelisted:       cmd.IsWhitelisted(),
+			AdminOnly:         cmd.RequiresAdmin(),
+			WorkingDir:        cmd.WorkingDir,
+			Env:               cmd.Env,
+			OutputFormat:      cmd.OutputFormat,
+		})
 	}
 
 	return &pb.ListCommandsResponse{
@@ -206,12 +585,124 @@ func (s *Server) ReloadConfig(ctx context.Context, req *pb.ReloadConfigRequest)
 	}, nil
 }
 
+// SyncCommands applies the commands the cloud manages for this device
+func (s *Server) SyncCommands(ctx context.Context, req *pb.SyncCommandsRequest) (*pb.SyncCommandsResponse, error) {
+	// Syncs replace the command set, so callers other than the cloud need the
+	// admin PIN
+	if !s.fromCloud(ctx) {
+		if err := s.securityService.VerifyAdminPin(peerIP(ctx), metadataValue(ctx, adminPinMetadataKey)); err != nil {
+			return nil, pinError(err, codes.PermissionDenied, "syncing commands requires a valid admin PIN")
+		}
+	}
+
+	synced := make([]service.SyncedCommand, len(req.Commands))
+	for i, info := range req.Commands {
+		synced[i] = service.SyncedCommand{
+			ID:          info.Id,
+			Name:        info.Name,
+			Description: info.Description,
+			Category:    info.Category,
+			Icon:        info.Icon,
+			Command:     info.PlatformCommand,
+			Platform:    info.Platform,
+			CommandType: info.CommandType,
+			Timeout:     int(info.Timeout),
+			WorkingDir:  info.WorkingDir,
+			Env:         info.Env,
+			RequirePin:  info.RequiresPin,
+			Whitelist:   info.Whitelisted,
+			AdminOnly:   info.AdminOnly,
+		}
+	}
+
+	result, skipped, err := s.commandService.SyncCommands(ctx, synced, req.RemovedIds, req.Hash)
+	if err != nil {
+		var validationErr *service.ImportValidationError
+		if errors.As(err, &validationErr) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to sync commands: %s", err.Error())
+	}
+
+	if skipped {
+		return &pb.SyncCommandsResponse{
+			Success: true,
+			Message: "Commands already in sync",
+			Skipped: true,
+		}, nil
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"created": result.Created,
+		"updated": result.Updated,
+		"removed": result.Removed,
+	}).Info("Commands synced from cloud")
+
+	return &pb.SyncCommandsResponse{
+		Success: true,
+		Message: "Commands synced successfully",
+		Created: int32(result.Created),
+		Updated: int32(result.Updated),
+		Removed: int32(result.Removed),
+	}, nil
+}
+
+// UpdateAgent starts replacing the agent binary with a signed release; progress
+// is reported under the agent_update service of HealthCheck
+func (s *Server) UpdateAgent(ctx context.Context, req *pb.UpdateAgentRequest) (*pb.UpdateAgentResponse, error) {
+	err := s.updates.Start(update.Request{
+		URL:       req.Url,
+		SHA256:    req.Sha256,
+		Signature: req.Signature,
+		Version:   req.Version,
+	})
+	switch {
+	case errors.Is(err, common.ErrUpdateDisabled):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, common.ErrUpdateInProgress):
+		return nil, status.Error(codes.Aborted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.logger.WithField("version", req.Version).Info("Agent update requested")
+	return &pb.UpdateAgentResponse{
+		Accepted: true,
+		Message:  "Update started",
+	}, nil
+}
+
 // HealthCheck performs health check
 func (s *Server) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
 	return &pb.HealthCheckResponse{
 		Status:        "SERVING",
 		Version:       common.AppVersion,
-		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+		UptimeSeconds: int64(common.Uptime().Seconds()),
+		System: &pb.SystemInfo{
+			Os:           runtime.GOOS,
+			Architecture: runtime.GOARCH,
+			GoVersion:    runtime.Version(),
+			NumCpu:       int32(runtime.NumCPU()),
+			NumGoroutine: int32(runtime.NumGoroutine()),
+		},
+		Memory: map[string]int64{
+			"allocated":  int64(memStats.Alloc),
+			"totalAlloc": int64(memStats.TotalAlloc),
+			"sys":        int64(memStats.Sys),
+			"numGC":      int64(memStats.NumGC),
+		},
+		Services: s.serviceStatus(),
+	}, nil
+}
+
+// Ping echoes the client's timestamp so callers can measure round-trip latency
+func (s *Server) Ping(ctx context.Context, req *pb.PingRequest) (*pb.PingResponse, error) {
+	return &pb.PingResponse{
+		ClientTimeUnixNano: req.ClientTimeUnixNano,
+		ServerTimeUnixNano: time.Now().UnixNano(),
 	}, nil
 }
 
@@ -225,8 +716,12 @@ func (s *Server) VerifyPin(ctx context.Context, req *pb.VerifyPinRequest) (*pb.V
 		}, nil
 	}
 
-	isValid := s.securityService.ValidatePin(req.Pin)
-	
+	err := s.securityService.VerifyPin(peerIP(ctx), req.Pin)
+	if errors.Is(err, common.ErrTooManyPinFailures) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	isValid := err == nil
+
 	var message string
 	if isValid {
 		message = "PIN verification successful"
@@ -247,8 +742,8 @@ func (s *Server) GetVersion(ctx context.Context, req *pb.GetVersionRequest) (*pb
 		Success:    true,
 		Message:    "Version information retrieved successfully",
 		Version:    common.AppVersion,
-		BuildTime:  "", // TODO: Add build-time variable
-		CommitHash: "", // TODO: Add build-time variable
+		BuildTime:  common.BuildTime,
+		CommitHash: common.GitCommit,
 		GoVersion:  runtime.Version(),
 		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
 		ApiVersion: "v1",
@@ -267,14 +762,7 @@ func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.G
 	systemInfo["app_name"] = common.AppName
 	
 	// Get service status
-	serviceStatus := make(map[string]string)
-	serviceStatus["http_server"] = "running"
-	serviceStatus["grpc_server"] = "running"
-	if s.config.MQTT.Enabled {
-		serviceStatus["mqtt_client"] = "enabled"
-	} else {
-		serviceStatus["mqtt_client"] = "disabled"
-	}
+	serviceStatus := s.serviceStatus()
 	
 	// Note: Command count could be added to system info if needed
 	
@@ -282,7 +770,7 @@ func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.G
 		Success:       true,
 		Message:       "Status retrieved successfully",
 		Online:        true,
-		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+		UptimeSeconds: int64(common.Uptime().Seconds()),
 		CpuUsage:      0.0,    // TODO: Implement actual CPU usage monitoring
 		MemoryUsage:   0.0,    // TODO: Implement actual memory usage monitoring
 		DiskUsage:     0.0,    // TODO: Implement actual disk usage monitoring
@@ -290,4 +778,18 @@ func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.G
 		ServiceStatus: serviceStatus,
 		LastSeen:      time.Now().Unix(),
 	}, nil
-}
\ No newline at end of file
+}
+
+// serviceStatus reports the state of the agent's transports
+func (s *Server) serviceStatus() map[string]string {
+	serviceStatus := make(map[string]string)
+	serviceStatus["http_server"] = "running"
+	serviceStatus["grpc_server"] = "running"
+	if s.config.MQTT.Enabled {
+		serviceStatus["mqtt_client"] = "enabled"
+	} else {
+		serviceStatus["mqtt_client"] = "disabled"
+	}
+	serviceStatus["agent_update"] = s.updates.Status()
+	return serviceStatus
+}
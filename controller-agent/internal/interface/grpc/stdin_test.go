@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is cat")
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	if err := repo.Create(context.Background(), &entity.Command{ID: "cat", Name: "Cat", Command: "cat", Platform: runtime.GOOS}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	cfg := &config.Config{}
+	executorService := executor.NewService(logger)
+	executorService.SetMaxStdinSize(16)
+	s := NewServer(cfg, logger, service.NewCommandService(repo), executorService, security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+	tests := []struct {
+		name       string
+		stdin      []byte
+		wantCode   codes.Code
+		wantOutput string
+	}{
+		{"stdin echoed", []byte("piped input"), codes.OK, "piped input"},
+		{"binary stdin", []byte{0x00, 0xff, 0x10}, codes.OK, "\x00\xff\x10"},
+		{"no stdin", nil, codes.OK, ""},
+		{"stdin over the limit", []byte(strings.Repeat("x", 17)), codes.InvalidArgument, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: "cat", Stdin: tt.stdin})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", resp.Output, tt.wantOutput)
+			}
+		})
+	}
+}
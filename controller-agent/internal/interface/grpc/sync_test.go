@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// newSyncServer returns a server with the PIN and admin PIN 1234 whose command service
+// checks commands against the blocklist
+func newSyncServer(t *testing.T) *Server {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	commandService := service.NewCommandService(infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json")))
+	commandService.SetCommandValidator(executor.NewService(logger))
+	cfg := &config.Config{Security: config.SecurityConfig{Pin: "1234", AdminPinHash: hashTestPin(t, "1234")}}
+	return NewServer(cfg, logger, commandService, nil, security.NewService(cfg, logger), nil, nil, nil)
+}
+
+func TestSyncCommandsAuthentication(t *testing.T) {
+	safe := &pb.SyncCommandsRequest{Commands: []*pb.CommandInfo{{Id: "synced", Name: "Synced", PlatformCommand: "echo synced"}}}
+	dangerous := &pb.SyncCommandsRequest{Commands: []*pb.CommandInfo{{Id: "synced", Name: "Synced", PlatformCommand: "rm -rf /"}}}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		req      *pb.SyncCommandsRequest
+		wantCode codes.Code
+	}{
+		{"no PIN", context.Background(), safe, codes.PermissionDenied},
+		{"regular PIN metadata", tenantContext(pinMetadataKey, "1234"), safe, codes.PermissionDenied},
+		{"wrong admin PIN", tenantContext(adminPinMetadataKey, "0000"), safe, codes.PermissionDenied},
+		{"admin PIN", tenantContext(adminPinMetadataKey, "1234"), safe, codes.OK},
+		{"tunnel", WithTunnelCaller(context.Background()), safe, codes.OK},
+		{"dangerous with admin PIN", tenantContext(adminPinMetadataKey, "1234"), dangerous, codes.InvalidArgument},
+		{"dangerous over tunnel", WithTunnelCaller(context.Background()), dangerous, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSyncServer(t)
+
+			_, err := s.SyncCommands(tt.ctx, tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			_, err = s.commandService.GetCommand(context.Background(), "synced")
+			if stored := err == nil; stored != (tt.wantCode == codes.OK) {
+				t.Errorf("stored = %v, want %v", stored, tt.wantCode == codes.OK)
+			}
+		})
+	}
+}
+
+func TestSyncCommandsPinFailureThrottling(t *testing.T) {
+	s := newSyncServer(t)
+	ctx := peer.NewContext(tenantContext(adminPinMetadataKey, "0000"), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5000}})
+	req := &pb.SyncCommandsRequest{}
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.SyncCommands(ctx, req); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("attempt %d: code = %v, want PermissionDenied", i+1, status.Code(err))
+		}
+	}
+
+	// Once throttled even the right PIN is refused for the rest of the minute
+	ctx = peer.NewContext(tenantContext(adminPinMetadataKey, "1234"), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5001}})
+	if _, err := s.SyncCommands(ctx, req); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("code = %v, want ResourceExhausted", status.Code(err))
+	}
+}
+
+func TestSyncCommandsOverDirectMTLS(t *testing.T) {
+	s := newSyncServer(t)
+	client := serveMTLSTestServer(t, s)
+
+	// The cloud gateway dials directly and sends no PIN metadata
+	for i := 0; i < 10; i++ {
+		resp, err := client.SyncCommands(context.Background(), &pb.SyncCommandsRequest{
+			Commands: []*pb.CommandInfo{{Id: "synced", Name: "Synced", PlatformCommand: "echo synced"}},
+		})
+		if err != nil {
+			t.Fatalf("sync %d: %v", i+1, err)
+		}
+		if !resp.Success {
+			t.Fatalf("sync %d failed: %s", i+1, resp.Message)
+		}
+	}
+	if _, err := s.commandService.GetCommand(context.Background(), "synced"); err != nil {
+		t.Errorf("synced command not stored: %v", err)
+	}
+	if err := s.securityService.CheckPinFailures("127.0.0.1"); err != nil {
+		t.Errorf("cloud syncs counted as PIN failures: %v", err)
+	}
+}
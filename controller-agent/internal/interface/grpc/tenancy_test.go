@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// newTenancyServer returns a server in tenancy mode over alice's, bob's and
+// one shared command
+func newTenancyServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Security.Pin = "4321"
+	cfg.Security.AdminPinHash = hashTestPin(t, "4321")
+	cfg.Commands.Tenancy = true
+	cfg.Commands.Tenants = []config.TenantToken{
+		{Token: "alice-token", UserID: "alice"},
+		{Token: "bob-token", UserID: "bob"},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range []*entity.Command{
+		{ID: "alice-cmd", Name: "Alice", Command: "echo alice", UserID: "alice"},
+		{ID: "bob-cmd", Name: "Bob", Command: "echo bob", UserID: "bob"},
+		{ID: "shared-cmd", Name: "Shared", Command: "echo shared"},
+	} {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	return NewServer(cfg, logger, service.NewCommandService(repo), nil, security.NewService(cfg, logger), nil, nil, nil)
+}
+
+func tenantContext(pairs ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+}
+
+func TestListCommandsTenancy(t *testing.T) {
+	s := newTenancyServer(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"alice", tenantContext(tenantTokenMetadataKey, "alice-token"), "alice-cmd,shared-cmd"},
+		{"bob", tenantContext(tenantTokenMetadataKey, "bob-token"), "bob-cmd,shared-cmd"},
+		{"no token", context.Background(), "shared-cmd"},
+		{"admin", tenantContext(adminPinMetadataKey, "4321"), "alice-cmd,bob-cmd,shared-cmd"},
+		{"wrong admin PIN", tenantContext(adminPinMetadataKey, "0000"), "shared-cmd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.ListCommands(tt.ctx, &pb.ListCommandsRequest{})
+			if err != nil {
+				t.Fatalf("ListCommands: %v", err)
+			}
+			var got []string
+			for _, cmd := range resp.Commands {
+				got = append(got, cmd.Id)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("listed %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandHidesOtherTenants(t *testing.T) {
+	s := newTenancyServer(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		id   string
+	}{
+		{"other tenant's command", tenantContext(tenantTokenMetadataKey, "alice-token"), "bob-cmd"},
+		{"owned command without token", context.Background(), "alice-cmd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ExecuteCommand(tt.ctx, &pb.ExecuteCommandRequest{CommandId: tt.id})
+			if status.Code(err) != codes.NotFound {
+				t.Errorf("err = %v, want NotFound", err)
+			}
+		})
+	}
+}
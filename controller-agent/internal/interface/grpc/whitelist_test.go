@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+func TestExecuteCommandWhitelist(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	commands := []*entity.Command{
+		{ID: "whitelisted", Name: "Whitelisted", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true}},
+		{ID: "plain", Name: "Plain", Command: "echo ok", Platform: runtime.GOOS},
+		{ID: "listed", Name: "Listed", Command: "echo ok", Platform: runtime.GOOS},
+	}
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		defaultDeny bool
+		id          string
+		wantCode    codes.Code
+	}{
+		{"default allow, whitelisted", false, "whitelisted", codes.OK},
+		{"default allow, plain", false, "plain", codes.OK},
+		{"default deny, whitelisted", true, "whitelisted", codes.OK},
+		{"default deny, plain", true, "plain", codes.PermissionDenied},
+		{"default deny, in allowed commands", true, "listed", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Security: config.SecurityConfig{DefaultDeny: tt.defaultDeny, AllowedCommands: []string{"listed"}}}
+			s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, nil, nil)
+
+			_, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: tt.id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), common.ErrCommandNotAllowed.Error()) {
+				t.Errorf("error %q is not a command not allowed error", err)
+			}
+
+			// A dry run reports the rejection as a warning instead
+			resp, err := s.ExecuteCommand(context.Background(), &pb.ExecuteCommandRequest{CommandId: tt.id, DryRun: true})
+			if err != nil {
+				t.Fatalf("dry run: %v", err)
+			}
+			warned := false
+			for _, warning := range resp.GetWarnings() {
+				if strings.Contains(warning, common.ErrCommandNotAllowed.Error()) {
+					warned = true
+				}
+			}
+			if warned != (tt.wantCode != codes.OK) {
+				t.Errorf("dry run warnings = %q, want a command not allowed warning %v", resp.GetWarnings(), tt.wantCode != codes.OK)
+			}
+		})
+	}
+}
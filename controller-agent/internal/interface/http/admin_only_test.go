@@ -0,0 +1,191 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// Regular and separate admin PIN of newAdminOnlyHandler
+const (
+	testUserPin     = "1111"
+	testSeparatePin = "9999"
+)
+
+// newAdminOnlyHandler returns handlers over a regular, an admin-only and an
+// admin-only PIN-protected command. The admin PIN is testSeparatePin when
+// withAdminPin is set, otherwise none is configured
+func newAdminOnlyHandler(t *testing.T, withAdminPin bool) (*ExecuteHandler, *CommandHandler) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Security.PinRequired = true
+	cfg.Security.Pin = testUserPin
+	if withAdminPin {
+		hash, err := security.HashPin(testSeparatePin)
+		if err != nil {
+			t.Fatalf("hash admin PIN: %v", err)
+		}
+		cfg.Security.AdminPinHash = hash
+	}
+	repo := newTestRepository(t,
+		&entity.Command{ID: "uptime", Name: "Uptime", Command: "uptime", Platform: runtime.GOOS},
+		&entity.Command{ID: "reboot", Name: "Reboot", Command: "reboot", Platform: runtime.GOOS, Security: &entity.SecurityConfig{AdminOnly: true}},
+		&entity.Command{ID: "wipe", Name: "Wipe", Command: "wipe", Platform: runtime.GOOS, Security: &entity.SecurityConfig{AdminOnly: true, RequirePin: true}},
+	)
+	executeHandler := newTestExecuteHandler(t, cfg, repo)
+	return executeHandler, NewCommandHandler(executeHandler.commandService, executeHandler.securityService, true)
+}
+
+func TestPrepareExecutionRequiresAdminPin(t *testing.T) {
+	tests := []struct {
+		name     string
+		adminPin bool // whether an admin PIN is configured
+		req      ExecuteRequest
+		want     int // 0 when the command may run
+	}{
+		{"regular command", true, ExecuteRequest{ID: "uptime"}, 0},
+		{"admin-only without admin PIN", true, ExecuteRequest{ID: "reboot"}, http.StatusForbidden},
+		{"admin-only with wrong admin PIN", true, ExecuteRequest{ID: "reboot", AdminPin: "0000"}, http.StatusForbidden},
+		{"admin-only with the PIN in the wrong field", true, ExecuteRequest{ID: "reboot", Pin: testSeparatePin}, http.StatusForbidden},
+		{"admin-only with the regular PIN", true, ExecuteRequest{ID: "reboot", AdminPin: testUserPin}, http.StatusForbidden},
+		{"admin-only with admin PIN", true, ExecuteRequest{ID: "reboot", AdminPin: testSeparatePin}, 0},
+		{"admin-only with PIN but no regular PIN", true, ExecuteRequest{ID: "wipe", AdminPin: testSeparatePin}, http.StatusUnauthorized},
+		{"admin PIN is not the regular PIN", true, ExecuteRequest{ID: "wipe", Pin: testSeparatePin, AdminPin: testSeparatePin}, http.StatusUnauthorized},
+		{"admin-only with PIN and both PINs", true, ExecuteRequest{ID: "wipe", Pin: testUserPin, AdminPin: testSeparatePin}, 0},
+		{"no admin PIN configured", false, ExecuteRequest{ID: "uptime"}, 0},
+		{"no admin PIN configured refuses the regular PIN", false, ExecuteRequest{ID: "reboot", AdminPin: testUserPin}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newAdminOnlyHandler(t, tt.adminPin)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/execute", nil)
+
+			prepared, failure := handler.prepareExecution(c, tt.req)
+			if tt.want == 0 {
+				if failure != nil {
+					t.Fatalf("prepareExecution failed with %d: %s", failure.status, failure.response.Message)
+				}
+				if prepared.cmd.ID != tt.req.ID {
+					t.Fatalf("prepared command %s, want %s", prepared.cmd.ID, tt.req.ID)
+				}
+				return
+			}
+			if failure == nil {
+				t.Fatalf("prepareExecution succeeded, want %d", tt.want)
+			}
+			if failure.status != tt.want {
+				t.Fatalf("status = %d, want %d: %s", failure.status, tt.want, failure.response.Message)
+			}
+		})
+	}
+}
+
+func TestWithoutAdminOnly(t *testing.T) {
+	regular := &entity.Command{ID: "regular"}
+	pinned := &entity.Command{ID: "pinned", Security: &entity.SecurityConfig{RequirePin: true}}
+	adminOnly := &entity.Command{ID: "admin-only", Security: &entity.SecurityConfig{AdminOnly: true}}
+
+	tests := []struct {
+		name     string
+		commands []*entity.Command
+		want     []string
+	}{
+		{"no commands", nil, []string{}},
+		{"only admin-only", []*entity.Command{adminOnly}, []string{}},
+		{"mixed keeps order", []*entity.Command{pinned, adminOnly, regular}, []string{"pinned", "regular"}},
+		{"nothing admin-only", []*entity.Command{regular, pinned}, []string{"regular", "pinned"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, cmd := range withoutAdminOnly(tt.commands) {
+				got = append(got, cmd.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("withoutAdminOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandListingsHideAdminOnly(t *testing.T) {
+	_, commandHandler := newAdminOnlyHandler(t, true)
+	router := gin.New()
+	router.GET("/commands", commandHandler.GetAllCommands)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"without admin PIN", "", []string{"uptime"}},
+		{"with wrong admin PIN", "?adminPin=0000", []string{"uptime"}},
+		{"with the regular PIN", "?adminPin=" + testUserPin, []string{"uptime"}},
+		{"with admin PIN", "?adminPin=" + testSeparatePin, []string{"reboot", "uptime", "wipe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commands"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var commands []CommandResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &commands); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			got := []string{}
+			for _, cmd := range commands {
+				got = append(got, cmd.ID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("listed commands = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutionPinsShareTheFailureThrottle(t *testing.T) {
+	handler, _ := newAdminOnlyHandler(t, true)
+	prepare := func(req ExecuteRequest) *executionError {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/execute", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		_, failure := handler.prepareExecution(c, req)
+		return failure
+	}
+
+	for i := 0; i < 5; i++ {
+		if failure := prepare(ExecuteRequest{ID: "reboot", AdminPin: "0000"}); failure == nil || failure.status != http.StatusForbidden {
+			t.Fatalf("wrong admin PIN %d: failure = %+v, want 403", i+1, failure)
+		}
+	}
+
+	// Guessing through /execute locks the caller out of every PIN check
+	if failure := prepare(ExecuteRequest{ID: "reboot", AdminPin: testSeparatePin}); failure == nil || failure.status != http.StatusTooManyRequests {
+		t.Fatalf("right admin PIN after the limit: failure = %+v, want 429", failure)
+	}
+	if failure := prepare(ExecuteRequest{ID: "wipe", Pin: testUserPin, AdminPin: testSeparatePin}); failure == nil || failure.status != http.StatusTooManyRequests {
+		t.Fatalf("regular PIN after the limit: failure = %+v, want 429", failure)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/execute/runs?adminPin="+testSeparatePin, nil)
+	c.Request.RemoteAddr = "192.0.2.1:1234"
+	if _, ok := handler.checkRunAdmin(c); ok || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("run admin check after the limit: ok = %v, status = %d, want 429", ok, w.Code)
+	}
+}
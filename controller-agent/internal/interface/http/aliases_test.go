@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestCommandAliases(t *testing.T) {
+	newRouter := func(t *testing.T, allowed []string) *gin.Engine {
+		repo := newTestRepository(t, &entity.Command{ID: "lights", Name: "Lights", Command: "echo toggled", Platform: runtime.GOOS, Aliases: []string{"lamp"}})
+		cfg := &config.Config{Security: config.SecurityConfig{EnableWhitelist: len(allowed) > 0, AllowedCommands: allowed}}
+		executeHandler := newTestExecuteHandler(t, cfg, repo)
+		commandHandler := NewCommandHandler(executeHandler.commandService, executeHandler.securityService, false)
+		router := gin.New()
+		router.GET("/execute", executeHandler.ExecuteCommand)
+		router.GET("/commands/:id", commandHandler.GetCommand)
+		router.POST("/commands", commandHandler.CreateCommand)
+		return router
+	}
+
+	t.Run("execute by alias", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			allowed    []string
+			id         string
+			wantStatus int
+		}{
+			{"canonical ID", nil, "lights", http.StatusOK},
+			{"alias", nil, "lamp", http.StatusOK},
+			{"alias with the ID allowed", []string{"lights"}, "lamp", http.StatusOK},
+			// allowed_commands lists canonical IDs, so allowing an alias allows nothing
+			{"alias allowed instead of the ID", []string{"lamp"}, "lamp", http.StatusForbidden},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var resp ExecuteResponse
+				if code := getJSON(t, newRouter(t, tt.allowed), "/execute?id="+tt.id, &resp); code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %+v", code, tt.wantStatus, resp)
+				}
+				if tt.wantStatus == http.StatusOK && (resp.CommandID != "lights" || resp.Output != "toggled\n") {
+					t.Errorf("commandId %q, output %q; want the canonical ID lights", resp.CommandID, resp.Output)
+				}
+			})
+		}
+	})
+
+	t.Run("get by alias", func(t *testing.T) {
+		var resp CommandResponse
+		if code := getJSON(t, newRouter(t, nil), "/commands/lamp", &resp); code != http.StatusOK || resp.ID != "lights" {
+			t.Errorf("status %d, id %q; want 200 and the canonical ID", code, resp.ID)
+		}
+	})
+
+	t.Run("create conflicts", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			body       string
+			wantStatus int
+		}{
+			{"new alias", `{"id":"fan","name":"Fan","command":"echo fan","aliases":["ceiling-fan"]}`, http.StatusCreated},
+			{"alias taken", `{"id":"fan","name":"Fan","command":"echo fan","aliases":["lamp"]}`, http.StatusConflict},
+			{"alias is an ID", `{"id":"fan","name":"Fan","command":"echo fan","aliases":["lights"]}`, http.StatusConflict},
+			{"ID is an alias", `{"id":"lamp","name":"Lamp","command":"echo lamp"}`, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, "/commands", strings.NewReader(tt.body))
+				req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+				w := httptest.NewRecorder()
+				newRouter(t, nil).ServeHTTP(w, req)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+				}
+			})
+		}
+	})
+}
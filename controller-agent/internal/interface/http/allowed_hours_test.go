@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandAllowedHours(t *testing.T) {
+	now := time.Now()
+	window := func(from, to time.Duration) *entity.AllowedHoursConfig {
+		return &entity.AllowedHoursConfig{Start: now.Add(from).Format("15:04"), End: now.Add(to).Format("15:04")}
+	}
+	repo := newTestRepository(t,
+		&entity.Command{ID: "inside", Name: "Inside", Command: "echo ok", Platform: runtime.GOOS, AllowedHours: window(-time.Hour, time.Hour)},
+		&entity.Command{ID: "outside", Name: "Outside", Command: "echo ok", Platform: runtime.GOOS, AllowedHours: window(time.Hour, 2*time.Hour)},
+	)
+	handler := newTestExecuteHandler(t, &config.Config{}, repo)
+	router := gin.New()
+	router.GET("/execute", handler.ExecuteCommand)
+
+	tests := []struct {
+		id          string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"inside", http.StatusOK, ""},
+		{"outside", http.StatusForbidden, now.Add(time.Hour).Format("15:04") + " to " + now.Add(2*time.Hour).Format("15:04")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/execute?id="+tt.id, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Error != "Outside allowed hours" || !strings.Contains(resp.Message, tt.wantMessage) || !strings.Contains(resp.Message, "next allowed at ") {
+				t.Errorf("response = %+v, want the window %q and the next allowed time", resp, tt.wantMessage)
+			}
+		})
+	}
+}
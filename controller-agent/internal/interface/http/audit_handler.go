@@ -0,0 +1,208 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 500
+)
+
+// AuditHandler handles HTTP requests for the execution audit log
+type AuditHandler struct {
+	auditService *audit.Service
+}
+
+// NewAuditHandler creates a new audit handler; auditService is nil when auditing is disabled
+func NewAuditHandler(auditService *audit.Service) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// AuditResponse represents a page of audit entries
+type AuditResponse struct {
+	Entries  []audit.Entry `json:"entries"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
+
+// CommandHistoryResponse represents the recent executions of a command with their stats
+type CommandHistoryResponse struct {
+	CommandID  string             `json:"commandId"`
+	Executions []audit.Entry      `json:"executions"` // newest first
+	Stats      audit.CommandStats `json:"stats"`      // over every execution in the time range
+}
+
+// @Summary Query the execution audit log
+// @Description List audited command executions across all interfaces, newest first
+// @Tags audit
+// @Produce json
+// @Param commandId query string false "Only executions of this command"
+// @Param since query string false "Only executions at or after this RFC3339 time"
+// @Param until query string false "Only executions at or before this RFC3339 time"
+// @Param success query bool false "Only successful or failed executions"
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Entries per page (max 500)"
+// @Success 200 {object} AuditResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /audit [get]
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	if h.auditService == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Audit log disabled",
+			Message: "Enable audit in the agent configuration",
+		})
+		return
+	}
+
+	filter, page, pageSize, err := parseAuditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	entries, total, err := h.auditService.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to query audit log",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditResponse{
+		Entries:  entries,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// @Summary Get the execution history of a command
+// @Description List the recent audited executions of a command, newest first, with stats over every execution in the time range: success rate, average duration and last run
+// @Tags audit
+// @Produce json
+// @Param id path string true "Command ID"
+// @Param since query string false "Only executions at or after this RFC3339 time"
+// @Param until query string false "Only executions at or before this RFC3339 time"
+// @Param limit query int false "Executions to return (default 20, max 500)"
+// @Success 200 {object} CommandHistoryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /commands/{id}/history [get]
+func (h *AuditHandler) GetCommandHistory(c *gin.Context) {
+	if h.auditService == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Audit log disabled",
+			Message: "Enable audit in the agent configuration",
+		})
+		return
+	}
+
+	filter := audit.Filter{CommandID: c.Param("id")}
+	err := parseTimeRange(c, &filter)
+	if err == nil {
+		filter.Limit, err = parseHistoryLimit(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	executions, stats, err := h.auditService.CommandHistory(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to query audit log",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, CommandHistoryResponse{
+		CommandID:  filter.CommandID,
+		Executions: executions,
+		Stats:      stats,
+	})
+}
+
+// parseAuditQuery builds an audit filter from the query string
+func parseAuditQuery(c *gin.Context) (audit.Filter, int, int, error) {
+	filter := audit.Filter{CommandID: c.Query("commandId")}
+
+	if err := parseTimeRange(c, &filter); err != nil {
+		return filter, 0, 0, err
+	}
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, 0, 0, fmt.Errorf("success must be a boolean, got %q", value)
+		}
+		filter.Success = &success
+	}
+
+	page, pageSize, err := parsePagination(c, defaultAuditPageSize, maxAuditPageSize)
+	if err != nil {
+		return filter, 0, 0, err
+	}
+
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+
+	return filter, page, pageSize, nil
+}
+
+// parseTimeRange sets the since and until bounds of filter from the query string
+func parseTimeRange(c *gin.Context, filter *audit.Filter) error {
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("since must be an RFC3339 time, got %q", value)
+		}
+		filter.Since = since
+	}
+	if value := c.Query("until"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("until must be an RFC3339 time, got %q", value)
+		}
+		filter.Until = until
+	}
+	return nil
+}
+
+// parseHistoryLimit reads the limit query parameter, capped at maxHistoryLimit
+func parseHistoryLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive integer, got %q", value)
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	return limit, nil
+}
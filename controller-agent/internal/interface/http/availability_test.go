@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// otherPlatform is a platform the tests are not running on
+func otherPlatform() string {
+	if runtime.GOOS == "windows" {
+		return "linux"
+	}
+	return "windows"
+}
+
+func TestCommandAvailability(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// Availability and whitelisting disagree for every command but "here"
+	tests := []struct {
+		cmd             *entity.Command
+		wantAvailable   bool
+		wantWhitelisted bool
+	}{
+		{&entity.Command{ID: "here", Command: "echo", Platform: runtime.GOOS}, true, true},
+		{&entity.Command{ID: "here-not-whitelisted", Command: "echo", Platform: runtime.GOOS, Security: &entity.SecurityConfig{}}, true, false},
+		{&entity.Command{ID: "elsewhere", Command: "echo", Platform: otherPlatform()}, false, true},
+		{&entity.Command{ID: "variant", Command: "echo", Platform: otherPlatform(), Platforms: map[string]string{runtime.GOOS: "echo"}, Security: &entity.SecurityConfig{}}, true, false},
+	}
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, tt := range tests {
+		if err := repo.Create(context.Background(), tt.cmd); err != nil {
+			t.Fatalf("create %s: %v", tt.cmd.ID, err)
+		}
+	}
+	cfg := &config.Config{}
+	handler := NewCommandHandler(service.NewCommandService(repo), security.NewService(cfg, logger), false)
+	router := gin.New()
+	router.GET("/commands", handler.GetAllCommands)
+	router.GET("/commands/:id", handler.GetCommand)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commands", nil))
+	var list []CommandResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v: %s", err, w.Body.String())
+	}
+	listed := make(map[string]CommandResponse, len(list))
+	for _, cmd := range list {
+		listed[cmd.ID] = cmd
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd.ID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commands/"+tt.cmd.ID, nil))
+			var single CommandResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &single); err != nil {
+				t.Fatalf("decode command: %v: %s", err, w.Body.String())
+			}
+
+			for source, got := range map[string]CommandResponse{"list": listed[tt.cmd.ID], "get": single} {
+				if got.Available != tt.wantAvailable {
+					t.Errorf("%s: available = %v, want %v", source, got.Available, tt.wantAvailable)
+				}
+				if got.Whitelisted != tt.wantWhitelisted {
+					t.Errorf("%s: whitelisted = %v, want %v", source, got.Whitelisted, tt.wantWhitelisted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandAllowedClients(t *testing.T) {
+	repo := newTestRepository(t,
+		&entity.Command{ID: "by-id", Name: "By ID", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedClientIDs: []string{"kitchen"}}},
+		&entity.Command{ID: "by-ip", Name: "By IP", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedIPs: []string{"192.0.2.0/24"}}},
+	)
+	handler := newTestExecuteHandler(t, &config.Config{}, repo)
+	router := gin.New()
+	router.POST("/execute", handler.ExecuteCommandPost)
+
+	tests := []struct {
+		name       string
+		id         string
+		clientID   string
+		remoteAddr string
+		wantStatus int
+	}{
+		{"allowed client ID", "by-id", "kitchen", "203.0.113.5:4000", http.StatusOK},
+		{"other client ID", "by-id", "garage", "203.0.113.5:4000", http.StatusForbidden},
+		{"no client ID", "by-id", "", "203.0.113.5:4000", http.StatusForbidden},
+		{"allowed network", "by-ip", "", "192.0.2.10:4000", http.StatusOK},
+		{"other network", "by-ip", "kitchen", "203.0.113.5:4000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ExecuteRequest{ID: tt.id})
+			req := httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(string(body)))
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			if tt.clientID != "" {
+				req.Header.Set(common.HeaderXClientID, tt.clientID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), common.ErrClientNotAllowed.Error()) {
+				t.Errorf("body = %s, want %q", w.Body.String(), common.ErrClientNotAllowed.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestCommandDangerousPatterns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"create safe", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"ls /"}`, http.StatusCreated},
+		{"create dangerous", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"rm -rf /"}`, http.StatusBadRequest},
+		{"create extra pattern", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"dd if=/dev/zero of=/dev/sda"}`, http.StatusBadRequest},
+		{"create dangerous args", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","shell":"none","args":["rm","-rf","/"]}`, http.StatusBadRequest},
+		{"override without PIN", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"rm -rf /","allowDangerous":true}`, http.StatusUnauthorized},
+		{"override with wrong PIN", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"rm -rf /","allowDangerous":true,"adminPin":"0000"}`, http.StatusUnauthorized},
+		{"override with admin PIN", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"rm -rf /","allowDangerous":true,"adminPin":"4321"}`, http.StatusCreated},
+		{"update safe", http.MethodPut, "/commands/existing", `{"command":"ls /"}`, http.StatusOK},
+		{"update dangerous", http.MethodPut, "/commands/existing", `{"command":"rm -rf /"}`, http.StatusBadRequest},
+		{"update dangerous with fields", http.MethodPut, "/commands/existing", `{"command":"rm -rf /","category":"cleanup"}`, http.StatusBadRequest},
+		{"update override with admin PIN", http.MethodPut, "/commands/existing", `{"command":"rm -rf /","allowDangerous":true,"adminPin":"4321"}`, http.StatusOK},
+
+		// Every other string the executor runs goes through the same check
+		{"create dangerous args with a command", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"echo","shell":"none","args":["rm","-rf","/"]}`, http.StatusBadRequest},
+		{"create dangerous pre-hook", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"ls /","preHook":"rm -rf /"}`, http.StatusBadRequest},
+		{"create dangerous post-hook", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"ls /","postHook":"rm -rf /"}`, http.StatusBadRequest},
+		{"create dangerous platform variant", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"ls /","platforms":{"linux":"rm -rf /"}}`, http.StatusBadRequest},
+		{"create dangerous hook with admin PIN", http.MethodPost, "/commands", `{"id":"wipe","name":"Wipe","command":"ls /","preHook":"rm -rf /","allowDangerous":true,"adminPin":"4321"}`, http.StatusCreated},
+		{"update dangerous args", http.MethodPut, "/commands/existing", `{"shell":"none","args":["rm","-rf","/"]}`, http.StatusBadRequest},
+		{"update dangerous pre-hook", http.MethodPut, "/commands/existing", `{"preHook":"rm -rf /"}`, http.StatusBadRequest},
+		{"update dangerous post-hook", http.MethodPut, "/commands/existing", `{"postHook":"rm -rf /"}`, http.StatusBadRequest},
+		{"update of an approved command", http.MethodPut, "/commands/approved", `{"category":"cleanup"}`, http.StatusOK},
+		{"import safe", http.MethodPost, "/commands/import", `{"version":"3.0","commands":[{"id":"wipe","name":"Wipe","command":"ls /"}]}`, http.StatusOK},
+		{"import dangerous", http.MethodPost, "/commands/import", `{"version":"3.0","commands":[{"id":"wipe","name":"Wipe","command":"rm -rf /"}]}`, http.StatusBadRequest},
+		{"import dangerous hook", http.MethodPost, "/commands/import", `{"version":"3.0","commands":[{"id":"wipe","name":"Wipe","command":"ls /","postHook":"rm -rf /"}]}`, http.StatusBadRequest},
+		{"import override without PIN", http.MethodPost, "/commands/import?allowDangerous=true", `{"version":"3.0","commands":[{"id":"wipe","name":"Wipe","command":"rm -rf /"}]}`, http.StatusUnauthorized},
+		{"import override with admin PIN", http.MethodPost, "/commands/import?allowDangerous=true&adminPin=4321", `{"version":"3.0","commands":[{"id":"wipe","name":"Wipe","command":"rm -rf /"}]}`, http.StatusOK},
+		{"re-import of an approved command", http.MethodPost, "/commands/import?overwrite=true", `{"version":"3.0","commands":[{"id":"approved","name":"Renamed","command":"rm -rf /tmp/cache"}]}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocked := append(append([]string(nil), common.DefaultBlockedPatterns...), "dd if=")
+			executorService := executor.NewService(logger)
+			executorService.SetBlockedPatterns(blocked)
+			repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+			// approved was created with allowDangerous before
+			for _, cmd := range []*entity.Command{
+				{ID: "existing", Name: "Existing", Command: "echo ok"},
+				{ID: "approved", Name: "Approved", Command: "rm -rf /tmp/cache"},
+			} {
+				if err := repo.Create(context.Background(), cmd); err != nil {
+					t.Fatalf("create %s: %v", cmd.ID, err)
+				}
+			}
+			commandService := service.NewCommandService(repo)
+			commandService.SetCommandValidator(executorService)
+			cfg := &config.Config{Security: config.SecurityConfig{Pin: testAdminPin, AdminPinHash: testAdminPinHash}}
+			handler := NewCommandHandler(commandService, security.NewService(cfg, logger), false)
+			router := gin.New()
+			router.POST("/commands", handler.CreateCommand)
+			router.PUT("/commands/:id", handler.UpdateCommand)
+			router.POST("/commands/import", handler.ImportCommands)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusBadRequest && !strings.Contains(w.Body.String(), common.ErrCommandDangerous.Error()) {
+				t.Errorf("body = %s, want the dangerous command error", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestCreateCommandDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantCategory string
+		wantPlatform string
+	}{
+		{"defaults applied when omitted", `{"id":"uptime","name":"Uptime","command":"uptime"}`, "general", runtime.GOOS},
+		{"provided category kept", `{"id":"uptime","name":"Uptime","command":"uptime","category":"monitoring"}`, "monitoring", runtime.GOOS},
+		{"provided platform kept", `{"id":"uptime","name":"Uptime","command":"uptime","platform":"` + otherPlatform() + `"}`, "general", otherPlatform()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+			commandService := service.NewCommandService(repo)
+			commandService.SetDefaults("general", "")
+			cfg := &config.Config{}
+			handler := NewCommandHandler(commandService, security.NewService(cfg, logger), false)
+			router := gin.New()
+			router.POST("/commands", handler.CreateCommand)
+			router.GET("/commands/:id", handler.GetCommand)
+
+			req := httptest.NewRequest(http.MethodPost, "/commands", strings.NewReader(tt.body))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("create status = %d: %s", w.Code, w.Body.String())
+			}
+			var created CommandResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			var fetched CommandResponse
+			if code := getJSON(t, router, "/commands/uptime", &fetched); code != http.StatusOK {
+				t.Fatalf("get status = %d", code)
+			}
+			for source, got := range map[string]CommandResponse{"created": created, "fetched": fetched} {
+				if got.Category != tt.wantCategory || got.Platform != tt.wantPlatform {
+					t.Errorf("%s: category %q, platform %q, want %q, %q", source, got.Category, got.Platform, tt.wantCategory, tt.wantPlatform)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestCommandDiagnosticValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"create with diagnostic", http.MethodPost, "/commands", `{"id":"backup","name":"Backup","command":"backup","onFailureDiagnostic":"capture-logs"}`, http.StatusCreated},
+		{"create as own diagnostic", http.MethodPost, "/commands", `{"id":"backup","name":"Backup","command":"backup","onFailureDiagnostic":"backup"}`, http.StatusBadRequest},
+		{"create with unknown diagnostic", http.MethodPost, "/commands", `{"id":"backup","name":"Backup","command":"backup","onFailureDiagnostic":"missing"}`, http.StatusBadRequest},
+		{"update to own diagnostic", http.MethodPut, "/commands/capture-logs", `{"onFailureDiagnostic":"capture-logs"}`, http.StatusBadRequest},
+		{"update removes diagnostic", http.MethodPut, "/commands/capture-logs", `{"onFailureDiagnostic":""}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+			if err := repo.Create(context.Background(), &entity.Command{ID: "capture-logs", Name: "Capture logs", Command: "echo logs"}); err != nil {
+				t.Fatalf("create capture-logs: %v", err)
+			}
+			handler := NewCommandHandler(service.NewCommandService(repo), security.NewService(&config.Config{}, logger), false)
+			router := gin.New()
+			router.POST("/commands", handler.CreateCommand)
+			router.PUT("/commands/:id", handler.UpdateCommand)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
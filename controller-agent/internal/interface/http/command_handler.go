@@ -2,61 +2,120 @@ package http
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
 )
 
 // CommandHandler handles HTTP requests for command operations
 type CommandHandler struct {
-	commandService *service.CommandService
+	commandService  *service.CommandService
+	securityService *security.Service
+	tenancy         bool // scope commands to the caller, see principal
 }
 
 // NewCommandHandler creates a new command handler
-func NewCommandHandler(commandService *service.CommandService) *CommandHandler {
+func NewCommandHandler(commandService *service.CommandService, securityService *security.Service, tenancy bool) *CommandHandler {
 	return &CommandHandler{
-		commandService: commandService,
+		commandService:  commandService,
+		securityService: securityService,
+		tenancy:         tenancy,
 	}
 }
 
 // CreateCommandRequest represents the request payload for creating a command
 type CreateCommandRequest struct {
 	ID             string                 `json:"id" binding:"required"`
+	Aliases        []string               `json:"aliases"` // other IDs the command answers to, unique across all commands
 	Name           string                 `json:"name"`
 	Description    string                 `json:"description"`
 	Category       string                 `json:"category"`
 	Icon           string                 `json:"icon"`
-	Command        string                 `json:"command" binding:"required"`
+	Command        string                 `json:"command"` // may be omitted when shell is "none" and args are given
 	Platform       string                 `json:"platform"`
+	Platforms      map[string]string      `json:"platforms"` // command per OS (windows, linux, darwin), used instead of command there
 	CommandType    string                 `json:"commandType"`
 	Timeout        int                    `json:"timeout"`
+	MaxTimeout     int                    `json:"maxTimeout"` // ms, caps request timeout overrides below the server max
 	UserID         string                 `json:"userId"`
 	DeviceID       string                 `json:"deviceId"`
 	TemplateId     string                 `json:"templateId"`
-	TemplateParams map[string]interface{} `json:"templateParams"`
+	TemplateParams map[string]interface{} `json:"templateParams"` // checked against params when the command declares any
+	Params         []entity.ParamSpec     `json:"params"`
+	WorkingDir     string                 `json:"workingDir"`
+	Env            map[string]string      `json:"env"`
+	PublishResults *bool                  `json:"publishResults"`
+	LoginShell     *bool                  `json:"loginShell"`
+	Shell          *string                `json:"shell"` // sh, bash, cmd, powershell or none; empty for the platform default
+	Args           []string               `json:"args"`  // program and arguments executed directly when shell is none
+	PreHook        *string                `json:"preHook"`  // empty removes the hook
+	PostHook       *string                `json:"postHook"` // empty removes the hook
+	StrictHooks    *bool                  `json:"strictHooks"`
+	OutputFormat   *string                `json:"outputFormat"` // text, json or lines; empty for text
+	OnFailureDiagnostic *string           `json:"onFailureDiagnostic"` // command ID run on failure; empty removes it
+	DependsOn      []string               `json:"dependsOn"`   // IDs of commands that must have succeeded recently
+	AutoRunDeps    *bool                  `json:"autoRunDeps"` // run stale dependencies first instead of failing
 	Security       *SecurityRequest       `json:"security"`
+	RateLimit      *RateLimitRequest      `json:"rateLimit"`
+	AllowedHours   *AllowedHoursRequest   `json:"allowedHours"`
+	Retry          *entity.RetryConfig    `json:"retry"` // maxAttempts of 1 or less removes the retry policy
 	HomeLayout     *HomeLayoutRequest     `json:"homeLayout"`
+
+	// Store a command matching the dangerous-pattern blocklist; requires the admin PIN
+	AllowDangerous bool   `json:"allowDangerous"`
+	AdminPin       string `json:"adminPin"`
 }
 
 // UpdateCommandRequest represents the request payload for updating a command
 type UpdateCommandRequest struct {
+	Aliases        []string               `json:"aliases"` // replaces the aliases; an empty list removes them
 	Name           string                 `json:"name"`
 	Description    string                 `json:"description"`
 	Command        string                 `json:"command"`
 	Category       string                 `json:"category"`
 	Icon           string                 `json:"icon"`
 	Platform       string                 `json:"platform"`
+	Platforms      map[string]string      `json:"platforms"` // command per OS (windows, linux, darwin), used instead of command there; an empty map removes them
 	CommandType    string                 `json:"commandType"`
 	Timeout        int                    `json:"timeout"`
+	MaxTimeout     *int                   `json:"maxTimeout"` // ms, caps request timeout overrides below the server max; 0 removes the cap
 	UserID         string                 `json:"userId"`
 	DeviceID       string                 `json:"deviceId"`
 	TemplateId     string                 `json:"templateId"`
-	TemplateParams map[string]interface{} `json:"templateParams"`
+	TemplateParams map[string]interface{} `json:"templateParams"` // checked against params when the command declares any
+	Params         []entity.ParamSpec     `json:"params"`
+	WorkingDir     string                 `json:"workingDir"`
+	Env            map[string]string      `json:"env"`
+	PublishResults *bool                  `json:"publishResults"`
+	LoginShell     *bool                  `json:"loginShell"`
+	Shell          *string                `json:"shell"` // sh, bash, cmd, powershell or none; empty for the platform default
+	Args           []string               `json:"args"`  // program and arguments executed directly when shell is none
+	PreHook        *string                `json:"preHook"`  // empty removes the hook
+	PostHook       *string                `json:"postHook"` // empty removes the hook
+	StrictHooks    *bool                  `json:"strictHooks"`
+	OutputFormat   *string                `json:"outputFormat"` // text, json or lines; empty for text
+	OnFailureDiagnostic *string           `json:"onFailureDiagnostic"` // command ID run on failure; empty removes it
+	DependsOn      []string               `json:"dependsOn"`   // replaces the dependencies; an empty list removes them
+	AutoRunDeps    *bool                  `json:"autoRunDeps"` // run stale dependencies first instead of failing
 	Security       *SecurityRequest       `json:"security"`
+	RateLimit      *RateLimitRequest      `json:"rateLimit"`
+	AllowedHours   *AllowedHoursRequest   `json:"allowedHours"`
+	Retry          *entity.RetryConfig    `json:"retry"` // maxAttempts of 1 or less removes the retry policy
 	HomeLayout     *HomeLayoutRequest     `json:"homeLayout"`
+
+	// Store a command matching the dangerous-pattern blocklist; requires the admin PIN
+	AllowDangerous bool   `json:"allowDangerous"`
+	AdminPin       string `json:"adminPin"`
 }
 
 // SecurityRequest represents security configuration in request
@@ -64,6 +123,22 @@ type SecurityRequest struct {
 	RequirePin bool `json:"requirePin"`
 	Whitelist  bool `json:"whitelist"`
 	AdminOnly  bool `json:"adminOnly"`
+
+	AllowedClientIDs []string `json:"allowedClientIds"` // only these X-Client-ID values may execute
+	AllowedIPs       []string `json:"allowedIps"`       // only these addresses or CIDR ranges may execute
+}
+
+// RateLimitRequest represents a per-command execution limit in request
+type RateLimitRequest struct {
+	MaxExecutions int `json:"maxExecutions"`
+	Window        int `json:"window"` // seconds
+}
+
+// AllowedHoursRequest represents a daily execution window in request;
+// empty start and end remove the window
+type AllowedHoursRequest struct {
+	Start string `json:"start"` // "HH:MM", inclusive
+	End   string `json:"end"`   // "HH:MM", exclusive; earlier than start for windows past midnight
 }
 
 // HomeLayoutRequest represents home layout configuration in request
@@ -85,22 +160,44 @@ type PositionRequest struct {
 // CommandResponse represents the response format for command operations
 type CommandResponse struct {
 	ID             string                 `json:"id"`
+	Aliases        []string               `json:"aliases,omitempty"`
 	Name           string                 `json:"name"`
 	Description    string                 `json:"description"`
 	Category       string                 `json:"category"`
 	Icon           string                 `json:"icon"`
 	Command        string                 `json:"command"`
 	Platform       string                 `json:"platform"`
+	Platforms      map[string]string      `json:"platforms,omitempty"`
 	CommandType    string                 `json:"commandType"`
 	Timeout        int                    `json:"timeout"`
+	MaxTimeout     int                    `json:"maxTimeout,omitempty"`
 	UserID         string                 `json:"userId"`
 	DeviceID       string                 `json:"deviceId"`
 	TemplateId     string                 `json:"templateId"`
 	TemplateParams map[string]interface{} `json:"templateParams"`
+	Params         []entity.ParamSpec     `json:"params,omitempty"`
+	WorkingDir     string                 `json:"workingDir,omitempty"`
+	Env            map[string]string      `json:"env,omitempty"`
+	PublishResults bool                   `json:"publishResults"`
+	LoginShell     bool                   `json:"loginShell"`
+	Shell          string                 `json:"shell,omitempty"`
+	Args           []string               `json:"args,omitempty"`
+	PreHook        string                 `json:"preHook,omitempty"`
+	PostHook       string                 `json:"postHook,omitempty"`
+	StrictHooks    bool                   `json:"strictHooks"`
+	OutputFormat   string                 `json:"outputFormat,omitempty"`
+	OnFailureDiagnostic string            `json:"onFailureDiagnostic,omitempty"`
+	DependsOn      []string               `json:"dependsOn,omitempty"`
+	AutoRunDeps    bool                   `json:"autoRunDeps"`
 	CreatedAt      string                 `json:"createdAt"`
 	UpdatedAt      string                 `json:"updatedAt"`
 	RequiresPin    bool                   `json:"requiresPin"`
 	Whitelisted    bool                   `json:"whitelisted"`
+	AllowedClientIDs []string             `json:"allowedClientIds,omitempty"`
+	AllowedIPs       []string             `json:"allowedIps,omitempty"`
+	RateLimit        *RateLimitRequest    `json:"rateLimit,omitempty"`
+	AllowedHours     *AllowedHoursRequest `json:"allowedHours,omitempty"`
+	Retry            *entity.RetryConfig  `json:"retry,omitempty"`
 	Available      bool                   `json:"available"`
 	ShowOnHomepage bool                   `json:"showOnHomepage"`
 	HomepageColor  string                 `json:"homepageColor,omitempty"`
@@ -122,8 +219,10 @@ type PositionResponse struct {
 // @Accept json
 // @Produce json
 // @Param command body CreateCommandRequest true "Command configuration"
+// @Param X-Tenant-Token header string false "Tenant token; required in tenancy mode unless adminPin is given, and the command belongs to its tenant"
 // @Success 201 {object} CommandResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /commands [post]
@@ -136,16 +235,118 @@ func (h *CommandHandler) CreateCommand(c *gin.Context) {
 		})
 		return
 	}
+	if req.AllowedHours != nil {
+		if err := validateAllowedHours(req.AllowedHours); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid allowed hours",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if req.Retry != nil {
+		if err := req.Retry.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid retry",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if err := validateShell(req.Shell, req.Args); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid shell",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := validateOutputFormat(req.OutputFormat); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid output format",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := validatePlatforms(req.Platforms); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid platforms",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := validateParams(req.Params, req.TemplateParams); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid params",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	if req.Command == "" {
+		if len(req.Args) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request format",
+				Message: "command is required unless args are given",
+			})
+			return
+		}
+		// Direct execution has no command string; show the args instead
+		req.Command = strings.Join(req.Args, " ")
+	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
+	if err := h.validateDiagnostic(ctx, req.ID, req.OnFailureDiagnostic); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid failure diagnostic",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := h.commandService.CheckDependencies(ctx, req.ID, req.DependsOn); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid dependencies",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !h.checkDangerousOverride(c, req.AllowDangerous, req.AdminPin) {
+		return
+	}
+	if err := h.commandService.CheckPlatforms(req.Platforms, req.AllowDangerous); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to create command",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	// In tenancy mode the command belongs to the caller unless an admin creates it
+	owner := entity.Principal{UserID: req.UserID, DeviceID: req.DeviceID}
+	if h.tenancy && !h.securityService.IsAdmin(c.ClientIP(), req.AdminPin) {
+		tenant, ok := h.securityService.AuthenticateTenant(c.GetHeader(common.HeaderXTenantToken))
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Failed to create command",
+				Message: "a valid X-Tenant-Token or admin PIN is required",
+			})
+			return
+		}
+		owner = tenant
+		req.UserID, req.DeviceID = owner.UserID, owner.DeviceID
+	}
+	
 	// Create command using service
-	cmd, err := h.commandService.CreateCommand(ctx, req.ID, req.Name, req.Command)
+	cmd, err := h.commandService.CreateCommand(ctx, req.ID, req.Name, req.Command, req.Aliases, owner, req.AllowDangerous, func(cmd *entity.Command) {
+		h.updateCommandFields(cmd, &req)
+	})
 	if err != nil {
 		status := http.StatusInternalServerError
-		if err.Error() == "command with ID "+req.ID+" already exists" {
+		if err.Error() == "command with ID "+req.ID+" already exists" || errors.Is(err, common.ErrCommandAliasConflict) {
 			status = http.StatusConflict
+		} else if errors.Is(err, common.ErrCommandDangerous) {
+			status = http.StatusBadRequest
 		}
 		c.JSON(status, ErrorResponse{
 			Error:   "Failed to create command",
@@ -154,18 +355,18 @@ func (h *CommandHandler) CreateCommand(c *gin.Context) {
 		return
 	}
 	
-	// Update additional fields if provided
-	h.updateCommandFields(cmd, &req)
-	
 	// Convert to response format
 	response := h.commandToResponse(cmd)
 	c.JSON(http.StatusCreated, response)
 }
 
 // @Summary Get all commands
-// @Description Retrieve all available commands
+// @Description Retrieve all available commands, optionally only those in one category
 // @Tags commands
 // @Produce json
+// @Param category query string false "Category; empty for uncategorized commands"
+// @Param adminPin query string false "Admin PIN; admin-only commands are left out without it"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode only its tenant's commands and unowned ones are listed"
 // @Success 200 {array} CommandResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /commands [get]
@@ -173,7 +374,13 @@ func (h *CommandHandler) GetAllCommands(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
-	commands, err := h.commandService.GetAllCommands(ctx)
+	var commands []*entity.Command
+	var err error
+	if category, ok := c.GetQuery("category"); ok {
+		commands, err = h.commandService.GetCommandsByCategory(ctx, category)
+	} else {
+		commands, err = h.commandService.GetAllCommands(ctx)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to retrieve commands",
@@ -181,6 +388,12 @@ func (h *CommandHandler) GetAllCommands(c *gin.Context) {
 		})
 		return
 	}
+	if !h.isAdminCaller(c) {
+		commands = withoutAdminOnly(commands)
+	}
+	if principal := h.principal(c); principal != nil {
+		commands = visibleTo(commands, *principal)
+	}
 	
 	// Convert to response format
 	responses := make([]CommandResponse, len(commands))
@@ -191,11 +404,287 @@ func (h *CommandHandler) GetAllCommands(c *gin.Context) {
 	c.JSON(http.StatusOK, responses)
 }
 
+// CategoryResponse represents a command category with its number of commands
+type CategoryResponse struct {
+	Category string `json:"category"` // empty for uncategorized commands
+	Count    int    `json:"count"`
+}
+
+// @Summary Get command categories
+// @Description List the categories in use with the number of commands in each, sorted by name
+// @Tags commands
+// @Produce json
+// @Success 200 {array} CategoryResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /commands/categories [get]
+func (h *CommandHandler) GetCategories(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	categories, err := h.commandService.GetCategories(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to retrieve categories",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	responses := make([]CategoryResponse, len(categories))
+	for i, category := range categories {
+		responses[i] = CategoryResponse{
+			Category: category.Category,
+			Count:    category.Count,
+		}
+	}
+	
+	c.JSON(http.StatusOK, responses)
+}
+
+const (
+	defaultSearchPageSize = 50
+	maxSearchPageSize     = 200
+)
+
+// CommandSearchResponse represents a page of matching commands
+type CommandSearchResponse struct {
+	Commands []CommandResponse `json:"commands"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+}
+
+// @Summary Search commands
+// @Description Search commands by text and filters, ordered by ID
+// @Tags commands
+// @Produce json
+// @Param q query string false "Case-insensitive substring of name, description or command"
+// @Param category query string false "Category"
+// @Param platform query string false "Platform (windows, linux, darwin)"
+// @Param showOnHomepage query bool false "Only commands shown or hidden on the homepage"
+// @Param adminPin query string false "Admin PIN; admin-only commands are left out without it"
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Commands per page (max 200)"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode only its tenant's commands and unowned ones are listed"
+// @Success 200 {object} CommandSearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /commands/search [get]
+func (h *CommandHandler) SearchCommands(c *gin.Context) {
+	filter := repository.CommandFilter{
+		Query:    c.Query("q"),
+		Category: c.Query("category"),
+		Platform: c.Query("platform"),
+	}
+	
+	if value := c.Query("showOnHomepage"); value != "" {
+		showOnHomepage, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request parameters",
+				Message: fmt.Sprintf("showOnHomepage must be a boolean, got %q", value),
+			})
+			return
+		}
+		filter.ShowOnHomepage = &showOnHomepage
+	}
+	
+	page, pageSize, err := parsePagination(c, defaultSearchPageSize, maxSearchPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+	filter.NoAdminOnly = !h.isAdminCaller(c)
+	filter.Principal = h.principal(c)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	commands, total, err := h.commandService.SearchCommands(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to search commands",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	responses := make([]CommandResponse, len(commands))
+	for i, cmd := range commands {
+		responses[i] = h.commandToResponse(cmd)
+	}
+	
+	c.JSON(http.StatusOK, CommandSearchResponse{
+		Commands: responses,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// ImportCommandsResponse summarizes a command bundle import
+type ImportCommandsResponse struct {
+	Mode      string                   `json:"mode"`
+	Created   int                      `json:"created"`
+	Updated   int                      `json:"updated"`
+	Skipped   int                      `json:"skipped"`
+	Removed   int                      `json:"removed,omitempty"`
+	Conflicts []ImportConflictResponse `json:"conflicts,omitempty"`
+}
+
+// ImportConflictResponse reports a bundle command whose ID already existed
+type ImportConflictResponse struct {
+	ID         string `json:"id"`
+	Resolution string `json:"resolution"` // "skipped" or "updated"
+}
+
+// ImportErrorResponse lists the problems that rejected a bundle
+type ImportErrorResponse struct {
+	Error    string   `json:"error"`
+	Message  string   `json:"message"`
+	Problems []string `json:"problems"`
+}
+
+// @Summary Export commands
+// @Description Download every command as a portable bundle that can be imported on another agent
+// @Tags commands
+// @Produce json
+// @Param adminPin query string false "Admin PIN, required in tenancy mode"
+// @Success 200 {object} repository.CommandConfig
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /commands/export [get]
+func (h *CommandHandler) ExportCommands(c *gin.Context) {
+	if !h.checkTenancyAdmin(c, "export") {
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	bundle, err := h.commandService.ExportCommands(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to export commands",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=commands-%s.json", time.Now().Format("20060102-150405")))
+	c.IndentedJSON(http.StatusOK, bundle)
+}
+
+// @Summary Import commands
+// @Description Import a bundle produced by the export endpoint. Merge mode adds new commands and reports
+// @Description commands whose ID already exists as conflicts, keeping them unless overwrite is set.
+// @Description Replace mode swaps the whole command set for the bundle. Invalid bundles are rejected as a whole
+// @Tags commands
+// @Accept json
+// @Produce json
+// @Param bundle body repository.CommandConfig true "Command bundle"
+// @Param mode query string false "merge (default) or replace"
+// @Param overwrite query bool false "In merge mode, replace existing commands with the same ID"
+// @Param allowDangerous query bool false "Accept commands matching the dangerous-pattern blocklist; requires adminPin"
+// @Param adminPin query string false "Admin PIN, required in tenancy mode and with allowDangerous"
+// @Success 200 {object} ImportCommandsResponse
+// @Failure 400 {object} ImportErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /commands/import [post]
+func (h *CommandHandler) ImportCommands(c *gin.Context) {
+	if !h.checkTenancyAdmin(c, "import") {
+		return
+	}
+	
+	mode := c.DefaultQuery("mode", service.ImportModeMerge)
+	var overwrite, allowDangerous bool
+	for _, flag := range []struct {
+		name  string
+		value *bool
+	}{{"overwrite", &overwrite}, {"allowDangerous", &allowDangerous}} {
+		value := c.Query(flag.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request parameters",
+				Message: fmt.Sprintf("%s must be a boolean, got %q", flag.name, value),
+			})
+			return
+		}
+		*flag.value = parsed
+	}
+	if !h.checkDangerousOverride(c, allowDangerous, c.Query("adminPin")) {
+		return
+	}
+	
+	var bundle repository.CommandConfig
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	result, err := h.commandService.ImportCommands(ctx, &bundle, mode, overwrite, allowDangerous)
+	if err != nil {
+		var validationErr *service.ImportValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, ImportErrorResponse{
+				Error:    "Invalid command bundle",
+				Message:  err.Error(),
+				Problems: validationErr.Problems,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to import commands",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	response := ImportCommandsResponse{
+		Mode:    mode,
+		Created: result.Created,
+		Updated: result.Updated,
+		Skipped: result.Skipped,
+		Removed: result.Removed,
+	}
+	for _, conflict := range result.Conflicts {
+		resolution := "skipped"
+		if conflict.Updated {
+			resolution = "updated"
+		}
+		response.Conflicts = append(response.Conflicts, ImportConflictResponse{
+			ID:         conflict.ID,
+			Resolution: resolution,
+		})
+	}
+	
+	c.JSON(http.StatusOK, response)
+}
+
 // @Summary Get command by ID
 // @Description Retrieve a specific command by its ID
 // @Tags commands
 // @Produce json
 // @Param id path string true "Command ID"
+// @Param adminPin query string false "Admin PIN; finds the commands of every user and device in tenancy mode"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode other tenants' commands are not found"
 // @Success 200 {object} CommandResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -225,6 +714,14 @@ func (h *CommandHandler) GetCommand(c *gin.Context) {
 		})
 		return
 	}
+	if hiddenFromCaller(c, h.securityService, cmd, c.Query("adminPin")) {
+		// Answer as for a missing command so other tenants' IDs are not revealed
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Failed to retrieve command",
+			Message: "failed to get command: command not found: " + id,
+		})
+		return
+	}
 	
 	response := h.commandToResponse(cmd)
 	c.JSON(http.StatusOK, response)
@@ -237,9 +734,13 @@ func (h *CommandHandler) GetCommand(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Command ID"
 // @Param command body UpdateCommandRequest true "Command updates"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode required unless adminPin is given, and only its tenant's commands can be updated"
 // @Success 200 {object} CommandResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /commands/{id} [put]
 func (h *CommandHandler) UpdateCommand(c *gin.Context) {
@@ -260,12 +761,76 @@ func (h *CommandHandler) UpdateCommand(c *gin.Context) {
 		})
 		return
 	}
+	if req.AllowedHours != nil {
+		if err := validateAllowedHours(req.AllowedHours); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid allowed hours",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if req.Retry != nil {
+		if err := req.Retry.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid retry",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if err := validateShell(req.Shell, req.Args); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid shell",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := validateOutputFormat(req.OutputFormat); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid output format",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := validatePlatforms(req.Platforms); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid platforms",
+			Message: err.Error(),
+		})
+		return
+	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
+	if err := h.validateDiagnostic(ctx, id, req.OnFailureDiagnostic); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid failure diagnostic",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !h.checkDangerousOverride(c, req.AllowDangerous, req.AdminPin) {
+		return
+	}
+	adminPin := req.AdminPin
+	if adminPin == "" {
+		adminPin = c.Query("adminPin")
+	}
+	if !h.checkTenantWrite(c, ctx, id, adminPin, "update") {
+		return
+	}
+	if h.securityService.TenantScope(c.ClientIP(), c.GetHeader(common.HeaderXTenantToken), adminPin) != nil {
+		// Only admins move commands between tenants
+		req.UserID, req.DeviceID = "", ""
+	}
+	
 	// Create updates map from request
 	updates := make(map[string]interface{})
+	if req.Aliases != nil {
+		updates["aliases"] = req.Aliases
+	}
 	if req.Name != "" {
 		updates["name"] = req.Name
 	}
@@ -284,12 +849,18 @@ func (h *CommandHandler) UpdateCommand(c *gin.Context) {
 	if req.Platform != "" {
 		updates["platform"] = req.Platform
 	}
+	if req.Platforms != nil {
+		updates["platforms"] = req.Platforms
+	}
 	if req.CommandType != "" {
 		updates["commandType"] = req.CommandType
 	}
 	if req.Timeout > 0 {
 		updates["timeout"] = req.Timeout
 	}
+	if req.MaxTimeout != nil {
+		updates["maxTimeout"] = *req.MaxTimeout
+	}
 	if req.UserID != "" {
 		updates["userId"] = req.UserID
 	}
@@ -302,13 +873,67 @@ func (h *CommandHandler) UpdateCommand(c *gin.Context) {
 	if req.TemplateParams != nil {
 		updates["templateParams"] = req.TemplateParams
 	}
+	if req.Params != nil {
+		updates["params"] = req.Params
+	}
+	if req.WorkingDir != "" {
+		updates["workingDir"] = req.WorkingDir
+	}
+	if req.Env != nil {
+		updates["env"] = req.Env
+	}
+	if req.PublishResults != nil {
+		updates["publishResults"] = *req.PublishResults
+	}
+	if req.LoginShell != nil {
+		updates["loginShell"] = *req.LoginShell
+	}
+	if req.Shell != nil {
+		updates["shell"] = *req.Shell
+	}
+	if req.Args != nil {
+		updates["args"] = req.Args
+	}
+	if req.PreHook != nil {
+		updates["preHook"] = *req.PreHook
+	}
+	if req.PostHook != nil {
+		updates["postHook"] = *req.PostHook
+	}
+	if req.StrictHooks != nil {
+		updates["strictHooks"] = *req.StrictHooks
+	}
+	if req.OutputFormat != nil {
+		updates["outputFormat"] = *req.OutputFormat
+	}
+	if req.OnFailureDiagnostic != nil {
+		updates["onFailureDiagnostic"] = *req.OnFailureDiagnostic
+	}
+	if req.DependsOn != nil {
+		updates["dependsOn"] = req.DependsOn
+	}
+	if req.AutoRunDeps != nil {
+		updates["autoRunDeps"] = *req.AutoRunDeps
+	}
 	if req.Security != nil {
 		updates["security"] = map[string]interface{}{
 			"requirePin": req.Security.RequirePin,
 			"whitelist":  req.Security.Whitelist,
 			"adminOnly":  req.Security.AdminOnly,
+
+			"allowedClientIds": req.Security.AllowedClientIDs,
+			"allowedIps":       req.Security.AllowedIPs,
 		}
 	}
+	if req.RateLimit != nil {
+		updates["rateLimit"] = rateLimitFromRequest(req.RateLimit)
+	}
+	if req.AllowedHours != nil {
+		updates["allowedHours"] = allowedHoursFromRequest(req.AllowedHours)
+	}
+	if req.Retry != nil {
+		updates["retry"] = retryFromRequest(req.Retry)
+	}
 	if req.HomeLayout != nil {
 		homeLayoutMap := map[string]interface{}{
 			"showOnHome": req.HomeLayout.ShowOnHome,
@@ -330,16 +955,24 @@ func (h *CommandHandler) UpdateCommand(c *gin.Context) {
 	var err error
 	
 	// Use appropriate service method based on whether we have extended fields
-	if len(updates) > 3 || req.Security != nil || req.HomeLayout != nil {
-		cmd, err = h.commandService.UpdateCommandWithFields(ctx, id, updates)
+	if len(updates) > 3 || req.Security != nil || req.RateLimit != nil || req.AllowedHours != nil || req.Retry != nil || req.HomeLayout != nil ||
+		req.PreHook != nil || req.PostHook != nil || req.StrictHooks != nil || req.Shell != nil || req.Args != nil ||
+		req.OnFailureDiagnostic != nil || req.OutputFormat != nil || req.MaxTimeout != nil || req.Aliases != nil || req.Platforms != nil ||
+		req.Params != nil || req.DependsOn != nil || req.AutoRunDeps != nil {
+		cmd, err = h.commandService.UpdateCommandWithFields(ctx, id, updates, req.AllowDangerous)
 	} else {
-		cmd, err = h.commandService.UpdateCommand(ctx, id, req.Name, req.Description, req.Command)
+		cmd, err = h.commandService.UpdateCommand(ctx, id, req.Name, req.Description, req.Command, req.AllowDangerous)
 	}
 	
 	if err != nil {
 		status := http.StatusInternalServerError
 		if err.Error() == "failed to get command: command not found: "+id {
 			status = http.StatusNotFound
+		} else if errors.Is(err, common.ErrCommandDangerous) || errors.Is(err, common.ErrCommandInvalidParams) ||
+			errors.Is(err, common.ErrCommandDependency) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, common.ErrCommandAliasConflict) {
+			status = http.StatusConflict
 		}
 		c.JSON(status, ErrorResponse{
 			Error:   "Failed to update command",
@@ -357,7 +990,11 @@ func (h *CommandHandler) UpdateCommand(c *gin.Context) {
 // @Tags commands
 // @Produce json
 // @Param id path string true "Command ID"
+// @Param adminPin query string false "Admin PIN; deletes the commands of every user and device in tenancy mode"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode required unless adminPin is given, and only its tenant's commands can be deleted"
 // @Success 204 "Command deleted successfully"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /commands/{id} [delete]
@@ -374,6 +1011,10 @@ func (h *CommandHandler) DeleteCommand(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
+	if !h.checkTenantWrite(c, ctx, id, c.Query("adminPin"), "delete") {
+		return
+	}
+	
 	err := h.commandService.DeleteCommand(ctx, id)
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -394,6 +1035,8 @@ func (h *CommandHandler) DeleteCommand(c *gin.Context) {
 // @Description Retrieve commands configured for homepage display
 // @Tags commands
 // @Produce json
+// @Param adminPin query string false "Admin PIN; admin-only commands are left out without it"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode only its tenant's commands and unowned ones are listed"
 // @Success 200 {array} CommandResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /commands/homepage [get]
@@ -409,6 +1052,12 @@ func (h *CommandHandler) GetHomepageCommands(c *gin.Context) {
 		})
 		return
 	}
+	if !h.isAdminCaller(c) {
+		commands = withoutAdminOnly(commands)
+	}
+	if principal := h.principal(c); principal != nil {
+		commands = visibleTo(commands, *principal)
+	}
 	
 	responses := make([]CommandResponse, len(commands))
 	for i, cmd := range commands {
@@ -432,12 +1081,18 @@ func (h *CommandHandler) updateCommandFields(cmd *entity.Command, req *CreateCom
 	if req.Platform != "" {
 		cmd.Platform = req.Platform
 	}
+	if len(req.Platforms) > 0 {
+		cmd.Platforms = req.Platforms
+	}
 	if req.CommandType != "" {
 		cmd.CommandType = req.CommandType
 	}
 	if req.Timeout > 0 {
 		cmd.Timeout = req.Timeout
 	}
+	if req.MaxTimeout > 0 {
+		cmd.MaxTimeout = req.MaxTimeout
+	}
 	if req.UserID != "" {
 		cmd.UserID = req.UserID
 	}
@@ -450,10 +1105,65 @@ func (h *CommandHandler) updateCommandFields(cmd *entity.Command, req *CreateCom
 	if req.TemplateParams != nil {
 		cmd.TemplateParams = req.TemplateParams
 	}
+	if len(req.Params) > 0 {
+		cmd.Params = req.Params
+	}
+	if req.WorkingDir != "" {
+		cmd.WorkingDir = req.WorkingDir
+	}
+	if req.Env != nil {
+		cmd.Env = req.Env
+	}
+	if req.PublishResults != nil {
+		cmd.PublishResults = *req.PublishResults
+	}
+	if req.LoginShell != nil {
+		cmd.LoginShell = *req.LoginShell
+	}
+	if req.Shell != nil {
+		cmd.Shell = *req.Shell
+	}
+	if req.Args != nil {
+		cmd.Args = req.Args
+	}
+	if req.PreHook != nil {
+		cmd.PreHook = *req.PreHook
+	}
+	if req.PostHook != nil {
+		cmd.PostHook = *req.PostHook
+	}
+	if req.StrictHooks != nil {
+		cmd.StrictHooks = *req.StrictHooks
+	}
+	if req.OutputFormat != nil {
+		cmd.OutputFormat = *req.OutputFormat
+	}
+	if req.OnFailureDiagnostic != nil {
+		cmd.OnFailureDiagnostic = *req.OnFailureDiagnostic
+	}
+	if len(req.DependsOn) > 0 {
+		cmd.DependsOn = req.DependsOn
+	}
+	if req.AutoRunDeps != nil {
+		cmd.AutoRunDeps = *req.AutoRunDeps
+	}
 	
 	// Set security configuration
 	if req.Security != nil {
 		cmd.SetSecurity(req.Security.RequirePin, req.Security.Whitelist, req.Security.AdminOnly)
+		cmd.SetAllowedClients(req.Security.AllowedClientIDs, req.Security.AllowedIPs)
+	}
+	
+	if req.RateLimit != nil {
+		cmd.RateLimit = rateLimitFromRequest(req.RateLimit)
+	}
+	
+	if req.AllowedHours != nil {
+		cmd.AllowedHours = allowedHoursFromRequest(req.AllowedHours)
+	}
+	
+	if req.Retry != nil {
+		cmd.Retry = retryFromRequest(req.Retry)
 	}
 	
 	// Set home layout configuration
@@ -471,22 +1181,248 @@ func (h *CommandHandler) updateCommandFields(cmd *entity.Command, req *CreateCom
 	}
 }
 
+// rateLimitFromRequest converts a rate limit request, where zero values remove the limit
+func rateLimitFromRequest(req *RateLimitRequest) *entity.RateLimitConfig {
+	if req.MaxExecutions <= 0 || req.Window <= 0 {
+		return nil
+	}
+	return &entity.RateLimitConfig{
+		MaxExecutions: req.MaxExecutions,
+		Window:        req.Window,
+	}
+}
+
+// allowedHoursFromRequest converts an allowed hours request, where empty times remove the window
+func allowedHoursFromRequest(req *AllowedHoursRequest) *entity.AllowedHoursConfig {
+	if req.Start == "" && req.End == "" {
+		return nil
+	}
+	return &entity.AllowedHoursConfig{
+		Start: req.Start,
+		End:   req.End,
+	}
+}
+
+// retryFromRequest copies a retry request, where maxAttempts of 1 or less removes the policy
+func retryFromRequest(req *entity.RetryConfig) *entity.RetryConfig {
+	if req.MaxAttempts <= 1 {
+		return nil
+	}
+	retry := *req
+	retry.ExitCodes = append([]int(nil), req.ExitCodes...)
+	return &retry
+}
+
+// validateAllowedHours checks an allowed hours request before it is applied
+func validateAllowedHours(req *AllowedHoursRequest) error {
+	if allowedHours := allowedHoursFromRequest(req); allowedHours != nil {
+		return allowedHours.Validate()
+	}
+	return nil
+}
+
+// validateShell checks a shell request before it is applied; switching to
+// shell none requires the args in the same request
+func validateShell(shell *string, args []string) error {
+	if shell == nil {
+		return nil
+	}
+	cmd := &entity.Command{Shell: *shell, Args: args}
+	return cmd.ValidateShell()
+}
+
+// validateOutputFormat checks an output format request before it is applied
+func validateOutputFormat(format *string) error {
+	if format == nil {
+		return nil
+	}
+	cmd := &entity.Command{OutputFormat: *format}
+	return cmd.ValidateOutputFormat()
+}
+
+// validatePlatforms checks platform variants before they are applied
+func validatePlatforms(platforms map[string]string) error {
+	cmd := &entity.Command{Platforms: platforms}
+	return cmd.ValidatePlatforms()
+}
+
+// validateParams checks parameter specs, and template params against them,
+// before they are applied
+func validateParams(params []entity.ParamSpec, templateParams map[string]interface{}) error {
+	cmd := &entity.Command{Params: params}
+	if err := cmd.ValidateParamSpecs(); err != nil {
+		return err
+	}
+	_, err := cmd.ResolveParams(templateParams)
+	return err
+}
+
+// checkDangerousOverride requires the admin PIN from callers storing a command
+// that matches the dangerous-pattern blocklist. On failure it writes the error
+// response and returns false
+func (h *CommandHandler) checkDangerousOverride(c *gin.Context, allowDangerous bool, adminPin string) bool {
+	if !allowDangerous {
+		return true
+	}
+	err := h.securityService.VerifyAdminPin(c.ClientIP(), adminPin)
+	if err == nil {
+		return true
+	}
+	c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+		Error:   "Authentication failed",
+		Message: "allowDangerous requires a valid admin PIN",
+	}))
+	return false
+}
+
+// isAdminCaller reports whether the request carries a valid admin PIN in the
+// adminPin query parameter, which lists admin-only commands
+func (h *CommandHandler) isAdminCaller(c *gin.Context) bool {
+	return h.securityService.IsAdmin(c.ClientIP(), c.Query("adminPin"))
+}
+
+// principal returns the caller to scope commands to in tenancy mode, or nil
+// when tenancy is off or the caller has the admin PIN and sees every command.
+// A caller without a valid X-Tenant-Token is nobody and sees only unowned commands
+func (h *CommandHandler) principal(c *gin.Context) *entity.Principal {
+	return h.securityService.TenantScope(c.ClientIP(), c.GetHeader(common.HeaderXTenantToken), c.Query("adminPin"))
+}
+
+// hiddenFromCaller reports whether tenancy mode hides cmd from the caller,
+// who is an admin when adminPin is valid
+func hiddenFromCaller(c *gin.Context, securityService *security.Service, cmd *entity.Command, adminPin string) bool {
+	principal := securityService.TenantScope(c.ClientIP(), c.GetHeader(common.HeaderXTenantToken), adminPin)
+	return principal != nil && !cmd.VisibleTo(*principal)
+}
+
+// checkTenancyAdmin answers and returns false when tenancy mode is on and the
+// caller lacks the admin PIN, for operations spanning every tenant's commands
+func (h *CommandHandler) checkTenancyAdmin(c *gin.Context, action string) bool {
+	if h.principal(c) == nil {
+		return true
+	}
+	c.JSON(http.StatusForbidden, ErrorResponse{
+		Error:   "Failed to " + action + " commands",
+		Message: "in tenancy mode only the admin PIN can " + action + " commands",
+	})
+	return false
+}
+
+// checkTenantWrite answers and returns false unless the caller may change the
+// command with the given ID. In tenancy mode a caller without the admin PIN
+// needs a tenant token and may only change commands its tenant owns; shared
+// commands are left to admins. Other tenants' commands are answered as missing
+func (h *CommandHandler) checkTenantWrite(c *gin.Context, ctx context.Context, id, adminPin, action string) bool {
+	principal := h.securityService.TenantScope(c.ClientIP(), c.GetHeader(common.HeaderXTenantToken), adminPin)
+	if principal == nil {
+		return true
+	}
+	if *principal == (entity.Principal{}) {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Failed to " + action + " command",
+			Message: "a valid X-Tenant-Token or admin PIN is required",
+		})
+		return false
+	}
+	
+	cmd, err := h.commandService.GetCommand(ctx, id)
+	if err != nil {
+		// Unknown IDs are left to the update or delete itself to report
+		return true
+	}
+	if !cmd.VisibleTo(*principal) {
+		// Answer as for a missing command so other tenants' IDs are not revealed
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Failed to " + action + " command",
+			Message: "failed to get command: command not found: " + id,
+		})
+		return false
+	}
+	if cmd.UserID == "" && cmd.DeviceID == "" {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Failed to " + action + " command",
+			Message: "shared commands can only be changed with the admin PIN",
+		})
+		return false
+	}
+	return true
+}
+
+// visibleTo returns the commands visible to the principal
+func visibleTo(commands []*entity.Command, principal entity.Principal) []*entity.Command {
+	visible := make([]*entity.Command, 0, len(commands))
+	for _, cmd := range commands {
+		if cmd.VisibleTo(principal) {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
+// withoutAdminOnly returns the commands that are not admin-only
+func withoutAdminOnly(commands []*entity.Command) []*entity.Command {
+	visible := make([]*entity.Command, 0, len(commands))
+	for _, cmd := range commands {
+		if !cmd.RequiresAdmin() {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
+// validateDiagnostic checks that a failure diagnostic request names another
+// existing command; empty removes the diagnostic
+func (h *CommandHandler) validateDiagnostic(ctx context.Context, id string, diagnostic *string) error {
+	if diagnostic == nil || *diagnostic == "" {
+		return nil
+	}
+	if *diagnostic == id {
+		return fmt.Errorf("a command cannot be its own diagnostic")
+	}
+	cmd, err := h.commandService.GetCommand(ctx, *diagnostic)
+	if err != nil {
+		return fmt.Errorf("diagnostic command %s: %w", *diagnostic, err)
+	}
+	// The diagnostic may be given by alias
+	if cmd.ID == id {
+		return fmt.Errorf("a command cannot be its own diagnostic")
+	}
+	return nil
+}
+
 // commandToResponse converts command entity to response format
 func (h *CommandHandler) commandToResponse(cmd *entity.Command) CommandResponse {
 	response := CommandResponse{
 		ID:             cmd.ID,
+		Aliases:        cmd.Aliases,
 		Name:           cmd.Name,
 		Description:    cmd.Description,
 		Category:       cmd.Category,
 		Icon:           cmd.Icon,
 		Command:        cmd.Command,
 		Platform:       cmd.Platform,
+		Platforms:      cmd.Platforms,
 		CommandType:    cmd.CommandType,
 		Timeout:        cmd.GetTimeout(),
+		MaxTimeout:     cmd.MaxTimeout,
 		UserID:         cmd.UserID,
 		DeviceID:       cmd.DeviceID,
 		TemplateId:     cmd.TemplateId,
 		TemplateParams: cmd.TemplateParams,
+		Params:         cmd.Params,
+		WorkingDir:     cmd.WorkingDir,
+		Env:            cmd.Env,
+		PublishResults: cmd.PublishResults,
+		LoginShell:     cmd.LoginShell,
+		Shell:          cmd.Shell,
+		Args:           cmd.Args,
+		PreHook:        cmd.PreHook,
+		PostHook:       cmd.PostHook,
+		StrictHooks:    cmd.StrictHooks,
+		OutputFormat:   cmd.OutputFormat,
+		OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+		DependsOn:      cmd.DependsOn,
+		AutoRunDeps:    cmd.AutoRunDeps,
 		CreatedAt:      cmd.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:      cmd.UpdatedAt.Format(time.RFC3339),
 		RequiresPin:    cmd.RequiresPin(),
@@ -497,6 +1433,29 @@ func (h *CommandHandler) commandToResponse(cmd *entity.Command) CommandResponse
 		HomepagePriority: cmd.GetHomepagePriority(),
 	}
 	
+	if cmd.HasRateLimit() {
+		response.RateLimit = &RateLimitRequest{
+			MaxExecutions: cmd.RateLimit.MaxExecutions,
+			Window:        cmd.RateLimit.Window,
+		}
+	}
+	
+	if cmd.HasAllowedHours() {
+		response.AllowedHours = &AllowedHoursRequest{
+			Start: cmd.AllowedHours.Start,
+			End:   cmd.AllowedHours.End,
+		}
+	}
+	
+	if cmd.HasRetry() {
+		response.Retry = cmd.Retry
+	}
+	
+	if cmd.Security != nil {
+		response.AllowedClientIDs = cmd.Security.AllowedClientIDs
+		response.AllowedIPs = cmd.Security.AllowedIPs
+	}
+	
 	// Add homepage position if available
 	if cmd.ShowOnHomepage() {
 		x, y, width, height := cmd.GetHomepagePosition()
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// commandStore opens the command storage under dir. Every call returns a new
+// repository reading what earlier ones saved
+type commandStore func(t *testing.T, dir string) repository.CommandRepository
+
+func openFileStore(t *testing.T, dir string) repository.CommandRepository {
+	t.Helper()
+	path := filepath.Join(dir, "commands.json")
+	repo := infrastructure.NewFileCommandRepository(path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return repo
+	}
+	if err := repo.(*infrastructure.FileCommandRepository).Initialize(); err != nil {
+		t.Fatalf("initialize file repository: %v", err)
+	}
+	return repo
+}
+
+func openSQLiteStore(t *testing.T, dir string) repository.CommandRepository {
+	t.Helper()
+	repo, err := infrastructure.NewSQLiteCommandRepository(filepath.Join(dir, "commands.db"))
+	if err != nil {
+		t.Fatalf("open SQLite repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if _, err := repo.Initialize(filepath.Join(dir, "commands.json")); err != nil {
+		t.Fatalf("initialize SQLite repository: %v", err)
+	}
+	return repo
+}
+
+func TestCreateCommandPersistsAllFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	stores := []struct {
+		name string
+		open commandStore
+	}{
+		{"file", openFileStore},
+		{"sqlite", openSQLiteStore},
+	}
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &config.Config{}
+			handler := NewCommandHandler(service.NewCommandService(store.open(t, dir)), security.NewService(cfg, logger), false)
+			router := gin.New()
+			router.POST("/commands", handler.CreateCommand)
+
+			for _, body := range []string{
+				`{"id":"mount","name":"Mount","command":"true"}`,
+				`{"id":"locked","name":"Locked","command":"true","description":"Admins only","category":"maintenance",
+				  "security":{"adminOnly":true,"requirePin":true},"rateLimit":{"maxExecutions":2,"window":60},
+				  "allowedHours":{"start":"08:00","end":"18:00"},"retry":{"maxAttempts":3},
+				  "preHook":"true","dependsOn":["mount"]}`,
+				// Another write, which reloads the storage before saving
+				`{"id":"later","name":"Later","command":"true"}`,
+			} {
+				req := httptest.NewRequest(http.MethodPost, "/commands", strings.NewReader(body))
+				req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != http.StatusCreated {
+					t.Fatalf("create status = %d: %s", w.Code, w.Body.String())
+				}
+			}
+
+			cmd, err := store.open(t, dir).GetByID(context.Background(), "locked")
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if cmd.Description != "Admins only" || cmd.Category != "maintenance" {
+				t.Errorf("description %q, category %q were not saved", cmd.Description, cmd.Category)
+			}
+			if !cmd.RequiresAdmin() || !cmd.RequiresPin() {
+				t.Errorf("security = %+v, want admin-only and PIN protected", cmd.Security)
+			}
+			if cmd.RateLimit == nil || cmd.RateLimit.MaxExecutions != 2 {
+				t.Errorf("rate limit = %+v, want 2 executions", cmd.RateLimit)
+			}
+			if cmd.AllowedHours == nil || cmd.AllowedHours.Start != "08:00" {
+				t.Errorf("allowed hours = %+v, want from 08:00", cmd.AllowedHours)
+			}
+			if cmd.Retry == nil || cmd.Retry.MaxAttempts != 3 {
+				t.Errorf("retry = %+v, want 3 attempts", cmd.Retry)
+			}
+			if cmd.PreHook != "true" || len(cmd.DependsOn) != 1 || cmd.DependsOn[0] != "mount" {
+				t.Errorf("pre-hook %q, dependencies %v were not saved", cmd.PreHook, cmd.DependsOn)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestGetCommandSecurity(t *testing.T) {
+	repo := newTestRepository(t,
+		&entity.Command{ID: "lights", Name: "Lights", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, RequirePin: true, AllowedClientIDs: []string{"kitchen"}}},
+	)
+	cfg := &config.Config{Security: config.SecurityConfig{PinRequired: true, RateLimitEnabled: true, RateLimitPerMin: 30}}
+	handler := newTestExecuteHandler(t, cfg, repo)
+	router := gin.New()
+	router.GET("/commands/:id/security", handler.GetCommandSecurity)
+
+	tests := []struct {
+		name           string
+		path           string
+		clientID       string
+		wantStatus     int
+		wantTransport  string
+		wantAllowed    bool
+		wantRateLimit  int
+		wantRequirePin bool
+	}{
+		{"http caller", "/commands/lights/security", "kitchen", http.StatusOK, "http", true, 30, true},
+		{"other client", "/commands/lights/security", "garage", http.StatusOK, "http", false, 30, true},
+		{"mqtt caller", "/commands/lights/security?transport=mqtt", "kitchen", http.StatusOK, "mqtt", true, 0, true},
+		{"grpc caller", "/commands/lights/security?transport=grpc", "kitchen", http.StatusOK, "grpc", true, 30, true},
+		{"invalid transport", "/commands/lights/security?transport=smtp", "", http.StatusBadRequest, "", false, 0, false},
+		{"missing command", "/commands/missing/security", "", http.StatusNotFound, "", false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.clientID != "" {
+				req.Header.Set("X-Client-ID", tt.clientID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var got security.EffectiveSecurity
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Transport != tt.wantTransport || got.ClientAllowed != tt.wantAllowed || got.ClientRateLimit != tt.wantRateLimit || got.RequiresPin != tt.wantRequirePin {
+				t.Errorf("got transport %q, clientAllowed %v, clientRateLimit %d, requiresPin %v", got.Transport, got.ClientAllowed, got.ClientRateLimit, got.RequiresPin)
+			}
+			if got.CanExecute != tt.wantAllowed {
+				t.Errorf("canExecute = %v, want %v (reasons %q)", got.CanExecute, tt.wantAllowed, got.Reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestCreateCommandShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses printf")
+	}
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantArgs   []string
+		wantOutput string
+	}{
+		{
+			name:       "direct exec with quoted args",
+			body:       `{"id":"quoted","name":"Quoted","shell":"none","args":["printf","%s|","say \"hi\"","two words","$HOME"]}`,
+			wantStatus: http.StatusCreated,
+			wantArgs:   []string{"printf", "%s|", `say "hi"`, "two words", "$HOME"},
+			wantOutput: `say "hi"|two words|$HOME|`,
+		},
+		{
+			name:       "shell with embedded quotes",
+			body:       `{"id":"quoted","name":"Quoted","shell":"sh","command":"printf '%s|' \"say \\\"hi\\\"\" 'two words'"}`,
+			wantStatus: http.StatusCreated,
+			wantOutput: `say "hi"|two words|`,
+		},
+		{"unknown shell", `{"id":"quoted","name":"Quoted","shell":"zsh","command":"echo hi"}`, http.StatusBadRequest, nil, ""},
+		{"none without args", `{"id":"quoted","name":"Quoted","shell":"none","command":"echo hi"}`, http.StatusBadRequest, nil, ""},
+		{"no command and no args", `{"id":"quoted","name":"Quoted"}`, http.StatusBadRequest, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executeHandler := newTestExecuteHandler(t, &config.Config{}, newTestRepository(t))
+			commandHandler := NewCommandHandler(executeHandler.commandService, executeHandler.securityService, false)
+			router := gin.New()
+			router.POST("/commands", commandHandler.CreateCommand)
+			router.GET("/execute", executeHandler.ExecuteCommand)
+
+			req := httptest.NewRequest(http.MethodPost, "/commands", strings.NewReader(tt.body))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("create status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			var created CommandResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(created.Args, tt.wantArgs) {
+				t.Errorf("args = %q, want %q", created.Args, tt.wantArgs)
+			}
+
+			// The stored command runs with its arguments intact
+			var resp ExecuteResponse
+			if code := getJSON(t, router, "/execute?id=quoted", &resp); code != http.StatusOK {
+				t.Fatalf("execute status = %d: %+v", code, resp)
+			}
+			if resp.Output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", resp.Output, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipWriters recycles gzip writers across responses
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipResponseWriter buffers a response until it reaches minSize bytes, then
+// compresses it. Smaller responses are written out unchanged when finished
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	minSize int
+	buf     bytes.Buffer
+	started bool         // the buffer was written out; later writes go straight through
+	gz      *gzip.Writer // set once the response is being compressed
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow holds the headers back while buffering, since compressing
+// changes them
+func (w *gzipResponseWriter) WriteHeaderNow() {
+	if w.started {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Flush writes out what is buffered, uncompressed unless compression already
+// started, so streamed responses are not held back
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start ends buffering. The response is compressed if compress is set and it
+// is not already encoded or a partial range
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		return err
+	}
+
+	if w.buf.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+// finish writes out a response that stayed under minSize, or completes the
+// compressed stream
+func (w *gzipResponseWriter) finish() {
+	if !w.started {
+		w.start(false)
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, listed
+// by name or through "*"
+func acceptsGzip(acceptEncoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip":
+			return qualityAllows(params)
+		case "*":
+			wildcard = qualityAllows(params)
+		}
+	}
+	return wildcard
+}
+
+// qualityAllows reports whether the parameters of a coding leave it
+// acceptable; only "q=0" refuses it
+func qualityAllows(params string) bool {
+	q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+	if !ok {
+		return true
+	}
+	value, err := strconv.ParseFloat(q, 64)
+	return err != nil || value > 0
+}
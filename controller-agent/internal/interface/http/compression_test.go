@@ -0,0 +1,160 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip;q=0.8", true},
+		{"br, deflate", false},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"gzip;q=0, *", false},
+		{"identity, *;q=0.5", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := acceptsGzip(tt.header); got != tt.want {
+				t.Fatalf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// newCompressionTestServer returns a server with its routes set up, count
+// commands and the given compression settings
+func newCompressionTestServer(t *testing.T, compression config.CompressionConfig, count int) *Server {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Server.HTTP.Compression = compression
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for i := 0; i < count; i++ {
+		cmd := &entity.Command{
+			ID:          fmt.Sprintf("cmd-%d", i),
+			Name:        fmt.Sprintf("Command %d", i),
+			Description: "Prints the uptime of the machine the agent runs on",
+			Command:     "uptime",
+			Platform:    runtime.GOOS,
+		}
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create command: %v", err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("create maintenance service: %v", err)
+	}
+	s := NewServer(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger),
+		maintenanceService, nil, nil, nil, nil)
+	// Start builds the engine; tests serve it without listening
+	s.setupEngine()
+	s.setupRoutes()
+	return s
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	enabled := config.CompressionConfig{Enabled: true, MinSize: 1024, ExcludedPaths: []string{"/api/v1/commands/export"}}
+
+	tests := []struct {
+		name           string
+		compression    config.CompressionConfig
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{"large command list", enabled, "/api/v1/commands", "gzip, deflate", true},
+		{"client without gzip", enabled, "/api/v1/commands", "", false},
+		{"gzip refused", enabled, "/api/v1/commands", "gzip;q=0", false},
+		{"under the minimum size", enabled, "/api/v1/commands/cmd-1", "gzip", false},
+		{"excluded path", enabled, "/api/v1/commands/export", "gzip", false},
+		{"compression disabled", config.CompressionConfig{MinSize: 1024}, "/api/v1/commands", "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCompressionTestServer(t, tt.compression, 200)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			s.engine.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", w.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+
+			body := w.Body.Bytes()
+			if gzipped {
+				if w.Header().Get("Content-Length") != "" {
+					t.Errorf("compressed response kept Content-Length %s", w.Header().Get("Content-Length"))
+				}
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("decompress: %v", err)
+				}
+				if len(body) <= w.Body.Len() {
+					t.Errorf("decompressed %d bytes from %d, want the list to shrink", len(body), w.Body.Len())
+				}
+			}
+
+			// The response formatter's envelope survives compression intact
+			var resp struct {
+				Success bool            `json:"success"`
+				Data    json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("decode response: %v: %.200s", err, body)
+			}
+			if !resp.Success || len(resp.Data) == 0 {
+				t.Errorf("response = %.200s, want a formatted success", body)
+			}
+			if tt.path == "/api/v1/commands" && !strings.Contains(string(resp.Data), `"cmd-199"`) {
+				t.Errorf("command list is missing cmd-199")
+			}
+			// Every response the middleware considers varies by encoding
+			considered := tt.compression.Enabled && tt.path != "/api/v1/commands/export"
+			if considered && !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+				t.Errorf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
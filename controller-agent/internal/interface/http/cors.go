@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsOrigins holds the origins allowed to make cross-origin requests
+type corsOrigins struct {
+	any     bool            // "*" was configured
+	allowed map[string]bool // normalized origins
+}
+
+// newCORSOrigins builds the allowlist from configured origins such as
+// "https://ctrl.example.com"; "*" allows every origin
+func newCORSOrigins(origins []string) corsOrigins {
+	o := corsOrigins{allowed: make(map[string]bool, len(origins))}
+	for _, origin := range origins {
+		if origin == "*" {
+			o.any = true
+			continue
+		}
+		if origin = normalizeOrigin(origin); origin != "" {
+			o.allowed[origin] = true
+		}
+	}
+	return o
+}
+
+// allows reports whether a request from origin may read the response
+func (o corsOrigins) allows(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	return o.any || o.allowed[normalizeOrigin(origin)]
+}
+
+// checkWebSocketOrigin accepts WebSocket handshakes from the agent's own host,
+// from clients that send no Origin, and from allowed origins
+func (o corsOrigins) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || o.allows(origin) {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
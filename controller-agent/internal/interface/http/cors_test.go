@@ -0,0 +1,102 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// newCORSRouter serves one route behind the CORS middleware with the given
+// allowed origins
+func newCORSRouter(origins []string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Server.HTTP.AllowedOrigins = origins
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &Server{config: cfg, logger: logger}
+	router := gin.New()
+	router.Use(s.corsMiddleware())
+	router.GET("/api/v1/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		method          string
+		origin          string
+		wantStatus      int
+		wantAllowOrigin string // empty when the origin must not be allowed
+		wantCredentials bool
+	}{
+		{"default denies any origin", nil, http.MethodGet, "https://evil.example", http.StatusOK, "", false},
+		{"default denies preflight", nil, http.MethodOptions, "https://evil.example", http.StatusNoContent, "", false},
+		{"same-origin request without Origin", nil, http.MethodGet, "", http.StatusOK, "", false},
+		{"listed origin", []string{"https://ctrl.example.com"}, http.MethodGet, "https://ctrl.example.com", http.StatusOK, "https://ctrl.example.com", true},
+		{"listed origin matched case-insensitively", []string{"https://Ctrl.example.com/"}, http.MethodGet, "https://ctrl.example.com", http.StatusOK, "https://ctrl.example.com", true},
+		{"listed origin preflight", []string{"https://ctrl.example.com"}, http.MethodOptions, "https://ctrl.example.com", http.StatusNoContent, "https://ctrl.example.com", true},
+		{"unlisted origin", []string{"https://ctrl.example.com"}, http.MethodGet, "https://evil.example", http.StatusOK, "", false},
+		{"wildcard opt-in allows without credentials", []string{"*"}, http.MethodGet, "https://any.example", http.StatusOK, "*", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/health", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			newCORSRouter(tt.origins).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("credentials allowed = %v, want %v", got, tt.wantCredentials)
+			}
+			if tt.wantAllowOrigin == "" && w.Header().Get("Access-Control-Allow-Methods") != "" {
+				t.Errorf("denied origin got Access-Control-Allow-Methods")
+			}
+		})
+	}
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		host    string
+		origin  string
+		want    bool
+	}{
+		{"no Origin header", nil, "agent.local:7070", "", true},
+		{"agent's own host", nil, "agent.local:7070", "http://agent.local:7070", true},
+		{"foreign origin by default", nil, "agent.local:7070", "https://evil.example", false},
+		{"listed origin", []string{"https://ctrl.example.com"}, "agent.local:7070", "https://ctrl.example.com", true},
+		{"unlisted origin", []string{"https://ctrl.example.com"}, "agent.local:7070", "https://evil.example", false},
+		{"wildcard opt-in", []string{"*"}, "agent.local:7070", "https://evil.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/execute/ws", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := newCORSOrigins(tt.origins).checkWebSocketOrigin(req); got != tt.want {
+				t.Errorf("checkWebSocketOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
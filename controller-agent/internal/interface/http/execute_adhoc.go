@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// AdhocCommandID is the command ID ad-hoc executions are audited and
+// reported under; it cannot clash with the IDs commands are normally given
+const AdhocCommandID = "<adhoc>"
+
+// AdhocExecuteRequest is a raw command string to run once without storing it
+type AdhocExecuteRequest struct {
+	Command  string `json:"command" binding:"required"`
+	AdminPin string `json:"adminPin" binding:"required"`
+	Timeout  int    `json:"timeout"` // milliseconds, clamped to the server max; 0 uses the default command timeout
+}
+
+// @Summary Execute an unsaved command string (DANGEROUS)
+// @Description DANGEROUS: runs an arbitrary command string on the agent host without storing it, for trying commands before saving them. Disabled unless security.allow_adhoc is set; requires the admin PIN, refuses clients that sent 5 wrong PINs within a minute, rejects the dangerous patterns created commands are checked against and is limited to security.adhoc_rate_limit_per_min executions per minute across all clients. Executions are audited under the command ID "<adhoc>"
+// @Tags execution
+// @Accept json
+// @Produce json
+// @Param request body AdhocExecuteRequest true "Command string and admin PIN"
+// @Param X-Tenant-Token header string false "Tenant token whose user is the command's LAZYCTRL_USER_ID"
+// @Success 200 {object} ExecuteResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ExecuteResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /execute/adhoc [post]
+func (h *ExecuteHandler) ExecuteAdhoc(c *gin.Context) {
+	if !h.allowAdhoc {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Ad-hoc execution disabled",
+			Message: "set security.allow_adhoc to run unsaved commands",
+		})
+		return
+	}
+
+	var req AdhocExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	if err := h.securityService.VerifyAdminPin(clientIP, req.AdminPin); err != nil {
+		h.logger.WithField("client_ip", clientIP).Warn("Ad-hoc execution rejected: invalid admin PIN")
+		c.JSON(pinError(err, http.StatusForbidden, ErrorResponse{
+			Error:   "Admin required",
+			Message: "ad-hoc execution requires the admin PIN",
+		}))
+		return
+	}
+	if err := h.securityService.CheckAdhocRateLimit(); err != nil {
+		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:   "Rate limit exceeded",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := h.maintenance.CheckExecution(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Maintenance mode",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := h.executorService.ValidateCommand(req.Command); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Command rejected",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	timeout := common.DefaultCommandTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Millisecond
+	}
+	if h.maxTimeout > 0 && timeout > h.maxTimeout {
+		timeout = h.maxTimeout
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"command":   req.Command,
+		"client_ip": clientIP,
+		"timeout":   timeout,
+	}).Warn("Executing ad-hoc command")
+
+	startTime := time.Now()
+	executeCtx, executeCancel := context.WithTimeout(context.Background(), timeout)
+	defer executeCancel()
+
+	result, err := h.executorService.ExecuteWithOptions(executeCtx, req.Command, executor.ExecuteOptions{
+		CommandID: AdhocCommandID,
+		UserID:    h.securityService.TenantUserID(c.GetHeader(common.HeaderXTenantToken)),
+		Source:    audit.InterfaceHTTP,
+		ClientIP:  clientIP,
+	})
+	duration := time.Since(startTime).Milliseconds()
+
+	status, response := executeResponse(&entity.Command{ID: AdhocCommandID}, result, err, duration)
+	c.JSON(status, response)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// newAdhocRouter serves ExecuteAdhoc with one ad-hoc execution allowed per
+// minute. Maintenance mode is on, so a request passing the PIN and rate
+// limit checks stops with 503 instead of running anything
+func newAdhocRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	cfg.Security.AllowAdhoc = true
+	cfg.Security.AdhocRateLimitPerMin = 1
+	handler := newTestExecuteHandler(t, cfg, newTestRepository(t))
+	handler.allowAdhoc = true
+	if _, err := handler.maintenance.SetMaintenanceMode(true, "test"); err != nil {
+		t.Fatalf("enable maintenance: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/execute/adhoc", handler.ExecuteAdhoc)
+	return router
+}
+
+func TestExecuteAdhocLimitsWrongPinsBeforeCheckingThem(t *testing.T) {
+	router := newAdhocRouter(t)
+
+	tests := []struct {
+		name     string
+		clientIP string
+		pin      string
+		want     int
+	}{
+		{"wrong PIN 1", "192.0.2.1", "0000", http.StatusForbidden},
+		{"wrong PIN 2", "192.0.2.1", "0000", http.StatusForbidden},
+		{"wrong PIN 3", "192.0.2.1", "0000", http.StatusForbidden},
+		{"wrong PIN 4", "192.0.2.1", "0000", http.StatusForbidden},
+		{"wrong PIN 5", "192.0.2.1", "0000", http.StatusForbidden},
+		{"wrong PIN after the limit", "192.0.2.1", "0000", http.StatusTooManyRequests},
+		{"right PIN after the limit is not checked", "192.0.2.1", testAdminPin, http.StatusTooManyRequests},
+		// Wrong PINs did not use up the shared ad-hoc budget
+		{"right PIN from another client", "192.0.2.2", testAdminPin, http.StatusServiceUnavailable},
+		{"shared ad-hoc limit still applies", "192.0.2.2", testAdminPin, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"command":"echo hi","adminPin":"` + tt.pin + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/execute/adhoc", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = tt.clientIP + ":1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
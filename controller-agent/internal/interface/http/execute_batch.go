@@ -0,0 +1,219 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Batch execution modes
+const (
+	BatchModeSequential = "sequential"
+	BatchModeParallel   = "parallel"
+)
+
+// BatchExecuteItem is one command of a batch
+type BatchExecuteItem struct {
+	ID       string `json:"id" binding:"required"`
+	Pin      string `json:"pin"`
+	AdminPin string `json:"adminPin"` // required by admin-only commands
+	Stdin    string `json:"stdin"`    // optional; piped to the command
+
+	Params map[string]interface{} `json:"params"` // optional; checked against the command's params
+}
+
+// BatchExecuteRequest represents the request payload for batch execution
+type BatchExecuteRequest struct {
+	Commands    []BatchExecuteItem `json:"commands" binding:"required,min=1,dive"`
+	Mode        string             `json:"mode"`        // sequential (default) or parallel
+	StopOnError bool               `json:"stopOnError"` // skip commands not yet started once one fails
+}
+
+// BatchItemResult is the outcome of one command of a batch. Commands that
+// failed a check before running carry the status and error the single execute
+// endpoint would have answered with instead of a result
+type BatchItemResult struct {
+	ID      string           `json:"id"`
+	Status  int              `json:"status,omitempty"`  // HTTP status the command alone would have returned
+	Skipped bool             `json:"skipped,omitempty"` // not started because an earlier command failed
+	Result  *ExecuteResponse `json:"result,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+// succeeded reports whether the command ran and exited successfully
+func (r BatchItemResult) succeeded() bool {
+	return r.Result != nil && r.Result.Success
+}
+
+// BatchExecuteResponse represents the response for batch execution
+type BatchExecuteResponse struct {
+	Success  bool              `json:"success"` // every command ran and succeeded
+	Mode     string            `json:"mode"`
+	Results  []BatchItemResult `json:"results"` // in request order
+	Duration int64             `json:"duration"` // Duration in milliseconds
+}
+
+// @Summary Execute a batch of commands
+// @Description Execute several commands in one request, one after another or in parallel. Each command goes through the same checks as a single execution, including PIN and rate limits, and the per-command results are returned in request order. The whole batch is bounded by the server max timeout
+// @Tags execution
+// @Accept json
+// @Produce json
+// @Param request body BatchExecuteRequest true "Commands to execute"
+// @Param X-Timeout-Ms header int false "Override the timeout of every command in milliseconds (clamped to the server max)"
+// @Param X-Client-ID header string false "Client identifier checked against each command's allowed clients"
+// @Success 200 {object} BatchExecuteResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /execute/batch [post]
+func (h *ExecuteHandler) ExecuteBatch(c *gin.Context) {
+	var req BatchExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = BatchModeSequential
+	}
+	if req.Mode != BatchModeSequential && req.Mode != BatchModeParallel {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid batch mode",
+			Message: "mode must be " + BatchModeSequential + " or " + BatchModeParallel,
+		})
+		return
+	}
+
+	if h.batch.MaxCommands > 0 && len(req.Commands) > h.batch.MaxCommands {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Too many commands",
+			Message: "a batch accepts at most " + strconv.Itoa(h.batch.MaxCommands) + " commands",
+		})
+		return
+	}
+
+	// Keep the whole batch within the time a single execution may take, so the
+	// response is written before the server write timeout
+	ctx, cancel := context.WithTimeout(context.Background(), h.maxTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+
+	var results []BatchItemResult
+	if req.Mode == BatchModeParallel {
+		results = h.executeBatchParallel(ctx, c, req)
+	} else {
+		results = h.executeBatchSequential(ctx, c, req)
+	}
+
+	success := true
+	for _, result := range results {
+		if !result.succeeded() {
+			success = false
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, BatchExecuteResponse{
+		Success:  success,
+		Mode:     req.Mode,
+		Results:  results,
+		Duration: time.Since(startTime).Milliseconds(),
+	})
+}
+
+// executeBatchSequential runs the commands one at a time in request order
+func (h *ExecuteHandler) executeBatchSequential(ctx context.Context, c *gin.Context, req BatchExecuteRequest) []BatchItemResult {
+	results := make([]BatchItemResult, len(req.Commands))
+	failed := false
+	for i, item := range req.Commands {
+		if failed && req.StopOnError {
+			results[i] = BatchItemResult{ID: item.ID, Skipped: true}
+			continue
+		}
+		results[i] = h.executeBatchItem(ctx, c, item)
+		if !results[i].succeeded() {
+			failed = true
+		}
+	}
+	return results
+}
+
+// executeBatchParallel starts every command at once; how many run together is
+// bounded by the executor's slots. With stopOnError, commands that have not
+// passed their checks are skipped once one fails; commands already running
+// or queued for a slot are left to finish
+func (h *ExecuteHandler) executeBatchParallel(ctx context.Context, c *gin.Context, req BatchExecuteRequest) []BatchItemResult {
+	results := make([]BatchItemResult, len(req.Commands))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for i, item := range req.Commands {
+		wg.Add(1)
+		go func(i int, item BatchExecuteItem) {
+			defer wg.Done()
+
+			if failed.Load() && req.StopOnError {
+				results[i] = BatchItemResult{ID: item.ID, Skipped: true}
+				return
+			}
+			results[i] = h.executeBatchItem(ctx, c, item)
+			if !results[i].succeeded() {
+				failed.Store(true)
+			}
+		}(i, item)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// executeBatchItem runs one command of a batch through the checks of a single
+// execution. Its timeout is cut short by the batch deadline
+func (h *ExecuteHandler) executeBatchItem(ctx context.Context, c *gin.Context, item BatchExecuteItem) BatchItemResult {
+	if ctx.Err() != nil {
+		return BatchItemResult{
+			ID:      item.ID,
+			Status:  http.StatusGatewayTimeout,
+			Error:   "Batch deadline exceeded",
+			Message: "the batch ran out of time before this command started",
+		}
+	}
+
+	prepared, failure := h.prepareExecution(c, ExecuteRequest{ID: item.ID, Pin: item.Pin, AdminPin: item.AdminPin, Params: item.Params})
+	if failure == nil {
+		failure = h.admitExecution(prepared.cmd)
+	}
+	if failure != nil {
+		return BatchItemResult{
+			ID:      item.ID,
+			Status:  failure.status,
+			Error:   failure.response.Error,
+			Message: failure.response.Message,
+		}
+	}
+
+	options := prepared.options
+	options.Stdin = []byte(item.Stdin)
+
+	executeCtx, cancel := context.WithTimeout(ctx, prepared.timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	result, err := h.executorService.ExecuteWithOptions(executeCtx, prepared.platformCommand, options)
+	duration := time.Since(startTime).Milliseconds()
+
+	status, response := executeResponse(prepared.cmd, result, err, duration)
+	return BatchItemResult{
+		ID:     item.ID,
+		Status: status,
+		Result: response,
+	}
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// batchSleep is how long the "slow" command of newBatchRouter runs
+const batchSleep = 200 * time.Millisecond
+
+// newBatchRouter serves ExecuteBatch over an executor running at most
+// maxConcurrent commands at once
+func newBatchRouter(t *testing.T, maxConcurrent int) *gin.Engine {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	cfg.Commands.Batch.MaxCommands = 10
+	repo := newTestRepository(t,
+		&entity.Command{ID: "ok", Name: "OK", Command: "true", Platform: runtime.GOOS},
+		&entity.Command{ID: "fail", Name: "Fail", Command: "false", Platform: runtime.GOOS},
+		&entity.Command{ID: "slow", Name: "Slow", Command: "sleep 0.2", Platform: runtime.GOOS},
+	)
+	handler := newTestExecuteHandler(t, cfg, repo)
+	handler.executorService.SetMaxConcurrent(maxConcurrent)
+
+	router := gin.New()
+	router.POST("/execute/batch", handler.ExecuteBatch)
+	return router
+}
+
+func postBatch(t *testing.T, router *gin.Engine, body string) (int, BatchExecuteResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/execute/batch", strings.NewReader(body))
+	req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp BatchExecuteResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestExecuteBatch(t *testing.T) {
+	router := newBatchRouter(t, 0)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantSuccess bool
+		wantOutcome []string // per command: ok, failed or skipped
+	}{
+		{"sequential", `{"commands":[{"id":"ok"},{"id":"ok"}]}`, http.StatusOK, true, []string{"ok", "ok"}},
+		{"sequential keeps going", `{"commands":[{"id":"fail"},{"id":"ok"}]}`, http.StatusOK, false, []string{"failed", "ok"}},
+		{"sequential stops on error", `{"commands":[{"id":"ok"},{"id":"fail"},{"id":"ok"}],"stopOnError":true}`, http.StatusOK, false, []string{"ok", "failed", "skipped"}},
+		{"unknown command", `{"commands":[{"id":"missing"},{"id":"ok"}]}`, http.StatusOK, false, []string{"failed", "ok"}},
+		{"parallel", `{"commands":[{"id":"ok"},{"id":"fail"},{"id":"ok"}],"mode":"parallel"}`, http.StatusOK, false, []string{"ok", "failed", "ok"}},
+		{"invalid mode", `{"commands":[{"id":"ok"}],"mode":"random"}`, http.StatusBadRequest, false, nil},
+		{"too many commands", `{"commands":[` + strings.Repeat(`{"id":"ok"},`, 10) + `{"id":"ok"}]}`, http.StatusBadRequest, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := postBatch(t, router, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("success = %v, want %v", resp.Success, tt.wantSuccess)
+			}
+			if len(resp.Results) != len(tt.wantOutcome) {
+				t.Fatalf("got %d results, want %d", len(resp.Results), len(tt.wantOutcome))
+			}
+			for i, result := range resp.Results {
+				outcome := "failed"
+				if result.Skipped {
+					outcome = "skipped"
+				} else if result.succeeded() {
+					outcome = "ok"
+				}
+				if outcome != tt.wantOutcome[i] {
+					t.Fatalf("result %d (%s) = %s, want %s", i, result.ID, outcome, tt.wantOutcome[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteBatchParallelUsesExecutorSlots(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		minDuration   time.Duration
+		maxDuration   time.Duration
+	}{
+		{"unlimited runs together", 0, batchSleep, 3 * batchSleep},
+		{"one slot runs one at a time", 1, 4 * batchSleep, time.Minute},
+		{"two slots run in pairs", 2, 2 * batchSleep, 4 * batchSleep},
+	}
+	body := `{"commands":[{"id":"slow"},{"id":"slow"},{"id":"slow"},{"id":"slow"}],"mode":"parallel"}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newBatchRouter(t, tt.maxConcurrent)
+
+			start := time.Now()
+			status, resp := postBatch(t, router, body)
+			elapsed := time.Since(start)
+			if status != http.StatusOK || !resp.Success {
+				t.Fatalf("status %d, response %+v", status, resp)
+			}
+			if elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Fatalf("batch took %v, want between %v and %v", elapsed, tt.minDuration, tt.maxDuration)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandContextVariables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command runs sh")
+	}
+	repo := newTestRepository(t,
+		&entity.Command{ID: "whoami", Name: "Who am I", Command: "echo {{.DeviceID}} [{{.UserID}}] {{.Platform}}", Platform: runtime.GOOS},
+	)
+	cfg := &config.Config{}
+	cfg.Commands.Tenants = []config.TenantToken{{Token: "alice-token", UserID: "alice"}}
+	handler := newTestExecuteHandler(t, cfg, repo)
+	handler.executorService.SetDeviceID("device-1")
+	router := gin.New()
+	router.GET("/execute", handler.ExecuteCommand)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{"tenant token", common.HeaderXTenantToken, "alice-token", "device-1 [alice] " + runtime.GOOS},
+		{"unknown token", common.HeaderXTenantToken, "mallory-token", "device-1 [] " + runtime.GOOS},
+		{"claimed user ID is ignored", "X-User-ID", "mallory", "device-1 [] " + runtime.GOOS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/execute?id=whoami", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var resp ExecuteResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got := strings.TrimSpace(resp.Output); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,22 @@ package http
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
 )
 
@@ -16,6 +26,12 @@ type ExecuteHandler struct {
 	commandService  *service.CommandService
 	executorService *executor.Service
 	securityService *security.Service
+	maintenance     *maintenance.Service
+	maxTimeout      time.Duration
+	batch           config.BatchConfig
+	allowAdhoc      bool // serve POST /execute/adhoc, see ExecuteAdhoc
+	upgrader        websocket.Upgrader
+	logger          *logrus.Logger
 }
 
 // NewExecuteHandler creates a new execute handler
@@ -23,40 +39,94 @@ func NewExecuteHandler(
 	commandService *service.CommandService,
 	executorService *executor.Service,
 	securityService *security.Service,
+	maintenanceService *maintenance.Service,
+	maxTimeout time.Duration,
+	batch config.BatchConfig,
+	allowAdhoc bool,
+	origins corsOrigins,
+	logger *logrus.Logger,
 ) *ExecuteHandler {
 	return &ExecuteHandler{
 		commandService:  commandService,
 		executorService: executorService,
 		securityService: securityService,
+		maintenance:     maintenanceService,
+		maxTimeout:      maxTimeout,
+		batch:           batch,
+		allowAdhoc:      allowAdhoc,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: origins.checkWebSocketOrigin,
+		},
+		logger: logger,
 	}
 }
 
 // ExecuteRequest represents the request payload for command execution
 type ExecuteRequest struct {
-	ID  string `form:"id" json:"id" binding:"required"`
-	Pin string `form:"pin" json:"pin"`
+	ID       string `form:"id" json:"id" binding:"required"`
+	Pin      string `form:"pin" json:"pin"`
+	AdminPin string `form:"adminPin" json:"adminPin"` // required by admin-only commands
+	RunID    string `form:"runId" json:"runId"`       // optional, generated when empty
+	Stdin    string `form:"-" json:"stdin"`           // optional, POST only; piped to the command
+	DryRun   bool   `form:"dryRun" json:"dryRun"`     // resolve and validate without executing
+
+	Params map[string]interface{} `form:"-" json:"params"` // optional, POST only; checked against the command's params, overriding its templateParams
 }
 
 // ExecuteResponse represents the response for command execution
 type ExecuteResponse struct {
-	Success bool   `json:"success"`
-	Output  string `json:"output"`
-	Error   string `json:"error,omitempty"`
-	ExitCode int   `json:"exitCode"`
-	Duration int64  `json:"duration"` // Duration in milliseconds
+	RunID        string `json:"runId,omitempty"`
+	CommandID    string `json:"commandId,omitempty"` // canonical ID, also when executed by alias
+	Cancelled    bool   `json:"cancelled,omitempty"`
+	Success      bool   `json:"success"`
+	Output       string `json:"output"`
+	OmittedLines int    `json:"omittedLines,omitempty"` // lines dropped from the middle of long output
+	Truncated    bool   `json:"truncated,omitempty"`    // output exceeded the size cap
+	Killed       bool   `json:"killed,omitempty"`       // stopped on timeout or cancellation
+	KilledBy     string `json:"killedBy,omitempty"`     // SIGTERM, or SIGKILL once the grace period ran out
+	Attempts     int    `json:"attempts,omitempty"`     // executions of the command, more than one when retried
+	Error        string `json:"error,omitempty"`
+	ExitCode     int    `json:"exitCode"`
+	Duration     int64  `json:"duration"` // Duration in milliseconds
+	Signature    string `json:"signature,omitempty"` // HMAC of runId, commandId, success, exitCode, output and error
+
+	PreHook  *executor.HookResult `json:"preHook,omitempty"`
+	PostHook *executor.HookResult `json:"postHook,omitempty"`
+
+	Diagnostic *executor.DiagnosticResult `json:"diagnostic,omitempty"` // output of the command's failure diagnostic
+
+	Data          interface{} `json:"data,omitempty"`          // output parsed per the command's outputFormat
+	OutputWarning string      `json:"outputWarning,omitempty"` // why the output could not be parsed
+}
+
+// DryRunResponse represents a resolved command that was not executed
+type DryRunResponse struct {
+	DryRun      bool   `json:"dryRun"`
+	CommandID   string `json:"commandId"`
+	Timeout     int64  `json:"timeout"` // milliseconds
+	RequiresPin bool   `json:"requiresPin"`
+	executor.DryRunResult
 }
 
 // @Summary Execute a command
-// @Description Execute a command by its ID
+// @Description Execute a command by its ID. With dryRun=true the command is resolved and validated but not executed, returning a DryRunResponse
 // @Tags execution
 // @Accept json
 // @Produce json
 // @Param id query string true "Command ID"
 // @Param pin query string false "PIN for authentication (if required)"
+// @Param adminPin query string false "Admin PIN, required by admin-only commands"
+// @Param runId query string false "Run ID used to cancel the execution (generated when empty)"
+// @Param dryRun query bool false "Resolve and validate the command without executing it"
+// @Param X-Timeout-Ms header int false "Override the command timeout in milliseconds (clamped to the server max)"
+// @Param X-Client-ID header string false "Client identifier checked against the command's allowed clients"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode other tenants' commands are not found. Its user is the command's LAZYCTRL_USER_ID"
 // @Success 200 {object} ExecuteResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 424 {object} ExecuteResponse
 // @Failure 429 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /execute [get]
@@ -74,15 +144,21 @@ func (h *ExecuteHandler) ExecuteCommand(c *gin.Context) {
 }
 
 // @Summary Execute a command (POST)
-// @Description Execute a command by its ID using POST method
+// @Description Execute a command by its ID using POST method. With dryRun=true the command is resolved and validated but not executed, returning a DryRunResponse
 // @Tags execution
 // @Accept json
 // @Produce json
 // @Param request body ExecuteRequest true "Execute request"
+// @Param X-Timeout-Ms header int false "Override the command timeout in milliseconds (clamped to the server max)"
+// @Param X-Client-ID header string false "Client identifier checked against the command's allowed clients"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode other tenants' commands are not found. Its user is the command's LAZYCTRL_USER_ID"
 // @Success 200 {object} ExecuteResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ExecuteResponse
+// @Failure 424 {object} ExecuteResponse
 // @Failure 429 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /execute [post]
@@ -101,18 +177,245 @@ func (h *ExecuteHandler) ExecuteCommandPost(c *gin.Context) {
 
 // executeCommand performs the actual command execution
 func (h *ExecuteHandler) executeCommand(c *gin.Context, req ExecuteRequest) {
+	prepared, failure := h.prepareExecution(c, req)
+	if failure != nil {
+		c.JSON(failure.status, failure.response)
+		return
+	}
+	cmd := prepared.cmd
+	platformCommand := prepared.platformCommand
+	executeOptions := prepared.options
+	executeOptions.Stdin = []byte(req.Stdin)
+	
+	if req.DryRun {
+		preview := h.executorService.DryRun(platformCommand, executeOptions)
+		if err := h.securityService.CheckCommandAccess(cmd); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		} else if !cmd.IsWhitelisted() {
+			preview.Warnings = append(preview.Warnings, "command is not whitelisted")
+		}
+		if err := h.securityService.CheckAllowedHours(cmd); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		}
+		if err := h.maintenance.CheckExecution(); err != nil {
+			preview.Warnings = append(preview.Warnings, err.Error())
+		}
+		c.JSON(http.StatusOK, DryRunResponse{
+			DryRun:       true,
+			CommandID:    cmd.ID,
+			Timeout:      prepared.timeout.Milliseconds(),
+			RequiresPin:  cmd.RequiresPin(),
+			DryRunResult: *preview,
+		})
+		return
+	}
+	
+	if failure := h.admitExecution(cmd); failure != nil {
+		c.JSON(failure.status, failure.response)
+		return
+	}
+	
+	// Record execution start time
+	startTime := time.Now()
+	
+	// Execute command with timeout; not derived from the lookup context so overrides can exceed it
+	executeCtx, executeCancel := context.WithTimeout(context.Background(), prepared.timeout)
+	defer executeCancel()
+	
+	result, err := h.executorService.ExecuteWithOptions(executeCtx, platformCommand, executeOptions)
+	duration := time.Since(startTime).Milliseconds()
+	
+	status, response := executeResponse(cmd, result, err, duration)
+	c.JSON(status, response)
+}
+
+// executeResponse converts an execution outcome to the response and its status.
+// Failures to run the command at all have exit code -1
+func executeResponse(cmd *entity.Command, result *executor.ExecutionResult, err error, duration int64) (int, *ExecuteResponse) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrStdinTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		} else if errors.Is(err, executor.ErrTenantBusy) || errors.Is(err, executor.ErrExecutorBusy) {
+			status = http.StatusTooManyRequests
+		} else if errors.Is(err, executor.ErrDependencyNotSatisfied) {
+			status = http.StatusFailedDependency
+		}
+		return status, &ExecuteResponse{
+			CommandID: cmd.ID,
+			Success:   false,
+			Output:    "",
+			Error:     err.Error(),
+			ExitCode:  -1,
+			Duration:  duration,
+		}
+	}
+	
+	return http.StatusOK, &ExecuteResponse{
+		RunID:        result.RunID,
+		CommandID:    cmd.ID,
+		Cancelled:    result.Cancelled,
+		Success:      result.Success,
+		Output:       result.Output,
+		OmittedLines: result.OmittedLines,
+		Truncated:    result.Truncated,
+		Killed:       result.Killed,
+		KilledBy:     result.KilledBy,
+		Attempts:     result.Attempts,
+		Error:        result.Error,
+		ExitCode:     result.ExitCode,
+		Duration:     duration,
+		Signature:    result.Signature,
+		PreHook:      result.PreHook,
+		PostHook:     result.PostHook,
+		Diagnostic:   result.Diagnostic,
+
+		Data:          result.Data,
+		OutputWarning: result.OutputWarning,
+	}
+}
+
+// @Summary Cancel a running command
+// @Description Cancel an in-progress execution by its run ID, terminating the child process. Only the caller that started the run, by tenant token or else by address, or a caller with the admin PIN can cancel it
+// @Tags execution
+// @Produce json
+// @Param run_id path string true "Run ID"
+// @Param adminPin query string false "Admin PIN, to cancel runs started by other callers"
+// @Param X-Tenant-Token header string false "Tenant token the run was started with"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /execute/{run_id}/cancel [post]
+func (h *ExecuteHandler) CancelRun(c *gin.Context) {
+	runID := c.Param("run_id")
+	
+	admin, ok := h.checkRunAdmin(c)
+	if !ok {
+		return
+	}
+	
+	// Other callers' runs are reported as missing rather than forbidden, so
+	// run IDs cannot be probed
+	run, exists := h.executorService.GetRun(runID)
+	if !exists || !(admin || h.startedRun(c, run)) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Run not found",
+			Message: "run not found: " + runID,
+		})
+		return
+	}
+	
+	if err := h.executorService.CancelRun(runID); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Run not found",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	h.logger.WithFields(logrus.Fields{
+		"run_id":    runID,
+		"client_ip": c.ClientIP(),
+		"admin":     admin,
+	}).Info("Run cancelled over HTTP")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"runId":   runID,
+		"message": "Cancellation requested",
+	})
+}
+
+// @Summary List active runs
+// @Description List command executions that are currently in progress. Without the admin PIN only the caller's own runs are listed, matched by tenant token or else by address
+// @Tags execution
+// @Produce json
+// @Param adminPin query string false "Admin PIN, to list every caller's runs"
+// @Param X-Tenant-Token header string false "Tenant token the runs were started with"
+// @Success 200 {array} executor.ActiveRun
+// @Failure 403 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /execute/active [get]
+func (h *ExecuteHandler) ListActiveRuns(c *gin.Context) {
+	admin, ok := h.checkRunAdmin(c)
+	if !ok {
+		return
+	}
+	
+	runs := h.executorService.ListActiveRuns()
+	if !admin {
+		own := make([]*executor.ActiveRun, 0, len(runs))
+		for _, run := range runs {
+			if h.startedRun(c, run) {
+				own = append(own, run)
+			}
+		}
+		runs = own
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// checkRunAdmin reports whether the caller passed a valid admin PIN in the
+// adminPin query parameter. A wrong PIN is answered with 403 and counts
+// towards the caller's failed PIN limit; ok is false when a response was written
+func (h *ExecuteHandler) checkRunAdmin(c *gin.Context) (admin bool, ok bool) {
+	pin := c.Query("adminPin")
+	if pin == "" {
+		return false, true
+	}
+	
+	if err := h.securityService.VerifyAdminPin(c.ClientIP(), pin); err != nil {
+		c.JSON(pinError(err, http.StatusForbidden, ErrorResponse{
+			Error:   "Authentication failed",
+			Message: "Invalid admin PIN",
+		}))
+		return false, false
+	}
+	return true, true
+}
+
+// startedRun reports whether the caller started the run
+func (h *ExecuteHandler) startedRun(c *gin.Context, run *executor.ActiveRun) bool {
+	return run.StartedBy(h.securityService.TenantUserID(c.GetHeader(common.HeaderXTenantToken)), c.ClientIP())
+}
+
+// preparedExecution is a command that passed the checks shared by the execution endpoints
+type preparedExecution struct {
+	cmd             *entity.Command
+	platformCommand string
+	options         executor.ExecuteOptions
+	timeout         time.Duration
+}
+
+// executionError is a failed execution check and the response it answers with
+type executionError struct {
+	status   int
+	response ErrorResponse
+}
+
+// prepareExecution runs the checks every execution endpoint applies before
+// anything runs: timeout header, client rate limit, command lookup, client
+// restriction, PIN and params. It only reads the request, so batch items share it
+func (h *ExecuteHandler) prepareExecution(c *gin.Context, req ExecuteRequest) (*preparedExecution, *executionError) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
 	clientIP := c.ClientIP()
 	
+	timeoutOverride, err := parseTimeoutHeader(c.GetHeader(common.HeaderXTimeoutMs))
+	if err != nil {
+		return nil, &executionError{status: http.StatusBadRequest, response: ErrorResponse{
+			Error:   "Invalid timeout header",
+			Message: err.Error(),
+		}}
+	}
+	
 	// Rate limiting check
 	if err := h.securityService.CheckRateLimit(clientIP); err != nil {
-		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		return nil, &executionError{status: http.StatusTooManyRequests, response: ErrorResponse{
 			Error:   "Rate limit exceeded",
 			Message: "Too many requests, please try again later",
-		})
-		return
+		}}
 	}
 	
 	// Get command to check if PIN is required
@@ -122,63 +425,226 @@ func (h *ExecuteHandler) executeCommand(c *gin.Context, req ExecuteRequest) {
 		if err.Error() == "failed to get command: command not found: "+req.ID {
 			status = http.StatusNotFound
 		}
-		c.JSON(status, ErrorResponse{
+		return nil, &executionError{status: status, response: ErrorResponse{
 			Error:   "Command not found",
 			Message: err.Error(),
-		})
-		return
+		}}
+	}
+	if hiddenFromCaller(c, h.securityService, cmd, req.AdminPin) {
+		return nil, &executionError{status: http.StatusNotFound, response: ErrorResponse{
+			Error:   "Command not found",
+			Message: "failed to get command: command not found: " + req.ID,
+		}}
+	}
+	
+	// Per-command client restriction
+	if !cmd.IsClientAllowed(c.GetHeader(common.HeaderXClientID), clientIP) {
+		return nil, &executionError{status: http.StatusForbidden, response: ErrorResponse{
+			Error:   "Client not allowed",
+			Message: common.ErrClientNotAllowed.Error(),
+		}}
 	}
 	
 	// PIN verification if required
 	if cmd.RequiresPin() {
-		if !h.securityService.ValidatePin(req.Pin) {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{
+		if err := h.securityService.VerifyPin(clientIP, req.Pin); err != nil {
+			status, response := pinError(err, http.StatusUnauthorized, ErrorResponse{
 				Error:   "Authentication failed",
 				Message: "Invalid or missing PIN",
 			})
-			return
+			return nil, &executionError{status: status, response: response}
+		}
+	}
+	
+	// Admin-only commands need the admin PIN in addition to any regular PIN
+	if cmd.RequiresAdmin() {
+		if err := h.securityService.VerifyAdminPin(clientIP, req.AdminPin); err != nil {
+			status, response := pinError(err, http.StatusForbidden, ErrorResponse{
+				Error:   "Admin required",
+				Message: common.ErrAdminRequired.Error(),
+			})
+			return nil, &executionError{status: status, response: response}
 		}
 	}
 	
 	// Get platform-specific command
-	platformCommand, err := h.commandService.GetPlatformCommand(ctx, req.ID)
+	platformCommand, err := h.commandService.GetPlatformCommand(ctx, cmd.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		return nil, &executionError{status: http.StatusBadRequest, response: ErrorResponse{
 			Error:   "Command not available",
 			Message: err.Error(),
-		})
-		return
+		}}
 	}
 	
-	// Record execution start time
-	startTime := time.Now()
+	env, err := cmd.ExecutionEnv(req.Params)
+	if err != nil {
+		return nil, &executionError{status: http.StatusBadRequest, response: ErrorResponse{
+			Error:   "Invalid params",
+			Message: err.Error(),
+		}}
+	}
 	
-	// Execute command with timeout
-	executeCtx, executeCancel := context.WithTimeout(ctx, time.Duration(cmd.GetTimeout())*time.Millisecond)
-	defer executeCancel()
+	options := executor.ExecuteOptions{
+		WorkingDir: cmd.WorkingDir,
+		Env:        env,
+		RunID:      req.RunID,
+		CommandID:  cmd.ID,
+		Publish:    cmd.PublishResults,
+		LoginShell: cmd.LoginShell,
+		Shell:      cmd.Shell,
+		Args:       cmd.Args,
+		Tenant:     cmd.Tenant(),
+		UserID:     h.securityService.TenantUserID(c.GetHeader(common.HeaderXTenantToken)),
+		Category:   cmd.Category,
+
+		PreHook:     cmd.PreHook,
+		PostHook:    cmd.PostHook,
+		StrictHooks: cmd.StrictHooks,
+
+		OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+		OutputFormat:        cmd.OutputFormat,
+		Retry:               cmd.Retry,
+		DependsOn:           cmd.DependsOn,
+		AutoRunDeps:         cmd.AutoRunDeps,
+
+		Source:      audit.InterfaceHTTP,
+		ClientIP:    clientIP,
+		RequiresPin: cmd.RequiresPin(),
+	}
 	
-	result, err := h.executorService.Execute(executeCtx, platformCommand)
-	duration := time.Since(startTime).Milliseconds()
+	return &preparedExecution{
+		cmd:             cmd,
+		platformCommand: platformCommand,
+		options:         options,
+		timeout:         h.executionTimeout(cmd, timeoutOverride),
+	}, nil
+}
+
+// admitExecution applies the checks dry runs only warn about: maintenance
+// mode, the command whitelist, allowed hours and rate limit
+func (h *ExecuteHandler) admitExecution(cmd *entity.Command) *executionError {
+	// Nothing runs while the agent is in maintenance
+	if err := h.maintenance.CheckExecution(); err != nil {
+		return &executionError{status: http.StatusServiceUnavailable, response: ErrorResponse{
+			Error:   "Maintenance mode",
+			Message: err.Error(),
+		}}
+	}
+	
+	// Command whitelist
+	if err := h.securityService.CheckCommandAccess(cmd); err != nil {
+		return &executionError{status: http.StatusForbidden, response: ErrorResponse{
+			Error:   "Command not allowed",
+			Message: err.Error(),
+		}}
+	}
+	
+	// Per-command time-of-day window
+	if err := h.securityService.CheckAllowedHours(cmd); err != nil {
+		return &executionError{status: http.StatusForbidden, response: ErrorResponse{
+			Error:   "Outside allowed hours",
+			Message: err.Error(),
+		}}
+	}
+	
+	// Per-command rate limiting on top of the per-client limit
+	if cmd.HasRateLimit() {
+		window := time.Duration(cmd.RateLimit.Window) * time.Second
+		if err := h.securityService.CheckCommandRateLimit(cmd.ID, cmd.RateLimit.MaxExecutions, window); err != nil {
+			return &executionError{status: http.StatusTooManyRequests, response: ErrorResponse{
+				Error:   "Command rate limit exceeded",
+				Message: err.Error(),
+			}}
+		}
+	}
+	
+	return nil
+}
+
+// executionTimeout returns the command timeout, replaced by the request
+// override when present and clamped to the command and server max
+func (h *ExecuteHandler) executionTimeout(cmd *entity.Command, override time.Duration) time.Duration {
+	timeout, clamped := cmd.ExecutionTimeout(override, h.maxTimeout)
+	if clamped {
+		h.logger.WithFields(logrus.Fields{
+			"command_id": cmd.ID,
+			"requested":  override,
+			"timeout":    timeout,
+		}).Info("Execution timeout clamped to the maximum")
+	}
+	return timeout
+}
+
+// parseTimeoutHeader parses the X-Timeout-Ms header, returning zero when absent
+func parseTimeoutHeader(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", common.HeaderXTimeoutMs, value)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// @Summary Get effective command security
+// @Description Report what the calling client must satisfy to execute a command over a transport: PIN, admin flag, whitelist, client restrictions, allowed hours and rate limits
+// @Tags commands
+// @Produce json
+// @Param id path string true "Command ID"
+// @Param transport query string false "Transport the caller will execute over: http, grpc or mqtt" default(http)
+// @Param X-Client-ID header string false "Client identifier checked against the command's allowed clients"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode other tenants' commands are not found"
+// @Success 200 {object} security.EffectiveSecurity
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /commands/{id}/security [get]
+func (h *ExecuteHandler) GetCommandSecurity(c *gin.Context) {
+	id := c.Param("id")
+	
+	caller := security.Caller{
+		Transport: c.DefaultQuery("transport", audit.InterfaceHTTP),
+		ClientID:  c.GetHeader(common.HeaderXClientID),
+		IP:        c.ClientIP(),
+	}
+	switch caller.Transport {
+	case audit.InterfaceHTTP, audit.InterfaceGRPC:
+	case audit.InterfaceMQTT:
+		// MQTT carries no client address, so only client IDs can match
+		caller.IP = ""
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid transport",
+			Message: fmt.Sprintf("transport must be %s, %s or %s", audit.InterfaceHTTP, audit.InterfaceGRPC, audit.InterfaceMQTT),
+		})
+		return
+	}
 	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	cmd, err := h.commandService.GetCommand(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ExecuteResponse{
-			Success:  false,
-			Output:   "",
-			Error:    err.Error(),
-			ExitCode: -1,
-			Duration: duration,
+		status := http.StatusInternalServerError
+		if err.Error() == "failed to get command: command not found: "+id {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, ErrorResponse{
+			Error:   "Command not found",
+			Message: err.Error(),
+		})
+		return
+	}
+	if hiddenFromCaller(c, h.securityService, cmd, c.Query("adminPin")) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Command not found",
+			Message: "failed to get command: command not found: " + id,
 		})
 		return
 	}
 	
-	// Return successful execution result
-	c.JSON(http.StatusOK, ExecuteResponse{
-		Success:  result.Success,
-		Output:   result.Output,
-		Error:    result.Error,
-		ExitCode: result.ExitCode,
-		Duration: duration,
-	})
+	c.JSON(http.StatusOK, h.securityService.EffectiveSecurity(cmd, caller))
 }
 
 // @Summary Get command execution info
@@ -186,6 +652,7 @@ func (h *ExecuteHandler) executeCommand(c *gin.Context, req ExecuteRequest) {
 // @Tags execution
 // @Produce json
 // @Param id query string true "Command ID"
+// @Param X-Tenant-Token header string false "Tenant token; in tenancy mode other tenants' commands are not found"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
@@ -204,6 +671,14 @@ func (h *ExecuteHandler) GetCommandInfo(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
+	if cmd, err := h.commandService.GetCommand(ctx, id); err == nil && hiddenFromCaller(c, h.securityService, cmd, c.Query("adminPin")) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Failed to get command info",
+			Message: "failed to get command: command not found: " + id,
+		})
+		return
+	}
+	
 	info, err := h.commandService.GetCommandInfo(ctx, id)
 	if err != nil {
 		status := http.StatusInternalServerError
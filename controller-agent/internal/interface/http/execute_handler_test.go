@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/repository"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// newTestRepository returns a file repository in a temporary directory
+// holding commands
+func newTestRepository(t *testing.T, commands ...*entity.Command) repository.CommandRepository {
+	t.Helper()
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	return repo
+}
+
+// newTestExecuteHandler returns an ExecuteHandler serving repo under cfg
+// with a fresh executor, maintenance mode off and timeouts capped at a
+// minute. Tests reach its services through the handler's fields
+func newTestExecuteHandler(t *testing.T, cfg *config.Config, repo repository.CommandRepository) *ExecuteHandler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	return NewExecuteHandler(service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService, time.Minute, cfg.Commands.Batch, false, corsOrigins{}, logger)
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandParams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command expands a POSIX shell variable")
+	}
+	specs := []entity.ParamSpec{
+		{Name: "host", Type: common.ParamTypeString, Required: true},
+		{Name: "mode", Type: common.ParamTypeEnum, Values: []string{"fast", "safe"}, Default: "safe"},
+	}
+	repo := newTestRepository(t, &entity.Command{
+		ID:       "ping",
+		Name:     "Ping",
+		Command:  "echo $LAZYCTRL_PARAM_HOST $LAZYCTRL_PARAM_MODE",
+		Platform: runtime.GOOS,
+		Params:   specs,
+	})
+	handler := newTestExecuteHandler(t, &config.Config{}, repo)
+	router := gin.New()
+	router.POST("/execute", handler.ExecuteCommandPost)
+	router.GET("/execute/info", handler.GetCommandInfo)
+
+	tests := []struct {
+		name       string
+		params     map[string]interface{}
+		wantStatus int
+		wantOutput string
+	}{
+		{"default filled in", map[string]interface{}{"host": "nas"}, http.StatusOK, "nas safe"},
+		{"enum value", map[string]interface{}{"host": "nas", "mode": "fast"}, http.StatusOK, "nas fast"},
+		{"required missing", map[string]interface{}{"mode": "fast"}, http.StatusBadRequest, ""},
+		{"enum violation", map[string]interface{}{"host": "nas", "mode": "reckless"}, http.StatusBadRequest, ""},
+		{"unknown param", map[string]interface{}{"host": "nas", "user": "root"}, http.StatusBadRequest, ""},
+		{"type mismatch", map[string]interface{}{"host": 42}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ExecuteRequest{ID: "ping", Params: tt.params})
+			req := httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(string(body)))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp ExecuteResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got := strings.TrimSpace(resp.Output); got != tt.wantOutput {
+				t.Errorf("output = %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+
+	t.Run("info exposes the specs", func(t *testing.T) {
+		var info struct {
+			Params []entity.ParamSpec `json:"params"`
+		}
+		if status := getJSON(t, router, "/execute/info?id=ping", &info); status != http.StatusOK {
+			t.Fatalf("status = %d", status)
+		}
+		if !reflect.DeepEqual(info.Params, specs) {
+			t.Errorf("params = %+v, want %+v", info.Params, specs)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+func TestExecuteResponse(t *testing.T) {
+	cmd := &entity.Command{ID: "cmd"}
+
+	tests := []struct {
+		name          string
+		result        *executor.ExecutionResult
+		err           error
+		wantStatus    int
+		wantTruncated bool
+		wantExitCode  int
+	}{
+		{"success", &executor.ExecutionResult{Success: true, Output: "ok"}, nil, http.StatusOK, false, 0},
+		{"truncated output", &executor.ExecutionResult{Success: true, Output: "partial\n[output truncated]", Truncated: true}, nil, http.StatusOK, true, 0},
+		{"command failed", &executor.ExecutionResult{Output: "boom", ExitCode: 2}, nil, http.StatusOK, false, 2},
+		{"stdin too large", nil, fmt.Errorf("%w: 9 bytes", executor.ErrStdinTooLarge), http.StatusRequestEntityTooLarge, false, -1},
+		{"executor busy", nil, executor.ErrExecutorBusy, http.StatusTooManyRequests, false, -1},
+		{"tenant busy", nil, executor.ErrTenantBusy, http.StatusTooManyRequests, false, -1},
+		{"dependency failed", nil, fmt.Errorf("%w: setup", executor.ErrDependencyNotSatisfied), http.StatusFailedDependency, false, -1},
+		{"other error", nil, errors.New("start failed"), http.StatusInternalServerError, false, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := executeResponse(cmd, tt.result, tt.err, 42)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if resp.Truncated != tt.wantTruncated || resp.ExitCode != tt.wantExitCode || resp.CommandID != "cmd" || resp.Duration != 42 {
+				t.Errorf("response = %+v, want truncated %v, exit code %d", resp, tt.wantTruncated, tt.wantExitCode)
+			}
+			if tt.result != nil && resp.Output != tt.result.Output {
+				t.Errorf("output = %q, want %q", resp.Output, tt.result.Output)
+			}
+			if tt.err != nil && (resp.Success || resp.Error != tt.err.Error()) {
+				t.Errorf("response = %+v, want failure with %q", resp, tt.err)
+			}
+		})
+	}
+}
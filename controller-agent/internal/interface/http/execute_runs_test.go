@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// newRunsRouter serves the active run endpoints over two running commands:
+// alice-run, started with alice's tenant token from 192.0.2.10, and
+// anon-run, started without a token from 192.0.2.20
+func newRunsRouter(t *testing.T) (*gin.Engine, *executor.Service) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	cfg.Commands.Tenants = []config.TenantToken{{Token: "alice-token", UserID: "alice"}, {Token: "bob-token", UserID: "bob"}}
+	handler := newTestExecuteHandler(t, cfg, newTestRepository(t))
+	executorService := handler.executorService
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	for _, opts := range []executor.ExecuteOptions{
+		{RunID: "alice-run", UserID: "alice", ClientIP: "192.0.2.10"},
+		{RunID: "anon-run", ClientIP: "192.0.2.20"},
+	} {
+		go func(opts executor.ExecuteOptions) {
+			executorService.ExecuteWithOptions(ctx, "sleep 30", opts)
+			done <- struct{}{}
+		}(opts)
+	}
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		<-done
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(executorService.ListActiveRuns()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("runs did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	router := gin.New()
+	router.GET("/execute/active", handler.ListActiveRuns)
+	router.POST("/execute/:run_id/cancel", handler.CancelRun)
+	return router, executorService
+}
+
+func serveRuns(router *gin.Engine, method, path, clientIP, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = clientIP + ":40000"
+	if token != "" {
+		req.Header.Set(common.HeaderXTenantToken, token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListActiveRuns(t *testing.T) {
+	router, _ := newRunsRouter(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		clientIP string
+		token    string
+		status   int
+		want     []string
+	}{
+		{"admin PIN lists every run", "?adminPin=" + testAdminPin, "192.0.2.99", "", http.StatusOK, []string{"alice-run", "anon-run"}},
+		{"tenant token lists its user's runs", "", "192.0.2.99", "alice-token", http.StatusOK, []string{"alice-run"}},
+		{"address lists runs started from it", "", "192.0.2.20", "", http.StatusOK, []string{"anon-run"}},
+		{"tenant's address alone is not enough", "", "192.0.2.10", "", http.StatusOK, []string{}},
+		{"unknown caller lists nothing", "", "192.0.2.99", "", http.StatusOK, []string{}},
+		{"wrong admin PIN", "?adminPin=0000", "192.0.2.99", "", http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRuns(router, http.MethodGet, "/execute/active"+tt.query, tt.clientIP, tt.token)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+			var runs []executor.ActiveRun
+			if err := json.Unmarshal(w.Body.Bytes(), &runs); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			got := []string{}
+			for _, run := range runs {
+				got = append(got, run.RunID)
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("runs = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("runs = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelRun(t *testing.T) {
+	router, executorService := newRunsRouter(t)
+
+	// Steps run in order; a successful cancel ends the run
+	tests := []struct {
+		name     string
+		path     string
+		clientIP string
+		token    string
+		status   int
+	}{
+		{"other address", "/execute/anon-run/cancel", "192.0.2.99", "", http.StatusNotFound},
+		{"other tenant's token", "/execute/alice-run/cancel", "192.0.2.99", "bob-token", http.StatusNotFound},
+		{"tenant's address without its token", "/execute/alice-run/cancel", "192.0.2.10", "", http.StatusNotFound},
+		{"wrong admin PIN", "/execute/anon-run/cancel?adminPin=0000", "192.0.2.99", "", http.StatusForbidden},
+		{"unknown run", "/execute/missing/cancel?adminPin=" + testAdminPin, "192.0.2.99", "", http.StatusNotFound},
+		{"tenant token of the starter", "/execute/alice-run/cancel", "192.0.2.99", "alice-token", http.StatusOK},
+		{"admin PIN", "/execute/anon-run/cancel?adminPin=" + testAdminPin, "192.0.2.99", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRuns(router, http.MethodPost, tt.path, tt.clientIP, tt.token)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(executorService.ListActiveRuns()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cancelled runs are still active")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelRunByAddress(t *testing.T) {
+	router, _ := newRunsRouter(t)
+
+	w := serveRuns(router, http.MethodPost, "/execute/anon-run/cancel", "192.0.2.20", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandPostStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is cat")
+	}
+	repo := newTestRepository(t, &entity.Command{ID: "cat", Name: "Cat", Command: "cat", Platform: runtime.GOOS})
+	handler := newTestExecuteHandler(t, &config.Config{}, repo)
+	handler.executorService.SetMaxStdinSize(16)
+	router := gin.New()
+	router.POST("/execute", handler.ExecuteCommandPost)
+
+	tests := []struct {
+		name       string
+		stdin      string
+		wantStatus int
+		wantOutput string
+	}{
+		{"stdin echoed", "piped input", http.StatusOK, "piped input"},
+		{"no stdin", "", http.StatusOK, ""},
+		{"stdin over the limit", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ExecuteRequest{ID: "cat", Stdin: tt.stdin})
+			req := httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(string(body)))
+			req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var resp ExecuteResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", resp.Output, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestParseTimeoutHeader(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1500", 1500 * time.Millisecond, false},
+		{"1", time.Millisecond, false},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"1.5", 0, true},
+		{"10s", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimeoutHeader(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeoutHeader(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseTimeoutHeader(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareExecutionTimeoutOverride(t *testing.T) {
+	repo := newTestRepository(t,
+		&entity.Command{ID: "default", Name: "Default", Command: "true", Platform: runtime.GOOS},
+		&entity.Command{ID: "timed", Name: "Timed", Command: "true", Platform: runtime.GOOS, Timeout: 5000},
+		&entity.Command{ID: "capped", Name: "Capped", Command: "true", Platform: runtime.GOOS, Timeout: 5000, MaxTimeout: 8000},
+	)
+	handler := newTestExecuteHandler(t, &config.Config{}, repo)
+
+	tests := []struct {
+		name       string
+		id         string
+		header     string
+		want       time.Duration
+		wantStatus int
+	}{
+		{"command default", "default", "", 10 * time.Second, 0},
+		{"command timeout", "timed", "", 5 * time.Second, 0},
+		{"header lowers it", "timed", "250", 250 * time.Millisecond, 0},
+		{"header raises it", "timed", "20000", 20 * time.Second, 0},
+		{"header clamped to the server max", "timed", "600000", time.Minute, 0},
+		{"header below the command max", "capped", "7000", 7 * time.Second, 0},
+		{"header clamped to the command max", "capped", "20000", 8 * time.Second, 0},
+		{"invalid header", "timed", "soon", 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/execute", nil)
+			if tt.header != "" {
+				c.Request.Header.Set(common.HeaderXTimeoutMs, tt.header)
+			}
+
+			prepared, failure := handler.prepareExecution(c, ExecuteRequest{ID: tt.id})
+			if tt.wantStatus != 0 {
+				if failure == nil || failure.status != tt.wantStatus {
+					t.Fatalf("prepareExecution() failure = %+v, want status %d", failure, tt.wantStatus)
+				}
+				return
+			}
+			if failure != nil {
+				t.Fatalf("prepareExecution() failed with %d: %s", failure.status, failure.response.Message)
+			}
+			if prepared.timeout != tt.want {
+				t.Fatalf("timeout = %v, want %v", prepared.timeout, tt.want)
+			}
+		})
+	}
+}
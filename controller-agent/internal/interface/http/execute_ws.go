@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+)
+
+// Stream frame types sent over the execution WebSocket
+const (
+	StreamFrameStdout = executor.StreamStdout
+	StreamFrameStderr = executor.StreamStderr
+	StreamFrameExit   = "exit"
+)
+
+// streamWriteTimeout bounds how long a slow client may block a frame write
+const streamWriteTimeout = 10 * time.Second
+
+// StreamFrame is one JSON text message sent over the execution WebSocket.
+// Output arrives as "stdout" and "stderr" frames while the command runs,
+// followed by a single "exit" frame carrying the ExecuteResponse fields,
+// after which the server closes the connection:
+//
+//	{"type":"stdout","data":"partial output\n"}
+//	{"type":"stderr","data":"warning\n"}
+//	{"type":"exit","runId":"...","success":true,"output":"partial output\nwarning\n","exitCode":0,"duration":12}
+//
+// If the command cannot be started the exit frame has success false,
+// exitCode -1 and the reason in error
+type StreamFrame struct {
+	Type             string `json:"type"`
+	Data             string `json:"data,omitempty"` // output chunk of stdout and stderr frames
+	*ExecuteResponse        // result of the exit frame
+}
+
+// @Summary Execute a command with live output
+// @Description Upgrade to a WebSocket, execute the command and stream its output as StreamFrame JSON messages. All execution checks run before the upgrade and fail with a normal HTTP error. Closing the connection kills the command
+// @Tags execution
+// @Param id query string true "Command ID"
+// @Param pin query string false "PIN for authentication (if required)"
+// @Param runId query string false "Run ID used to cancel the execution (generated when empty)"
+// @Param X-Timeout-Ms header int false "Override the command timeout in milliseconds (clamped to the server max)"
+// @Param X-Client-ID header string false "Client identifier checked against the command's allowed clients"
+// @Success 101 {object} StreamFrame
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /execute/ws [get]
+func (h *ExecuteHandler) ExecuteWebSocket(c *gin.Context) {
+	var req ExecuteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request parameters",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	prepared, failure := h.prepareExecution(c, req)
+	if failure == nil {
+		failure = h.admitExecution(prepared.cmd)
+	}
+	if failure != nil {
+		c.JSON(failure.status, failure.response)
+		return
+	}
+
+	// The upgrader replies with an HTTP error itself when the handshake fails
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), prepared.timeout)
+	defer cancel()
+
+	// Clients send nothing; a read error means they went away, which kills the command
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	var writeMutex sync.Mutex
+	send := func(frame StreamFrame) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := conn.WriteJSON(frame); err != nil {
+			cancel()
+		}
+	}
+
+	options := prepared.options
+	options.OnOutput = func(stream string, data []byte) {
+		send(StreamFrame{Type: stream, Data: string(data)})
+	}
+
+	startTime := time.Now()
+	result, err := h.executorService.ExecuteWithOptions(ctx, prepared.platformCommand, options)
+	duration := time.Since(startTime).Milliseconds()
+
+	// The HTTP status is already spent on the upgrade
+	_, response := executeResponse(prepared.cmd, result, err, duration)
+	send(StreamFrame{Type: StreamFrameExit, ExecuteResponse: response})
+
+	// Skip the close handshake when the client already went away
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// multipartOverhead is how far an upload body may exceed the file size limit
+// to leave room for the multipart boundaries and part headers
+const multipartOverhead = 64 * 1024
+
+// FileHandler handles HTTP requests for transferring files to and from the agent host
+type FileHandler struct {
+	files           *files.Service
+	securityService *security.Service
+	logger          *logrus.Logger
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(fileService *files.Service, securityService *security.Service, logger *logrus.Logger) *FileHandler {
+	return &FileHandler{
+		files:           fileService,
+		securityService: securityService,
+		logger:          logger,
+	}
+}
+
+// UploadFileResponse describes an uploaded file
+type UploadFileResponse struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// @Summary Upload file
+// @Description Write the multipart "file" part to path inside one of files.allowed_dirs; relative paths resolve against the first. Paths containing ".." or leaving the allowed directories are rejected. Requires the admin PIN
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param path query string true "Destination path"
+// @Param X-Pin header string true "Admin PIN"
+// @Param overwrite query bool false "Replace an existing file"
+// @Param file formData file true "File content"
+// @Success 200 {object} UploadFileResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /files/upload [post]
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	if !h.authorize(c) {
+		return
+	}
+	if !h.files.Enabled() {
+		h.fileError(c, common.ErrFilesDisabled)
+		return
+	}
+
+	overwrite, _ := strconv.ParseBool(c.Query("overwrite"))
+	if limit := h.files.MaxUploadSize(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+	}
+
+	// The part is streamed to disk rather than buffered by ParseMultipartForm
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request format",
+				Message: `multipart part "file" is required`,
+			})
+			return
+		}
+		if err != nil {
+			h.fileError(c, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		result, err := h.files.Upload(c.Query("path"), part, overwrite)
+		if err != nil {
+			h.fileError(c, err)
+			return
+		}
+		h.logger.WithFields(logrus.Fields{
+			"path":      result.Path,
+			"size":      result.Size,
+			"client_ip": c.ClientIP(),
+		}).Warn("File uploaded over HTTP")
+		c.JSON(http.StatusOK, UploadFileResponse{
+			Path:   result.Path,
+			Size:   result.Size,
+			SHA256: result.SHA256,
+		})
+		return
+	}
+}
+
+// @Summary Download file
+// @Description Serve a file from one of files.allowed_dirs; relative paths resolve against the first. Paths containing ".." or leaving the allowed directories are rejected. Supports range requests. Requires the admin PIN
+// @Tags files
+// @Produce octet-stream
+// @Param path query string true "File path"
+// @Param X-Pin header string true "Admin PIN"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /files/download [get]
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	if !h.authorize(c) {
+		return
+	}
+
+	file, info, err := h.files.Open(c.Query("path"))
+	if err != nil {
+		h.fileError(c, err)
+		return
+	}
+	defer file.Close()
+
+	h.logger.WithFields(logrus.Fields{
+		"path":      file.Name(),
+		"size":      info.Size(),
+		"client_ip": c.ClientIP(),
+	}).Info("File downloaded over HTTP")
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), file)
+}
+
+// authorize checks the admin PIN in the X-Pin header, which unlike the query
+// string stays out of the request log. A wrong PIN is answered with 401 and
+// counts towards the caller's failed PIN limit
+func (h *FileHandler) authorize(c *gin.Context) bool {
+	clientIP := c.ClientIP()
+	err := h.securityService.VerifyAdminPin(clientIP, c.GetHeader(common.HeaderXPin))
+	if err == nil {
+		return true
+	}
+	h.logger.WithField("client_ip", clientIP).Warn("File transfer rejected: invalid admin PIN")
+	c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+		Error:   "Authentication failed",
+		Message: "File transfers require a valid admin PIN",
+	}))
+	return false
+}
+
+// fileError responds with the status matching a file service error
+func (h *FileHandler) fileError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	status, title := http.StatusInternalServerError, "File transfer failed"
+	switch {
+	case errors.Is(err, common.ErrFilesDisabled):
+		status, title = http.StatusForbidden, "File transfers disabled"
+	case errors.Is(err, common.ErrInvalidFilePath):
+		status, title = http.StatusBadRequest, "Invalid path"
+	case errors.Is(err, common.ErrFileNotFound):
+		status, title = http.StatusNotFound, "File not found"
+	case errors.Is(err, common.ErrFileExists):
+		status, title = http.StatusConflict, "File exists"
+	case errors.Is(err, common.ErrFileTooLarge), errors.As(err, &maxBytesErr):
+		status, title = http.StatusRequestEntityTooLarge, "File too large"
+	}
+	if status == http.StatusInternalServerError {
+		h.logger.WithError(err).Error("File transfer failed")
+	}
+	c.JSON(status, ErrorResponse{
+		Error:   title,
+		Message: err.Error(),
+	})
+}
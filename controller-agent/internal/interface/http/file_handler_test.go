@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// newFileRouter serves the file routes over a temporary allowed directory
+// holding hello.txt
+func newFileRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	cfg.Files.AllowedDirs = []string{dir}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileService, err := files.NewService(cfg.Files, logger)
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	handler := NewFileHandler(fileService, security.NewService(cfg, logger), logger)
+
+	router := gin.New()
+	router.POST("/files/upload", handler.UploadFile)
+	router.GET("/files/download", handler.DownloadFile)
+	return router, dir
+}
+
+func downloadRequest(query, pin string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/files/download?path=hello.txt"+query, nil)
+	if pin != "" {
+		req.Header.Set(common.HeaderXPin, pin)
+	}
+	return req
+}
+
+func TestFileTransferPin(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		pin   string
+		want  int
+	}{
+		{"header PIN", "", testAdminPin, http.StatusOK},
+		{"no PIN", "", "", http.StatusUnauthorized},
+		{"wrong header PIN", "", "0000", http.StatusUnauthorized},
+		{"PIN in query", "&pin=" + testAdminPin, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newFileRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, downloadRequest(tt.query, tt.pin))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && w.Body.String() != "hello" {
+				t.Errorf("body = %q, want hello", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFileUploadPin(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		pin  string
+		want int
+	}{
+		{"header PIN", testAdminPin, http.StatusOK},
+		{"no PIN", "", http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			router, dir := newFileRouter(t)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", "upload.txt")
+			if err != nil {
+				t.Fatalf("form file: %v", err)
+			}
+			part.Write([]byte("uploaded"))
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/files/upload?path=upload.txt", &body)
+			req.Header.Set(common.HeaderContentType, writer.FormDataContentType())
+			if tt.pin != "" {
+				req.Header.Set(common.HeaderXPin, tt.pin)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+
+			_, err = os.Stat(filepath.Join(dir, "upload.txt"))
+			if written := err == nil; written != (tt.want == http.StatusOK) {
+				t.Errorf("written = %v, want %v", written, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestFileTransferPinFailureThrottling(t *testing.T) {
+	router, _ := newFileRouter(t)
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, downloadRequest("", "0000"))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i+1, w.Code)
+		}
+	}
+
+	// Once throttled even the right PIN is refused for the rest of the minute
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, downloadRequest("", testAdminPin))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// LogHandler handles HTTP requests for the agent's logging
+type LogHandler struct {
+	logger          *logrus.Logger
+	securityService *security.Service
+}
+
+// NewLogHandler creates a new log handler
+func NewLogHandler(logger *logrus.Logger, securityService *security.Service) *LogHandler {
+	return &LogHandler{
+		logger:          logger,
+		securityService: securityService,
+	}
+}
+
+// LogLevelRequest represents the request payload for changing the log level
+type LogLevelRequest struct {
+	Level string `json:"level" binding:"required"` // panic, fatal, error, warn, info, debug or trace
+	Pin   string `json:"pin"`
+}
+
+// LogLevelResponse reports the current log level and the Gin mode it implies
+type LogLevelResponse struct {
+	Level   string `json:"level"`
+	GinMode string `json:"ginMode"` // debug at the debug and trace levels, release otherwise
+}
+
+// @Summary Get log level
+// @Description Report the current log level. Requires the admin PIN
+// @Tags system
+// @Produce json
+// @Param X-Pin header string true "Admin PIN"
+// @Success 200 {object} LogLevelResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /admin/loglevel [get]
+func (h *LogHandler) GetLogLevel(c *gin.Context) {
+	if !h.authorize(c, c.GetHeader(common.HeaderXPin), "Reading the log level requires a valid admin PIN") {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.levelResponse())
+}
+
+// @Summary Set log level
+// @Description Change the log level until the agent restarts, switching Gin to debug mode at the debug and trace levels. Requires the admin PIN
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param request body LogLevelRequest true "Log level request"
+// @Success 200 {object} LogLevelResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /admin/loglevel [put]
+func (h *LogHandler) SetLogLevel(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !h.authorize(c, req.Pin, "Changing the log level requires a valid admin PIN") {
+		return
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid log level",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	previous := h.logger.GetLevel()
+	h.logger.SetLevel(level)
+	gin.SetMode(ginMode(level))
+
+	// Logged at warn so the change shows up at every level but error and above
+	h.logger.WithFields(logrus.Fields{
+		"from": previous.String(),
+		"to":   level.String(),
+	}).Warn("Log level changed")
+
+	c.JSON(http.StatusOK, h.levelResponse())
+}
+
+// authorize checks the admin PIN, responding with 401 and message when it is
+// wrong. Wrong PINs count towards the caller's failed PIN limit, past which
+// the caller gets 429
+func (h *LogHandler) authorize(c *gin.Context, pin, message string) bool {
+	if err := h.securityService.VerifyAdminPin(c.ClientIP(), pin); err != nil {
+		c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Authentication failed",
+			Message: message,
+		}))
+		return false
+	}
+	return true
+}
+
+// levelResponse reports the logger's current level
+func (h *LogHandler) levelResponse() LogLevelResponse {
+	return LogLevelResponse{
+		Level:   h.logger.GetLevel().String(),
+		GinMode: gin.Mode(),
+	}
+}
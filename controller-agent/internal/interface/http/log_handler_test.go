@@ -0,0 +1,135 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// newLogRouter serves the log level routes over a logger at the info level
+func newLogRouter(t *testing.T) (*gin.Engine, *logrus.Logger) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// Setting the level switches the global Gin mode
+	t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.InfoLevel)
+	handler := NewLogHandler(logger, security.NewService(cfg, logger))
+
+	router := gin.New()
+	router.GET("/admin/loglevel", handler.GetLogLevel)
+	router.PUT("/admin/loglevel", handler.SetLogLevel)
+	return router, logger
+}
+
+func getLogLevel(router *gin.Engine, query, pin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/admin/loglevel"+query, nil)
+	if pin != "" {
+		req.Header.Set(common.HeaderXPin, pin)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func setLogLevel(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(body))
+	req.Header.Set(common.HeaderContentType, "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetLogLevelPin(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		pin   string
+		want  int
+	}{
+		{"header PIN", "", testAdminPin, http.StatusOK},
+		{"no PIN", "", "", http.StatusUnauthorized},
+		{"wrong header PIN", "", "0000", http.StatusUnauthorized},
+		{"PIN in query", "?pin=" + testAdminPin, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newLogRouter(t)
+			w := getLogLevel(router, tt.query, tt.pin)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && !strings.Contains(w.Body.String(), `"level":"info"`) {
+				t.Errorf("body = %s, want level info", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantLevel logrus.Level
+	}{
+		{"admin PIN", `{"level":"debug","pin":"4321"}`, http.StatusOK, logrus.DebugLevel},
+		{"no PIN", `{"level":"debug"}`, http.StatusUnauthorized, logrus.InfoLevel},
+		{"wrong PIN", `{"level":"debug","pin":"0000"}`, http.StatusUnauthorized, logrus.InfoLevel},
+		{"unknown level", `{"level":"loud","pin":"4321"}`, http.StatusBadRequest, logrus.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, logger := newLogRouter(t)
+			w := setLogLevel(router, tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if level := logger.GetLevel(); level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLogLevelPinFailureThrottling(t *testing.T) {
+	router, logger := newLogRouter(t)
+
+	// Wrong PINs on either route count towards the same limit
+	for i := 0; i < 5; i++ {
+		var w *httptest.ResponseRecorder
+		if i%2 == 0 {
+			w = getLogLevel(router, "", "0000")
+		} else {
+			w = setLogLevel(router, `{"level":"debug","pin":"0000"}`)
+		}
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i+1, w.Code)
+		}
+	}
+
+	// Once throttled even the right PIN is refused for the rest of the minute
+	if w := getLogLevel(router, "", testAdminPin); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("get status = %d, want 429: %s", w.Code, w.Body.String())
+	}
+	if w := setLogLevel(router, `{"level":"debug","pin":"4321"}`); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("set status = %d, want 429: %s", w.Code, w.Body.String())
+	}
+	if level := logger.GetLevel(); level != logrus.InfoLevel {
+		t.Errorf("level = %v, want info", level)
+	}
+}
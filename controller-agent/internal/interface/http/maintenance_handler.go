@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// MaintenanceHandler handles HTTP requests for maintenance mode
+type MaintenanceHandler struct {
+	maintenance     *maintenance.Service
+	securityService *security.Service
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(maintenanceService *maintenance.Service, securityService *security.Service) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenance:     maintenanceService,
+		securityService: securityService,
+	}
+}
+
+// MaintenanceRequest represents the request payload for changing maintenance mode
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+	Pin     string `json:"pin"`
+}
+
+// @Summary Set maintenance mode
+// @Description Enable or disable maintenance mode. While enabled no command is executed. Requires the admin PIN
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param request body MaintenanceRequest true "Maintenance request"
+// @Success 200 {object} maintenance.Mode
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /maintenance [post]
+func (h *MaintenanceHandler) SetMaintenanceMode(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.securityService.VerifyAdminPin(c.ClientIP(), req.Pin); err != nil {
+		c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Authentication failed",
+			Message: "Maintenance mode requires a valid admin PIN",
+		}))
+		return
+	}
+
+	mode, err := h.maintenance.SetMaintenanceMode(req.Enabled, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to set maintenance mode",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, mode)
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/metrics"
+)
+
+// MetricsHandler handles HTTP requests for the resource usage history
+type MetricsHandler struct {
+	metricsService *metrics.Service
+}
+
+// NewMetricsHandler creates a new metrics handler; metricsService is nil when metrics history is disabled
+func NewMetricsHandler(metricsService *metrics.Service) *MetricsHandler {
+	return &MetricsHandler{
+		metricsService: metricsService,
+	}
+}
+
+// MetricsHistoryResponse represents the retained resource usage samples
+type MetricsHistoryResponse struct {
+	Interval  int64            `json:"interval"`  // seconds between samples
+	Retention int64            `json:"retention"` // seconds of samples kept
+	Samples   []metrics.Sample `json:"samples"`
+}
+
+// @Summary Get resource usage history
+// @Description List periodic CPU, memory and goroutine samples of the agent, oldest first, for sparkline charts
+// @Tags system
+// @Produce json
+// @Param window query string false "Only samples within this Go duration, e.g. 15m (defaults to everything retained)"
+// @Success 200 {object} MetricsHistoryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /metrics/history [get]
+func (h *MetricsHandler) GetHistory(c *gin.Context) {
+	if h.metricsService == nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Metrics history disabled",
+			Message: "Enable metrics in the agent configuration",
+		})
+		return
+	}
+
+	var window time.Duration
+	if value := c.Query("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request parameters",
+				Message: fmt.Sprintf("window must be a positive duration such as 15m, got %q", value),
+			})
+			return
+		}
+		window = parsed
+	}
+
+	c.JSON(http.StatusOK, MetricsHistoryResponse{
+		Interval:  int64(h.metricsService.Interval().Seconds()),
+		Retention: int64(h.metricsService.Retention().Seconds()),
+		Samples:   h.metricsService.History(window),
+	})
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/metrics"
+)
+
+func TestGetMetricsHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sampled := metrics.NewService(10*time.Second, time.Minute)
+	sampled.Sample()
+	sampled.Sample()
+
+	tests := []struct {
+		name        string
+		service     *metrics.Service
+		query       string
+		wantStatus  int
+		wantSamples int
+	}{
+		{"disabled", nil, "", http.StatusServiceUnavailable, 0},
+		{"no samples yet", metrics.NewService(10*time.Second, time.Minute), "", http.StatusOK, 0},
+		{"everything", sampled, "", http.StatusOK, 2},
+		{"window", sampled, "?window=15m", http.StatusOK, 2},
+		{"invalid window", sampled, "?window=soon", http.StatusBadRequest, 0},
+		{"negative window", sampled, "?window=-1m", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/metrics/history", NewMetricsHandler(tt.service).GetHistory)
+
+			var resp MetricsHistoryResponse
+			if status := getJSON(t, router, "/metrics/history"+tt.query, &resp); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if resp.Interval != 10 || resp.Retention != 60 || len(resp.Samples) != tt.wantSamples {
+				t.Errorf("interval %d, retention %d, %d samples; want 10, 60, %d", resp.Interval, resp.Retention, len(resp.Samples), tt.wantSamples)
+			}
+		})
+	}
+
+	// Sparkline clients expect an array even before the first sample
+	router := gin.New()
+	router.GET("/metrics/history", NewMetricsHandler(metrics.NewService(time.Second, time.Minute)).GetHistory)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/history", nil))
+	if !strings.Contains(w.Body.String(), `"samples":[]`) {
+		t.Errorf("body = %s, want an empty samples array", w.Body.String())
+	}
+}
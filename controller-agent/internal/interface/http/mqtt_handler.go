@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/mqtt"
+)
+
+// MQTTHandler handles HTTP requests about the MQTT interface
+type MQTTHandler struct {
+	config *config.Config
+}
+
+// NewMQTTHandler creates a new MQTT handler
+func NewMQTTHandler(cfg *config.Config) *MQTTHandler {
+	return &MQTTHandler{
+		config: cfg,
+	}
+}
+
+// MQTTTopicsResponse represents the effective MQTT topic layout
+type MQTTTopicsResponse struct {
+	Enabled   bool     `json:"enabled"`
+	TopicBase string   `json:"topicBase"`
+	Subscribe []string `json:"subscribe"`
+	Publish   []string `json:"publish"` // "+" marks per-request and per-command levels
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// @Summary List MQTT topics
+// @Description List the topics the agent subscribes and publishes to, derived from the MQTT configuration, with warnings for likely misconfiguration
+// @Tags system
+// @Produce json
+// @Success 200 {object} MQTTTopicsResponse
+// @Router /mqtt/topics [get]
+func (h *MQTTHandler) GetTopics(c *gin.Context) {
+	layout := mqtt.Topics(h.config.MQTT)
+	
+	c.JSON(http.StatusOK, MQTTTopicsResponse{
+		Enabled:   h.config.MQTT.Enabled,
+		TopicBase: h.config.MQTT.TopicBase,
+		Subscribe: layout.Subscribe,
+		Publish:   layout.Publish,
+		Warnings:  layout.Warnings,
+	})
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestGetMQTTTopics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		mqtt          config.MQTTConfig
+		wantSubscribe []string
+		wantWarnings  int
+	}{
+		{"valid", config.MQTTConfig{Enabled: true, TopicBase: "lazy-ctrl/pc"}, []string{"lazy-ctrl/pc/execute", "lazy-ctrl/pc/commands"}, 0},
+		{"trailing slash", config.MQTTConfig{Enabled: true, TopicBase: "lazy-ctrl/pc/"}, []string{"lazy-ctrl/pc//execute", "lazy-ctrl/pc//commands"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/mqtt/topics", NewMQTTHandler(&config.Config{MQTT: tt.mqtt}).GetTopics)
+
+			var resp MQTTTopicsResponse
+			if code := getJSON(t, router, "/mqtt/topics", &resp); code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			if !resp.Enabled || resp.TopicBase != tt.mqtt.TopicBase {
+				t.Errorf("enabled = %v, topicBase = %q, want true, %q", resp.Enabled, resp.TopicBase, tt.mqtt.TopicBase)
+			}
+			if !reflect.DeepEqual(resp.Subscribe, tt.wantSubscribe) {
+				t.Errorf("subscribe = %q, want %q", resp.Subscribe, tt.wantSubscribe)
+			}
+			if len(resp.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %d", resp.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
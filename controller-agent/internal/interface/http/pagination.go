@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPage is the highest page served; later pages are clamped to it, which
+// keeps (page-1)*pageSize from overflowing into a negative offset
+const maxPage = 1000000
+
+// parsePagination reads the page (from 1) and pageSize query parameters,
+// clamping page to maxPage and pageSize to maxSize
+func parsePagination(c *gin.Context, defaultSize, maxSize int) (int, int, error) {
+	page := 1
+	if value := c.Query("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("page must be a positive integer, got %q", value)
+		}
+		page = parsed
+	}
+	if page > maxPage {
+		page = maxPage
+	}
+	
+	pageSize := defaultSize
+	if value := c.Query("pageSize"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("pageSize must be a positive integer, got %q", value)
+		}
+		pageSize = parsed
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+	
+	return page, pageSize, nil
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestSearchCommandsPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	commandService := service.NewCommandService(infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json")))
+	handler := NewCommandHandler(commandService, security.NewService(&config.Config{}, logger), true)
+	router := gin.New()
+	router.GET("/commands/search", handler.SearchCommands)
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantPage     int
+		wantPageSize int
+	}{
+		{"defaults", "", http.StatusOK, 1, defaultSearchPageSize},
+		{"page size clamped", "?pageSize=100000", http.StatusOK, 1, maxSearchPageSize},
+		{"overflowing page clamped", "?page=9223372036854775807&pageSize=100", http.StatusOK, maxPage, 100},
+		{"page past int range", "?page=99999999999999999999", http.StatusBadRequest, 0, 0},
+		{"zero page", "?page=0", http.StatusBadRequest, 0, 0},
+		{"negative page size", "?pageSize=-1", http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commands/search"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp CommandSearchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Page != tt.wantPage || resp.PageSize != tt.wantPageSize {
+				t.Errorf("page = %d, pageSize = %d, want %d, %d", resp.Page, resp.PageSize, tt.wantPage, tt.wantPageSize)
+			}
+		})
+	}
+}
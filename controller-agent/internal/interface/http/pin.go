@@ -0,0 +1,20 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+// pinError returns the response to a failed PIN check: 429 once the caller
+// sent too many wrong PINs, status with response otherwise
+func pinError(err error, status int, response ErrorResponse) (int, ErrorResponse) {
+	if errors.Is(err, common.ErrTooManyPinFailures) {
+		return http.StatusTooManyRequests, ErrorResponse{
+			Error:   "Rate limit exceeded",
+			Message: err.Error(),
+		}
+	}
+	return status, response
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/scheduler"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// PowerHandler handles HTTP requests for scheduled power operations
+type PowerHandler struct {
+	executorService  *executor.Service
+	securityService  *security.Service
+	schedulerService *scheduler.Service
+	logger           *logrus.Logger
+}
+
+// NewPowerHandler creates a new power handler
+func NewPowerHandler(
+	executorService *executor.Service,
+	securityService *security.Service,
+	schedulerService *scheduler.Service,
+	logger *logrus.Logger,
+) *PowerHandler {
+	return &PowerHandler{
+		executorService:  executorService,
+		securityService:  securityService,
+		schedulerService: schedulerService,
+		logger:           logger,
+	}
+}
+
+// PowerScheduleRequest represents the request payload for scheduling a power action
+type PowerScheduleRequest struct {
+	Action       string `json:"action" binding:"required"` // shutdown or reboot
+	DelaySeconds int    `json:"delaySeconds"`
+	Pin          string `json:"pin"`
+	Confirm      bool   `json:"confirm"`
+}
+
+// PowerCancelRequest represents the request payload for cancelling a power action
+type PowerCancelRequest struct {
+	Pin string `json:"pin"`
+}
+
+// @Summary Schedule a power action
+// @Description Schedule a shutdown or reboot after a delay. Requires the admin PIN and confirm=true
+// @Tags power
+// @Accept json
+// @Produce json
+// @Param request body PowerScheduleRequest true "Power schedule request"
+// @Success 200 {object} scheduler.Task
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /power/schedule [post]
+func (h *PowerHandler) SchedulePower(c *gin.Context) {
+	var req PowerScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.securityService.VerifyAdminPin(c.ClientIP(), req.Pin); err != nil {
+		c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Authentication failed",
+			Message: "Power actions require a valid admin PIN",
+		}))
+		return
+	}
+
+	if !req.Confirm {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Confirmation required",
+			Message: "Set confirm to true to schedule a power action",
+		})
+		return
+	}
+
+	delay := time.Duration(req.DelaySeconds) * time.Second
+	if delay < 0 || delay > common.MaxPowerDelay {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid delay",
+			Message: fmt.Sprintf("delaySeconds must be between 0 and %d", int(common.MaxPowerDelay.Seconds())),
+		})
+		return
+	}
+
+	command, err := executor.PowerCommand(req.Action)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid power action",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Only one power action can be pending at a time
+	task, err := h.schedulerService.Schedule(powerTaskID, req.Action, command, delay, func() {
+		if _, err := h.executorService.ExecuteWithTimeout(command, common.DefaultCommandTimeout); err != nil {
+			h.logger.WithError(err).Error("Scheduled power action failed")
+		}
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Power action already scheduled",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// @Summary Get scheduled power action
+// @Description Get the currently pending power action, if any
+// @Tags power
+// @Produce json
+// @Success 200 {object} scheduler.Task
+// @Failure 404 {object} ErrorResponse
+// @Router /power/schedule [get]
+func (h *PowerHandler) GetScheduledPower(c *gin.Context) {
+	task, exists := h.schedulerService.Get(powerTaskID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Not found",
+			Message: "No power action scheduled",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// @Summary Cancel scheduled power action
+// @Description Cancel the pending shutdown or reboot. Requires the admin PIN
+// @Tags power
+// @Accept json
+// @Produce json
+// @Param request body PowerCancelRequest true "Power cancel request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /power/cancel [post]
+func (h *PowerHandler) CancelPower(c *gin.Context) {
+	var req PowerCancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.securityService.VerifyAdminPin(c.ClientIP(), req.Pin); err != nil {
+		c.JSON(pinError(err, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Authentication failed",
+			Message: "Power actions require a valid admin PIN",
+		}))
+		return
+	}
+
+	if err := h.schedulerService.Cancel(powerTaskID); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Power action cancelled",
+	})
+}
+
+// powerTaskID is the scheduler task ID shared by all power actions
+const powerTaskID = "power"
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/scheduler"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// newPowerRouter serves the power routes. Nothing scheduled by a test gets to
+// run: the executor is nil and every task is cancelled at cleanup
+func newPowerRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	schedulerService := scheduler.NewService(logger)
+	t.Cleanup(schedulerService.CancelAll)
+	handler := NewPowerHandler(nil, security.NewService(cfg, logger), schedulerService, logger)
+
+	router := gin.New()
+	router.POST("/power/schedule", handler.SchedulePower)
+	router.GET("/power/schedule", handler.GetScheduledPower)
+	router.POST("/power/cancel", handler.CancelPower)
+	return router
+}
+
+func TestPowerSchedule(t *testing.T) {
+	router := newPowerRouter(t)
+
+	// Steps run in order against one scheduler
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"nothing scheduled", http.MethodGet, "/power/schedule", "", http.StatusNotFound},
+		{"wrong PIN", http.MethodPost, "/power/schedule", `{"action":"reboot","delaySeconds":3600,"pin":"0000","confirm":true}`, http.StatusUnauthorized},
+		{"without confirm", http.MethodPost, "/power/schedule", `{"action":"reboot","delaySeconds":3600,"pin":"4321"}`, http.StatusBadRequest},
+		{"negative delay", http.MethodPost, "/power/schedule", `{"action":"reboot","delaySeconds":-1,"pin":"4321","confirm":true}`, http.StatusBadRequest},
+		{"delay over a day", http.MethodPost, "/power/schedule", `{"action":"reboot","delaySeconds":86401,"pin":"4321","confirm":true}`, http.StatusBadRequest},
+		{"unknown action", http.MethodPost, "/power/schedule", `{"action":"hibernate","delaySeconds":3600,"pin":"4321","confirm":true}`, http.StatusBadRequest},
+		{"missing action", http.MethodPost, "/power/schedule", `{"delaySeconds":3600,"pin":"4321","confirm":true}`, http.StatusBadRequest},
+		{"scheduled", http.MethodPost, "/power/schedule", `{"action":"reboot","delaySeconds":3600,"pin":"4321","confirm":true}`, http.StatusOK},
+		{"reported", http.MethodGet, "/power/schedule", "", http.StatusOK},
+		{"only one at a time", http.MethodPost, "/power/schedule", `{"action":"shutdown","delaySeconds":3600,"pin":"4321","confirm":true}`, http.StatusConflict},
+		{"cancel with wrong PIN", http.MethodPost, "/power/cancel", `{"pin":"0000"}`, http.StatusUnauthorized},
+		{"cancelled", http.MethodPost, "/power/cancel", `{"pin":"4321"}`, http.StatusOK},
+		{"gone after cancel", http.MethodGet, "/power/schedule", "", http.StatusNotFound},
+		{"nothing to cancel", http.MethodPost, "/power/cancel", `{"pin":"4321"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
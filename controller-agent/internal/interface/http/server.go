@@ -3,7 +3,11 @@ package http
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,20 +19,42 @@ import (
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/files"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/metrics"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/scheduler"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/utils"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	config          *config.Config
-	logger          *logrus.Logger
-	commandService  *service.CommandService
-	executorService *executor.Service
-	securityService *security.Service
-	engine          *gin.Engine
-	server          *http.Server
+	config           *config.Config
+	logger           *logrus.Logger
+	commandService   *service.CommandService
+	executorService  *executor.Service
+	securityService  *security.Service
+	maintenance      *maintenance.Service
+	schedulerService *scheduler.Service
+	auditService     *audit.Service
+	metricsService   *metrics.Service
+	fileService      *files.Service
+	engine           *gin.Engine
+
+	mu      sync.Mutex   // guards server and stopped
+	server  *http.Server // nil until Start
+	stopped bool
+
+	status     atomic.Value // common.Status* of the listener
+	subsystems map[string]Subsystem
+}
+
+// Subsystem is a running transport whose status the health endpoint reports
+type Subsystem interface {
+	// Status returns one of the common.Status* values
+	Status() string
 }
 
 // NewServer creates a new HTTP server instance
@@ -38,61 +64,136 @@ func NewServer(
 	commandService *service.CommandService,
 	executorService *executor.Service,
 	securityService *security.Service,
+	maintenanceService *maintenance.Service,
+	schedulerService *scheduler.Service,
+	auditService *audit.Service,
+	metricsService *metrics.Service,
+	fileService *files.Service,
 ) *Server {
 	return &Server{
-		config:          cfg,
-		logger:          logger,
-		commandService:  commandService,
-		executorService: executorService,
-		securityService: securityService,
+		config:           cfg,
+		logger:           logger,
+		commandService:   commandService,
+		executorService:  executorService,
+		securityService:  securityService,
+		maintenance:      maintenanceService,
+		schedulerService: schedulerService,
+		auditService:     auditService,
+		metricsService:   metricsService,
+		fileService:      fileService,
 	}
 }
 
-// Start starts the HTTP server
+// Start builds the engine and routes, binds the configured address and serves
+// until Stop is called. It returns nil without serving when Stop came first
 func (s *Server) Start() error {
-	s.setupEngine()
-	s.setupRoutes()
-	s.setupServer()
+	listener, server, err := s.listen()
+	if err != nil {
+		s.status.Store(common.StatusUnhealthy)
+		return err
+	}
+	if server == nil {
+		return nil
+	}
+	s.status.Store(common.StatusHealthy)
 
-	s.logger.WithFields(logrus.Fields{
-		"port": s.config.Server.HTTP.Port,
-		"host": s.config.Server.HTTP.Host,
-	}).Info("Starting HTTP server")
+	s.logger.WithField("addr", listener.Addr().String()).Info("Starting HTTP server")
 
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("HTTP server failed to start: %w", err)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		s.status.Store(common.StatusUnhealthy)
+		return fmt.Errorf("HTTP server failed: %w", err)
 	}
 
 	return nil
 }
 
-// Stop stops the HTTP server gracefully
+// Status reports whether the server is listening
+func (s *Server) Status() string {
+	if status, ok := s.status.Load().(string); ok {
+		return status
+	}
+	return common.StatusStarting
+}
+
+// SetSubsystems sets the transports reported by the health endpoint, keyed by name
+func (s *Server) SetSubsystems(subsystems map[string]Subsystem) {
+	s.subsystems = subsystems
+}
+
+// Stop stops the HTTP server gracefully, giving in-flight requests up to the
+// configured shutdown timeout. It is safe to call before or without Start
 func (s *Server) Stop() {
 	s.logger.Info("Stopping HTTP server")
+	s.status.Store(common.StatusStopping)
+
+	s.mu.Lock()
+	s.stopped = true
+	server := s.server
+	s.mu.Unlock()
+
+	if server == nil {
+		s.logger.Info("HTTP server was not started")
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), common.DefaultShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(ctx); err != nil {
 		s.logger.WithError(err).Error("Failed to shutdown HTTP server gracefully")
 	} else {
 		s.logger.Info("HTTP server stopped gracefully")
 	}
 }
 
+// listen sets up the engine, routes and http.Server and binds the configured
+// address. The server is nil when Stop was already called
+func (s *Server) listen() (net.Listener, *http.Server, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, nil, nil
+	}
+
+	// Register middleware and routes before anything is served
+	s.setupEngine()
+	s.setupRoutes()
+	server := s.newHTTPServer()
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("HTTP server failed to start: %w", err)
+	}
+
+	// Record the bound address, which differs from the configured one for port 0
+	server.Addr = listener.Addr().String()
+	s.server = server
+	return listener, server, nil
+}
+
+// ginMode returns the Gin mode matching a log level: debug when debug
+// messages are logged, release otherwise
+func ginMode(level logrus.Level) string {
+	if level >= logrus.DebugLevel {
+		return gin.DebugMode
+	}
+	return gin.ReleaseMode
+}
+
 // setupEngine configures the Gin engine
 func (s *Server) setupEngine() {
 	// Set Gin mode based on log level
-	if s.logger.Level == logrus.DebugLevel {
-		gin.SetMode(gin.DebugMode)
-	} else {
-		gin.SetMode(gin.ReleaseMode)
-	}
+	gin.SetMode(ginMode(s.logger.GetLevel()))
 
 	s.engine = gin.New()
 
 	// Add middleware
 	s.engine.Use(s.requestIDMiddleware())
+	// Compression wraps the formatter, so it compresses the rewritten body
+	if s.config.Server.HTTP.Compression.Enabled {
+		s.engine.Use(s.compressionMiddleware())
+	}
 	s.engine.Use(utils.ResponseFormatterMiddleware())
 	s.engine.Use(s.loggingMiddleware())
 	s.engine.Use(s.corsMiddleware())
@@ -102,9 +203,17 @@ func (s *Server) setupEngine() {
 // setupRoutes configures the HTTP routes
 func (s *Server) setupRoutes() {
 	// Create handlers
-	commandHandler := NewCommandHandler(s.commandService)
-	executeHandler := NewExecuteHandler(s.commandService, s.executorService, s.securityService)
-	systemHandler := NewSystemHandler(s.commandService, s.securityService)
+	commandHandler := NewCommandHandler(s.commandService, s.securityService, s.config.Commands.Tenancy)
+	executeHandler := NewExecuteHandler(s.commandService, s.executorService, s.securityService, s.maintenance, s.maxTimeout(), s.config.Commands.Batch,
+		s.config.Security.AllowAdhoc, newCORSOrigins(s.config.Server.HTTP.AllowedOrigins), s.logger)
+	systemHandler := NewSystemHandler(s.commandService, s.securityService, s.maintenance, s.subsystems)
+	powerHandler := NewPowerHandler(s.executorService, s.securityService, s.schedulerService, s.logger)
+	auditHandler := NewAuditHandler(s.auditService)
+	metricsHandler := NewMetricsHandler(s.metricsService)
+	mqttHandler := NewMQTTHandler(s.config)
+	maintenanceHandler := NewMaintenanceHandler(s.maintenance, s.securityService)
+	logHandler := NewLogHandler(s.logger, s.securityService)
+	fileHandler := NewFileHandler(s.fileService, s.securityService, s.logger)
 
 	// API v1 routes
 	v1 := s.engine.Group("/api/v1")
@@ -113,7 +222,17 @@ func (s *Server) setupRoutes() {
 		v1.GET("/health", systemHandler.HealthCheck)
 		v1.GET("/version", systemHandler.GetVersion)
 		v1.GET("/status", systemHandler.GetStatus)
+		v1.GET("/platform", systemHandler.GetPlatform)
 		v1.POST("/reload", systemHandler.ReloadCommands)
+		v1.GET("/mqtt/topics", mqttHandler.GetTopics)
+		v1.POST("/maintenance", maintenanceHandler.SetMaintenanceMode)
+
+		// Admin routes
+		admin := v1.Group("/admin")
+		{
+			admin.GET("/loglevel", logHandler.GetLogLevel)
+			admin.PUT("/loglevel", logHandler.SetLogLevel)
+		}
 
 		// Authentication routes
 		auth := v1.Group("/auth")
@@ -127,7 +246,13 @@ func (s *Server) setupRoutes() {
 			commands.POST("", commandHandler.CreateCommand)
 			commands.GET("", commandHandler.GetAllCommands)
 			commands.GET("/homepage", commandHandler.GetHomepageCommands)
+			commands.GET("/categories", commandHandler.GetCategories)
+			commands.GET("/search", commandHandler.SearchCommands)
+			commands.GET("/export", commandHandler.ExportCommands)
+			commands.POST("/import", commandHandler.ImportCommands)
 			commands.GET("/:id", commandHandler.GetCommand)
+			commands.GET("/:id/security", executeHandler.GetCommandSecurity)
+			commands.GET("/:id/history", auditHandler.GetCommandHistory)
 			commands.PUT("/:id", commandHandler.UpdateCommand)
 			commands.DELETE("/:id", commandHandler.DeleteCommand)
 		}
@@ -135,7 +260,33 @@ func (s *Server) setupRoutes() {
 		// Execution routes
 		v1.GET("/execute", executeHandler.ExecuteCommand)
 		v1.POST("/execute", executeHandler.ExecuteCommandPost)
+		v1.POST("/execute/batch", executeHandler.ExecuteBatch)
+		v1.POST("/execute/adhoc", executeHandler.ExecuteAdhoc)
+		v1.GET("/execute/ws", executeHandler.ExecuteWebSocket)
 		v1.GET("/execute/info", executeHandler.GetCommandInfo)
+		v1.GET("/execute/active", executeHandler.ListActiveRuns)
+		v1.POST("/execute/:run_id/cancel", executeHandler.CancelRun)
+
+		// File transfer routes
+		fileRoutes := v1.Group("/files")
+		{
+			fileRoutes.POST("/upload", fileHandler.UploadFile)
+			fileRoutes.GET("/download", fileHandler.DownloadFile)
+		}
+
+		// Audit routes
+		v1.GET("/audit", auditHandler.GetAuditLog)
+
+		// Metrics routes
+		v1.GET("/metrics/history", metricsHandler.GetHistory)
+
+		// Power routes
+		power := v1.Group("/power")
+		{
+			power.POST("/schedule", powerHandler.SchedulePower)
+			power.GET("/schedule", powerHandler.GetScheduledPower)
+			power.POST("/cancel", powerHandler.CancelPower)
+		}
 	}
 
 	// Swagger documentation routes
@@ -158,6 +309,9 @@ func (s *Server) setupRoutes() {
 		c.Redirect(http.StatusFound, "/swagger/index.html")
 	})
 
+	// Unversioned health check for load balancers and container probes
+	s.engine.GET("/health", systemHandler.HealthCheck)
+
 	// Default route for API documentation or health check
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -172,18 +326,37 @@ func (s *Server) setupRoutes() {
 	})
 }
 
-// setupServer configures the HTTP server
-func (s *Server) setupServer() {
-	s.server = &http.Server{
+// newHTTPServer configures the HTTP server for the engine
+func (s *Server) newHTTPServer() *http.Server {
+	// Leave room for the longest allowed command execution
+	writeTimeout := common.DefaultHTTPTimeout
+	if maxTimeout := s.maxTimeout() + 5*time.Second; maxTimeout > writeTimeout {
+		writeTimeout = maxTimeout
+	}
+
+	return &http.Server{
 		Addr:           fmt.Sprintf("%s:%d", s.config.Server.HTTP.Host, s.config.Server.HTTP.Port),
 		Handler:        s.engine,
 		ReadTimeout:    common.DefaultHTTPTimeout,
-		WriteTimeout:   common.DefaultHTTPTimeout,
+		WriteTimeout:   writeTimeout,
 		IdleTimeout:    60 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 }
 
+// shutdownTimeout returns how long Stop waits for in-flight requests
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.config.Server.HTTP.ShutdownTimeout <= 0 {
+		return common.DefaultShutdownTimeout
+	}
+	return time.Duration(s.config.Server.HTTP.ShutdownTimeout) * time.Second
+}
+
+// maxTimeout returns the configured upper bound for command execution
+func (s *Server) maxTimeout() time.Duration {
+	return s.config.Commands.MaxExecutionTimeout()
+}
+
 // Middleware implementations
 
 // requestIDMiddleware adds request ID to each request
@@ -216,23 +389,72 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 
 // corsMiddleware handles CORS headers
 func (s *Server) corsMiddleware() gin.HandlerFunc {
+	origins := newCORSOrigins(s.config.Server.HTTP.AllowedOrigins)
+
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
-
-		// Allow all origins for development
-		// In production, this should be configured more restrictively
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Pin, X-Request-ID")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, X-Request-ID")
-		c.Header("Access-Control-Allow-Credentials", "true")
+		allowed := origins.allows(origin)
+
+		// Responses differ by origin, so caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		// Disallowed origins get no CORS headers, so browsers keep the response from them
+		if allowed {
+			if origins.any {
+				// Browsers reject credentials with a wildcard origin
+				c.Header("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Pin, X-Request-ID, X-Timeout-Ms, X-Client-ID, X-Tenant-Token")
+			c.Header("Access-Control-Expose-Headers", "Content-Length, X-Request-ID")
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
-		s.logger.WithField("origin", origin).Debug("CORS request processed")
+		if origin != "" {
+			s.logger.WithFields(logrus.Fields{
+				"origin":  origin,
+				"allowed": allowed,
+			}).Debug("CORS request processed")
+		}
+		c.Next()
+	}
+}
+
+// compressionMiddleware gzips responses of at least the configured size for
+// clients that accept it. WebSocket upgrades and excluded paths pass through
+func (s *Server) compressionMiddleware() gin.HandlerFunc {
+	cfg := s.config.Server.HTTP.Compression
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, prefix := range cfg.ExcludedPaths {
+			if prefix != "" && strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+
+		// Responses differ by accepted encoding, so caches must key on it
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipResponseWriter{ResponseWriter: c.Writer, minSize: cfg.MinSize}
+		c.Writer = w
+		defer w.finish()
 		c.Next()
 	}
 }
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// newTestServer returns a server for port on localhost, 0 for any free port,
+// with a "slow" command that runs for half a second
+func newTestServer(t *testing.T, port int) (*Server, *executor.Service) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+
+	cfg := &config.Config{}
+	cfg.Server.HTTP.Host = "127.0.0.1"
+	cfg.Server.HTTP.Port = port
+	cfg.Server.HTTP.ShutdownTimeout = 5
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	if err := repo.Create(context.Background(), &entity.Command{ID: "slow", Name: "Slow", Command: "sleep 0.5", Platform: runtime.GOOS}); err != nil {
+		t.Fatalf("create command: %v", err)
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("create maintenance service: %v", err)
+	}
+	executorService := executor.NewService(logger)
+	server := NewServer(cfg, logger, service.NewCommandService(repo), executorService, security.NewService(cfg, logger),
+		maintenanceService, nil, nil, nil, nil)
+	return server, executorService
+}
+
+// startTestServer runs s.Start in the background and returns its address once
+// it is listening, and the channel Start's result arrives on
+func startTestServer(t *testing.T, s *Server) (string, <-chan error) {
+	t.Helper()
+	started := make(chan error, 1)
+	go func() { started <- s.Start() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status() != common.StatusHealthy {
+		select {
+		case err := <-started:
+			t.Fatalf("Start() returned %v before serving", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	addr := s.server.Addr
+	s.mu.Unlock()
+	return addr, started
+}
+
+// waitStart waits for the result of a Start call
+func waitStart(t *testing.T, started <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-started:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Start() did not return")
+		return nil
+	}
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	s, executorService := newTestServer(t, 0)
+	addr, started := startTestServer(t, s)
+
+	resp, err := http.Get("http://" + addr + "/health")
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("health status = %d", resp.StatusCode)
+	}
+
+	// Start a request that is still running when Stop is called
+	type outcome struct {
+		status int
+		body   []byte
+		err    error
+	}
+	inFlight := make(chan outcome, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/api/v1/execute?id=slow")
+		if err != nil {
+			inFlight <- outcome{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- outcome{status: resp.StatusCode, body: body, err: err}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(executorService.ListActiveRuns()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("command did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatalf("Stop() returned before the request in flight finished")
+	case got := <-inFlight:
+		if got.err != nil {
+			t.Fatalf("request in flight failed: %v", got.err)
+		}
+		if got.status != http.StatusOK {
+			t.Fatalf("request in flight status = %d: %s", got.status, got.body)
+		}
+		var body struct {
+			Success bool `json:"success"`
+		}
+		if err := json.Unmarshal(got.body, &body); err != nil || !body.Success {
+			t.Fatalf("request in flight response = %s", got.body)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("request in flight did not finish")
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Stop() did not return")
+	}
+	if err := waitStart(t, started); err != nil {
+		t.Fatalf("Start() = %v after a graceful stop", err)
+	}
+	if got := s.Status(); got != common.StatusStopping {
+		t.Fatalf("Status() = %q after Stop, want %q", got, common.StatusStopping)
+	}
+	if _, err := http.Get("http://" + addr + "/health"); err == nil {
+		t.Fatalf("server still accepts requests after Stop")
+	}
+}
+
+func TestServerLifecycleEdges(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name       string
+		port       int
+		stopFirst  bool
+		wantErr    bool
+		wantStatus string
+	}{
+		{"stop before start skips serving", 0, true, false, common.StatusStopping},
+		{"address in use", busyPort, false, true, common.StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, tt.port)
+			if tt.stopFirst {
+				s.Stop()
+			}
+
+			started := make(chan error, 1)
+			go func() { started <- s.Start() }()
+			err := waitStart(t, started)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := s.Status(); got != tt.wantStatus {
+				t.Fatalf("Status() = %q, want %q", got, tt.wantStatus)
+			}
+			// Stop is safe whatever Start did
+			s.Stop()
+		})
+	}
+}
+
+func TestServerStopWithoutStart(t *testing.T) {
+	s, _ := newTestServer(t, 0)
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stop() without Start blocked")
+	}
+	if got := s.Status(); got != common.StatusStopping {
+		t.Fatalf("Status() = %q, want %q", got, common.StatusStopping)
+	}
+}
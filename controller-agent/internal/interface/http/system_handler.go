@@ -2,12 +2,16 @@ package http
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"runtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
 )
 
@@ -15,16 +19,22 @@ import (
 type SystemHandler struct {
 	commandService  *service.CommandService
 	securityService *security.Service
+	maintenance     *maintenance.Service
+	subsystems      map[string]Subsystem
 }
 
 // NewSystemHandler creates a new system handler
 func NewSystemHandler(
 	commandService *service.CommandService,
 	securityService *security.Service,
+	maintenanceService *maintenance.Service,
+	subsystems map[string]Subsystem,
 ) *SystemHandler {
 	return &SystemHandler{
 		commandService:  commandService,
 		securityService: securityService,
+		maintenance:     maintenanceService,
+		subsystems:      subsystems,
 	}
 }
 
@@ -63,6 +73,15 @@ type ReloadResponse struct {
 	Message string `json:"message"`
 }
 
+// PlatformResponse represents the agent's platform and shell capabilities
+type PlatformResponse struct {
+	OS             string               `json:"os"`
+	Architecture   string               `json:"architecture"`
+	DefaultShell   string               `json:"defaultShell"`
+	ShellExecution bool                 `json:"shellExecution"` // default shell is available
+	Shells         []executor.ShellInfo `json:"shells"`
+}
+
 // ErrorResponse represents error response format
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -70,16 +89,18 @@ type ErrorResponse struct {
 }
 
 // @Summary Health check
-// @Description Get the health status of the application
+// @Description Get the health status of the application and of each enabled transport
+// @Description (http, grpc, mqtt, tunnel). Any transport that is not healthy makes the whole agent unhealthy
 // @Tags system
 // @Produce json
 // @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
 // @Router /health [get]
 func (h *SystemHandler) HealthCheck(c *gin.Context) {
 	response := HealthResponse{
-		Status:    "healthy",
+		Status:    common.StatusHealthy,
 		Timestamp: time.Now().Format(time.RFC3339),
-		Version:   "2.0.0", // This should be injected from build
+		Version:   common.AppVersion,
 		System: SystemInfo{
 			OS:           runtime.GOOS,
 			Architecture: runtime.GOARCH,
@@ -88,13 +109,30 @@ func (h *SystemHandler) HealthCheck(c *gin.Context) {
 			NumGoroutine: runtime.NumGoroutine(),
 		},
 		Services: map[string]string{
-			"command_service":  "healthy",
-			"executor_service": "healthy",
-			"security_service": "healthy",
+			"command_service":  common.StatusHealthy,
+			"executor_service": common.StatusHealthy,
+			"security_service": common.StatusHealthy,
 		},
 	}
 	
-	c.JSON(http.StatusOK, response)
+	for name, subsystem := range h.subsystems {
+		status := subsystem.Status()
+		response.Services[name] = status
+		
+		switch {
+		case status == common.StatusHealthy:
+		case status == common.StatusStarting && response.Status == common.StatusHealthy:
+			response.Status = common.StatusStarting
+		default:
+			response.Status = common.StatusUnhealthy
+		}
+	}
+	
+	code := http.StatusOK
+	if response.Status != common.StatusHealthy {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, response)
 }
 
 // @Summary Verify PIN
@@ -117,7 +155,16 @@ func (h *SystemHandler) VerifyPin(c *gin.Context) {
 		return
 	}
 	
-	if h.securityService.ValidatePin(req.Pin) {
+	err := h.securityService.VerifyPin(c.ClientIP(), req.Pin)
+	if errors.Is(err, common.ErrTooManyPinFailures) {
+		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:   "Rate limit exceeded",
+			Message: err.Error(),
+		})
+		return
+	}
+	
+	if err == nil {
 		c.JSON(http.StatusOK, AuthResponse{
 			Success: true,
 			Message: "PIN verified successfully",
@@ -164,9 +211,25 @@ func (h *SystemHandler) ReloadCommands(c *gin.Context) {
 // @Router /version [get]
 func (h *SystemHandler) GetVersion(c *gin.Context) {
 	c.JSON(http.StatusOK, map[string]string{
-		"version":   "2.0.0",
-		"buildTime": "2024-01-01T00:00:00Z", // This should be injected from build
-		"gitCommit": "unknown",               // This should be injected from build
+		"version":   common.AppVersion,
+		"buildTime": common.BuildTime,
+		"gitCommit": common.GitCommit,
+	})
+}
+
+// @Summary Get platform capabilities
+// @Description Get the agent's OS, architecture and the shells available for commands
+// @Tags system
+// @Produce json
+// @Success 200 {object} PlatformResponse
+// @Router /platform [get]
+func (h *SystemHandler) GetPlatform(c *gin.Context) {
+	c.JSON(http.StatusOK, PlatformResponse{
+		OS:             runtime.GOOS,
+		Architecture:   runtime.GOARCH,
+		DefaultShell:   executor.DefaultShell(),
+		ShellExecution: executor.ShellExecutionAvailable(),
+		Shells:         executor.DetectShells(),
 	})
 }
 
@@ -197,9 +260,13 @@ func (h *SystemHandler) GetStatus(c *gin.Context) {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 	
+	uptime := common.Uptime()
+	
 	status := map[string]interface{}{
-		"uptime":    time.Since(time.Now()).String(), // This should track actual uptime
-		"timestamp": time.Now().Format(time.RFC3339),
+		"uptime":        uptime.Truncate(time.Second).String(),
+		"uptimeSeconds": int64(uptime.Seconds()),
+		"startTime":     common.StartTime().Format(time.RFC3339),
+		"timestamp":     time.Now().Format(time.RFC3339),
 		"system": map[string]interface{}{
 			"os":           runtime.GOOS,
 			"architecture": runtime.GOARCH,
@@ -217,6 +284,7 @@ func (h *SystemHandler) GetStatus(c *gin.Context) {
 			"total":    commandCount,
 			"homepage": homepageCount,
 		},
+		"maintenance": h.maintenance.Mode(),
 		"services": map[string]string{
 			"command_service":  "running",
 			"executor_service": "running",
@@ -0,0 +1,308 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/interface/mqtt"
+)
+
+// newSystemRouter serves the system endpoints of a handler with no commands
+// and the given subsystems
+func newSystemRouter(t *testing.T, subsystems map[string]Subsystem) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.Config{}
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	handler := NewSystemHandler(service.NewCommandService(repo), security.NewService(cfg, logger), maintenanceService, subsystems)
+
+	router := gin.New()
+	router.GET("/health", handler.HealthCheck)
+	router.GET("/version", handler.GetVersion)
+	router.GET("/platform", handler.GetPlatform)
+	router.GET("/status", handler.GetStatus)
+	return router
+}
+
+// getJSON serves a GET request and decodes its JSON body into v
+func getJSON(t *testing.T, router *gin.Engine, path string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v: %s", path, err, w.Body.String())
+	}
+	return w.Code
+}
+
+type statusUptime struct {
+	Uptime        string `json:"uptime"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+	StartTime     string `json:"startTime"`
+}
+
+func TestGetStatusUptime(t *testing.T) {
+	router := newSystemRouter(t, nil)
+
+	var first, second statusUptime
+	if code := getJSON(t, router, "/status", &first); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if code := getJSON(t, router, "/status", &second); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+
+	if second.UptimeSeconds <= first.UptimeSeconds {
+		t.Errorf("uptimeSeconds went from %d to %d, want growth", first.UptimeSeconds, second.UptimeSeconds)
+	}
+	if first.StartTime != second.StartTime || first.StartTime != common.StartTime().Format(time.RFC3339) {
+		t.Errorf("startTime = %q then %q, want %q", first.StartTime, second.StartTime, common.StartTime().Format(time.RFC3339))
+	}
+	for _, status := range []statusUptime{first, second} {
+		uptime, err := time.ParseDuration(status.Uptime)
+		if err != nil {
+			t.Fatalf("uptime %q: %v", status.Uptime, err)
+		}
+		if int64(uptime.Seconds()) != status.UptimeSeconds {
+			t.Errorf("uptime %q disagrees with uptimeSeconds %d", status.Uptime, status.UptimeSeconds)
+		}
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	router := newSystemRouter(t, nil)
+	version, commit, buildTime := common.AppVersion, common.GitCommit, common.BuildTime
+	t.Cleanup(func() {
+		common.AppVersion, common.GitCommit, common.BuildTime = version, commit, buildTime
+	})
+
+	tests := []struct {
+		name      string
+		version   string
+		commit    string
+		buildTime string
+	}{
+		{"defaults", version, commit, buildTime},
+		{"injected by ldflags", "2.1.0", "abc1234", "2026-10-01T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			common.AppVersion, common.GitCommit, common.BuildTime = tt.version, tt.commit, tt.buildTime
+
+			var got map[string]string
+			if code := getJSON(t, router, "/version", &got); code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if got["version"] != tt.version || got["gitCommit"] != tt.commit || got["buildTime"] != tt.buildTime {
+				t.Errorf("version = %v, want %s %s %s", got, tt.version, tt.commit, tt.buildTime)
+			}
+		})
+	}
+}
+
+func TestGetPlatform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell stubs are shell scripts")
+	}
+	router := newSystemRouter(t, nil)
+
+	tests := []struct {
+		name               string
+		onPath             []string
+		wantShells         []string
+		wantShellExecution bool
+	}{
+		{"default shell and bash", []string{"sh", "bash"}, []string{"sh", "bash"}, true},
+		{"pwsh without the default shell", []string{"pwsh"}, []string{"pwsh"}, false},
+		{"no shells", nil, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.onPath {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+			t.Setenv("PATH", dir)
+
+			var got PlatformResponse
+			if code := getJSON(t, router, "/platform", &got); code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if got.OS != runtime.GOOS || got.Architecture != runtime.GOARCH || got.DefaultShell != "sh" {
+				t.Errorf("platform = %s/%s with %s, want %s/%s with sh", got.OS, got.Architecture, got.DefaultShell, runtime.GOOS, runtime.GOARCH)
+			}
+			if got.ShellExecution != tt.wantShellExecution {
+				t.Errorf("shellExecution = %v, want %v", got.ShellExecution, tt.wantShellExecution)
+			}
+			names := make([]string, len(got.Shells))
+			for i, shell := range got.Shells {
+				names[i] = shell.Name
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantShells, ",") {
+				t.Errorf("shells = %v, want %v", names, tt.wantShells)
+			}
+		})
+	}
+}
+
+func TestReloadCommandsServesNewDefinition(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "commands.json")
+	repo := infrastructure.NewFileCommandRepository(path)
+	if err := repo.Create(context.Background(), &entity.Command{ID: "greet", Name: "Greet", Command: "echo v1", Platform: runtime.GOOS}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	executeHandler := newTestExecuteHandler(t, &config.Config{}, repo)
+	systemHandler := NewSystemHandler(executeHandler.commandService, executeHandler.securityService, executeHandler.maintenance, nil)
+	router := gin.New()
+	router.POST("/reload", systemHandler.ReloadCommands)
+	router.GET("/execute", executeHandler.ExecuteCommand)
+
+	// editFile changes the command on disk, as an operator editing the config would
+	editFile := func(command string) {
+		editor := infrastructure.NewFileCommandRepository(path)
+		if err := editor.Reload(context.Background()); err != nil {
+			t.Fatalf("load commands file: %v", err)
+		}
+		cmd, err := editor.GetByID(context.Background(), "greet")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		cmd.Command = command
+		if err := editor.Update(context.Background(), cmd); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	// Results are never cached: each step executes the command again, and a
+	// reload makes the edited definition the one executed
+	tests := []struct {
+		name       string
+		edit       string
+		reload     bool
+		wantOutput string
+	}{
+		{"initial definition", "", false, "v1"},
+		{"executed again", "", false, "v1"},
+		{"edited but not reloaded", "echo v2", false, "v1"},
+		{"reloaded", "", true, "v2"},
+		{"edited and reloaded again", "echo v3", true, "v3"},
+	}
+	runIDs := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.edit != "" {
+				editFile(tt.edit)
+			}
+			if tt.reload {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/reload", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("reload status = %d: %s", w.Code, w.Body.String())
+				}
+			}
+
+			var resp ExecuteResponse
+			if code := getJSON(t, router, "/execute?id=greet", &resp); code != http.StatusOK {
+				t.Fatalf("execute status = %d: %+v", code, resp)
+			}
+			if got := strings.TrimSpace(resp.Output); got != tt.wantOutput {
+				t.Errorf("output = %q, want %q", got, tt.wantOutput)
+			}
+			if runIDs[resp.RunID] {
+				t.Errorf("run %s was returned twice", resp.RunID)
+			}
+			runIDs[resp.RunID] = true
+		})
+	}
+}
+
+// staticSubsystem is a transport whose status never changes
+type staticSubsystem string
+
+func (s staticSubsystem) Status() string { return string(s) }
+
+func TestHealthCheckSubsystems(t *testing.T) {
+	// A client of a broker that is not running stays disconnected
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	brokerAddr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	cfg.MQTT.Broker, cfg.MQTT.Port = brokerAddr.IP.String(), brokerAddr.Port
+	cfg.MQTT.ClientID, cfg.MQTT.TopicBase = "agent", "lazy-ctrl/test"
+	disconnected := mqtt.NewClient(cfg, logger, nil, nil, nil, nil)
+	if err := disconnected.Start(); err == nil {
+		t.Fatal("MQTT client connected without a broker")
+	}
+	defer disconnected.Stop()
+
+	healthy := staticSubsystem(common.StatusHealthy)
+	tests := []struct {
+		name       string
+		subsystems map[string]Subsystem
+		wantCode   int
+		wantStatus string
+	}{
+		{"no transports", nil, http.StatusOK, common.StatusHealthy},
+		{"all healthy", map[string]Subsystem{"http": healthy, "grpc": healthy, "mqtt": healthy}, http.StatusOK, common.StatusHealthy},
+		{"mqtt disconnected", map[string]Subsystem{"http": healthy, "grpc": healthy, "mqtt": disconnected}, http.StatusServiceUnavailable, common.StatusUnhealthy},
+		{"grpc starting", map[string]Subsystem{"http": healthy, "grpc": staticSubsystem(common.StatusStarting)}, http.StatusServiceUnavailable, common.StatusStarting},
+		{"unhealthy beats starting", map[string]Subsystem{"grpc": staticSubsystem(common.StatusStarting), "mqtt": disconnected}, http.StatusServiceUnavailable, common.StatusUnhealthy},
+		{"tunnel stopping", map[string]Subsystem{"http": healthy, "tunnel": staticSubsystem(common.StatusStopping)}, http.StatusServiceUnavailable, common.StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newSystemRouter(t, tt.subsystems)
+
+			var resp HealthResponse
+			if code := getJSON(t, router, "/health", &resp); code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			for name, subsystem := range tt.subsystems {
+				if got := resp.Services[name]; got != subsystem.Status() {
+					t.Errorf("services[%s] = %q, want %q", name, got, subsystem.Status())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+const testAdminPin = "4321"
+
+// testAdminPinHash is the bcrypt hash of testAdminPin, at the lowest cost to
+// keep the tests fast
+var testAdminPinHash = func() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testAdminPin), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(hash)
+}()
+
+// newTenancyRouter serves the command and execute routes in tenancy mode over
+// a command set of alice's, bob's and one shared command
+func newTenancyRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Security.Pin = testAdminPin
+	cfg.Security.AdminPinHash = testAdminPinHash
+	cfg.Commands.Tenancy = true
+	cfg.Commands.Tenants = []config.TenantToken{
+		{Token: "alice-token", UserID: "alice"},
+		{Token: "bob-token", UserID: "bob"},
+	}
+	repo := newTestRepository(t,
+		&entity.Command{ID: "alice-cmd", Name: "Alice", Command: "echo alice", UserID: "alice"},
+		&entity.Command{ID: "bob-cmd", Name: "Bob", Command: "echo bob", UserID: "bob"},
+		&entity.Command{ID: "shared-cmd", Name: "Shared", Command: "echo shared"},
+	)
+	executeHandler := newTestExecuteHandler(t, cfg, repo)
+	commandHandler := NewCommandHandler(executeHandler.commandService, executeHandler.securityService, true)
+
+	router := gin.New()
+	router.POST("/commands", commandHandler.CreateCommand)
+	router.GET("/commands", commandHandler.GetAllCommands)
+	router.GET("/commands/export", commandHandler.ExportCommands)
+	router.GET("/commands/:id", commandHandler.GetCommand)
+	router.GET("/commands/:id/security", executeHandler.GetCommandSecurity)
+	router.PUT("/commands/:id", commandHandler.UpdateCommand)
+	router.DELETE("/commands/:id", commandHandler.DeleteCommand)
+	router.GET("/execute", executeHandler.ExecuteCommand)
+	router.GET("/execute/info", executeHandler.GetCommandInfo)
+	return router
+}
+
+func serveTenancy(router *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(common.HeaderContentType, common.ContentTypeJSON)
+	}
+	if token != "" {
+		req.Header.Set(common.HeaderXTenantToken, token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenancyListsOnlyCallersCommands(t *testing.T) {
+	router := newTenancyRouter(t)
+
+	tests := []struct {
+		name  string
+		token string
+		query string
+		want  []string
+	}{
+		{"alice", "alice-token", "", []string{"alice-cmd", "shared-cmd"}},
+		{"bob", "bob-token", "", []string{"bob-cmd", "shared-cmd"}},
+		{"no token", "", "", []string{"shared-cmd"}},
+		{"forged token", "alice", "", []string{"shared-cmd"}},
+		{"admin", "", "?adminPin=" + testAdminPin, []string{"alice-cmd", "bob-cmd", "shared-cmd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveTenancy(router, http.MethodGet, "/commands"+tt.query, tt.token, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var commands []CommandResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &commands); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var got []string
+			for _, cmd := range commands {
+				got = append(got, cmd.ID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenancyHidesOtherTenantsCommandsByID(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"get own", http.MethodGet, "/commands/alice-cmd", "alice-token", "", http.StatusOK},
+		{"get other's", http.MethodGet, "/commands/bob-cmd", "alice-token", "", http.StatusNotFound},
+		{"security of other's", http.MethodGet, "/commands/bob-cmd/security", "alice-token", "", http.StatusNotFound},
+		{"info of other's", http.MethodGet, "/execute/info?id=bob-cmd", "alice-token", "", http.StatusNotFound},
+		{"execute other's", http.MethodGet, "/execute?id=bob-cmd", "alice-token", "", http.StatusNotFound},
+		{"execute other's without token", http.MethodGet, "/execute?id=bob-cmd", "", "", http.StatusNotFound},
+		{"update own", http.MethodPut, "/commands/alice-cmd", "alice-token", `{"name":"Renamed"}`, http.StatusOK},
+		{"update other's", http.MethodPut, "/commands/bob-cmd", "alice-token", `{"name":"Hijacked"}`, http.StatusNotFound},
+		{"update without token", http.MethodPut, "/commands/alice-cmd", "", `{"name":"Anonymous"}`, http.StatusUnauthorized},
+		{"update shared", http.MethodPut, "/commands/shared-cmd", "alice-token", `{"name":"Mine"}`, http.StatusForbidden},
+		{"update as admin", http.MethodPut, "/commands/bob-cmd?adminPin=" + testAdminPin, "", `{"name":"Admin"}`, http.StatusOK},
+		{"delete other's", http.MethodDelete, "/commands/bob-cmd", "alice-token", "", http.StatusNotFound},
+		{"delete shared", http.MethodDelete, "/commands/shared-cmd", "bob-token", "", http.StatusForbidden},
+		{"delete own", http.MethodDelete, "/commands/alice-cmd", "alice-token", "", http.StatusNoContent},
+		{"create without token", http.MethodPost, "/commands", "", `{"id":"anon","name":"Anon","command":"echo"}`, http.StatusUnauthorized},
+		{"export without admin", http.MethodGet, "/commands/export", "alice-token", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTenancyRouter(t)
+			w := serveTenancy(router, tt.method, tt.path, tt.token, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestTenancyUpdateKeepsOwner(t *testing.T) {
+	router := newTenancyRouter(t)
+
+	w := serveTenancy(router, http.MethodPut, "/commands/alice-cmd", "alice-token", `{"userId":"bob"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if w := serveTenancy(router, http.MethodGet, "/commands/alice-cmd", "alice-token", ""); w.Code != http.StatusOK {
+		t.Errorf("alice lost her command: %d", w.Code)
+	}
+	if w := serveTenancy(router, http.MethodGet, "/commands/alice-cmd", "bob-token", ""); w.Code != http.StatusNotFound {
+		t.Errorf("bob sees alice's command: %d", w.Code)
+	}
+}
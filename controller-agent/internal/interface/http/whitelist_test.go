@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestExecuteCommandWhitelist(t *testing.T) {
+	repo := newTestRepository(t,
+		&entity.Command{ID: "whitelisted", Name: "Whitelisted", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true}},
+		&entity.Command{ID: "plain", Name: "Plain", Command: "echo ok", Platform: runtime.GOOS},
+		&entity.Command{ID: "listed", Name: "Listed", Command: "echo ok", Platform: runtime.GOOS},
+	)
+
+	tests := []struct {
+		name        string
+		defaultDeny bool
+		id          string
+		wantStatus  int
+	}{
+		{"default allow, whitelisted", false, "whitelisted", http.StatusOK},
+		{"default allow, plain", false, "plain", http.StatusOK},
+		{"default deny, whitelisted", true, "whitelisted", http.StatusOK},
+		{"default deny, plain", true, "plain", http.StatusForbidden},
+		{"default deny, in allowed commands", true, "listed", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Security: config.SecurityConfig{DefaultDeny: tt.defaultDeny, AllowedCommands: []string{"listed"}}}
+			handler := newTestExecuteHandler(t, cfg, repo)
+			router := gin.New()
+			router.GET("/execute", handler.ExecuteCommand)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/execute?id="+tt.id, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusOK {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Error != "Command not allowed" || !strings.Contains(resp.Message, common.ErrCommandNotAllowed.Error()) {
+				t.Errorf("response = %+v, want a command not allowed error", resp)
+			}
+		})
+	}
+}
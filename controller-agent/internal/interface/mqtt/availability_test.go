@@ -0,0 +1,73 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+func TestCommandsAvailability(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+
+	tests := []struct {
+		cmd  *entity.Command
+		want bool
+	}{
+		{&entity.Command{ID: "here", Command: "echo", Platform: runtime.GOOS}, true},
+		{&entity.Command{ID: "here-not-whitelisted", Command: "echo", Platform: runtime.GOOS, Security: &entity.SecurityConfig{}}, true},
+		{&entity.Command{ID: "elsewhere", Command: "echo", Platform: other}, false},
+		{&entity.Command{ID: "elsewhere-whitelisted", Command: "echo", Platform: other, Security: &entity.SecurityConfig{Whitelist: true}}, false},
+		{&entity.Command{ID: "variant", Command: "echo", Platform: other, Platforms: map[string]string{runtime.GOOS: "echo"}, Security: &entity.SecurityConfig{}}, true},
+	}
+	broker := startTestBroker(t)
+	var commands []*entity.Command
+	for _, tt := range tests {
+		commands = append(commands, tt.cmd)
+	}
+	c := startBrokerClient(t, broker, commands...)
+	sub := subscribeTestBroker(t, broker, responseTopic(c.config.MQTT, "+"))
+
+	if token := sub.Publish(commandsTopic(c.config.MQTT), 1, false, `{"requestId":"list"}`); token.Wait() && token.Error() != nil {
+		t.Fatalf("publish: %v", token.Error())
+	}
+	var resp struct {
+		RequestID string `json:"requestId"`
+		Commands  []struct {
+			ID        string `json:"id"`
+			Available bool   `json:"available"`
+		} `json:"commands"`
+	}
+	select {
+	case msg := <-sub.messages:
+		if err := json.Unmarshal(msg.Payload(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no command list response")
+	}
+	if resp.RequestID != "list" {
+		t.Fatalf("requestId = %q, want list", resp.RequestID)
+	}
+	available := make(map[string]bool, len(resp.Commands))
+	for _, cmd := range resp.Commands {
+		available[cmd.ID] = cmd.Available
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd.ID, func(t *testing.T) {
+			got, ok := available[tt.cmd.ID]
+			if !ok {
+				t.Fatal("command not listed")
+			}
+			if got != tt.want {
+				t.Errorf("available = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+// testBroker is a minimal in-process MQTT 3.1.1 broker: it accepts every
+// connection, acknowledges QoS 1 publishes and forwards them at QoS 0 to
+// matching subscriptions. Retained messages and wills are not kept
+type testBroker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	sessions map[*brokerSession]bool
+}
+
+type brokerSession struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	filters []string // guarded by the broker's mu
+}
+
+// startTestBroker starts a broker on a free local port, stopped with the test
+func startTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	b := &testBroker{listener: listener, sessions: make(map[*brokerSession]bool)}
+	go b.accept()
+	t.Cleanup(b.close)
+	return b
+}
+
+// hostPort returns the broker address as MQTT config broker and port
+func (b *testBroker) hostPort() (string, int) {
+	addr := b.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// url returns the broker address for a paho client
+func (b *testBroker) url() string {
+	host, port := b.hostPort()
+	return "tcp://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (b *testBroker) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		session := &brokerSession{conn: conn}
+		b.mu.Lock()
+		b.sessions[session] = true
+		b.mu.Unlock()
+		go b.serve(session)
+	}
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for session := range b.sessions {
+		session.conn.Close()
+	}
+}
+
+func (b *testBroker) serve(session *brokerSession) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.sessions, session)
+		b.mu.Unlock()
+		session.conn.Close()
+	}()
+
+	for {
+		packet, err := packets.ReadPacket(session.conn)
+		if err != nil {
+			return
+		}
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			session.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			b.mu.Lock()
+			session.filters = append(session.filters, p.Topics...)
+			b.mu.Unlock()
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics)) // granted QoS 0
+			session.write(ack)
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				session.write(ack)
+			}
+			b.forward(p.TopicName, p.Payload)
+		case *packets.PingreqPacket:
+			session.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// waitSubscribed waits until some session subscribes to filter, as clients
+// subscribe asynchronously once connected
+func (b *testBroker) waitSubscribed(t *testing.T, filter string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for session := range b.sessions {
+			for _, f := range session.filters {
+				if f == filter {
+					b.mu.Unlock()
+					return
+				}
+			}
+		}
+		b.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("nothing subscribed to %s", filter)
+}
+
+// forward delivers a message to every session with a matching subscription
+func (b *testBroker) forward(topic string, payload []byte) {
+	b.mu.Lock()
+	var targets []*brokerSession
+	for session := range b.sessions {
+		for _, filter := range session.filters {
+			if topicMatches(filter, topic) {
+				targets = append(targets, session)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, session := range targets {
+		msg := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		msg.TopicName = topic
+		msg.Payload = payload
+		session.write(msg)
+	}
+}
+
+func (s *brokerSession) write(packet packets.ControlPacket) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	packet.Write(s.conn)
+}
+
+// topicMatches reports whether topic matches a subscription filter with the
+// "+" and "#" wildcards
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// startBrokerClient starts an agent client with the given commands, connected
+// to broker under the topic base "lazy-ctrl/test"
+func startBrokerClient(t *testing.T, broker *testBroker, commands ...*entity.Command) *Client {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.Config{}
+	cfg.MQTT.Broker, cfg.MQTT.Port = broker.hostPort()
+	cfg.MQTT.ClientID = "agent"
+	cfg.MQTT.TopicBase = "lazy-ctrl/test"
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	for _, cmd := range commands {
+		if err := repo.Create(context.Background(), cmd); err != nil {
+			t.Fatalf("create %s: %v", cmd.ID, err)
+		}
+	}
+	maintenanceService, err := maintenance.NewService(filepath.Join(t.TempDir(), "maintenance.json"), logger)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	c := NewClient(cfg, logger, service.NewCommandService(repo), executor.NewService(logger), security.NewService(cfg, logger), maintenanceService)
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	broker.waitSubscribed(t, commandsTopic(cfg.MQTT))
+	return c
+}
+
+// testSubscriber is a second client on the broker, like a dashboard
+type testSubscriber struct {
+	paho.Client
+	messages chan paho.Message
+}
+
+// subscribeTestBroker connects a subscriber to filter on broker
+func subscribeTestBroker(t *testing.T, broker *testBroker, filter string) *testSubscriber {
+	t.Helper()
+	sub := &testSubscriber{
+		Client:   paho.NewClient(paho.NewClientOptions().AddBroker(broker.url()).SetClientID("subscriber")),
+		messages: make(chan paho.Message, 16),
+	}
+	if token := sub.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatalf("connect subscriber: %v", token.Error())
+	}
+	t.Cleanup(func() { sub.Disconnect(0) })
+	if token := sub.Subscribe(filter, 0, func(_ paho.Client, msg paho.Message) {
+		sub.messages <- msg
+	}); token.Wait() && token.Error() != nil {
+		t.Fatalf("subscribe: %v", token.Error())
+	}
+	return sub
+}
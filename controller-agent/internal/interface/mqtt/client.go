@@ -3,40 +3,117 @@ package mqtt
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
 	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/audit"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/executor"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/maintenance"
 	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
 )
 
-// Client represents the MQTT client
+// Client represents the MQTT client.
+//
+// Topic layout, relative to the configured topic base:
+//
+//	execute               execute requests ({"commandId", "pin", "requestId"})
+//	commands              command list requests ({"requestId"}, optional)
+//	response              responses, each carrying the request's requestId
+//	response/<requestId>  responses when per_request_response is enabled
+//	results/<commandId>   published execution results
+//	status                retained presence ({"online": ...}), also the Last Will
+//
+// Requests without a requestId get a generated one, returned in the response.
 type Client struct {
 	config          *config.Config
 	logger          *logrus.Logger
 	commandService  *service.CommandService
 	executorService *executor.Service
 	securityService *security.Service
+	maintenance     *maintenance.Service
 	client          mqtt.Client
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// offlinePayload is the retained Last Will published by the broker if the agent disappears
+const offlinePayload = `{"online":false}`
+
+// mqttPinClient keys the wrong-PIN throttle for MQTT requests, which carry no
+// client address, so every MQTT caller shares one failure count
+const mqttPinClient = "mqtt"
+
+// pinErrorMessage is the error reported for a failed PIN check, message
+// unless the callers were throttled for too many wrong PINs
+func pinErrorMessage(err error, message string) string {
+	if errors.Is(err, common.ErrTooManyPinFailures) {
+		return err.Error()
+	}
+	return message
+}
+
+// StatusMessage represents the retained presence message
+type StatusMessage struct {
+	Online    bool   `json:"online"`
+	ClientID  string `json:"clientId"`
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
 }
 
 // ExecuteRequest represents MQTT execute request
 type ExecuteRequest struct {
+	RequestID string `json:"requestId,omitempty"`
 	CommandID string `json:"commandId"`
 	Pin       string `json:"pin,omitempty"`
+	AdminPin  string `json:"adminPin,omitempty"` // required by admin-only commands
+	Stdin     string `json:"stdin,omitempty"`    // piped to the command
+	ClientID  string `json:"clientId,omitempty"` // checked against the command's allowed clients
+
+	TenantToken string `json:"tenantToken,omitempty"` // in tenancy mode other tenants' commands are not found; its user is the command's LAZYCTRL_USER_ID
+
+	Params map[string]interface{} `json:"params,omitempty"` // checked against the command's params, overriding its templateParams
+}
+
+// CommandsRequest represents MQTT command list request
+type CommandsRequest struct {
+	RequestID string `json:"requestId,omitempty"`
+	AdminPin  string `json:"adminPin,omitempty"` // lists admin-only commands too
+
+	TenantToken string `json:"tenantToken,omitempty"` // in tenancy mode only its tenant's commands and unowned ones are listed
 }
 
 // ExecuteResponse represents MQTT execute response
 type ExecuteResponse struct {
-	Success  bool   `json:"success"`
-	Output   string `json:"output"`
-	Error    string `json:"error,omitempty"`
-	ExitCode int    `json:"exitCode"`
+	RequestID string `json:"requestId"`
+	RunID     string `json:"runId,omitempty"`
+	CommandID string `json:"commandId,omitempty"` // canonical ID, also when executed by alias
+	Success   bool   `json:"success"`
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"` // output exceeded the size cap
+	Killed    bool   `json:"killed,omitempty"`    // stopped on timeout or cancellation
+	KilledBy  string `json:"killedBy,omitempty"`  // SIGTERM, or SIGKILL once the grace period ran out
+	Attempts  int    `json:"attempts,omitempty"`  // executions of the command, more than one when retried
+	Error     string `json:"error,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	Signature string `json:"signature,omitempty"` // HMAC of runId, commandId, success, exitCode, output and error
+
+	PreHook  *executor.HookResult `json:"preHook,omitempty"`
+	PostHook *executor.HookResult `json:"postHook,omitempty"`
+
+	Diagnostic *executor.DiagnosticResult `json:"diagnostic,omitempty"` // output of the command's failure diagnostic
+
+	Data          interface{} `json:"data,omitempty"`          // output parsed per the command's outputFormat
+	OutputWarning string      `json:"outputWarning,omitempty"` // why the output could not be parsed
 }
 
 // NewClient creates a new MQTT client instance
@@ -46,6 +123,7 @@ func NewClient(
 	commandService *service.CommandService,
 	executorService *executor.Service,
 	securityService *security.Service,
+	maintenanceService *maintenance.Service,
 ) *Client {
 	return &Client{
 		config:          cfg,
@@ -53,11 +131,17 @@ func NewClient(
 		commandService:  commandService,
 		executorService: executorService,
 		securityService: securityService,
+		maintenance:     maintenanceService,
+		stopCh:          make(chan struct{}),
 	}
 }
 
 // Start starts the MQTT client
 func (c *Client) Start() error {
+	for _, warning := range lintTopics(c.config.MQTT) {
+		c.logger.WithField("topic_base", c.config.MQTT.TopicBase).Warn("MQTT topic configuration: " + warning)
+	}
+	
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", c.config.MQTT.Broker, c.config.MQTT.Port))
 	opts.SetClientID(c.config.MQTT.ClientID)
@@ -69,6 +153,9 @@ func (c *Client) Start() error {
 		opts.SetPassword(c.config.MQTT.Password)
 	}
 
+	// Must be registered before Connect so the broker announces crashes
+	opts.SetWill(c.statusTopic(), offlinePayload, 1, true)
+
 	opts.SetDefaultPublishHandler(c.messageHandler)
 	opts.OnConnect = c.onConnect
 	opts.OnConnectionLost = c.onConnectionLost
@@ -80,35 +167,102 @@ func (c *Client) Start() error {
 		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
 	}
 
+	if c.config.MQTT.HeartbeatInterval > 0 {
+		go c.heartbeat(time.Duration(c.config.MQTT.HeartbeatInterval) * time.Second)
+	}
+
 	return nil
 }
 
+// Status reports whether the client is connected to the broker
+func (c *Client) Status() string {
+	select {
+	case <-c.stopCh:
+		return common.StatusStopping
+	default:
+	}
+	
+	if c.client == nil {
+		return common.StatusStarting
+	}
+	// IsConnected stays true while paho reconnects, hiding a lost broker
+	if !c.client.IsConnectionOpen() {
+		return common.StatusUnhealthy
+	}
+	return common.StatusHealthy
+}
+
 // Stop stops the MQTT client
 func (c *Client) Stop() {
 	c.logger.Info("Disconnecting from MQTT broker")
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
 	if c.client != nil && c.client.IsConnected() {
+		// A clean disconnect does not trigger the will, so announce it ourselves
+		if token := c.client.Publish(c.statusTopic(), 1, true, offlinePayload); token.WaitTimeout(time.Second) && token.Error() != nil {
+			c.logger.WithError(token.Error()).Warn("Failed to publish offline status")
+		}
 		c.client.Disconnect(250)
 	}
 }
 
+// statusTopic returns the presence topic
+func (c *Client) statusTopic() string {
+	return statusTopic(c.config.MQTT)
+}
+
+// publishStatus publishes the retained online presence message
+func (c *Client) publishStatus(client mqtt.Client) {
+	payload, _ := json.Marshal(StatusMessage{
+		Online:    true,
+		ClientID:  c.config.MQTT.ClientID,
+		Version:   common.AppVersion,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+	
+	if token := client.Publish(c.statusTopic(), 1, true, payload); token.Wait() && token.Error() != nil {
+		c.logger.WithError(token.Error()).Error("Failed to publish status")
+	}
+}
+
+// heartbeat refreshes the presence message until the client is stopped
+func (c *Client) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ticker.C:
+			if c.client.IsConnected() {
+				c.publishStatus(c.client)
+			}
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
 // onConnect handles MQTT connection event
 func (c *Client) onConnect(client mqtt.Client) {
 	c.logger.Info("MQTT client connected")
 	
+	c.publishStatus(client)
+	
 	// Subscribe to execute topic
-	executeTopic := fmt.Sprintf("%s/execute", c.config.MQTT.TopicBase)
-	if token := client.Subscribe(executeTopic, 1, c.executeHandler); token.Wait() && token.Error() != nil {
+	topic := executeTopic(c.config.MQTT)
+	if token := client.Subscribe(topic, 1, c.executeHandler); token.Wait() && token.Error() != nil {
 		c.logger.WithError(token.Error()).Error("Failed to subscribe to execute topic")
 	} else {
-		c.logger.WithField("topic", executeTopic).Info("Subscribed to MQTT topic")
+		c.logger.WithField("topic", topic).Info("Subscribed to MQTT topic")
 	}
 	
 	// Subscribe to commands topic
-	commandsTopic := fmt.Sprintf("%s/commands", c.config.MQTT.TopicBase)
-	if token := client.Subscribe(commandsTopic, 1, c.commandsHandler); token.Wait() && token.Error() != nil {
+	topic = commandsTopic(c.config.MQTT)
+	if token := client.Subscribe(topic, 1, c.commandsHandler); token.Wait() && token.Error() != nil {
 		c.logger.WithError(token.Error()).Error("Failed to subscribe to commands topic")
 	} else {
-		c.logger.WithField("topic", commandsTopic).Info("Subscribed to MQTT topic")
+		c.logger.WithField("topic", topic).Info("Subscribed to MQTT topic")
 	}
 }
 
@@ -132,21 +286,24 @@ func (c *Client) executeHandler(client mqtt.Client, msg mqtt.Message) {
 	var req ExecuteRequest
 	if err := json.Unmarshal(msg.Payload(), &req); err != nil {
 		c.logger.WithError(err).Error("Failed to parse execute request")
-		c.publishError(client, "invalid_request", "Failed to parse request", msg.Topic())
+		c.publishError(client, uuid.New().String(), "invalid_request", "Failed to parse request", msg.Topic())
 		return
 	}
+	if req.RequestID == "" {
+		req.RequestID = uuid.New().String()
+	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
 	// Execute the command
 	response := c.executeCommand(ctx, req)
+	response.RequestID = req.RequestID
 	
 	// Publish response
-	responseTopic := fmt.Sprintf("%s/response", c.config.MQTT.TopicBase)
 	responseData, _ := json.Marshal(response)
 	
-	if token := client.Publish(responseTopic, 1, false, responseData); token.Wait() && token.Error() != nil {
+	if token := client.Publish(c.responseTopic(req.RequestID), 1, false, responseData); token.Wait() && token.Error() != nil {
 		c.logger.WithError(token.Error()).Error("Failed to publish execute response")
 	}
 }
@@ -155,19 +312,36 @@ func (c *Client) executeHandler(client mqtt.Client, msg mqtt.Message) {
 func (c *Client) commandsHandler(client mqtt.Client, msg mqtt.Message) {
 	c.logger.WithField("topic", msg.Topic()).Info("Received commands request")
 	
+	// The payload is optional; an empty or unparsable one just has no request ID
+	var req CommandsRequest
+	_ = json.Unmarshal(msg.Payload(), &req)
+	if req.RequestID == "" {
+		req.RequestID = uuid.New().String()
+	}
+	
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
 	commands, err := c.commandService.GetAllCommands(ctx)
 	if err != nil {
-		c.publishError(client, "internal_error", "Failed to get commands", msg.Topic())
+		c.publishError(client, req.RequestID, "internal_error", "Failed to get commands", msg.Topic())
 		return
 	}
 	
+	// Admin-only commands are listed for callers with the admin PIN
+	isAdmin := c.securityService.IsAdmin(mqttPinClient, req.AdminPin)
+	principal := c.securityService.TenantScope(mqttPinClient, req.TenantToken, req.AdminPin)
+	
 	// Convert to simple format
-	simpleCommands := make([]map[string]interface{}, len(commands))
-	for i, cmd := range commands {
-		simpleCommands[i] = map[string]interface{}{
+	simpleCommands := make([]map[string]interface{}, 0, len(commands))
+	for _, cmd := range commands {
+		if cmd.RequiresAdmin() && !isAdmin {
+			continue
+		}
+		if principal != nil && !cmd.VisibleTo(*principal) {
+			continue
+		}
+		simpleCommands = append(simpleCommands, map[string]interface{}{
 			"id":          cmd.ID,
 			"name":        cmd.Name,
 			"description": cmd.Description,
@@ -175,17 +349,17 @@ func (c *Client) commandsHandler(client mqtt.Client, msg mqtt.Message) {
 			"platform":    cmd.Platform,
 			"available":   cmd.IsAvailableOnPlatform(),
 			"requiresPin": cmd.RequiresPin(),
-		}
+		})
 	}
 	
 	// Publish response
-	responseTopic := fmt.Sprintf("%s/response", c.config.MQTT.TopicBase)
 	responseData, _ := json.Marshal(map[string]interface{}{
-		"success":  true,
-		"commands": simpleCommands,
+		"requestId": req.RequestID,
+		"success":   true,
+		"commands":  simpleCommands,
 	})
 	
-	if token := client.Publish(responseTopic, 1, false, responseData); token.Wait() && token.Error() != nil {
+	if token := client.Publish(c.responseTopic(req.RequestID), 1, false, responseData); token.Wait() && token.Error() != nil {
 		c.logger.WithError(token.Error()).Error("Failed to publish commands response")
 	}
 }
@@ -194,6 +368,11 @@ func (c *Client) commandsHandler(client mqtt.Client, msg mqtt.Message) {
 func (c *Client) executeCommand(ctx context.Context, req ExecuteRequest) ExecuteResponse {
 	// Get command
 	cmd, err := c.commandService.GetCommand(ctx, req.CommandID)
+	if err == nil {
+		if principal := c.securityService.TenantScope(mqttPinClient, req.TenantToken, req.AdminPin); principal != nil && !cmd.VisibleTo(*principal) {
+			err = common.ErrCommandNotFound
+		}
+	}
 	if err != nil {
 		return ExecuteResponse{
 			Success:  false,
@@ -202,19 +381,38 @@ func (c *Client) executeCommand(ctx context.Context, req ExecuteRequest) Execute
 		}
 	}
 	
+	// MQTT carries no client address, so only client IDs can match
+	if !cmd.IsClientAllowed(req.ClientID, "") {
+		return ExecuteResponse{
+			Success:  false,
+			Error:    common.ErrClientNotAllowed.Error(),
+			ExitCode: -1,
+		}
+	}
+	
 	// PIN verification if required
 	if cmd.RequiresPin() {
-		if !c.securityService.ValidatePin(req.Pin) {
+		if err := c.securityService.VerifyPin(mqttPinClient, req.Pin); err != nil {
+			return ExecuteResponse{
+				Success:  false,
+				Error:    pinErrorMessage(err, "Invalid or missing PIN"),
+				ExitCode: -1,
+			}
+		}
+	}
+	
+	if cmd.RequiresAdmin() {
+		if err := c.securityService.VerifyAdminPin(mqttPinClient, req.AdminPin); err != nil {
 			return ExecuteResponse{
 				Success:  false,
-				Error:    "Invalid or missing PIN",
+				Error:    pinErrorMessage(err, common.ErrAdminRequired.Error()),
 				ExitCode: -1,
 			}
 		}
 	}
 	
 	// Get platform command
-	platformCommand, err := c.commandService.GetPlatformCommand(ctx, req.CommandID)
+	platformCommand, err := c.commandService.GetPlatformCommand(ctx, cmd.ID)
 	if err != nil {
 		return ExecuteResponse{
 			Success:  false,
@@ -223,31 +421,162 @@ func (c *Client) executeCommand(ctx context.Context, req ExecuteRequest) Execute
 		}
 	}
 	
+	env, err := cmd.ExecutionEnv(req.Params)
+	if err != nil {
+		return ExecuteResponse{
+			Success:  false,
+			Error:    err.Error(),
+			ExitCode: -1,
+		}
+	}
+	
+	if err := c.maintenance.CheckExecution(); err != nil {
+		return ExecuteResponse{
+			Success:  false,
+			Error:    err.Error(),
+			ExitCode: -1,
+		}
+	}
+	
+	if err := c.securityService.CheckCommandAccess(cmd); err != nil {
+		return ExecuteResponse{
+			Success:  false,
+			Error:    err.Error(),
+			ExitCode: -1,
+		}
+	}
+	
+	if err := c.securityService.CheckAllowedHours(cmd); err != nil {
+		return ExecuteResponse{
+			Success:  false,
+			Error:    err.Error(),
+			ExitCode: -1,
+		}
+	}
+	
+	if cmd.HasRateLimit() {
+		window := time.Duration(cmd.RateLimit.Window) * time.Second
+		if err := c.securityService.CheckCommandRateLimit(cmd.ID, cmd.RateLimit.MaxExecutions, window); err != nil {
+			return ExecuteResponse{
+				Success:  false,
+				Error:    err.Error(),
+				ExitCode: -1,
+			}
+		}
+	}
+	
 	// Execute with timeout
-	executeCtx, cancel := context.WithTimeout(ctx, time.Duration(cmd.GetTimeout())*time.Millisecond)
+	timeout, clamped := cmd.ExecutionTimeout(0, c.config.Commands.MaxExecutionTimeout())
+	if clamped {
+		c.logger.WithFields(logrus.Fields{
+			"command_id": cmd.ID,
+			"timeout":    timeout,
+		}).Info("Execution timeout clamped to the maximum")
+	}
+	executeCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	
-	result, err := c.executorService.Execute(executeCtx, platformCommand)
+	result, err := c.executorService.ExecuteWithOptions(executeCtx, platformCommand, executor.ExecuteOptions{
+		WorkingDir: cmd.WorkingDir,
+		Env:        env,
+		CommandID:  cmd.ID,
+		Publish:    cmd.PublishResults,
+		LoginShell: cmd.LoginShell,
+		Shell:      cmd.Shell,
+		Args:       cmd.Args,
+		Tenant:     cmd.Tenant(),
+		UserID:     c.securityService.TenantUserID(req.TenantToken),
+		Category:   cmd.Category,
+		Stdin:      []byte(req.Stdin),
+
+		PreHook:     cmd.PreHook,
+		PostHook:    cmd.PostHook,
+		StrictHooks: cmd.StrictHooks,
+
+		OnFailureDiagnostic: cmd.OnFailureDiagnostic,
+		OutputFormat:        cmd.OutputFormat,
+		Retry:               cmd.Retry,
+		DependsOn:           cmd.DependsOn,
+		AutoRunDeps:         cmd.AutoRunDeps,
+
+		Source:      audit.InterfaceMQTT,
+		RequiresPin: cmd.RequiresPin(),
+	})
 	if err != nil {
 		return ExecuteResponse{
-			Success:  false,
-			Error:    fmt.Sprintf("Execution failed: %s", err.Error()),
-			ExitCode: -1,
+			CommandID: cmd.ID,
+			Success:   false,
+			Error:     fmt.Sprintf("Execution failed: %s", err.Error()),
+			ExitCode:  -1,
 		}
 	}
 	
 	return ExecuteResponse{
-		Success:  result.Success,
-		Output:   result.Output,
-		Error:    result.Error,
-		ExitCode: result.ExitCode,
+		RunID:     result.RunID,
+		CommandID: cmd.ID,
+		Success:   result.Success,
+		Output:    result.Output,
+		Truncated: result.Truncated,
+		Killed:    result.Killed,
+		KilledBy:  result.KilledBy,
+		Attempts:  result.Attempts,
+		Error:     result.Error,
+		ExitCode:  result.ExitCode,
+		Signature: result.Signature,
+		PreHook:   result.PreHook,
+		PostHook:  result.PostHook,
+
+		Diagnostic: result.Diagnostic,
+
+		Data:          result.Data,
+		OutputWarning: result.OutputWarning,
+	}
+}
+
+// PublishResult publishes an execution result to <topic_base>/results/<commandId>
+func (c *Client) PublishResult(commandID string, result *executor.ExecutionResult) {
+	if c.client == nil || !c.client.IsConnected() {
+		c.logger.WithField("command_id", commandID).Debug("MQTT not connected, skipping result publish")
+		return
 	}
+	
+	payload, err := json.Marshal(map[string]interface{}{
+		"commandId": commandID,
+		"runId":     result.RunID,
+		"success":   result.Success,
+		"output":    result.Output,
+		"error":     result.Error,
+		"exitCode":  result.ExitCode,
+		"duration":  result.ExecutionTime.Milliseconds(),
+		"timestamp": time.Now().Format(time.RFC3339),
+		"signature": result.Signature,
+	})
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to marshal execution result")
+		return
+	}
+	
+	topic := resultsTopic(c.config.MQTT, commandID)
+	
+	// Publish asynchronously so execution is not delayed by the broker
+	token := c.client.Publish(topic, 1, false, payload)
+	go func() {
+		if token.Wait() && token.Error() != nil {
+			c.logger.WithError(token.Error()).WithField("topic", topic).Error("Failed to publish execution result")
+		}
+	}()
+}
+
+// responseTopic returns the topic a response to requestID is published on
+func (c *Client) responseTopic(requestID string) string {
+	return responseTopic(c.config.MQTT, requestID)
 }
 
 // publishError publishes an error response
-func (c *Client) publishError(client mqtt.Client, code, message, originalTopic string) {
+func (c *Client) publishError(client mqtt.Client, requestID, code, message, originalTopic string) {
 	errorResponse := map[string]interface{}{
-		"success": false,
+		"requestId": requestID,
+		"success":   false,
 		"error": map[string]string{
 			"code":    code,
 			"message": message,
@@ -255,9 +584,8 @@ func (c *Client) publishError(client mqtt.Client, code, message, originalTopic s
 	}
 	
 	responseData, _ := json.Marshal(errorResponse)
-	responseTopic := fmt.Sprintf("%s/response", c.config.MQTT.TopicBase)
 	
-	if token := client.Publish(responseTopic, 1, false, responseData); token.Wait() && token.Error() != nil {
+	if token := client.Publish(c.responseTopic(requestID), 1, false, responseData); token.Wait() && token.Error() != nil {
 		c.logger.WithError(token.Error()).Error("Failed to publish error response")
 	}
 }
\ No newline at end of file
@@ -0,0 +1,60 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+)
+
+func TestExecuteAllowedClients(t *testing.T) {
+	broker := startTestBroker(t)
+	c := startBrokerClient(t, broker,
+		&entity.Command{ID: "by-id", Name: "By ID", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedClientIDs: []string{"kitchen"}}},
+		// MQTT has no client IP, so an IP-only restriction rejects every request
+		&entity.Command{ID: "by-ip", Name: "By IP", Command: "echo ok", Platform: runtime.GOOS, Security: &entity.SecurityConfig{Whitelist: true, AllowedIPs: []string{"0.0.0.0/0"}}},
+	)
+	sub := subscribeTestBroker(t, broker, responseTopic(c.config.MQTT, "+"))
+
+	tests := []struct {
+		name        string
+		id          string
+		clientID    string
+		wantSuccess bool
+	}{
+		{"allowed client ID", "by-id", "kitchen", true},
+		{"other client ID", "by-id", "garage", false},
+		{"no client ID", "by-id", "", false},
+		{"IP restriction", "by-ip", "kitchen", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := json.Marshal(ExecuteRequest{RequestID: tt.name, CommandID: tt.id, ClientID: tt.clientID})
+			if token := sub.Publish(executeTopic(c.config.MQTT), 1, false, payload); token.Wait() && token.Error() != nil {
+				t.Fatalf("publish: %v", token.Error())
+			}
+
+			var resp ExecuteResponse
+			select {
+			case msg := <-sub.messages:
+				if err := json.Unmarshal(msg.Payload(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no response")
+			}
+			if resp.RequestID != tt.name {
+				t.Fatalf("response to %q, want %q", resp.RequestID, tt.name)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("success = %v, want %v: %+v", resp.Success, tt.wantSuccess, resp)
+			}
+			if !tt.wantSuccess && resp.Error != common.ErrClientNotAllowed.Error() {
+				t.Errorf("error = %q, want %q", resp.Error, common.ErrClientNotAllowed.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestExecuteCommandThrottlesWrongPins(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.PinRequired = true
+	cfg.Security.Pin = "4321"
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	if err := repo.Create(context.Background(), &entity.Command{ID: "pinned", Name: "Pinned", Command: "echo ok", Security: &entity.SecurityConfig{RequirePin: true}}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	c := NewClient(cfg, logger, service.NewCommandService(repo), nil, security.NewService(cfg, logger), nil)
+
+	for i := 0; i < 5; i++ {
+		resp := c.executeCommand(context.Background(), ExecuteRequest{CommandID: "pinned", Pin: "0000"})
+		if resp.Success || resp.Error != "Invalid or missing PIN" {
+			t.Fatalf("wrong PIN %d: response = %+v", i+1, resp)
+		}
+	}
+
+	// Past the limit the right PIN is not checked either
+	resp := c.executeCommand(context.Background(), ExecuteRequest{CommandID: "pinned", Pin: "4321"})
+	if resp.Success || !strings.Contains(resp.Error, "too many wrong PINs") {
+		t.Fatalf("right PIN after the limit: response = %+v, want throttled", resp)
+	}
+}
@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+func TestPublishResults(t *testing.T) {
+	broker := startTestBroker(t)
+	c := startBrokerClient(t, broker,
+		&entity.Command{ID: "opted-in", Name: "Opted in", Command: "echo opted-in", Platform: runtime.GOOS, PublishResults: true},
+		&entity.Command{ID: "quiet", Name: "Quiet", Command: "echo quiet", Platform: runtime.GOOS},
+	)
+	results := subscribeTestBroker(t, broker, resultsTopic(c.config.MQTT, "+"))
+
+	tests := []struct {
+		name        string
+		publishAll  bool
+		commandID   string
+		wantPublish bool
+	}{
+		{"opted-in command", false, "opted-in", true},
+		{"command not opted in", false, "quiet", false},
+		{"publish_results covers every command", true, "quiet", true},
+		{"publish_results with opted-in command", true, "opted-in", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.executorService.SetResultPublisher(c, tt.publishAll)
+			resp := c.executeCommand(context.Background(), ExecuteRequest{CommandID: tt.commandID})
+			if !resp.Success {
+				t.Fatalf("execute failed: %+v", resp)
+			}
+
+			select {
+			case msg := <-results.messages:
+				if !tt.wantPublish {
+					t.Fatalf("unexpected result published on %s", msg.Topic())
+				}
+				if want := "lazy-ctrl/test/results/" + tt.commandID; msg.Topic() != want {
+					t.Errorf("topic = %q, want %q", msg.Topic(), want)
+				}
+				var payload struct {
+					CommandID string `json:"commandId"`
+					RunID     string `json:"runId"`
+					Success   bool   `json:"success"`
+					Output    string `json:"output"`
+					ExitCode  int    `json:"exitCode"`
+				}
+				if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
+					t.Fatalf("decode result: %v", err)
+				}
+				if payload.CommandID != tt.commandID || payload.RunID != resp.RunID || !payload.Success ||
+					payload.ExitCode != 0 || strings.TrimSpace(payload.Output) != tt.commandID {
+					t.Errorf("result = %+v, want the successful run %s of %s", payload, resp.RunID, tt.commandID)
+				}
+			case <-time.After(300 * time.Millisecond):
+				if tt.wantPublish {
+					t.Fatal("no result published")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package mqtt
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// waitStatus polls the client status until it is want or a few seconds pass
+func waitStatus(t *testing.T, c *Client, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Status() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %q, want %q", c.Status(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientStatus(t *testing.T) {
+	broker := startTestBroker(t)
+	c := startBrokerClient(t, broker)
+	waitStatus(t, c, common.StatusHealthy)
+
+	// Paho keeps reconnecting, which must not pass for a connection
+	broker.close()
+	waitStatus(t, c, common.StatusUnhealthy)
+
+	c.Stop()
+	waitStatus(t, c, common.StatusStopping)
+}
+
+func TestClientStatusBrokerUnreachable(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	cfg := &config.Config{}
+	cfg.MQTT.Broker = host
+	cfg.MQTT.Port, _ = strconv.Atoi(port)
+	cfg.MQTT.ClientID = "agent"
+	cfg.MQTT.TopicBase = "lazy-ctrl/test"
+	c := NewClient(cfg, logger, nil, nil, nil, nil)
+	if got := c.Status(); got != common.StatusStarting {
+		t.Errorf("status before start = %q, want %q", got, common.StatusStarting)
+	}
+	if err := c.Start(); err == nil {
+		t.Fatal("start succeeded without a broker")
+	}
+	defer c.Stop()
+	if got := c.Status(); got != common.StatusUnhealthy {
+		t.Errorf("status = %q, want %q", got, common.StatusUnhealthy)
+	}
+}
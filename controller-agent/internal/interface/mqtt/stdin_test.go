@@ -0,0 +1,57 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+)
+
+func TestExecuteStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is cat")
+	}
+	broker := startTestBroker(t)
+	c := startBrokerClient(t, broker, &entity.Command{ID: "cat", Name: "Cat", Command: "cat", Platform: runtime.GOOS})
+	c.executorService.SetMaxStdinSize(16)
+	sub := subscribeTestBroker(t, broker, responseTopic(c.config.MQTT, "+"))
+
+	tests := []struct {
+		name        string
+		stdin       string
+		wantSuccess bool
+		wantOutput  string
+		wantError   string
+	}{
+		{"stdin echoed", "piped input", true, "piped input", ""},
+		{"no stdin", "", true, "", ""},
+		{"stdin over the limit", strings.Repeat("x", 17), false, "", "stdin exceeds maximum size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := json.Marshal(ExecuteRequest{RequestID: tt.name, CommandID: "cat", Stdin: tt.stdin})
+			if token := sub.Publish(executeTopic(c.config.MQTT), 1, false, payload); token.Wait() && token.Error() != nil {
+				t.Fatalf("publish: %v", token.Error())
+			}
+
+			var resp ExecuteResponse
+			select {
+			case msg := <-sub.messages:
+				if err := json.Unmarshal(msg.Payload(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no response")
+			}
+			if resp.RequestID != tt.name {
+				t.Fatalf("response to %q, want %q", resp.RequestID, tt.name)
+			}
+			if resp.Success != tt.wantSuccess || resp.Output != tt.wantOutput || !strings.Contains(resp.Error, tt.wantError) {
+				t.Errorf("response = %+v, want success %v, output %q, error containing %q", resp, tt.wantSuccess, tt.wantOutput, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/entity"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/infrastructure"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/command/service"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/infrastructure/security"
+)
+
+func TestExecuteCommandHidesOtherTenants(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.Pin = "4321"
+	cfg.Commands.Tenancy = true
+	cfg.Commands.Tenants = []config.TenantToken{{Token: "alice-token", UserID: "alice"}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := infrastructure.NewFileCommandRepository(filepath.Join(t.TempDir(), "commands.json"))
+	if err := repo.Create(context.Background(), &entity.Command{ID: "bob-cmd", Name: "Bob", Command: "echo bob", UserID: "bob"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	c := NewClient(cfg, logger, service.NewCommandService(repo), nil, security.NewService(cfg, logger), nil)
+
+	tests := []struct {
+		name string
+		req  ExecuteRequest
+	}{
+		{"other tenant's token", ExecuteRequest{CommandID: "bob-cmd", TenantToken: "alice-token"}},
+		{"no token", ExecuteRequest{CommandID: "bob-cmd"}},
+		{"forged token", ExecuteRequest{CommandID: "bob-cmd", TenantToken: "bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := c.executeCommand(context.Background(), tt.req)
+			if resp.Success || resp.Error != "Command not found: bob-cmd" {
+				t.Errorf("response = %+v, want command not found", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+// TopicLayout lists the effective topics for an MQTT configuration
+type TopicLayout struct {
+	Subscribe []string `json:"subscribe"`
+	Publish   []string `json:"publish"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// Topics derives the topics the client subscribes and publishes to, using the
+// "+" wildcard for per-request and per-command levels, and warns about values
+// that are likely misconfigured
+func Topics(cfg config.MQTTConfig) TopicLayout {
+	return TopicLayout{
+		Subscribe: []string{
+			executeTopic(cfg),
+			commandsTopic(cfg),
+		},
+		Publish: []string{
+			responseTopic(cfg, "+"),
+			resultsTopic(cfg, "+"),
+			statusTopic(cfg),
+		},
+		Warnings: lintTopics(cfg),
+	}
+}
+
+// executeTopic returns the topic execute requests are received on
+func executeTopic(cfg config.MQTTConfig) string {
+	return fmt.Sprintf("%s/%s", cfg.TopicBase, common.MQTTTopicExecute)
+}
+
+// commandsTopic returns the topic command list requests are received on
+func commandsTopic(cfg config.MQTTConfig) string {
+	return fmt.Sprintf("%s/%s", cfg.TopicBase, common.MQTTTopicCommands)
+}
+
+// statusTopic returns the presence topic
+func statusTopic(cfg config.MQTTConfig) string {
+	if cfg.StatusTopic != "" {
+		return cfg.StatusTopic
+	}
+	return fmt.Sprintf("%s/%s", cfg.TopicBase, common.MQTTTopicStatus)
+}
+
+// responseTopic returns the topic a response to requestID is published on
+func responseTopic(cfg config.MQTTConfig, requestID string) string {
+	if cfg.PerRequestResponse {
+		return fmt.Sprintf("%s/%s/%s", cfg.TopicBase, common.MQTTTopicResponse, requestID)
+	}
+	return fmt.Sprintf("%s/%s", cfg.TopicBase, common.MQTTTopicResponse)
+}
+
+// resultsTopic returns the topic results of commandID are published on
+func resultsTopic(cfg config.MQTTConfig, commandID string) string {
+	return fmt.Sprintf("%s/%s/%s", cfg.TopicBase, common.MQTTTopicResults, commandID)
+}
+
+// lintTopics reports topic settings that will not work as intended
+func lintTopics(cfg config.MQTTConfig) []string {
+	var warnings []string
+
+	if cfg.TopicBase == "" {
+		warnings = append(warnings, "topic_base is empty, so every topic starts with an empty level (\"/execute\")")
+	} else {
+		warnings = append(warnings, lintTopic("topic_base", cfg.TopicBase)...)
+	}
+	if cfg.StatusTopic != "" {
+		warnings = append(warnings, lintTopic("status_topic", cfg.StatusTopic)...)
+	}
+
+	return warnings
+}
+
+// lintTopic checks a single configured topic
+func lintTopic(name, topic string) []string {
+	var warnings []string
+
+	if strings.TrimSpace(topic) != topic {
+		warnings = append(warnings, fmt.Sprintf("%s %q has leading or trailing whitespace", name, topic))
+	}
+	if strings.HasPrefix(topic, "/") {
+		warnings = append(warnings, fmt.Sprintf("%s %q starts with '/', adding an empty first level", name, topic))
+	}
+	if strings.HasSuffix(topic, "/") {
+		warnings = append(warnings, fmt.Sprintf("%s %q ends with '/', producing an empty level (\"//\")", name, topic))
+	}
+	if strings.Contains(strings.Trim(topic, "/"), "//") {
+		warnings = append(warnings, fmt.Sprintf("%s %q contains an empty level", name, topic))
+	}
+	if strings.ContainsAny(topic, "+#") {
+		warnings = append(warnings, fmt.Sprintf("%s %q contains wildcards, which cannot be published to", name, topic))
+	}
+	if strings.HasPrefix(topic, "$") {
+		warnings = append(warnings, fmt.Sprintf("%s %q starts with '$', which brokers reserve for system topics", name, topic))
+	}
+
+	return warnings
+}
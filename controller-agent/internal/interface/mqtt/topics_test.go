@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+)
+
+func TestTopics(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           config.MQTTConfig
+		wantSubscribe []string
+		wantPublish   []string
+	}{
+		{
+			name:          "shared response topic",
+			cfg:           config.MQTTConfig{TopicBase: "lazy-ctrl/pc"},
+			wantSubscribe: []string{"lazy-ctrl/pc/execute", "lazy-ctrl/pc/commands"},
+			wantPublish:   []string{"lazy-ctrl/pc/response", "lazy-ctrl/pc/results/+", "lazy-ctrl/pc/status"},
+		},
+		{
+			name:          "per-request response topic",
+			cfg:           config.MQTTConfig{TopicBase: "lazy-ctrl/pc", PerRequestResponse: true},
+			wantSubscribe: []string{"lazy-ctrl/pc/execute", "lazy-ctrl/pc/commands"},
+			wantPublish:   []string{"lazy-ctrl/pc/response/+", "lazy-ctrl/pc/results/+", "lazy-ctrl/pc/status"},
+		},
+		{
+			name:          "custom status topic",
+			cfg:           config.MQTTConfig{TopicBase: "home/pc", StatusTopic: "presence/pc"},
+			wantSubscribe: []string{"home/pc/execute", "home/pc/commands"},
+			wantPublish:   []string{"home/pc/response", "home/pc/results/+", "presence/pc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := Topics(tt.cfg)
+			if !reflect.DeepEqual(layout.Subscribe, tt.wantSubscribe) {
+				t.Errorf("subscribe = %q, want %q", layout.Subscribe, tt.wantSubscribe)
+			}
+			if !reflect.DeepEqual(layout.Publish, tt.wantPublish) {
+				t.Errorf("publish = %q, want %q", layout.Publish, tt.wantPublish)
+			}
+			if len(layout.Warnings) != 0 {
+				t.Errorf("warnings = %q, want none", layout.Warnings)
+			}
+		})
+	}
+}
+
+func TestTopicsWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MQTTConfig
+		want []string // substrings, one per expected warning
+	}{
+		{"valid", config.MQTTConfig{TopicBase: "lazy-ctrl/pc"}, nil},
+		{"empty base", config.MQTTConfig{}, []string{"topic_base is empty"}},
+		{"trailing slash", config.MQTTConfig{TopicBase: "lazy-ctrl/pc/"}, []string{"ends with '/'"}},
+		{"leading slash", config.MQTTConfig{TopicBase: "/lazy-ctrl"}, []string{"starts with '/'"}},
+		{"empty level", config.MQTTConfig{TopicBase: "lazy-ctrl//pc"}, []string{"contains an empty level"}},
+		{"whitespace", config.MQTTConfig{TopicBase: "lazy-ctrl "}, []string{"whitespace"}},
+		{"wildcard", config.MQTTConfig{TopicBase: "lazy-ctrl/+"}, []string{"wildcards"}},
+		{"system topic", config.MQTTConfig{TopicBase: "$SYS/pc"}, []string{"reserve for system topics"}},
+		{"bad status topic", config.MQTTConfig{TopicBase: "lazy-ctrl", StatusTopic: "presence/#"}, []string{"status_topic \"presence/#\" contains wildcards"}},
+		{"several problems", config.MQTTConfig{TopicBase: "/lazy-ctrl/"}, []string{"starts with '/'", "ends with '/'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := Topics(tt.cfg).Warnings
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %d", warnings, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(warnings[i], want) {
+					t.Errorf("warning %d = %q, want it to contain %q", i, warnings[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	grpcserver "github.com/myczh-1/lazy-ctrl-agent/internal/interface/grpc"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// connectMethod is the cloud GatewayService streaming RPC used for the tunnel
+const connectMethod = "/gateway.GatewayService/Connect"
+
+// connectStreamDesc describes the bidirectional Connect stream
+var connectStreamDesc = &grpc.StreamDesc{
+	StreamName:    "Connect",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// tunnelStream is the client side of the Connect stream
+type tunnelStream = grpc.BidiStreamingClient[pb.TunnelMessage, pb.TunnelMessage]
+
+// Client keeps an outbound tunnel to the cloud open so devices behind NAT
+// can receive requests without being dialed directly
+type Client struct {
+	config  *config.Config
+	logger  *logrus.Logger
+	handler pb.ControllerServiceServer
+	token   *AccessToken
+
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	connected atomic.Bool
+}
+
+// NewClient creates a new tunnel client instance
+func NewClient(
+	cfg *config.Config,
+	logger *logrus.Logger,
+	handler pb.ControllerServiceServer,
+	token *AccessToken,
+) *Client {
+	return &Client{
+		config:  cfg,
+		logger:  logger,
+		handler: handler,
+		token:   token,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start connects to the cloud and reconnects with exponential backoff until stopped
+func (c *Client) Start() error {
+	cfg := c.config.Tunnel
+	if cfg.CloudAddress == "" || cfg.DeviceID == "" {
+		return fmt.Errorf("tunnel requires cloud_address and device_id")
+	}
+
+	creds, err := loadClientCredentials(cfg.TLS)
+	if err != nil {
+		return fmt.Errorf("failed to load tunnel TLS credentials: %w", err)
+	}
+
+	minDelay := time.Duration(cfg.ReconnectMin) * time.Second
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	maxDelay := time.Duration(cfg.ReconnectMax) * time.Second
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+
+	delay := minDelay
+	for {
+		established, err := c.runSession(creds)
+		if c.stopped() {
+			return nil
+		}
+
+		if established {
+			delay = minDelay
+		}
+
+		c.logger.WithFields(logrus.Fields{
+			"cloud_address": cfg.CloudAddress,
+			"retry_in":      delay.String(),
+		}).WithError(err).Warn("Tunnel disconnected")
+
+		select {
+		case <-time.After(delay):
+		case <-c.stopCh:
+			return nil
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// Status reports whether the tunnel is registered with the cloud
+func (c *Client) Status() string {
+	if c.stopped() {
+		return common.StatusStopping
+	}
+	if !c.connected.Load() {
+		return common.StatusUnhealthy
+	}
+	return common.StatusHealthy
+}
+
+// Stop closes the tunnel and stops reconnecting
+func (c *Client) Stop() {
+	c.logger.Info("Stopping tunnel client")
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
+
+// stopped reports whether Stop has been called
+func (c *Client) stopped() bool {
+	select {
+	case <-c.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSession runs a single tunnel session and reports whether registration succeeded
+func (c *Client) runSession(creds credentials.TransportCredentials) (bool, error) {
+	cfg := c.config.Tunnel
+
+	conn, err := grpc.NewClient(cfg.CloudAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return false, fmt.Errorf("failed to create tunnel connection: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-c.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	clientStream, err := conn.NewStream(ctx, connectStreamDesc, connectMethod)
+	if err != nil {
+		return false, fmt.Errorf("failed to open tunnel stream: %w", err)
+	}
+	stream := &grpc.GenericClientStream[pb.TunnelMessage, pb.TunnelMessage]{ClientStream: clientStream}
+
+	if err := stream.Send(&pb.TunnelMessage{
+		Payload: &pb.TunnelMessage_Register{
+			Register: &pb.TunnelRegister{
+				DeviceId:    cfg.DeviceID,
+				AccessToken: c.token.Get(),
+				Version:     common.AppVersion,
+				Platform:    runtime.GOOS,
+			},
+		},
+	}); err != nil {
+		return false, fmt.Errorf("failed to send tunnel registration: %w", err)
+	}
+
+	ack, err := stream.Recv()
+	if err != nil {
+		return false, fmt.Errorf("failed to receive tunnel registration: %w", err)
+	}
+	if ack.GetRegisterAck() == nil || !ack.GetRegisterAck().Success {
+		return false, fmt.Errorf("tunnel registration rejected: %s", ack.GetRegisterAck().GetMessage())
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"cloud_address": cfg.CloudAddress,
+		"device_id":     cfg.DeviceID,
+	}).Info("Tunnel established")
+
+	c.connected.Store(true)
+	defer c.connected.Store(false)
+
+	var sendMutex sync.Mutex
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return true, err
+		}
+
+		req := msg.GetRequest()
+		if req == nil {
+			continue
+		}
+
+		go func(requestID string, req *pb.TunnelRequest) {
+			resp := c.handleRequest(ctx, req)
+
+			sendMutex.Lock()
+			defer sendMutex.Unlock()
+			if err := stream.Send(&pb.TunnelMessage{
+				RequestId: requestID,
+				Payload:   &pb.TunnelMessage_Response{Response: resp},
+			}); err != nil {
+				c.logger.WithError(err).Warn("Failed to send tunnel response")
+			}
+		}(msg.RequestId, req)
+	}
+}
+
+// handleRequest dispatches a tunneled request to the local controller service
+func (c *Client) handleRequest(ctx context.Context, req *pb.TunnelRequest) *pb.TunnelResponse {
+	resp := &pb.TunnelResponse{}
+	ctx = grpcserver.WithTunnelCaller(ctx)
+
+	switch r := req.Request.(type) {
+	case *pb.TunnelRequest_ExecuteCommand:
+		result, err := c.handler.ExecuteCommand(ctx, r.ExecuteCommand)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_ExecuteCommand{ExecuteCommand: result}
+		}
+	case *pb.TunnelRequest_ListCommands:
+		result, err := c.handler.ListCommands(ctx, r.ListCommands)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_ListCommands{ListCommands: result}
+		}
+	case *pb.TunnelRequest_HealthCheck:
+		result, err := c.handler.HealthCheck(ctx, r.HealthCheck)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_HealthCheck{HealthCheck: result}
+		}
+	case *pb.TunnelRequest_SyncCommands:
+		result, err := c.handler.SyncCommands(ctx, r.SyncCommands)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_SyncCommands{SyncCommands: result}
+		}
+	case *pb.TunnelRequest_Ping:
+		result, err := c.handler.Ping(ctx, r.Ping)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_Ping{Ping: result}
+		}
+	case *pb.TunnelRequest_UpdateAgent:
+		result, err := c.handler.UpdateAgent(ctx, r.UpdateAgent)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &pb.TunnelResponse_UpdateAgent{UpdateAgent: result}
+		}
+	default:
+		resp.Error = "unsupported tunnel request"
+	}
+
+	return resp
+}
+
+// loadClientCredentials builds the transport credentials used to reach the cloud
+func loadClientCredentials(cfg config.TunnelTLSConfig) (credentials.TransportCredentials, error) {
+	if !cfg.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", cfg.CAFile, err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %w", cfg.CertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
@@ -0,0 +1,164 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/myczh-1/lazy-ctrl-agent/internal/common"
+	"github.com/myczh-1/lazy-ctrl-agent/internal/config"
+	pb "github.com/myczh-1/lazy-ctrl-agent/proto"
+)
+
+// enrollMethod is the cloud GatewayService RPC agents register themselves with
+const enrollMethod = "/gateway.GatewayService/EnrollDevice"
+
+// enrollRetryMin is the delay before retrying a failed enrollment; it doubles
+// up to the enrollment interval
+const enrollRetryMin = 5 * time.Second
+
+// Enroller registers the agent with the cloud on startup and again every
+// interval, so the cloud creates the device and follows address changes
+type Enroller struct {
+	config *config.Config
+	logger *logrus.Logger
+	token  *AccessToken
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewEnroller creates a new enroller instance
+func NewEnroller(cfg *config.Config, logger *logrus.Logger, token *AccessToken) *Enroller {
+	return &Enroller{
+		config: cfg,
+		logger: logger,
+		token:  token,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start enrolls the agent and keeps re-enrolling until stopped
+func (e *Enroller) Start() error {
+	cfg := e.config.Tunnel
+	if cfg.CloudAddress == "" || cfg.DeviceID == "" || e.config.Enrollment.Token == "" {
+		return fmt.Errorf("enrollment requires tunnel cloud_address and device_id and an enrollment token")
+	}
+
+	creds, err := loadClientCredentials(cfg.TLS)
+	if err != nil {
+		return fmt.Errorf("failed to load tunnel TLS credentials: %w", err)
+	}
+	conn, err := grpc.NewClient(cfg.CloudAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to create enrollment connection: %w", err)
+	}
+	defer conn.Close()
+
+	interval := time.Duration(e.config.Enrollment.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	retry := enrollRetryMin
+	for {
+		delay := interval
+		if err := e.enroll(conn); err != nil {
+			e.logger.WithFields(logrus.Fields{
+				"cloud_address": cfg.CloudAddress,
+				"retry_in":      retry.String(),
+			}).WithError(err).Warn("Enrollment failed")
+			delay = retry
+			retry = min(retry*2, interval)
+		} else {
+			retry = enrollRetryMin
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-e.stopCh:
+			return nil
+		}
+	}
+}
+
+// Stop stops re-enrolling
+func (e *Enroller) Stop() {
+	e.logger.Info("Stopping enrollment")
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+	})
+}
+
+// enroll makes a single EnrollDevice call
+func (e *Enroller) enroll(conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	address := e.advertisedAddress()
+	req := &pb.EnrollDeviceRequest{
+		DeviceId:        e.config.Tunnel.DeviceID,
+		DeviceName:      e.config.Enrollment.DeviceName,
+		Address:         address,
+		Platform:        runtime.GOOS,
+		Version:         common.AppVersion,
+		EnrollmentToken: e.config.Enrollment.Token,
+		Metadata:        map[string]string{"arch": runtime.GOARCH},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		req.Metadata["hostname"] = hostname
+	}
+
+	resp := &pb.EnrollDeviceResponse{}
+	if err := conn.Invoke(ctx, enrollMethod, req, resp); err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("enrollment rejected: %s", resp.Message)
+	}
+	if resp.AccessToken != "" {
+		e.token.Set(resp.AccessToken)
+	}
+
+	entry := e.logger.WithFields(logrus.Fields{
+		"device_id": req.DeviceId,
+		"address":   address,
+	})
+	if resp.Created {
+		entry.Info("Device enrolled with the cloud")
+	} else {
+		entry.Debug("Enrollment refreshed")
+	}
+	return nil
+}
+
+// advertisedAddress returns the gRPC address the cloud should dial: the
+// configured one, or the local IP used to reach the cloud with the gRPC port.
+// Devices without a gRPC server advertise none and are reached by tunnel
+func (e *Enroller) advertisedAddress() string {
+	if e.config.Enrollment.Address != "" {
+		return e.config.Enrollment.Address
+	}
+	if !e.config.Server.GRPC.Enabled {
+		return ""
+	}
+
+	// A UDP "connection" sends nothing but picks the outbound interface
+	probe, err := net.Dial("udp", e.config.Tunnel.CloudAddress)
+	if err != nil {
+		e.logger.WithError(err).Warn("Failed to detect the enrollment address")
+		return ""
+	}
+	defer probe.Close()
+
+	ip := probe.LocalAddr().(*net.UDPAddr).IP
+	return net.JoinHostPort(ip.String(), strconv.Itoa(e.config.Server.GRPC.Port))
+}
@@ -0,0 +1,30 @@
+package tunnel
+
+import "sync"
+
+// AccessToken is the token the tunnel registers with. It starts as the
+// configured tunnel.access_token and is replaced by the token the cloud
+// issues on every enrollment, which revokes the previous one
+type AccessToken struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// NewAccessToken creates an access token holding token
+func NewAccessToken(token string) *AccessToken {
+	return &AccessToken{token: token}
+}
+
+// Get returns the current token
+func (t *AccessToken) Get() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token
+}
+
+// Set replaces the token
+func (t *AccessToken) Set(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
@@ -163,11 +163,12 @@ func (w *responseWriter) WriteString(s string) (int, error) {
 // ResponseFormatterMiddleware wraps gin responses with standard format
 func ResponseFormatterMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip formatting for Swagger/docs paths
+		// Skip formatting for Swagger/docs paths and WebSocket upgrades
 		path := c.Request.URL.Path
 		if strings.HasPrefix(path, "/swagger") || 
 		   strings.HasPrefix(path, "/docs") ||
-		   strings.Contains(path, "swagger") {
+		   strings.Contains(path, "swagger") ||
+		   strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
 			c.Next()
 			return
 		}
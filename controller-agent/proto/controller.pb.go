@@ -27,6 +27,9 @@ type ExecuteCommandRequest struct {
 	CommandId      string                 `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`                 // 命令ID
 	Args           []string               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`                                            // 命令参数
 	TimeoutSeconds int32                  `protobuf:"varint,3,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"` // 超时时间(秒)，0表示使用默认超时
+	Stdin          []byte                 `protobuf:"bytes,4,opt,name=stdin,proto3" json:"stdin,omitempty"`                                          // 写入子进程标准输入的数据
+	DryRun         bool                   `protobuf:"varint,5,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`                         // 仅解析和校验命令，不执行
+	CallerRole     string                 `protobuf:"bytes,6,opt,name=caller_role,json=callerRole,proto3" json:"caller_role,omitempty"`              // 云端代为调用的用户在设备上的角色，仅云端调用时生效
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -82,6 +85,27 @@ func (x *ExecuteCommandRequest) GetTimeoutSeconds() int32 {
 	return 0
 }
 
+func (x *ExecuteCommandRequest) GetStdin() []byte {
+	if x != nil {
+		return x.Stdin
+	}
+	return nil
+}
+
+func (x *ExecuteCommandRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ExecuteCommandRequest) GetCallerRole() string {
+	if x != nil {
+		return x.CallerRole
+	}
+	return ""
+}
+
 // 执行命令响应
 type ExecuteCommandResponse struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
@@ -90,6 +114,16 @@ type ExecuteCommandResponse struct {
 	Error           string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`                                               // 错误信息
 	ExitCode        int32                  `protobuf:"varint,4,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`                        // 退出码
 	ExecutionTimeMs int64                  `protobuf:"varint,5,opt,name=execution_time_ms,json=executionTimeMs,proto3" json:"execution_time_ms,omitempty"` // 执行时间(毫秒)
+	RunId           string                 `protobuf:"bytes,6,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`                                  // 执行ID，可用于取消
+	Truncated       bool                   `protobuf:"varint,7,opt,name=truncated,proto3" json:"truncated,omitempty"`                                      // 输出是否因超过大小上限被截断
+	DryRun          bool                   `protobuf:"varint,8,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`                              // 是否为预演(未执行)
+	Command         string                 `protobuf:"bytes,9,opt,name=command,proto3" json:"command,omitempty"`                                           // 预演时解析出的平台命令
+	Args            []string               `protobuf:"bytes,10,rep,name=args,proto3" json:"args,omitempty"`                                                // 预演时实际的进程调用参数
+	Warnings        []string               `protobuf:"bytes,11,rep,name=warnings,proto3" json:"warnings,omitempty"`                                        // 预演时的校验警告
+	Signature       string                 `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`                                      // 执行结果签名(HMAC-SHA256)
+	CommandId       string                 `protobuf:"bytes,13,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`                     // 规范命令ID(以别名调用时返回别名所属的命令ID)
+	DataJson        string                 `protobuf:"bytes,14,opt,name=data_json,json=dataJson,proto3" json:"data_json,omitempty"`                        // 按输出格式(json/lines)解析后的结构化输出，JSON编码
+	OutputWarning   string                 `protobuf:"bytes,15,opt,name=output_warning,json=outputWarning,proto3" json:"output_warning,omitempty"`         // 输出解析失败时的警告，此时仅返回原始输出
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -159,9 +193,80 @@ func (x *ExecuteCommandResponse) GetExecutionTimeMs() int64 {
 	return 0
 }
 
+func (x *ExecuteCommandResponse) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *ExecuteCommandResponse) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *ExecuteCommandResponse) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ExecuteCommandResponse) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ExecuteCommandResponse) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ExecuteCommandResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *ExecuteCommandResponse) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *ExecuteCommandResponse) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *ExecuteCommandResponse) GetDataJson() string {
+	if x != nil {
+		return x.DataJson
+	}
+	return ""
+}
+
+func (x *ExecuteCommandResponse) GetOutputWarning() string {
+	if x != nil {
+		return x.OutputWarning
+	}
+	return ""
+}
+
 // 获取命令列表请求
 type ListCommandsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	CallerRole    string                 `protobuf:"bytes,1,opt,name=caller_role,json=callerRole,proto3" json:"caller_role,omitempty"` // 云端代为调用的用户在设备上的角色，仅云端调用时生效
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -196,13 +301,32 @@ func (*ListCommandsRequest) Descriptor() ([]byte, []int) {
 	return file_proto_controller_proto_rawDescGZIP(), []int{2}
 }
 
+func (x *ListCommandsRequest) GetCallerRole() string {
+	if x != nil {
+		return x.CallerRole
+	}
+	return ""
+}
+
 // 命令信息
 type CommandInfo struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
-	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                                         // 命令ID
-	Description       string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`                                       // 命令描述
-	PlatformSupported bool                   `protobuf:"varint,3,opt,name=platform_supported,json=platformSupported,proto3" json:"platform_supported,omitempty"` // 当前平台是否支持
-	PlatformCommand   string                 `protobuf:"bytes,4,opt,name=platform_command,json=platformCommand,proto3" json:"platform_command,omitempty"`        // 当前平台的实际命令
+	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                                                              // 命令ID
+	Description       string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`                                                            // 命令描述
+	PlatformSupported bool                   `protobuf:"varint,3,opt,name=platform_supported,json=platformSupported,proto3" json:"platform_supported,omitempty"`                      // 当前平台是否支持
+	PlatformCommand   string                 `protobuf:"bytes,4,opt,name=platform_command,json=platformCommand,proto3" json:"platform_command,omitempty"`                             // 当前平台的实际命令
+	Name              string                 `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`                                                                          // 命令名称
+	Category          string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`                                                                  // 命令分类
+	Icon              string                 `protobuf:"bytes,7,opt,name=icon,proto3" json:"icon,omitempty"`                                                                          // 图标
+	Platform          string                 `protobuf:"bytes,8,opt,name=platform,proto3" json:"platform,omitempty"`                                                                  // 目标平台
+	CommandType       string                 `protobuf:"bytes,9,opt,name=command_type,json=commandType,proto3" json:"command_type,omitempty"`                                         // 命令类型
+	Timeout           int32                  `protobuf:"varint,10,opt,name=timeout,proto3" json:"timeout,omitempty"`                                                                  // 超时时间(毫秒)
+	RequiresPin       bool                   `protobuf:"varint,11,opt,name=requires_pin,json=requiresPin,proto3" json:"requires_pin,omitempty"`                                       // 是否需要PIN
+	Whitelisted       bool                   `protobuf:"varint,12,opt,name=whitelisted,proto3" json:"whitelisted,omitempty"`                                                          // 是否在白名单中
+	AdminOnly         bool                   `protobuf:"varint,13,opt,name=admin_only,json=adminOnly,proto3" json:"admin_only,omitempty"`                                             // 是否仅管理员可用
+	WorkingDir        string                 `protobuf:"bytes,14,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`                                           // 工作目录
+	Env               map[string]string      `protobuf:"bytes,15,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 环境变量
+	OutputFormat      string                 `protobuf:"bytes,16,opt,name=output_format,json=outputFormat,proto3" json:"output_format,omitempty"`                                     // 输出格式: text、json 或 lines
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -265,6 +389,90 @@ func (x *CommandInfo) GetPlatformCommand() string {
 	return ""
 }
 
+func (x *CommandInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetIcon() string {
+	if x != nil {
+		return x.Icon
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetCommandType() string {
+	if x != nil {
+		return x.CommandType
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *CommandInfo) GetRequiresPin() bool {
+	if x != nil {
+		return x.RequiresPin
+	}
+	return false
+}
+
+func (x *CommandInfo) GetWhitelisted() bool {
+	if x != nil {
+		return x.Whitelisted
+	}
+	return false
+}
+
+func (x *CommandInfo) GetAdminOnly() bool {
+	if x != nil {
+		return x.AdminOnly
+	}
+	return false
+}
+
+func (x *CommandInfo) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *CommandInfo) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *CommandInfo) GetOutputFormat() string {
+	if x != nil {
+		return x.OutputFormat
+	}
+	return ""
+}
+
 // 获取命令列表响应
 type ListCommandsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -448,9 +656,12 @@ func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
 // 健康检查响应
 type HealthCheckResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`                                     // 状态: "SERVING", "NOT_SERVING"
-	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                                   // 版本信息
-	UptimeSeconds int64                  `protobuf:"varint,3,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"` // 运行时间(秒)
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`                                                                               // 状态: "SERVING", "NOT_SERVING"
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                                                                             // 版本信息
+	UptimeSeconds int64                  `protobuf:"varint,3,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`                                           // 运行时间(秒)
+	System        *SystemInfo            `protobuf:"bytes,4,opt,name=system,proto3" json:"system,omitempty"`                                                                               // 系统信息
+	Memory        map[string]int64       `protobuf:"bytes,5,rep,name=memory,proto3" json:"memory,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`    // 内存统计(字节)
+	Services      map[string]string      `protobuf:"bytes,6,rep,name=services,proto3" json:"services,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 服务状态
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -506,92 +717,2294 @@ func (x *HealthCheckResponse) GetUptimeSeconds() int64 {
 	return 0
 }
 
-var File_proto_controller_proto protoreflect.FileDescriptor
+func (x *HealthCheckResponse) GetSystem() *SystemInfo {
+	if x != nil {
+		return x.System
+	}
+	return nil
+}
 
-const file_proto_controller_proto_rawDesc = "" +
-	"\n" +
-	"\x16proto/controller.proto\x12\n" +
-	"controller\"s\n" +
-	"\x15ExecuteCommandRequest\x12\x1d\n" +
-	"\n" +
-	"command_id\x18\x01 \x01(\tR\tcommandId\x12\x12\n" +
-	"\x04args\x18\x02 \x03(\tR\x04args\x12'\n" +
-	"\x0ftimeout_seconds\x18\x03 \x01(\x05R\x0etimeoutSeconds\"\xa9\x01\n" +
-	"\x16ExecuteCommandResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x16\n" +
-	"\x06output\x18\x02 \x01(\tR\x06output\x12\x14\n" +
-	"\x05error\x18\x03 \x01(\tR\x05error\x12\x1b\n" +
-	"\texit_code\x18\x04 \x01(\x05R\bexitCode\x12*\n" +
-	"\x11execution_time_ms\x18\x05 \x01(\x03R\x0fexecutionTimeMs\"\x15\n" +
-	"\x13ListCommandsRequest\"\x99\x01\n" +
-	"\vCommandInfo\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12 \n" +
-	"\vdescription\x18\x02 \x01(\tR\vdescription\x12-\n" +
-	"\x12platform_supported\x18\x03 \x01(\bR\x11platformSupported\x12)\n" +
-	"\x10platform_command\x18\x04 \x01(\tR\x0fplatformCommand\"K\n" +
-	"\x14ListCommandsResponse\x123\n" +
-	"\bcommands\x18\x01 \x03(\v2\x17.controller.CommandInfoR\bcommands\"\x15\n" +
-	"\x13ReloadConfigRequest\"s\n" +
-	"\x14ReloadConfigResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
-	"\x0fcommands_loaded\x18\x03 \x01(\x05R\x0ecommandsLoaded\"\x14\n" +
-	"\x12HealthCheckRequest\"n\n" +
-	"\x13HealthCheckResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
-	"\aversion\x18\x02 \x01(\tR\aversion\x12%\n" +
-	"\x0euptime_seconds\x18\x03 \x01(\x03R\ruptimeSeconds2\xe2\x02\n" +
-	"\x11ControllerService\x12W\n" +
-	"\x0eExecuteCommand\x12!.controller.ExecuteCommandRequest\x1a\".controller.ExecuteCommandResponse\x12Q\n" +
-	"\fListCommands\x12\x1f.controller.ListCommandsRequest\x1a .controller.ListCommandsResponse\x12Q\n" +
-	"\fReloadConfig\x12\x1f.controller.ReloadConfigRequest\x1a .controller.ReloadConfigResponse\x12N\n" +
-	"\vHealthCheck\x12\x1e.controller.HealthCheckRequest\x1a\x1f.controller.HealthCheckResponseB*Z(github.com/myczh-1/lazy-ctrl-agent/protob\x06proto3"
+func (x *HealthCheckResponse) GetMemory() map[string]int64 {
+	if x != nil {
+		return x.Memory
+	}
+	return nil
+}
 
-var (
-	file_proto_controller_proto_rawDescOnce sync.Once
-	file_proto_controller_proto_rawDescData []byte
-)
+func (x *HealthCheckResponse) GetServices() map[string]string {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
 
-func file_proto_controller_proto_rawDescGZIP() []byte {
-	file_proto_controller_proto_rawDescOnce.Do(func() {
-		file_proto_controller_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_controller_proto_rawDesc), len(file_proto_controller_proto_rawDesc)))
-	})
-	return file_proto_controller_proto_rawDescData
+// 延迟探测请求
+type PingRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ClientTimeUnixNano int64                  `protobuf:"varint,1,opt,name=client_time_unix_nano,json=clientTimeUnixNano,proto3" json:"client_time_unix_nano,omitempty"` // 客户端发送时间(纳秒)
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
-var file_proto_controller_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
-var file_proto_controller_proto_goTypes = []any{
-	(*ExecuteCommandRequest)(nil),  // 0: controller.ExecuteCommandRequest
-	(*ExecuteCommandResponse)(nil), // 1: controller.ExecuteCommandResponse
-	(*ListCommandsRequest)(nil),    // 2: controller.ListCommandsRequest
-	(*CommandInfo)(nil),            // 3: controller.CommandInfo
-	(*ListCommandsResponse)(nil),   // 4: controller.ListCommandsResponse
-	(*ReloadConfigRequest)(nil),    // 5: controller.ReloadConfigRequest
-	(*ReloadConfigResponse)(nil),   // 6: controller.ReloadConfigResponse
-	(*HealthCheckRequest)(nil),     // 7: controller.HealthCheckRequest
-	(*HealthCheckResponse)(nil),    // 8: controller.HealthCheckResponse
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_proto_controller_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
-var file_proto_controller_proto_depIdxs = []int32{
-	3, // 0: controller.ListCommandsResponse.commands:type_name -> controller.CommandInfo
-	0, // 1: controller.ControllerService.ExecuteCommand:input_type -> controller.ExecuteCommandRequest
-	2, // 2: controller.ControllerService.ListCommands:input_type -> controller.ListCommandsRequest
-	5, // 3: controller.ControllerService.ReloadConfig:input_type -> controller.ReloadConfigRequest
-	7, // 4: controller.ControllerService.HealthCheck:input_type -> controller.HealthCheckRequest
-	1, // 5: controller.ControllerService.ExecuteCommand:output_type -> controller.ExecuteCommandResponse
-	4, // 6: controller.ControllerService.ListCommands:output_type -> controller.ListCommandsResponse
-	6, // 7: controller.ControllerService.ReloadConfig:output_type -> controller.ReloadConfigResponse
-	8, // 8: controller.ControllerService.HealthCheck:output_type -> controller.HealthCheckResponse
-	5, // [5:9] is the sub-list for method output_type
-	1, // [1:5] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func init() { file_proto_controller_proto_init() }
-func file_proto_controller_proto_init() {
-	if File_proto_controller_proto != nil {
-		return
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PingRequest) GetClientTimeUnixNano() int64 {
+	if x != nil {
+		return x.ClientTimeUnixNano
+	}
+	return 0
+}
+
+// 延迟探测响应
+type PingResponse struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ClientTimeUnixNano int64                  `protobuf:"varint,1,opt,name=client_time_unix_nano,json=clientTimeUnixNano,proto3" json:"client_time_unix_nano,omitempty"` // 原样返回的客户端时间
+	ServerTimeUnixNano int64                  `protobuf:"varint,2,opt,name=server_time_unix_nano,json=serverTimeUnixNano,proto3" json:"server_time_unix_nano,omitempty"` // 设备处理时间(纳秒)
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_proto_controller_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PingResponse) GetClientTimeUnixNano() int64 {
+	if x != nil {
+		return x.ClientTimeUnixNano
+	}
+	return 0
+}
+
+func (x *PingResponse) GetServerTimeUnixNano() int64 {
+	if x != nil {
+		return x.ServerTimeUnixNano
+	}
+	return 0
+}
+
+// 系统信息
+type SystemInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Os            string                 `protobuf:"bytes,1,opt,name=os,proto3" json:"os,omitempty"`                                          // 操作系统
+	Architecture  string                 `protobuf:"bytes,2,opt,name=architecture,proto3" json:"architecture,omitempty"`                      // CPU架构
+	GoVersion     string                 `protobuf:"bytes,3,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`           // Go版本
+	NumCpu        int32                  `protobuf:"varint,4,opt,name=num_cpu,json=numCpu,proto3" json:"num_cpu,omitempty"`                   // CPU核数
+	NumGoroutine  int32                  `protobuf:"varint,5,opt,name=num_goroutine,json=numGoroutine,proto3" json:"num_goroutine,omitempty"` // goroutine数量
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SystemInfo) Reset() {
+	*x = SystemInfo{}
+	mi := &file_proto_controller_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SystemInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SystemInfo) ProtoMessage() {}
+
+func (x *SystemInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SystemInfo.ProtoReflect.Descriptor instead.
+func (*SystemInfo) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SystemInfo) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetArchitecture() string {
+	if x != nil {
+		return x.Architecture
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *SystemInfo) GetNumCpu() int32 {
+	if x != nil {
+		return x.NumCpu
+	}
+	return 0
+}
+
+func (x *SystemInfo) GetNumGoroutine() int32 {
+	if x != nil {
+		return x.NumGoroutine
+	}
+	return 0
+}
+
+// PIN验证请求
+type VerifyPinRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pin           string                 `protobuf:"bytes,1,opt,name=pin,proto3" json:"pin,omitempty"` // PIN码
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyPinRequest) Reset() {
+	*x = VerifyPinRequest{}
+	mi := &file_proto_controller_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyPinRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyPinRequest) ProtoMessage() {}
+
+func (x *VerifyPinRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyPinRequest.ProtoReflect.Descriptor instead.
+func (*VerifyPinRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *VerifyPinRequest) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+// PIN验证响应
+type VerifyPinResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"` // 验证是否成功
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`  // 响应消息
+	Valid         bool                   `protobuf:"varint,3,opt,name=valid,proto3" json:"valid,omitempty"`     // PIN是否有效
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyPinResponse) Reset() {
+	*x = VerifyPinResponse{}
+	mi := &file_proto_controller_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyPinResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyPinResponse) ProtoMessage() {}
+
+func (x *VerifyPinResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyPinResponse.ProtoReflect.Descriptor instead.
+func (*VerifyPinResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *VerifyPinResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *VerifyPinResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *VerifyPinResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+// 获取版本信息请求
+type GetVersionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVersionRequest) Reset() {
+	*x = GetVersionRequest{}
+	mi := &file_proto_controller_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVersionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionRequest) ProtoMessage() {}
+
+func (x *GetVersionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionRequest.ProtoReflect.Descriptor instead.
+func (*GetVersionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{14}
+}
+
+// 获取版本信息响应
+type GetVersionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`                        // 请求是否成功
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`                         // 响应消息
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`                         // 版本号
+	BuildTime     string                 `protobuf:"bytes,4,opt,name=build_time,json=buildTime,proto3" json:"build_time,omitempty"`    // 构建时间
+	CommitHash    string                 `protobuf:"bytes,5,opt,name=commit_hash,json=commitHash,proto3" json:"commit_hash,omitempty"` // Git提交哈希
+	GoVersion     string                 `protobuf:"bytes,6,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`    // Go版本
+	Platform      string                 `protobuf:"bytes,7,opt,name=platform,proto3" json:"platform,omitempty"`                       // 平台信息
+	ApiVersion    string                 `protobuf:"bytes,8,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"` // API版本
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVersionResponse) Reset() {
+	*x = GetVersionResponse{}
+	mi := &file_proto_controller_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVersionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionResponse) ProtoMessage() {}
+
+func (x *GetVersionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionResponse.ProtoReflect.Descriptor instead.
+func (*GetVersionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetVersionResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *GetVersionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetBuildTime() string {
+	if x != nil {
+		return x.BuildTime
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetCommitHash() string {
+	if x != nil {
+		return x.CommitHash
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetApiVersion() string {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return ""
+}
+
+// 获取系统状态请求
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_proto_controller_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{16}
+}
+
+// 获取系统状态响应
+type GetStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`                                                                                                           // 请求是否成功
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`                                                                                                            // 响应消息
+	Online        bool                   `protobuf:"varint,3,opt,name=online,proto3" json:"online,omitempty"`                                                                                                             // 是否在线
+	UptimeSeconds int64                  `protobuf:"varint,4,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`                                                                          // 运行时间(秒)
+	CpuUsage      float64                `protobuf:"fixed64,5,opt,name=cpu_usage,json=cpuUsage,proto3" json:"cpu_usage,omitempty"`                                                                                        // CPU使用率
+	MemoryUsage   float64                `protobuf:"fixed64,6,opt,name=memory_usage,json=memoryUsage,proto3" json:"memory_usage,omitempty"`                                                                               // 内存使用率
+	DiskUsage     float64                `protobuf:"fixed64,7,opt,name=disk_usage,json=diskUsage,proto3" json:"disk_usage,omitempty"`                                                                                     // 磁盘使用率
+	SystemInfo    map[string]string      `protobuf:"bytes,8,rep,name=system_info,json=systemInfo,proto3" json:"system_info,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`          // 系统信息
+	ServiceStatus map[string]string      `protobuf:"bytes,9,rep,name=service_status,json=serviceStatus,proto3" json:"service_status,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 服务状态
+	LastSeen      int64                  `protobuf:"varint,10,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`                                                                                        // 最后活跃时间戳
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	mi := &file_proto_controller_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetStatusResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetCpuUsage() float64 {
+	if x != nil {
+		return x.CpuUsage
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetMemoryUsage() float64 {
+	if x != nil {
+		return x.MemoryUsage
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetDiskUsage() float64 {
+	if x != nil {
+		return x.DiskUsage
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetSystemInfo() map[string]string {
+	if x != nil {
+		return x.SystemInfo
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetServiceStatus() map[string]string {
+	if x != nil {
+		return x.ServiceStatus
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+// 日志跟踪请求
+type TailLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pin           string                 `protobuf:"bytes,1,opt,name=pin,proto3" json:"pin,omitempty"` // 管理员PIN
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TailLogsRequest) Reset() {
+	*x = TailLogsRequest{}
+	mi := &file_proto_controller_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TailLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TailLogsRequest) ProtoMessage() {}
+
+func (x *TailLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TailLogsRequest.ProtoReflect.Descriptor instead.
+func (*TailLogsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TailLogsRequest) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+// 日志行
+type LogLine struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          string                 `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`            // 日志内容(不含换行符)
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // 读取时间戳
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogLine) Reset() {
+	*x = LogLine{}
+	mi := &file_proto_controller_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogLine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogLine) ProtoMessage() {}
+
+func (x *LogLine) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogLine.ProtoReflect.Descriptor instead.
+func (*LogLine) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *LogLine) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+func (x *LogLine) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// 同步命令请求，命令按ID新增或覆盖，设备本地的其他命令保持不变
+type SyncCommandsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Commands      []*CommandInfo         `protobuf:"bytes,1,rep,name=commands,proto3" json:"commands,omitempty"`                       // 云端保存的设备命令，platform_command 为命令内容
+	RemovedIds    []string               `protobuf:"bytes,2,rep,name=removed_ids,json=removedIds,proto3" json:"removed_ids,omitempty"` // 云端已删除的命令ID
+	Hash          string                 `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`                               // 命令集合的哈希，与上次同步相同时设备跳过同步
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncCommandsRequest) Reset() {
+	*x = SyncCommandsRequest{}
+	mi := &file_proto_controller_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncCommandsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncCommandsRequest) ProtoMessage() {}
+
+func (x *SyncCommandsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncCommandsRequest.ProtoReflect.Descriptor instead.
+func (*SyncCommandsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SyncCommandsRequest) GetCommands() []*CommandInfo {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *SyncCommandsRequest) GetRemovedIds() []string {
+	if x != nil {
+		return x.RemovedIds
+	}
+	return nil
+}
+
+func (x *SyncCommandsRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+// 同步命令响应
+type SyncCommandsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Skipped       bool                   `protobuf:"varint,3,opt,name=skipped,proto3" json:"skipped,omitempty"` // 哈希未变化，未做修改
+	Created       int32                  `protobuf:"varint,4,opt,name=created,proto3" json:"created,omitempty"` // 新增的命令数量
+	Updated       int32                  `protobuf:"varint,5,opt,name=updated,proto3" json:"updated,omitempty"` // 更新的命令数量
+	Removed       int32                  `protobuf:"varint,6,opt,name=removed,proto3" json:"removed,omitempty"` // 删除的命令数量
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncCommandsResponse) Reset() {
+	*x = SyncCommandsResponse{}
+	mi := &file_proto_controller_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncCommandsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncCommandsResponse) ProtoMessage() {}
+
+func (x *SyncCommandsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncCommandsResponse.ProtoReflect.Descriptor instead.
+func (*SyncCommandsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *SyncCommandsResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SyncCommandsResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SyncCommandsResponse) GetSkipped() bool {
+	if x != nil {
+		return x.Skipped
+	}
+	return false
+}
+
+func (x *SyncCommandsResponse) GetCreated() int32 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *SyncCommandsResponse) GetUpdated() int32 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *SyncCommandsResponse) GetRemoved() int32 {
+	if x != nil {
+		return x.Removed
+	}
+	return 0
+}
+
+// agent自更新请求
+type UpdateAgentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`             // 新程序的下载地址(可为带签名的临时URL)
+	Sha256        string                 `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`       // 新程序的SHA-256(十六进制)
+	Signature     string                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"` // 对SHA-256摘要与版本号拼接后的Ed25519签名(base64)，由 update.public_key 校验
+	Version       string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`     // 新版本号，须高于当前版本，用于状态报告
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateAgentRequest) Reset() {
+	*x = UpdateAgentRequest{}
+	mi := &file_proto_controller_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateAgentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateAgentRequest) ProtoMessage() {}
+
+func (x *UpdateAgentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateAgentRequest.ProtoReflect.Descriptor instead.
+func (*UpdateAgentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *UpdateAgentRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *UpdateAgentRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *UpdateAgentRequest) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *UpdateAgentRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+// agent自更新响应，更新在后台进行，进度与结果见健康检查 services["agent_update"]
+type UpdateAgentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"` // 已开始更新
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateAgentResponse) Reset() {
+	*x = UpdateAgentResponse{}
+	mi := &file_proto_controller_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateAgentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateAgentResponse) ProtoMessage() {}
+
+func (x *UpdateAgentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateAgentResponse.ProtoReflect.Descriptor instead.
+func (*UpdateAgentResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *UpdateAgentResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *UpdateAgentResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// 文件上传分块，首块携带路径与PIN，之后只需携带数据
+type UploadFileChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`            // 目标路径，相对路径基于第一个允许目录
+	Pin           string                 `protobuf:"bytes,2,opt,name=pin,proto3" json:"pin,omitempty"`              // 管理员PIN
+	Overwrite     bool                   `protobuf:"varint,3,opt,name=overwrite,proto3" json:"overwrite,omitempty"` // 是否覆盖已存在的文件
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`            // 文件内容
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadFileChunk) Reset() {
+	*x = UploadFileChunk{}
+	mi := &file_proto_controller_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileChunk) ProtoMessage() {}
+
+func (x *UploadFileChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileChunk.ProtoReflect.Descriptor instead.
+func (*UploadFileChunk) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *UploadFileChunk) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *UploadFileChunk) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+func (x *UploadFileChunk) GetOverwrite() bool {
+	if x != nil {
+		return x.Overwrite
+	}
+	return false
+}
+
+func (x *UploadFileChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// 文件上传响应
+type UploadFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`     // 写入的绝对路径
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`    // 文件大小(字节)
+	Sha256        string                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"` // 文件SHA-256(十六进制)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadFileResponse) Reset() {
+	*x = UploadFileResponse{}
+	mi := &file_proto_controller_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileResponse) ProtoMessage() {}
+
+func (x *UploadFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileResponse.ProtoReflect.Descriptor instead.
+func (*UploadFileResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *UploadFileResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *UploadFileResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadFileResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+// 文件下载请求
+type DownloadFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"` // 文件路径，相对路径基于第一个允许目录
+	Pin           string                 `protobuf:"bytes,2,opt,name=pin,proto3" json:"pin,omitempty"`   // 管理员PIN
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadFileRequest) Reset() {
+	*x = DownloadFileRequest{}
+	mi := &file_proto_controller_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadFileRequest) ProtoMessage() {}
+
+func (x *DownloadFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadFileRequest.ProtoReflect.Descriptor instead.
+func (*DownloadFileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DownloadFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DownloadFileRequest) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+// 文件下载分块，首块携带文件大小
+type FileChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`  // 文件内容
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"` // 文件总大小(字节)，仅首块设置
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileChunk) Reset() {
+	*x = FileChunk{}
+	mi := &file_proto_controller_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileChunk) ProtoMessage() {}
+
+func (x *FileChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileChunk.ProtoReflect.Descriptor instead.
+func (*FileChunk) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *FileChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *FileChunk) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+// 隧道消息，设备与云端双向传输
+type TunnelMessage struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // 请求ID，用于匹配请求与响应
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*TunnelMessage_Register
+	//	*TunnelMessage_RegisterAck
+	//	*TunnelMessage_Request
+	//	*TunnelMessage_Response
+	Payload       isTunnelMessage_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TunnelMessage) Reset() {
+	*x = TunnelMessage{}
+	mi := &file_proto_controller_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TunnelMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelMessage) ProtoMessage() {}
+
+func (x *TunnelMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelMessage.ProtoReflect.Descriptor instead.
+func (*TunnelMessage) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TunnelMessage) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *TunnelMessage) GetPayload() isTunnelMessage_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *TunnelMessage) GetRegister() *TunnelRegister {
+	if x != nil {
+		if x, ok := x.Payload.(*TunnelMessage_Register); ok {
+			return x.Register
+		}
+	}
+	return nil
+}
+
+func (x *TunnelMessage) GetRegisterAck() *TunnelRegisterAck {
+	if x != nil {
+		if x, ok := x.Payload.(*TunnelMessage_RegisterAck); ok {
+			return x.RegisterAck
+		}
+	}
+	return nil
+}
+
+func (x *TunnelMessage) GetRequest() *TunnelRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*TunnelMessage_Request); ok {
+			return x.Request
+		}
+	}
+	return nil
+}
+
+func (x *TunnelMessage) GetResponse() *TunnelResponse {
+	if x != nil {
+		if x, ok := x.Payload.(*TunnelMessage_Response); ok {
+			return x.Response
+		}
+	}
+	return nil
+}
+
+type isTunnelMessage_Payload interface {
+	isTunnelMessage_Payload()
+}
+
+type TunnelMessage_Register struct {
+	Register *TunnelRegister `protobuf:"bytes,2,opt,name=register,proto3,oneof"` // 设备注册（设备 -> 云端，首条消息）
+}
+
+type TunnelMessage_RegisterAck struct {
+	RegisterAck *TunnelRegisterAck `protobuf:"bytes,3,opt,name=register_ack,json=registerAck,proto3,oneof"` // 注册结果（云端 -> 设备）
+}
+
+type TunnelMessage_Request struct {
+	Request *TunnelRequest `protobuf:"bytes,4,opt,name=request,proto3,oneof"` // 转发请求（云端 -> 设备）
+}
+
+type TunnelMessage_Response struct {
+	Response *TunnelResponse `protobuf:"bytes,5,opt,name=response,proto3,oneof"` // 请求响应（设备 -> 云端）
+}
+
+func (*TunnelMessage_Register) isTunnelMessage_Payload() {}
+
+func (*TunnelMessage_RegisterAck) isTunnelMessage_Payload() {}
+
+func (*TunnelMessage_Request) isTunnelMessage_Payload() {}
+
+func (*TunnelMessage_Response) isTunnelMessage_Payload() {}
+
+// 隧道注册信息
+type TunnelRegister struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`          // 设备ID
+	AccessToken   string                 `protobuf:"bytes,2,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"` // 设备访问令牌
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`                            // agent版本
+	Platform      string                 `protobuf:"bytes,4,opt,name=platform,proto3" json:"platform,omitempty"`                          // 平台信息
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TunnelRegister) Reset() {
+	*x = TunnelRegister{}
+	mi := &file_proto_controller_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TunnelRegister) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelRegister) ProtoMessage() {}
+
+func (x *TunnelRegister) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelRegister.ProtoReflect.Descriptor instead.
+func (*TunnelRegister) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *TunnelRegister) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *TunnelRegister) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *TunnelRegister) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *TunnelRegister) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+// 隧道注册结果
+type TunnelRegisterAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"` // 注册是否成功
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`  // 响应消息
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TunnelRegisterAck) Reset() {
+	*x = TunnelRegisterAck{}
+	mi := &file_proto_controller_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TunnelRegisterAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelRegisterAck) ProtoMessage() {}
+
+func (x *TunnelRegisterAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelRegisterAck.ProtoReflect.Descriptor instead.
+func (*TunnelRegisterAck) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *TunnelRegisterAck) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *TunnelRegisterAck) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// 通过隧道转发的请求
+type TunnelRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*TunnelRequest_ExecuteCommand
+	//	*TunnelRequest_ListCommands
+	//	*TunnelRequest_HealthCheck
+	//	*TunnelRequest_SyncCommands
+	//	*TunnelRequest_Ping
+	//	*TunnelRequest_UpdateAgent
+	Request       isTunnelRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TunnelRequest) Reset() {
+	*x = TunnelRequest{}
+	mi := &file_proto_controller_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TunnelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelRequest) ProtoMessage() {}
+
+func (x *TunnelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelRequest.ProtoReflect.Descriptor instead.
+func (*TunnelRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *TunnelRequest) GetRequest() isTunnelRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetExecuteCommand() *ExecuteCommandRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_ExecuteCommand); ok {
+			return x.ExecuteCommand
+		}
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetListCommands() *ListCommandsRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_ListCommands); ok {
+			return x.ListCommands
+		}
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetHealthCheck() *HealthCheckRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_HealthCheck); ok {
+			return x.HealthCheck
+		}
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetSyncCommands() *SyncCommandsRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_SyncCommands); ok {
+			return x.SyncCommands
+		}
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetPing() *PingRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_Ping); ok {
+			return x.Ping
+		}
+	}
+	return nil
+}
+
+func (x *TunnelRequest) GetUpdateAgent() *UpdateAgentRequest {
+	if x != nil {
+		if x, ok := x.Request.(*TunnelRequest_UpdateAgent); ok {
+			return x.UpdateAgent
+		}
+	}
+	return nil
+}
+
+type isTunnelRequest_Request interface {
+	isTunnelRequest_Request()
+}
+
+type TunnelRequest_ExecuteCommand struct {
+	ExecuteCommand *ExecuteCommandRequest `protobuf:"bytes,1,opt,name=execute_command,json=executeCommand,proto3,oneof"`
+}
+
+type TunnelRequest_ListCommands struct {
+	ListCommands *ListCommandsRequest `protobuf:"bytes,2,opt,name=list_commands,json=listCommands,proto3,oneof"`
+}
+
+type TunnelRequest_HealthCheck struct {
+	HealthCheck *HealthCheckRequest `protobuf:"bytes,3,opt,name=health_check,json=healthCheck,proto3,oneof"`
+}
+
+type TunnelRequest_SyncCommands struct {
+	SyncCommands *SyncCommandsRequest `protobuf:"bytes,4,opt,name=sync_commands,json=syncCommands,proto3,oneof"`
+}
+
+type TunnelRequest_Ping struct {
+	Ping *PingRequest `protobuf:"bytes,5,opt,name=ping,proto3,oneof"`
+}
+
+type TunnelRequest_UpdateAgent struct {
+	UpdateAgent *UpdateAgentRequest `protobuf:"bytes,6,opt,name=update_agent,json=updateAgent,proto3,oneof"`
+}
+
+func (*TunnelRequest_ExecuteCommand) isTunnelRequest_Request() {}
+
+func (*TunnelRequest_ListCommands) isTunnelRequest_Request() {}
+
+func (*TunnelRequest_HealthCheck) isTunnelRequest_Request() {}
+
+func (*TunnelRequest_SyncCommands) isTunnelRequest_Request() {}
+
+func (*TunnelRequest_Ping) isTunnelRequest_Request() {}
+
+func (*TunnelRequest_UpdateAgent) isTunnelRequest_Request() {}
+
+// 通过隧道返回的响应
+type TunnelResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Error string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"` // 处理失败时的错误信息
+	// Types that are valid to be assigned to Response:
+	//
+	//	*TunnelResponse_ExecuteCommand
+	//	*TunnelResponse_ListCommands
+	//	*TunnelResponse_HealthCheck
+	//	*TunnelResponse_SyncCommands
+	//	*TunnelResponse_Ping
+	//	*TunnelResponse_UpdateAgent
+	Response      isTunnelResponse_Response `protobuf_oneof:"response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TunnelResponse) Reset() {
+	*x = TunnelResponse{}
+	mi := &file_proto_controller_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TunnelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelResponse) ProtoMessage() {}
+
+func (x *TunnelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelResponse.ProtoReflect.Descriptor instead.
+func (*TunnelResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *TunnelResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *TunnelResponse) GetResponse() isTunnelResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetExecuteCommand() *ExecuteCommandResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_ExecuteCommand); ok {
+			return x.ExecuteCommand
+		}
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetListCommands() *ListCommandsResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_ListCommands); ok {
+			return x.ListCommands
+		}
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetHealthCheck() *HealthCheckResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_HealthCheck); ok {
+			return x.HealthCheck
+		}
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetSyncCommands() *SyncCommandsResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_SyncCommands); ok {
+			return x.SyncCommands
+		}
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetPing() *PingResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_Ping); ok {
+			return x.Ping
+		}
+	}
+	return nil
+}
+
+func (x *TunnelResponse) GetUpdateAgent() *UpdateAgentResponse {
+	if x != nil {
+		if x, ok := x.Response.(*TunnelResponse_UpdateAgent); ok {
+			return x.UpdateAgent
+		}
+	}
+	return nil
+}
+
+type isTunnelResponse_Response interface {
+	isTunnelResponse_Response()
+}
+
+type TunnelResponse_ExecuteCommand struct {
+	ExecuteCommand *ExecuteCommandResponse `protobuf:"bytes,2,opt,name=execute_command,json=executeCommand,proto3,oneof"`
+}
+
+type TunnelResponse_ListCommands struct {
+	ListCommands *ListCommandsResponse `protobuf:"bytes,3,opt,name=list_commands,json=listCommands,proto3,oneof"`
+}
+
+type TunnelResponse_HealthCheck struct {
+	HealthCheck *HealthCheckResponse `protobuf:"bytes,4,opt,name=health_check,json=healthCheck,proto3,oneof"`
+}
+
+type TunnelResponse_SyncCommands struct {
+	SyncCommands *SyncCommandsResponse `protobuf:"bytes,5,opt,name=sync_commands,json=syncCommands,proto3,oneof"`
+}
+
+type TunnelResponse_Ping struct {
+	Ping *PingResponse `protobuf:"bytes,6,opt,name=ping,proto3,oneof"`
+}
+
+type TunnelResponse_UpdateAgent struct {
+	UpdateAgent *UpdateAgentResponse `protobuf:"bytes,7,opt,name=update_agent,json=updateAgent,proto3,oneof"`
+}
+
+func (*TunnelResponse_ExecuteCommand) isTunnelResponse_Response() {}
+
+func (*TunnelResponse_ListCommands) isTunnelResponse_Response() {}
+
+func (*TunnelResponse_HealthCheck) isTunnelResponse_Response() {}
+
+func (*TunnelResponse_SyncCommands) isTunnelResponse_Response() {}
+
+func (*TunnelResponse_Ping) isTunnelResponse_Response() {}
+
+func (*TunnelResponse_UpdateAgent) isTunnelResponse_Response() {}
+
+// 设备自注册请求，设备启动时及之后定期发送
+type EnrollDeviceRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId        string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`                                                           // 设备ID
+	DeviceName      string                 `protobuf:"bytes,2,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`                                                     // 设备名称，为空时使用设备ID
+	Address         string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`                                                                             // 云端可直连的 gRPC 地址，为空表示仅通过隧道访问
+	Platform        string                 `protobuf:"bytes,4,opt,name=platform,proto3" json:"platform,omitempty"`                                                                           // 平台信息
+	Version         string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`                                                                             // agent版本
+	EnrollmentToken string                 `protobuf:"bytes,6,opt,name=enrollment_token,json=enrollmentToken,proto3" json:"enrollment_token,omitempty"`                                      // 预共享的注册令牌
+	Metadata        map[string]string      `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 系统信息
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *EnrollDeviceRequest) Reset() {
+	*x = EnrollDeviceRequest{}
+	mi := &file_proto_controller_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnrollDeviceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnrollDeviceRequest) ProtoMessage() {}
+
+func (x *EnrollDeviceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnrollDeviceRequest.ProtoReflect.Descriptor instead.
+func (*EnrollDeviceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *EnrollDeviceRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetDeviceName() string {
+	if x != nil {
+		return x.DeviceName
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetEnrollmentToken() string {
+	if x != nil {
+		return x.EnrollmentToken
+	}
+	return ""
+}
+
+func (x *EnrollDeviceRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// 设备自注册响应
+type EnrollDeviceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	AccessToken   string                 `protobuf:"bytes,3,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"` // 设备访问令牌，用于建立隧道
+	Created       bool                   `protobuf:"varint,4,opt,name=created,proto3" json:"created,omitempty"`                           // 是否为首次注册
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnrollDeviceResponse) Reset() {
+	*x = EnrollDeviceResponse{}
+	mi := &file_proto_controller_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnrollDeviceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnrollDeviceResponse) ProtoMessage() {}
+
+func (x *EnrollDeviceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_controller_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnrollDeviceResponse.ProtoReflect.Descriptor instead.
+func (*EnrollDeviceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_controller_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *EnrollDeviceResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *EnrollDeviceResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *EnrollDeviceResponse) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *EnrollDeviceResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
+var File_proto_controller_proto protoreflect.FileDescriptor
+
+const file_proto_controller_proto_rawDesc = "" +
+	"\n" +
+	"\x16proto/controller.proto\x12\n" +
+	"controller\"\xc3\x01\n" +
+	"\x15ExecuteCommandRequest\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\x12\x12\n" +
+	"\x04args\x18\x02 \x03(\tR\x04args\x12'\n" +
+	"\x0ftimeout_seconds\x18\x03 \x01(\x05R\x0etimeoutSeconds\x12\x14\n" +
+	"\x05stdin\x18\x04 \x01(\fR\x05stdin\x12\x17\n" +
+	"\adry_run\x18\x05 \x01(\bR\x06dryRun\x12\x1f\n" +
+	"\vcaller_role\x18\x06 \x01(\tR\n" +
+	"callerRole\"\xc2\x03\n" +
+	"\x16ExecuteCommandResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x1b\n" +
+	"\texit_code\x18\x04 \x01(\x05R\bexitCode\x12*\n" +
+	"\x11execution_time_ms\x18\x05 \x01(\x03R\x0fexecutionTimeMs\x12\x15\n" +
+	"\x06run_id\x18\x06 \x01(\tR\x05runId\x12\x1c\n" +
+	"\ttruncated\x18\a \x01(\bR\ttruncated\x12\x17\n" +
+	"\adry_run\x18\b \x01(\bR\x06dryRun\x12\x18\n" +
+	"\acommand\x18\t \x01(\tR\acommand\x12\x12\n" +
+	"\x04args\x18\n" +
+	" \x03(\tR\x04args\x12\x1a\n" +
+	"\bwarnings\x18\v \x03(\tR\bwarnings\x12\x1c\n" +
+	"\tsignature\x18\f \x01(\tR\tsignature\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\r \x01(\tR\tcommandId\x12\x1b\n" +
+	"\tdata_json\x18\x0e \x01(\tR\bdataJson\x12%\n" +
+	"\x0eoutput_warning\x18\x0f \x01(\tR\routputWarning\"6\n" +
+	"\x13ListCommandsRequest\x12\x1f\n" +
+	"\vcaller_role\x18\x01 \x01(\tR\n" +
+	"callerRole\"\xcc\x04\n" +
+	"\vCommandInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12-\n" +
+	"\x12platform_supported\x18\x03 \x01(\bR\x11platformSupported\x12)\n" +
+	"\x10platform_command\x18\x04 \x01(\tR\x0fplatformCommand\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\x12\x1a\n" +
+	"\bcategory\x18\x06 \x01(\tR\bcategory\x12\x12\n" +
+	"\x04icon\x18\a \x01(\tR\x04icon\x12\x1a\n" +
+	"\bplatform\x18\b \x01(\tR\bplatform\x12!\n" +
+	"\fcommand_type\x18\t \x01(\tR\vcommandType\x12\x18\n" +
+	"\atimeout\x18\n" +
+	" \x01(\x05R\atimeout\x12!\n" +
+	"\frequires_pin\x18\v \x01(\bR\vrequiresPin\x12 \n" +
+	"\vwhitelisted\x18\f \x01(\bR\vwhitelisted\x12\x1d\n" +
+	"\n" +
+	"admin_only\x18\r \x01(\bR\tadminOnly\x12\x1f\n" +
+	"\vworking_dir\x18\x0e \x01(\tR\n" +
+	"workingDir\x122\n" +
+	"\x03env\x18\x0f \x03(\v2 .controller.CommandInfo.EnvEntryR\x03env\x12#\n" +
+	"\routput_format\x18\x10 \x01(\tR\foutputFormat\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"K\n" +
+	"\x14ListCommandsResponse\x123\n" +
+	"\bcommands\x18\x01 \x03(\v2\x17.controller.CommandInfoR\bcommands\"\x15\n" +
+	"\x13ReloadConfigRequest\"s\n" +
+	"\x14ReloadConfigResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
+	"\x0fcommands_loaded\x18\x03 \x01(\x05R\x0ecommandsLoaded\"\x14\n" +
+	"\x12HealthCheckRequest\"\xa6\x03\n" +
+	"\x13HealthCheckResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12%\n" +
+	"\x0euptime_seconds\x18\x03 \x01(\x03R\ruptimeSeconds\x12.\n" +
+	"\x06system\x18\x04 \x01(\v2\x16.controller.SystemInfoR\x06system\x12C\n" +
+	"\x06memory\x18\x05 \x03(\v2+.controller.HealthCheckResponse.MemoryEntryR\x06memory\x12I\n" +
+	"\bservices\x18\x06 \x03(\v2-.controller.HealthCheckResponse.ServicesEntryR\bservices\x1a9\n" +
+	"\vMemoryEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a;\n" +
+	"\rServicesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"@\n" +
+	"\vPingRequest\x121\n" +
+	"\x15client_time_unix_nano\x18\x01 \x01(\x03R\x12clientTimeUnixNano\"t\n" +
+	"\fPingResponse\x121\n" +
+	"\x15client_time_unix_nano\x18\x01 \x01(\x03R\x12clientTimeUnixNano\x121\n" +
+	"\x15server_time_unix_nano\x18\x02 \x01(\x03R\x12serverTimeUnixNano\"\x9d\x01\n" +
+	"\n" +
+	"SystemInfo\x12\x0e\n" +
+	"\x02os\x18\x01 \x01(\tR\x02os\x12\"\n" +
+	"\farchitecture\x18\x02 \x01(\tR\farchitecture\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x03 \x01(\tR\tgoVersion\x12\x17\n" +
+	"\anum_cpu\x18\x04 \x01(\x05R\x06numCpu\x12#\n" +
+	"\rnum_goroutine\x18\x05 \x01(\x05R\fnumGoroutine\"$\n" +
+	"\x10VerifyPinRequest\x12\x10\n" +
+	"\x03pin\x18\x01 \x01(\tR\x03pin\"]\n" +
+	"\x11VerifyPinResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x14\n" +
+	"\x05valid\x18\x03 \x01(\bR\x05valid\"\x13\n" +
+	"\x11GetVersionRequest\"\xfe\x01\n" +
+	"\x12GetVersionResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x1d\n" +
+	"\n" +
+	"build_time\x18\x04 \x01(\tR\tbuildTime\x12\x1f\n" +
+	"\vcommit_hash\x18\x05 \x01(\tR\n" +
+	"commitHash\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x06 \x01(\tR\tgoVersion\x12\x1a\n" +
+	"\bplatform\x18\a \x01(\tR\bplatform\x12\x1f\n" +
+	"\vapi_version\x18\b \x01(\tR\n" +
+	"apiVersion\"\x12\n" +
+	"\x10GetStatusRequest\"\xac\x04\n" +
+	"\x11GetStatusResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06online\x18\x03 \x01(\bR\x06online\x12%\n" +
+	"\x0euptime_seconds\x18\x04 \x01(\x03R\ruptimeSeconds\x12\x1b\n" +
+	"\tcpu_usage\x18\x05 \x01(\x01R\bcpuUsage\x12!\n" +
+	"\fmemory_usage\x18\x06 \x01(\x01R\vmemoryUsage\x12\x1d\n" +
+	"\n" +
+	"disk_usage\x18\a \x01(\x01R\tdiskUsage\x12N\n" +
+	"\vsystem_info\x18\b \x03(\v2-.controller.GetStatusResponse.SystemInfoEntryR\n" +
+	"systemInfo\x12W\n" +
+	"\x0eservice_status\x18\t \x03(\v20.controller.GetStatusResponse.ServiceStatusEntryR\rserviceStatus\x12\x1b\n" +
+	"\tlast_seen\x18\n" +
+	" \x01(\x03R\blastSeen\x1a=\n" +
+	"\x0fSystemInfoEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a@\n" +
+	"\x12ServiceStatusEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"#\n" +
+	"\x0fTailLogsRequest\x12\x10\n" +
+	"\x03pin\x18\x01 \x01(\tR\x03pin\";\n" +
+	"\aLogLine\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\tR\x04line\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"\x7f\n" +
+	"\x13SyncCommandsRequest\x123\n" +
+	"\bcommands\x18\x01 \x03(\v2\x17.controller.CommandInfoR\bcommands\x12\x1f\n" +
+	"\vremoved_ids\x18\x02 \x03(\tR\n" +
+	"removedIds\x12\x12\n" +
+	"\x04hash\x18\x03 \x01(\tR\x04hash\"\xb2\x01\n" +
+	"\x14SyncCommandsResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\askipped\x18\x03 \x01(\bR\askipped\x12\x18\n" +
+	"\acreated\x18\x04 \x01(\x05R\acreated\x12\x18\n" +
+	"\aupdated\x18\x05 \x01(\x05R\aupdated\x12\x18\n" +
+	"\aremoved\x18\x06 \x01(\x05R\aremoved\"v\n" +
+	"\x12UpdateAgentRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\tR\x06sha256\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\tR\tsignature\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\"K\n" +
+	"\x13UpdateAgentResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"i\n" +
+	"\x0fUploadFileChunk\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03pin\x18\x02 \x01(\tR\x03pin\x12\x1c\n" +
+	"\toverwrite\x18\x03 \x01(\bR\toverwrite\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\"T\n" +
+	"\x12UploadFileResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\";\n" +
+	"\x13DownloadFileRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03pin\x18\x02 \x01(\tR\x03pin\"3\n" +
+	"\tFileChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\"\xa8\x02\n" +
+	"\rTunnelMessage\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x128\n" +
+	"\bregister\x18\x02 \x01(\v2\x1a.controller.TunnelRegisterH\x00R\bregister\x12B\n" +
+	"\fregister_ack\x18\x03 \x01(\v2\x1d.controller.TunnelRegisterAckH\x00R\vregisterAck\x125\n" +
+	"\arequest\x18\x04 \x01(\v2\x19.controller.TunnelRequestH\x00R\arequest\x128\n" +
+	"\bresponse\x18\x05 \x01(\v2\x1a.controller.TunnelResponseH\x00R\bresponseB\t\n" +
+	"\apayload\"\x86\x01\n" +
+	"\x0eTunnelRegister\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12!\n" +
+	"\faccess_token\x18\x02 \x01(\tR\vaccessToken\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x1a\n" +
+	"\bplatform\x18\x04 \x01(\tR\bplatform\"G\n" +
+	"\x11TunnelRegisterAck\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xb1\x03\n" +
+	"\rTunnelRequest\x12L\n" +
+	"\x0fexecute_command\x18\x01 \x01(\v2!.controller.ExecuteCommandRequestH\x00R\x0eexecuteCommand\x12F\n" +
+	"\rlist_commands\x18\x02 \x01(\v2\x1f.controller.ListCommandsRequestH\x00R\flistCommands\x12C\n" +
+	"\fhealth_check\x18\x03 \x01(\v2\x1e.controller.HealthCheckRequestH\x00R\vhealthCheck\x12F\n" +
+	"\rsync_commands\x18\x04 \x01(\v2\x1f.controller.SyncCommandsRequestH\x00R\fsyncCommands\x12-\n" +
+	"\x04ping\x18\x05 \x01(\v2\x17.controller.PingRequestH\x00R\x04ping\x12C\n" +
+	"\fupdate_agent\x18\x06 \x01(\v2\x1e.controller.UpdateAgentRequestH\x00R\vupdateAgentB\t\n" +
+	"\arequest\"\xcf\x03\n" +
+	"\x0eTunnelResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\x12M\n" +
+	"\x0fexecute_command\x18\x02 \x01(\v2\".controller.ExecuteCommandResponseH\x00R\x0eexecuteCommand\x12G\n" +
+	"\rlist_commands\x18\x03 \x01(\v2 .controller.ListCommandsResponseH\x00R\flistCommands\x12D\n" +
+	"\fhealth_check\x18\x04 \x01(\v2\x1f.controller.HealthCheckResponseH\x00R\vhealthCheck\x12G\n" +
+	"\rsync_commands\x18\x05 \x01(\v2 .controller.SyncCommandsResponseH\x00R\fsyncCommands\x12.\n" +
+	"\x04ping\x18\x06 \x01(\v2\x18.controller.PingResponseH\x00R\x04ping\x12D\n" +
+	"\fupdate_agent\x18\a \x01(\v2\x1f.controller.UpdateAgentResponseH\x00R\vupdateAgentB\n" +
+	"\n" +
+	"\bresponse\"\xd6\x02\n" +
+	"\x13EnrollDeviceRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1f\n" +
+	"\vdevice_name\x18\x02 \x01(\tR\n" +
+	"deviceName\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\x12\x1a\n" +
+	"\bplatform\x18\x04 \x01(\tR\bplatform\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\x12)\n" +
+	"\x10enrollment_token\x18\x06 \x01(\tR\x0fenrollmentToken\x12I\n" +
+	"\bmetadata\x18\a \x03(\v2-.controller.EnrollDeviceRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x87\x01\n" +
+	"\x14EnrollDeviceResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12!\n" +
+	"\faccess_token\x18\x03 \x01(\tR\vaccessToken\x12\x18\n" +
+	"\acreated\x18\x04 \x01(\bR\acreated2\xf8\a\n" +
+	"\x11ControllerService\x12W\n" +
+	"\x0eExecuteCommand\x12!.controller.ExecuteCommandRequest\x1a\".controller.ExecuteCommandResponse\x12Q\n" +
+	"\fListCommands\x12\x1f.controller.ListCommandsRequest\x1a .controller.ListCommandsResponse\x12Q\n" +
+	"\fReloadConfig\x12\x1f.controller.ReloadConfigRequest\x1a .controller.ReloadConfigResponse\x12N\n" +
+	"\vHealthCheck\x12\x1e.controller.HealthCheckRequest\x1a\x1f.controller.HealthCheckResponse\x129\n" +
+	"\x04Ping\x12\x17.controller.PingRequest\x1a\x18.controller.PingResponse\x12H\n" +
+	"\tVerifyPin\x12\x1c.controller.VerifyPinRequest\x1a\x1d.controller.VerifyPinResponse\x12K\n" +
+	"\n" +
+	"GetVersion\x12\x1d.controller.GetVersionRequest\x1a\x1e.controller.GetVersionResponse\x12H\n" +
+	"\tGetStatus\x12\x1c.controller.GetStatusRequest\x1a\x1d.controller.GetStatusResponse\x12>\n" +
+	"\bTailLogs\x12\x1b.controller.TailLogsRequest\x1a\x13.controller.LogLine0\x01\x12Q\n" +
+	"\fSyncCommands\x12\x1f.controller.SyncCommandsRequest\x1a .controller.SyncCommandsResponse\x12N\n" +
+	"\vUpdateAgent\x12\x1e.controller.UpdateAgentRequest\x1a\x1f.controller.UpdateAgentResponse\x12K\n" +
+	"\n" +
+	"UploadFile\x12\x1b.controller.UploadFileChunk\x1a\x1e.controller.UploadFileResponse(\x01\x12H\n" +
+	"\fDownloadFile\x12\x1f.controller.DownloadFileRequest\x1a\x15.controller.FileChunk0\x01B*Z(github.com/myczh-1/lazy-ctrl-agent/protob\x06proto3"
+
+var (
+	file_proto_controller_proto_rawDescOnce sync.Once
+	file_proto_controller_proto_rawDescData []byte
+)
+
+func file_proto_controller_proto_rawDescGZIP() []byte {
+	file_proto_controller_proto_rawDescOnce.Do(func() {
+		file_proto_controller_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_controller_proto_rawDesc), len(file_proto_controller_proto_rawDesc)))
+	})
+	return file_proto_controller_proto_rawDescData
+}
+
+var file_proto_controller_proto_msgTypes = make([]protoimpl.MessageInfo, 41)
+var file_proto_controller_proto_goTypes = []any{
+	(*ExecuteCommandRequest)(nil),  // 0: controller.ExecuteCommandRequest
+	(*ExecuteCommandResponse)(nil), // 1: controller.ExecuteCommandResponse
+	(*ListCommandsRequest)(nil),    // 2: controller.ListCommandsRequest
+	(*CommandInfo)(nil),            // 3: controller.CommandInfo
+	(*ListCommandsResponse)(nil),   // 4: controller.ListCommandsResponse
+	(*ReloadConfigRequest)(nil),    // 5: controller.ReloadConfigRequest
+	(*ReloadConfigResponse)(nil),   // 6: controller.ReloadConfigResponse
+	(*HealthCheckRequest)(nil),     // 7: controller.HealthCheckRequest
+	(*HealthCheckResponse)(nil),    // 8: controller.HealthCheckResponse
+	(*PingRequest)(nil),            // 9: controller.PingRequest
+	(*PingResponse)(nil),           // 10: controller.PingResponse
+	(*SystemInfo)(nil),             // 11: controller.SystemInfo
+	(*VerifyPinRequest)(nil),       // 12: controller.VerifyPinRequest
+	(*VerifyPinResponse)(nil),      // 13: controller.VerifyPinResponse
+	(*GetVersionRequest)(nil),      // 14: controller.GetVersionRequest
+	(*GetVersionResponse)(nil),     // 15: controller.GetVersionResponse
+	(*GetStatusRequest)(nil),       // 16: controller.GetStatusRequest
+	(*GetStatusResponse)(nil),      // 17: controller.GetStatusResponse
+	(*TailLogsRequest)(nil),        // 18: controller.TailLogsRequest
+	(*LogLine)(nil),                // 19: controller.LogLine
+	(*SyncCommandsRequest)(nil),    // 20: controller.SyncCommandsRequest
+	(*SyncCommandsResponse)(nil),   // 21: controller.SyncCommandsResponse
+	(*UpdateAgentRequest)(nil),     // 22: controller.UpdateAgentRequest
+	(*UpdateAgentResponse)(nil),    // 23: controller.UpdateAgentResponse
+	(*UploadFileChunk)(nil),        // 24: controller.UploadFileChunk
+	(*UploadFileResponse)(nil),     // 25: controller.UploadFileResponse
+	(*DownloadFileRequest)(nil),    // 26: controller.DownloadFileRequest
+	(*FileChunk)(nil),              // 27: controller.FileChunk
+	(*TunnelMessage)(nil),          // 28: controller.TunnelMessage
+	(*TunnelRegister)(nil),         // 29: controller.TunnelRegister
+	(*TunnelRegisterAck)(nil),      // 30: controller.TunnelRegisterAck
+	(*TunnelRequest)(nil),          // 31: controller.TunnelRequest
+	(*TunnelResponse)(nil),         // 32: controller.TunnelResponse
+	(*EnrollDeviceRequest)(nil),    // 33: controller.EnrollDeviceRequest
+	(*EnrollDeviceResponse)(nil),   // 34: controller.EnrollDeviceResponse
+	nil,                            // 35: controller.CommandInfo.EnvEntry
+	nil,                            // 36: controller.HealthCheckResponse.MemoryEntry
+	nil,                            // 37: controller.HealthCheckResponse.ServicesEntry
+	nil,                            // 38: controller.GetStatusResponse.SystemInfoEntry
+	nil,                            // 39: controller.GetStatusResponse.ServiceStatusEntry
+	nil,                            // 40: controller.EnrollDeviceRequest.MetadataEntry
+}
+var file_proto_controller_proto_depIdxs = []int32{
+	35, // 0: controller.CommandInfo.env:type_name -> controller.CommandInfo.EnvEntry
+	3,  // 1: controller.ListCommandsResponse.commands:type_name -> controller.CommandInfo
+	11, // 2: controller.HealthCheckResponse.system:type_name -> controller.SystemInfo
+	36, // 3: controller.HealthCheckResponse.memory:type_name -> controller.HealthCheckResponse.MemoryEntry
+	37, // 4: controller.HealthCheckResponse.services:type_name -> controller.HealthCheckResponse.ServicesEntry
+	38, // 5: controller.GetStatusResponse.system_info:type_name -> controller.GetStatusResponse.SystemInfoEntry
+	39, // 6: controller.GetStatusResponse.service_status:type_name -> controller.GetStatusResponse.ServiceStatusEntry
+	3,  // 7: controller.SyncCommandsRequest.commands:type_name -> controller.CommandInfo
+	29, // 8: controller.TunnelMessage.register:type_name -> controller.TunnelRegister
+	30, // 9: controller.TunnelMessage.register_ack:type_name -> controller.TunnelRegisterAck
+	31, // 10: controller.TunnelMessage.request:type_name -> controller.TunnelRequest
+	32, // 11: controller.TunnelMessage.response:type_name -> controller.TunnelResponse
+	0,  // 12: controller.TunnelRequest.execute_command:type_name -> controller.ExecuteCommandRequest
+	2,  // 13: controller.TunnelRequest.list_commands:type_name -> controller.ListCommandsRequest
+	7,  // 14: controller.TunnelRequest.health_check:type_name -> controller.HealthCheckRequest
+	20, // 15: controller.TunnelRequest.sync_commands:type_name -> controller.SyncCommandsRequest
+	9,  // 16: controller.TunnelRequest.ping:type_name -> controller.PingRequest
+	22, // 17: controller.TunnelRequest.update_agent:type_name -> controller.UpdateAgentRequest
+	1,  // 18: controller.TunnelResponse.execute_command:type_name -> controller.ExecuteCommandResponse
+	4,  // 19: controller.TunnelResponse.list_commands:type_name -> controller.ListCommandsResponse
+	8,  // 20: controller.TunnelResponse.health_check:type_name -> controller.HealthCheckResponse
+	21, // 21: controller.TunnelResponse.sync_commands:type_name -> controller.SyncCommandsResponse
+	10, // 22: controller.TunnelResponse.ping:type_name -> controller.PingResponse
+	23, // 23: controller.TunnelResponse.update_agent:type_name -> controller.UpdateAgentResponse
+	40, // 24: controller.EnrollDeviceRequest.metadata:type_name -> controller.EnrollDeviceRequest.MetadataEntry
+	0,  // 25: controller.ControllerService.ExecuteCommand:input_type -> controller.ExecuteCommandRequest
+	2,  // 26: controller.ControllerService.ListCommands:input_type -> controller.ListCommandsRequest
+	5,  // 27: controller.ControllerService.ReloadConfig:input_type -> controller.ReloadConfigRequest
+	7,  // 28: controller.ControllerService.HealthCheck:input_type -> controller.HealthCheckRequest
+	9,  // 29: controller.ControllerService.Ping:input_type -> controller.PingRequest
+	12, // 30: controller.ControllerService.VerifyPin:input_type -> controller.VerifyPinRequest
+	14, // 31: controller.ControllerService.GetVersion:input_type -> controller.GetVersionRequest
+	16, // 32: controller.ControllerService.GetStatus:input_type -> controller.GetStatusRequest
+	18, // 33: controller.ControllerService.TailLogs:input_type -> controller.TailLogsRequest
+	20, // 34: controller.ControllerService.SyncCommands:input_type -> controller.SyncCommandsRequest
+	22, // 35: controller.ControllerService.UpdateAgent:input_type -> controller.UpdateAgentRequest
+	24, // 36: controller.ControllerService.UploadFile:input_type -> controller.UploadFileChunk
+	26, // 37: controller.ControllerService.DownloadFile:input_type -> controller.DownloadFileRequest
+	1,  // 38: controller.ControllerService.ExecuteCommand:output_type -> controller.ExecuteCommandResponse
+	4,  // 39: controller.ControllerService.ListCommands:output_type -> controller.ListCommandsResponse
+	6,  // 40: controller.ControllerService.ReloadConfig:output_type -> controller.ReloadConfigResponse
+	8,  // 41: controller.ControllerService.HealthCheck:output_type -> controller.HealthCheckResponse
+	10, // 42: controller.ControllerService.Ping:output_type -> controller.PingResponse
+	13, // 43: controller.ControllerService.VerifyPin:output_type -> controller.VerifyPinResponse
+	15, // 44: controller.ControllerService.GetVersion:output_type -> controller.GetVersionResponse
+	17, // 45: controller.ControllerService.GetStatus:output_type -> controller.GetStatusResponse
+	19, // 46: controller.ControllerService.TailLogs:output_type -> controller.LogLine
+	21, // 47: controller.ControllerService.SyncCommands:output_type -> controller.SyncCommandsResponse
+	23, // 48: controller.ControllerService.UpdateAgent:output_type -> controller.UpdateAgentResponse
+	25, // 49: controller.ControllerService.UploadFile:output_type -> controller.UploadFileResponse
+	27, // 50: controller.ControllerService.DownloadFile:output_type -> controller.FileChunk
+	38, // [38:51] is the sub-list for method output_type
+	25, // [25:38] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_proto_controller_proto_init() }
+func file_proto_controller_proto_init() {
+	if File_proto_controller_proto != nil {
+		return
+	}
+	file_proto_controller_proto_msgTypes[28].OneofWrappers = []any{
+		(*TunnelMessage_Register)(nil),
+		(*TunnelMessage_RegisterAck)(nil),
+		(*TunnelMessage_Request)(nil),
+		(*TunnelMessage_Response)(nil),
+	}
+	file_proto_controller_proto_msgTypes[31].OneofWrappers = []any{
+		(*TunnelRequest_ExecuteCommand)(nil),
+		(*TunnelRequest_ListCommands)(nil),
+		(*TunnelRequest_HealthCheck)(nil),
+		(*TunnelRequest_SyncCommands)(nil),
+		(*TunnelRequest_Ping)(nil),
+		(*TunnelRequest_UpdateAgent)(nil),
+	}
+	file_proto_controller_proto_msgTypes[32].OneofWrappers = []any{
+		(*TunnelResponse_ExecuteCommand)(nil),
+		(*TunnelResponse_ListCommands)(nil),
+		(*TunnelResponse_HealthCheck)(nil),
+		(*TunnelResponse_SyncCommands)(nil),
+		(*TunnelResponse_Ping)(nil),
+		(*TunnelResponse_UpdateAgent)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -599,7 +3012,7 @@ func file_proto_controller_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_controller_proto_rawDesc), len(file_proto_controller_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   41,
 			NumExtensions: 0,
 			NumServices:   1,
 		},